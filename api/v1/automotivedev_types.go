@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +32,280 @@ type AutomotiveDevSpec struct {
 
 	// BuildConfig defines the global configuration for build operations
 	BuildConfig *BuildConfig `json:"buildConfig,omitempty"`
+
+	// ImageDiscovery configures periodic import of images from external registries as Image resources
+	// +optional
+	ImageDiscovery *ImageDiscovery `json:"imageDiscovery,omitempty"`
+
+	// ImageGC configures garbage collection of Images that are no longer accessed
+	// +optional
+	ImageGC *ImageGCPolicy `json:"imageGC,omitempty"`
+
+	// Monitoring configures the build alerts and dashboard the operator installs for Prometheus and Grafana
+	// +optional
+	Monitoring *MonitoringConfig `json:"monitoring,omitempty"`
+
+	// GitHooks starts builds on the pushes Git providers send to the build API's /v1/hooks/git webhook
+	// +optional
+	GitHooks *GitHooks `json:"gitHooks,omitempty"`
+
+	// Exposure selects how build artifacts, and the build API URL builds report, are reached from outside
+	// the cluster. Routes need OpenShift; Ingress and Gateway work on any Kubernetes cluster.
+	// +optional
+	Exposure *Exposure `json:"exposure,omitempty"`
+
+	// FeatureGates turns features that are rolled out one cluster at a time on or off, e.g.
+	// "enableQueueing: true". The operator and the build API follow changes without restarting.
+	// Gates not set are off; gates this version does not know are ignored.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// GitHooks configures the build API's Git webhook receiver. GitHub and GitLab push events, tag pushes
+// included, start a build for every trigger matching their repository and ref, passing the pushed commit
+// to automotive-image-builder as --define GIT_COMMIT=<sha>.
+type GitHooks struct {
+	// SecretRef is the name of a secret in the operator namespace whose "secret" key authenticates deliveries:
+	// the key GitHub signs them with in X-Hub-Signature-256, or the token GitLab sends in X-Gitlab-Token
+	// +kubebuilder:validation:MinLength=1
+	SecretRef string `json:"secretRef"`
+
+	// Triggers map repositories and refs to the builds they start
+	// +optional
+	Triggers []GitTrigger `json:"triggers,omitempty"`
+
+	// Status reports the progress of triggered builds to the Git provider as commit statuses
+	// +optional
+	Status *GitStatusReporting `json:"status,omitempty"`
+}
+
+// GitTrigger starts a build from a template build when a branch or tag of a repository is pushed
+type GitTrigger struct {
+	// Repository is the full name of the repository, e.g. "myorg/manifests" or "group/subgroup/project"
+	// +kubebuilder:validation:MinLength=1
+	Repository string `json:"repository"`
+
+	// Branches are shell patterns of the branches whose pushes start a build, e.g. "main" or "release-*"
+	// +optional
+	Branches []string `json:"branches,omitempty"`
+
+	// Tags are shell patterns of the tags whose pushes start a build, e.g. "v*"
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// TemplateBuild is the name of an ImageBuild in Namespace whose inputs, as served by the build API's
+	// /v1/builds/{name}/template, the triggered builds reuse. Builds uploading local files cannot be templates.
+	// +kubebuilder:validation:MinLength=1
+	TemplateBuild string `json:"templateBuild"`
+
+	// Namespace holds the template build and the builds started
+	// Default: the operator namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// GitStatusReporting configures the commit statuses the operator sets for triggered builds: pending while
+// they run, then success or failure
+type GitStatusReporting struct {
+	// APIURL is the API of the Git provider, e.g. "https://api.github.com" or "https://gitlab.example.com/api/v4".
+	// The token is only ever sent there.
+	// +kubebuilder:validation:MinLength=1
+	APIURL string `json:"apiURL"`
+
+	// TokenSecretRef is the name of a secret in the operator namespace whose "token" key authenticates to the
+	// API: a GitHub token allowed to write commit statuses, or a GitLab token with the api scope
+	// +kubebuilder:validation:MinLength=1
+	TokenSecretRef string `json:"tokenSecretRef"`
+
+	// Context names the statuses on the commit
+	// Default: "automotive-dev/build"
+	// +optional
+	Context string `json:"context,omitempty"`
+}
+
+// Exposure types
+const (
+	ExposureRoute   = "Route"
+	ExposureIngress = "Ingress"
+	ExposureGateway = "Gateway"
+)
+
+// Exposure configures the resources the operator exposes artifacts with. Every exposed build gets the
+// host <build>-artifacts-<namespace>.<Domain>, except with Routes, whose hosts OpenShift assigns. The build
+// API is looked up as the Route, Ingress or HTTPRoute named ado-build-api in the build's namespace.
+type Exposure struct {
+	// Type is Route for OpenShift Routes, Ingress for networking.k8s.io Ingresses, or Gateway for
+	// gateway.networking.k8s.io HTTPRoutes. OAuth route auth needs Routes.
+	// +kubebuilder:validation:Enum=Route;Ingress;Gateway
+	// +kubebuilder:default=Route
+	Type string `json:"type,omitempty"`
+
+	// Domain is the DNS domain the hosts of artifacts are created under; required for Ingress and Gateway
+	// +optional
+	Domain string `json:"domain,omitempty"`
+
+	// Annotations are added to every Ingress or HTTPRoute, e.g. for cert-manager or the ingress controller
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Ingress configures exposure with Ingresses
+	// +optional
+	Ingress *IngressExposure `json:"ingress,omitempty"`
+
+	// Gateway configures exposure with HTTPRoutes
+	// +optional
+	Gateway *GatewayExposure `json:"gateway,omitempty"`
+}
+
+// IngressExposure configures the Ingresses of exposed artifacts
+type IngressExposure struct {
+	// ClassName is the IngressClass of the Ingresses, the cluster default when empty
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// TLSSecretName names a secret holding a certificate for the hosts under Domain, e.g. a wildcard
+	// certificate, in every build namespace. Artifacts are served over plain HTTP without it.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+}
+
+// GatewayExposure configures the HTTPRoutes of exposed artifacts
+type GatewayExposure struct {
+	// Name of the Gateway the HTTPRoutes attach to
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace of the Gateway, the build's namespace when empty
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName selects a listener of the Gateway
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+
+	// HTTPS reports that the listener terminates TLS, so artifact URLs use https
+	// +optional
+	HTTPS bool `json:"https,omitempty"`
+}
+
+// MonitoringConfig configures the PrometheusRule and Grafana dashboard ConfigMap the operator generates
+// from its build metrics in its namespace. The PrometheusRule needs the Prometheus Operator, or OpenShift
+// user workload monitoring.
+type MonitoringConfig struct {
+	// Enabled installs the alerts and the dashboard; disabling it removes them
+	Enabled bool `json:"enabled,omitempty"`
+
+	// BuildFailureRatePercent is the share of the builds of a namespace finished in the last hour that may
+	// fail before the AutomotiveBuildFailureRateHigh alert fires
+	// Default: 25
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	BuildFailureRatePercent int32 `json:"buildFailureRatePercent,omitempty"`
+
+	// UploadingStuckMinutes is how long a build may wait for its file uploads before the
+	// AutomotiveBuildStuckUploading alert fires
+	// Default: 60
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UploadingStuckMinutes int32 `json:"uploadingStuckMinutes,omitempty"`
+
+	// PVCPendingMinutes is how long the workspace PVC of a build may wait to be bound before the
+	// AutomotiveBuildWorkspacePending alert fires
+	// Default: 5
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PVCPendingMinutes int32 `json:"pvcPendingMinutes,omitempty"`
+
+	// RuleLabels are added to the PrometheusRule, for example to match the ruleSelector of a Prometheus
+	// +optional
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+
+	// DashboardLabels are the labels of the dashboard ConfigMap that make Grafana load it
+	// Default: grafana_dashboard: "1"
+	// +optional
+	DashboardLabels map[string]string `json:"dashboardLabels,omitempty"`
+}
+
+// ImageGCAction is what garbage collection does with a stale Image
+// +kubebuilder:validation:Enum=deprecate;delete
+type ImageGCAction string
+
+const (
+	// ImageGCDeprecate marks stale Images deprecated and leaves their artifacts in the registry
+	ImageGCDeprecate ImageGCAction = "deprecate"
+	// ImageGCDelete deletes the artifacts of stale Images from their registry and then the Images
+	ImageGCDelete ImageGCAction = "delete"
+)
+
+// ImageGCPolicy selects stale Images by the access metrics in their status. An Image is stale once it has not
+// been accessed for MaxAgeDays, counted from its build or creation if it never was, and has fewer than
+// MinAccesses accesses. Revoked Images are never collected, so their revocation keeps being enforced.
+type ImageGCPolicy struct {
+	// Enabled turns garbage collection on
+	Enabled bool `json:"enabled,omitempty"`
+
+	// MaxAgeDays is how many days an Image may go without being accessed
+	// Default: 90
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAgeDays int32 `json:"maxAgeDays,omitempty"`
+
+	// MinAccesses is the access count that keeps an Image however long ago it was last accessed
+	// Default: 0, any access count is collected
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinAccesses int64 `json:"minAccesses,omitempty"`
+
+	// Action is what happens to stale Images (deprecate, delete)
+	// Default: deprecate
+	// +optional
+	Action ImageGCAction `json:"action,omitempty"`
+
+	// Lifecycles are the lifecycles of the Images that are collected
+	// Default: candidate and deprecated
+	// +optional
+	Lifecycles []ImageLifecycle `json:"lifecycles,omitempty"`
+}
+
+// ImageDiscovery configures scanning of container registries for automotive images
+type ImageDiscovery struct {
+	// Enabled turns registry scanning on
+	Enabled bool `json:"enabled,omitempty"`
+
+	// IntervalMinutes specifies how often the repositories are scanned
+	// Default: 60
+	// +optional
+	IntervalMinutes int32 `json:"intervalMinutes,omitempty"`
+
+	// Repositories are the registry repositories to scan
+	Repositories []DiscoveryRepository `json:"repositories,omitempty"`
+}
+
+// DiscoveryRepository is a registry repository scanned for automotive images. A tag is imported when its
+// manifest carries the automotive.sdv.cloud.redhat.com/distro annotation, the automotive artifact type
+// (application/vnd.redhat.automotive.image.v1) or one of MediaTypes.
+type DiscoveryRepository struct {
+	// Repository is the registry host and repository path (e.g., "quay.io/myorg/automotive-images")
+	Repository string `json:"repository"`
+
+	// SecretRef is the name of a kubernetes.io/dockerconfigjson secret in the AutomotiveDev namespace
+	// holding pull credentials for the registry
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+
+	// TagPattern is a shell glob tags must match to be imported (e.g., "v*")
+	// Default: all tags
+	// +optional
+	TagPattern string `json:"tagPattern,omitempty"`
+
+	// MediaTypes are artifact or config media types that identify automotive images
+	// +optional
+	MediaTypes []string `json:"mediaTypes,omitempty"`
+
+	// Insecure allows plain HTTP connections to the registry
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
 }
 
 // BuildConfig defines configuration options for build operations
@@ -46,15 +322,385 @@ type BuildConfig struct {
 	// +optional
 	PVCSize string `json:"pvcSize,omitempty"`
 
+	// PVCBindTimeoutMinutes is how long a build waits for its workspace PVC to be bound before it fails
+	// Default: 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PVCBindTimeoutMinutes int32 `json:"pvcBindTimeoutMinutes,omitempty"`
+
+	// UnschedulableTimeoutMinutes fails builds whose pod the scheduler could not place for that long, e.g.
+	// when no node has their architecture or every such node is tainted. The scheduler's reason shows in the
+	// build's status either way
+	// Default: builds wait for a node until their run times out
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UnschedulableTimeoutMinutes int32 `json:"unschedulableTimeoutMinutes,omitempty"`
+
+	// UploadTimeoutMinutes is how long a build may wait in the Uploading phase without receiving any file
+	// before the build API's upload sweep cancels it, releasing its workspace PVC and upload pod
+	// Default: 120
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	UploadTimeoutMinutes int32 `json:"uploadTimeoutMinutes,omitempty"`
+
+	// MaxWorkspaceStorage caps the total size of the live build workspace PVCs in a namespace, e.g. "100Gi".
+	// Builds whose workspace would exceed it wait until other builds release theirs
+	// Default: unlimited
+	// +optional
+	MaxWorkspaceStorage string `json:"maxWorkspaceStorage,omitempty"`
+
+	// MaxConcurrentBuilds caps how many builds of all namespaces hold a workspace while they run. Builds beyond
+	// it wait, taking turns between requesters
+	// Default: unlimited
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentBuilds int32 `json:"maxConcurrentBuilds,omitempty"`
+
+	// NodeSelector restricts build pods to the nodes with these labels, e.g. nodes reserved for builds
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// PrePull pulls builder images ahead of builds on the nodes builds run on
+	// +optional
+	PrePull *PrePullPolicy `json:"prePull,omitempty"`
+
+	// Unprivileged runs the build step rootless, in a user namespace, for clusters that do not admit privileged
+	// pods: without the privileged security context and the /dev host path. Such builds cannot use loop
+	// devices, so they are limited to the export formats that need none, e.g. tar and container
+	// +optional
+	Unprivileged bool `json:"unprivileged,omitempty"`
+
 	// RuntimeClassName specifies the runtime class to use for the build pod
 	// More info: https://kubernetes.io/docs/concepts/containers/runtime-class/
 	// +optional
 	RuntimeClassName string `json:"runtimeClassName,omitempty"`
 
+	// ArtifactNameTemplate is the default Go template naming build artifacts, without their extension, from
+	// the fields .Distro, .Target, .Arch, .Name and .Date, e.g. "{{.Name}}-{{.Target}}-{{.Date}}". Builds may
+	// set their own
+	// Default: "{{.Distro}}-{{.Target}}"
+	// +kubebuilder:validation:MaxLength=256
+	// +optional
+	ArtifactNameTemplate string `json:"artifactNameTemplate,omitempty"`
+
 	// ServeExpiryHours specifies how long to serve build artifacts before automatic cleanup
 	// Default: 24
 	// +optional
 	ServeExpiryHours int32 `json:"serveExpiryHours,omitempty"`
+
+	// ServiceAccountName is the service account used to run build TaskRuns
+	// Default: a dedicated "automotive-dev-build" service account managed by the operator in each build namespace
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// BuilderImage controls how the automotive-image-builder image of each build is pinned and verified
+	// +optional
+	BuilderImage *BuilderImagePolicy `json:"builderImage,omitempty"`
+
+	// KeepWorkspaceOnFailure is the default for ImageBuilds that do not set KeepWorkspaceOnFailure
+	// +optional
+	KeepWorkspaceOnFailure bool `json:"keepWorkspaceOnFailure,omitempty"`
+
+	// WorkspaceKeep lists shell patterns of workspace files a successful build keeps besides its artifact, the
+	// artifact's parts, metadata and scan report, and image.json. Everything else, such as the uncompressed
+	// export next to a compressed artifact, is removed once the artifact is written.
+	// +optional
+	WorkspaceKeep []string `json:"workspaceKeep,omitempty"`
+
+	// FailedWorkspaceTTLHours specifies how long the kept workspace of a failed build is served before cleanup
+	// Default: 6
+	// +optional
+	FailedWorkspaceTTLHours int32 `json:"failedWorkspaceTTLHours,omitempty"`
+
+	// Catalog adds distros, targets and architectures to the ones the build API accepts by default
+	// +optional
+	Catalog *BuildCatalog `json:"catalog,omitempty"`
+
+	// Profiles are target board presets builds select by name, replacing built-in ones of the same name
+	// +optional
+	Profiles []BuildProfile `json:"profiles,omitempty"`
+
+	// Scan configures a vulnerability scan of every build's output before it is served
+	// +optional
+	Scan *ScanPolicy `json:"scan,omitempty"`
+
+	// Images points the images builds run at other locations, e.g. mirrors on an air-gapped cluster
+	// +optional
+	Images *ImageOverrides `json:"images,omitempty"`
+
+	// RouteAuth is the default protection of artifact Routes for ImageBuilds that do not set RouteAuth
+	// +optional
+	RouteAuth *RouteAuth `json:"routeAuth,omitempty"`
+
+	// LintRulesConfigMap names a ConfigMap in the operator namespace whose "rules.yaml" key holds the
+	// manifest lint rules the build API checks before accepting a build
+	// +optional
+	LintRulesConfigMap string `json:"lintRulesConfigMap,omitempty"`
+
+	// ExtendedResources lists the extended resources builds may request. Builds requesting a resource that
+	// is not listed, or more of it than allowed, fail
+	// +optional
+	ExtendedResources []AllowedExtendedResource `json:"extendedResources,omitempty"`
+
+	// AllowedPipelines lists the Pipelines of other namespaces that ImageBuild PipelineRefs may name. Pipelines
+	// of the build's own namespace are always allowed
+	// +optional
+	AllowedPipelines []AllowedPipeline `json:"allowedPipelines,omitempty"`
+
+	// AllowedAIBArgs lists the automotive-image-builder flags builds may pass in their extra or override
+	// args, e.g. "--define". It replaces the build API's built-in list. Flags the build API does not know
+	// to take a value are switches, which cannot be given one
+	// Default: --verbose, --define, --extend-define, --distro, --target, --arch, --export, --mode and --fusa
+	// +optional
+	AllowedAIBArgs []string `json:"allowedAIBArgs,omitempty"`
+
+	// Access restricts who sees builds through the build API
+	// +optional
+	Access *BuildAccess `json:"access,omitempty"`
+
+	// IntermediateRegistry runs a registry in the operator namespace that builds push intermediate
+	// container content to, e.g. the containers package-mode and container-target builds embed
+	// +optional
+	IntermediateRegistry *IntermediateRegistry `json:"intermediateRegistry,omitempty"`
+
+	// Pricing is the price table the cost of each finished build is estimated with. Builds finished without
+	// one have no cost
+	// +optional
+	Pricing *BuildPricing `json:"pricing,omitempty"`
+}
+
+// BuildPricing prices the nodes and storage builds use. Prices are decimal numbers, e.g. "0.384", in Currency.
+// A build costs the hourly price of the node of each of its pods for as long as the pod ran, plus its
+// workspace storage for as long as the build ran.
+type BuildPricing struct {
+	// Currency the prices are in; it is only shown next to costs
+	// Default: "USD"
+	// +optional
+	Currency string `json:"currency,omitempty"`
+
+	// InstanceTypes prices nodes by their node.kubernetes.io/instance-type label
+	// +optional
+	InstanceTypes []InstanceTypePrice `json:"instanceTypes,omitempty"`
+
+	// DefaultHourlyPrice prices the nodes no entry of InstanceTypes matches
+	// Default: "0"
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	DefaultHourlyPrice string `json:"defaultHourlyPrice,omitempty"`
+
+	// StorageGiBMonthPrice is the price of a GiB of workspace storage for a month of 730 hours
+	// Default: "0"
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	// +optional
+	StorageGiBMonthPrice string `json:"storageGiBMonthPrice,omitempty"`
+}
+
+// InstanceTypePrice is the hourly price of a type of node
+type InstanceTypePrice struct {
+	// InstanceType is the node.kubernetes.io/instance-type label of the nodes, e.g. "m6i.2xlarge"
+	// +kubebuilder:validation:MinLength=1
+	InstanceType string `json:"instanceType"`
+
+	// HourlyPrice is the price of an hour on such a node
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	HourlyPrice string `json:"hourlyPrice"`
+}
+
+// IntermediateRegistry configures the in-cluster registry for intermediate build content. Each build gets a
+// repository of its own, <namespace>/<build>, whose address is passed to the build as the
+// intermediate_registry define and the INTERMEDIATE_REGISTRY environment variable. The repository is deleted
+// once the build stops serving its artifact, or once it finishes when it serves none.
+type IntermediateRegistry struct {
+	// Enabled runs the registry; turning it off deletes it with its storage
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// StorageSize is the size of the registry's PersistentVolumeClaim; it only applies when the claim is created
+	// Default: "20Gi"
+	// +optional
+	StorageSize string `json:"storageSize,omitempty"`
+
+	// StorageClass is the storage class of the registry's PersistentVolumeClaim
+	// Default: the cluster's default storage class
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// BuildAccess restricts the builds a user of the build API can see. By default everyone allowed to use a
+// namespace sees all its builds
+type BuildAccess struct {
+	// Restricted makes a build visible only to its requester, the members of the groups it is shared
+	// with and AdminGroups: others can neither get it, follow its logs nor download its artifacts, and
+	// listings leave it out. A build is shared with the groups its request names, or else the groups of
+	// its requester
+	// +optional
+	Restricted bool `json:"restricted,omitempty"`
+
+	// AdminGroups see every build, e.g. release engineering
+	// +optional
+	AdminGroups []string `json:"adminGroups,omitempty"`
+}
+
+// AllowedExtendedResource is an extended resource builds may request
+type AllowedExtendedResource struct {
+	// Name is the resource name a device plugin advertises, e.g. "nvidia.com/gpu"
+	// +kubebuilder:validation:MinLength=1
+	Name corev1.ResourceName `json:"name"`
+
+	// Max is the largest quantity a single build may request
+	// Default: no limit
+	// +optional
+	Max *resource.Quantity `json:"max,omitempty"`
+}
+
+// AllowedPipeline is a Pipeline, or every Pipeline of a namespace, builds may run with a PipelineRef
+type AllowedPipeline struct {
+	// Namespace of the Pipeline
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Name of the Pipeline
+	// Default: every Pipeline of the namespace
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// ImageOverrides names the images the operator runs for builds; an empty field keeps the default image.
+// Together with ScanPolicy.Image they cover every image a build pulls.
+type ImageOverrides struct {
+	// Builder is the automotive-image-builder image of builds that do not name one
+	// Default: "quay.io/centos-sig-automotive/automotive-image-builder:1.0.0"
+	// +optional
+	Builder string `json:"builder,omitempty"`
+
+	// Yq prepares the manifest in the build task
+	// Default: "quay.io/konflux-ci/yq:latest"
+	// +optional
+	Yq string `json:"yq,omitempty"`
+
+	// Oras pushes artifacts to OCI registries
+	// Default: "ghcr.io/oras-project/oras:v1.2.0"
+	// +optional
+	Oras string `json:"oras,omitempty"`
+
+	// FileServer runs the pods that receive uploaded files and serve artifacts
+	// Default: "quay.io/nginx/nginx-unprivileged:latest"
+	// +optional
+	FileServer string `json:"fileServer,omitempty"`
+
+	// OAuthProxy protects artifact Routes whose RouteAuth type is OAuth
+	// Default: "registry.redhat.io/openshift4/ose-oauth-proxy:latest"
+	// +optional
+	OAuthProxy string `json:"oauthProxy,omitempty"`
+
+	// Registry runs the intermediate registry of builds
+	// Default: "docker.io/library/registry:2.8.3"
+	// +optional
+	Registry string `json:"registry,omitempty"`
+
+	// RequireDigests refuses images that are not pinned by digest (name@sha256:...), the defaults and the scan
+	// image included: the Tekton tasks are not installed and builds fail until every image is pinned. The
+	// builder image is exempt unless BuilderImage disables its digest pinning, as builds resolve it themselves.
+	// +optional
+	RequireDigests bool `json:"requireDigests,omitempty"`
+}
+
+// PrePullPolicy configures a DaemonSet in the operator namespace that pulls images on every node matching
+// BuildConfig.NodeSelector, so the first build on a node does not wait for a multi-gigabyte image pull
+type PrePullPolicy struct {
+	// Enabled runs the DaemonSet; disabling it removes it
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Images are the images to pull. They must provide /bin/sh
+	// Default: the builder image of BuildConfig.Images
+	// +optional
+	Images []string `json:"images,omitempty"`
+}
+
+// ScanPolicy configures the post-build scan. The scanner's JSON report is kept next to the build's artifact
+// and its findings are summarized in the ImageBuild status.
+type ScanPolicy struct {
+	// Enabled runs the scan after every successful build
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Image is the trivy image that runs the scan
+	// Default: "docker.io/aquasec/trivy:0.57.1"
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// MaxCritical is the number of critical vulnerabilities a build may have and still be served. Builds
+	// exceeding it are blocked: their artifact can be neither downloaded nor exposed. Unset never blocks.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxCritical *int32 `json:"maxCritical,omitempty"`
+}
+
+// BuildCatalog lists additional values builds may use. The build API rejects builds whose distro, target or
+// architecture is neither built in nor listed here.
+type BuildCatalog struct {
+	// Distros are additional automotive-image-builder distributions, e.g. a custom distro definition
+	// +optional
+	Distros []string `json:"distros,omitempty"`
+
+	// Targets are additional automotive-image-builder targets
+	// +optional
+	Targets []string `json:"targets,omitempty"`
+
+	// Architectures are additional target architectures
+	// +optional
+	Architectures []string `json:"architectures,omitempty"`
+}
+
+// BuildProfile bundles the settings of a target board. Settings a build gives itself take precedence over
+// the profile's; the profile's AIB args and defines come before the build's own.
+type BuildProfile struct {
+	// Name selects the profile, e.g. "ridesx4"
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9_]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Description tells users what the profile builds for
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Target is the automotive-image-builder target
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Architecture is the target architecture
+	// +optional
+	Architecture string `json:"architecture,omitempty"`
+
+	// ExportFormat is the output format, e.g. "aboot.simg"
+	// +optional
+	ExportFormat string `json:"exportFormat,omitempty"`
+
+	// AIBExtraArgs are added to the automotive-image-builder command, one argument per item
+	// +optional
+	AIBExtraArgs []string `json:"aibExtraArgs,omitempty"`
+
+	// CustomDefs are defines in KEY=VALUE form
+	// +optional
+	CustomDefs []string `json:"customDefs,omitempty"`
+}
+
+// BuilderImagePolicy controls how builds resolve and trust their automotive-image-builder image. By default
+// the image tag is resolved to a digest when the build starts and the build runs that digest.
+type BuilderImagePolicy struct {
+	// DisableDigestPinning runs builds with the builder image reference as given, without resolving it to a digest.
+	// It has no effect when CosignPublicKeySecretRef is set, since only a pinned image can be verified.
+	// +optional
+	DisableDigestPinning bool `json:"disableDigestPinning,omitempty"`
+
+	// PullSecretRef is the name of a kubernetes.io/dockerconfigjson secret in the operator namespace with
+	// credentials for reading the builder image from its registry
+	// +optional
+	PullSecretRef string `json:"pullSecretRef,omitempty"`
+
+	// CosignPublicKeySecretRef is the name of a secret in the operator namespace whose "cosign.pub" key holds a
+	// PEM-encoded public key. When set, builds fail unless their builder image has a cosign signature made with it.
+	// +optional
+	CosignPublicKeySecretRef string `json:"cosignPublicKeySecretRef,omitempty"`
 }
 
 // AutomotiveDevStatus defines the observed state of AutomotiveDev
@@ -67,6 +713,88 @@ type AutomotiveDevStatus struct {
 
 	// LastUpdated is when the status was last updated
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the Tekton tasks and pipeline were last reconciled from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions report whether the Tekton resources are installed: TasksReady, PipelineReady and VersionInstalled,
+	// whether the monitoring resources are when monitoring is enabled: MonitoringReady, whether images are
+	// pulled on the build nodes when pre-pulling is enabled: ImagesPrePulled, and whether the intermediate
+	// registry serves requests when it is enabled: IntermediateRegistryReady
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// OperatorVersion is the version of the operator that last installed the Tekton resources
+	OperatorVersion string `json:"operatorVersion,omitempty"`
+
+	// ManagedResources lists the installed Tekton resources with a hash of their spec
+	// +optional
+	ManagedResources []ManagedResource `json:"managedResources,omitempty"`
+
+	// Discovery reports the outcome of the last registry scan
+	Discovery *ImageDiscoveryStatus `json:"discovery,omitempty"`
+
+	// PrePull reports the progress of the image pre-pull DaemonSet while it is enabled
+	// +optional
+	PrePull *PrePullStatus `json:"prePull,omitempty"`
+
+	// EnabledFeatures are the feature gates of the spec this operator version knows and turns on
+	// +optional
+	EnabledFeatures []string `json:"enabledFeatures,omitempty"`
+}
+
+// PrePullStatus reports on how many build nodes the pre-pull DaemonSet has pulled its images
+type PrePullStatus struct {
+	// Images are the images being pulled
+	Images []string `json:"images,omitempty"`
+
+	// DesiredNodes is the number of nodes the images should be pulled on
+	DesiredNodes int32 `json:"desiredNodes"`
+
+	// ReadyNodes is the number of nodes that pulled every image
+	ReadyNodes int32 `json:"readyNodes"`
+}
+
+// AutomotiveDev condition types
+const (
+	// AutomotiveDevTasksReady is True when the Tekton tasks are installed as the spec describes
+	AutomotiveDevTasksReady = "TasksReady"
+	// AutomotiveDevPipelineReady is True when the Tekton pipeline is installed
+	AutomotiveDevPipelineReady = "PipelineReady"
+	// AutomotiveDevVersionInstalled is True when all Tekton resources of the running operator version are installed
+	AutomotiveDevVersionInstalled = "VersionInstalled"
+	// AutomotiveDevMonitoringReady is True when the PrometheusRule and dashboard of enabled monitoring are installed
+	AutomotiveDevMonitoringReady = "MonitoringReady"
+	// AutomotiveDevImagesPrePulled is True when enabled pre-pulling has pulled its images on every build node
+	AutomotiveDevImagesPrePulled = "ImagesPrePulled"
+	// AutomotiveDevIntermediateRegistryReady is True when the enabled intermediate registry serves requests
+	AutomotiveDevIntermediateRegistryReady = "IntermediateRegistryReady"
+)
+
+// ManagedResource identifies a resource the operator installed for an AutomotiveDev
+type ManagedResource struct {
+	// Kind is the resource kind, e.g. Task or Pipeline
+	Kind string `json:"kind"`
+
+	// Name is the resource name in the operator namespace
+	Name string `json:"name"`
+
+	// Hash is the SHA-256 of the resource's spec as last applied
+	Hash string `json:"hash"`
+}
+
+// ImageDiscoveryStatus reports the outcome of a registry scan
+type ImageDiscoveryStatus struct {
+	// LastScanTime is when the repositories were last scanned
+	LastScanTime *metav1.Time `json:"lastScanTime,omitempty"`
+
+	// ImportedImages is the number of Image resources created or updated by the last scan
+	ImportedImages int32 `json:"importedImages,omitempty"`
+
+	// Message lists the repositories that could not be scanned, if any
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -20,6 +20,24 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ImageLifecycle is the release state of an Image
+// +kubebuilder:validation:Enum=candidate;released;deprecated;revoked
+type ImageLifecycle string
+
+const (
+	// ImageLifecycleCandidate is a freshly produced image that has not been released yet
+	ImageLifecycleCandidate ImageLifecycle = "candidate"
+	// ImageLifecycleReleased is an image promoted for general use
+	ImageLifecycleReleased ImageLifecycle = "released"
+	// ImageLifecycleDeprecated is an image that still works but should no longer be used for new deployments
+	ImageLifecycleDeprecated ImageLifecycle = "deprecated"
+	// ImageLifecycleRevoked is an image that must not be downloaded anymore
+	ImageLifecycleRevoked ImageLifecycle = "revoked"
+)
+
+// ImageGarbageCollected is the Image condition reporting what garbage collection did with a stale Image
+const ImageGarbageCollected = "GarbageCollected"
+
 // ImageSpec defines the desired state of Image
 type ImageSpec struct {
 	// Distro specifies the distribution
@@ -54,6 +72,11 @@ type ImageSpec struct {
 
 	// Version specifies the version of this image
 	Version string `json:"version,omitempty"`
+
+	// Lifecycle is the release state of the image (candidate, released, deprecated, revoked)
+	// +kubebuilder:default=candidate
+	// +optional
+	Lifecycle ImageLifecycle `json:"lifecycle,omitempty"`
 }
 
 // ImageSize contains size information about the image
@@ -128,6 +151,9 @@ type ImageStatus struct {
 
 	// LastAccessed is when the image was last accessed
 	LastAccessed *metav1.Time `json:"lastAccessed,omitempty"`
+
+	// ObservedLifecycle is the lifecycle state last processed by the controller
+	ObservedLifecycle ImageLifecycle `json:"observedLifecycle,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -138,6 +164,7 @@ type ImageStatus struct {
 // +kubebuilder:printcolumn:name="Location Type",type=string,JSONPath=`.spec.location.type`
 // +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
 // +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="Lifecycle",type=string,JSONPath=`.spec.lifecycle`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Image is the Schema for the images API
@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -38,24 +39,59 @@ type ImageBuildSpec struct {
 	// ExportFormat specifies the output format (image, qcow2)
 	ExportFormat string `json:"exportFormat,omitempty"`
 
+	// ArtifactNameTemplate is a Go template naming the build's artifact, without its extension, from the fields
+	// .Distro, .Target, .Arch, .Name (the ImageBuild's) and .Date (the UTC day it was created, e.g. 20261015)
+	// Default: the AutomotiveDev's BuildConfig.ArtifactNameTemplate, else "{{.Distro}}-{{.Target}}"
+	// +kubebuilder:validation:MaxLength=256
+	// +optional
+	ArtifactNameTemplate string `json:"artifactNameTemplate,omitempty"`
+
 	// Mode specifies the build mode (package, image)
 	Mode string `json:"mode,omitempty"`
 
+	// Profile is the build profile the build API applied to this spec
+	// +optional
+	Profile string `json:"profile,omitempty"`
+
 	// StorageClass is the name of the storage class to use for the build PVC
 	StorageClass string `json:"storageClass,omitempty"`
 
+	// WorkspaceSize is the size of the build's workspace PVC, e.g. "24Gi". The build API estimates it from the
+	// manifests when the request does not set it
+	// Default: the AutomotiveDev's BuildConfig.PVCSize, else "8Gi"
+	// +optional
+	WorkspaceSize string `json:"workspaceSize,omitempty"`
+
+	// WorkspaceSizeEstimate tells what the build API estimated WorkspaceSize from; it is informational
+	// +optional
+	WorkspaceSizeEstimate string `json:"workspaceSizeEstimate,omitempty"`
+
 	// AutomotiveImageBuilder specifies the image to use for building
 	AutomotiveImageBuilder string `json:"automotiveImageBuilder,omitempty"`
 
 	// ManifestConfigMap specifies the name of the ConfigMap containing the manifest configuration
 	ManifestConfigMap string `json:"manifestConfigMap,omitempty"`
 
+	// ManifestFile is the key of the main manifest in ManifestConfigMap. Other manifest files in the
+	// ConfigMap are placed next to it so it can include them. When empty, the first *.aib.yml or
+	// *.mpp.yml file is used
+	ManifestFile string `json:"manifestFile,omitempty"`
+
+	// ManifestRef is an OCI artifact holding the manifests to build, pulled into the manifest workspace
+	// with the registry credentials of EnvSecretRef. ManifestFile selects the main manifest among its
+	// files; ManifestConfigMap then only supplies custom definitions and extra arguments
+	// +optional
+	ManifestRef string `json:"manifestRef,omitempty"`
+
 	// Publishers defines where to publish the built artifacts
 	Publishers *Publishers `json:"publishers,omitempty"`
 
 	// RuntimeClassName specifies the runtime class to use for the build pod
 	RuntimeClassName string `json:"runtimeClassName,omitempty"`
 
+	// ServiceAccountName overrides the service account used to run the build TaskRun
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
 	// ServeArtifact determines whether to make the built artifact available for download
 	ServeArtifact bool `json:"serveArtifact,omitempty"`
 
@@ -73,10 +109,148 @@ type ImageBuildSpec struct {
 	// for private registry authentication (e.g., REGISTRY_USERNAME, REGISTRY_PASSWORD, REGISTRY_AUTH_FILE)
 	EnvSecretRef string `json:"envSecretRef,omitempty"`
 
-	// Compression specifies the compression algorithm for artifacts
-	// +kubebuilder:validation:Enum=lz4;gzip
+	// Compression specifies the compression algorithm for artifacts. "none" serves them uncompressed, which
+	// is faster where the artifacts do not leave the cluster's network
+	// +kubebuilder:validation:Enum=lz4;gzip;none
 	// +kubebuilder:default=gzip
 	Compression string `json:"compression,omitempty"`
+
+	// EncryptionKeySecretRef is the name of a secret in the build's namespace whose "key" entry is the
+	// passphrase the artifacts are encrypted with (AES-256-CBC, PBKDF2) while they are packaged. Encrypted
+	// artifacts are served as is and decrypted by their client; they cannot be converted. A PipelineRef gets
+	// the secret as the encryption-key workspace and must declare it
+	// +optional
+	EncryptionKeySecretRef string `json:"encryptionKeySecretRef,omitempty"`
+
+	// KeepWorkspaceOnFailure keeps the workspace of a failed build, including the automotive-image-builder
+	// build directory logs, and serves it for debugging until the AutomotiveDev's FailedWorkspaceTTLHours pass.
+	// When unset, the AutomotiveDev's BuildConfig.KeepWorkspaceOnFailure applies
+	// +optional
+	KeepWorkspaceOnFailure *bool `json:"keepWorkspaceOnFailure,omitempty"`
+
+	// BuildInfo bakes the build's provenance into the image as /etc/automotive-build-info
+	// +optional
+	BuildInfo *BuildInfo `json:"buildInfo,omitempty"`
+
+	// RouteAuth protects the artifact Route of ExposeRoute. When unset, the AutomotiveDev's
+	// BuildConfig.RouteAuth applies
+	// +optional
+	RouteAuth *RouteAuth `json:"routeAuth,omitempty"`
+
+	// PipelineRef runs the build with a user maintained Tekton Pipeline instead of the operator's build
+	// task. The pipeline gets the build's standard params and the shared-workspace and
+	// manifest-config-workspace workspaces, and must declare them
+	// +optional
+	PipelineRef *PipelineRef `json:"pipelineRef,omitempty"`
+
+	// ExtendedResources requests extended resources for the build step, such as GPUs ("nvidia.com/gpu": 1) or
+	// other device plugin resources, for builds whose steps need hardware in the loop. The build pod tolerates
+	// the NoSchedule taints named after them. Each must be allowed by the AutomotiveDev's
+	// BuildConfig.ExtendedResources, and builds with a PipelineRef cannot request any
+	// +optional
+	ExtendedResources corev1.ResourceList `json:"extendedResources,omitempty"`
+
+	// Conversions are formats the artifact of the completed build is converted to next to it in the workspace
+	// while it is served, e.g. "vmdk". Only raw image and qcow2 artifacts can be converted; the status reports
+	// the progress of each conversion
+	// +kubebuilder:validation:items:Enum=qcow2;vmdk;vdi;vhdx;simg
+	// +optional
+	Conversions []string `json:"conversions,omitempty"`
+
+	// OSTree commits the build's tree to a remote ostree repository, so devices update over the air by pulling
+	// only what changed instead of flashing a full disk image. A PipelineRef must declare the ostree-ref param
+	// +optional
+	OSTree *OSTreeUpdate `json:"ostree,omitempty"`
+
+	// InputPVC mounts an existing PersistentVolumeClaim of the build's namespace into the build at
+	// /workspace/input, so manifests reference large files staged on it, e.g. vendor blobs, as
+	// source_path: /workspace/input/<file> instead of uploading them with every build. A PipelineRef must
+	// declare the input workspace
+	// +optional
+	InputPVC *InputPVC `json:"inputPVC,omitempty"`
+}
+
+// InputPVC names the claim a build reads input files from
+type InputPVC struct {
+	// ClaimName is the PersistentVolumeClaim in the build's namespace. Claims that are ReadWriteOnce can only be
+	// mounted by builds running on the node that uses them, so shared inputs belong on ReadOnlyMany or
+	// ReadWriteMany claims
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// SubPath mounts this directory of the claim instead of its root
+	// +kubebuilder:validation:Pattern=`^[^/]+(/[^/]+)*$`
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+
+	// ReadOnly mounts the claim read-only, so builds cannot change the staged files
+	// +optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// OSTreeUpdate configures the ostree commit of a build. The commit is made on top of the ref's current commit in
+// the remote repository and pushed to it with rsync over SSH.
+type OSTreeUpdate struct {
+	// RepositoryURL is the remote archive mode repository as an rsync destination, e.g.
+	// ostree@updates.example.com:/srv/ostree/repo
+	// +kubebuilder:validation:Pattern=`^[^\s:/]+:([^\s/][^\s]*|/[^\s/][^\s]*)$`
+	RepositoryURL string `json:"repositoryUrl"`
+
+	// Ref is the branch the commit is made on and pushed to, e.g. autosd/aarch64/qemu
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_][-._A-Za-z0-9]*(/[A-Za-z0-9_][-._A-Za-z0-9]*)*$`
+	Ref string `json:"ref"`
+
+	// SecretRef names a kubernetes.io/ssh-auth secret in the build's namespace whose ssh-privatekey entry logs in
+	// to the repository's host. Its optional known_hosts entry pins the host's key, which is otherwise trusted on
+	// first use
+	// +optional
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+// PipelineRef names the Tekton Pipeline a build runs
+type PipelineRef struct {
+	// Name of the Pipeline
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace of the Pipeline, the ImageBuild's namespace when empty. Pipelines in other namespaces must
+	// be allowed by the AutomotiveDev's BuildConfig.AllowedPipelines and are fetched with Tekton's cluster resolver
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Artifact route protection types
+const (
+	RouteAuthNone  = "None"
+	RouteAuthBasic = "Basic"
+	RouteAuthOAuth = "OAuth"
+)
+
+// RouteAuth configures how the artifact Route authenticates downloads. Protected routes are served
+// over TLS with edge termination.
+type RouteAuth struct {
+	// Type is None, Basic for HTTP basic auth against an htpasswd file, or OAuth for an OpenShift
+	// oauth-proxy allowing users who can get the ImageBuild
+	// +kubebuilder:validation:Enum=None;Basic;OAuth
+	// +kubebuilder:default=None
+	Type string `json:"type,omitempty"`
+
+	// HtpasswdSecretRef names a secret in the build's namespace whose "auth" key holds the htpasswd
+	// file of Basic auth
+	// +optional
+	HtpasswdSecretRef string `json:"htpasswdSecretRef,omitempty"`
+}
+
+// BuildInfo configures the provenance file written into the image. The file is in os-release format and
+// records the build name and UID, the builder image, the manifest hash, the build time and GitRef
+type BuildInfo struct {
+	// Enabled adds /etc/automotive-build-info to the image
+	Enabled bool `json:"enabled,omitempty"`
+
+	// GitRef is the source revision the manifest was taken from, recorded as given
+	// +kubebuilder:validation:MaxLength=256
+	// +optional
+	GitRef string `json:"gitRef,omitempty"`
 }
 
 // Publishers defines the configuration for artifact publishing
@@ -111,17 +285,219 @@ type ImageBuildStatus struct {
 	// PVCName is the name of the PVC where the artifact is stored
 	PVCName string `json:"pvcName,omitempty"`
 
+	// WorkspaceSize is the size the workspace PVC in PVCName was created with; compare it with the
+	// WorkspaceBytes and PrunedWorkspaceBytes of ResourceUsage to right-size later builds
+	// +optional
+	WorkspaceSize string `json:"workspaceSize,omitempty"`
+
 	// ArtifactPath is the path inside the PVC where the artifact is stored
 	ArtifactPath string `json:"artifactPath,omitempty"`
 
 	// ArtifactFileName is the name of the artifact file inside the PVC
 	ArtifactFileName string `json:"artifactFileName,omitempty"`
 
+	// ArtifactSize is the size of the artifact in bytes
+	ArtifactSize int64 `json:"artifactSize,omitempty"`
+
+	// ArtifactSHA256 is the hex SHA-256 checksum of the artifact file
+	ArtifactSHA256 string `json:"artifactSha256,omitempty"`
+
+	// ArtifactContentType is the media type of the artifact once decompressed and decrypted, e.g.
+	// application/x-android-sparse-image, as the build recorded it when packaging the artifact
+	// +optional
+	ArtifactContentType string `json:"artifactContentType,omitempty"`
+
 	// TaskRunName is the name of the active TaskRun for this build
 	TaskRunName string `json:"taskRunName,omitempty"`
 
+	// PipelineRunName is the name of the active PipelineRun of a build with a PipelineRef
+	PipelineRunName string `json:"pipelineRunName,omitempty"`
+
 	// ArtifactURL is the route URL created to expose the artifacts
 	ArtifactURL string `json:"artifactURL,omitempty"`
+
+	// BuilderImageDigest is the digest of the automotive-image-builder image the build runs
+	BuilderImageDigest string `json:"builderImageDigest,omitempty"`
+
+	// ManifestSHA256 is the hex SHA-256 of the files of ManifestConfigMap as they were when the build was
+	// created. The build fails rather than build from a ConfigMap that was modified since.
+	// +optional
+	ManifestSHA256 string `json:"manifestSha256,omitempty"`
+
+	// OSTreeCommit is the checksum of the commit the build pushed to its OSTree ref
+	// +optional
+	OSTreeCommit string `json:"ostreeCommit,omitempty"`
+
+	// WorkspaceExpiryTime is when the kept workspace of a failed build stops being served
+	WorkspaceExpiryTime *metav1.Time `json:"workspaceExpiryTime,omitempty"`
+
+	// Scan summarizes the post-build vulnerability scan, when the AutomotiveDev enables one
+	Scan *ScanResult `json:"scan,omitempty"`
+
+	// ResourceUsage is what the build step consumed, to right-size build resources and memory volumes
+	// +optional
+	ResourceUsage *ResourceUsage `json:"resourceUsage,omitempty"`
+
+	// Cost is the estimated cost of the finished build, when the AutomotiveDev's BuildConfig.Pricing sets prices
+	// +optional
+	Cost *BuildCost `json:"cost,omitempty"`
+
+	// Download tells how to retrieve the outputs of a completed build while its artifact is served
+	// +optional
+	Download *DownloadInfo `json:"download,omitempty"`
+
+	// Conversions report the conversions of the artifact the spec requests
+	// +optional
+	Conversions []ArtifactConversion `json:"conversions,omitempty"`
+
+	// Conditions report the health of the build's resources: WorkspaceBound, PodScheduled and ArtifactServing
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ImageBuild condition types
+const (
+	// ImageBuildArtifactServing is True while the artifact pod of a completed build is ready to serve downloads
+	ImageBuildArtifactServing = "ArtifactServing"
+	// ImageBuildWorkspaceBound is False while the workspace PVC of a running build waits to be bound
+	ImageBuildWorkspaceBound = "WorkspaceBound"
+	// ImageBuildPodScheduled is False while a pod of a running build cannot be scheduled
+	ImageBuildPodScheduled = "PodScheduled"
+)
+
+// ArtifactConversionFormats are the formats ImageBuildSpec.Conversions accepts
+var ArtifactConversionFormats = []string{"qcow2", "vmdk", "vdi", "vhdx", "simg"}
+
+// Artifact conversion phases
+const (
+	ConversionPending   = "Pending"
+	ConversionRunning   = "Running"
+	ConversionCompleted = "Completed"
+	ConversionFailed    = "Failed"
+)
+
+// ArtifactConversion is the progress of converting the artifact of a completed build to another format.
+// Failed conversions are not retried.
+type ArtifactConversion struct {
+	// Format is the format the artifact is converted to
+	Format string `json:"format"`
+
+	// Phase is Pending, Running, Completed or Failed
+	Phase string `json:"phase"`
+
+	// FileName is the converted file, next to the artifact in the workspace
+	// +optional
+	FileName string `json:"fileName,omitempty"`
+
+	// Size is the size of the converted file in bytes
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// SHA256 is the hex SHA-256 checksum of the converted file
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Message tells why a conversion failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the conversion was started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the conversion finished
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DownloadInfo tells how to retrieve the outputs of a completed build without consulting the CLI or API docs
+type DownloadInfo struct {
+	// APIPath is the build API path of the artifact, e.g. GET <build API URL>/v1/builds/<name>/artifact/<file>.
+	// Builds outside the build API's namespace are selected with the X-Build-Namespace header.
+	APIPath string `json:"apiPath"`
+
+	// ArchiveAPIPath is the build API path of a tar archive of all the files in Artifacts
+	ArchiveAPIPath string `json:"archiveAPIPath"`
+
+	// RouteURL is the URL of the artifact on the build's artifact Route, when the build exposes one
+	// +optional
+	RouteURL string `json:"routeURL,omitempty"`
+
+	// Artifacts are the files the build produced in its workspace
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// ExpiryTime is when the artifact stops being served
+	ExpiryTime metav1.Time `json:"expiryTime"`
+}
+
+// ResourceUsage is what the build step consumed, read from its cgroup when the build finished
+type ResourceUsage struct {
+	// PeakMemoryBytes is the most memory the build step used at once
+	// +optional
+	PeakMemoryBytes int64 `json:"peakMemoryBytes,omitempty"`
+
+	// CPUSeconds is the CPU time the build step used
+	// +optional
+	CPUSeconds int64 `json:"cpuSeconds,omitempty"`
+
+	// DiskBytes is the space the build used in its build, output and osbuild directories, which are memory
+	// volumes when the AutomotiveDev's BuildConfig.UseMemoryVolumes is set
+	// +optional
+	DiskBytes int64 `json:"diskBytes,omitempty"`
+
+	// WorkspaceBytes is the space the build's workspace holds after its intermediates were pruned
+	// +optional
+	WorkspaceBytes int64 `json:"workspaceBytes,omitempty"`
+
+	// PrunedWorkspaceBytes is the space pruning the build's intermediates reclaimed from its workspace
+	// +optional
+	PrunedWorkspaceBytes int64 `json:"prunedWorkspaceBytes,omitempty"`
+}
+
+// BuildCost is what a build is estimated to have cost. Amounts are decimal numbers with four decimals, e.g.
+// "0.1280"
+type BuildCost struct {
+	// Total is Compute plus Storage
+	Total string `json:"total"`
+
+	// Compute is the price of the nodes the build's pods ran on, for as long as they ran
+	// +optional
+	Compute string `json:"compute,omitempty"`
+
+	// Storage is the price of the build's workspace for as long as the build ran
+	// +optional
+	Storage string `json:"storage,omitempty"`
+
+	// Currency the amounts are in
+	// +optional
+	Currency string `json:"currency,omitempty"`
+
+	// InstanceTypes are the instance types of the nodes the build's pods ran on, when the nodes have one
+	// +optional
+	InstanceTypes []string `json:"instanceTypes,omitempty"`
+}
+
+// ScanResult summarizes the findings of a build's post-build scan
+type ScanResult struct {
+	// Critical is the number of critical vulnerabilities found
+	Critical int32 `json:"critical"`
+
+	// High is the number of high severity vulnerabilities found
+	High int32 `json:"high"`
+
+	// Medium is the number of medium severity vulnerabilities found
+	Medium int32 `json:"medium"`
+
+	// Low is the number of low severity vulnerabilities found
+	Low int32 `json:"low"`
+
+	// ReportFileName is the scanner's JSON report in the build's shared workspace
+	ReportFileName string `json:"reportFileName,omitempty"`
+
+	// Blocked is set when the findings exceed the scan policy; the artifact is then not served
+	Blocked bool `json:"blocked,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -21,10 +21,69 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedExtendedResource) DeepCopyInto(out *AllowedExtendedResource) {
+	*out = *in
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedExtendedResource.
+func (in *AllowedExtendedResource) DeepCopy() *AllowedExtendedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedExtendedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedPipeline) DeepCopyInto(out *AllowedPipeline) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedPipeline.
+func (in *AllowedPipeline) DeepCopy() *AllowedPipeline {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedPipeline)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArtifactConversion) DeepCopyInto(out *ArtifactConversion) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArtifactConversion.
+func (in *ArtifactConversion) DeepCopy() *ArtifactConversion {
+	if in == nil {
+		return nil
+	}
+	out := new(ArtifactConversion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AutomotiveDev) DeepCopyInto(out *AutomotiveDev) {
 	*out = *in
@@ -90,7 +149,39 @@ func (in *AutomotiveDevSpec) DeepCopyInto(out *AutomotiveDevSpec) {
 	if in.BuildConfig != nil {
 		in, out := &in.BuildConfig, &out.BuildConfig
 		*out = new(BuildConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageDiscovery != nil {
+		in, out := &in.ImageDiscovery, &out.ImageDiscovery
+		*out = new(ImageDiscovery)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageGC != nil {
+		in, out := &in.ImageGC, &out.ImageGC
+		*out = new(ImageGCPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(MonitoringConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitHooks != nil {
+		in, out := &in.GitHooks, &out.GitHooks
+		*out = new(GitHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exposure != nil {
+		in, out := &in.Exposure, &out.Exposure
+		*out = new(Exposure)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FeatureGates != nil {
+		in, out := &in.FeatureGates, &out.FeatureGates
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 }
 
@@ -111,6 +202,33 @@ func (in *AutomotiveDevStatus) DeepCopyInto(out *AutomotiveDevStatus) {
 		in, out := &in.LastUpdated, &out.LastUpdated
 		*out = (*in).DeepCopy()
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ManagedResources != nil {
+		in, out := &in.ManagedResources, &out.ManagedResources
+		*out = make([]ManagedResource, len(*in))
+		copy(*out, *in)
+	}
+	if in.Discovery != nil {
+		in, out := &in.Discovery, &out.Discovery
+		*out = new(ImageDiscoveryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrePull != nil {
+		in, out := &in.PrePull, &out.PrePull
+		*out = new(PrePullStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnabledFeatures != nil {
+		in, out := &in.EnabledFeatures, &out.EnabledFeatures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutomotiveDevStatus.
@@ -123,9 +241,140 @@ func (in *AutomotiveDevStatus) DeepCopy() *AutomotiveDevStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildAccess) DeepCopyInto(out *BuildAccess) {
+	*out = *in
+	if in.AdminGroups != nil {
+		in, out := &in.AdminGroups, &out.AdminGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildAccess.
+func (in *BuildAccess) DeepCopy() *BuildAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildCatalog) DeepCopyInto(out *BuildCatalog) {
+	*out = *in
+	if in.Distros != nil {
+		in, out := &in.Distros, &out.Distros
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Architectures != nil {
+		in, out := &in.Architectures, &out.Architectures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildCatalog.
+func (in *BuildCatalog) DeepCopy() *BuildCatalog {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildCatalog)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BuildConfig) DeepCopyInto(out *BuildConfig) {
 	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PrePull != nil {
+		in, out := &in.PrePull, &out.PrePull
+		*out = new(PrePullPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BuilderImage != nil {
+		in, out := &in.BuilderImage, &out.BuilderImage
+		*out = new(BuilderImagePolicy)
+		**out = **in
+	}
+	if in.WorkspaceKeep != nil {
+		in, out := &in.WorkspaceKeep, &out.WorkspaceKeep
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Catalog != nil {
+		in, out := &in.Catalog, &out.Catalog
+		*out = new(BuildCatalog)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]BuildProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Scan != nil {
+		in, out := &in.Scan, &out.Scan
+		*out = new(ScanPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = new(ImageOverrides)
+		**out = **in
+	}
+	if in.RouteAuth != nil {
+		in, out := &in.RouteAuth, &out.RouteAuth
+		*out = new(RouteAuth)
+		**out = **in
+	}
+	if in.ExtendedResources != nil {
+		in, out := &in.ExtendedResources, &out.ExtendedResources
+		*out = make([]AllowedExtendedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowedPipelines != nil {
+		in, out := &in.AllowedPipelines, &out.AllowedPipelines
+		*out = make([]AllowedPipeline, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedAIBArgs != nil {
+		in, out := &in.AllowedAIBArgs, &out.AllowedAIBArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(BuildAccess)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IntermediateRegistry != nil {
+		in, out := &in.IntermediateRegistry, &out.IntermediateRegistry
+		*out = new(IntermediateRegistry)
+		**out = **in
+	}
+	if in.Pricing != nil {
+		in, out := &in.Pricing, &out.Pricing
+		*out = new(BuildPricing)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildConfig.
@@ -138,6 +387,256 @@ func (in *BuildConfig) DeepCopy() *BuildConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildCost) DeepCopyInto(out *BuildCost) {
+	*out = *in
+	if in.InstanceTypes != nil {
+		in, out := &in.InstanceTypes, &out.InstanceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildCost.
+func (in *BuildCost) DeepCopy() *BuildCost {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildCost)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildInfo) DeepCopyInto(out *BuildInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildInfo.
+func (in *BuildInfo) DeepCopy() *BuildInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildPricing) DeepCopyInto(out *BuildPricing) {
+	*out = *in
+	if in.InstanceTypes != nil {
+		in, out := &in.InstanceTypes, &out.InstanceTypes
+		*out = make([]InstanceTypePrice, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildPricing.
+func (in *BuildPricing) DeepCopy() *BuildPricing {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildPricing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuildProfile) DeepCopyInto(out *BuildProfile) {
+	*out = *in
+	if in.AIBExtraArgs != nil {
+		in, out := &in.AIBExtraArgs, &out.AIBExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CustomDefs != nil {
+		in, out := &in.CustomDefs, &out.CustomDefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuildProfile.
+func (in *BuildProfile) DeepCopy() *BuildProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(BuildProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BuilderImagePolicy) DeepCopyInto(out *BuilderImagePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BuilderImagePolicy.
+func (in *BuilderImagePolicy) DeepCopy() *BuilderImagePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BuilderImagePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiscoveryRepository) DeepCopyInto(out *DiscoveryRepository) {
+	*out = *in
+	if in.MediaTypes != nil {
+		in, out := &in.MediaTypes, &out.MediaTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiscoveryRepository.
+func (in *DiscoveryRepository) DeepCopy() *DiscoveryRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(DiscoveryRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DownloadInfo) DeepCopyInto(out *DownloadInfo) {
+	*out = *in
+	if in.Artifacts != nil {
+		in, out := &in.Artifacts, &out.Artifacts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.ExpiryTime.DeepCopyInto(&out.ExpiryTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DownloadInfo.
+func (in *DownloadInfo) DeepCopy() *DownloadInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(DownloadInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Exposure) DeepCopyInto(out *Exposure) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = new(IngressExposure)
+		**out = **in
+	}
+	if in.Gateway != nil {
+		in, out := &in.Gateway, &out.Gateway
+		*out = new(GatewayExposure)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Exposure.
+func (in *Exposure) DeepCopy() *Exposure {
+	if in == nil {
+		return nil
+	}
+	out := new(Exposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayExposure) DeepCopyInto(out *GatewayExposure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayExposure.
+func (in *GatewayExposure) DeepCopy() *GatewayExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHooks) DeepCopyInto(out *GitHooks) {
+	*out = *in
+	if in.Triggers != nil {
+		in, out := &in.Triggers, &out.Triggers
+		*out = make([]GitTrigger, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(GitStatusReporting)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHooks.
+func (in *GitHooks) DeepCopy() *GitHooks {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHooks)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitStatusReporting) DeepCopyInto(out *GitStatusReporting) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitStatusReporting.
+func (in *GitStatusReporting) DeepCopy() *GitStatusReporting {
+	if in == nil {
+		return nil
+	}
+	out := new(GitStatusReporting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitTrigger) DeepCopyInto(out *GitTrigger) {
+	*out = *in
+	if in.Branches != nil {
+		in, out := &in.Branches, &out.Branches
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitTrigger.
+func (in *GitTrigger) DeepCopy() *GitTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(GitTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
@@ -162,107 +661,248 @@ func (in *Image) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
-	return nil
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImageBuild) DeepCopyInto(out *ImageBuild) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageBuild) DeepCopyInto(out *ImageBuild) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuild.
+func (in *ImageBuild) DeepCopy() *ImageBuild {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageBuild)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ImageBuild) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageBuildList) DeepCopyInto(out *ImageBuildList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ImageBuild, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildList.
+func (in *ImageBuildList) DeepCopy() *ImageBuildList {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageBuildList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ImageBuildList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageBuildSpec) DeepCopyInto(out *ImageBuildSpec) {
+	*out = *in
+	if in.Publishers != nil {
+		in, out := &in.Publishers, &out.Publishers
+		*out = new(Publishers)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeepWorkspaceOnFailure != nil {
+		in, out := &in.KeepWorkspaceOnFailure, &out.KeepWorkspaceOnFailure
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BuildInfo != nil {
+		in, out := &in.BuildInfo, &out.BuildInfo
+		*out = new(BuildInfo)
+		**out = **in
+	}
+	if in.RouteAuth != nil {
+		in, out := &in.RouteAuth, &out.RouteAuth
+		*out = new(RouteAuth)
+		**out = **in
+	}
+	if in.PipelineRef != nil {
+		in, out := &in.PipelineRef, &out.PipelineRef
+		*out = new(PipelineRef)
+		**out = **in
+	}
+	if in.ExtendedResources != nil {
+		in, out := &in.ExtendedResources, &out.ExtendedResources
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Conversions != nil {
+		in, out := &in.Conversions, &out.Conversions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OSTree != nil {
+		in, out := &in.OSTree, &out.OSTree
+		*out = new(OSTreeUpdate)
+		**out = **in
+	}
+	if in.InputPVC != nil {
+		in, out := &in.InputPVC, &out.InputPVC
+		*out = new(InputPVC)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildSpec.
+func (in *ImageBuildSpec) DeepCopy() *ImageBuildSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageBuildSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageBuildStatus) DeepCopyInto(out *ImageBuildStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.WorkspaceExpiryTime != nil {
+		in, out := &in.WorkspaceExpiryTime, &out.WorkspaceExpiryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Scan != nil {
+		in, out := &in.Scan, &out.Scan
+		*out = new(ScanResult)
+		**out = **in
+	}
+	if in.ResourceUsage != nil {
+		in, out := &in.ResourceUsage, &out.ResourceUsage
+		*out = new(ResourceUsage)
+		**out = **in
+	}
+	if in.Cost != nil {
+		in, out := &in.Cost, &out.Cost
+		*out = new(BuildCost)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Download != nil {
+		in, out := &in.Download, &out.Download
+		*out = new(DownloadInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conversions != nil {
+		in, out := &in.Conversions, &out.Conversions
+		*out = make([]ArtifactConversion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuild.
-func (in *ImageBuild) DeepCopy() *ImageBuild {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildStatus.
+func (in *ImageBuildStatus) DeepCopy() *ImageBuildStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ImageBuild)
+	out := new(ImageBuildStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ImageBuild) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImageBuildList) DeepCopyInto(out *ImageBuildList) {
+func (in *ImageDiscovery) DeepCopyInto(out *ImageDiscovery) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]ImageBuild, len(*in))
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]DiscoveryRepository, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildList.
-func (in *ImageBuildList) DeepCopy() *ImageBuildList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageDiscovery.
+func (in *ImageDiscovery) DeepCopy() *ImageDiscovery {
 	if in == nil {
 		return nil
 	}
-	out := new(ImageBuildList)
+	out := new(ImageDiscovery)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ImageBuildList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImageBuildSpec) DeepCopyInto(out *ImageBuildSpec) {
+func (in *ImageDiscoveryStatus) DeepCopyInto(out *ImageDiscoveryStatus) {
 	*out = *in
-	if in.Publishers != nil {
-		in, out := &in.Publishers, &out.Publishers
-		*out = new(Publishers)
-		(*in).DeepCopyInto(*out)
+	if in.LastScanTime != nil {
+		in, out := &in.LastScanTime, &out.LastScanTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildSpec.
-func (in *ImageBuildSpec) DeepCopy() *ImageBuildSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageDiscoveryStatus.
+func (in *ImageDiscoveryStatus) DeepCopy() *ImageDiscoveryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ImageBuildSpec)
+	out := new(ImageDiscoveryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ImageBuildStatus) DeepCopyInto(out *ImageBuildStatus) {
+func (in *ImageGCPolicy) DeepCopyInto(out *ImageGCPolicy) {
 	*out = *in
-	if in.StartTime != nil {
-		in, out := &in.StartTime, &out.StartTime
-		*out = (*in).DeepCopy()
-	}
-	if in.CompletionTime != nil {
-		in, out := &in.CompletionTime, &out.CompletionTime
-		*out = (*in).DeepCopy()
+	if in.Lifecycles != nil {
+		in, out := &in.Lifecycles, &out.Lifecycles
+		*out = make([]ImageLifecycle, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageBuildStatus.
-func (in *ImageBuildStatus) DeepCopy() *ImageBuildStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageGCPolicy.
+func (in *ImageGCPolicy) DeepCopy() *ImageGCPolicy {
 	if in == nil {
 		return nil
 	}
-	out := new(ImageBuildStatus)
+	out := new(ImageGCPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -352,6 +992,21 @@ func (in *ImageMetadata) DeepCopy() *ImageMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageOverrides) DeepCopyInto(out *ImageOverrides) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageOverrides.
+func (in *ImageOverrides) DeepCopy() *ImageOverrides {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageOverrides)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSize) DeepCopyInto(out *ImageSize) {
 	*out = *in
@@ -443,6 +1098,180 @@ func (in *ImageStatus) DeepCopy() *ImageStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IngressExposure) DeepCopyInto(out *IngressExposure) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IngressExposure.
+func (in *IngressExposure) DeepCopy() *IngressExposure {
+	if in == nil {
+		return nil
+	}
+	out := new(IngressExposure)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InputPVC) DeepCopyInto(out *InputPVC) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InputPVC.
+func (in *InputPVC) DeepCopy() *InputPVC {
+	if in == nil {
+		return nil
+	}
+	out := new(InputPVC)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceTypePrice) DeepCopyInto(out *InstanceTypePrice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceTypePrice.
+func (in *InstanceTypePrice) DeepCopy() *InstanceTypePrice {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceTypePrice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IntermediateRegistry) DeepCopyInto(out *IntermediateRegistry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IntermediateRegistry.
+func (in *IntermediateRegistry) DeepCopy() *IntermediateRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(IntermediateRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedResource) DeepCopyInto(out *ManagedResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedResource.
+func (in *ManagedResource) DeepCopy() *ManagedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConfig) DeepCopyInto(out *MonitoringConfig) {
+	*out = *in
+	if in.RuleLabels != nil {
+		in, out := &in.RuleLabels, &out.RuleLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DashboardLabels != nil {
+		in, out := &in.DashboardLabels, &out.DashboardLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConfig.
+func (in *MonitoringConfig) DeepCopy() *MonitoringConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OSTreeUpdate) DeepCopyInto(out *OSTreeUpdate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OSTreeUpdate.
+func (in *OSTreeUpdate) DeepCopy() *OSTreeUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(OSTreeUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineRef) DeepCopyInto(out *PipelineRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineRef.
+func (in *PipelineRef) DeepCopy() *PipelineRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrePullPolicy) DeepCopyInto(out *PrePullPolicy) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrePullPolicy.
+func (in *PrePullPolicy) DeepCopy() *PrePullPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PrePullPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrePullStatus) DeepCopyInto(out *PrePullStatus) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrePullStatus.
+func (in *PrePullStatus) DeepCopy() *PrePullStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PrePullStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Publishers) DeepCopyInto(out *Publishers) {
 	*out = *in
@@ -492,3 +1321,68 @@ func (in *RegistryPublisher) DeepCopy() *RegistryPublisher {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceUsage) DeepCopyInto(out *ResourceUsage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceUsage.
+func (in *ResourceUsage) DeepCopy() *ResourceUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteAuth) DeepCopyInto(out *RouteAuth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteAuth.
+func (in *RouteAuth) DeepCopy() *RouteAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanPolicy) DeepCopyInto(out *ScanPolicy) {
+	*out = *in
+	if in.MaxCritical != nil {
+		in, out := &in.MaxCritical, &out.MaxCritical
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanPolicy.
+func (in *ScanPolicy) DeepCopy() *ScanPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanResult) DeepCopyInto(out *ScanResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanResult.
+func (in *ScanResult) DeepCopy() *ScanResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanResult)
+	in.DeepCopyInto(out)
+	return out
+}
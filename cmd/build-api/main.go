@@ -20,7 +20,18 @@ func main() {
 		kubeconfigPath = flag.String("kubeconfig-path", "", "Path to kubeconfig file")
 		port           = flag.String("port", "", "Port to listen on (default: 8080)")
 		namespace      = flag.String("namespace", "automotive-dev-operator-system", "Kubernetes namespace to use")
+		tlsConfig      = buildapi.TLSConfigFromEnv()
+		rateLimits     = buildapi.RateLimitConfigFromEnv()
+		disableUI      = flag.Bool("disable-ui", buildapi.UIDisabledFromEnv(), "Do not serve the web UI at /ui/ (env: BUILD_API_DISABLE_UI)")
 	)
+	flag.StringVar(&tlsConfig.CertFile, "tls-cert-file", tlsConfig.CertFile, "TLS certificate file; the server listens with TLS when set (env: BUILD_API_TLS_CERT_FILE)")
+	flag.StringVar(&tlsConfig.KeyFile, "tls-key-file", tlsConfig.KeyFile, "TLS private key file (env: BUILD_API_TLS_KEY_FILE)")
+	flag.StringVar(&tlsConfig.ClientCAFile, "tls-client-ca-file", tlsConfig.ClientCAFile, "CA bundle client certificates must be signed by, enabling mutual TLS (env: BUILD_API_TLS_CLIENT_CA_FILE)")
+	flag.StringVar(&tlsConfig.ClientAuth, "tls-client-auth", tlsConfig.ClientAuth, "require or optional: whether clients must present a certificate when a client CA is set (default: require; env: BUILD_API_TLS_CLIENT_AUTH)")
+	flag.StringVar(&rateLimits.Download, "download-rate-limit", rateLimits.Download, "bytes per second all artifact downloads together may use, e.g. 100M (env: BUILD_API_DOWNLOAD_RATE_LIMIT)")
+	flag.StringVar(&rateLimits.DownloadPerStream, "download-stream-rate-limit", rateLimits.DownloadPerStream, "bytes per second a single artifact download may use (env: BUILD_API_DOWNLOAD_STREAM_RATE_LIMIT)")
+	flag.StringVar(&rateLimits.Upload, "upload-rate-limit", rateLimits.Upload, "bytes per second all file uploads together may use (env: BUILD_API_UPLOAD_RATE_LIMIT)")
+	flag.StringVar(&rateLimits.UploadPerStream, "upload-stream-rate-limit", rateLimits.UploadPerStream, "bytes per second a single file upload may use (env: BUILD_API_UPLOAD_STREAM_RATE_LIMIT)")
 	flag.Parse()
 
 	// Set kubeconfig from flag if provided
@@ -54,9 +65,25 @@ func main() {
 		"addr", addr,
 		"gin_mode", os.Getenv("GIN_MODE"),
 		"kubeconfig", os.Getenv("KUBECONFIG"),
-		"namespace", os.Getenv("BUILD_API_NAMESPACE"))
+		"namespace", os.Getenv("BUILD_API_NAMESPACE"),
+		"ui", !*disableUI)
 
 	apiServer := buildapi.NewAPIServer(addr, logger)
+	if tlsConfig.Enabled() {
+		if err := apiServer.ConfigureTLS(tlsConfig); err != nil {
+			slog.Error("invalid TLS configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := apiServer.ConfigureRateLimits(rateLimits); err != nil {
+		slog.Error("invalid rate limits", "error", err)
+		os.Exit(1)
+	}
+
+	if *disableUI {
+		apiServer.DisableUI()
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
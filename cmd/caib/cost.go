@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+// formatCost renders the estimated cost of a build with its breakdown, e.g.
+// "0.1280 USD (compute 0.1200 on m6i.2xlarge, storage 0.0080)"
+func formatCost(c *buildapitypes.BuildCost) string {
+	s := strings.TrimSpace(c.Total + " " + c.Currency)
+	if c.Compute == "" {
+		return s
+	}
+	compute := "compute " + c.Compute
+	if len(c.InstanceTypes) > 0 {
+		compute += " on " + strings.Join(c.InstanceTypes, ", ")
+	}
+	return fmt.Sprintf("%s (%s, storage %s)", s, compute, c.Storage)
+}
+
+// printCostStats prints the estimated costs of builds per currency, broken down by requester with the largest
+// spenders first
+func printCostStats(w io.Writer, costs []buildapitypes.CostStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range costs {
+		requesters := make([]string, 0, len(c.ByRequester))
+		for r := range c.ByRequester {
+			requesters = append(requesters, r)
+		}
+		sort.Slice(requesters, func(i, j int) bool {
+			a, b := c.ByRequester[requesters[i]], c.ByRequester[requesters[j]]
+			if a != b {
+				return a > b
+			}
+			return requesters[i] < requesters[j]
+		})
+
+		fmt.Fprintf(tw, "\nCost:         %.4f %s (%d builds)\n", c.Total, c.Currency, c.Builds)
+		fmt.Fprintf(tw, "REQUESTER\tCOST (%s)\n", c.Currency)
+		for _, r := range requesters {
+			fmt.Fprintf(tw, "%s\t%.4f\n", r, c.ByRequester[r])
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Printing build costs", func() {
+	It("should show the breakdown of a build's cost", func() {
+		Expect(formatCost(&buildapitypes.BuildCost{
+			Total: "0.1280", Compute: "0.1200", Storage: "0.0080", Currency: "USD", InstanceTypes: []string{"m6i.2xlarge"},
+		})).To(Equal("0.1280 USD (compute 0.1200 on m6i.2xlarge, storage 0.0080)"))
+		Expect(formatCost(&buildapitypes.BuildCost{Total: "0.5000"})).To(Equal("0.5000"))
+	})
+
+	It("should list the largest spenders first in each currency", func() {
+		var out bytes.Buffer
+		Expect(printCostStats(&out, []buildapitypes.CostStats{{
+			Currency:    "USD",
+			Builds:      3,
+			Total:       0.35,
+			ByRequester: map[string]float64{"alice": 0.1, "bob": 0.25},
+		}})).To(Succeed())
+
+		Expect(out.String()).To(ContainSubstring("Cost:         0.3500 USD (3 builds)\n"))
+		Expect(out.String()).To(MatchRegexp(`(?s)REQUESTER\s+COST \(USD\)\s+bob\s+0.2500\s+alice\s+0.1000`))
+	})
+})
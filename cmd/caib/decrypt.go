@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Encrypted builds encrypt their artifacts like openssl enc -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -salt:
+// a "Salted__" header and an 8-byte salt, then the AES-256-CBC ciphertext of the PKCS#7 padded content, with
+// key and IV derived from the passphrase and salt. The file ends with an HMAC-SHA256 of everything before it,
+// keyed with the HMAC-SHA256 of the passphrase under macKeyLabel, so tampering is detected before decrypting.
+const (
+	saltedMagic     = "Salted__"
+	pbkdf2Iter      = 100000
+	macKeyLabel     = "artifact-mac"
+	encryptedSuffix = ".enc"
+)
+
+var errBadKey = errors.New("wrong key or corrupt file")
+
+// readKeyFile returns the passphrase in a key file; like openssl -pass file:, it is the file's first line
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read key file: %w", err)
+	}
+	key, _, _ := strings.Cut(string(data), "\n")
+	key = strings.TrimSuffix(key, "\r")
+	if key == "" {
+		return "", fmt.Errorf("key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// ensureKeyFile returns the passphrase of a key file, writing a random one readable only by the user first
+// when the file does not exist
+func ensureKeyFile(path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return readKeyFile(path)
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read key file: %w", err)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(raw)
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write key file: %w", err)
+	}
+	fmt.Printf("Generated encryption key in %s; keep it to decrypt the artifacts\n", path)
+	return key, nil
+}
+
+// decryptArtifact decrypts an encrypted artifact next to it, dropping its .enc suffix, and returns the path of
+// the decrypted file. Nothing is written unless the artifact verifies against the key; the decrypted file is
+// removed again if decryption fails.
+func decryptArtifact(path, keyFile string) (string, error) {
+	if !strings.HasSuffix(path, encryptedSuffix) {
+		return path, nil
+	}
+	if keyFile == "" {
+		return "", fmt.Errorf("%s is encrypted; pass --key-file", path)
+	}
+	key, err := readKeyFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	n, err := authenticate(in, fi.Size(), key)
+	if err != nil {
+		return "", fmt.Errorf("decrypt %s: %w", path, err)
+	}
+
+	outPath := strings.TrimSuffix(path, encryptedSuffix)
+	out, err := os.Create(outPath)
+	if err != nil {
+		return "", err
+	}
+	if err := decryptContent(io.NewSectionReader(in, 0, n), out, key); err != nil {
+		out.Close()
+		os.Remove(outPath)
+		return "", fmt.Errorf("decrypt %s: %w", path, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	fmt.Printf("Artifact decrypted to %s\n", outPath)
+	return outPath, nil
+}
+
+// decrypt verifies an encrypted artifact of the given size and streams its content to w
+func decrypt(r io.ReaderAt, size int64, w io.Writer, passphrase string) error {
+	n, err := authenticate(r, size, passphrase)
+	if err != nil {
+		return err
+	}
+	return decryptContent(io.NewSectionReader(r, 0, n), w, passphrase)
+}
+
+// authenticate checks the HMAC an encrypted artifact ends with and returns the length of what it covers
+func authenticate(r io.ReaderAt, size int64, passphrase string) (int64, error) {
+	header := make([]byte, len(saltedMagic)+8)
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.HasPrefix(header, []byte(saltedMagic)) {
+		return 0, errors.New("not an encrypted artifact")
+	}
+	n := size - sha256.Size
+	if n < int64(len(header)+aes.BlockSize) {
+		return 0, errors.New("truncated file")
+	}
+	keyMAC := hmac.New(sha256.New, []byte(macKeyLabel))
+	keyMAC.Write([]byte(passphrase))
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	if _, err := io.Copy(mac, io.NewSectionReader(r, 0, n)); err != nil {
+		return 0, err
+	}
+	tag := make([]byte, sha256.Size)
+	if _, err := r.ReadAt(tag, n); err != nil {
+		return 0, err
+	}
+	if !hmac.Equal(mac.Sum(nil), tag) {
+		return 0, errBadKey
+	}
+	return n, nil
+}
+
+// decryptContent streams the content of verified ciphertext from r to w
+func decryptContent(r io.Reader, w io.Writer, passphrase string) error {
+	header := make([]byte, len(saltedMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, []byte(saltedMagic)) {
+		return errors.New("not an encrypted artifact")
+	}
+	derived, err := pbkdf2.Key(sha256.New, passphrase, header[len(saltedMagic):], pbkdf2Iter, 32+aes.BlockSize)
+	if err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(derived[:32])
+	if err != nil {
+		return err
+	}
+	mode := cipher.NewCBCDecrypter(block, derived[32:])
+
+	// the last block carries the padding, so it is held back until the end of the input
+	br := bufio.NewReaderSize(r, 1<<20)
+	buf := make([]byte, 1<<20)
+	var tail []byte
+	for {
+		n, err := io.ReadFull(br, buf)
+		if n > 0 {
+			if n%aes.BlockSize != 0 {
+				return errors.New("truncated file")
+			}
+			mode.CryptBlocks(buf[:n], buf[:n])
+			if _, err := w.Write(tail); err != nil {
+				return err
+			}
+			if _, err := w.Write(buf[:n-aes.BlockSize]); err != nil {
+				return err
+			}
+			tail = append(tail[:0], buf[n-aes.BlockSize:n]...)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if tail == nil {
+		return errBadKey
+	}
+	pad := int(tail[aes.BlockSize-1])
+	if pad < 1 || pad > aes.BlockSize || !bytes.Equal(tail[aes.BlockSize-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return errBadKey
+	}
+	_, err = w.Write(tail[:aes.BlockSize-pad])
+	return err
+}
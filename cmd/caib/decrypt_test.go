@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// encrypt encrypts content like the build's openssl enc -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -salt and
+// appends the HMAC of the result
+func encrypt(content []byte, passphrase string, salt []byte) []byte {
+	derived, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iter, 32+aes.BlockSize)
+	Expect(err).NotTo(HaveOccurred())
+	block, err := aes.NewCipher(derived[:32])
+	Expect(err).NotTo(HaveOccurred())
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	padded := append(bytes.Clone(content), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, derived[32:]).CryptBlocks(padded, padded)
+	encrypted := append(append([]byte(saltedMagic), salt...), padded...)
+	keyMAC := hmac.New(sha256.New, []byte(macKeyLabel))
+	keyMAC.Write([]byte(passphrase))
+	mac := hmac.New(sha256.New, keyMAC.Sum(nil))
+	mac.Write(encrypted)
+	return mac.Sum(encrypted)
+}
+
+// decryptBytes decrypts an encrypted artifact held in memory
+func decryptBytes(encrypted []byte, w io.Writer, passphrase string) error {
+	return decrypt(bytes.NewReader(encrypted), int64(len(encrypted)), w, passphrase)
+}
+
+var _ = Describe("Artifact decryption", func() {
+	It("decrypts what the build encrypted", func() {
+		// encrypt_file of build_image.sh with correct-horse-battery in the key file, run on
+		// printf 'an automotive image, longer than one block\n'
+		encrypted, err := base64.StdEncoding.DecodeString("U2FsdGVkX18Zzy9akYp9ofROnLMun7ZlIQbJeb+Ve+zQZlyGfAvxjYbj88LTFqh3UvB27aCE+g2bmEXPGg/PEj3XRh/SeT5EpiN/oss5LTVoCLKNuBC5r/odfxvKcDZ/")
+		Expect(err).NotTo(HaveOccurred())
+
+		var out bytes.Buffer
+		Expect(decryptBytes(encrypted, &out, "correct-horse-battery")).To(Succeed())
+		Expect(out.String()).To(Equal("an automotive image, longer than one block\n"))
+	})
+
+	It("decrypts content spanning several reads", func() {
+		content := bytes.Repeat([]byte("0123456789abcdef-"), 200000)
+		var out bytes.Buffer
+		Expect(decryptBytes(encrypt(content, "passphrase", []byte("saltsalt")), &out, "passphrase")).To(Succeed())
+		Expect(out.Bytes()).To(Equal(content))
+	})
+
+	It("rejects a wrong key and files that are not encrypted", func() {
+		encrypted := encrypt([]byte("image"), "passphrase", []byte("saltsalt"))
+		Expect(decryptBytes(encrypted, &bytes.Buffer{}, "another passphrase")).To(MatchError(errBadKey))
+		Expect(decryptBytes([]byte("plain image content"), &bytes.Buffer{}, "passphrase")).
+			To(MatchError(ContainSubstring("not an encrypted artifact")))
+	})
+
+	It("writes nothing for tampered or truncated files", func() {
+		encrypted := encrypt(bytes.Repeat([]byte("image"), 100), "passphrase", []byte("saltsalt"))
+		tampered := bytes.Clone(encrypted)
+		tampered[len(saltedMagic)+8+aes.BlockSize] ^= 1
+		var out bytes.Buffer
+		Expect(decryptBytes(tampered, &out, "passphrase")).To(MatchError(errBadKey))
+		Expect(decryptBytes(encrypted[:len(encrypted)-aes.BlockSize], &out, "passphrase")).To(MatchError(errBadKey))
+		Expect(out.Len()).To(BeZero())
+
+		dir := GinkgoT().TempDir()
+		keyFile := filepath.Join(dir, "key")
+		Expect(os.WriteFile(keyFile, []byte("passphrase\n"), 0o600)).To(Succeed())
+		artifact := filepath.Join(dir, "disk.raw.gz.enc")
+		Expect(os.WriteFile(artifact, tampered, 0o644)).To(Succeed())
+		_, err := decryptArtifact(artifact, keyFile)
+		Expect(err).To(MatchError(errBadKey))
+		Expect(filepath.Join(dir, "disk.raw.gz")).NotTo(BeAnExistingFile())
+	})
+
+	It("decrypts an artifact next to it with the key of a key file", func() {
+		dir := GinkgoT().TempDir()
+		keyFile := filepath.Join(dir, "key")
+		key, err := ensureKeyFile(keyFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(key).To(HaveLen(64))
+		fi, err := os.Stat(keyFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+
+		artifact := filepath.Join(dir, "disk.raw.gz.enc")
+		Expect(os.WriteFile(artifact, encrypt([]byte("image"), key, []byte("saltsalt")), 0o644)).To(Succeed())
+		path, err := decryptArtifact(artifact, keyFile)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal(filepath.Join(dir, "disk.raw.gz")))
+		Expect(os.ReadFile(path)).To(Equal([]byte("image")))
+
+		_, err = decryptArtifact(artifact, "")
+		Expect(err).To(MatchError(ContainSubstring("pass --key-file")))
+	})
+})
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// Exit codes of caib, so CI pipelines can tell what went wrong without parsing the output
+const (
+	exitOK             = 0
+	exitError          = 1
+	exitInvalidArgs    = 2
+	exitBuildFailed    = 3
+	exitTimedOut       = 4
+	exitUploadFailed   = 5
+	exitDownloadFailed = 6
+	exitVerifyFailed   = 7
+)
+
+// exitResults names the exit codes in the build summary
+var exitResults = map[int]string{
+	exitOK:             "succeeded",
+	exitError:          "error",
+	exitInvalidArgs:    "invalid-args",
+	exitBuildFailed:    "build-failed",
+	exitTimedOut:       "timed-out",
+	exitUploadFailed:   "upload-failed",
+	exitDownloadFailed: "download-failed",
+	exitVerifyFailed:   "verify-failed",
+}
+
+// noProgress suppresses progress bars, whose redraws clutter the logs of CI jobs
+var noProgress bool
+
+// codedError is an error caib exits with a specific code for
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+
+func (e *codedError) Unwrap() error { return e.err }
+
+// withExitCode marks err to make caib exit with code
+func withExitCode(code int, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// exitCodeOf returns the code caib exits with for err
+func exitCodeOf(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return exitError
+}
+
+// buildSummary is the JSON line caib build ends with
+type buildSummary struct {
+	Build    string `json:"build,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+	Result   string `json:"result"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	Artifact string `json:"artifact,omitempty"`
+}
+
+// activeSummary is the summary of the running build command, printed by handleError when it fails
+var activeSummary *buildSummary
+
+// finish completes the summary with the outcome err and prints it as a single line
+func (s *buildSummary) finish(w io.Writer, err error) {
+	s.ExitCode = exitCodeOf(err)
+	s.Result = exitResults[s.ExitCode]
+	if err != nil {
+		s.Error = err.Error()
+	}
+	line, _ := json.Marshal(s)
+	fmt.Fprintln(w, string(line))
+}
+
+// newProgressBar returns a progress bar of max bytes, -1 when unknown, hidden with --no-progress
+func newProgressBar(max int64, description string, options ...progressbar.Option) *progressbar.ProgressBar {
+	options = append([]progressbar.Option{
+		progressbar.OptionSetDescription(description),
+		progressbar.OptionSetVisibility(!noProgress),
+		progressbar.OptionClearOnFinish(),
+	}, options...)
+	return progressbar.NewOptions64(max, options...)
+}
+
+func handleError(err error) {
+	fmt.Printf("Error: %v\n", err)
+	if activeSummary != nil {
+		activeSummary.finish(os.Stdout, err)
+	}
+	os.Exit(exitCodeOf(err))
+}
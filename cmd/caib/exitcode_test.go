@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exit codes", func() {
+	It("should exit with the code an error is marked with, also when wrapped", func() {
+		Expect(exitCodeOf(nil)).To(Equal(exitOK))
+		Expect(exitCodeOf(errors.New("boom"))).To(Equal(exitError))
+		err := withExitCode(exitTimedOut, errors.New("timed out waiting for build"))
+		Expect(exitCodeOf(err)).To(Equal(exitTimedOut))
+		Expect(exitCodeOf(fmt.Errorf("waiting: %w", err))).To(Equal(exitTimedOut))
+		Expect(err.Error()).To(Equal("timed out waiting for build"))
+	})
+
+	It("should end a failed build with a JSON summary line", func() {
+		var out bytes.Buffer
+		summary := &buildSummary{Build: "qemu-abc12", Phase: "Failed"}
+		summary.finish(&out, withExitCode(exitBuildFailed, errors.New("build failed: step build-image failed")))
+
+		Expect(out.String()).To(HaveSuffix("}\n"))
+		Expect(bytes.Count(out.Bytes(), []byte("\n"))).To(Equal(1))
+		var got map[string]any
+		Expect(json.Unmarshal(out.Bytes(), &got)).To(Succeed())
+		Expect(got).To(Equal(map[string]any{
+			"build":    "qemu-abc12",
+			"phase":    "Failed",
+			"result":   "build-failed",
+			"exitCode": float64(exitBuildFailed),
+			"error":    "build failed: step build-image failed",
+		}))
+	})
+
+	It("should summarize a successful build with its artifact", func() {
+		var out bytes.Buffer
+		summary := &buildSummary{Build: "qemu-abc12", Phase: "Completed", Artifact: "autosd-qemu.raw.gz"}
+		summary.finish(&out, nil)
+
+		Expect(out.String()).To(Equal(`{"build":"qemu-abc12","phase":"Completed","result":"succeeded","exitCode":0,"artifact":"autosd-qemu.raw.gz"}` + "\n"))
+	})
+})
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+// Android sparse image format, as written by img2simg and read by fastboot
+const (
+	sparseMagic         = 0xed26ff3a
+	sparseHeaderSize    = 28
+	sparseChunkSize     = 12
+	sparseChunkRaw      = 0xcac1
+	sparseChunkFill     = 0xcac2
+	sparseChunkDontCare = 0xcac3
+	sparseChunkCRC32    = 0xcac4
+)
+
+// flashBufferSize is the size of the writes to the device
+const flashBufferSize = 4 << 20
+
+func runFlash(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		fmt.Fprintf(os.Stderr, "Error: caib flash is only supported on Linux and macOS\n")
+		os.Exit(1)
+	}
+
+	if err := checkFlashDevice(flashDevice); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	artifactPath := flashArtifact
+	var expectedSHA256 string
+	if artifactPath == "" {
+		if strings.TrimSpace(serverURL) == "" {
+			fmt.Fprintln(os.Stderr, "Error: --server is required (or set CAIB_SERVER) unless --artifact is given")
+			os.Exit(1)
+		}
+		if strings.TrimSpace(authToken) == "" {
+			if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+				authToken = tok
+			}
+		}
+		var opts []buildapiclient.Option
+		if strings.TrimSpace(authToken) != "" {
+			opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+		}
+		opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+		api, err := buildapiclient.New(serverURL, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		st, path, err := localArtifact(ctx, api, name, outputDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		artifactPath = path
+		// the build records the checksum of the encrypted artifact, not of what is written
+		if !st.Encrypted {
+			expectedSHA256 = st.ArtifactSHA256
+		}
+	}
+	if isArchive(artifactPath) {
+		fmt.Fprintf(os.Stderr, "Error: %s is a directory export and cannot be written to a device\n", artifactPath)
+		os.Exit(1)
+	}
+
+	if !flashYes {
+		fmt.Printf("This will overwrite all data on %s with %s.\nType 'yes' to continue: ", flashDevice, filepath.Base(artifactPath))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println("Aborted")
+			os.Exit(1)
+		}
+	}
+
+	if err := flashFile(ctx, artifactPath, flashDevice, expectedSHA256); err != nil {
+		fmt.Fprintf(os.Stderr, "Error flashing %s: %v\n", flashDevice, err)
+		os.Exit(1)
+	}
+}
+
+// checkFlashDevice refuses anything but an unmounted block or character device
+func checkFlashDevice(device string) error {
+	if strings.TrimSpace(device) == "" {
+		return fmt.Errorf("--device is required")
+	}
+	fi, err := os.Stat(device)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeDevice == 0 {
+		return fmt.Errorf("%s is not a device", device)
+	}
+	mounts, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		// macOS has no /proc; diskutil refuses to hand out mounted disks for raw writes anyway
+		return nil
+	}
+	for _, line := range strings.Split(string(mounts), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if fields[0] == device || isPartition(fields[0], device) {
+			return fmt.Errorf("%s is mounted at %s; unmount it first", fields[0], fields[1])
+		}
+	}
+	return nil
+}
+
+// isPartition reports whether a device path names a partition of disk, as /dev/sda1 or /dev/nvme0n1p1
+func isPartition(path, disk string) bool {
+	suffix, ok := strings.CutPrefix(path, disk)
+	if !ok || suffix == "" {
+		return false
+	}
+	suffix = strings.TrimPrefix(suffix, "p")
+	return suffix != "" && strings.Trim(suffix, "0123456789") == ""
+}
+
+// isArchive reports whether an artifact is the tar archive of a directory export
+func isArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tar.lz4")
+}
+
+// flashFile decompresses an artifact on the fly, writes it to the device and reads it back to verify it.
+// When expectedSHA256 is set, the artifact is checked against the checksum the build recorded.
+func flashFile(ctx context.Context, artifactPath, device, expectedSHA256 string) error {
+	f, err := os.Open(artifactPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	bar := newProgressBar(fi.Size(), "Flashing",
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(15),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+	)
+	artifactHash := sha256.New()
+	src := io.TeeReader(f, io.MultiWriter(bar, artifactHash))
+	image, err := decompress(ctx, src, artifactPath)
+	if err != nil {
+		return err
+	}
+	defer image.Close()
+
+	dev, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	start := time.Now()
+	written, err := flashImage(image, dev)
+	_ = bar.Finish()
+	if !noProgress {
+		fmt.Println()
+	}
+	if err != nil {
+		return err
+	}
+	if err := image.Close(); err != nil {
+		return fmt.Errorf("decompress %s: %w", filepath.Base(artifactPath), err)
+	}
+	if expectedSHA256 != "" {
+		// a sparse image may end before its file does
+		if _, err := io.Copy(io.Discard, src); err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(artifactHash.Sum(nil)); sum != expectedSHA256 {
+			return fmt.Errorf("artifact checksum %s does not match the build's %s; the device holds a corrupt image", sum, expectedSHA256)
+		}
+	}
+	fmt.Printf("Wrote %d bytes to %s in %s\n", written.size, device, time.Since(start).Round(time.Second))
+
+	fmt.Println("Verifying...")
+	if err := written.verify(dev); err != nil {
+		return err
+	}
+	fmt.Printf("Verified %s: %s\n", device, hex.EncodeToString(written.hash.Sum(nil)))
+	return nil
+}
+
+// decompress returns the image inside an artifact by its extension: gzip is read in process, lz4 and xz
+// through their command line tools
+func decompress(ctx context.Context, r io.Reader, name string) (io.ReadCloser, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".gz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("decompress %s: %w", filepath.Base(name), err)
+		}
+		return gr, nil
+	case strings.HasSuffix(lower, ".lz4"):
+		return decompressCommand(ctx, r, "lz4", "-dc")
+	case strings.HasSuffix(lower, ".xz"):
+		return decompressCommand(ctx, r, "xz", "-dc")
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// commandReader is the output of a decompression command; Close waits for it and reports its failure
+type commandReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	done   bool
+}
+
+func (c *commandReader) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	c.ReadCloser.Close()
+	if err := c.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %v: %s", c.cmd.Path, err, strings.TrimSpace(c.stderr.String()))
+	}
+	return nil
+}
+
+func decompressCommand(ctx context.Context, r io.Reader, tool string, args ...string) (io.ReadCloser, error) {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, fmt.Errorf("%s is needed to decompress this artifact: %w", tool, err)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = r
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandReader{ReadCloser: out, cmd: cmd, stderr: stderr}, nil
+}
+
+// extent is a range of the device that was written
+type extent struct {
+	offset, length int64
+}
+
+// writtenImage records what was written to a device so it can be read back
+type writtenImage struct {
+	extents []extent
+	// hash covers the written bytes in the order of extents
+	hash hash.Hash
+	// size is how far into the device the image reaches, including skipped blocks
+	size int64
+}
+
+func (w *writtenImage) writeAt(dev io.WriterAt, p []byte, off int64) error {
+	if _, err := dev.WriteAt(p, off); err != nil {
+		return err
+	}
+	w.hash.Write(p)
+	if n := len(w.extents); n > 0 && w.extents[n-1].offset+w.extents[n-1].length == off {
+		w.extents[n-1].length += int64(len(p))
+	} else {
+		w.extents = append(w.extents, extent{offset: off, length: int64(len(p))})
+	}
+	w.size = max(w.size, off+int64(len(p)))
+	return nil
+}
+
+// verify reads the written extents back and compares them to what was written
+func (w *writtenImage) verify(dev io.ReaderAt) error {
+	readBack := sha256.New()
+	buf := make([]byte, flashBufferSize)
+	for _, e := range w.extents {
+		if _, err := io.CopyBuffer(readBack, io.NewSectionReader(dev, e.offset, e.length), buf); err != nil {
+			return fmt.Errorf("read back: %w", err)
+		}
+	}
+	if !bytes.Equal(readBack.Sum(nil), w.hash.Sum(nil)) {
+		return errors.New("verification failed: the device does not read back what was written")
+	}
+	return nil
+}
+
+// flashImage writes a raw or sparse image to the device and syncs it
+func flashImage(image io.Reader, dev *os.File) (*writtenImage, error) {
+	br := bufio.NewReaderSize(image, flashBufferSize)
+	written := &writtenImage{hash: sha256.New()}
+	var err error
+	if magic, peekErr := br.Peek(4); peekErr == nil && binary.LittleEndian.Uint32(magic) == sparseMagic {
+		err = writeSparse(br, dev, written)
+	} else {
+		err = writeRaw(br, dev, written)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := dev.Sync(); err != nil {
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+	return written, nil
+}
+
+func writeRaw(r io.Reader, dev io.WriterAt, written *writtenImage) error {
+	buf := make([]byte, flashBufferSize)
+	var off int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if werr := written.writeAt(dev, buf[:n], off); werr != nil {
+				return werr
+			}
+			off += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeSparse expands an Android sparse image onto the device; don't-care chunks are skipped
+func writeSparse(r io.Reader, dev io.WriterAt, written *writtenImage) error {
+	header := make([]byte, sparseHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("sparse header: %w", err)
+	}
+	if major := binary.LittleEndian.Uint16(header[4:]); major != 1 {
+		return fmt.Errorf("unsupported sparse image version %d", major)
+	}
+	fileHeaderSize := int64(binary.LittleEndian.Uint16(header[8:]))
+	chunkHeaderSize := int64(binary.LittleEndian.Uint16(header[10:]))
+	blockSize := int64(binary.LittleEndian.Uint32(header[12:]))
+	totalChunks := binary.LittleEndian.Uint32(header[20:])
+	if fileHeaderSize < sparseHeaderSize || chunkHeaderSize < sparseChunkSize || blockSize == 0 || blockSize%4 != 0 {
+		return errors.New("malformed sparse image header")
+	}
+	if _, err := io.CopyN(io.Discard, r, fileHeaderSize-sparseHeaderSize); err != nil {
+		return fmt.Errorf("sparse header: %w", err)
+	}
+
+	chunk := make([]byte, sparseChunkSize)
+	buf := make([]byte, flashBufferSize)
+	var off int64
+	for i := uint32(0); i < totalChunks; i++ {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return fmt.Errorf("sparse chunk %d: %w", i, err)
+		}
+		if _, err := io.CopyN(io.Discard, r, chunkHeaderSize-sparseChunkSize); err != nil {
+			return fmt.Errorf("sparse chunk %d: %w", i, err)
+		}
+		chunkType := binary.LittleEndian.Uint16(chunk)
+		length := int64(binary.LittleEndian.Uint32(chunk[4:])) * blockSize
+		dataSize := int64(binary.LittleEndian.Uint32(chunk[8:])) - chunkHeaderSize
+
+		switch chunkType {
+		case sparseChunkRaw:
+			if dataSize != length {
+				return fmt.Errorf("sparse chunk %d: %d bytes of data for %d bytes of blocks", i, dataSize, length)
+			}
+			for remaining := length; remaining > 0; {
+				n := min(remaining, int64(len(buf)))
+				if _, err := io.ReadFull(r, buf[:n]); err != nil {
+					return fmt.Errorf("sparse chunk %d: %w", i, err)
+				}
+				if err := written.writeAt(dev, buf[:n], off); err != nil {
+					return err
+				}
+				off += n
+				remaining -= n
+			}
+		case sparseChunkFill:
+			if dataSize != 4 {
+				return fmt.Errorf("sparse chunk %d: fill chunk with %d bytes of data", i, dataSize)
+			}
+			var pattern [4]byte
+			if _, err := io.ReadFull(r, pattern[:]); err != nil {
+				return fmt.Errorf("sparse chunk %d: %w", i, err)
+			}
+			fill := buf[:min(length, int64(len(buf)))]
+			for j := 0; j < len(fill); j += 4 {
+				copy(fill[j:], pattern[:])
+			}
+			for remaining := length; remaining > 0; {
+				n := min(remaining, int64(len(fill)))
+				if err := written.writeAt(dev, fill[:n], off); err != nil {
+					return err
+				}
+				off += n
+				remaining -= n
+			}
+		case sparseChunkDontCare:
+			off += length
+			written.size = max(written.size, off)
+		case sparseChunkCRC32:
+			if _, err := io.CopyN(io.Discard, r, dataSize); err != nil {
+				return fmt.Errorf("sparse chunk %d: %w", i, err)
+			}
+		default:
+			return fmt.Errorf("sparse chunk %d: unknown type %#x", i, chunkType)
+		}
+	}
+	return nil
+}
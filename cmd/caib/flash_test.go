@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sparseImage builds an Android sparse image with 4-byte blocks from chunks of type and data
+func sparseImage(chunks ...[]byte) []byte {
+	var b bytes.Buffer
+	header := make([]byte, sparseHeaderSize)
+	binary.LittleEndian.PutUint32(header, sparseMagic)
+	binary.LittleEndian.PutUint16(header[4:], 1)
+	binary.LittleEndian.PutUint16(header[8:], sparseHeaderSize)
+	binary.LittleEndian.PutUint16(header[10:], sparseChunkSize)
+	binary.LittleEndian.PutUint32(header[12:], 4)
+	binary.LittleEndian.PutUint32(header[20:], uint32(len(chunks)))
+	b.Write(header)
+	for _, c := range chunks {
+		b.Write(c)
+	}
+	return b.Bytes()
+}
+
+func sparseChunk(chunkType uint16, blocks uint32, data []byte) []byte {
+	c := make([]byte, sparseChunkSize)
+	binary.LittleEndian.PutUint16(c, chunkType)
+	binary.LittleEndian.PutUint32(c[4:], blocks)
+	binary.LittleEndian.PutUint32(c[8:], uint32(sparseChunkSize+len(data)))
+	return append(c, data...)
+}
+
+var _ = Describe("Flashing an image", func() {
+	var dev *os.File
+
+	BeforeEach(func() {
+		var err error
+		dev, err = os.Create(filepath.Join(GinkgoT().TempDir(), "disk"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(dev.Close)
+		Expect(dev.Truncate(24)).To(Succeed())
+		_, err = dev.WriteAt(bytes.Repeat([]byte{'.'}, 24), 0)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should write a raw image and verify it", func() {
+		written, err := flashImage(bytes.NewReader([]byte("raw image")), dev)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written.size).To(Equal(int64(9)))
+		Expect(written.verify(dev)).To(Succeed())
+
+		content, err := os.ReadFile(dev.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("raw image..............."))
+	})
+
+	It("should expand a sparse image, skipping don't-care blocks", func() {
+		image := sparseImage(
+			sparseChunk(sparseChunkRaw, 2, []byte("abcdefgh")),
+			sparseChunk(sparseChunkDontCare, 1, nil),
+			sparseChunk(sparseChunkFill, 2, []byte("xy01")),
+			sparseChunk(sparseChunkCRC32, 0, []byte{1, 2, 3, 4}),
+		)
+		written, err := flashImage(bytes.NewReader(image), dev)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written.size).To(Equal(int64(20)))
+		Expect(written.extents).To(Equal([]extent{{0, 8}, {12, 8}}))
+		Expect(written.verify(dev)).To(Succeed())
+
+		content, err := os.ReadFile(dev.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("abcdefgh....xy01xy01...."))
+	})
+
+	It("should fail verification when the device does not read back what was written", func() {
+		written, err := flashImage(bytes.NewReader([]byte("raw image")), dev)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = dev.WriteAt([]byte("R"), 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written.verify(dev)).To(MatchError(ContainSubstring("verification failed")))
+	})
+
+	It("should reject malformed sparse images", func() {
+		image := sparseImage(sparseChunk(sparseChunkRaw, 2, []byte("abcd")))
+		_, err := flashImage(bytes.NewReader(image), dev)
+		Expect(err).To(MatchError(ContainSubstring("4 bytes of data for 8 bytes of blocks")))
+	})
+
+	It("should recognize the partitions of a disk", func() {
+		Expect(isPartition("/dev/sda1", "/dev/sda")).To(BeTrue())
+		Expect(isPartition("/dev/nvme0n1p2", "/dev/nvme0n1")).To(BeTrue())
+		Expect(isPartition("/dev/sdab", "/dev/sda")).To(BeFalse())
+		Expect(isPartition("/dev/sda", "/dev/sda")).To(BeFalse())
+	})
+})
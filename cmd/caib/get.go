@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+// buildDocument is what `caib get` prints: the build as the API returns it, the conditions of its
+// TaskRun, the parts of its artifact, its timings and where its template can be fetched
+type buildDocument struct {
+	buildapitypes.BuildResponse `json:",inline"`
+	Conditions                  []buildCondition             `json:"conditions,omitempty"`
+	Artifacts                   []buildapitypes.ArtifactItem `json:"artifacts,omitempty"`
+	Timings                     buildTimings                 `json:"timings"`
+	// Template is the URL of the build's inputs, as `caib build` would submit them
+	Template string `json:"template"`
+}
+
+type buildCondition struct {
+	Type           string `json:"type"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason,omitempty"`
+	Message        string `json:"message,omitempty"`
+	StartTime      string `json:"startTime,omitempty"`
+	CompletionTime string `json:"completionTime,omitempty"`
+}
+
+type buildTimings struct {
+	StartTime      string `json:"startTime,omitempty"`
+	CompletionTime string `json:"completionTime,omitempty"`
+	// Duration is how long the build ran, or has been running so far
+	Duration string `json:"duration,omitempty"`
+}
+
+func runGet(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Fprintln(os.Stderr, "Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if getOutput != "yaml" && getOutput != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q (yaml, json)\n", getOutput)
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	st, err := api.GetBuild(ctx, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting build %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	// the TaskRun and artifact parts are extras: a build without them is still printed
+	tr, err := api.GetTaskRun(ctx, name)
+	if err != nil && buildapiclient.StatusCode(err) != http.StatusNotFound {
+		fmt.Fprintf(os.Stderr, "Warning: TaskRun unavailable: %v\n", err)
+	}
+	var parts []buildapitypes.ArtifactItem
+	if st.Phase == "Completed" {
+		if parts, err = api.ListArtifacts(ctx, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: artifacts unavailable: %v\n", err)
+		}
+	}
+
+	if err := printBuildDocument(os.Stdout, newBuildDocument(serverURL, st, tr, parts, time.Now()), getOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newBuildDocument(server string, st *buildapitypes.BuildResponse, tr *buildapitypes.TaskRunResponse, parts []buildapitypes.ArtifactItem, now time.Time) buildDocument {
+	doc := buildDocument{
+		BuildResponse: *st,
+		Artifacts:     parts,
+		Timings: buildTimings{
+			StartTime:      st.StartTime,
+			CompletionTime: st.CompletionTime,
+		},
+		Template: strings.TrimRight(server, "/") + path.Join("/v1/builds", url.PathEscape(st.Name), "template"),
+	}
+	if d := buildDuration(buildapitypes.BuildListItem{StartTime: st.StartTime, CompletionTime: st.CompletionTime}, now); d > 0 {
+		doc.Timings.Duration = d.Round(time.Second).String()
+	}
+	if tr != nil && tr.Status != "" {
+		doc.Conditions = append(doc.Conditions, buildCondition{
+			Type:           "Succeeded",
+			Status:         tr.Status,
+			Reason:         tr.Reason,
+			Message:        tr.Message,
+			StartTime:      tr.StartTime,
+			CompletionTime: tr.CompletionTime,
+		})
+	}
+	return doc
+}
+
+func printBuildDocument(w io.Writer, doc buildDocument, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Getting a build", func() {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	st := &buildapitypes.BuildResponse{
+		Name:             "done",
+		Phase:            "Completed",
+		StartTime:        "2025-06-01T10:00:00Z",
+		CompletionTime:   "2025-06-01T10:30:00Z",
+		ArtifactFileName: "cs9-qemu.qcow2",
+	}
+	tr := &buildapitypes.TaskRunResponse{Name: "done-build-x", Status: "True", Reason: "Succeeded"}
+	parts := []buildapitypes.ArtifactItem{{Name: "cs9-qemu.qcow2.gz", SizeBytes: "1024"}}
+
+	It("should combine the build, its TaskRun condition, artifacts and timings", func() {
+		doc := newBuildDocument("https://api.example/", st, tr, parts, now)
+		Expect(doc.Timings.Duration).To(Equal("30m0s"))
+		Expect(doc.Conditions).To(Equal([]buildCondition{{Type: "Succeeded", Status: "True", Reason: "Succeeded"}}))
+		Expect(doc.Template).To(Equal("https://api.example/v1/builds/done/template"))
+	})
+
+	It("should print the build fields at the top level in JSON and YAML", func() {
+		doc := newBuildDocument("https://api.example", st, nil, parts, now)
+
+		var out bytes.Buffer
+		Expect(printBuildDocument(&out, doc, "json")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring(`"name": "done"`))
+		Expect(out.String()).To(ContainSubstring(`"artifactFileName": "cs9-qemu.qcow2"`))
+		Expect(out.String()).NotTo(ContainSubstring("conditions"))
+
+		out.Reset()
+		Expect(printBuildDocument(&out, doc, "yaml")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("phase: Completed\n"))
+		Expect(out.String()).To(ContainSubstring("- name: cs9-qemu.qcow2.gz\n"))
+		Expect(out.String()).To(ContainSubstring("  duration: 30m0s\n"))
+	})
+})
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+func runImagesList(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	images, err := api.ListImages(ctx, buildapitypes.ImageFilter{
+		Distro:       strings.TrimSpace(imagesDistro),
+		Architecture: strings.TrimSpace(imagesArch),
+		Tag:          strings.TrimSpace(imagesTag),
+	})
+	if err != nil {
+		fmt.Printf("Error listing Images: %v\n", err)
+		os.Exit(1)
+	}
+	if len(images) == 0 {
+		fmt.Println("No Images found")
+		return
+	}
+	if err := printImages(os.Stdout, images); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printImages writes images as a table; SOURCE is the build an image was promoted from, or its registry
+// reference for a registered external image
+func printImages(w io.Writer, images []buildapitypes.ImageResponse) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tLIFECYCLE\tDISTRO\tARCH\tFORMAT\tVERSION\tSIZE\tTAGS\tSOURCE")
+	for _, img := range images {
+		source := img.SourceBuild
+		if source == "" {
+			source = img.URL
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", img.Name, img.Lifecycle, img.Distro, img.Architecture,
+			img.ExportFormat, orDash(img.Version), formatSize(img.SizeBytes), orDash(strings.Join(img.Tags, ",")), orDash(source))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Listing images", func() {
+	It("should show where each image comes from", func() {
+		images := []buildapitypes.ImageResponse{
+			{Name: "radio-1.2", Lifecycle: "released", Distro: "autosd", Architecture: "arm64", ExportFormat: "qcow2",
+				Version: "1.2", SizeBytes: 2 << 30, Tags: []string{"radio", "nightly"}, SourceBuild: "radio"},
+			{Name: "vendor-bsp", Lifecycle: "candidate", Distro: "autosd", Architecture: "arm64", ExportFormat: "image",
+				URL: "quay.io/vendor/bsp:3"},
+		}
+		var out bytes.Buffer
+		Expect(printImages(&out, images)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("LIFECYCLE"))
+		Expect(out.String()).To(MatchRegexp(`radio-1.2\s+released\s+autosd\s+arm64\s+qcow2\s+1.2\s+2.0 GiB\s+radio,nightly\s+radio\n`))
+		Expect(out.String()).To(MatchRegexp(`vendor-bsp\s+candidate\s+autosd\s+arm64\s+image\s+-\s+-\s+-\s+quay.io/vendor/bsp:3\n`))
+	})
+})
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+func runLint(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	manifestBytes, err := os.ReadFile(manifest)
+	if err != nil {
+		fmt.Printf("Error reading manifest: %v\n", err)
+		os.Exit(1)
+	}
+	additionalManifests, err := readIncludedManifests(includeManifests)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	resp, err := api.Lint(ctx, buildapitypes.LintRequest{
+		Manifest:            string(manifestBytes),
+		ManifestFileName:    filepath.Base(manifest),
+		AdditionalManifests: additionalManifests,
+		CustomDefs:          customDefs,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(resp.Violations) == 0 {
+		fmt.Println("No lint violations")
+		return
+	}
+	for _, v := range resp.Violations {
+		fmt.Println(formatLintViolation(v))
+	}
+	if resp.Blocked {
+		fmt.Println("The manifest violates rules that block builds")
+		os.Exit(1)
+	}
+}
+
+// formatLintViolation renders a violation as "[action] file: rule: message"
+func formatLintViolation(v buildapitypes.LintViolation) string {
+	msg := v.Rule + ": " + v.Message
+	if v.File != "" {
+		msg = v.File + ": " + msg
+	}
+	return fmt.Sprintf("[%s] %s", v.Action, msg)
+}
+
+// readIncludedManifests reads the manifests the main manifest includes, named by their base name
+func readIncludedManifests(paths []string) ([]buildapitypes.ManifestFile, error) {
+	var files []buildapitypes.ManifestFile
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading included manifest: %w", err)
+		}
+		files = append(files, buildapitypes.ManifestFile{Name: filepath.Base(p), Content: string(b)})
+	}
+	return files, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+// listWatchInterval is how often `caib list --watch` polls the build API for changes
+const listWatchInterval = 3 * time.Second
+
+var (
+	listOutputFormats = []string{"", "wide", "json", "yaml"}
+	listSortKeys      = []string{"created", "duration", "phase"}
+)
+
+func runList(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if !contains(listOutputFormats, listOutput) {
+		fmt.Printf("Error: unknown output format %q (wide, json, yaml)\n", listOutput)
+		os.Exit(1)
+	}
+	if !contains(listSortKeys, listSortBy) {
+		fmt.Printf("Error: unknown sort key %q (%s)\n", listSortBy, strings.Join(listSortKeys, ", "))
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	labels, err := parseLabels(buildLabels)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	items, err := api.ListBuilds(ctx, labels)
+	if err != nil {
+		fmt.Printf("Error listing ImageBuilds: %v\n", err)
+		os.Exit(1)
+	}
+	now := time.Now()
+	sortBuilds(items, listSortBy, now)
+	if len(items) == 0 && !listWatch && (listOutput == "" || listOutput == "wide") {
+		fmt.Println("No ImageBuilds found")
+		return
+	}
+	if err := printBuilds(os.Stdout, items, listOutput, !listWatch, true, now); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !listWatch {
+		return
+	}
+
+	// The list is polled; each change to a build prints the build again, as kubectl get --watch does
+	seen := make(map[string]buildapitypes.BuildListItem, len(items))
+	for _, it := range items {
+		seen[it.Name] = it
+	}
+	ticker := time.NewTicker(listWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current, err := api.ListBuilds(ctx, labels)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Error listing ImageBuilds: %v\n", err)
+			continue
+		}
+		changed := changedBuilds(seen, current)
+		if len(changed) == 0 {
+			continue
+		}
+		now := time.Now()
+		sortBuilds(changed, listSortBy, now)
+		if err := printBuilds(os.Stdout, changed, listOutput, false, false, now); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// changedBuilds returns the builds in current that are new or differ from seen, and the builds that
+// disappeared with their phase set to "Deleted". seen is updated to current.
+func changedBuilds(seen map[string]buildapitypes.BuildListItem, current []buildapitypes.BuildListItem) []buildapitypes.BuildListItem {
+	var changed []buildapitypes.BuildListItem
+	present := make(map[string]bool, len(current))
+	for _, it := range current {
+		present[it.Name] = true
+		if prev, ok := seen[it.Name]; !ok || !reflect.DeepEqual(prev, it) {
+			changed = append(changed, it)
+			seen[it.Name] = it
+		}
+	}
+	for name, it := range seen {
+		if !present[name] {
+			it.Phase = "Deleted"
+			it.Message = ""
+			changed = append(changed, it)
+			delete(seen, name)
+		}
+	}
+	return changed
+}
+
+// sortBuilds orders builds by creation time, duration or phase; ties keep creation order
+func sortBuilds(items []buildapitypes.BuildListItem, by string, now time.Time) {
+	sort.SliceStable(items, func(i, j int) bool {
+		switch by {
+		case "duration":
+			di, dj := buildDuration(items[i], now), buildDuration(items[j], now)
+			if di != dj {
+				return di < dj
+			}
+		case "phase":
+			if items[i].Phase != items[j].Phase {
+				return items[i].Phase < items[j].Phase
+			}
+		}
+		return parseTime(items[i].CreatedAt).Before(parseTime(items[j].CreatedAt))
+	})
+}
+
+// buildDuration is how long a build ran, or has been running so far; 0 if it has not started
+func buildDuration(it buildapitypes.BuildListItem, now time.Time) time.Duration {
+	start := parseTime(it.StartTime)
+	if start.IsZero() {
+		return 0
+	}
+	end := parseTime(it.CompletionTime)
+	if end.IsZero() {
+		end = now
+	}
+	return end.Sub(start)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339, s)
+	return t
+}
+
+// printBuilds writes builds as a table, a wide table, JSON or YAML. A complete list is written as one
+// JSON array or YAML list; watch updates are written one object or document per build.
+func printBuilds(w io.Writer, items []buildapitypes.BuildListItem, format string, complete, header bool, now time.Time) error {
+	switch format {
+	case "json":
+		if complete {
+			if items == nil {
+				items = []buildapitypes.BuildListItem{}
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(items)
+		}
+		enc := json.NewEncoder(w)
+		for _, it := range items {
+			if err := enc.Encode(it); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml":
+		if complete {
+			if items == nil {
+				items = []buildapitypes.BuildListItem{}
+			}
+			out, err := yaml.Marshal(items)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(out)
+			return err
+		}
+		for _, it := range items {
+			out, err := yaml.Marshal(it)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "---\n%s", out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	wide := format == "wide"
+	if header {
+		if wide {
+			fmt.Fprintln(tw, "NAME\tSTATUS\tCREATED\tDURATION\tREQUESTED BY\tARTIFACT\tSIZE\tMESSAGE")
+		} else {
+			fmt.Fprintln(tw, "NAME\tSTATUS\tMESSAGE\tCREATED\tARTIFACT")
+		}
+	}
+	for _, it := range items {
+		if wide {
+			duration := "-"
+			if d := buildDuration(it, now); d > 0 {
+				duration = d.Round(time.Second).String()
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", it.Name, buildStatus(it), it.CreatedAt, duration,
+				orDash(it.RequestedBy), orDash(it.ArtifactFileName), formatSize(it.ArtifactSize), it.Message)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", it.Name, buildStatus(it), it.Message, it.CreatedAt, it.ArtifactFileName)
+		}
+	}
+	return tw.Flush()
+}
+
+// formatSize renders a byte count with a binary unit, or "-" for an unknown size
+func formatSize(n int64) string {
+	if n <= 0 {
+		return "-"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// buildStatus is the phase of a build, with the share of its uploads received while it waits for them
+func buildStatus(it buildapitypes.BuildListItem) string {
+	if p := it.UploadProgress; p != nil && p.Percent >= 0 {
+		return fmt.Sprintf("%s (%d%%)", it.Phase, p.Percent)
+	}
+	return it.Phase
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
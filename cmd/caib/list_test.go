@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Listing builds", func() {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	builds := func() []buildapitypes.BuildListItem {
+		return []buildapitypes.BuildListItem{
+			{Name: "running", Phase: "Building", CreatedAt: "2025-06-01T11:50:00Z", StartTime: "2025-06-01T11:50:00Z"},
+			{Name: "done", Phase: "Completed", CreatedAt: "2025-06-01T10:00:00Z", StartTime: "2025-06-01T10:00:00Z",
+				CompletionTime: "2025-06-01T10:30:00Z", ArtifactFileName: "cs9-qemu.qcow2.gz", ArtifactSize: 3 << 30},
+			{Name: "queued", Phase: "Pending", CreatedAt: "2025-06-01T11:55:00Z"},
+		}
+	}
+	names := func(items []buildapitypes.BuildListItem) []string {
+		var out []string
+		for _, it := range items {
+			out = append(out, it.Name)
+		}
+		return out
+	}
+
+	DescribeTable("sorting",
+		func(by string, expected []string) {
+			items := builds()
+			sortBuilds(items, by, now)
+			Expect(names(items)).To(Equal(expected))
+		},
+		Entry("by creation time", "created", []string{"done", "running", "queued"}),
+		Entry("by duration, counting running builds up to now", "duration", []string{"queued", "running", "done"}),
+		Entry("by phase", "phase", []string{"running", "done", "queued"}),
+	)
+
+	It("should show artifact, size, requester and duration in wide output", func() {
+		var out bytes.Buffer
+		Expect(printBuilds(&out, builds()[1:2], "wide", true, true, now)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("DURATION"))
+		Expect(out.String()).To(MatchRegexp(`done\s+Completed\s+\S+\s+30m0s\s+-\s+cs9-qemu.qcow2.gz\s+3.0 GiB`))
+	})
+
+	It("should show how much of its uploads a build received", func() {
+		items := []buildapitypes.BuildListItem{{Name: "inputs", Phase: "Uploading", CreatedAt: "2025-06-01T11:58:00Z",
+			UploadProgress: &buildapitypes.UploadProgress{ReceivedBytes: 35, TotalBytes: 100, Percent: 35}}}
+		var out bytes.Buffer
+		Expect(printBuilds(&out, items, "table", true, true, now)).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`inputs\s+Uploading \(35%\)`))
+
+		items[0].UploadProgress.Percent = -1
+		out.Reset()
+		Expect(printBuilds(&out, items, "wide", true, true, now)).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`inputs\s+Uploading\s+2025`))
+	})
+
+	It("should print an empty JSON list rather than null", func() {
+		var out bytes.Buffer
+		Expect(printBuilds(&out, nil, "json", true, true, now)).To(Succeed())
+		Expect(out.String()).To(Equal("[]\n"))
+	})
+
+	It("should report new, changed and deleted builds while watching", func() {
+		seen := map[string]buildapitypes.BuildListItem{}
+		Expect(changedBuilds(seen, builds())).To(HaveLen(3))
+		Expect(changedBuilds(seen, builds())).To(BeEmpty())
+
+		current := builds()[:2]
+		current[0].Phase = "Completed"
+		changed := changedBuilds(seen, current)
+		Expect(names(changed)).To(ConsistOf("running", "queued"))
+		for _, it := range changed {
+			if it.Name == "queued" {
+				Expect(it.Phase).To(Equal("Deleted"))
+			}
+		}
+	})
+})
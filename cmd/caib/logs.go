@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+func runLogs(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Fprintln(os.Stderr, "Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if logsSave != "" {
+		if err := saveLogsArchive(ctx, api, name, logsSave); err != nil {
+			if buildapiclient.StatusCode(err) == http.StatusConflict {
+				fmt.Fprintf(os.Stderr, "Error: build %s has not finished; follow its logs with caib logs %s\n", name, name)
+			} else {
+				fmt.Fprintf(os.Stderr, "Error saving logs of build %s: %v\n", name, err)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("Logs of build %s saved to %s\n", name, logsSave)
+		return
+	}
+
+	if err := followBuildLogs(ctx, api, name, &logCursor{out: os.Stdout}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting logs of build %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// logReconnects is how often in a row a dropped log stream is reopened without receiving anything new
+const logReconnects = 3
+
+// followBuildLogs copies the logs of a build to cursor until the server reports their end. A stream that a
+// proxy or load balancer drops before that is reopened where it stopped.
+func followBuildLogs(ctx context.Context, api *buildapiclient.Client, name string, cursor *logCursor) error {
+	for failures := 0; ; {
+		opts := cursor.resume()
+		if opts.Step != "" {
+			fmt.Fprintf(os.Stderr, "\nLog stream dropped, resuming step %s at byte %d\n", opts.Step, opts.SinceBytes)
+		}
+		logs, err := api.StreamLogs(ctx, name, opts)
+		if err != nil {
+			return err
+		}
+		step, offset := cursor.step, cursor.offset
+		_, err = io.Copy(cursor, logs)
+		logs.Close()
+		if cursor.completed {
+			return nil
+		}
+		if cursor.step != step || cursor.offset != offset {
+			failures = 0
+		}
+		if failures++; failures > logReconnects {
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("log stream ended early: %w", err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// saveLogsArchive downloads the logs archive of a build next to outPath first, so a failed download does
+// not leave a truncated archive behind
+func saveLogsArchive(ctx context.Context, api *buildapiclient.Client, name, outPath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), "."+filepath.Base(outPath)+"-*.partial")
+	if err != nil {
+		return err
+	}
+	_, err = api.DownloadLogsArchive(ctx, name, tmp, nil)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
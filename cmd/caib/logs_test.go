@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+var _ = Describe("Following logs", func() {
+	It("should drop heartbeats and resume a dropped stream where it stopped", func() {
+		var queries []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			queries = append(queries, r.URL.RawQuery)
+			if len(queries) == 1 {
+				_, _ = w.Write([]byte("Waiting for logs...\n\x00\n===== Logs from build =====\n\nline one\n\x00line t"))
+				return
+			}
+			_, _ = w.Write([]byte("Waiting for logs...\n\n===== Logs from build =====\n\nwo\n\n[Log streaming completed]\n"))
+		}))
+		defer srv.Close()
+		api, err := buildapiclient.New(srv.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		var out bytes.Buffer
+		cursor := &logCursor{out: &out}
+		Expect(followBuildLogs(context.Background(), api, "b", cursor)).To(Succeed())
+		Expect(queries).To(Equal([]string{"follow=1&heartbeat=1", "follow=1&heartbeat=1&sinceBytes=15&step=build"}))
+		Expect(out.String()).NotTo(ContainSubstring("\x00"))
+		Expect(out.String()).To(ContainSubstring("line one\nline t"))
+	})
+})
@@ -2,22 +2,31 @@ package main
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"gopkg.in/yaml.v3"
 
 	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
 	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifactname"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifacttype"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
 	progressbar "github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"k8s.io/client-go/tools/clientcmd"
@@ -27,9 +36,14 @@ var (
 	serverURL              string
 	imageBuildCfg          string
 	manifest               string
+	manifestRef            string
+	includeManifests       []string
+	safeDirs               []string
+	uploadConcurrency      int
 	buildName              string
 	distro                 string
 	target                 string
+	profile                string
 	architecture           string
 	exportFormat           string
 	mode                   string
@@ -39,6 +53,7 @@ var (
 	timeout                int
 	waitForBuild           bool
 	download               bool
+	watchArtifacts         bool
 	customDefs             []string
 	followLogs             bool
 	version                string
@@ -46,7 +61,64 @@ var (
 	aibOverrideArgs        string
 	compressArtifacts      bool
 	compressionAlgo        string
+	artifactNameTemplate   string
+	ostreeRepo             string
+	ostreeRef              string
+	ostreeSecret           string
+	inputPVC               string
+	inputPVCReadOnly       bool
 	authToken              string
+	showDebug              bool
+	downloadAll            bool
+	downloadWorkspace      bool
+	downloadScanReport     bool
+	keepWorkspace          bool
+	workspaceSize          string
+	buildInfo              bool
+	gitRef                 string
+	reuseExisting          bool
+	noCache                bool
+	keyFile                string
+	encryptionSecret       string
+	imageName              string
+	lifecycleState         string
+	lifecycleReason        string
+	imagesDistro           string
+	imagesArch             string
+	imagesTag              string
+	buildLabels            []string
+	accessGroups           []string
+	namespace              string
+	verbose                bool
+	statsWindow            string
+	listOutput             string
+	listSortBy             string
+	listWatch              bool
+	getOutput              string
+	purgeForce             bool
+	logsSave               string
+	promoteNamespace       string
+	promoteRegistry        string
+	promoteSecret          string
+	promoteInsecure        bool
+	convertFormat          string
+	convertWait            bool
+	flashDevice            string
+	flashArtifact          string
+	flashYes               bool
+	verifyMetadata         string
+	verifySignature        string
+	verifyKey              string
+	runArtifact            string
+	runArch                string
+	runMemory              int
+	runCPUs                int
+	runSSHPort             int
+	runFirmware            string
+	runPersist             bool
+	runPrint               bool
+	// resolvedNamespace is the namespace selected by resolveNamespace, empty for the server's default
+	resolvedNamespace string
 )
 
 func main() {
@@ -57,6 +129,10 @@ func main() {
 	}
 
 	rootCmd.InitDefaultVersionFlag()
+	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "", "namespace to work in (default: $CAIB_NAMESPACE, the kubeconfig context namespace, or the server's default)")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "print diagnostic details such as the resolved namespace")
+	rootCmd.PersistentFlags().BoolVar(&noProgress, "no-progress", false, "do not draw progress bars, e.g. in CI logs")
+	rootCmd.PersistentFlags().Var(&limitRate, "limit-rate", "cap the bandwidth of downloads and uploads at this many bytes per second, e.g. 512K, 10M or 1G (default: no limit)")
 	rootCmd.SetVersionTemplate("caib version: {{.Version}}\n")
 
 	buildCmd := &cobra.Command{
@@ -77,28 +153,164 @@ func main() {
 		Run:   runList,
 	}
 
+	getCmd := &cobra.Command{
+		Use:   "get NAME",
+		Short: "Print an ImageBuild with its conditions, artifacts and timings as YAML or JSON",
+		Args:  cobra.ExactArgs(1),
+		Run:   runGet,
+	}
+
+	promoteCmd := &cobra.Command{
+		Use:   "promote NAME",
+		Short: "Push the artifact of a completed build to a registry and catalog it as an Image in another namespace",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPromote,
+	}
+
+	convertCmd := &cobra.Command{
+		Use:   "convert NAME",
+		Short: "Convert the disk image of a completed build to qcow2, vmdk, vdi, vhdx or an Android sparse image in the cluster",
+		Args:  cobra.ExactArgs(1),
+		Run:   runConvert,
+	}
+
+	logsCmd := &cobra.Command{
+		Use:   "logs NAME",
+		Short: "Print the logs of an ImageBuild, or save those of a finished build as a tar.gz archive",
+		Args:  cobra.ExactArgs(1),
+		Run:   runLogs,
+	}
+
+	flashCmd := &cobra.Command{
+		Use:   "flash NAME",
+		Short: "Write the artifact of a completed build to a device, decompressing it and verifying what was written",
+		Args:  cobra.ExactArgs(1),
+		Run:   runFlash,
+	}
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify ARTIFACT",
+		Short: "Check a downloaded artifact's size, checksum and optional cosign signature against its metadata file, offline",
+		Args:  cobra.ExactArgs(1),
+		Run:   runVerify,
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run NAME",
+		Short: "Boot the qcow2 or raw artifact of a completed build in a local QEMU virtual machine",
+		Args:  cobra.ExactArgs(1),
+		Run:   runQemu,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the status of an ImageBuild",
+		Run:   runShow,
+	}
+
+	cancelCmd := &cobra.Command{
+		Use:   "cancel",
+		Short: "Cancel an ImageBuild that has not finished",
+		Run:   runCancel,
+	}
+
+	purgeCmd := &cobra.Command{
+		Use:   "purge NAME",
+		Short: "Delete an ImageBuild with its workspace, artifact and registry credentials",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPurge,
+	}
+
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check manifests against the server's lint policy without building",
+		Run:   runLint,
+	}
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show build statistics: outcomes, durations and per-distro/target breakdowns",
+		Run:   runStats,
+	}
+
+	quotaCmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show the storage held by build workspaces in the namespace, per requester, and its limit",
+		Run:   runQuota,
+	}
+
+	imageCmd := &cobra.Command{
+		Use:     "image",
+		Aliases: []string{"images"},
+		Short:   "Manage Image resources",
+	}
+
+	imageListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the Images of the catalog",
+		Run:   runImagesList,
+	}
+
+	imageLifecycleCmd := &cobra.Command{
+		Use:   "lifecycle",
+		Short: "Move an Image to a new lifecycle state (released, deprecated, revoked)",
+		Run:   runImageLifecycle,
+	}
+
 	buildCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
 	buildCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
 	buildCmd.Flags().StringVar(&imageBuildCfg, "config", "", "path to ImageBuild YAML configuration file")
 	buildCmd.Flags().StringVar(&manifest, "manifest", "", "path to manifest YAML file for the build")
-	buildCmd.Flags().StringVar(&buildName, "name", "", "name for the ImageBuild")
+	buildCmd.Flags().StringVar(&manifestRef, "manifest-ref", "", "OCI artifact holding the manifests to build, pulled by the build instead of sending --manifest")
+	buildCmd.Flags().StringArrayVar(&includeManifests, "include", []string{}, "path to an additional manifest file the main manifest includes (can be specified multiple times)")
+	buildCmd.Flags().StringArrayVar(&safeDirs, "safe-dir", []string{}, "directory absolute source_path entries in the manifest may refer to (can be specified multiple times)")
+	buildCmd.Flags().IntVar(&uploadConcurrency, "upload-concurrency", 4, "number of local files uploaded in parallel")
+	buildCmd.Flags().StringVar(&buildName, "name", "", "name for the ImageBuild (default: generated from the manifest's file name)")
 	buildCmd.Flags().StringVar(&distro, "distro", "autosd", "distribution to build")
 	buildCmd.Flags().StringVar(&target, "target", "qemu", "target platform (qemu, etc)")
-	buildCmd.Flags().StringVar(&architecture, "arch", "arm64", "architecture (amd64, arm64)")
+	buildCmd.Flags().StringVar(&profile, "profile", "", "build profile of a board supplying the target, architecture, export format, AIB args and defines (e.g. ridesx4)")
+	buildCmd.Flags().StringVar(&architecture, "arch", "", "architecture (amd64, arm64); required unless --profile is given")
 	buildCmd.Flags().StringVar(&exportFormat, "export-format", "image", "export format (image, qcow2, etc)")
 	buildCmd.Flags().StringVar(&mode, "mode", "image", "build mode")
-	buildCmd.Flags().StringVar(&automotiveImageBuilder, "automotive-image-builder", "quay.io/centos-sig-automotive/automotive-image-builder:1.0.0", "container image for automotive-image-builder")
+	buildCmd.Flags().StringVar(&automotiveImageBuilder, "automotive-image-builder", "", "container image for automotive-image-builder (default: the server's builder image)")
 	buildCmd.Flags().StringVar(&storageClass, "storage-class", "", "storage class to use for build workspace PVC")
 	buildCmd.Flags().IntVar(&timeout, "timeout", 60, "timeout in minutes when waiting for build completion")
 	buildCmd.Flags().BoolVarP(&waitForBuild, "wait", "w", false, "wait for the build to complete")
 	buildCmd.Flags().BoolVarP(&download, "download", "d", false, "automatically download artifacts when build completes")
+	buildCmd.Flags().BoolVar(&watchArtifacts, "watch-artifacts", false, "download every file of the build (parts, metadata, artifact, conversions) as it appears, ending with a complete copy in --output-dir")
+	buildCmd.Flags().StringVar(&outputDir, "output-dir", "./output", "directory to save artifacts with --download or --watch-artifacts")
 	buildCmd.Flags().BoolVar(&compressArtifacts, "compress", true, "compress directory artifacts (tar.gz). For directories, server always compresses.")
 	buildCmd.Flags().BoolVarP(&followLogs, "follow", "f", false, "follow logs of the build")
 	buildCmd.Flags().StringArrayVar(&customDefs, "define", []string{}, "Custom definition in KEY=VALUE format (can be specified multiple times)")
 	buildCmd.Flags().StringVar(&aibExtraArgs, "aib-args", "", "extra arguments passed to automotive-image-builder (space-separated)")
 	buildCmd.Flags().StringVar(&aibOverrideArgs, "override", "", "override arguments passed as-is to automotive-image-builder")
-	buildCmd.Flags().StringVar(&compressionAlgo, "compression", "gzip", "artifact compression algorithm (lz4|gzip)")
-	_ = buildCmd.MarkFlagRequired("arch")
+	buildCmd.Flags().StringVar(&compressionAlgo, "compression", "gzip", "artifact compression algorithm (lz4|gzip|none); none is fastest on local clusters")
+	buildCmd.Flags().StringVar(&artifactNameTemplate, "artifact-name", "", "Go template naming the artifact from .Distro, .Target, .Arch, .Name and .Date, e.g. '{{.Name}}-{{.Date}}' (default: the server's template)")
+	buildCmd.Flags().StringVar(&ostreeRepo, "ostree-repo", "", "remote ostree repository to push a commit of the build to with rsync over SSH, e.g. ostree@updates.example.com:/srv/ostree/repo")
+	buildCmd.Flags().StringVar(&ostreeRef, "ostree-ref", "", "ostree ref to commit the build to on top of its current commit, e.g. autosd/aarch64/qemu (requires --ostree-repo)")
+	buildCmd.Flags().StringVar(&inputPVC, "input-pvc", "", "PersistentVolumeClaim of the build namespace to mount at "+buildapitypes.InputMountPath+", as CLAIM or CLAIM/SUBPATH; the manifest references its files as source_path: "+buildapitypes.InputMountPath+"/<file>")
+	buildCmd.Flags().BoolVar(&inputPVCReadOnly, "input-pvc-read-only", false, "mount the --input-pvc claim read-only")
+	buildCmd.Flags().StringVar(&ostreeSecret, "ostree-secret", "", "kubernetes.io/ssh-auth secret of the build namespace logging in to the ostree repository's host")
+	buildCmd.Flags().StringArrayVar(&buildLabels, "label", []string{}, "label in KEY=VALUE format to attach to the build (can be specified multiple times)")
+	buildCmd.Flags().StringArrayVar(&accessGroups, "access-group", []string{}, "group to share the build with when the server restricts access to builds (can be specified multiple times; default: your groups)")
+	buildCmd.Flags().StringVar(&workspaceSize, "workspace-size", "", "size of the build's workspace PVC, e.g. 24Gi (default: estimated from the manifest)")
+	buildCmd.Flags().BoolVar(&keepWorkspace, "keep-workspace", false, "keep the build workspace and its logs for debugging if the build fails (default: the server's setting)")
+	buildCmd.Flags().BoolVar(&buildInfo, "build-info", false, "bake build provenance into the image as /etc/automotive-build-info")
+	buildCmd.Flags().StringVar(&gitRef, "git-ref", "", "source revision recorded in the build info (default: HEAD of the manifest's git repository)")
+	buildCmd.Flags().BoolVar(&reuseExisting, "reuse", false, "return a completed build of the same manifests and settings that still serves its artifact instead of rebuilding")
+	buildCmd.Flags().BoolVar(&noCache, "no-cache", false, "build even when the result cache holds an identical build")
+	buildCmd.Flags().StringVar(&keyFile, "key-file", "", "encrypt the artifacts with the key in this file, generated if it does not exist, and decrypt them with --download")
+	buildCmd.Flags().StringVar(&encryptionSecret, "encryption-secret", "", "encrypt the artifacts with the key entry of this secret of the build namespace; --key-file then only decrypts them")
+	_ = buildCmd.RegisterFlagCompletionFunc("distro", catalogCompletion(func(c *buildapitypes.CatalogResponse) []string { return c.Distros }))
+	_ = buildCmd.RegisterFlagCompletionFunc("target", catalogCompletion(func(c *buildapitypes.CatalogResponse) []string { return c.Targets }))
+	_ = buildCmd.RegisterFlagCompletionFunc("arch", catalogCompletion(func(c *buildapitypes.CatalogResponse) []string { return c.Architectures }))
+	_ = buildCmd.RegisterFlagCompletionFunc("profile", catalogCompletion(func(c *buildapitypes.CatalogResponse) []string {
+		names := make([]string, 0, len(c.Profiles))
+		for _, p := range c.Profiles {
+			names = append(names, p.Name)
+		}
+		return names
+	}))
 
 	downloadCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
 	downloadCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
@@ -106,27 +318,144 @@ func main() {
 	downloadCmd.Flags().StringVar(&outputDir, "output-dir", "./output", "directory to save artifacts")
 	downloadCmd.MarkFlagRequired("name")
 	downloadCmd.Flags().BoolVar(&compressArtifacts, "compress", true, "compress directory artifacts (tar.gz). For directories, server always compresses.")
+	downloadCmd.Flags().BoolVar(&downloadAll, "all", false, "download every output of the build (image, image.json, SBOMs, ...) as a single tar archive")
+	downloadCmd.Flags().BoolVar(&downloadWorkspace, "workspace", false, "download the workspace a failed build kept for debugging, including its build directory logs, as a tar archive")
+	downloadCmd.Flags().BoolVar(&downloadScanReport, "scan-report", false, "download the JSON report of the build's vulnerability scan, also available when the scan policy blocks the artifact")
+	downloadCmd.Flags().StringVar(&keyFile, "key-file", "", "decrypt the artifact of an encrypted build with the key in this file")
 
 	listCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
 	listCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	listCmd.Flags().StringArrayVar(&buildLabels, "label", []string{}, "only list builds with this KEY=VALUE label (can be specified multiple times)")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format: wide, json or yaml (default: a table)")
+	listCmd.Flags().StringVar(&listSortBy, "sort-by", "created", "sort builds by created, duration or phase")
+	listCmd.Flags().BoolVarP(&listWatch, "watch", "w", false, "keep running and print builds again as they change")
+
+	getCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	getCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	getCmd.Flags().StringVarP(&getOutput, "output", "o", "yaml", "output format: yaml or json")
+
+	promoteCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	promoteCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	promoteCmd.Flags().StringVar(&promoteNamespace, "to-namespace", "", "namespace to create the Image in")
+	promoteCmd.Flags().StringVar(&promoteRegistry, "registry", "", "tagged reference to push the artifact to (e.g. quay.io/org/image:1.0)")
+	promoteCmd.Flags().StringVar(&promoteSecret, "secret", "", "dockerconfigjson secret in the target namespace with push credentials for the registry")
+	promoteCmd.Flags().BoolVar(&promoteInsecure, "insecure-registry", false, "talk to the registry over plain HTTP")
+	promoteCmd.Flags().StringVar(&imageName, "image-name", "", "name of the Image (default: the build name)")
+	_ = promoteCmd.MarkFlagRequired("to-namespace")
+	_ = promoteCmd.MarkFlagRequired("registry")
+
+	convertCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	convertCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	convertCmd.Flags().StringVar(&convertFormat, "format", "", "format to convert to: qcow2, vmdk, vdi, vhdx or simg (Android sparse image)")
+	convertCmd.Flags().BoolVar(&convertWait, "wait", false, "wait for the conversion to finish")
+	_ = convertCmd.MarkFlagRequired("format")
+	_ = convertCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"qcow2", "vmdk", "vdi", "vhdx", "simg"}, cobra.ShellCompDirectiveNoFileComp))
+
+	logsCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	logsCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	logsCmd.Flags().StringVar(&logsSave, "save", "", "save the logs of every step of a finished build to this tar.gz file instead of printing them")
+
+	flashCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	flashCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	flashCmd.Flags().StringVar(&flashDevice, "device", "", "device to write the image to (e.g. /dev/sdb, or /dev/rdisk4 on macOS)")
+	flashCmd.Flags().StringVar(&flashArtifact, "artifact", "", "local artifact to write instead of the build's (.gz, .lz4, .xz, raw or sparse .simg)")
+	flashCmd.Flags().StringVar(&outputDir, "output-dir", "./output", "directory the artifact is downloaded to, or reused from")
+	flashCmd.Flags().BoolVarP(&flashYes, "yes", "y", false, "do not ask for confirmation before overwriting the device")
+	flashCmd.Flags().StringVar(&keyFile, "key-file", "", "decrypt the artifact of an encrypted build with the key in this file")
+	_ = flashCmd.MarkFlagRequired("device")
+
+	runCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	runCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	runCmd.Flags().StringVar(&runArtifact, "artifact", "", "local image to boot instead of the build's")
+	runCmd.Flags().StringVar(&outputDir, "output-dir", "./output", "directory the artifact is downloaded to, or reused from")
+	runCmd.Flags().StringVar(&runArch, "arch", "", "architecture of the image, amd64 or arm64 (default: the build's, or this machine's with --artifact)")
+	runCmd.Flags().IntVar(&runMemory, "memory", 2048, "memory of the virtual machine in MiB")
+	runCmd.Flags().IntVar(&runCPUs, "cpus", 2, "number of virtual CPUs")
+	runCmd.Flags().IntVar(&runSSHPort, "ssh-port", 2222, "local port forwarded to the guest's SSH port, 0 to forward none")
+	runCmd.Flags().StringVar(&runFirmware, "firmware", "", "UEFI firmware to boot with (default: the edk2 firmware installed for the architecture)")
+	runCmd.Flags().BoolVar(&runPersist, "persist", false, "keep the guest's changes in the image instead of discarding them on exit")
+	runCmd.Flags().BoolVar(&runPrint, "print", false, "print the QEMU command line instead of running it")
+	runCmd.Flags().StringVar(&keyFile, "key-file", "", "decrypt the artifact of an encrypted build with the key in this file")
+
+	showCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	showCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	showCmd.Flags().StringVar(&buildName, "name", "", "name of the ImageBuild")
+	showCmd.Flags().BoolVar(&showDebug, "debug", false, "also show the backing TaskRun step statuses")
+	showCmd.MarkFlagRequired("name")
+
+	cancelCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	cancelCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	cancelCmd.Flags().StringVar(&buildName, "name", "", "name of the ImageBuild")
+	cancelCmd.MarkFlagRequired("name")
+
+	purgeCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	purgeCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	purgeCmd.Flags().BoolVar(&purgeForce, "force", false, "also delete a build that is still uploading or building")
+
+	lintCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	lintCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	lintCmd.Flags().StringVar(&manifest, "manifest", "", "path to manifest YAML file to lint")
+	lintCmd.Flags().StringArrayVar(&includeManifests, "include", []string{}, "path to an additional manifest file the main manifest includes (can be specified multiple times)")
+	lintCmd.Flags().StringArrayVar(&customDefs, "define", []string{}, "Custom definition in KEY=VALUE format (can be specified multiple times)")
+	lintCmd.MarkFlagRequired("manifest")
 
-	rootCmd.AddCommand(buildCmd, downloadCmd, listCmd)
+	statsCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	statsCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	statsCmd.Flags().StringVar(&statsWindow, "window", "7d", "time window to summarize, in days (7d) or as a duration (36h)")
+
+	quotaCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	quotaCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+
+	verifyCmd.Flags().StringVar(&verifyMetadata, "metadata", "", "metadata file of the artifact (default: ARTIFACT.metadata.json)")
+	verifyCmd.Flags().StringVar(&verifySignature, "signature", "", "base64 signature of the artifact, as written by cosign sign-blob")
+	verifyCmd.Flags().StringVar(&verifyKey, "key", "", "PEM public key to check --signature with, as written by cosign generate-key-pair")
+
+	imageLifecycleCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	imageLifecycleCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	imageLifecycleCmd.Flags().StringVar(&imageName, "name", "", "name of the Image")
+	imageLifecycleCmd.Flags().StringVar(&lifecycleState, "state", "", "target lifecycle state (candidate, released, deprecated, revoked)")
+	imageLifecycleCmd.Flags().StringVar(&lifecycleReason, "reason", "", "reason for the transition, recorded on the Image")
+	imageLifecycleCmd.MarkFlagRequired("name")
+	imageLifecycleCmd.MarkFlagRequired("state")
+	imageListCmd.Flags().StringVar(&serverURL, "server", os.Getenv("CAIB_SERVER"), "REST API server base URL (e.g. https://api.example)")
+	imageListCmd.Flags().StringVar(&authToken, "token", os.Getenv("CAIB_TOKEN"), "Bearer token for authentication (e.g., OpenShift access token)")
+	imageListCmd.Flags().StringVar(&imagesDistro, "distro", "", "only list images of this distribution")
+	imageListCmd.Flags().StringVar(&imagesArch, "arch", "", "only list images of this architecture")
+	imageListCmd.Flags().StringVar(&imagesTag, "tag", "", "only list images carrying this tag")
+	imageCmd.AddCommand(imageLifecycleCmd, imageListCmd)
+
+	rootCmd.AddCommand(buildCmd, downloadCmd, flashCmd, verifyCmd, listCmd, getCmd, logsCmd, runCmd, showCmd, cancelCmd, purgeCmd, promoteCmd, convertCmd, lintCmd, statsCmd, quotaCmd, imageCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitInvalidArgs)
 	}
 }
 
 func runBuild(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
+	summary := &buildSummary{}
+	activeSummary = summary
+	defer summary.finish(os.Stdout, nil)
 
 	if err := validateBuildRequirements(); err != nil {
-		handleError(err)
+		handleError(withExitCode(exitInvalidArgs, err))
+	}
+	// a profile supplies the architecture; without one it must be chosen explicitly
+	if profile == "" && architecture == "" {
+		handleError(withExitCode(exitInvalidArgs, fmt.Errorf("--arch is required unless --profile is given")))
+	}
+	// the server takes what the profile sets from it unless the flags set it themselves
+	if profile != "" {
+		for name, v := range map[string]*string{"target": &target, "export-format": &exportFormat} {
+			if !cmd.Flags().Changed(name) {
+				*v = ""
+			}
+		}
 	}
 
 	if serverURL == "" {
-		handleError(fmt.Errorf("--server is required"))
+		handleError(withExitCode(exitInvalidArgs, fmt.Errorf("--server is required")))
 	}
 
 	if serverURL != "" {
@@ -139,35 +468,51 @@ func runBuild(cmd *cobra.Command, args []string) {
 		if strings.TrimSpace(authToken) != "" {
 			opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
 		}
-		api, err := buildapiclient.New(serverURL, opts...)
-		if err != nil {
-			handleError(err)
+		opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+		// a trace started by a CI job or wrapper script is recorded with the build
+		if tp := strings.TrimSpace(os.Getenv("TRACEPARENT")); tp != "" {
+			opts = append(opts, buildapiclient.WithTraceparent(tp))
 		}
-
-		manifestBytes, err := os.ReadFile(manifest)
+		api, err := buildapiclient.New(serverURL, opts...)
 		if err != nil {
-			handleError(fmt.Errorf("error reading manifest: %w", err))
+			handleError(withExitCode(exitInvalidArgs, err))
 		}
 
 		parsedDistro, err := buildapitypes.ParseDistro(distro)
 		if err != nil {
-			handleError(err)
+			handleError(withExitCode(exitInvalidArgs, err))
 		}
-		parsedTarget, err := buildapitypes.ParseTarget(target)
+		parsedTarget, err := parseUnlessEmpty(target, buildapitypes.ParseTarget)
 		if err != nil {
-			handleError(err)
+			handleError(withExitCode(exitInvalidArgs, err))
 		}
-		parsedArch, err := buildapitypes.ParseArchitecture(architecture)
+		parsedArch, err := parseUnlessEmpty(architecture, buildapitypes.ParseArchitecture)
 		if err != nil {
-			handleError(err)
+			handleError(withExitCode(exitInvalidArgs, err))
 		}
-		parsedExportFormat, err := buildapitypes.ParseExportFormat(exportFormat)
+		parsedExportFormat, err := parseUnlessEmpty(exportFormat, buildapitypes.ParseExportFormat)
 		if err != nil {
-			handleError(err)
+			handleError(withExitCode(exitInvalidArgs, err))
 		}
 		parsedMode, err := buildapitypes.ParseMode(mode)
 		if err != nil {
-			handleError(err)
+			handleError(withExitCode(exitInvalidArgs, err))
+		}
+
+		labels, err := parseLabels(buildLabels)
+		if err != nil {
+			handleError(withExitCode(exitInvalidArgs, err))
+		}
+		if artifactNameTemplate != "" {
+			if err := artifactname.Validate(artifactNameTemplate); err != nil {
+				handleError(withExitCode(exitInvalidArgs, err))
+			}
+		}
+		if (ostreeRepo == "") != (ostreeRef == "") || (ostreeSecret != "" && ostreeRepo == "") {
+			handleError(withExitCode(exitInvalidArgs, fmt.Errorf("--ostree-repo and --ostree-ref must be given together")))
+		}
+		if inputPVCReadOnly && inputPVC == "" {
+			handleError(withExitCode(exitInvalidArgs, fmt.Errorf("--input-pvc-read-only requires --input-pvc")))
 		}
 
 		var aibArgsArray []string
@@ -181,8 +526,9 @@ func runBuild(cmd *cobra.Command, args []string) {
 
 		req := buildapitypes.BuildRequest{
 			Name:                   buildName,
-			Manifest:               string(manifestBytes),
-			ManifestFileName:       filepath.Base(manifest),
+			IdempotencyKey:         uuid.NewString(),
+			ManifestRef:            manifestRef,
+			Profile:                profile,
 			Distro:                 parsedDistro,
 			Target:                 parsedTarget,
 			Architecture:           parsedArch,
@@ -195,22 +541,117 @@ func runBuild(cmd *cobra.Command, args []string) {
 			AIBOverrideArgs:        aibOverrideArray,
 			ServeArtifact:          download,
 			Compression:            compressionAlgo,
+			ArtifactNameTemplate:   artifactNameTemplate,
+			Labels:                 labels,
+			AccessGroups:           accessGroups,
+			ReuseExisting:          reuseExisting,
+		}
+		if noCache {
+			req.Cache = "bypass"
+		}
+		if cmd.Flags().Changed("keep-workspace") {
+			req.KeepWorkspaceOnFailure = &keepWorkspace
+		}
+		req.WorkspaceSize = workspaceSize
+		// with a secret holding the key, the key file only decrypts the downloaded artifact
+		if keyFile != "" && encryptionSecret == "" {
+			if req.EncryptionKey, err = ensureKeyFile(keyFile); err != nil {
+				handleError(err)
+			}
+		}
+		req.EncryptionKeySecret = encryptionSecret
+		if ostreeRepo != "" {
+			req.OSTree = &buildapitypes.OSTreeUpdate{RepositoryURL: ostreeRepo, Ref: ostreeRef, SecretRef: ostreeSecret}
+		}
+		if inputPVC != "" {
+			claim, subPath, _ := strings.Cut(inputPVC, "/")
+			req.InputPVC = &buildapitypes.InputPVC{ClaimName: claim, SubPath: subPath, ReadOnly: inputPVCReadOnly}
+		}
+		if buildName == "" {
+			req.GenerateName = generateBuildName(manifest, manifestRef)
+		}
+
+		// manifests of an artifact are pulled by the build and cannot reference local files
+		var localRefs []map[string]string
+		if manifestRef == "" {
+			manifestBytes, err := os.ReadFile(manifest)
+			if err != nil {
+				handleError(withExitCode(exitInvalidArgs, fmt.Errorf("error reading manifest: %w", err)))
+			}
+			additionalManifests, err := readIncludedManifests(includeManifests)
+			if err != nil {
+				handleError(withExitCode(exitInvalidArgs, err))
+			}
+
+			// Local file references are resolved up front so the manifests sent carry POSIX upload paths
+			style := pathStyleFor(runtime.GOOS)
+			manifestContent := string(manifestBytes)
+			localRefs, err = findLocalFileReferences(manifestContent, style, safeDirs)
+			if err != nil {
+				handleError(withExitCode(exitInvalidArgs, fmt.Errorf("manifest file reference error: %w", err)))
+			}
+			if manifestContent, err = rewriteSourcePaths(manifestContent, uploadPaths(localRefs)); err != nil {
+				handleError(err)
+			}
+			for i, m := range additionalManifests {
+				refs, err := findLocalFileReferences(m.Content, style, safeDirs)
+				if err != nil {
+					handleError(withExitCode(exitInvalidArgs, fmt.Errorf("manifest file reference error in %s: %w", m.Name, err)))
+				}
+				if additionalManifests[i].Content, err = rewriteSourcePaths(m.Content, uploadPaths(refs)); err != nil {
+					handleError(err)
+				}
+				localRefs = append(localRefs, refs...)
+			}
+			req.Manifest = manifestContent
+			req.ManifestFileName = filepath.Base(manifest)
+			req.AdditionalManifests = additionalManifests
+		}
+		if buildInfo {
+			req.BuildInfo = true
+			req.GitRef = gitRef
+			if req.GitRef == "" && manifestRef == "" {
+				req.GitRef = manifestGitRef(manifest)
+			}
 		}
 
 		resp, err := api.CreateBuild(ctx, req)
 		if err != nil {
+			// the server rejects invalid requests before creating anything
+			if code := buildapiclient.StatusCode(err); code == http.StatusBadRequest || code == http.StatusUnprocessableEntity {
+				err = withExitCode(exitInvalidArgs, err)
+			}
 			handleError(err)
 		}
+		summary.Build, summary.Phase = resp.Name, resp.Phase
 		fmt.Printf("Build %s accepted: %s - %s\n", resp.Name, resp.Phase, resp.Message)
-		// If manifest references local files, upload them via the API
-		localRefs, err := findLocalFileReferences(string(manifestBytes))
-		if err != nil {
-			handleError(fmt.Errorf("manifest file reference error: %w", err))
+		if verbose {
+			fmt.Printf("Request ID: %s\n", api.RequestID())
+		}
+		if resp.Profile != "" {
+			fmt.Printf("Profile: %s\n", resp.Profile)
+		}
+		if img := resp.CachedImage; img != nil {
+			// the cached build may be gone; its result is the image it was promoted to
+			ref := img.URL
+			if parsed, err := registry.ParseReference(img.URL); err == nil && img.Digest != "" {
+				ref = parsed.WithDigest(img.Digest)
+			}
+			fmt.Printf("Cached image: %s/%s\nPull it with: oras pull %s\n", img.Namespace, img.Name, ref)
+			return
 		}
+		if resp.Reused {
+			// the reused build finished long ago; there are no logs left to follow
+			followLogs = false
+		}
+		for _, v := range resp.LintWarnings {
+			fmt.Printf("lint: %s\n", formatLintViolation(v))
+		}
+		// If manifest references local files, upload them via the API
 		if len(localRefs) > 0 {
 			for _, ref := range localRefs {
 				if _, err := os.Stat(ref["source_path"]); err != nil {
-					handleError(fmt.Errorf("referenced file %s does not exist: %w", ref["source_path"], err))
+					handleError(withExitCode(exitInvalidArgs, fmt.Errorf("referenced file %s does not exist: %w", ref["source_path"], err)))
 				}
 			}
 
@@ -219,7 +660,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 			defer cancel()
 			for {
 				if err := readyCtx.Err(); err != nil {
-					handleError(fmt.Errorf("timed out waiting for upload server to be ready"))
+					handleError(withExitCode(exitUploadFailed, fmt.Errorf("timed out waiting for upload server to be ready")))
 				}
 				reqCtx, c := context.WithTimeout(ctx, 15*time.Second)
 				st, err := api.GetBuild(reqCtx, resp.Name)
@@ -228,8 +669,10 @@ func runBuild(cmd *cobra.Command, args []string) {
 					if st.Phase == "Uploading" {
 						break
 					}
+					summary.Phase = st.Phase
 					if st.Phase == "Failed" {
-						handleError(fmt.Errorf("build failed while waiting for upload server: %s", st.Message))
+						printConsoleLinks(st)
+						handleError(withExitCode(exitBuildFailed, fmt.Errorf("build failed while waiting for upload server: %s", st.Message)))
 					}
 				}
 				time.Sleep(3 * time.Second)
@@ -237,29 +680,46 @@ func runBuild(cmd *cobra.Command, args []string) {
 
 			uploads := make([]buildapiclient.Upload, 0, len(localRefs))
 			for _, ref := range localRefs {
-				uploads = append(uploads, buildapiclient.Upload{SourcePath: ref["source_path"], DestPath: ref["source_path"]})
+				uploads = append(uploads, buildapiclient.Upload{SourcePath: ref["source_path"], DestPath: ref["upload_path"]})
 			}
 
 			uploadDeadline := time.Now().Add(10 * time.Minute)
+			var uploaded *buildapitypes.UploadResponse
 			for {
-				if err := api.UploadFiles(ctx, resp.Name, uploads); err != nil {
+				var err error
+				// a retry resumes the files the workspace already holds part of
+				bar := newProgressBar(-1, "Uploading",
+					progressbar.OptionShowBytes(true),
+					progressbar.OptionSetWidth(15),
+					progressbar.OptionThrottle(65*time.Millisecond),
+				)
+				opts := buildapiclient.UploadOptions{
+					Concurrency: uploadConcurrency,
+					Progress: func(stored, total int64) {
+						bar.ChangeMax64(total)
+						_ = bar.Set64(stored)
+					},
+				}
+				uploaded, err = api.UploadFiles(ctx, resp.Name, uploads, opts)
+				_ = bar.Finish()
+				if err != nil {
 					lower := strings.ToLower(err.Error())
 					if time.Now().After(uploadDeadline) {
-						handleError(fmt.Errorf("upload files failed: %w", err))
+						handleError(withExitCode(exitUploadFailed, fmt.Errorf("upload files failed: %w", err)))
 					}
 					if strings.Contains(lower, "503") || strings.Contains(lower, "service unavailable") || strings.Contains(lower, "upload pod not ready") {
 						fmt.Println("Upload server not ready yet. Retrying...")
 						time.Sleep(5 * time.Second)
 						continue
 					}
-					handleError(fmt.Errorf("upload files failed: %w", err))
+					handleError(withExitCode(exitUploadFailed, fmt.Errorf("upload files failed: %w", err)))
 				}
 				break
 			}
-			fmt.Println("Local files uploaded. Build will proceed.")
+			fmt.Printf("Local files uploaded and verified (%d files, sha256). Build will proceed.\n", len(uploaded.Files))
 		}
 
-		if waitForBuild || followLogs || download {
+		if waitForBuild || followLogs || download || watchArtifacts {
 			fmt.Println("Waiting for build to complete...")
 			timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Minute)
 			defer cancel()
@@ -268,50 +728,42 @@ func runBuild(cmd *cobra.Command, args []string) {
 			userFollowRequested := followLogs
 			var lastPhase, lastMessage string
 			logFollowWarned := false
-
-			logClient := &http.Client{
-				Timeout: 10 * time.Minute,
-				Transport: &http.Transport{
-					ResponseHeaderTimeout: 30 * time.Second,
-					IdleConnTimeout:       2 * time.Minute,
-				},
+			cursor := &logCursor{out: os.Stdout}
+			var mirror *artifactMirror
+			if watchArtifacts {
+				mirror = newArtifactMirror(api, resp.Name, outputDir)
 			}
 
 			for {
 				select {
 				case <-timeoutCtx.Done():
-					handleError(fmt.Errorf("timed out waiting for build"))
+					handleError(withExitCode(exitTimedOut, fmt.Errorf("timed out waiting for build")))
 				case <-ticker.C:
 					if followLogs {
-						req, _ := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(serverURL, "/")+"/v1/builds/"+url.PathEscape(resp.Name)+"/logs?follow=1", nil)
-						if strings.TrimSpace(authToken) != "" {
-							req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(authToken))
-						}
-						resp2, err := logClient.Do(req)
-						if err == nil && resp2.StatusCode == http.StatusOK {
-							fmt.Println("Streaming logs...")
-							io.Copy(os.Stdout, resp2.Body)
-							resp2.Body.Close()
-							followLogs = userFollowRequested
-						} else if resp2 != nil {
-							body, _ := io.ReadAll(resp2.Body)
-							msg := strings.TrimSpace(string(body))
-							if resp2.StatusCode == http.StatusServiceUnavailable || resp2.StatusCode == http.StatusGatewayTimeout {
-								if !logFollowWarned {
-									fmt.Println("log stream not ready (HTTP", resp2.StatusCode, "). Retrying…")
-									logFollowWarned = true
-								}
-								// treat as transient; keep trying silently afterwards
-							} else {
-								if msg != "" {
-									fmt.Printf("log stream error (%d): %s\n", resp2.StatusCode, msg)
-								} else {
-									fmt.Printf("log stream error: HTTP %d\n", resp2.StatusCode)
-								}
-								followLogs = false
+						// a stream is reopened where it stopped if it outlives the request timeout
+						logCtx, cancelLogs := context.WithTimeout(ctx, 10*time.Minute)
+						logs, err := api.StreamLogs(logCtx, resp.Name, cursor.resume())
+						if err == nil {
+							if cursor.step == "" {
+								fmt.Println("Streaming logs...")
+							} else if verbose {
+								fmt.Fprintf(os.Stderr, "\nResuming logs of step %s at byte %d\n", cursor.step, cursor.offset)
+							}
+							io.Copy(cursor, logs)
+							logs.Close()
+							// once the server reports the end of the logs there is nothing left to resume
+							followLogs = userFollowRequested && !cursor.completed
+						} else if code := buildapiclient.StatusCode(err); code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout {
+							if !logFollowWarned {
+								fmt.Println("log stream not ready (HTTP", code, "). Retrying…")
+								logFollowWarned = true
 							}
-							resp2.Body.Close()
+							// treat as transient; keep trying silently afterwards
+						} else if code != 0 {
+							fmt.Printf("log stream error: %v\n", err)
+							followLogs = false
 						}
+						cancelLogs()
 					}
 					reqCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 					st, err := api.GetBuild(reqCtx, resp.Name)
@@ -320,6 +772,7 @@ func runBuild(cmd *cobra.Command, args []string) {
 						fmt.Printf("status check failed: %v\n", err)
 						continue
 					}
+					summary.Phase = st.Phase
 					if !userFollowRequested {
 						if st.Phase != lastPhase || st.Message != lastMessage {
 							fmt.Printf("status: %s - %s\n", st.Phase, st.Message)
@@ -327,17 +780,32 @@ func runBuild(cmd *cobra.Command, args []string) {
 							lastMessage = st.Message
 						}
 					}
+					if mirror != nil && st.Phase != "Completed" {
+						reqCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+						if _, err := mirror.sync(reqCtx, st); err != nil {
+							fmt.Printf("artifact sync failed: %v\n", err)
+						}
+						cancel()
+					}
 					if st.Phase == "Completed" {
+						summary.Artifact = st.ArtifactFileName
+						if mirror != nil {
+							if err := mirror.finish(ctx, st); err != nil {
+								handleError(withExitCode(exitDownloadFailed, fmt.Errorf("downloading build files: %w", err)))
+							}
+							return
+						}
 						if download {
-							if err := downloadArtifactViaAPI(ctx, serverURL, resp.Name, outputDir); err != nil {
-								fmt.Printf("Download via API failed: %v\n", err)
+							if err := downloadArtifactViaAPI(ctx, api, resp.Name, outputDir); err != nil {
+								handleError(withExitCode(exitDownloadFailed, fmt.Errorf("download via API failed: %w", err)))
 							}
 							return
 						}
 						return
 					}
 					if st.Phase == "Failed" {
-						handleError(fmt.Errorf("build failed: %s", st.Message))
+						printConsoleLinks(st)
+						handleError(withExitCode(exitBuildFailed, fmt.Errorf("build failed: %s", st.Message)))
 					}
 				}
 			}
@@ -347,13 +815,42 @@ func runBuild(cmd *cobra.Command, args []string) {
 
 }
 
-func validateBuildRequirements() error {
-	if manifest == "" {
-		return fmt.Errorf("--manifest is required")
+// generateBuildName returns the generateName prefix of a build without --name: the manifest's file name,
+// or the repository of the manifest artifact, as a DNS label
+func generateBuildName(manifestPath, ref string) string {
+	base := filepath.Base(manifestPath)
+	for _, ext := range []string{".aib.yml", ".mpp.yml", filepath.Ext(base)} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	if ref != "" {
+		repo, _, _ := strings.Cut(ref, "@")
+		if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+			repo = repo[:i]
+		}
+		base = path.Base(repo)
+	}
+	name := strings.Trim(nonDNSLabelChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if len(name) > maxGeneratedNamePrefix {
+		name = strings.TrimRight(name[:maxGeneratedNamePrefix], "-")
 	}
+	if name == "" {
+		name = "build"
+	}
+	return name + "-"
+}
+
+// maxGeneratedNamePrefix leaves room in generated names for the server's suffix and the suffixes of the
+// resources named after a build
+const maxGeneratedNamePrefix = 40
+
+var nonDNSLabelChars = regexp.MustCompile(`[^a-z0-9-]+`)
 
-	if buildName == "" {
-		return fmt.Errorf("name is required")
+func validateBuildRequirements() error {
+	if manifest == "" && manifestRef == "" {
+		return fmt.Errorf("--manifest or --manifest-ref is required")
+	}
+	if manifestRef != "" && (manifest != "" || len(includeManifests) > 0) {
+		return fmt.Errorf("--manifest and --include cannot be combined with --manifest-ref")
 	}
 
 	if strings.TrimSpace(architecture) == "" {
@@ -363,44 +860,120 @@ func validateBuildRequirements() error {
 	return nil
 }
 
-func handleError(err error) {
-	fmt.Printf("Error: %v\n", err)
-	os.Exit(1)
+// parseUnlessEmpty parses a flag value, leaving an empty one for the server to fill in from the profile
+func parseUnlessEmpty[T ~string](s string, parse func(string) (T, error)) (T, error) {
+	if s == "" {
+		return "", nil
+	}
+	return parse(s)
 }
 
-func findLocalFileReferences(manifestContent string) ([]map[string]string, error) {
-	var manifestData map[string]any
-	var localFiles []map[string]string
+// stepBanner matches the line the build API writes before the logs of each step
+var stepBanner = regexp.MustCompile(`^===== Logs from (.+) =====$`)
 
-	if err := yaml.Unmarshal([]byte(manifestContent), &manifestData); err != nil {
-		return nil, fmt.Errorf("failed to parse manifest YAML: %w", err)
-	}
+// logCursor copies a plain-text log stream to out while tracking the current step and how many
+// bytes of its logs were received, so a dropped stream can be resumed where it stopped
+type logCursor struct {
+	out       io.Writer
+	step      string
+	offset    int64
+	completed bool
+
+	line []byte
+	// inStep is set once the banner of the current connection's first step was seen;
+	// anything before it is server chatter that is not part of the step's logs
+	inStep      bool
+	afterBanner bool
+	resuming    bool
+}
 
-	isPathSafe := func(path string) error {
-		if path == "" || path == "/" {
-			return fmt.Errorf("empty or root path is not allowed")
+func (c *logCursor) Write(p []byte) (int, error) {
+	// the server's heartbeats are NUL bytes, not logs
+	text := bytes.ReplaceAll(p, []byte{0}, nil)
+	n, err := c.out.Write(text)
+	for _, b := range text[:n] {
+		c.line = append(c.line, b)
+		if b == '\n' {
+			c.endLine()
 		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
 
-		if strings.Contains(path, "..") {
-			return fmt.Errorf("directory traversal detected in path: %s", path)
+func (c *logCursor) endLine() {
+	text := strings.TrimSuffix(string(c.line), "\n")
+	size := int64(len(c.line))
+	c.line = c.line[:0]
+
+	if m := stepBanner.FindStringSubmatch(text); m != nil {
+		// a resumed step starts after the bytes already received
+		if !c.resuming || m[1] != c.step {
+			c.offset = 0
+		}
+		c.step = m[1]
+		c.inStep = true
+		c.resuming = false
+		c.afterBanner = true
+		return
+	}
+	if c.afterBanner {
+		c.afterBanner = false
+		if text == "" {
+			return
 		}
+	}
+	if text == "[Log streaming completed]" {
+		c.completed = true
+		return
+	}
+	if c.inStep {
+		c.offset += size
+	}
+}
 
-		if filepath.IsAbs(path) {
-			// TODO add safe dirs flag
-			safeDirectories := []string{}
-			isInSafeDir := false
-			for _, dir := range safeDirectories {
-				if strings.HasPrefix(path, dir+"/") {
-					isInSafeDir = true
-					break
-				}
-			}
-			if !isInSafeDir {
-				return fmt.Errorf("absolute path outside safe directories: %s", path)
-			}
+// resume prepares the cursor for a new connection and returns the options that continue the stream
+func (c *logCursor) resume() buildapiclient.LogOptions {
+	if c.inStep {
+		c.offset += int64(len(c.line))
+	}
+	c.line = c.line[:0]
+	c.inStep = false
+	c.afterBanner = false
+
+	opts := buildapiclient.LogOptions{Follow: true, Heartbeat: true}
+	if c.step != "" {
+		c.resuming = true
+		opts.Step = c.step
+		opts.SinceBytes = c.offset
+	}
+	return opts
+}
+
+// printConsoleLinks points at the web console pages of a build's resources, when the server links them
+func printConsoleLinks(st *buildapitypes.BuildResponse) {
+	for _, link := range []struct{ name, url string }{
+		{"TaskRun", st.TaskRunURL},
+		{"Logs", st.PodLogsURL},
+		{"Workspace", st.PVCURL},
+	} {
+		if link.url != "" {
+			fmt.Printf("%s: %s\n", link.name, link.url)
 		}
+	}
+}
 
-		return nil
+// findLocalFileReferences lists the add_files entries of a manifest that refer to client files. Each
+// reference records the manifest's own spelling ("source"), the local path to read ("source_path")
+// and the POSIX path in the build's shared workspace it is uploaded to ("upload_path").
+func findLocalFileReferences(manifestContent string, style pathStyle, safeDirs []string) ([]map[string]string, error) {
+	var manifestData map[string]any
+	var localFiles []map[string]string
+
+	if err := yaml.Unmarshal([]byte(manifestContent), &manifestData); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest YAML: %w", err)
 	}
 
 	processAddFiles := func(addFiles []any) error {
@@ -408,13 +981,20 @@ func findLocalFileReferences(manifestContent string) ([]map[string]string, error
 			if fileMap, ok := file.(map[string]any); ok {
 				path, hasPath := fileMap["path"].(string)
 				sourcePath, hasSourcePath := fileMap["source_path"].(string)
+				// files of the build's input claim are already in the cluster
+				if hasSourcePath && strings.HasPrefix(sourcePath, buildapitypes.InputMountPath+"/") {
+					continue
+				}
 				if hasPath && hasSourcePath {
-					if err := isPathSafe(sourcePath); err != nil {
+					localPath, uploadPath, err := resolveSourcePath(style, sourcePath, safeDirs)
+					if err != nil {
 						return err
 					}
 					localFiles = append(localFiles, map[string]string{
 						"path":        path,
-						"source_path": sourcePath,
+						"source":      sourcePath,
+						"source_path": localPath,
+						"upload_path": uploadPath,
 					})
 				}
 			}
@@ -443,7 +1023,16 @@ func findLocalFileReferences(manifestContent string) ([]map[string]string, error
 	return localFiles, nil
 }
 
-func downloadArtifactViaAPI(ctx context.Context, baseURL, name, outDir string) error {
+// uploadPaths maps the manifest spelling of each reference to its upload path
+func uploadPaths(refs []map[string]string) map[string]string {
+	m := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		m[ref["source"]] = ref["upload_path"]
+	}
+	return m
+}
+
+func downloadArtifactViaAPI(ctx context.Context, api *buildapiclient.Client, name, outDir string) error {
 	if strings.TrimSpace(outDir) == "" {
 		outDir = "./output"
 	}
@@ -451,136 +1040,145 @@ func downloadArtifactViaAPI(ctx context.Context, baseURL, name, outDir string) e
 		return fmt.Errorf("create output dir: %w", err)
 	}
 
-	base := strings.TrimRight(baseURL, "/")
-	urlStr := base + "/v1/builds/" + url.PathEscape(name) + "/artifact"
-
-	deadline := time.Now().Add(30 * time.Minute)
-
-	httpClient := &http.Client{
-		Timeout: 30 * time.Minute,
-		Transport: &http.Transport{
-			ResponseHeaderTimeout: 2 * time.Minute,
-			IdleConnTimeout:       5 * time.Minute,
-		},
-	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
 
 	warned := false
 	for {
-		if ctx.Err() != nil || time.Now().After(deadline) {
-			return fmt.Errorf("timed out waiting for artifact to become ready")
-		}
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
-		if strings.TrimSpace(authToken) != "" {
-			req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(authToken))
-		}
-		resp, err := httpClient.Do(req)
+		tmp, err := os.CreateTemp(outDir, "."+name+"-*.partial")
 		if err != nil {
-			time.Sleep(3 * time.Second)
-			continue
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			filename := name + ".artifact"
-			contentType := resp.Header.Get("Content-Type")
-			if cd := resp.Header.Get("Content-Disposition"); cd != "" {
-				if i := strings.Index(cd, "filename="); i >= 0 {
-					f := strings.Trim(cd[i+9:], "\" ")
-					if f != "" {
-						filename = f
-					}
+			return err
+		}
+		var bar *progressbar.ProgressBar
+		progress := func(written, total int64) {
+			if bar == nil {
+				if total >= 0 {
+					bar = newProgressBar(total, "Downloading",
+						progressbar.OptionShowBytes(true),
+						progressbar.OptionSetWidth(15),
+						progressbar.OptionThrottle(65*time.Millisecond),
+						progressbar.OptionShowCount(),
+					)
+				} else {
+					bar = newProgressBar(-1, "Downloading", progressbar.OptionSpinnerType(14))
 				}
 			}
-			if at := strings.TrimSpace(resp.Header.Get("X-AIB-Artifact-Type")); at != "" {
-				fmt.Printf("Artifact type: %s\n", at)
-			}
-			if comp := strings.TrimSpace(resp.Header.Get("X-AIB-Compression")); comp != "" {
-				fmt.Printf("Compression: %s\n", comp)
-			}
-			if root := strings.TrimSpace(resp.Header.Get("X-AIB-Archive-Root")); root != "" {
-				fmt.Printf("Archive root: %s\n", root)
+			_ = bar.Set64(written)
+		}
+
+		var d *buildapiclient.Download
+		switch {
+		case downloadScanReport:
+			d, err = api.DownloadScanReport(ctx, name, tmp, progress)
+		case downloadWorkspace:
+			d, err = api.DownloadWorkspace(ctx, name, tmp, progress)
+		case downloadAll:
+			d, err = api.DownloadArtifactsTar(ctx, name, tmp, progress)
+		default:
+			d, err = api.DownloadArtifact(ctx, name, "", tmp, progress)
+		}
+		tmp.Close()
+		if bar != nil {
+			_ = bar.Finish()
+			if !noProgress {
+				fmt.Println()
 			}
-			outPath := filepath.Join(outDir, filename)
-			tmp := outPath + ".partial"
-			f, err := os.Create(tmp)
-			if err != nil {
-				resp.Body.Close()
-				return err
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			if ctx.Err() != nil {
+				return fmt.Errorf("timed out waiting for artifact to become ready")
 			}
-			if cl := strings.TrimSpace(resp.Header.Get("Content-Length")); cl != "" {
-				// Known size: nice progress bar
-				// Convert to int64
-				var total int64
-				fmt.Sscan(cl, &total)
-				bar := progressbar.NewOptions64(
-					total,
-					progressbar.OptionSetDescription("Downloading"),
-					progressbar.OptionShowBytes(true),
-					progressbar.OptionSetWidth(15),
-					progressbar.OptionThrottle(65*time.Millisecond),
-					progressbar.OptionShowCount(),
-					progressbar.OptionClearOnFinish(),
-				)
-				reader := io.TeeReader(resp.Body, bar)
-				if _, copyErr := io.Copy(f, reader); copyErr != nil {
-					f.Close()
-					os.Remove(tmp)
-					return copyErr
-				}
-				_ = bar.Finish()
-				fmt.Println()
-			} else {
-				bar := progressbar.NewOptions(
-					-1,
-					progressbar.OptionSetDescription("Downloading"),
-					progressbar.OptionSpinnerType(14),
-					progressbar.OptionClearOnFinish(),
-				)
-				reader := io.TeeReader(resp.Body, bar)
-				if _, copyErr := io.Copy(f, reader); copyErr != nil {
-					f.Close()
-					os.Remove(tmp)
-					return copyErr
+			code := buildapiclient.StatusCode(err)
+			// a kept workspace never becomes available by waiting, unlike an artifact of a running build
+			if code == 0 || code == http.StatusServiceUnavailable || (code == http.StatusConflict && !downloadWorkspace) || strings.Contains(strings.ToLower(err.Error()), "not ready") {
+				if !warned && code != 0 {
+					fmt.Println("Artifact not ready yet. Waiting...")
+					warned = true
 				}
-				_ = bar.Finish()
-				fmt.Println()
+				time.Sleep(3 * time.Second)
+				continue
 			}
-			resp.Body.Close()
-			f.Close()
-			if err := os.Rename(tmp, outPath); err != nil {
+			return err
+		}
+
+		if d.ArtifactType != "" {
+			fmt.Printf("Artifact type: %s\n", d.ArtifactType)
+		}
+		if d.Compression != "" {
+			fmt.Printf("Compression: %s\n", d.Compression)
+		}
+		if d.ArchiveRoot != "" {
+			fmt.Printf("Archive root: %s\n", d.ArchiveRoot)
+		}
+		if d.PayloadType != "" {
+			fmt.Printf("Content type: %s\n", d.PayloadType)
+		}
+		outPath := filepath.Join(outDir, artifacttype.FileName(d.FileName, d.PayloadType))
+		if err := os.Rename(tmp.Name(), outPath); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		fmt.Printf("Artifact downloaded to %s\n", outPath)
+		if strings.HasSuffix(outPath, encryptedSuffix) {
+			if keyFile == "" {
+				fmt.Println("The artifact is encrypted; decrypt it with --key-file")
+				return nil
+			}
+			if outPath, err = decryptArtifact(outPath, keyFile); err != nil {
 				return err
 			}
-			fmt.Printf("Artifact downloaded to %s\n", outPath)
+		}
 
-			// If the artifact is a tar archive (directory export), optionally extract it
-			if strings.HasPrefix(contentType, "application/x-tar") || strings.HasPrefix(contentType, "application/gzip") || strings.HasSuffix(strings.ToLower(outPath), ".tar") || strings.HasSuffix(strings.ToLower(outPath), ".tar.gz") {
-				if !compressArtifacts {
-					destDir := strings.TrimSuffix(outPath, ".tar")
-					destDir = strings.TrimSuffix(destDir, ".gz")
-					if err := os.MkdirAll(destDir, 0o755); err != nil {
-						return fmt.Errorf("create extract dir: %w", err)
-					}
-					if err := extractTar(outPath, destDir); err != nil {
-						return fmt.Errorf("extract tar: %w", err)
-					}
-					fmt.Printf("Extracted to %s\n", destDir)
+		// If the artifact is a tar archive (directory export), optionally extract it
+		if d.PayloadType == artifacttype.Tar || strings.HasPrefix(d.ContentType, "application/x-tar") || strings.HasPrefix(d.ContentType, "application/gzip") || strings.HasSuffix(strings.ToLower(outPath), ".tar") || strings.HasSuffix(strings.ToLower(outPath), ".tar.gz") {
+			if !compressArtifacts {
+				destDir := strings.TrimSuffix(outPath, ".tar")
+				destDir = strings.TrimSuffix(destDir, ".gz")
+				if err := os.MkdirAll(destDir, 0o755); err != nil {
+					return fmt.Errorf("create extract dir: %w", err)
+				}
+				if err := extractTar(outPath, destDir); err != nil {
+					return fmt.Errorf("extract tar: %w", err)
 				}
+				fmt.Printf("Extracted to %s\n", destDir)
 			}
-			return nil
 		}
+		return nil
+	}
+}
 
-		body, _ := io.ReadAll(resp.Body)
-		msg := strings.ToLower(strings.TrimSpace(string(body)))
-		resp.Body.Close()
-		if resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusConflict || strings.Contains(msg, "not ready") {
-			if !warned {
-				fmt.Println("Artifact not ready yet. Waiting...")
-				warned = true
-			}
-			time.Sleep(3 * time.Second)
-			continue
+// localArtifact returns the path of a completed build's artifact in outDir, reusing a complete earlier
+// download and downloading it otherwise
+func localArtifact(ctx context.Context, api *buildapiclient.Client, name, outDir string) (*buildapitypes.BuildResponse, string, error) {
+	st, err := api.GetBuild(ctx, name)
+	if err != nil {
+		return nil, "", fmt.Errorf("getting build %s: %w", name, err)
+	}
+	if st.Phase != "Completed" {
+		return nil, "", fmt.Errorf("build %s is not completed (status: %s)", name, st.Phase)
+	}
+	if st.ArtifactFileName == "" {
+		return nil, "", fmt.Errorf("build %s did not record its artifact", name)
+	}
+	if st.Encrypted && keyFile == "" {
+		return nil, "", fmt.Errorf("the artifact of build %s is encrypted; pass --key-file", name)
+	}
+	artifactPath := filepath.Join(outDir, artifacttype.FileName(st.ArtifactFileName, st.ArtifactContentType))
+	if fi, err := os.Stat(artifactPath); err == nil && (st.ArtifactSize <= 0 || fi.Size() == st.ArtifactSize) {
+		fmt.Printf("Reusing %s\n", artifactPath)
+		path, err := decryptArtifact(artifactPath, keyFile)
+		if err != nil {
+			return nil, "", err
 		}
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return st, path, nil
+	}
+	// keep directory exports as archives; callers reject them
+	compressArtifacts = true
+	if err := downloadArtifactViaAPI(ctx, api, name, outDir); err != nil {
+		return nil, "", fmt.Errorf("download failed: %w", err)
 	}
+	return st, strings.TrimSuffix(artifactPath, encryptedSuffix), nil
 }
 
 func extractTar(tarPath, destDir string) error {
@@ -644,7 +1242,7 @@ func runDownload(cmd *cobra.Command, args []string) {
 
 	if strings.TrimSpace(serverURL) == "" {
 		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
-		os.Exit(1)
+		os.Exit(exitInvalidArgs)
 	}
 
 	if strings.TrimSpace(authToken) == "" {
@@ -656,10 +1254,11 @@ func runDownload(cmd *cobra.Command, args []string) {
 	if strings.TrimSpace(authToken) != "" {
 		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
 	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
 	api, err := buildapiclient.New(serverURL, opts...)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitInvalidArgs)
 	}
 
 	st, err := api.GetBuild(ctx, buildName)
@@ -672,13 +1271,275 @@ func runDownload(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if err := downloadArtifactViaAPI(ctx, serverURL, buildName, outputDir); err != nil {
+	if err := downloadArtifactViaAPI(ctx, api, buildName, outputDir); err != nil {
 		fmt.Printf("Download failed: %v\n", err)
+		os.Exit(exitDownloadFailed)
+	}
+}
+
+// parseLabels turns repeated KEY=VALUE flags into a label map
+func parseLabels(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(values))
+	for _, l := range values {
+		k, v, err := userlabels.Parse(l)
+		if err != nil {
+			return nil, err
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+// manifestGitRef returns the commit checked out in the git repository holding the manifest, marked
+// "-dirty" when the manifest has uncommitted changes, or "" when it is not in a git repository
+func manifestGitRef(manifest string) string {
+	dir, file := filepath.Split(manifest)
+	if dir == "" {
+		dir = "."
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	ref := strings.TrimSpace(string(out))
+	if err := exec.Command("git", "-C", dir, "diff", "--quiet", "HEAD", "--", file).Run(); err != nil {
+		ref += "-dirty"
+	}
+	return ref
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	st, err := api.BuildStats(ctx, statsWindow)
+	if err != nil {
+		fmt.Printf("Error getting build statistics: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Builds since %s: %d\n", st.Since, st.Total)
+	if st.Total == 0 {
+		return
+	}
+	phases := make([]string, 0, len(st.ByPhase))
+	for phase, n := range st.ByPhase {
+		phases = append(phases, fmt.Sprintf("%s=%d", phase, n))
+	}
+	sort.Strings(phases)
+	fmt.Printf("Phases:       %s\n", strings.Join(phases, " "))
+	fmt.Printf("Success rate: %.1f%%\n", st.SuccessRate*100)
+	if d := st.Durations; d.Count > 0 {
+		fmt.Printf("Duration:     avg %s, p50 %s, p90 %s, p99 %s (%d finished builds)\n",
+			seconds(d.Average), seconds(d.P50), seconds(d.P90), seconds(d.P99), d.Count)
+	}
+
+	printGroupStats("DISTRO", st.ByDistro)
+	printGroupStats("TARGET", st.ByTarget)
+	if err := printCostStats(os.Stdout, st.Costs); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printGroupStats prints a per-distro or per-target breakdown as a table sorted by key
+func printGroupStats(title string, groups map[string]buildapitypes.BuildGroupStats) {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("\n%-20s %-8s %-10s %-8s %-8s %-12s\n", title, "TOTAL", "COMPLETED", "FAILED", "SUCCESS", "AVG DURATION")
+	for _, k := range keys {
+		g := groups[k]
+		fmt.Printf("%-20s %-8d %-10d %-8d %-8s %-12s\n", k, g.Total, g.Completed, g.Failed,
+			fmt.Sprintf("%.1f%%", g.SuccessRate*100), seconds(g.AverageDuration))
+	}
+}
+
+// seconds formats a duration in seconds for display, rounded to the second
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+}
+
+func runCancel(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := api.CancelBuild(ctx, buildName); err != nil {
+		fmt.Printf("Error cancelling build %s: %v\n", buildName, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Cancellation of build %s requested\n", buildName)
+}
+
+func runPurge(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, err := api.DeleteBuild(ctx, name, purgeForce); err != nil {
+		if buildapiclient.StatusCode(err) == http.StatusConflict {
+			fmt.Printf("Error: build %s has not finished; cancel it first or pass --force\n", name)
+		} else {
+			fmt.Printf("Error deleting build %s: %v\n", name, err)
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("Build %s deleted\n", name)
+}
+
+func runPromote(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pushing artifact of build %s to %s...\n", name, promoteRegistry)
+	resp, err := api.PromoteBuild(ctx, name, buildapitypes.PromoteRequest{
+		TargetNamespace: promoteNamespace,
+		ImageName:       imageName,
+		Registry: buildapitypes.PromoteRegistry{
+			URL:       promoteRegistry,
+			SecretRef: promoteSecret,
+			Insecure:  promoteInsecure,
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error promoting build %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Image %s created in namespace %s\n", resp.Image, resp.Namespace)
+	fmt.Printf("  %s@%s\n", resp.URL, resp.Digest)
+}
+
+func runConvert(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	req := buildapitypes.ConvertRequest{Format: convertFormat}
+	conversion, err := api.ConvertArtifact(ctx, name, req)
+	if err != nil {
+		fmt.Printf("Error converting artifact of build %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Conversion of build %s to %s: %s\n", name, conversion.Format, conversion.Phase)
+	// requesting the conversion again returns its progress
+	for convertWait && (conversion.Phase == "Pending" || conversion.Phase == "Running") {
+		time.Sleep(5 * time.Second)
+		phase := conversion.Phase
+		conversion, err = api.ConvertArtifact(ctx, name, req)
+		if err != nil {
+			fmt.Printf("Error checking conversion of build %s: %v\n", name, err)
+			os.Exit(1)
+		}
+		if conversion.Phase != phase {
+			fmt.Printf("Conversion of build %s to %s: %s\n", name, conversion.Format, conversion.Phase)
+		}
+	}
+	switch conversion.Phase {
+	case "Completed":
+		fmt.Printf("  %s (%d bytes, sha256 %s)\n", conversion.FileName, conversion.Size, conversion.SHA256)
+	case "Failed":
+		fmt.Printf("  %s\n", conversion.Message)
 		os.Exit(1)
 	}
 }
 
-func runList(cmd *cobra.Command, args []string) {
+func runShow(cmd *cobra.Command, args []string) {
 	ctx := context.Background()
 	if strings.TrimSpace(serverURL) == "" {
 		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
@@ -693,24 +1554,197 @@ func runList(cmd *cobra.Command, args []string) {
 	if strings.TrimSpace(authToken) != "" {
 		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
 	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
 	api, err := buildapiclient.New(serverURL, opts...)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	items, err := api.ListBuilds(ctx)
+
+	st, err := api.GetBuild(ctx, buildName)
 	if err != nil {
-		fmt.Printf("Error listing ImageBuilds: %v\n", err)
+		fmt.Printf("Error getting build %s: %v\n", buildName, err)
 		os.Exit(1)
 	}
-	if len(items) == 0 {
-		fmt.Println("No ImageBuilds found")
+	fmt.Printf("Name:         %s\n", st.Name)
+	fmt.Printf("Phase:        %s\n", st.Phase)
+	fmt.Printf("Message:      %s\n", st.Message)
+	fmt.Printf("Requested by: %s\n", st.RequestedBy)
+	if st.RequestID != "" {
+		fmt.Printf("Request ID:   %s\n", st.RequestID)
+	}
+	if st.Profile != "" {
+		fmt.Printf("Profile:      %s\n", st.Profile)
+	}
+	fmt.Printf("Started:      %s\n", st.StartTime)
+	fmt.Printf("Completed:    %s\n", st.CompletionTime)
+	if st.ArtifactFileName != "" {
+		fmt.Printf("Artifact:     %s\n", st.ArtifactFileName)
+	}
+	if st.ArtifactSHA256 != "" {
+		fmt.Printf("SHA256:       %s\n", st.ArtifactSHA256)
+	}
+	if st.BuilderImageDigest != "" {
+		fmt.Printf("Builder:      %s\n", st.BuilderImageDigest)
+	}
+	if st.OSTreeCommit != "" {
+		fmt.Printf("OSTree:       %s\n", st.OSTreeCommit)
+	}
+	if st.Scan != nil {
+		fmt.Printf("Scan:         %d critical, %d high, %d medium, %d low", st.Scan.Critical, st.Scan.High, st.Scan.Medium, st.Scan.Low)
+		if st.Scan.Blocked {
+			fmt.Print(" (artifact blocked)")
+		}
+		fmt.Println(" (caib download --scan-report)")
+	}
+	if st.Cost != nil {
+		fmt.Printf("Cost:         %s\n", formatCost(st.Cost))
+	}
+	if st.WorkspaceSize != "" {
+		fmt.Printf("Workspace:    %s\n", formatWorkspace(st))
+	}
+	if st.WorkspaceExpiryTime != "" {
+		fmt.Printf("Workspace:    kept until %s (caib download --workspace)\n", st.WorkspaceExpiryTime)
+	}
+
+	if !showDebug {
+		return
+	}
+
+	tr, err := api.GetTaskRun(ctx, buildName)
+	if err != nil {
+		fmt.Printf("\nTaskRun: unavailable (%v)\n", err)
 		return
 	}
-	fmt.Printf("%-20s %-12s %-20s %-20s %-20s\n", "NAME", "STATUS", "MESSAGE", "CREATED", "ARTIFACT")
-	for _, it := range items {
-		fmt.Printf("%-20s %-12s %-20s %-20s %-20s\n", it.Name, it.Phase, it.Message, it.CreatedAt, "")
+	fmt.Printf("\nTaskRun:      %s\n", tr.Name)
+	fmt.Printf("Pod:          %s\n", tr.PodName)
+	fmt.Printf("Status:       %s %s\n", tr.Status, tr.Reason)
+	if tr.Message != "" {
+		fmt.Printf("Message:      %s\n", tr.Message)
+	}
+	fmt.Printf("Started:      %s\n", tr.StartTime)
+	fmt.Printf("Completed:    %s\n", tr.CompletionTime)
+	if len(tr.Steps) == 0 {
+		return
+	}
+	fmt.Printf("\n%-24s %-12s %-20s %-6s %-20s %-20s\n", "STEP", "STATE", "REASON", "EXIT", "STARTED", "FINISHED")
+	for _, step := range tr.Steps {
+		exit := ""
+		if step.ExitCode != nil {
+			exit = fmt.Sprintf("%d", *step.ExitCode)
+		}
+		fmt.Printf("%-24s %-12s %-20s %-6s %-20s %-20s\n", step.Name, step.State, step.Reason, exit, step.StartedAt, step.FinishedAt)
+		if step.Message != "" {
+			fmt.Printf("  %s\n", step.Message)
+		}
+	}
+}
+
+func runImageLifecycle(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Println("Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts), rateLimitOption())
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp, err := api.SetImageLifecycle(ctx, imageName, buildapitypes.ImageLifecycleRequest{
+		State:  lifecycleState,
+		Reason: lifecycleReason,
+	})
+	if err != nil {
+		fmt.Printf("Error changing lifecycle of image %s: %v\n", imageName, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Image %s: %s -> %s (by %s at %s)\n", resp.Name, resp.Previous, resp.State, resp.ChangedBy, resp.ChangedAt)
+}
+
+// resolveNamespace picks the namespace commands act on: --namespace, then $CAIB_NAMESPACE, then the namespace
+// of the current kubeconfig context. It returns "" and no source when the server's default should be used.
+func resolveNamespace() (string, string) {
+	if ns := strings.TrimSpace(namespace); ns != "" {
+		return ns, "--namespace"
+	}
+	if ns := strings.TrimSpace(os.Getenv("CAIB_NAMESPACE")); ns != "" {
+		return ns, "CAIB_NAMESPACE"
+	}
+	if rawCfg, err := clientcmd.NewDefaultClientConfigLoadingRules().Load(); err == nil && rawCfg != nil {
+		if kctx := rawCfg.Contexts[rawCfg.CurrentContext]; kctx != nil && strings.TrimSpace(kctx.Namespace) != "" {
+			return strings.TrimSpace(kctx.Namespace), "kubeconfig context " + rawCfg.CurrentContext
+		}
+	}
+	return "", ""
+}
+
+// catalogCompletion completes a build flag from the server's catalog. Completion must stay quiet, so an
+// unset or unreachable server simply offers no values.
+func catalogCompletion(values func(*buildapitypes.CatalogResponse) []string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if strings.TrimSpace(serverURL) == "" {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		token := strings.TrimSpace(authToken)
+		if token == "" {
+			if tok, err := loadTokenFromKubeconfig(); err == nil {
+				token = strings.TrimSpace(tok)
+			}
+		}
+		var opts []buildapiclient.Option
+		if token != "" {
+			opts = append(opts, buildapiclient.WithAuthToken(token))
+		}
+		api, err := buildapiclient.New(serverURL, opts...)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		catalog, err := api.Catalog(ctx)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var out []string
+		for _, v := range values(catalog) {
+			if strings.HasPrefix(v, toComplete) {
+				out = append(out, v)
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// namespaceOption resolves the namespace for a command and returns the client option selecting it.
+// With --verbose the choice is printed, asking the server for its default if nothing else applies.
+func namespaceOption(ctx context.Context, opts []buildapiclient.Option) buildapiclient.Option {
+	ns, source := resolveNamespace()
+	resolvedNamespace = ns
+	if verbose {
+		shown := ns
+		if ns == "" {
+			source, shown = "server default", "<unknown>"
+			if api, err := buildapiclient.New(serverURL, opts...); err == nil {
+				if info, err := api.ServerInfo(ctx); err == nil {
+					shown = info.DefaultNamespace
+				}
+			}
+		}
+		fmt.Printf("Using namespace %s (from %s)\n", shown, source)
 	}
+	return buildapiclient.WithNamespace(ns)
 }
 
 func loadTokenFromKubeconfig() (string, error) {
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pathStyle describes how a client operating system spells local file paths
+type pathStyle struct {
+	// windows paths use backslash separators and may start with a drive letter or a UNC host
+	windows bool
+	// caseInsensitive file systems match safe directories regardless of case
+	caseInsensitive bool
+}
+
+func pathStyleFor(goos string) pathStyle {
+	switch goos {
+	case "windows":
+		return pathStyle{windows: true, caseInsensitive: true}
+	case "darwin":
+		return pathStyle{caseInsensitive: true}
+	default:
+		return pathStyle{}
+	}
+}
+
+// localPath renders a slash-separated path in the client's native form
+func (s pathStyle) localPath(p string) string {
+	if s.windows {
+		return strings.ReplaceAll(p, "/", `\`)
+	}
+	return p
+}
+
+// splitVolume separates a Windows drive ("C:") or UNC prefix ("//host/share") from a slash-separated path
+func splitVolume(p string) (string, string) {
+	if len(p) >= 2 && p[1] == ':' && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z') {
+		return strings.ToUpper(p[:2]), p[2:]
+	}
+	if strings.HasPrefix(p, "//") {
+		parts := strings.SplitN(p[2:], "/", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return p, ""
+		}
+		vol := "//" + parts[0] + "/" + parts[1]
+		return vol, strings.TrimPrefix(p, vol)
+	}
+	return "", p
+}
+
+// resolveSourcePath checks a manifest source_path and translates it for the client and the build.
+// Backslashes are read as separators on every OS so manifests written on Windows work elsewhere.
+// It returns the path to read locally, in the client's native form, and the relative POSIX path
+// the file is uploaded to in the build's shared workspace.
+func resolveSourcePath(style pathStyle, sourcePath string, safeDirs []string) (string, string, error) {
+	p := strings.ReplaceAll(strings.TrimSpace(sourcePath), `\`, "/")
+	if p == "" || p == "/" {
+		return "", "", fmt.Errorf("empty or root path is not allowed")
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return "", "", fmt.Errorf("directory traversal detected in path: %s", sourcePath)
+		}
+	}
+
+	vol, rest := splitVolume(p)
+	if vol != "" {
+		if !style.windows {
+			return "", "", fmt.Errorf("windows path %s cannot be read on this system", sourcePath)
+		}
+		if !strings.HasPrefix(rest, "/") {
+			return "", "", fmt.Errorf("drive-relative path %s is not allowed", sourcePath)
+		}
+	}
+	clean := vol + path.Clean(rest)
+
+	if vol == "" && !strings.HasPrefix(clean, "/") {
+		return style.localPath(clean), clean, nil
+	}
+
+	for _, dir := range safeDirs {
+		if style.within(clean, dir) {
+			dest := strings.TrimPrefix(strings.TrimPrefix(clean, vol), "/")
+			if strings.HasPrefix(vol, "//") {
+				dest = strings.TrimPrefix(vol, "//") + "/" + dest
+			}
+			return style.localPath(clean), dest, nil
+		}
+	}
+	return "", "", fmt.Errorf("absolute path outside safe directories: %s", sourcePath)
+}
+
+// within reports whether the cleaned absolute path p lies below dir
+func (s pathStyle) within(p, dir string) bool {
+	dir = strings.ReplaceAll(strings.TrimSpace(dir), `\`, "/")
+	vol, rest := splitVolume(dir)
+	if vol != "" && !s.windows {
+		return false
+	}
+	dir = strings.TrimSuffix(vol+path.Clean("/"+rest), "/")
+	if dir == "" || dir == vol {
+		// a whole file system is never a safe directory
+		return false
+	}
+	if s.caseInsensitive {
+		p, dir = strings.ToLower(p), strings.ToLower(dir)
+	}
+	return strings.HasPrefix(p, dir+"/")
+}
+
+// rewriteSourcePaths replaces add_files source_path values with the upload paths in dests. The
+// manifest is returned unchanged when no value needs rewriting so its formatting and comments survive.
+func rewriteSourcePaths(manifestContent string, dests map[string]string) (string, error) {
+	changed := false
+	for src, dest := range dests {
+		if src != dest {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return manifestContent, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(manifestContent), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse manifest YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return manifestContent, nil
+	}
+	root := doc.Content[0]
+	for _, addFiles := range []*yaml.Node{
+		mappingValue(mappingValue(root, "content"), "add_files"),
+		mappingValue(mappingValue(mappingValue(root, "qm"), "content"), "add_files"),
+	} {
+		if addFiles == nil || addFiles.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, file := range addFiles.Content {
+			if src := mappingValue(file, "source_path"); src != nil {
+				if dest, ok := dests[src.Value]; ok {
+					src.Value = dest
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// mappingValue returns the value stored under key in a YAML mapping node, or nil
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
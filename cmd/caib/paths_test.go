@@ -0,0 +1,82 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Local file references", func() {
+	var (
+		linux   = pathStyleFor("linux")
+		darwin  = pathStyleFor("darwin")
+		windows = pathStyleFor("windows")
+	)
+
+	DescribeTable("resolving source paths",
+		func(style pathStyle, sourcePath string, safeDirs []string, local, upload string) {
+			l, u, err := resolveSourcePath(style, sourcePath, safeDirs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(l).To(Equal(local))
+			Expect(u).To(Equal(upload))
+		},
+		Entry("relative POSIX path", linux, "files/radio.conf", nil, "files/radio.conf", "files/radio.conf"),
+		Entry("redundant segments", linux, "./files//radio.conf", nil, "files/radio.conf", "files/radio.conf"),
+		Entry("backslashes on linux", linux, `files\radio.conf`, nil, "files/radio.conf", "files/radio.conf"),
+		Entry("backslashes on windows", windows, `files\radio.conf`, nil, `files\radio.conf`, "files/radio.conf"),
+		Entry("forward slashes on windows", windows, "files/radio.conf", nil, `files\radio.conf`, "files/radio.conf"),
+		Entry("absolute path in a safe dir", linux, "/srv/data/radio.conf", []string{"/srv/data/"}, "/srv/data/radio.conf", "srv/data/radio.conf"),
+		Entry("case-insensitive safe dir on macOS", darwin, "/Users/Me/data/radio.conf", []string{"/users/me/data"}, "/Users/Me/data/radio.conf", "Users/Me/data/radio.conf"),
+		Entry("drive letter in a safe dir", windows, `c:\Data\radio.conf`, []string{`C:\data`}, `C:\Data\radio.conf`, "Data/radio.conf"),
+		Entry("UNC path in a safe dir", windows, `\\host\share\data\radio.conf`, []string{`\\host\share\data\`}, `\\host\share\data\radio.conf`, "host/share/data/radio.conf"),
+		Entry("dots inside a file name", linux, "files/radio..conf", nil, "files/radio..conf", "files/radio..conf"),
+	)
+
+	DescribeTable("rejecting unsafe source paths",
+		func(style pathStyle, sourcePath string, safeDirs []string, msg string) {
+			_, _, err := resolveSourcePath(style, sourcePath, safeDirs)
+			Expect(err).To(MatchError(ContainSubstring(msg)))
+		},
+		Entry("empty", linux, " ", nil, "empty or root"),
+		Entry("traversal", linux, "files/../../etc/passwd", nil, "directory traversal"),
+		Entry("traversal with backslashes", windows, `files\..\..\secret`, nil, "directory traversal"),
+		Entry("absolute without safe dirs", linux, "/etc/passwd", nil, "outside safe directories"),
+		Entry("absolute next to a safe dir", linux, "/srv/database/x", []string{"/srv/data"}, "outside safe directories"),
+		Entry("case mismatch on linux", linux, "/Srv/data/x", []string{"/srv/data"}, "outside safe directories"),
+		Entry("drive letter on linux", linux, `C:\data\x`, []string{"/data"}, "cannot be read on this system"),
+		Entry("drive letter on windows", windows, `D:\data\x`, []string{`C:\data`}, "outside safe directories"),
+		Entry("drive-relative path", windows, "C:data", nil, "drive-relative"),
+		Entry("whole drive as safe dir", windows, `C:\x`, []string{`C:\`}, "outside safe directories"),
+	)
+
+	It("should rewrite manifests to use upload paths", func() {
+		manifest := "name: radio\ncontent:\n  add_files:\n    - path: /etc/radio.conf\n      source_path: files\\radio.conf\nqm:\n  content:\n    add_files:\n      - path: /etc/qm.conf\n        source_path: qm.conf\n"
+		refs, err := findLocalFileReferences(manifest, windows, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refs).To(HaveLen(2))
+		Expect(refs[0]).To(HaveKeyWithValue("source_path", `files\radio.conf`))
+		Expect(refs[0]).To(HaveKeyWithValue("upload_path", "files/radio.conf"))
+
+		out, err := rewriteSourcePaths(manifest, uploadPaths(refs))
+		Expect(err).NotTo(HaveOccurred())
+		var m map[string]any
+		Expect(yaml.Unmarshal([]byte(out), &m)).To(Succeed())
+		files := m["content"].(map[string]any)["add_files"].([]any)
+		Expect(files[0].(map[string]any)["source_path"]).To(Equal("files/radio.conf"))
+
+		unchanged := "# keep me\ncontent:\n  add_files:\n    - path: /etc/qm.conf\n      source_path: qm.conf\n"
+		refs, err = findLocalFileReferences(unchanged, linux, nil)
+		Expect(err).NotTo(HaveOccurred())
+		out, err = rewriteSourcePaths(unchanged, uploadPaths(refs))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(unchanged))
+	})
+
+	It("should leave files of the input claim to the build", func() {
+		manifest := "content:\n  add_files:\n    - path: /opt/vendor/blob.bin\n      source_path: /workspace/input/vendor/blob.bin\n    - path: /etc/radio.conf\n      source_path: radio.conf\n"
+		refs, err := findLocalFileReferences(manifest, linux, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(refs).To(HaveLen(1))
+		Expect(refs[0]).To(HaveKeyWithValue("source", "radio.conf"))
+	})
+})
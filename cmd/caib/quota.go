@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+func runQuota(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	if strings.TrimSpace(serverURL) == "" {
+		fmt.Fprintln(os.Stderr, "Error: --server is required (or set CAIB_SERVER)")
+		os.Exit(1)
+	}
+	if strings.TrimSpace(authToken) == "" {
+		if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+			authToken = tok
+		}
+	}
+	var opts []buildapiclient.Option
+	if strings.TrimSpace(authToken) != "" {
+		opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+	}
+	opts = append(opts, namespaceOption(ctx, opts))
+	api, err := buildapiclient.New(serverURL, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	q, err := api.StorageQuota(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting storage quota: %v\n", err)
+		os.Exit(1)
+	}
+	if err := printQuota(os.Stdout, q); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printQuota prints the workspace storage of a namespace against its limit, then its breakdown by requester
+// with the largest users first
+func printQuota(w io.Writer, q *buildapitypes.StorageQuotaResponse) error {
+	limit := "unlimited"
+	if q.LimitBytes > 0 {
+		limit = fmt.Sprintf("%s (%.1f%% used)", formatSize(q.LimitBytes), float64(q.UsedBytes)/float64(q.LimitBytes)*100)
+	}
+	fmt.Fprintf(w, "Namespace:  %s\n", q.Namespace)
+	fmt.Fprintf(w, "Workspaces: %d using %s\n", q.Workspaces, byteCount(q.UsedBytes))
+	fmt.Fprintf(w, "Limit:      %s\n", limit)
+	if len(q.ByRequester) == 0 {
+		return nil
+	}
+
+	requesters := make([]string, 0, len(q.ByRequester))
+	for r := range q.ByRequester {
+		requesters = append(requesters, r)
+	}
+	sort.Slice(requesters, func(i, j int) bool {
+		a, b := q.ByRequester[requesters[i]], q.ByRequester[requesters[j]]
+		if a.UsedBytes != b.UsedBytes {
+			return a.UsedBytes > b.UsedBytes
+		}
+		return requesters[i] < requesters[j]
+	})
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REQUESTER\tWORKSPACES\tUSED")
+	for _, r := range requesters {
+		u := q.ByRequester[r]
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", r, u.Workspaces, byteCount(u.UsedBytes))
+	}
+	return tw.Flush()
+}
+
+// byteCount is formatSize for amounts that may be zero
+func byteCount(n int64) string {
+	if n == 0 {
+		return "0 B"
+	}
+	return formatSize(n)
+}
+
+// formatWorkspace renders the workspace PVC size of a build with what it was estimated from and, once the build
+// finished, the most it held, e.g. "24Gi (estimated from 120 packages), peak 11.2 GiB"
+func formatWorkspace(st *buildapitypes.BuildResponse) string {
+	s := st.WorkspaceSize
+	if st.WorkspaceSizeEstimate != "" {
+		s += " (estimated from " + st.WorkspaceSizeEstimate + ")"
+	}
+	if st.WorkspacePeakBytes > 0 {
+		s += ", peak " + formatSize(st.WorkspacePeakBytes)
+	}
+	return s
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Printing the storage quota", func() {
+	It("should show usage against the limit and the largest requesters first", func() {
+		var out bytes.Buffer
+		Expect(printQuota(&out, &buildapitypes.StorageQuotaResponse{
+			Namespace:  "team-a",
+			UsedBytes:  24 << 30,
+			Workspaces: 3,
+			LimitBytes: 96 << 30,
+			ByRequester: map[string]buildapitypes.StorageUsage{
+				"alice": {UsedBytes: 8 << 30, Workspaces: 1},
+				"bob":   {UsedBytes: 16 << 30, Workspaces: 2},
+			},
+		})).To(Succeed())
+
+		Expect(out.String()).To(ContainSubstring("Workspaces: 3 using 24.0 GiB\n"))
+		Expect(out.String()).To(ContainSubstring("Limit:      96.0 GiB (25.0% used)\n"))
+		Expect(out.String()).To(MatchRegexp(`(?s)bob\s+2\s+16.0 GiB\s+alice\s+1\s+8.0 GiB`))
+	})
+
+	It("should report an unlimited namespace without workspaces", func() {
+		var out bytes.Buffer
+		Expect(printQuota(&out, &buildapitypes.StorageQuotaResponse{Namespace: "team-a"})).To(Succeed())
+		Expect(out.String()).To(Equal("Namespace:  team-a\nWorkspaces: 0 using 0 B\nLimit:      unlimited\n"))
+	})
+})
+
+var _ = Describe("Printing the workspace of a build", func() {
+	It("should show its size, what it was estimated from and the most it held", func() {
+		Expect(formatWorkspace(&buildapitypes.BuildResponse{WorkspaceSize: "8Gi"})).To(Equal("8Gi"))
+		Expect(formatWorkspace(&buildapitypes.BuildResponse{
+			WorkspaceSize:         "24Gi",
+			WorkspaceSizeEstimate: "120 packages",
+			WorkspacePeakBytes:    11 << 30,
+		})).To(Equal("24Gi (estimated from 120 packages), peak 11.0 GiB"))
+	})
+})
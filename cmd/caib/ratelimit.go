@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strconv"
+
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/throttle"
+)
+
+// limitRate caps the bandwidth of caib's downloads and uploads, set with --limit-rate
+var limitRate rateFlag
+
+// rateFlag is a flag holding bytes per second, written like curl's --limit-rate (512K, 10M, 1G)
+type rateFlag int64
+
+func (r *rateFlag) String() string {
+	if *r == 0 {
+		return ""
+	}
+	return strconv.FormatInt(int64(*r), 10)
+}
+
+func (r *rateFlag) Set(s string) error {
+	v, err := throttle.ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = rateFlag(v)
+	return nil
+}
+
+func (r *rateFlag) Type() string { return "rate" }
+
+// rateLimitOption applies --limit-rate to a build API client
+func rateLimitOption() buildapiclient.Option {
+	return buildapiclient.WithRateLimit(int64(limitRate))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("--limit-rate", func() {
+	It("should read rates like curl", func() {
+		var r rateFlag
+		Expect(r.Set("512K")).To(Succeed())
+		Expect(int64(r)).To(Equal(int64(512 << 10)))
+		Expect(r.Set("1.5m")).To(Succeed())
+		Expect(int64(r)).To(Equal(int64(3 << 19)))
+		Expect(r.Set("2000")).To(Succeed())
+		Expect(r.String()).To(Equal("2000"))
+		Expect(r.Set("fast")).To(MatchError(ContainSubstring(`invalid rate "fast"`)))
+		Expect(r.Set("-1M")).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+)
+
+// qemuFirmware lists where distributions and Homebrew install UEFI firmware for each QEMU architecture
+var qemuFirmware = map[string][]string{
+	"aarch64": {
+		"/usr/share/edk2/aarch64/QEMU_EFI.fd",
+		"/usr/share/AAVMF/AAVMF_CODE.fd",
+		"/usr/share/qemu-efi-aarch64/QEMU_EFI.fd",
+		"/opt/homebrew/share/qemu/edk2-aarch64-code.fd",
+		"/usr/local/share/qemu/edk2-aarch64-code.fd",
+	},
+	"x86_64": {
+		"/usr/share/edk2/ovmf/OVMF_CODE.fd",
+		"/usr/share/OVMF/OVMF.fd",
+		"/usr/share/ovmf/OVMF.fd",
+		"/opt/homebrew/share/qemu/edk2-x86_64-code.fd",
+		"/usr/local/share/qemu/edk2-x86_64-code.fd",
+	},
+}
+
+// qemuOptions describe the virtual machine `caib run` boots
+type qemuOptions struct {
+	// Arch is the QEMU architecture, aarch64 or x86_64
+	Arch   string
+	Image  string
+	Format string
+	// Accel is kvm, hvf or tcg
+	Accel    string
+	MemoryMB int
+	CPUs     int
+	// SSHPort is the local port forwarded to the guest's SSH port, 0 for none
+	SSHPort  int
+	Firmware string
+	// Persist writes the guest's changes to the image instead of discarding them
+	Persist bool
+}
+
+func runQemu(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	name := args[0]
+
+	artifactPath := runArtifact
+	arch := runArch
+	if artifactPath == "" {
+		if strings.TrimSpace(serverURL) == "" {
+			fmt.Fprintln(os.Stderr, "Error: --server is required (or set CAIB_SERVER) unless --artifact is given")
+			os.Exit(1)
+		}
+		if strings.TrimSpace(authToken) == "" {
+			if tok, err := loadTokenFromKubeconfig(); err == nil && strings.TrimSpace(tok) != "" {
+				authToken = tok
+			}
+		}
+		var opts []buildapiclient.Option
+		if strings.TrimSpace(authToken) != "" {
+			opts = append(opts, buildapiclient.WithAuthToken(strings.TrimSpace(authToken)))
+		}
+		opts = append(opts, namespaceOption(ctx, opts))
+		api, err := buildapiclient.New(serverURL, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if arch == "" {
+			tpl, err := api.GetTemplate(ctx, name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error getting the architecture of build %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			arch = string(tpl.Architecture)
+		}
+		_, path, err := localArtifact(ctx, api, name, outputDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		artifactPath = path
+	}
+	if isArchive(artifactPath) {
+		fmt.Fprintf(os.Stderr, "Error: %s is a directory export and cannot be booted\n", artifactPath)
+		os.Exit(1)
+	}
+
+	imagePath, err := decompressedImage(ctx, artifactPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	qemuArch, err := qemuArchitecture(arch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	firmware := runFirmware
+	if firmware == "" {
+		firmware = findFirmware(qemuArch)
+	}
+	if firmware == "" && qemuArch == "aarch64" {
+		fmt.Fprintln(os.Stderr, "Error: no UEFI firmware for aarch64 found; install edk2-aarch64 (qemu-efi-aarch64 on Debian) or pass --firmware")
+		os.Exit(1)
+	}
+	format := "raw"
+	if strings.HasSuffix(strings.ToLower(imagePath), ".qcow2") {
+		format = "qcow2"
+	}
+
+	qemuArgs := qemuCommand(qemuOptions{
+		Arch:     qemuArch,
+		Image:    imagePath,
+		Format:   format,
+		Accel:    qemuAccel(qemuArch),
+		MemoryMB: runMemory,
+		CPUs:     runCPUs,
+		SSHPort:  runSSHPort,
+		Firmware: firmware,
+		Persist:  runPersist,
+	})
+	if runPrint {
+		fmt.Println(strings.Join(qemuArgs, " "))
+		return
+	}
+
+	binary, err := exec.LookPath(qemuArgs[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s is not installed: %v\n", qemuArgs[0], err)
+		os.Exit(1)
+	}
+	if runSSHPort > 0 {
+		fmt.Printf("Booting %s; once it is up, ssh -p %d root@localhost. Press Ctrl-A X to quit.\n", filepath.Base(imagePath), runSSHPort)
+	} else {
+		fmt.Printf("Booting %s. Press Ctrl-A X to quit.\n", filepath.Base(imagePath))
+	}
+	// QEMU takes over the terminal as the guest's serial console
+	qemu := exec.CommandContext(ctx, binary, qemuArgs[1:]...)
+	qemu.Stdin, qemu.Stdout, qemu.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := qemu.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error starting %s: %v\n", binary, err)
+		os.Exit(1)
+	}
+}
+
+// qemuArchitecture maps a build architecture to QEMU's name for it
+func qemuArchitecture(arch string) (string, error) {
+	switch arch {
+	case "arm64", "aarch64":
+		return "aarch64", nil
+	case "amd64", "x86_64":
+		return "x86_64", nil
+	case "":
+		return qemuArchitecture(runtime.GOARCH)
+	default:
+		return "", fmt.Errorf("cannot boot %s images; pass --arch arm64 or amd64", arch)
+	}
+}
+
+// qemuAccel picks hardware virtualization when the guest runs on a host of its architecture
+func qemuAccel(qemuArch string) string {
+	if host, _ := qemuArchitecture(runtime.GOARCH); host != qemuArch {
+		return "tcg"
+	}
+	switch runtime.GOOS {
+	case "linux":
+		if f, err := os.OpenFile("/dev/kvm", os.O_RDWR, 0); err == nil {
+			f.Close()
+			return "kvm"
+		}
+	case "darwin":
+		return "hvf"
+	}
+	return "tcg"
+}
+
+func findFirmware(qemuArch string) string {
+	for _, path := range qemuFirmware[qemuArch] {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// qemuCommand returns the command line booting an image with a serial console on the terminal and user
+// networking
+func qemuCommand(o qemuOptions) []string {
+	args := []string{"qemu-system-" + o.Arch}
+	if o.Arch == "aarch64" {
+		args = append(args, "-machine", "virt")
+	} else {
+		args = append(args, "-machine", "q35")
+	}
+	cpu := "max"
+	if o.Accel != "tcg" {
+		cpu = "host"
+	}
+	args = append(args,
+		"-accel", o.Accel,
+		"-cpu", cpu,
+		"-m", strconv.Itoa(o.MemoryMB),
+		"-smp", strconv.Itoa(o.CPUs),
+		"-nographic",
+	)
+	if o.Firmware != "" {
+		args = append(args, "-bios", o.Firmware)
+	}
+	args = append(args, "-drive", fmt.Sprintf("file=%s,if=virtio,format=%s", o.Image, o.Format))
+	if !o.Persist {
+		args = append(args, "-snapshot")
+	}
+	netdev := "user,id=net0"
+	if o.SSHPort > 0 {
+		netdev += fmt.Sprintf(",hostfwd=tcp:127.0.0.1:%d-:22", o.SSHPort)
+	}
+	args = append(args,
+		"-netdev", netdev,
+		"-device", "virtio-net-pci,netdev=net0",
+		"-device", "virtio-rng-pci",
+	)
+	return args
+}
+
+// decompressedImage returns the image inside a compressed artifact, decompressing it next to the artifact
+// unless an earlier run already did
+func decompressedImage(ctx context.Context, artifactPath string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(artifactPath))
+	if ext != ".gz" && ext != ".lz4" && ext != ".xz" {
+		return artifactPath, nil
+	}
+	imagePath := strings.TrimSuffix(artifactPath, filepath.Ext(artifactPath))
+	src, err := os.Stat(artifactPath)
+	if err != nil {
+		return "", err
+	}
+	if dst, err := os.Stat(imagePath); err == nil && !dst.ModTime().Before(src.ModTime()) {
+		fmt.Printf("Reusing %s\n", imagePath)
+		return imagePath, nil
+	}
+
+	fmt.Printf("Decompressing %s...\n", filepath.Base(artifactPath))
+	f, err := os.Open(artifactPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	image, err := decompress(ctx, f, artifactPath)
+	if err != nil {
+		return "", err
+	}
+	defer image.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(imagePath), "."+filepath.Base(imagePath)+"-*.partial")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, image)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = image.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), imagePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("decompress %s: %w", filepath.Base(artifactPath), err)
+	}
+	return imagePath, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Running an image in QEMU", func() {
+	It("should boot aarch64 images on the virt machine with firmware, a snapshot and SSH forwarded", func() {
+		args := qemuCommand(qemuOptions{
+			Arch:     "aarch64",
+			Image:    "output/disk.qcow2",
+			Format:   "qcow2",
+			Accel:    "tcg",
+			MemoryMB: 2048,
+			CPUs:     2,
+			SSHPort:  2222,
+			Firmware: "/usr/share/edk2/aarch64/QEMU_EFI.fd",
+		})
+		Expect(strings.Join(args, " ")).To(Equal("qemu-system-aarch64 -machine virt -accel tcg -cpu max -m 2048 -smp 2 -nographic" +
+			" -bios /usr/share/edk2/aarch64/QEMU_EFI.fd -drive file=output/disk.qcow2,if=virtio,format=qcow2 -snapshot" +
+			" -netdev user,id=net0,hostfwd=tcp:127.0.0.1:2222-:22 -device virtio-net-pci,netdev=net0 -device virtio-rng-pci"))
+	})
+
+	It("should use the host CPU when accelerated and keep changes when asked to", func() {
+		args := qemuCommand(qemuOptions{Arch: "x86_64", Image: "disk.raw", Format: "raw", Accel: "kvm", MemoryMB: 4096, CPUs: 4, Persist: true})
+		Expect(args).To(ContainElements("-machine", "q35", "-cpu", "host", "file=disk.raw,if=virtio,format=raw", "user,id=net0"))
+		Expect(args).NotTo(ContainElements("-snapshot", "-bios"))
+	})
+
+	It("should map build architectures to QEMU's", func() {
+		Expect(qemuArchitecture("arm64")).To(Equal("aarch64"))
+		Expect(qemuArchitecture("amd64")).To(Equal("x86_64"))
+		_, err := qemuArchitecture("riscv64")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should decompress an artifact once and reuse the image afterwards", func() {
+		dir := GinkgoT().TempDir()
+		artifact := filepath.Join(dir, "disk.qcow2.gz")
+		f, err := os.Create(artifact)
+		Expect(err).NotTo(HaveOccurred())
+		gw := gzip.NewWriter(f)
+		_, err = gw.Write([]byte("qcow2 image"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gw.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		image, err := decompressedImage(context.Background(), artifact)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal(filepath.Join(dir, "disk.qcow2")))
+		Expect(os.ReadFile(image)).To(Equal([]byte("qcow2 image")))
+
+		Expect(os.WriteFile(image, []byte("booted"), 0o644)).To(Succeed())
+		image, err = decompressedImage(context.Background(), artifact)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.ReadFile(image)).To(Equal([]byte("booted")))
+	})
+})
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	progressbar "github.com/schollz/progressbar/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+const metadataSuffix = ".metadata.json"
+
+// artifactMetadata is what caib verify checks of the metadata file a build writes next to its artifact
+type artifactMetadata struct {
+	Name      string `json:"name"`
+	BuildName string `json:"buildName"`
+	SizeBytes int64  `json:"sizeBytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// readArtifactMetadata reads an artifact's metadata file, which must record its size and checksum
+func readArtifactMetadata(path string) (*artifactMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+	var m artifactMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", path, err)
+	}
+	if m.SHA256 == "" {
+		return nil, fmt.Errorf("metadata file %s records no sha256 checksum", path)
+	}
+	return &m, nil
+}
+
+// verifyArtifact checks the size and sha256 checksum of an artifact against its metadata and, when signature
+// is set, that it is a cosign sign-blob signature of the artifact made with keyFile's key
+func verifyArtifact(artifactPath string, m *artifactMetadata, signature, keyFile string) error {
+	var key crypto.PublicKey
+	if signature != "" {
+		pem, err := os.ReadFile(keyFile)
+		if err != nil {
+			return fmt.Errorf("read public key: %w", err)
+		}
+		if key, err = registry.ParsePublicKey(pem); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(artifactPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() != m.SizeBytes {
+		return withExitCode(exitVerifyFailed, fmt.Errorf("%s is %d bytes, the metadata records %d",
+			artifactPath, fi.Size(), m.SizeBytes))
+	}
+
+	bar := newProgressBar(fi.Size(), "Verifying",
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionSetWidth(15),
+		progressbar.OptionThrottle(65*time.Millisecond),
+	)
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(hash, bar), f)
+	_ = bar.Finish()
+	if err != nil {
+		return fmt.Errorf("read %s: %w", artifactPath, err)
+	}
+	var sum [sha256.Size]byte
+	hash.Sum(sum[:0])
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, m.SHA256) {
+		return withExitCode(exitVerifyFailed, fmt.Errorf("sha256 of %s is %s, the metadata records %s",
+			artifactPath, got, m.SHA256))
+	}
+
+	if signature != "" {
+		if err := registry.VerifyBlobSignature(sum, signature, key); err != nil {
+			return withExitCode(exitVerifyFailed, fmt.Errorf("signature of %s: %w", artifactPath, err))
+		}
+	}
+	return nil
+}
+
+func runVerify(cmd *cobra.Command, args []string) {
+	artifactPath := args[0]
+	metadataPath := verifyMetadata
+	if metadataPath == "" {
+		metadataPath = artifactPath + metadataSuffix
+	}
+	var signature string
+	if verifySignature != "" {
+		if verifyKey == "" {
+			handleError(withExitCode(exitInvalidArgs, fmt.Errorf("--signature needs the public key to check it with (--key)")))
+		}
+		data, err := os.ReadFile(verifySignature)
+		if err != nil {
+			handleError(withExitCode(exitInvalidArgs, fmt.Errorf("read signature: %w", err)))
+		}
+		signature = string(data)
+	}
+
+	m, err := readArtifactMetadata(metadataPath)
+	if err != nil {
+		handleError(withExitCode(exitInvalidArgs, err))
+	}
+	if err := verifyArtifact(artifactPath, m, signature, verifyKey); err != nil {
+		handleError(err)
+	}
+
+	fmt.Printf("%s: %d bytes, sha256 %s match %s\n", artifactPath, m.SizeBytes, strings.ToLower(m.SHA256), metadataPath)
+	if signature != "" {
+		fmt.Printf("%s: signature verified with %s\n", artifactPath, verifyKey)
+	}
+}
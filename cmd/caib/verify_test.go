@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// signBlob signs content like cosign sign-blob and returns the base64 signature and the PEM public key
+func signBlob(content []byte) (string, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	sum := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	Expect(err).NotTo(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	Expect(err).NotTo(HaveOccurred())
+	return base64.StdEncoding.EncodeToString(sig), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+var _ = Describe("Artifact verification", func() {
+	var (
+		dir      string
+		artifact string
+		content  []byte
+		metadata *artifactMetadata
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		artifact = filepath.Join(dir, "disk.raw.gz")
+		content = []byte("a compressed automotive image")
+		Expect(os.WriteFile(artifact, content, 0o644)).To(Succeed())
+		sum := sha256.Sum256(content)
+		metadata = &artifactMetadata{Name: "disk.raw.gz", SizeBytes: int64(len(content)), SHA256: hex.EncodeToString(sum[:])}
+	})
+
+	It("reads the size and checksum of a metadata file", func() {
+		path := artifact + metadataSuffix
+		Expect(os.WriteFile(path, []byte(`{"name":"disk.raw.gz","buildName":"radio","sizeBytes":29,"sha256":"abc","distro":"autosd"}`), 0o644)).To(Succeed())
+		m, err := readArtifactMetadata(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*m).To(Equal(artifactMetadata{Name: "disk.raw.gz", BuildName: "radio", SizeBytes: 29, SHA256: "abc"}))
+
+		Expect(os.WriteFile(path, []byte(`{"name":"disk.raw.gz"}`), 0o644)).To(Succeed())
+		_, err = readArtifactMetadata(path)
+		Expect(err).To(MatchError(ContainSubstring("no sha256 checksum")))
+	})
+
+	It("accepts an artifact matching its metadata", func() {
+		Expect(verifyArtifact(artifact, metadata, "", "")).To(Succeed())
+	})
+
+	It("rejects an artifact of another size or checksum", func() {
+		metadata.SizeBytes++
+		err := verifyArtifact(artifact, metadata, "", "")
+		Expect(err).To(MatchError(ContainSubstring("the metadata records 30")))
+		Expect(exitCodeOf(err)).To(Equal(exitVerifyFailed))
+
+		metadata.SizeBytes--
+		metadata.SHA256 = hex.EncodeToString(make([]byte, sha256.Size))
+		err = verifyArtifact(artifact, metadata, "", "")
+		Expect(err).To(MatchError(ContainSubstring("sha256 of")))
+		Expect(exitCodeOf(err)).To(Equal(exitVerifyFailed))
+	})
+
+	It("checks a cosign sign-blob signature of the artifact", func() {
+		signature, publicKey := signBlob(content)
+		keyFile := filepath.Join(dir, "cosign.pub")
+		Expect(os.WriteFile(keyFile, publicKey, 0o644)).To(Succeed())
+		Expect(verifyArtifact(artifact, metadata, signature+"\n", keyFile)).To(Succeed())
+
+		otherSignature, _ := signBlob(content)
+		err := verifyArtifact(artifact, metadata, otherSignature, keyFile)
+		Expect(err).To(MatchError(ContainSubstring("signature does not match")))
+		Expect(exitCodeOf(err)).To(Equal(exitVerifyFailed))
+	})
+})
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	buildapiclient "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/client"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifacttype"
+)
+
+// mirrorFile is a file of a build that the local mirror lacks, or holds an older copy of
+type mirrorFile struct {
+	Name string
+	// Size is the size the server reports, -1 when it reports none
+	Size int64
+	// ContentType is the media type of the file's payload, which names the local copy
+	ContentType string
+	// Part is set for the files /artifacts lists, which are fetched from there
+	Part bool
+}
+
+// artifactMirror downloads the files of a build into dir as the build API starts serving them, so that
+// --watch-artifacts leaves a complete local copy of the build's outputs the moment it finishes
+type artifactMirror struct {
+	api  *buildapiclient.Client
+	name string
+	dir  string
+	// have maps the files downloaded so far to their size, so that files that grew are fetched again
+	have map[string]int64
+}
+
+func newArtifactMirror(api *buildapiclient.Client, name, dir string) *artifactMirror {
+	if dir == "" {
+		dir = "./output"
+	}
+	return &artifactMirror{api: api, name: name, dir: dir, have: map[string]int64{}}
+}
+
+// missingArtifactFiles returns the files of st and the artifact items listed for it that have does not hold
+// at their current size
+func missingArtifactFiles(have map[string]int64, st *buildapitypes.BuildResponse, items []buildapitypes.ArtifactItem) []mirrorFile {
+	var files []mirrorFile
+	for _, item := range items {
+		size, err := strconv.ParseInt(item.SizeBytes, 10, 64)
+		if err != nil {
+			size = -1
+		}
+		files = append(files, mirrorFile{Name: item.Name, Size: size, ContentType: item.ContentType, Part: true})
+	}
+	// the artifact and its conversions are only served once they are final, so one download each is enough
+	if st.Phase == "Completed" && st.ArtifactFileName != "" {
+		files = append(files, mirrorFile{Name: st.ArtifactFileName, Size: -1, ContentType: st.ArtifactContentType})
+		for _, c := range st.Conversions {
+			if c.Phase == "Completed" && c.FileName != "" {
+				files = append(files, mirrorFile{Name: c.FileName, Size: -1})
+			}
+		}
+	}
+
+	missing := files[:0]
+	for _, f := range files {
+		got, ok := have[f.Name]
+		if ok && (f.Size < 0 || got == f.Size) {
+			continue
+		}
+		missing = append(missing, f)
+	}
+	return missing
+}
+
+// sync downloads the files of the build the mirror lacks. It reports whether the mirror is complete: the
+// build completed and every file it serves was downloaded. Files the server does not serve yet are left
+// for the next call.
+func (m *artifactMirror) sync(ctx context.Context, st *buildapitypes.BuildResponse) (bool, error) {
+	items, err := m.api.ListArtifacts(ctx, m.name)
+	listed := err == nil
+	if err != nil && !artifactNotServedYet(err) {
+		return false, fmt.Errorf("list artifacts: %w", err)
+	}
+
+	complete := listed && st.Phase == "Completed"
+	for _, f := range missingArtifactFiles(m.have, st, items) {
+		size, err := m.download(ctx, f)
+		if err != nil {
+			if artifactNotServedYet(err) {
+				complete = false
+				continue
+			}
+			return false, fmt.Errorf("download %s: %w", f.Name, err)
+		}
+		m.have[f.Name] = size
+		fmt.Printf("Downloaded %s (%d bytes)\n", m.localPath(f), size)
+	}
+	return complete, nil
+}
+
+// finish downloads the files of a completed build the mirror still lacks, waiting for the artifact pod to
+// serve them
+func (m *artifactMirror) finish(ctx context.Context, st *buildapitypes.BuildResponse) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+	for {
+		complete, err := m.sync(ctx, st)
+		if err != nil {
+			return err
+		}
+		if complete {
+			fmt.Printf("All %d build files are in %s\n", len(m.have), m.dir)
+			if keyFile != "" && strings.HasSuffix(st.ArtifactFileName, encryptedSuffix) {
+				_, err := decryptArtifact(m.localPath(mirrorFile{Name: st.ArtifactFileName, ContentType: st.ArtifactContentType}), keyFile)
+				return err
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the build's files to be served")
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+func (m *artifactMirror) download(ctx context.Context, f mirrorFile) (int64, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return 0, fmt.Errorf("create output dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(m.dir, "."+f.Name+"-*.partial")
+	if err != nil {
+		return 0, err
+	}
+	var d *buildapiclient.Download
+	if f.Part {
+		d, err = m.api.DownloadArtifact(ctx, m.name, f.Name, tmp, nil)
+	} else {
+		d, err = m.api.DownloadFile(ctx, m.name, f.Name, tmp, nil)
+	}
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), m.localPath(f)); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return d.Size, nil
+}
+
+// localPath is where the mirror keeps f, named after its content type
+func (m *artifactMirror) localPath(f mirrorFile) string {
+	return filepath.Join(m.dir, artifacttype.FileName(f.Name, f.ContentType))
+}
+
+// artifactNotServedYet reports whether err means the build API does not serve a build's files yet, because
+// the build has not completed or its artifact pod is not ready, rather than that they cannot be downloaded
+func artifactNotServedYet(err error) bool {
+	switch buildapiclient.StatusCode(err) {
+	case http.StatusConflict, http.StatusNotFound, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	buildapitypes "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+)
+
+var _ = Describe("Watching artifacts", func() {
+	items := []buildapitypes.ArtifactItem{
+		{Name: "cs9-qemu.qcow2.metadata.json", SizeBytes: "120"},
+		{Name: "cs9-qemu.qcow2.gz", SizeBytes: "4096"},
+	}
+
+	It("should only fetch listed files while the build runs", func() {
+		st := &buildapitypes.BuildResponse{Phase: "Building", ArtifactFileName: "cs9-qemu.qcow2"}
+		Expect(missingArtifactFiles(map[string]int64{}, st, items)).To(Equal([]mirrorFile{
+			{Name: "cs9-qemu.qcow2.metadata.json", Size: 120, Part: true},
+			{Name: "cs9-qemu.qcow2.gz", Size: 4096, Part: true},
+		}))
+	})
+
+	It("should fetch files again that grew, and the artifact and conversions once completed", func() {
+		st := &buildapitypes.BuildResponse{
+			Phase:            "Completed",
+			ArtifactFileName: "cs9-qemu.qcow2",
+			Conversions: []buildapitypes.ArtifactConversion{
+				{Format: "vmdk", Phase: "Completed", FileName: "cs9-qemu.vmdk"},
+				{Format: "vdi", Phase: "Running"},
+			},
+		}
+		have := map[string]int64{"cs9-qemu.qcow2.metadata.json": 120, "cs9-qemu.qcow2.gz": 1024}
+		Expect(missingArtifactFiles(have, st, items)).To(Equal([]mirrorFile{
+			{Name: "cs9-qemu.qcow2.gz", Size: 4096, Part: true},
+			{Name: "cs9-qemu.qcow2", Size: -1},
+			{Name: "cs9-qemu.vmdk", Size: -1},
+		}))
+
+		have["cs9-qemu.qcow2.gz"] = 4096
+		have["cs9-qemu.qcow2"] = 1 << 30
+		have["cs9-qemu.vmdk"] = 1 << 30
+		Expect(missingArtifactFiles(have, st, items)).To(BeEmpty())
+	})
+})
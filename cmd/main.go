@@ -28,6 +28,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -40,7 +41,9 @@ import (
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 
 	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/requeue"
 	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/controller/automotivedev"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/controller/discovery"
 	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/controller/image"
 	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/controller/imagebuild"
 	// +kubebuilder:scaffold:imports
@@ -49,6 +52,8 @@ import (
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
+	// version is set at build time with -ldflags "-X main.version=..."
+	version = "dev"
 )
 
 func init() {
@@ -82,6 +87,8 @@ func main() {
 	opts := zap.Options{
 		Development: true,
 	}
+	requeueConfig := requeue.DefaultConfig()
+	requeueConfig.BindFlags(flag.CommandLine)
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
@@ -145,10 +152,11 @@ func main() {
 	autoDevReady := make(chan struct{})
 
 	autoDevReconciler := &automotivedev.AutomotiveDevReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("AutomotiveDev"),
-		Ready:  autoDevReady,
+		Client:  mgr.GetClient(),
+		Scheme:  mgr.GetScheme(),
+		Log:     ctrl.Log.WithName("controllers").WithName("AutomotiveDev"),
+		Ready:   autoDevReady,
+		Version: version,
 	}
 
 	if err = autoDevReconciler.SetupWithManager(mgr); err != nil {
@@ -156,16 +164,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create clientset")
+		os.Exit(1)
+	}
+
 	imageBuildReconciler := &imagebuild.ImageBuildReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ImageBuild"),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Log:       ctrl.Log.WithName("controllers").WithName("ImageBuild"),
+		Clientset: clientset,
+		Requeue:   requeue.Pacer{Config: requeueConfig, Controller: "imagebuild"},
 	}
 
 	imageReconciler := &image.ImageReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Image"),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Image"),
+		Recorder: mgr.GetEventRecorderFor("image-controller"),
+		Requeue:  requeue.Pacer{Config: requeueConfig, Controller: "image"},
 	}
 
 	if err = imageReconciler.SetupWithManager(mgr); err != nil {
@@ -173,6 +191,34 @@ func main() {
 		os.Exit(1)
 	}
 
+	imageDiscoveryReconciler := &discovery.ImageDiscoveryReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Log:    ctrl.Log.WithName("controllers").WithName("ImageDiscovery"),
+	}
+
+	if err = imageDiscoveryReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ImageDiscovery")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&imagebuild.RegistrySecretCleanup{
+		Client: mgr.GetClient(),
+		Reader: mgr.GetAPIReader(),
+		Log:    ctrl.Log.WithName("registry-secret-cleanup"),
+	}); err != nil {
+		setupLog.Error(err, "unable to set up registry secret cleanup")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&imagebuild.IntermediateRegistryCleanup{
+		Reader: mgr.GetAPIReader(),
+		Log:    ctrl.Log.WithName("intermediate-registry-cleanup"),
+	}); err != nil {
+		setupLog.Error(err, "unable to set up intermediate registry cleanup")
+		os.Exit(1)
+	}
+
 	go func() {
 		<-autoDevReady
 		setupLog.Info("AutomotiveDev is ready, starting ImageBuild controller")
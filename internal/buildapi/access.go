@@ -0,0 +1,163 @@
+package buildapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	authnv1 "k8s.io/api/authentication/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	// requestedByGroupsAnnotation records the groups of the requester of a build, comma separated
+	requestedByGroupsAnnotation = "automotive.sdv.cloud.redhat.com/requested-by-groups"
+	// accessGroupsAnnotation records the groups a build is shared with, comma separated
+	accessGroupsAnnotation = "automotive.sdv.cloud.redhat.com/access-groups"
+)
+
+// requesterKey is the gin context key of the authenticated caller's authnv1.UserInfo
+const requesterKey = "requester"
+
+type ctxKeyRequesterGroups struct{}
+
+// withRequesterGroups returns ctx carrying the groups of the caller a build is created for
+func withRequesterGroups(ctx context.Context, groups []string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequesterGroups{}, groups)
+}
+
+func requesterGroupsFrom(ctx context.Context) []string {
+	groups, _ := ctx.Value(ctxKeyRequesterGroups{}).([]string)
+	return groups
+}
+
+// validateAccessGroups checks the groups a build request shares the build with
+func validateAccessGroups(groups []string) error {
+	for _, g := range groups {
+		if strings.TrimSpace(g) != g || g == "" || strings.Contains(g, ",") {
+			return newError(ErrInvalidInput, "invalid access group %q: must be non-empty without commas or surrounding spaces", g)
+		}
+	}
+	return nil
+}
+
+// buildAccessGroups returns the groups a build is shared with: the ones its request names, or else its
+// requester's groups except the system: ones every user is in, such as system:authenticated
+func buildAccessGroups(requested, requesterGroups []string) []string {
+	if len(requested) > 0 {
+		return requested
+	}
+	var groups []string
+	for _, g := range requesterGroups {
+		if !strings.HasPrefix(g, "system:") && !strings.Contains(g, ",") {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// splitGroups parses a comma separated group annotation
+func splitGroups(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// canAccessBuild reports whether user may see a build requested by owner and shared with groups under policy
+func canAccessBuild(user authnv1.UserInfo, owner string, groups []string, policy *automotivev1.BuildAccess) bool {
+	if policy == nil || !policy.Restricted {
+		return true
+	}
+	if user.Username != "" && user.Username == owner {
+		return true
+	}
+	for _, g := range user.Groups {
+		if slices.Contains(groups, g) || slices.Contains(policy.AdminGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessPolicy returns the AutomotiveDev's BuildConfig.Access, nil when it does not restrict access to builds
+func (s *buildService) AccessPolicy(ctx context.Context) (*automotivev1.BuildAccess, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading build access policy: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil || autoDev.Spec.BuildConfig.Access == nil || !autoDev.Spec.BuildConfig.Access.Restricted {
+		return nil, nil
+	}
+	return autoDev.Spec.BuildConfig.Access, nil
+}
+
+// buildAccessMiddleware refuses requests about a build the caller may not see when the AutomotiveDev restricts
+// access to builds. Requests for builds that do not exist are passed on, so that handlers answer them as usual.
+func (a *APIServer) buildAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if name == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		policy, err := a.svc.AccessPolicy(ctx)
+		if err != nil {
+			writeError(c, err)
+			c.Abort()
+			return
+		}
+		if policy == nil {
+			c.Next()
+			return
+		}
+		user, ok := a.requester(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		build, err := a.svc.GetBuild(ctx, name)
+		if errors.Is(err, ErrNotFound) {
+			c.Next()
+			return
+		}
+		if err != nil {
+			writeError(c, err)
+			c.Abort()
+			return
+		}
+		if !canAccessBuild(user, build.RequestedBy, build.AccessGroups, policy) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("build %s is only accessible to %s and the groups it is shared with", name, build.RequestedBy),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// visibleBuilds leaves the builds the caller may not see out of items
+func (a *APIServer) visibleBuilds(c *gin.Context, items []BuildListItem) ([]BuildListItem, error) {
+	policy, err := a.svc.AccessPolicy(c.Request.Context())
+	if err != nil || policy == nil {
+		return items, err
+	}
+	user, _ := a.requester(c)
+	visible := make([]BuildListItem, 0, len(items))
+	for _, b := range items {
+		if canAccessBuild(user, b.RequestedBy, b.AccessGroups, policy) {
+			visible = append(visible, b)
+		}
+	}
+	return visible, nil
+}
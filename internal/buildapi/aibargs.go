@@ -0,0 +1,90 @@
+package buildapi
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// defaultAllowedAIBArgs are the automotive-image-builder flags builds may pass unless the AutomotiveDev's
+// BuildConfig.AllowedAIBArgs replaces them. Flags choosing paths inside the build pod, such as --build-dir,
+// --define-file or --include, are left out: the build task sets the former, and the others would read files
+// of the build pod rather than of the build's manifests.
+var defaultAllowedAIBArgs = []string{
+	"--verbose", "--define", "--extend-define",
+	"--distro", "--target", "--arch", "--export", "--mode", "--fusa",
+}
+
+// aibValueFlags are the automotive-image-builder flags that take a value, as the next arg or after "=". Any
+// other flag is a switch: a word after it would reach automotive-image-builder as an extra positional argument.
+var aibValueFlags = []string{
+	"--define", "--define-file", "--extend-define", "--include",
+	"--distro", "--target", "--arch", "--export", "--mode",
+	"--build-dir", "--cache", "--policy",
+}
+
+// shellMetacharacters are refused in AIB args even though the build task no longer evaluates them, so a
+// request meant to reach a shell fails loudly instead of building something unexpected. Quotes, brackets and
+// braces are let through for list and map values of --define, which the task passes on as they are.
+const shellMetacharacters = "`$&|;<>()*?!~#\\"
+
+// validateAIBArgs checks the args of field against the allowed flags. Every flag, the part of an arg
+// before "=", must be allowed; an arg that is not a flag must be the value of the flag before it, which must
+// be one of aibValueFlags. Switches take no value.
+func validateAIBArgs(field string, args, allowed []string) error {
+	valueOf := ""
+	for i, arg := range args {
+		if arg == "" {
+			return fmt.Errorf("%s[%d]: empty argument", field, i)
+		}
+		if strings.ContainsFunc(arg, unicode.IsSpace) {
+			return fmt.Errorf("%s[%d] %q: whitespace is not allowed, pass each argument separately", field, i, arg)
+		}
+		if strings.ContainsFunc(arg, unicode.IsControl) {
+			return fmt.Errorf("%s[%d] %q: control characters are not allowed", field, i, arg)
+		}
+		if j := strings.IndexAny(arg, shellMetacharacters); j >= 0 {
+			return fmt.Errorf("%s[%d] %q: shell metacharacter %q is not allowed", field, i, arg, arg[j])
+		}
+		if !strings.HasPrefix(arg, "-") {
+			if valueOf == "" {
+				return fmt.Errorf("%s[%d] %q: not a flag nor the value of one", field, i, arg)
+			}
+			valueOf = ""
+			continue
+		}
+		if valueOf != "" {
+			return fmt.Errorf("%s[%d]: flag %q needs a value", field, i-1, valueOf)
+		}
+		name, _, hasValue := strings.Cut(arg, "=")
+		if !slices.Contains(allowed, name) {
+			return fmt.Errorf("%s[%d]: flag %q is not allowed (allowed: %s)", field, i, name, strings.Join(allowed, ", "))
+		}
+		takesValue := slices.Contains(aibValueFlags, name)
+		if hasValue && !takesValue {
+			return fmt.Errorf("%s[%d]: flag %q takes no value", field, i, name)
+		}
+		if takesValue && !hasValue {
+			valueOf = name
+		}
+	}
+	if valueOf != "" {
+		return fmt.Errorf("%s[%d]: flag %q needs a value", field, len(args)-1, valueOf)
+	}
+	return nil
+}
+
+// aibArgValue returns the value the last occurrence of flag in args sets, as --flag=value or --flag value, or def
+// when args do not set it
+func aibArgValue(args []string, flag, def string) string {
+	value := def
+	for i, arg := range args {
+		if name, v, ok := strings.Cut(arg, "="); ok && name == flag {
+			value = v
+		} else if arg == flag && i+1 < len(args) {
+			value = args[i+1]
+		}
+	}
+	return value
+}
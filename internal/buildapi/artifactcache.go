@@ -0,0 +1,183 @@
+package buildapi
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultArtifactCacheSize bounds the artifact cache when $BUILD_API_ARTIFACT_CACHE_SIZE is not set
+const defaultArtifactCacheSize = 10 << 30
+
+// artifactCache keeps recently downloaded artifacts on the build API's disk, so many clients fetching
+// the same file do not each reach the artifact pod. The least recently used files are evicted once the
+// cache outgrows its size. A nil cache caches nothing.
+type artifactCache struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	size int64
+	// lru holds *cachedArtifact, most recently used first
+	lru     *list.List
+	entries map[string]*list.Element
+	// filling marks the keys being written to the cache
+	filling map[string]bool
+}
+
+type cachedArtifact struct {
+	key  string
+	path string
+	size int64
+}
+
+// artifactCacheFromEnv returns the cache configured with $BUILD_API_ARTIFACT_CACHE_DIR and
+// $BUILD_API_ARTIFACT_CACHE_SIZE (e.g. "20Gi"), or nil if no directory is set
+func artifactCacheFromEnv() (*artifactCache, error) {
+	dir := strings.TrimSpace(os.Getenv("BUILD_API_ARTIFACT_CACHE_DIR"))
+	if dir == "" {
+		return nil, nil
+	}
+	maxSize := int64(defaultArtifactCacheSize)
+	if v := strings.TrimSpace(os.Getenv("BUILD_API_ARTIFACT_CACHE_SIZE")); v != "" {
+		n, err := parseSize(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BUILD_API_ARTIFACT_CACHE_SIZE: %w", err)
+		}
+		maxSize = n
+	}
+	return newArtifactCache(dir, maxSize)
+}
+
+func newArtifactCache(dir string, maxSize int64) (*artifactCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("artifact cache: %w", err)
+	}
+	// files left by a previous run are not indexed, so they would only take up space
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("artifact cache: %w", err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".artifact") || strings.HasSuffix(e.Name(), ".partial") {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return &artifactCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+		filling: map[string]bool{},
+	}, nil
+}
+
+// open returns the cached file of key and its size, or nil if it is not cached
+func (c *artifactCache) open(key string) (*os.File, int64) {
+	if c == nil {
+		return nil, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, 0
+	}
+	entry := el.Value.(*cachedArtifact)
+	f, err := os.Open(entry.path)
+	if err != nil {
+		c.remove(el)
+		return nil, 0
+	}
+	c.lru.MoveToFront(el)
+	return f, entry.size
+}
+
+// copy copies r to w and keeps the content as key if it is exactly size bytes and fits the cache.
+// While a key is being cached, other copies of it pass straight through.
+func (c *artifactCache) copy(key string, size int64, w io.Writer, r io.Reader) error {
+	if c == nil || size < 0 || size > c.maxSize || !c.startFill(key) {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	defer c.endFill(key)
+
+	tmp, err := os.CreateTemp(c.dir, "*.partial")
+	if err != nil {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	cf := &cacheFile{f: tmp}
+	n, err := io.Copy(w, io.TeeReader(r, cf))
+	closeErr := tmp.Close()
+	if err != nil || cf.err != nil || closeErr != nil || n != size {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	c.add(key, tmp.Name(), size)
+	return nil
+}
+
+func (c *artifactCache) startFill(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || c.filling[key] {
+		return false
+	}
+	c.filling[key] = true
+	return true
+}
+
+func (c *artifactCache) endFill(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.filling, key)
+}
+
+// add moves a fully written file into the cache and evicts the least recently used files beyond its size
+func (c *artifactCache) add(key, tmpPath string, size int64) {
+	sum := sha256.Sum256([]byte(key))
+	p := filepath.Join(c.dir, hex.EncodeToString(sum[:])+".artifact")
+	if err := os.Rename(tmpPath, p); err != nil {
+		_ = os.Remove(tmpPath)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = c.lru.PushFront(&cachedArtifact{key: key, path: p, size: size})
+	c.size += size
+	// files still being read stay readable after they are removed
+	for c.size > c.maxSize && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry and its file; c.mu must be held
+func (c *artifactCache) remove(el *list.Element) {
+	entry := el.Value.(*cachedArtifact)
+	c.lru.Remove(el)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	_ = os.Remove(entry.path)
+}
+
+// cacheFile writes to a cache file until a write fails; a full cache disk must not fail the download
+// passing through it
+type cacheFile struct {
+	f   *os.File
+	err error
+}
+
+func (t *cacheFile) Write(b []byte) (int, error) {
+	if t.err == nil {
+		_, t.err = t.f.Write(b)
+	}
+	return len(b), nil
+}
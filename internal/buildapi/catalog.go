@@ -0,0 +1,175 @@
+package buildapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// Built-in catalog values; an AutomotiveDev's BuildConfig.Catalog adds to them
+var (
+	defaultDistros = []string{"autosd", "autosd9", "autosd10", "cs9", "cs10", "eln", "f40", "f41"}
+	defaultTargets = []string{
+		"qemu", "abootqemu", "abootqemukvm", "acrn", "am62sk", "am69sk", "aws", "azure", "beagleplay",
+		"ccimx93dvk", "j784s4evm", "qdrive3", "rcar_s4", "ridesx4", "rpi4", "s32g_vnp_rdb3", "tda4vm_sk",
+	}
+	// the build task maps arm64 and amd64 to the aarch64 and x86_64 names automotive-image-builder uses
+	defaultArchitectures = []string{"arm64", "amd64", "aarch64", "x86_64"}
+	// profiles of the AutomotiveDev's BuildConfig.Profiles replace the built-in ones of the same name
+	defaultProfiles = []Profile{
+		{Name: "ridesx4", Description: "Qualcomm Ride SX 4.0", Target: "ridesx4", Architecture: "arm64", ExportFormat: "aboot.simg"},
+		{Name: "qdrive", Description: "Qualcomm QDrive 3", Target: "qdrive3", Architecture: "arm64", ExportFormat: "aboot.simg"},
+		{Name: "rcar-s4", Description: "Renesas R-Car S4 Spider", Target: "rcar_s4", Architecture: "arm64", ExportFormat: "image"},
+	}
+)
+
+// CatalogResponse lists the distros, targets, architectures and build profiles the server accepts
+type CatalogResponse struct {
+	Distros       []string  `json:"distros"`
+	Targets       []string  `json:"targets"`
+	Architectures []string  `json:"architectures"`
+	Profiles      []Profile `json:"profiles"`
+}
+
+// Profile bundles the settings of a target board under a name builds select it by. Settings a build
+// gives itself take precedence; the profile's AIB args and defines come before the build's own.
+type Profile struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	Target       Target       `json:"target,omitempty"`
+	Architecture Architecture `json:"architecture,omitempty"`
+	ExportFormat ExportFormat `json:"exportFormat,omitempty"`
+	AIBExtraArgs []string     `json:"aibExtraArgs,omitempty"`
+	CustomDefs   []string     `json:"customDefs,omitempty"`
+}
+
+// newCatalog returns the built-in catalog extended with extra values, each list sorted and without
+// duplicates, and the built-in profiles merged with profiles
+func newCatalog(distros, targets, architectures []string, profiles []automotivev1.BuildProfile) *CatalogResponse {
+	return &CatalogResponse{
+		Distros:       mergeValues(defaultDistros, distros),
+		Targets:       mergeValues(defaultTargets, targets),
+		Architectures: mergeValues(defaultArchitectures, architectures),
+		Profiles:      mergeProfiles(profiles),
+	}
+}
+
+// mergeProfiles returns the built-in profiles with profiles added or replacing them by name, sorted by name
+func mergeProfiles(profiles []automotivev1.BuildProfile) []Profile {
+	byName := map[string]Profile{}
+	for _, p := range defaultProfiles {
+		byName[p.Name] = p
+	}
+	for _, p := range profiles {
+		byName[p.Name] = Profile{
+			Name:         p.Name,
+			Description:  p.Description,
+			Target:       Target(p.Target),
+			Architecture: Architecture(p.Architecture),
+			ExportFormat: ExportFormat(p.ExportFormat),
+			AIBExtraArgs: p.AIBExtraArgs,
+			CustomDefs:   p.CustomDefs,
+		}
+	}
+	out := make([]Profile, 0, len(byName))
+	for _, p := range byName {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Profile returns the profile named name, suggesting the closest known name for an unknown one
+func (c *CatalogResponse) Profile(name string) (*Profile, error) {
+	names := make([]string, 0, len(c.Profiles))
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i], nil
+		}
+		names = append(names, c.Profiles[i].Name)
+	}
+	return nil, checkCatalogValue("profile", name, names)
+}
+
+func mergeValues(lists ...[]string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, l := range lists {
+		for _, v := range l {
+			v = strings.TrimSpace(v)
+			if v != "" && !seen[v] {
+				seen[v] = true
+				out = append(out, v)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Validate checks a build's distro, target and architecture against the catalog, suggesting the closest
+// known value for an unknown one
+func (c *CatalogResponse) Validate(d Distro, t Target, a Architecture) error {
+	if err := checkCatalogValue("distro", string(d), c.Distros); err != nil {
+		return err
+	}
+	if err := checkCatalogValue("target", string(t), c.Targets); err != nil {
+		return err
+	}
+	return checkCatalogValue("architecture", string(a), c.Architectures)
+}
+
+func checkCatalogValue(kind, value string, known []string) error {
+	for _, k := range known {
+		if k == value {
+			return nil
+		}
+	}
+	if s := suggest(value, known); s != "" {
+		return fmt.Errorf("unknown %s %q (did you mean %s?)", kind, value, s)
+	}
+	return fmt.Errorf("unknown %s %q (known: %s)", kind, value, strings.Join(known, ", "))
+}
+
+// suggest returns the known value closest to value, or "" if none is close enough to be a likely typo
+func suggest(value string, known []string) string {
+	lower := strings.ToLower(value)
+	best, bestDist := "", -1
+	for _, k := range known {
+		d := editDistance(lower, strings.ToLower(k))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	limit := len(value) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
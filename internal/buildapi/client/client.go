@@ -6,20 +6,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"strings"
 
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
 	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/throttle"
 )
 
 type Client struct {
-	baseURL    *url.URL
-	httpClient *http.Client
-	authToken  string
+	baseURL     *url.URL
+	httpClient  *http.Client
+	authToken   string
+	namespace   string
+	requestID   string
+	traceparent string
+	// limiter, if set, caps the bandwidth of downloads and uploads together
+	limiter *rate.Limiter
 }
 
 func New(base string, opts ...Option) (*Client, error) {
@@ -37,6 +45,9 @@ func New(base string, opts ...Option) (*Client, error) {
 	for _, o := range opts {
 		o(c)
 	}
+	if c.requestID == "" {
+		c.requestID = uuid.New().String()
+	}
 	return c, nil
 }
 
@@ -45,20 +56,193 @@ type Option func(*Client)
 func WithHTTPClient(h *http.Client) Option { return func(c *Client) { c.httpClient = h } }
 func WithAuthToken(t string) Option        { return func(c *Client) { c.authToken = t } }
 
+// WithNamespace makes every request act on namespace; the server's default namespace is used if empty
+func WithNamespace(ns string) Option { return func(c *Client) { c.namespace = ns } }
+
+// WithRequestID sends id as the X-Request-ID of every request instead of an ID generated for the client, so
+// that the server, the operator and the build log it
+func WithRequestID(id string) Option { return func(c *Client) { c.requestID = id } }
+
+// WithTraceparent sends a W3C traceparent header with every request; the server records it with the build
+func WithTraceparent(tp string) Option { return func(c *Client) { c.traceparent = tp } }
+
+// WithRateLimit caps the bandwidth all downloads and uploads of the client use together at bytesPerSecond;
+// 0 means no limit
+func WithRateLimit(bytesPerSecond int64) Option {
+	return func(c *Client) { c.limiter = throttle.NewLimiter(bytesPerSecond) }
+}
+
+// RequestID returns the X-Request-ID the client sends
+func (c *Client) RequestID() string { return c.requestID }
+
+// setHeaders adds the credentials, namespace selection and correlation IDs to a request
+func (c *Client) setHeaders(req *http.Request) {
+	req.Header.Set(correlation.RequestIDHeader, c.requestID)
+	if c.traceparent != "" {
+		req.Header.Set(correlation.TraceparentHeader, c.traceparent)
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	if c.namespace != "" {
+		req.Header.Set(buildapi.NamespaceHeader, c.namespace)
+	}
+}
+
+// ServerInfo returns information about the build API instance, such as its default namespace
+func (c *Client) ServerInfo(ctx context.Context) (*buildapi.ServerInfoResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolve("/v1/info"), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("get server info failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.ServerInfoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BuildStats summarizes the builds created within window, which is given in days ("7d") or as a Go
+// duration ("36h"); an empty window uses the server's default
+func (c *Client) BuildStats(ctx context.Context, window string) (*buildapi.BuildStatsResponse, error) {
+	endpoint := c.resolve("/v1/stats")
+	if window != "" {
+		endpoint += "?" + url.Values{"window": {window}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("get build stats failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.BuildStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StorageQuota reports the storage held by the namespace's build workspaces and the limit they are checked against
+func (c *Client) StorageQuota(ctx context.Context) (*buildapi.StorageQuotaResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolve("/v1/quota"), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("get storage quota failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.StorageQuotaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Catalog returns the distros, targets and architectures the server accepts
+func (c *Client) Catalog(ctx context.Context) (*buildapi.CatalogResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.resolve("/v1/catalog"), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("get catalog failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.CatalogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Lint checks manifests against the server's lint rules
+func (c *Client) Lint(ctx context.Context, req buildapi.LintRequest) (*buildapi.LintResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.resolve("/v1/lint"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("lint failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.LintResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// createBuildRetries is how often CreateBuild resends a request carrying an Idempotency-Key
+const createBuildRetries = 3
+
+// CreateBuild starts a build. A request with an IdempotencyKey is retried on transient failures, since the
+// server answers a retry with the build the first attempt created.
 func (c *Client) CreateBuild(ctx context.Context, req buildapi.BuildRequest) (*buildapi.BuildResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
+	if req.IdempotencyKey == "" {
+		return c.createBuild(ctx, body, "")
+	}
+	return withRetries(ctx, createBuildRetries, func() (*buildapi.BuildResponse, error) {
+		return c.createBuild(ctx, body, req.IdempotencyKey)
+	})
+}
+
+func (c *Client) createBuild(ctx context.Context, body []byte, idempotencyKey string) (*buildapi.BuildResponse, error) {
 	endpoint := c.resolve("/v1/builds")
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	if c.authToken != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+c.authToken)
+	if idempotencyKey != "" {
+		httpReq.Header.Set(buildapi.IdempotencyKeyHeader, idempotencyKey)
 	}
+	c.setHeaders(httpReq)
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -66,7 +250,7 @@ func (c *Client) CreateBuild(ctx context.Context, req buildapi.BuildRequest) (*b
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, fmt.Errorf("create build failed: %s: %s", resp.Status, string(b))
+		return nil, &statusError{op: "create build", status: resp.Status, code: resp.StatusCode, body: string(b)}
 	}
 	var out buildapi.BuildResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
@@ -81,9 +265,7 @@ func (c *Client) GetBuild(ctx context.Context, name string) (*buildapi.BuildResp
 	if err != nil {
 		return nil, err
 	}
-	if c.authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.authToken)
-	}
+	c.setHeaders(req)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -100,15 +282,13 @@ func (c *Client) GetBuild(ctx context.Context, name string) (*buildapi.BuildResp
 	return &out, nil
 }
 
-func (c *Client) ListBuilds(ctx context.Context) ([]buildapi.BuildListItem, error) {
-	endpoint := c.resolve("/v1/builds")
+func (c *Client) GetTaskRun(ctx context.Context, name string) (*buildapi.TaskRunResponse, error) {
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "taskrun"))
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
-	if c.authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.authToken)
-	}
+	c.setHeaders(req)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -116,87 +296,174 @@ func (c *Client) ListBuilds(ctx context.Context) ([]buildapi.BuildListItem, erro
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, fmt.Errorf("list builds failed: %s: %s", resp.Status, string(b))
+		return nil, fmt.Errorf("get taskrun failed: %s: %s", resp.Status, string(b))
 	}
-	var out []buildapi.BuildListItem
+	var out buildapi.TaskRunResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return nil, err
 	}
-	return out, nil
+	return &out, nil
 }
 
-func (c *Client) resolve(p string) string {
-	u := *c.baseURL
-	basePath := u.Path
-	if !strings.HasSuffix(basePath, "/") && basePath != "" {
-		basePath += "/"
+func (c *Client) SetImageLifecycle(ctx context.Context, name string, req buildapi.ImageLifecycleRequest) (*buildapi.ImageLifecycleResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
 	}
-	p = strings.TrimPrefix(p, "/")
-	u.Path = path.Join(basePath, p)
-	return u.String()
+	endpoint := c.resolve(path.Join("/v1/images", url.PathEscape(name), "lifecycle"))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("set image lifecycle failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.ImageLifecycleResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
-type Upload struct {
-	SourcePath string
-	DestPath   string
-}
-
-func (c *Client) UploadFiles(ctx context.Context, name string, files []Upload) error {
-	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads"))
-	pr, pw := io.Pipe()
-	mw := multipart.NewWriter(pw)
-
-	go func() {
-		defer pw.Close()
-		defer mw.Close()
-
-		done := make(chan struct{})
-		go func() {
-			defer close(done)
-			for _, f := range files {
-				file, err := os.Open(f.SourcePath)
-				if err != nil {
-					pw.CloseWithError(err)
-					return
-				}
-				part, err := mw.CreateFormFile("file", f.DestPath)
-				if err != nil {
-					file.Close()
-					pw.CloseWithError(err)
-					return
-				}
-				if _, err := io.Copy(part, file); err != nil {
-					file.Close()
-					pw.CloseWithError(err)
-					return
-				}
-				file.Close()
-			}
-		}()
-
-		select {
-		case <-done:
-		case <-ctx.Done():
-			pw.CloseWithError(ctx.Err())
+// ListImages lists the Images of the catalog matching filter
+func (c *Client) ListImages(ctx context.Context, filter buildapi.ImageFilter) ([]buildapi.ImageResponse, error) {
+	endpoint := c.resolve("/v1/images")
+	q := url.Values{}
+	for k, v := range map[string]string{"distro": filter.Distro, "arch": filter.Architecture, "tag": filter.Tag} {
+		if v != "" {
+			q.Set(k, v)
 		}
-	}()
+	}
+	if len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("list images failed: %s: %s", resp.Status, string(b))
+	}
+	var out []buildapi.ImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, pr)
+func (c *Client) GetImage(ctx context.Context, name string) (*buildapi.ImageResponse, error) {
+	return c.doImage(ctx, http.MethodGet, name, "get image")
+}
+
+// DeleteImage removes an Image from the catalog and returns it
+func (c *Client) DeleteImage(ctx context.Context, name string) (*buildapi.ImageResponse, error) {
+	return c.doImage(ctx, http.MethodDelete, name, "delete image")
+}
+
+func (c *Client) doImage(ctx context.Context, method, name, action string) (*buildapi.ImageResponse, error) {
+	endpoint := c.resolve(path.Join("/v1/images", url.PathEscape(name)))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", mw.FormDataContentType())
-	if c.authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s failed: %s: %s", action, resp.Status, string(b))
+	}
+	var out buildapi.ImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RegisterImage catalogs an image built outside the cluster
+func (c *Client) RegisterImage(ctx context.Context, req buildapi.RegisterImageRequest) (*buildapi.ImageResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.resolve("/v1/images"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
 	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("register image failed: %s: %s", resp.Status, string(b))
+	}
+	var out buildapi.ImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBuilds lists builds, restricted to those carrying every one of labels if any are given
+func (c *Client) ListBuilds(ctx context.Context, labels map[string]string) ([]buildapi.BuildListItem, error) {
+	endpoint := c.resolve("/v1/builds")
+	if len(labels) > 0 {
+		q := url.Values{}
+		for k, v := range labels {
+			q.Add("label", k+"="+v)
+		}
+		endpoint += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return fmt.Errorf("upload failed: %s: %s", resp.Status, string(b))
+		return nil, fmt.Errorf("list builds failed: %s: %s", resp.Status, string(b))
 	}
-	return nil
+	var out []buildapi.BuildListItem
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) resolve(p string) string {
+	u := *c.baseURL
+	basePath := u.Path
+	if !strings.HasSuffix(basePath, "/") && basePath != "" {
+		basePath += "/"
+	}
+	p = strings.TrimPrefix(p, "/")
+	u.Path = path.Join(basePath, p)
+	return u.String()
 }
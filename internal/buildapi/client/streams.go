@@ -0,0 +1,385 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/throttle"
+)
+
+// LogOptions selects the logs StreamLogs returns; zero values return every step from the start
+type LogOptions struct {
+	// Follow keeps the stream open until the build's pod finishes
+	Follow bool
+	// Step is the step to start from; earlier steps are skipped
+	Step string
+	// SinceBytes skips that many bytes of the first streamed step, to resume an interrupted stream
+	SinceBytes int64
+	// SinceTime limits every step to log lines written at or after it
+	SinceTime time.Time
+	// TailLines, if set, limits every step to its last lines
+	TailLines *int64
+	// Heartbeat has the server write a NUL byte whenever the stream was quiet for a while, so proxies keep it
+	// open; the reader must drop NUL bytes
+	Heartbeat bool
+}
+
+func (o LogOptions) query() url.Values {
+	q := url.Values{}
+	if o.Follow {
+		q.Set("follow", "1")
+	}
+	if o.Step != "" {
+		q.Set("step", o.Step)
+	}
+	if o.SinceBytes > 0 {
+		q.Set("sinceBytes", strconv.FormatInt(o.SinceBytes, 10))
+	}
+	if !o.SinceTime.IsZero() {
+		q.Set("sinceTime", o.SinceTime.UTC().Format(time.RFC3339))
+	}
+	if o.TailLines != nil {
+		q.Set("tail", strconv.FormatInt(*o.TailLines, 10))
+	}
+	if o.Heartbeat {
+		q.Set("heartbeat", "1")
+	}
+	return q
+}
+
+// StreamLogs opens the plain-text log stream of a build; the caller must close it. While the build's
+// pod has not started the server answers 503, which StatusCode reports.
+func (c *Client) StreamLogs(ctx context.Context, name string, opts LogOptions) (io.ReadCloser, error) {
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "logs"))
+	if q := opts.query(); len(q) > 0 {
+		endpoint += "?" + q.Encode()
+	}
+	resp, err := c.get(ctx, endpoint, "stream logs")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// ListArtifacts lists the metadata file and the compressed parts of a completed build's artifact
+func (c *Client) ListArtifacts(ctx context.Context, name string) ([]buildapi.ArtifactItem, error) {
+	resp, err := c.get(ctx, c.resolve(path.Join("/v1/builds", url.PathEscape(name), "artifacts")), "list artifacts")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Items []buildapi.ArtifactItem `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// GetTemplate returns the inputs that produced a build, ready to be submitted again
+func (c *Client) GetTemplate(ctx context.Context, name string) (*buildapi.BuildTemplateResponse, error) {
+	resp, err := c.get(ctx, c.resolve(path.Join("/v1/builds", url.PathEscape(name), "template")), "get template")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out buildapi.BuildTemplateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelBuild asks the operator to stop a build that has not finished yet
+func (c *Client) CancelBuild(ctx context.Context, name string) (*buildapi.BuildResponse, error) {
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "cancel"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: "cancel build", status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	var out buildapi.BuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateBuild changes settings of a build that has not started building yet
+func (c *Client) UpdateBuild(ctx context.Context, name string, req buildapi.UpdateBuildRequest) (*buildapi.BuildResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name)))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPatch, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: "update build", status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	var out buildapi.BuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteBuild deletes a build and the resources it owns. The server refuses to delete builds that have not
+// finished unless force is set.
+func (c *Client) DeleteBuild(ctx context.Context, name string, force bool) (*buildapi.BuildResponse, error) {
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name)))
+	if force {
+		endpoint += "?" + url.Values{"force": {"true"}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: "delete build", status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	var out buildapi.BuildResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Download describes a file written by one of the Download methods
+type Download struct {
+	// FileName is the name the server suggests for the file, reduced to its base name
+	FileName    string
+	ContentType string
+	// ArtifactType, Compression and ArchiveRoot are the server's X-AIB-* hints, empty if not sent
+	ArtifactType string
+	Compression  string
+	ArchiveRoot  string
+	// PayloadType is the media type of the file once decompressed and decrypted, empty if not sent
+	PayloadType string
+	// Size is the number of bytes written
+	Size int64
+}
+
+// DownloadProgress is called as a download is written with the bytes written so far and the total
+// size, or -1 if the server did not announce it
+type DownloadProgress func(written, total int64)
+
+// PromoteBuild pushes the artifact of a completed build to a registry and catalogs it as an Image in
+// req.TargetNamespace. The request returns once the artifact is pushed.
+func (c *Client) PromoteBuild(ctx context.Context, name string, req buildapi.PromoteRequest) (*buildapi.PromoteResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "promote"))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: "promote build", status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	var out buildapi.PromoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ConvertArtifact asks the server to convert the artifact of a completed build to another disk format. It
+// returns the progress of the conversion; poll it by calling ConvertArtifact again or with GetBuild.
+func (c *Client) ConvertArtifact(ctx context.Context, name string, req buildapi.ConvertRequest) (*buildapi.ArtifactConversion, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "convert"))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	c.setHeaders(httpReq)
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: "convert artifact", status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	var out buildapi.ArtifactConversion
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadArtifact writes a file of a completed build to w: its artifact if file is empty, or one of
+// the parts ListArtifacts returns. Nothing is written unless the server answers with the file.
+func (c *Client) DownloadArtifact(ctx context.Context, name, file string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	if file != "" {
+		return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "artifacts", url.PathEscape(file)), "download artifact part", w, progress)
+	}
+	st, err := c.GetBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if st.ArtifactFileName == "" {
+		return nil, &statusError{op: "download artifact", status: http.StatusText(http.StatusConflict), code: http.StatusConflict, body: "artifact not available until build completes"}
+	}
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "artifact", url.PathEscape(st.ArtifactFileName)), "download artifact", w, progress)
+}
+
+// DownloadFile writes one of the files of a completed build's download info to w, such as its artifact or
+// a completed conversion of it
+func (c *Client) DownloadFile(ctx context.Context, name, file string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "artifact", url.PathEscape(file)), "download file", w, progress)
+}
+
+// DownloadArtifactsTar writes every output of a completed build to w as one tar archive
+func (c *Client) DownloadArtifactsTar(ctx context.Context, name string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "artifacts.tar"), "download artifacts", w, progress)
+}
+
+// DownloadWorkspace writes the workspace a failed build kept for debugging to w as a tar archive
+func (c *Client) DownloadWorkspace(ctx context.Context, name string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "workspace.tar"), "download workspace", w, progress)
+}
+
+// DownloadScanReport writes the vulnerability scan report of a completed build to w
+func (c *Client) DownloadScanReport(ctx context.Context, name string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "scan-report"), "download scan report", w, progress)
+}
+
+// DownloadLogsArchive writes the logs of every step of a finished build to w as a tar.gz archive
+func (c *Client) DownloadLogsArchive(ctx context.Context, name string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	return c.download(ctx, path.Join("/v1/builds", url.PathEscape(name), "logs", "archive"), "download logs archive", w, progress)
+}
+
+func (c *Client) download(ctx context.Context, p, op string, w io.Writer, progress DownloadProgress) (*Download, error) {
+	resp, err := c.get(ctx, c.resolve(p), op)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	d := &Download{
+		FileName:     path.Base(p),
+		ContentType:  resp.Header.Get("Content-Type"),
+		ArtifactType: strings.TrimSpace(resp.Header.Get("X-AIB-Artifact-Type")),
+		Compression:  strings.TrimSpace(resp.Header.Get("X-AIB-Compression")),
+		ArchiveRoot:  strings.TrimSpace(resp.Header.Get("X-AIB-Archive-Root")),
+		PayloadType:  strings.TrimSpace(resp.Header.Get("X-AIB-Content-Type")),
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		// the suggested name must not lead a caller out of the directory it saves to
+		if f := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")); f != "" && f != "." && f != "/" && f != ".." {
+			d.FileName = f
+		}
+	}
+
+	total := resp.ContentLength
+	if total < 0 {
+		total = -1
+	}
+	var dst io.Writer = w
+	if progress != nil {
+		dst = &progressWriter{w: w, total: total, report: progress}
+	}
+	d.Size, err = io.Copy(dst, throttle.Reader(ctx, resp.Body, nil, c.limiter))
+	if err != nil {
+		return d, fmt.Errorf("%s: %w", op, err)
+	}
+	return d, nil
+}
+
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	report  DownloadProgress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.report(p.written, p.total)
+	return n, err
+}
+
+// get sends a GET request and returns the response if the server answered 200
+func (c *Client) get(ctx context.Context, endpoint, op string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{op: op, status: resp.Status, code: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	return resp, nil
+}
+
+// StatusCode returns the HTTP status of a request the build API refused, or 0 if err is not such an error
+func StatusCode(err error) int {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code
+	}
+	return 0
+}
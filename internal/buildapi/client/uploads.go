@@ -0,0 +1,515 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/throttle"
+)
+
+type Upload struct {
+	// SourcePath is the local file to read, in the client's native form
+	SourcePath string
+	// DestPath is the path relative to the build's shared workspace; it is always sent with forward slashes
+	DestPath string
+}
+
+// UploadOptions tunes UploadFiles; zero values select the defaults
+type UploadOptions struct {
+	// Concurrency is how many files are uploaded at once (default 4)
+	Concurrency int
+	// ChunkSize is the most bytes sent per request (default 8 MiB)
+	ChunkSize int64
+	// ChunkThreshold is the size above which a file is sent in chunks that an interrupted upload can resume;
+	// smaller files are sent in a single request (default ChunkSize)
+	ChunkThreshold int64
+	// Retries is how often a failed chunk is retried before its file fails (default 3, negative for none)
+	Retries int
+	// Progress, if set, is called after every stored chunk with the bytes stored so far and the total of all files.
+	// It is called from several goroutines, one at a time.
+	Progress func(stored, total int64)
+}
+
+const (
+	defaultUploadConcurrency = 4
+	defaultUploadChunkSize   = 8 << 20
+	defaultUploadRetries     = 3
+)
+
+// pendingUpload is a file of an upload with the size and checksum it had when the upload started
+type pendingUpload struct {
+	Upload
+	dest   string
+	size   int64
+	sha256 string
+}
+
+// uploadProgress adds up the bytes stored across files and reports them through UploadOptions.Progress
+type uploadProgress struct {
+	mu     sync.Mutex
+	stored int64
+	total  int64
+	report func(stored, total int64)
+}
+
+func (p *uploadProgress) add(n int64) {
+	if p.report == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stored += n
+	p.report(p.stored, p.total)
+}
+
+// statusError is a non-2xx answer of the build API
+type statusError struct {
+	op     string
+	status string
+	code   int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s failed: %s: %s", e.op, e.status, e.body)
+}
+
+// retryable reports whether the request may succeed when sent again
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// UploadFiles sends files to a build's workspace and fails unless the server verified every one against its
+// SHA-256 checksum. Files are sent in parallel. Small files go in a single request each; files over
+// opts.ChunkThreshold are sent in chunks, so no request outlasts the idle timeout of a route: a failed chunk is
+// retried on its own and a file the workspace already holds part of (from an interrupted upload) resumes where
+// it stopped. A file that fails verification is sent again once from the start. Only after every file is
+// verified does it ask the server to complete the uploads, which lets the build proceed.
+func (c *Client) UploadFiles(ctx context.Context, name string, files []Upload, opts UploadOptions) (*buildapi.UploadResponse, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultUploadConcurrency
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultUploadChunkSize
+	}
+	if opts.ChunkThreshold <= 0 {
+		opts.ChunkThreshold = opts.ChunkSize
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = defaultUploadRetries
+	}
+
+	progress := &uploadProgress{report: opts.Progress}
+	pending := make([]*pendingUpload, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := fileSha256(f.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		p := &pendingUpload{Upload: f, dest: path.Clean(strings.ReplaceAll(f.DestPath, `\`, "/")), size: info.Size(), sha256: sum}
+		pending = append(pending, p)
+		progress.total += p.size
+	}
+	// servers predating upload progress lack the endpoint, and the uploads do not depend on it
+	_, _ = c.SetUploadTotals(ctx, name, len(pending), progress.total)
+
+	results, err := c.uploadAll(ctx, name, pending, opts, progress)
+	if err != nil {
+		return nil, err
+	}
+	// every file was verified as it was uploaded, so the completion lists none
+	resp, err := c.completeUploads(ctx, name, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	resp.Files = results
+	return resp, nil
+}
+
+// uploadAll uploads files with opts.Concurrency workers and returns their results in order, or the first error
+func (c *Client) uploadAll(ctx context.Context, name string, files []*pendingUpload, opts UploadOptions, progress *uploadProgress) ([]buildapi.UploadFileResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]buildapi.UploadFileResult, len(files))
+	work := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i := 0; i < min(opts.Concurrency, len(files)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				res, err := c.uploadFile(ctx, name, files[i], opts, progress)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[i] = *res
+			}
+		}()
+	}
+	for i := range files {
+		select {
+		case work <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(work)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, ctx.Err()
+}
+
+// uploadFile sends one file and returns its verification. A file that fails verification, e.g. because it
+// changed while it was read, is sent once more from the start.
+func (c *Client) uploadFile(ctx context.Context, name string, f *pendingUpload, opts UploadOptions, progress *uploadProgress) (*buildapi.UploadFileResult, error) {
+	send := c.uploadChunked
+	if f.size <= opts.ChunkThreshold {
+		send = c.uploadWhole
+	}
+	res, err := send(ctx, name, f, opts, progress)
+	if isChecksumMismatch(err) {
+		res, err = send(ctx, name, f, opts, progress)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("upload %s: %w", f.dest, err)
+	}
+	return res, nil
+}
+
+// isChecksumMismatch reports whether the server rejected a file because it did not match its checksum
+func isChecksumMismatch(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusBadRequest && strings.Contains(se.body, "checksum mismatch")
+}
+
+// uploadWhole sends a file in a single multipart request, which verifies it
+func (c *Client) uploadWhole(ctx context.Context, name string, f *pendingUpload, opts UploadOptions, progress *uploadProgress) (*buildapi.UploadFileResult, error) {
+	resp, err := withRetries(ctx, opts.Retries, func() (*buildapi.UploadResponse, error) {
+		return c.sendFile(ctx, name, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Files) != 1 || !resp.Files[0].Verified {
+		return nil, fmt.Errorf("server did not verify the file")
+	}
+	progress.add(f.size)
+	return &resp.Files[0], nil
+}
+
+// uploadChunked sends a file chunk by chunk, starting after whatever the workspace already holds of it, and
+// has the server verify it once it is complete
+func (c *Client) uploadChunked(ctx context.Context, name string, f *pendingUpload, opts UploadOptions, progress *uploadProgress) (*buildapi.UploadFileResult, error) {
+	st, err := withRetries(ctx, opts.Retries, func() (*buildapi.UploadedFile, error) {
+		return c.startUpload(ctx, name, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var offset int64
+	if st.Size <= f.size {
+		offset = st.Size
+	}
+	progress.add(offset)
+
+	file, err := os.Open(f.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	for offset < f.size {
+		n := min(opts.ChunkSize, f.size-offset)
+		st, err := withRetries(ctx, opts.Retries, func() (*buildapi.UploadedFile, error) {
+			return c.writeChunk(ctx, name, f.dest, offset, io.NewSectionReader(file, offset, n), n)
+		})
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusConflict {
+			// the workspace holds less than expected; continue from what it has
+			if st, err = c.uploadedFile(ctx, name, f.dest); err == nil {
+				progress.add(st.Size - offset)
+				offset = st.Size
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if st.Size != offset+n {
+			return nil, fmt.Errorf("server stored %d bytes, expected %d", st.Size, offset+n)
+		}
+		offset += n
+		progress.add(n)
+	}
+
+	res, err := withRetries(ctx, opts.Retries, func() (*buildapi.UploadFileResult, error) {
+		return c.finishUpload(ctx, name, f)
+	})
+	if isChecksumMismatch(err) {
+		// the server discarded the file, so it will be sent again from the start
+		progress.add(-f.size)
+	}
+	return res, err
+}
+
+// withRetries calls fn until it succeeds, fails with an error that is not retryable, or retries run out
+func withRetries[T any](ctx context.Context, retries int, fn func() (T, error)) (T, error) {
+	for attempt := 0; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= retries || !retryable(err) {
+			return v, err
+		}
+		select {
+		case <-ctx.Done():
+			return v, ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		}
+	}
+}
+
+func (c *Client) uploadFileEndpoint(name, dest string, query url.Values) string {
+	query.Set("path", dest)
+	return c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads", "file")) + "?" + query.Encode()
+}
+
+func (c *Client) uploadedFile(ctx context.Context, name, dest string) (*buildapi.UploadedFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.uploadFileEndpoint(name, dest, url.Values{}), nil)
+	if err != nil {
+		return nil, err
+	}
+	var out buildapi.UploadedFile
+	if err := c.doUpload(req, "upload status", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) startUpload(ctx context.Context, name string, f *pendingUpload) (*buildapi.UploadedFile, error) {
+	body, err := json.Marshal(buildapi.StartUploadRequest{Size: f.size, Sha256: f.sha256})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uploadFileEndpoint(name, f.dest, url.Values{}), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out buildapi.UploadedFile
+	if err := c.doUpload(req, "start upload", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) finishUpload(ctx context.Context, name string, f *pendingUpload) (*buildapi.UploadFileResult, error) {
+	body, err := json.Marshal(buildapi.FinishUploadRequest{Sha256: f.sha256})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads", "file", "finish")) +
+		"?" + url.Values{"path": {f.dest}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out buildapi.UploadFileResult
+	if err := c.doUpload(req, "finish upload", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetUploadTotals announces how many files and bytes are about to be uploaded to a build, so the build reports the
+// progress of its uploads as a percentage
+func (c *Client) SetUploadTotals(ctx context.Context, name string, files int, size int64) (*buildapi.UploadProgress, error) {
+	body, err := json.Marshal(buildapi.UploadTotalsRequest{Files: files, Bytes: size})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads", "totals"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out buildapi.UploadProgress
+	if err := c.doUpload(req, "set upload totals", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// quoteEscaper escapes a multipart filename like mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// sendFile posts a file as the single part of a multipart upload, with its checksum in the part header
+func (c *Client) sendFile(ctx context.Context, name string, f *pendingUpload) (*buildapi.UploadResponse, error) {
+	file, err := os.Open(f.SourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(f.dest)))
+		h.Set("Content-Type", "application/octet-stream")
+		h.Set(buildapi.ChecksumHeader, f.sha256)
+		part, err := mw.CreatePart(h)
+		if err == nil {
+			_, err = io.Copy(part, io.NewSectionReader(file, 0, f.size))
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, pr)
+	if err != nil {
+		pr.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var out buildapi.UploadResponse
+	err = c.doUpload(req, "upload", &out)
+	// stop the writer if the request ended before reading the whole body
+	pr.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) writeChunk(ctx context.Context, name, dest string, offset int64, content io.Reader, size int64) (*buildapi.UploadedFile, error) {
+	endpoint := c.uploadFileEndpoint(name, dest, url.Values{"offset": {strconv.FormatInt(offset, 10)}})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, content)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	var out buildapi.UploadedFile
+	if err := c.doUpload(req, "upload", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// completeUploads asks the server to verify the uploaded files and mark the uploads complete. On a checksum
+// mismatch it returns the per-file results together with the error.
+func (c *Client) completeUploads(ctx context.Context, name string, checksums map[string]string) (*buildapi.UploadResponse, error) {
+	body, err := json.Marshal(buildapi.CompleteUploadsRequest{Files: checksums})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.resolve(path.Join("/v1/builds", url.PathEscape(name), "uploads", "complete"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		buildapi.UploadResponse
+		Error string `json:"error"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("complete uploads failed: %s: %s", resp.Status, string(b))
+	}
+	if resp.StatusCode != http.StatusOK {
+		if out.Files != nil {
+			return &out.UploadResponse, fmt.Errorf("upload verification failed: %s", out.Error)
+		}
+		return nil, fmt.Errorf("complete uploads failed: %s: %s", resp.Status, out.Error)
+	}
+	for _, f := range out.Files {
+		if !f.Verified {
+			return &out.UploadResponse, fmt.Errorf("upload of %s was not verified (got sha256 %s)", f.Path, f.Sha256)
+		}
+	}
+	return &out.UploadResponse, nil
+}
+
+// doUpload sends an upload request and decodes its JSON answer into out
+func (c *Client) doUpload(req *http.Request, op string, out any) error {
+	c.setHeaders(req)
+	if c.limiter != nil && req.Body != nil {
+		body := req.Body
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{throttle.Reader(req.Context(), body, nil, c.limiter), body}
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &statusError{op: op, status: resp.Status, code: resp.StatusCode, body: string(b)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func fileSha256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", p, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,1487 @@
+package buildapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifacttype"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+)
+
+// statusForError maps BuildService error kinds to HTTP status codes
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrGone):
+		return http.StatusGone
+	case errors.Is(err, ErrNotReady):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrUnprocessable):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(c *gin.Context, err error) {
+	c.JSON(statusForError(err), gin.H{"error": err.Error()})
+}
+
+func writeJSON(c *gin.Context, status int, v any) {
+	c.Header("Cache-Control", "no-store")
+	c.IndentedJSON(status, v)
+}
+
+// @Summary Create a build
+// @Description Requests carrying an Idempotency-Key answer a retry with the build the first request created,
+// @Description so clients can retry creation safely, in particular together with generateName.
+// @ID createBuild
+// @Param Namespace
+// @Param Idempotency-Key header string optional Unique key of the request, at most 255 characters; keys are scoped to the requester
+// @Body application/json {BuildRequest}
+// @Success 200 application/json {BuildResponse} reuseExisting was set and an identical completed build was returned instead of a new one, or the Idempotency-Key matched an earlier request whose build is returned (replayed is set)
+// @Success 202 application/json {BuildResponse} Build accepted
+// @Failure 400 Invalid input, including manifests violating a lint rule whose action is block
+// @Failure 409 A build of that name exists, or the Idempotency-Key was used for a build of another name
+// @Failure 422 aibExtraArgs or aibOverrideArgs pass a flag the AutomotiveDev does not allow, or contain shell metacharacters
+// @Failure 503 The AutomotiveDev reports that the Tekton tasks or pipeline are not installed, or its lint rules are missing or invalid
+// @Router /v1/builds [post]
+func (a *APIServer) handleCreateBuild(c *gin.Context) {
+	a.log.Info("create build", "reqID", c.GetString("reqID"))
+
+	var req BuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	req.IdempotencyKey = c.GetHeader(IdempotencyKeyHeader)
+	requester := a.resolveRequester(c)
+	user, _ := a.requester(c)
+	resp, err := a.svc.CreateBuild(withRequesterGroups(c.Request.Context(), user.Groups), req, requester)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if resp.Reused || resp.Replayed {
+		writeJSON(c, http.StatusOK, resp)
+		return
+	}
+	writeJSON(c, http.StatusAccepted, resp)
+}
+
+// @Summary List builds
+// @ID listBuilds
+// @Param Namespace
+// @Param label query []string optional Only list builds carrying this KEY=VALUE label; may be repeated, all must match
+// @Success 200 application/json {[]BuildListItem} List of builds; builds not shared with the caller are left out when access to builds is restricted
+// @Failure 400 Malformed label filter
+// @Router /v1/builds [get]
+func (a *APIServer) handleListBuilds(c *gin.Context) {
+	a.log.Info("list builds", "reqID", c.GetString("reqID"))
+
+	labels := make(map[string]string)
+	for _, l := range c.QueryArray("label") {
+		k, v, err := userlabels.Parse(l)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		labels[k] = v
+	}
+
+	resp, err := a.svc.ListBuilds(c.Request.Context(), labels)
+	if err == nil {
+		resp, err = a.visibleBuilds(c, resp)
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get build status
+// @ID getBuild
+// @Param Namespace
+// @Success 200 application/json {BuildResponse} Build status
+// @Failure 403 Access to builds is restricted and the build is not shared with the caller
+// @Failure 404 Not found
+// @Router /v1/builds/{name} [get]
+func (a *APIServer) handleGetBuild(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("get build", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.GetBuild(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Cancel a build
+// @Description Asks the operator to stop the build's TaskRun or upload server; the build then fails with a
+// @Description cancellation message.
+// @ID cancelBuild
+// @Param Namespace
+// @Success 200 application/json {BuildResponse} Cancellation requested
+// @Failure 404 Not found
+// @Failure 409 Build already finished
+// @Router /v1/builds/{name}/cancel [post]
+func (a *APIServer) handleCancelBuild(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("cancel build", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.CancelBuild(c.Request.Context(), name, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Update a pending build
+// @Description Changes the serving settings, user labels or registry credentials of a build before it starts
+// @Description building, e.g. while it waits for its input files.
+// @ID updateBuild
+// @Param Namespace
+// @Body application/json {UpdateBuildRequest}
+// @Success 200 application/json {BuildResponse} Build updated
+// @Failure 400 Invalid input, or a change to the build's distro, target or architecture
+// @Failure 404 Not found
+// @Failure 409 Build has already started
+// @Router /v1/builds/{name} [patch]
+func (a *APIServer) handleUpdateBuild(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("update build", "build", name, "reqID", c.GetString("reqID"))
+
+	var req UpdateBuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.UpdateBuild(c.Request.Context(), name, req, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Delete a build
+// @Description Deletes the build and its registry secret; its TaskRun, workspace, artifact pod and manifest
+// @Description ConfigMap are garbage collected with it. Outside the default namespace the caller must be allowed
+// @Description to delete imagebuilds.
+// @ID deleteBuild
+// @Param Namespace
+// @Param force query boolean optional If true, also delete a build that has not finished
+// @Success 200 application/json {BuildResponse} Build deleted
+// @Failure 404 Not found
+// @Failure 409 Build has not finished and force is not set
+// @Router /v1/builds/{name} [delete]
+func (a *APIServer) handleDeleteBuild(c *gin.Context) {
+	name := c.Param("name")
+	force := c.Query("force") == "true"
+	a.log.Info("delete build", "build", name, "force", force, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.DeleteBuild(c.Request.Context(), name, force)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get a build's inputs as a template
+// @ID getBuildTemplate
+// @Param Namespace
+// @Success 200 application/json {BuildTemplateResponse} Build template
+// @Failure 404 Not found
+// @Router /v1/builds/{name}/template [get]
+func (a *APIServer) handleGetBuildTemplate(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("template requested", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.GetBuildTemplate(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get a sanitized view of the build's TaskRun for debugging
+// @ID getBuildTaskRun
+// @Param Namespace
+// @Success 200 application/json {TaskRunResponse} TaskRun status
+// @Failure 404 Build or TaskRun not found
+// @Router /v1/builds/{name}/taskrun [get]
+func (a *APIServer) handleGetTaskRun(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("taskrun requested", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.GetTaskRun(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get the timeline of a build
+// @Description Lists what happened to the build, oldest first: its creation, uploads, TaskRun and steps,
+// @Description completion, artifact serving and expiry, together with the Kubernetes Events recorded for the
+// @Description build, its TaskRun, pods and workspace. Events expire from the cluster after a while, an hour
+// @Description by default.
+// @ID getBuildTimeline
+// @Param Namespace
+// @Success 200 application/json {BuildTimelineResponse} Build timeline
+// @Failure 404 Not found
+// @Router /v1/builds/{name}/timeline [get]
+func (a *APIServer) handleGetBuildTimeline(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("timeline requested", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.BuildTimeline(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get the resources the build step of a finished build used
+// @Description Peak memory and CPU time are read from the build step's cgroup when it finishes; compare them
+// @Description with the resources and memory volumes builds are given to right-size them.
+// @ID getBuildUsage
+// @Param Namespace
+// @Success 200 application/json {BuildUsageResponse} Resource usage
+// @Failure 404 Build not found, or it reported no resource usage
+// @Failure 409 Build has not finished
+// @Router /v1/builds/{name}/usage [get]
+func (a *APIServer) handleGetBuildUsage(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("usage requested", "build", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.BuildUsage(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Upload local files referenced by manifest
+// @Description A leading "manifest" part holding an UploadManifest maps the filenames of the file parts to their
+// @Description destinations, permissions and checksums, and lists directories to create; without it, the filename
+// @Description of each file part is its destination.
+// @Description Each file part may carry an X-Checksum-Sha256 part header with the hex SHA-256 of its content.
+// @Description Clients that cannot set part headers may instead send a trailing "checksums" part holding a JSON
+// @Description object that maps destination paths to checksums. The server checksums every file inside the
+// @Description upload pod after copying it. The files may be split across several requests; the build only
+// @Description proceeds once the client calls POST /v1/builds/{name}/uploads/complete after all of them succeeded.
+// @ID uploadFiles
+// @Param Namespace
+// @Body multipart/form-data {UploadForm}
+// @Success 200 application/json {UploadResponse} Upload complete
+// @Failure 400 application/json {UploadErrorResponse} Invalid upload or checksum mismatch; on a mismatch the per-file results are included
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads [post]
+func (a *APIServer) handleUploadFiles(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("uploads", "build", name, "reqID", c.GetString("reqID"))
+	a.limitUpload(c)
+
+	// The multipart body is only opened once the service has found the upload pod
+	var reader *multipart.Reader
+	next := func() (*UploadFile, error) {
+		if reader == nil {
+			r, err := c.Request.MultipartReader()
+			if err != nil {
+				return nil, newError(ErrInvalidInput, "invalid multipart: %v", err)
+			}
+			reader = r
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, newError(ErrInvalidInput, "read part: %v", err)
+			}
+			switch part.FormName() {
+			case "file":
+				return &UploadFile{Path: partFileName(part), Content: part, Sha256: part.Header.Get(ChecksumHeader)}, nil
+			case "checksums":
+				// a manifest part maps destination paths to checksums for clients that cannot set part headers
+				var sums map[string]string
+				if err := json.NewDecoder(part).Decode(&sums); err != nil {
+					return nil, newError(ErrInvalidInput, "invalid checksums part: %v", err)
+				}
+				if sums == nil {
+					sums = map[string]string{}
+				}
+				return &UploadFile{Checksums: sums}, nil
+			case "manifest":
+				var manifest UploadManifest
+				dec := json.NewDecoder(part)
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&manifest); err != nil {
+					return nil, newError(ErrInvalidInput, "invalid manifest part: %v", err)
+				}
+				return &UploadFile{Manifest: &manifest}, nil
+			}
+		}
+	}
+
+	resp, err := a.svc.UploadFiles(c.Request.Context(), name, next)
+	if err != nil {
+		if resp != nil {
+			c.JSON(statusForError(err), UploadErrorResponse{Error: err.Error(), Files: resp.Files})
+			return
+		}
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// partFileName returns the filename of a part as the client sent it; Part.FileName drops its directories
+func partFileName(part *multipart.Part) string {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		return part.FileName()
+	}
+	return params["filename"]
+}
+
+// @Summary Report how much of a file the workspace holds
+// @Description Lets a client resume an interrupted chunked upload; a file never uploaded has size 0.
+// @ID getUploadedFile
+// @Param Namespace
+// @Param path query string required Destination relative to the build's shared workspace
+// @Success 200 application/json {UploadedFile} Stored size
+// @Failure 400 Invalid destination path
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads/file [get]
+func (a *APIServer) handleGetUploadedFile(c *gin.Context) {
+	resp, err := a.svc.UploadedFile(c.Request.Context(), c.Param("name"), c.Query("path"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Start or resume the chunked upload of a file
+// @Description Announces the size and checksum of a file about to be uploaded in chunks, which lets uploads of
+// @Description large files through routes that close idle connections. The response tells how many bytes of that
+// @Description same file the workspace already holds, where the next chunk starts; a partial file left by an
+// @Description upload of other content is discarded. Send the chunks with PUT and finish with POST
+// @Description /v1/builds/{name}/uploads/file/finish.
+// @ID startUpload
+// @Param Namespace
+// @Param path query string required Destination relative to the build's shared workspace
+// @Body application/json {StartUploadRequest}
+// @Success 200 application/json {UploadedFile} Size already stored
+// @Failure 400 Invalid destination path, size or checksum
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads/file [post]
+func (a *APIServer) handleStartUpload(c *gin.Context) {
+	name := c.Param("name")
+	var req StartUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	a.log.Info("start upload", "build", name, "path", c.Query("path"), "size", req.Size, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.StartUpload(c.Request.Context(), name, c.Query("path"), req.Size, req.Sha256)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Upload one chunk of a file
+// @Description Writes the request body into the file at offset and drops anything stored after it, so a failed
+// @Description chunk can be sent again as is. Files may be uploaded in parallel; finish with POST
+// @Description /v1/builds/{name}/uploads/complete.
+// @ID writeUploadChunk
+// @Param Namespace
+// @Param path query string required Destination relative to the build's shared workspace
+// @Param offset query int64 optional default=0 minimum=0 Position of the chunk in the file
+// @Body application/octet-stream {binary}
+// @Success 200 application/json {UploadedFile} Size stored after the chunk
+// @Failure 400 Invalid destination path or offset
+// @Failure 409 Offset lies past the end of what the workspace holds
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads/file [put]
+func (a *APIServer) handleWriteUploadChunk(c *gin.Context) {
+	name := c.Param("name")
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	a.log.Info("upload chunk", "build", name, "path", c.Query("path"), "offset", offset, "reqID", c.GetString("reqID"))
+	a.limitUpload(c)
+
+	resp, err := a.svc.WriteUploadChunk(c.Request.Context(), name, c.Query("path"), offset, c.Request.Body)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Verify a file uploaded in chunks
+// @Description Checksums the file inside the upload pod. A file that does not match is discarded, so its next
+// @Description upload starts over. Files verified here may be left out of POST /v1/builds/{name}/uploads/complete.
+// @ID finishUpload
+// @Param Namespace
+// @Param path query string required Destination relative to the build's shared workspace
+// @Body application/json {FinishUploadRequest}
+// @Success 200 application/json {UploadFileResult} File verified
+// @Failure 400 application/json {UploadErrorResponse} Invalid request or checksum mismatch; on a mismatch the file's result is included
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads/file/finish [post]
+func (a *APIServer) handleFinishUpload(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("finish upload", "build", name, "path", c.Query("path"), "reqID", c.GetString("reqID"))
+
+	var req FinishUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.FinishUpload(c.Request.Context(), name, c.Query("path"), req.Sha256)
+	if err != nil {
+		if resp != nil {
+			c.JSON(statusForError(err), UploadErrorResponse{Error: err.Error(), Files: []UploadFileResult{*resp}})
+			return
+		}
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Announce the size of a build's uploads
+// @Description Records how many files and bytes the client is about to upload, so the build reports the progress
+// @Description of its uploads as a percentage. Clients announce the totals before they upload; builds whose
+// @Description client announced none report the received files and bytes only.
+// @ID setUploadTotals
+// @Param Namespace
+// @Body application/json {UploadTotalsRequest}
+// @Success 200 application/json {UploadProgress} Totals recorded
+// @Failure 400 Invalid request
+// @Failure 404 Not found
+// @Failure 409 Uploads already complete
+// @Router /v1/builds/{name}/uploads/totals [put]
+func (a *APIServer) handleSetUploadTotals(c *gin.Context) {
+	name := c.Param("name")
+
+	var req UploadTotalsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.SetUploadTotals(c.Request.Context(), name, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Verify uploads and let the build proceed
+// @Description Marks the uploads of a build complete, the only way to let a build waiting for local files
+// @Description proceed. The server first checksums every listed file inside the upload pod and refuses when any
+// @Description does not match; files already verified by a multipart upload or POST
+// @Description /v1/builds/{name}/uploads/file/finish may be left out, and an empty object completes without checking.
+// @ID completeUploads
+// @Param Namespace
+// @Body application/json {CompleteUploadsRequest}
+// @Success 200 application/json {UploadResponse} Upload complete
+// @Failure 400 application/json {UploadErrorResponse} Invalid request or checksum mismatch; on a mismatch the per-file results are included
+// @Failure 503 Upload pod not ready
+// @Router /v1/builds/{name}/uploads/complete [post]
+func (a *APIServer) handleCompleteUploads(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("complete uploads", "build", name, "reqID", c.GetString("reqID"))
+
+	var req CompleteUploadsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.CompleteUploads(c.Request.Context(), name, req.Files)
+	if err != nil {
+		if resp != nil {
+			c.JSON(statusForError(err), UploadErrorResponse{Error: err.Error(), Files: resp.Files})
+			return
+		}
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Describe this build API instance
+// @Description Reports the default namespace and the feature gates the AutomotiveDev turns on; changes to the
+// @Description gates show here without restarting the build API.
+// @ID getServerInfo
+// @Success 200 application/json {ServerInfoResponse} Server information
+// @Router /v1/info [get]
+func (a *APIServer) handleServerInfo(c *gin.Context) {
+	writeJSON(c, http.StatusOK, ServerInfoResponse{
+		DefaultNamespace: a.svc.DefaultNamespace(),
+		Features:         a.svc.EnabledFeatures(c.Request.Context()),
+	})
+}
+
+// @Summary Check manifests against the lint rules of the AutomotiveDev
+// @Description Rules come from the ConfigMap named by the AutomotiveDev buildConfig.lintRulesConfigMap. Without
+// @Description one, no violations are reported. Builds are checked the same way when they are created.
+// @ID lintManifests
+// @Body application/json {LintRequest}
+// @Success 200 application/json {LintResponse} Lint result
+// @Failure 400 Missing manifest or manifests that are not valid YAML
+// @Failure 503 The lint rules ConfigMap is missing or invalid
+// @Router /v1/lint [post]
+func (a *APIServer) handleLint(c *gin.Context) {
+	a.log.Info("lint manifests", "reqID", c.GetString("reqID"))
+
+	var req LintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.LintManifests(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary List the distros, targets, architectures and profiles builds may use
+// @ID getCatalog
+// @Success 200 application/json {CatalogResponse} Build catalog
+// @Router /v1/catalog [get]
+func (a *APIServer) handleGetCatalog(c *gin.Context) {
+	resp, err := a.svc.Catalog(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// defaultStatsWindow is the window /v1/stats summarizes when the request does not set one
+const defaultStatsWindow = 7 * 24 * time.Hour
+
+// @Summary Summarize the builds created within a time window
+// @ID getBuildStats
+// @Param Namespace
+// @Param window query string optional default=7d Window length in days (e.g. 7d) or as a Go duration (e.g. 36h)
+// @Success 200 application/json {BuildStatsResponse} Build statistics
+// @Failure 400 Invalid window
+// @Router /v1/stats [get]
+func (a *APIServer) handleGetStats(c *gin.Context) {
+	a.log.Info("build stats", "reqID", c.GetString("reqID"))
+
+	window := defaultStatsWindow
+	if v := c.Query("window"); v != "" {
+		w, err := parseWindow(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		window = w
+	}
+
+	resp, err := a.svc.BuildStats(c.Request.Context(), window)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Report the storage held by build workspaces
+// @Description Sums the live workspace PVCs of the namespace's builds, per namespace and per requester, and
+// @Description returns the AutomotiveDev's MaxWorkspaceStorage limit that new workspaces are checked against.
+// @ID getStorageQuota
+// @Param Namespace
+// @Success 200 application/json {StorageQuotaResponse} Workspace storage usage
+// @Router /v1/quota [get]
+func (a *APIServer) handleGetQuota(c *gin.Context) {
+	a.log.Info("storage quota", "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.StorageQuota(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Cancel builds abandoned while uploading
+// @Description Cancels the namespace's builds that have waited in the Uploading phase without receiving a file
+// @Description for longer than the AutomotiveDev's BuildConfig.UploadTimeoutMinutes (default 120), so the operator
+// @Description releases their workspace PVC and upload pod, and reports them. The build API also sweeps its own
+// @Description namespace periodically.
+// @ID sweepUploads
+// @Param Namespace
+// @Param dryRun query boolean optional If true, only report the builds that would be cancelled
+// @Success 200 application/json {SweepResponse} Builds cancelled
+// @Failure 403 Not allowed in the target namespace
+// @Router /v1/maintenance/sweep [post]
+func (a *APIServer) handleSweepUploads(c *gin.Context) {
+	dryRun := c.Query("dryRun") == "true"
+	a.log.Info("upload sweep", "dryRun", dryRun, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.SweepUploads(c.Request.Context(), a.resolveRequester(c), dryRun)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// parseWindow reads a positive duration given in days ("7d") or as a Go duration ("36h")
+func parseWindow(v string) (time.Duration, error) {
+	var d time.Duration
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid window %q: expected a number of days such as 7d or a duration such as 36h", v)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, fmt.Errorf("invalid window %q: expected a number of days such as 7d or a duration such as 36h", v)
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid window %q: must be positive", v)
+	}
+	return d, nil
+}
+
+// @Summary List images
+// @Description Lists the Images of the catalog, both those promoted from builds and registered external ones
+// @ID listImages
+// @Param Namespace
+// @Param distro query string optional Only list images of this distribution
+// @Param arch query string optional Only list images of this architecture
+// @Param tag query string optional Only list images carrying this tag
+// @Success 200 application/json {[]ImageResponse} Images sorted by name
+// @Router /v1/images [get]
+func (a *APIServer) handleListImages(c *gin.Context) {
+	a.log.Info("list images", "reqID", c.GetString("reqID"))
+
+	filter := ImageFilter{
+		Distro:       strings.TrimSpace(c.Query("distro")),
+		Architecture: strings.TrimSpace(c.Query("arch")),
+		Tag:          strings.TrimSpace(c.Query("tag")),
+	}
+	resp, err := a.svc.ListImages(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Get an image
+// @ID getImage
+// @Param Namespace
+// @Success 200 application/json {ImageResponse} Image
+// @Failure 404 Not found
+// @Router /v1/images/{name} [get]
+func (a *APIServer) handleGetImage(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("get image", "image", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.GetImage(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Register an external image
+// @Description Catalogs an image built outside the cluster that is already pushed to a registry. The image is
+// @Description not pulled or checked; it enters the catalog as a candidate.
+// @ID registerImage
+// @Param Namespace
+// @Body application/json {RegisterImageRequest}
+// @Success 201 application/json {ImageResponse} Image registered
+// @Failure 400 Invalid name, missing field or invalid registry reference
+// @Failure 409 An image with this name already exists
+// @Router /v1/images [post]
+func (a *APIServer) handleRegisterImage(c *gin.Context) {
+	var req RegisterImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	a.log.Info("register image", "image", req.Name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.RegisterImage(c.Request.Context(), req, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusCreated, resp)
+}
+
+// @Summary Delete an image
+// @Description Removes an Image from the catalog. The image itself is left in its registry.
+// @ID deleteImage
+// @Param Namespace
+// @Success 200 application/json {ImageResponse} Image deleted
+// @Failure 404 Not found
+// @Router /v1/images/{name} [delete]
+func (a *APIServer) handleDeleteImage(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("delete image", "image", name, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.DeleteImage(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Move an Image to a new lifecycle state
+// @ID setImageLifecycle
+// @Param Namespace
+// @Body application/json {ImageLifecycleRequest}
+// @Success 200 application/json {ImageLifecycleResponse} Lifecycle updated
+// @Failure 400 Invalid input
+// @Failure 404 Not found
+// @Failure 409 Transition not allowed
+// @Router /v1/images/{name}/lifecycle [post]
+func (a *APIServer) handleSetImageLifecycle(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("image lifecycle change", "image", name, "reqID", c.GetString("reqID"))
+
+	var req ImageLifecycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	resp, err := a.svc.SetImageLifecycle(c.Request.Context(), name, req, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
+
+// @Summary Promote a build's artifact to another namespace
+// @Description Pushes the artifact of a completed build to a registry and creates an Image for it in the target
+// @Description namespace, annotated with the namespace and build it was promoted from. The caller must be allowed
+// @Description to create images in the target namespace, and to read the registry secret there if one is named.
+// @ID promoteBuild
+// @Param Namespace
+// @Body application/json {PromoteRequest}
+// @Success 201 application/json {PromoteResponse} Artifact pushed and Image created
+// @Failure 400 Invalid target namespace, registry reference, image name or secret
+// @Failure 403 Not allowed in the target namespace, or the artifact is blocked by the scan policy
+// @Failure 404 Not found
+// @Failure 409 Build has not completed, or the Image already exists
+// @Failure 410 An Image produced by the build has been revoked
+// @Router /v1/builds/{name}/promote [post]
+func (a *APIServer) handlePromoteBuild(c *gin.Context) {
+	name := c.Param("name")
+
+	var req PromoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	a.log.Info("promote build", "build", name, "targetNamespace", req.TargetNamespace, "reqID", c.GetString("reqID"))
+
+	// the Image is created with the server's permissions, so the requester must hold them in the target
+	// namespace; invalid namespaces are rejected by the service
+	target := strings.TrimSpace(req.TargetNamespace)
+	if target != a.svc.DefaultNamespace() && len(validation.IsDNS1123Label(target)) == 0 {
+		checks := [][2]string{{"images", "create"}}
+		if strings.TrimSpace(req.Registry.SecretRef) != "" {
+			checks = append(checks, [2]string{"secrets", "get"})
+		}
+		for _, check := range checks {
+			allowed, err := a.reviewer.ReviewAccess(c.Request.Context(), bearerToken(c), target, check[0], check[1])
+			if err != nil {
+				a.log.Error(err, "access review failed", "namespace", target, "reqID", c.GetString("reqID"))
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "access review failed"})
+				return
+			}
+			if !allowed {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("not allowed to %s %s in namespace %s", check[1], check[0], target)})
+				return
+			}
+		}
+	}
+
+	resp, err := a.svc.PromoteBuild(c.Request.Context(), name, req, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusCreated, resp)
+}
+
+// @Summary Convert a build's artifact to another disk format
+// @Description Starts converting the served raw or qcow2 artifact of a completed build to qcow2, vmdk, vdi, vhdx
+// @Description or an Android sparse image (simg) in the cluster. The conversion runs in the background; its
+// @Description progress is returned by this endpoint when called again and in the conversions of the build. Once
+// @Description completed, the converted file is downloaded like the artifact and listed in its download info.
+// @ID convertArtifact
+// @Param Namespace
+// @Body application/json {ConvertRequest}
+// @Success 202 application/json {ArtifactConversion} Conversion requested, or its progress
+// @Failure 400 Unsupported format, or the artifact already has it
+// @Failure 403 The artifact is blocked by the scan policy
+// @Failure 404 Not found
+// @Failure 409 Build has not completed or does not serve its artifact
+// @Failure 410 An Image produced by the build has been revoked
+// @Failure 422 The artifact is not a disk image
+// @Router /v1/builds/{name}/convert [post]
+func (a *APIServer) handleConvertArtifact(c *gin.Context) {
+	name := c.Param("name")
+
+	var req ConvertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	a.log.Info("convert artifact", "build", name, "format", req.Format, "reqID", c.GetString("reqID"))
+
+	resp, err := a.svc.ConvertArtifact(c.Request.Context(), name, req, a.resolveRequester(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusAccepted, resp)
+}
+
+// @Summary List the parts of the build's artifact
+// @Description The first item is the artifact's <artifact>.metadata.json, if the build wrote one: a JSON object
+// @Description with the artifact's name, sizeBytes, sha256, contentType, compression, encryption, distro, target,
+// @Description architecture, exportFormat, buildName, created time, builderImage and builderDigest, and the name and
+// @Description contentType of each of its parts. contentType is the media type once decompressed and decrypted, e.g.
+// @Description application/x-android-sparse-image or application/x-android-boot-image. Items are downloaded from
+// @Description /v1/builds/{name}/artifacts/{file}; those of encrypted builds end with .enc.
+// @ID listArtifacts
+// @Param Namespace
+// @Success 200 application/json {ArtifactListResponse} Artifact metadata file and parts
+// @Failure 403 Access to builds is restricted and the build is not shared with the caller
+// @Failure 409 Build not completed
+// @Failure 503 Artifact pod not ready
+// @Router /v1/builds/{name}/artifacts [get]
+func (a *APIServer) handleListArtifacts(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("artifacts list requested", "build", name, "reqID", c.GetString("reqID"))
+
+	items, err := a.svc.ListArtifacts(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, ArtifactListResponse{Items: items})
+}
+
+// @Summary Download one part of the build's artifact
+// @ID downloadArtifactPart
+// @Param Namespace
+// @Success 200 application/octet-stream {binary} Artifact part stream, compressed unless the build's compression is none
+// @Header 200 X-AIB-Content-Type Media type of the part once decompressed and decrypted, e.g. application/x-android-sparse-image
+// @Failure 403 Access to builds is restricted and the build is not shared with the caller
+// @Failure 404 Part not found
+// @Failure 409 Build not completed
+// @Failure 503 Artifact pod not ready
+// @Router /v1/builds/{name}/artifacts/{file} [get]
+func (a *APIServer) handleStreamArtifactPart(c *gin.Context) {
+	name := c.Param("name")
+	file := c.Param("file")
+	a.log.Info("artifact item requested", "build", name, "file", file, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenArtifactPart(c.Request.Context(), name, file)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	setArtifactContentTypes(c, artifact)
+	c.Writer.Header().Set("X-AIB-Artifact-Type", "file")
+	c.Writer.Header().Set("X-AIB-Compression", artifactCompression(artifact.FileName))
+	a.streamArtifact(c, artifact)
+}
+
+// @Summary Download built artifact
+// @ID downloadArtifact
+// @Param Namespace
+// @Param filename path string required The build's artifactFileName
+// @Success 200 application/octet-stream {binary} Artifact stream
+// @Header 200 Content-Disposition Suggested filename for download
+// @Header 200 Content-Length Artifact size in bytes (when known)
+// @Header 200 X-AIB-Content-Type Media type of the file once decompressed and decrypted, as the build recorded it for its artifact
+// @Failure 403 Artifact is blocked by the scan policy, or access to builds is restricted and the build is not shared with the caller
+// @Failure 409 Build not completed
+// @Failure 410 text/plain {string} Image produced by this build has been revoked
+// @Failure 503 text/plain {string} Artifact pod not ready
+// @Router /v1/builds/{name}/artifact/{filename} [get]
+func (a *APIServer) handleStreamArtifactByFilename(c *gin.Context) {
+	name := c.Param("name")
+	filename := c.Param("filename")
+	a.log.Info("artifact by filename requested", "build", name, "filename", filename, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenArtifactByFilename(c.Request.Context(), name, filename)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	setArtifactContentTypes(c, artifact)
+	a.streamArtifact(c, artifact)
+}
+
+// @Summary Download all build outputs as a single tar archive
+// @ID downloadArtifactsTar
+// @Param Namespace
+// @Success 200 application/x-tar {binary} Tar stream of the build workspace outputs, generated on the fly
+// @Header 200 Content-Disposition Suggested filename for download
+// @Failure 403 Artifact is blocked by the scan policy, or access to builds is restricted and the build is not shared with the caller
+// @Failure 409 Build not completed
+// @Failure 410 Image produced by this build has been revoked
+// @Failure 503 Artifact pod not ready
+// @Router /v1/builds/{name}/artifacts.tar [get]
+func (a *APIServer) handleStreamArtifactsTar(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("artifacts tar requested", "build", name, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenArtifactsTar(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/x-tar")
+	c.Writer.Header().Set("X-AIB-Artifact-Type", "archive")
+	a.streamArtifact(c, artifact)
+}
+
+// @Summary Download the workspace a failed build kept for debugging
+// @Description The archive holds the whole shared workspace; the build directory logs are under _build/.
+// @ID downloadWorkspaceTar
+// @Param Namespace
+// @Success 200 application/x-tar {binary} Tar stream of the kept workspace, generated on the fly
+// @Header 200 Content-Disposition Suggested filename for download
+// @Failure 404 Build not found, or its workspace was not kept or has expired
+// @Failure 409 Build has not failed
+// @Failure 503 Artifact pod not ready
+// @Router /v1/builds/{name}/workspace.tar [get]
+func (a *APIServer) handleStreamWorkspaceTar(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("workspace tar requested", "build", name, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenWorkspaceTar(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/x-tar")
+	c.Writer.Header().Set("X-AIB-Artifact-Type", "archive")
+	a.streamArtifact(c, artifact)
+}
+
+// @Summary Download the vulnerability scan report of a build
+// @Description The scanner's JSON report; it stays available when the scan policy blocks the artifact.
+// @ID downloadScanReport
+// @Param Namespace
+// @Success 200 application/json {object} Scan report
+// @Failure 404 Build not found, or it was not scanned
+// @Failure 409 Build not completed
+// @Failure 503 Artifact pod not ready
+// @Router /v1/builds/{name}/scan-report [get]
+func (a *APIServer) handleStreamScanReport(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("scan report requested", "build", name, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenScanReport(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/json")
+	a.streamArtifact(c, artifact)
+}
+
+// @Summary Download the logs of a finished build
+// @Description The archive holds a <step>.log file per step, read from the build's pod while Tekton keeps it.
+// @ID downloadLogsArchive
+// @Param Namespace
+// @Success 200 application/gzip {binary} Tar.gz stream of the step logs, generated on the fly
+// @Header 200 Content-Disposition Suggested filename for download
+// @Failure 404 Build not found, or it never started or its pod was removed
+// @Failure 409 Build has not finished
+// @Router /v1/builds/{name}/logs/archive [get]
+func (a *APIServer) handleStreamLogsArchive(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("logs archive requested", "build", name, "reqID", c.GetString("reqID"))
+
+	artifact, err := a.svc.OpenLogsArchive(c.Request.Context(), name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Writer.Header().Set("Content-Type", "application/gzip")
+	a.streamArtifact(c, artifact)
+}
+
+// artifactContentTypes returns the Content-Type an artifact file is sent with, that of its compression or
+// encryption when it has any, and the media type of its payload, recorded by the build or derived from the
+// file name
+func artifactContentTypes(artifact *Artifact) (string, string) {
+	payload := artifact.ContentType
+	if payload == "" {
+		payload = artifacttype.ForFile(artifact.FileName)
+	}
+	lower := strings.ToLower(artifact.FileName)
+	switch {
+	case strings.HasSuffix(lower, ".enc"):
+		return artifacttype.OctetStream, payload
+	case strings.HasSuffix(lower, ".lz4"):
+		return "application/x-lz4", payload
+	case strings.HasSuffix(lower, ".gz"):
+		return "application/gzip", payload
+	case strings.HasSuffix(lower, ".xz"):
+		return "application/x-xz", payload
+	default:
+		return payload, payload
+	}
+}
+
+// setArtifactContentTypes sets the Content-Type of an artifact file and, in X-AIB-Content-Type, the media
+// type of its payload once decompressed and decrypted
+func setArtifactContentTypes(c *gin.Context, artifact *Artifact) {
+	contentType, payload := artifactContentTypes(artifact)
+	c.Writer.Header().Set("Content-Type", contentType)
+	c.Writer.Header().Set("X-AIB-Content-Type", payload)
+}
+
+// artifactCompression names the compression of an artifact file by its name, for the X-AIB-Compression header
+func artifactCompression(fileName string) string {
+	lower := strings.ToLower(fileName)
+	switch {
+	case strings.HasSuffix(lower, ".lz4"):
+		return "lz4"
+	case strings.HasSuffix(lower, ".gz"):
+		return "gzip"
+	default:
+		return "none"
+	}
+}
+
+// streamArtifact writes the download headers and copies the artifact to the response
+func (a *APIServer) streamArtifact(c *gin.Context, artifact *Artifact) {
+	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", artifact.FileName))
+	if artifact.Size != "" {
+		c.Writer.Header().Set("Content-Length", artifact.Size)
+	}
+	if f, ok := c.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	_ = artifact.WriteTo(c.Request.Context(), a.limitDownload(c, c.Writer))
+}
+
+// @Summary Stream build logs
+// @ID streamLogs
+// @Param Namespace
+// @Param step query string optional Start at this step, skipping the logs of earlier steps
+// @Param sinceBytes query int64 optional minimum=0 Skip this many bytes of the first streamed step, to resume an interrupted stream
+// @Param sinceTime query date-time optional Only return log lines written at or after this RFC 3339 time
+// @Param tail query int64 optional minimum=0 Only return the last N lines of each step
+// @Param heartbeat query boolean optional If true, write a NUL byte whenever the stream was quiet for 15 seconds, so proxies keep it open; the client must drop NUL bytes
+// @Success 200 text/plain {string} Log stream
+// @Failure 400 Invalid log options
+// @Failure 403 Access to builds is restricted and the build is not shared with the caller
+// @Failure 503 text/plain {string} Logs not available yet
+// @Router /v1/builds/{name}/logs [get]
+func (a *APIServer) handleStreamLogs(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("logs requested", "build", name, "reqID", c.GetString("reqID"))
+
+	opts, err := parseLogOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	podName, err := a.svc.LogPod(ctx, name)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// Set up streaming response
+	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+
+	c.Writer.WriteHeader(http.StatusOK)
+	var heartbeat func(gin.ResponseWriter)
+	if heartbeatRequested, _ := strconv.ParseBool(c.Query("heartbeat")); heartbeatRequested {
+		heartbeat = func(w gin.ResponseWriter) { _, _ = w.Write([]byte{0}) }
+	}
+	sw := newStreamWriter(c.Writer, heartbeat)
+	defer sw.stop()
+	sw.write(func(w gin.ResponseWriter) { _, _ = w.Write([]byte("Waiting for logs...\n")) })
+
+	err = a.svc.FollowLogs(ctx, podName, opts, &textLogSink{sw: sw})
+	sw.stop()
+	switch {
+	case err == nil:
+		_, _ = c.Writer.Write([]byte("\n[Log streaming completed]\n"))
+		c.Writer.Flush()
+	case errors.Is(err, ErrNotReady):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case ctx.Err() == nil:
+		fmt.Fprintf(c.Writer, "\n[Error: %v]\n", err)
+		c.Writer.Flush()
+	}
+}
+
+// parseLogOptions reads the step, sinceBytes, sinceTime and tail query parameters of a logs request
+func parseLogOptions(c *gin.Context) (LogOptions, error) {
+	opts := LogOptions{Step: strings.TrimSpace(c.Query("step"))}
+	if v := c.Query("sinceBytes"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid sinceBytes %q: must be a non-negative integer", v)
+		}
+		opts.SinceBytes = n
+	}
+	if v := c.Query("sinceTime"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid sinceTime %q: must be an RFC 3339 timestamp", v)
+		}
+		opts.SinceTime = &metav1.Time{Time: t}
+	}
+	if v := c.Query("tail"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid tail %q: must be a non-negative integer", v)
+		}
+		opts.TailLines = &n
+	}
+	return opts, nil
+}
+
+// textLogSink renders build logs as plain text with a banner per step
+type textLogSink struct {
+	sw *streamWriter
+}
+
+func (s *textLogSink) StepStarted(step string) {
+	s.sw.write(func(w gin.ResponseWriter) { _, _ = w.Write([]byte("\n===== Logs from " + step + " =====\n\n")) })
+}
+
+func (s *textLogSink) Write(_ string, p []byte) error {
+	var err error
+	s.sw.write(func(w gin.ResponseWriter) { _, err = w.Write(p) })
+	return err
+}
+
+func (s *textLogSink) StreamError(_ string, err error) {
+	s.sw.write(func(w gin.ResponseWriter) { _, _ = w.Write(fmt.Appendf(nil, "\n[Stream error: %v]\n", err)) })
+}
+
+func (s *textLogSink) Waiting() {
+	// keep-alive to prevent router/proxy 504s while waiting
+	s.sw.write(func(w gin.ResponseWriter) { _, _ = w.Write([]byte(".")) })
+}
+
+// @Summary Stream build logs as server-sent events
+// @Description Follows the logs of a build as "log" events, one per line, for browsers. EventSource cannot send
+// @Description an Authorization header, so browsers reach the route through the OAuth proxy of the build-api-ui
+// @Description Route, which forwards their token. Progress is reported as connected, waiting, step, ping,
+// @Description message, error, completed and disconnected events; ping events are sent whenever the stream was
+// @Description quiet for 15 seconds, so proxies keep it open. The stream asks EventSource to reconnect after 5
+// @Description seconds; a reconnected stream starts over, unless step and sinceBytes say where to resume.
+// @ID streamLogsSSE
+// @Param step query string optional Start at this step, skipping the logs of earlier steps
+// @Param sinceBytes query int64 optional minimum=0 Skip this many bytes of the first streamed step, to resume an interrupted stream
+// @Param sinceTime query date-time optional Only return log lines written at or after this RFC 3339 time
+// @Param tail query int64 optional minimum=0 Only return the last N lines of each step
+// @Success 200 text/event-stream {string} Event stream
+// @Failure 400 Invalid log options
+// @Failure 403 Access to builds is restricted and the build is not shared with the caller
+// @Router /v1/builds/{name}/logs/sse [get]
+func (a *APIServer) handleStreamLogsSSE(c *gin.Context) {
+	name := c.Param("name")
+	a.log.Info("logs SSE requested", "build", name, "reqID", c.GetString("reqID"))
+
+	opts, err := parseLogOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+	c.Writer.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	ctx := c.Request.Context()
+	podName, err := a.svc.LogPod(ctx, name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			sendSSEEvent(c, "message", "", "ERROR: Build not found")
+		case errors.Is(err, ErrNotReady):
+			sendSSEEvent(c, "waiting", "", "Build not started yet, waiting for logs...")
+		default:
+			sendSSEEvent(c, "message", "", fmt.Sprintf("ERROR: Build lookup error: %v", err))
+		}
+		c.Writer.Flush()
+		return
+	}
+
+	_, _ = c.Writer.WriteString(fmt.Sprintf("retry: %d\n\n", sseRetry.Milliseconds()))
+	sendSSEEvent(c, "connected", "", "Log stream connected")
+	c.Writer.Flush()
+
+	sw := newStreamWriter(c.Writer, func(gin.ResponseWriter) { sendSSEEvent(c, "ping", "", "") })
+	defer sw.stop()
+	sink := &sseLogSink{c: c, sw: sw}
+	err = a.svc.FollowLogs(ctx, podName, opts, sink)
+	sw.stop()
+	switch {
+	case ctx.Err() != nil:
+		sendSSEEvent(c, "disconnected", "", "Connection closed")
+	case err == nil:
+		sendSSEEvent(c, "completed", "", "Log streaming completed")
+	case errors.Is(err, ErrNotReady):
+		sendSSEEvent(c, "error", "", err.Error())
+	default:
+		sendSSEEvent(c, "error", "", fmt.Sprintf("Error: %v", err))
+	}
+	c.Writer.Flush()
+}
+
+// sseLogSink renders build logs as server-sent events, one "log" event per line
+type sseLogSink struct {
+	c          *gin.Context
+	sw         *streamWriter
+	lineBuffer strings.Builder
+}
+
+func (s *sseLogSink) StepStarted(step string) {
+	s.lineBuffer.Reset()
+	s.sw.write(func(gin.ResponseWriter) { sendSSEEvent(s.c, "step", step, "===== Logs from "+step+" =====") })
+}
+
+func (s *sseLogSink) Write(step string, p []byte) error {
+	s.lineBuffer.Write(p)
+
+	lines := strings.Split(s.lineBuffer.String(), "\n")
+	s.lineBuffer.Reset()
+
+	if len(lines) > 1 {
+		s.lineBuffer.WriteString(lines[len(lines)-1])
+		lines = lines[:len(lines)-1]
+	}
+
+	s.sw.write(func(gin.ResponseWriter) {
+		for _, line := range lines {
+			if strings.TrimSpace(line) != "" {
+				sendSSEEvent(s.c, "log", step, line)
+			}
+		}
+	})
+	return nil
+}
+
+func (s *sseLogSink) StreamError(step string, err error) {
+	s.sw.write(func(gin.ResponseWriter) { sendSSEEvent(s.c, "error", step, fmt.Sprintf("Stream error: %v", err)) })
+}
+
+func (s *sseLogSink) Waiting() {
+	s.sw.write(func(gin.ResponseWriter) { sendSSEEvent(s.c, "waiting", "", "Waiting for logs...") })
+}
+
+func sendSSEEvent(c *gin.Context, event, step, data string) {
+	if event != "" {
+		c.Writer.WriteString("event: " + event + "\n")
+	}
+	if step != "" {
+		c.Writer.WriteString("id: " + step + "\n")
+	}
+	if data != "" {
+		escapedData := strings.ReplaceAll(data, "\n", "\\n")
+		c.Writer.WriteString("data: " + escapedData + "\n")
+	}
+	c.Writer.WriteString("\n")
+}
+
+// sseRetry is how long EventSource clients wait before reconnecting a dropped log stream
+const sseRetry = 5 * time.Second
+
+// logHeartbeatInterval is how long a log stream may stay quiet before it gets a heartbeat, well below the idle
+// timeouts of common proxies and load balancers; OpenShift's router closes connections quiet for 30 seconds
+var logHeartbeatInterval = 15 * time.Second
+
+// streamWriter serializes the writes to a streaming response and flushes each of them. Given a heartbeat, it
+// also writes that whenever the response was quiet for logHeartbeatInterval, until stopped.
+type streamWriter struct {
+	mu   sync.Mutex
+	w    gin.ResponseWriter
+	last time.Time
+
+	done     chan struct{}
+	stopped  sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func newStreamWriter(w gin.ResponseWriter, heartbeat func(gin.ResponseWriter)) *streamWriter {
+	sw := &streamWriter{w: w, last: time.Now(), done: make(chan struct{})}
+	if heartbeat != nil {
+		sw.stopped.Add(1)
+		go sw.beat(heartbeat)
+	}
+	return sw
+}
+
+// write calls fn with the response and flushes what it wrote
+func (sw *streamWriter) write(fn func(gin.ResponseWriter)) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	fn(sw.w)
+	sw.w.Flush()
+	sw.last = time.Now()
+}
+
+func (sw *streamWriter) beat(heartbeat func(gin.ResponseWriter)) {
+	defer sw.stopped.Done()
+	ticker := time.NewTicker(logHeartbeatInterval / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sw.done:
+			return
+		case <-ticker.C:
+			sw.mu.Lock()
+			quiet := time.Since(sw.last) >= logHeartbeatInterval
+			sw.mu.Unlock()
+			if quiet {
+				sw.write(heartbeat)
+			}
+		}
+	}
+}
+
+// stop ends the heartbeats; the response may only be written directly once it returned
+func (sw *streamWriter) stop() {
+	sw.stopOnce.Do(func() { close(sw.done) })
+	sw.stopped.Wait()
+}
+
+// maxGitHookPayload is the largest push event accepted; GitHub caps its payloads at 25 MB
+const maxGitHookPayload = 25 << 20
+
+// @Summary Start builds from a Git push
+// @Description Receives the push events, tag pushes included, of GitHub and GitLab webhooks and starts a build for
+// @Description every trigger of the AutomotiveDev's gitHooks matching the repository and the pushed branch or tag.
+// @Description Deliveries are authenticated with the gitHooks secret instead of a bearer token: GitHub signs them
+// @Description with it, GitLab sends it as the token. Builds reuse the inputs of the trigger's template build and
+// @Description get the pushed commit as --define GIT_COMMIT=<sha>. Redeliveries return the builds the first
+// @Description delivery started; other events are acknowledged without starting any.
+// @ID gitHook
+// @Param X-GitHub-Event header string optional GitHub event type, e.g. push
+// @Param X-GitHub-Delivery header string optional GitHub delivery ID, shared by redeliveries
+// @Param X-Hub-Signature-256 header string optional GitHub HMAC-SHA256 signature of the body
+// @Param X-Gitlab-Event header string optional GitLab event type, e.g. Push Hook or Tag Push Hook
+// @Param X-Gitlab-Event-UUID header string optional GitLab event ID
+// @Param X-Gitlab-Token header string optional GitLab secret token
+// @Body application/json {binary}
+// @Success 200 application/json {GitHookResponse} Builds started, or why none was
+// @Failure 400 Neither a GitHub nor a GitLab delivery, or a malformed push event
+// @Failure 403 Invalid signature or token
+// @Failure 404 Git hooks are not configured, or a trigger's template build does not exist
+// @Failure 422 A trigger's template build uploads local files, or its namespace is invalid
+// @Router /v1/hooks/git [post]
+func (a *APIServer) handleGitHook(c *gin.Context) {
+	hook := GitHook{}
+	switch {
+	case c.GetHeader("X-GitHub-Event") != "":
+		hook.Provider = gitstatus.GitHub
+		hook.Event = c.GetHeader("X-GitHub-Event")
+		hook.Delivery = c.GetHeader("X-GitHub-Delivery")
+		hook.Signature = c.GetHeader("X-Hub-Signature-256")
+	case c.GetHeader("X-Gitlab-Event") != "":
+		hook.Provider = gitstatus.GitLab
+		hook.Event = c.GetHeader("X-Gitlab-Event")
+		// retries of a delivery share the Idempotency-Key of recent GitLab versions
+		hook.Delivery = c.GetHeader(IdempotencyKeyHeader)
+		if hook.Delivery == "" {
+			hook.Delivery = c.GetHeader("X-Gitlab-Event-UUID")
+		}
+		hook.Token = c.GetHeader("X-Gitlab-Token")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not a GitHub or GitLab webhook delivery"})
+		return
+	}
+	a.log.Info("git hook", "provider", hook.Provider, "event", hook.Event, "delivery", hook.Delivery, "reqID", c.GetString("reqID"))
+
+	payload, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxGitHookPayload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("error reading body: %v", err)})
+		return
+	}
+	hook.Payload = payload
+
+	resp, err := a.svc.TriggerGitBuilds(c.Request.Context(), hook)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeJSON(c, http.StatusOK, resp)
+}
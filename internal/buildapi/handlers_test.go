@@ -0,0 +1,554 @@
+package buildapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	authnv1 "k8s.io/api/authentication/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
+)
+
+// fakeReviewer accepts a single token, whose holder may access the namespaces in allowed
+type fakeReviewer struct {
+	token   string
+	user    string
+	groups  []string
+	allowed map[string]bool
+}
+
+func (f *fakeReviewer) ReviewToken(_ context.Context, token string) (authnv1.UserInfo, bool, error) {
+	if token != f.token {
+		return authnv1.UserInfo{}, false, nil
+	}
+	return authnv1.UserInfo{Username: f.user, Groups: f.groups}, true, nil
+}
+
+func (f *fakeReviewer) ReviewAccess(_ context.Context, token, namespace, _, _ string) (bool, error) {
+	return token == f.token && f.allowed[namespace], nil
+}
+
+// fakeDiscoverer serves the resources in served by group version, and 404s for the rest
+type fakeDiscoverer struct {
+	served map[string][]string
+}
+
+func (f *fakeDiscoverer) ServedResources(_ context.Context, groupVersion string) ([]string, error) {
+	resources, ok := f.served[groupVersion]
+	if !ok {
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Group: groupVersion}, "")
+	}
+	return resources, nil
+}
+
+// fakeBuildService implements the BuildService methods the tests use; the rest panic via the nil embedded interface
+type fakeBuildService struct {
+	BuildService
+	builds      map[string]*BuildResponse
+	created     *BuildRequest
+	requestedBy string
+	listLabels  map[string]string
+	namespace   string
+	logOpts     *LogOptions
+	// logQuiet is how long FollowLogs waits before writing its only log line
+	logQuiet    time.Duration
+	statsWindow time.Duration
+	promoted    *PromoteRequest
+	gitHook     *GitHook
+	features    []string
+	access      *automotivev1.BuildAccess
+	createCtx   context.Context
+}
+
+func (f *fakeBuildService) DefaultNamespace() string {
+	return "builds"
+}
+
+func (f *fakeBuildService) EnabledFeatures(context.Context) []string {
+	return f.features
+}
+
+func (f *fakeBuildService) AccessPolicy(context.Context) (*automotivev1.BuildAccess, error) {
+	return f.access, nil
+}
+
+func (f *fakeBuildService) ListBuilds(_ context.Context, labels map[string]string) ([]BuildListItem, error) {
+	f.listLabels = labels
+	items := []BuildListItem{}
+	for _, b := range f.builds {
+		items = append(items, BuildListItem{Name: b.Name, Phase: b.Phase, RequestedBy: b.RequestedBy, AccessGroups: b.AccessGroups})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func (f *fakeBuildService) GetBuild(ctx context.Context, name string) (*BuildResponse, error) {
+	f.namespace = k8s.NamespaceFrom(ctx)
+	if b, ok := f.builds[name]; ok {
+		return b, nil
+	}
+	return nil, newError(ErrNotFound, "not found")
+}
+
+func (f *fakeBuildService) BuildStats(_ context.Context, window time.Duration) (*BuildStatsResponse, error) {
+	f.statsWindow = window
+	return &BuildStatsResponse{Window: window.String()}, nil
+}
+
+func (f *fakeBuildService) LogPod(_ context.Context, name string) (string, error) {
+	return name + "-pod", nil
+}
+
+func (f *fakeBuildService) FollowLogs(_ context.Context, _ string, opts LogOptions, sink LogSink) error {
+	f.logOpts = &opts
+	if f.logQuiet > 0 {
+		time.Sleep(f.logQuiet)
+		sink.StepStarted("build")
+		return sink.Write("build", []byte("built\n"))
+	}
+	return nil
+}
+
+func (f *fakeBuildService) CreateBuild(ctx context.Context, req BuildRequest, requestedBy string) (*BuildResponse, error) {
+	if req.Name == "" {
+		return nil, newError(ErrInvalidInput, "name and manifest are required")
+	}
+	f.created = &req
+	f.createCtx = ctx
+	f.requestedBy = requestedBy
+	if req.IdempotencyKey == "retried" {
+		return &BuildResponse{Name: "existing", Phase: "Building", Replayed: true}, nil
+	}
+	return &BuildResponse{Name: req.Name, Phase: "Building", RequestedBy: requestedBy}, nil
+}
+
+func (f *fakeBuildService) PromoteBuild(_ context.Context, name string, req PromoteRequest, requestedBy string) (*PromoteResponse, error) {
+	f.promoted = &req
+	return &PromoteResponse{Image: name, Namespace: req.TargetNamespace, PromotedBy: requestedBy}, nil
+}
+
+// OpenArtifactByFilename serves the sparse image artifact of the "android" build, as the build recorded its type
+func (f *fakeBuildService) OpenArtifactByFilename(_ context.Context, name, filename string) (*Artifact, error) {
+	content := "image"
+	if filename == "large.raw" {
+		content = strings.Repeat("x", 40<<10)
+	}
+	artifact := &Artifact{FileName: filename, stream: func(_ context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, content)
+		return err
+	}}
+	if filename == "ridesx4.img.gz" {
+		artifact.ContentType = "application/x-android-sparse-image"
+	}
+	return artifact, nil
+}
+
+func (f *fakeBuildService) OpenArtifactPart(ctx context.Context, name, file string) (*Artifact, error) {
+	return f.OpenArtifactByFilename(ctx, name, file)
+}
+
+func (f *fakeBuildService) TriggerGitBuilds(_ context.Context, hook GitHook) (*GitHookResponse, error) {
+	f.gitHook = &hook
+	return &GitHookResponse{Builds: []GitHookBuild{}}, nil
+}
+
+var _ = Describe("Handlers", func() {
+	var (
+		server *APIServer
+		svc    *fakeBuildService
+	)
+
+	BeforeEach(func() {
+		gin.SetMode(gin.TestMode)
+		svc = &fakeBuildService{builds: map[string]*BuildResponse{
+			"existing": {Name: "existing", Phase: "Completed"},
+		}}
+		server = NewAPIServerWithService(":0", logr.Discard(), svc, &fakeReviewer{token: "good", user: "alice", allowed: map[string]bool{"team-a": true}})
+	})
+
+	doIn := func(namespace, method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer good")
+		if namespace != "" {
+			req.Header.Set(NamespaceHeader, namespace)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		return doIn("", method, path, body)
+	}
+
+	It("should reject tokens the reviewer does not accept", func() {
+		req, _ := http.NewRequest("GET", "/v1/builds/existing", nil)
+		req.Header.Set("Authorization", "Bearer bad")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should serve the web UI's calls with the token the OAuth proxy forwards", func() {
+		// the UI sends no Authorization header; the proxy behind the build-api-ui Route adds the session's token
+		viaProxy := func(path, token string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", path, nil)
+			req.Header.Set("Cookie", "_oauth_proxy=session")
+			if token != "" {
+				req.Header.Set("X-Forwarded-Access-Token", token)
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			return w
+		}
+
+		Expect(viaProxy("/ui/", "good").Code).To(Equal(http.StatusOK))
+		Expect(viaProxy("/v1/builds", "good").Code).To(Equal(http.StatusOK))
+		Expect(viaProxy("/v1/builds/existing", "good").Code).To(Equal(http.StatusOK))
+		Expect(viaProxy("/v1/builds/existing", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(viaProxy("/v1/builds/existing", "bad").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should answer 503 with remediation hints while the CRDs or Tekton are missing", func() {
+		discoverer := &fakeDiscoverer{served: map[string][]string{
+			"automotive.sdv.cloud.redhat.com/v1": {"imagebuilds", "automotivedevs"},
+		}}
+		server.readiness = newReadinessGate(discoverer, logr.Discard())
+
+		// until the first check finished requests go through
+		Expect(do("GET", "/v1/builds/existing", "").Code).To(Equal(http.StatusOK))
+		req, _ := http.NewRequest("GET", "/v1/readyz", nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+
+		server.readiness.check(context.Background())
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		var ready ReadyzResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &ready)).To(Succeed())
+		Expect(ready.Ready).To(BeFalse())
+		Expect(ready.CheckedAt).NotTo(BeEmpty())
+		Expect(ready.Dependencies).To(HaveLen(2))
+		Expect(ready.Dependencies[0].Missing).To(Equal([]string{"images"}))
+		Expect(ready.Dependencies[0].Remediation).To(ContainSubstring("make install"))
+		Expect(ready.Dependencies[1].Missing).To(Equal([]string{"taskruns", "pipelineruns"}))
+		Expect(ready.Dependencies[1].Remediation).To(ContainSubstring("Pipelines"))
+
+		w = do("GET", "/v1/builds", "")
+		Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(w.Header().Get("Retry-After")).To(Equal("60"))
+		var body struct {
+			Error        string             `json:"error"`
+			Dependencies []DependencyStatus `json:"dependencies"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.Error).To(ContainSubstring("Automotive Dev CRDs, Tekton Pipelines missing"))
+		Expect(body.Dependencies).To(HaveLen(2))
+
+		discoverer.served["automotive.sdv.cloud.redhat.com/v1"] = []string{"imagebuilds", "automotivedevs", "images"}
+		discoverer.served["tekton.dev/v1"] = []string{"tasks", "taskruns", "pipelineruns"}
+		server.readiness.check(context.Background())
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(do("GET", "/v1/builds/existing", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should only show restricted builds to their requester, the groups they are shared with and admins", func() {
+		svc.builds = map[string]*BuildResponse{
+			"own":     {Name: "own", Phase: "Completed", RequestedBy: "alice"},
+			"team":    {Name: "team", Phase: "Completed", RequestedBy: "bob", AccessGroups: []string{"infotainment"}},
+			"other":   {Name: "other", Phase: "Completed", RequestedBy: "carol", AccessGroups: []string{"adas"}},
+			"android": {Name: "android", Phase: "Completed", RequestedBy: "carol", ArtifactFileName: "ridesx4.img.gz"},
+		}
+		server = NewAPIServerWithService(":0", logr.Discard(), svc, &fakeReviewer{token: "good", user: "alice", groups: []string{"system:authenticated", "infotainment"}})
+
+		// without a policy everyone sees every build
+		Expect(do("GET", "/v1/builds/other", "").Code).To(Equal(http.StatusOK))
+
+		svc.access = &automotivev1.BuildAccess{Restricted: true, AdminGroups: []string{"release"}}
+		Expect(do("GET", "/v1/builds/own", "").Code).To(Equal(http.StatusOK))
+		Expect(do("GET", "/v1/builds/team", "").Code).To(Equal(http.StatusOK))
+		w := do("GET", "/v1/builds/other", "")
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(w.Body.String()).To(ContainSubstring("only accessible to carol"))
+		Expect(do("GET", "/v1/builds/other/logs", "").Code).To(Equal(http.StatusForbidden))
+		Expect(do("GET", "/v1/builds/android/artifact/ridesx4.img.gz", "").Code).To(Equal(http.StatusForbidden))
+		Expect(do("GET", "/v1/builds/missing", "").Code).To(Equal(http.StatusNotFound))
+
+		w = do("GET", "/v1/builds", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var items []BuildListItem
+		Expect(json.Unmarshal(w.Body.Bytes(), &items)).To(Succeed())
+		names := []string{}
+		for _, b := range items {
+			names = append(names, b.Name)
+		}
+		Expect(names).To(Equal([]string{"own", "team"}))
+
+		server = NewAPIServerWithService(":0", logr.Discard(), svc, &fakeReviewer{token: "good", user: "dave", groups: []string{"release"}})
+		Expect(do("GET", "/v1/builds/other", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("should authenticate log streams and authorize their namespace and build in both access modes", func() {
+		svc.builds = map[string]*BuildResponse{
+			"own":   {Name: "own", Phase: "Building", RequestedBy: "alice"},
+			"other": {Name: "other", Phase: "Building", RequestedBy: "carol"},
+		}
+		// EventSource sends no headers of its own, so the token comes from the OAuth proxy
+		stream := func(name, token, namespace string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/v1/builds/"+name+"/logs/sse", nil)
+			if token != "" {
+				req.Header.Set("X-Forwarded-Access-Token", token)
+			}
+			if namespace != "" {
+				req.Header.Set(NamespaceHeader, namespace)
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			return w
+		}
+
+		By("without a policy")
+		Expect(stream("other", "", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(stream("other", "bad", "").Code).To(Equal(http.StatusUnauthorized))
+		w := stream("other", "good", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+		Expect(stream("other", "good", "team-a").Code).To(Equal(http.StatusOK))
+		Expect(stream("other", "good", "team-b").Code).To(Equal(http.StatusForbidden))
+
+		By("with restricted access")
+		svc.access = &automotivev1.BuildAccess{Restricted: true}
+		Expect(stream("own", "", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(stream("own", "good", "").Code).To(Equal(http.StatusOK))
+		Expect(stream("other", "good", "").Code).To(Equal(http.StatusForbidden))
+		Expect(stream("own", "good", "team-b").Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should pass the requester's groups to the build it creates", func() {
+		server = NewAPIServerWithService(":0", logr.Discard(), svc, &fakeReviewer{token: "good", user: "alice", groups: []string{"system:authenticated", "infotainment"}})
+		w := do("POST", "/v1/builds", `{"name":"b1","manifest":"x","accessGroups":["adas"]}`)
+		Expect(w.Code).To(Equal(http.StatusAccepted))
+		Expect(svc.requestedBy).To(Equal("alice"))
+		Expect(svc.created.AccessGroups).To(Equal([]string{"adas"}))
+		Expect(requesterGroupsFrom(svc.createCtx)).To(Equal([]string{"system:authenticated", "infotainment"}))
+	})
+
+	It("should hold downloads to the configured rate and count them", func() {
+		Expect(server.ConfigureRateLimits(RateLimitConfig{Download: "fast"})).To(MatchError(ContainSubstring("download rate limit")))
+		Expect(server.ConfigureRateLimits(RateLimitConfig{Download: "1G", DownloadPerStream: "32K", Upload: "10M"})).To(Succeed())
+
+		bytesBefore, throttledBefore := transferCounters(directionDownload)
+		start := time.Now()
+		w := do("GET", "/v1/builds/existing/artifact/large.raw", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.Len()).To(Equal(40 << 10))
+		// the stream's bucket holds 32K, the remaining 8K take a quarter of a second
+		Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+
+		bytesAfter, throttledAfter := transferCounters(directionDownload)
+		Expect(bytesAfter - bytesBefore).To(Equal(float64(40 << 10)))
+		Expect(throttledAfter).To(BeNumerically(">", throttledBefore))
+	})
+
+	It("should send the content types of artifacts and their payloads", func() {
+		w := do("GET", "/v1/builds/android/artifact/ridesx4.img.gz", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/gzip"))
+		Expect(w.Header().Get("X-AIB-Content-Type")).To(Equal("application/x-android-sparse-image"))
+		Expect(w.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="ridesx4.img.gz"`))
+
+		w = do("GET", "/v1/builds/android/artifact/rootfs.simg", "")
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/x-android-sparse-image"))
+
+		w = do("GET", "/v1/builds/android/artifacts/aboot.img.lz4.enc", "")
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/octet-stream"))
+		Expect(w.Header().Get("X-AIB-Content-Type")).To(Equal("application/x-android-boot-image"))
+	})
+
+	It("should return the build from the service", func() {
+		w := do("GET", "/v1/builds/existing", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var resp BuildResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &resp)).To(Succeed())
+		Expect(resp.Phase).To(Equal("Completed"))
+	})
+
+	It("should map service errors to status codes", func() {
+		w := do("GET", "/v1/builds/missing", "")
+		Expect(w.Code).To(Equal(http.StatusNotFound))
+		Expect(w.Body.String()).To(ContainSubstring("not found"))
+
+		w = do("POST", "/v1/builds", `{"manifest":"x"}`)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should pass the reviewed username as requester", func() {
+		w := do("POST", "/v1/builds", `{"name":"b1","manifest":"x"}`)
+		Expect(w.Code).To(Equal(http.StatusAccepted))
+		Expect(svc.created.Name).To(Equal("b1"))
+		Expect(svc.requestedBy).To(Equal("alice"))
+	})
+
+	It("should pass Git webhook deliveries to the service without a bearer token", func() {
+		req, _ := http.NewRequest("POST", "/v1/hooks/git", strings.NewReader(`{"ref":"refs/heads/main"}`))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-GitHub-Delivery", "d1")
+		req.Header.Set("X-Hub-Signature-256", "sha256=abc")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.gitHook.Provider).To(Equal(gitstatus.GitHub))
+		Expect(svc.gitHook.Delivery).To(Equal("d1"))
+		Expect(svc.gitHook.Signature).To(Equal("sha256=abc"))
+		Expect(string(svc.gitHook.Payload)).To(Equal(`{"ref":"refs/heads/main"}`))
+
+		req, _ = http.NewRequest("POST", "/v1/hooks/git", strings.NewReader(`{}`))
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should pass the Idempotency-Key to the service and answer replays with 200", func() {
+		post := func(key string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest("POST", "/v1/builds", strings.NewReader(`{"name":"b1","manifest":"x"}`))
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer good")
+			req.Header.Set(IdempotencyKeyHeader, key)
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			return w
+		}
+		Expect(post("first").Code).To(Equal(http.StatusAccepted))
+		Expect(svc.created.IdempotencyKey).To(Equal("first"))
+
+		w := post("retried")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).To(ContainSubstring(`"replayed": true`))
+	})
+
+	It("should pass label filters to the service", func() {
+		w := do("GET", "/v1/builds?label=team%3Dinfotainment&label=stage%3Ddev", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.listLabels).To(Equal(map[string]string{"team": "infotainment", "stage": "dev"}))
+
+		w = do("GET", "/v1/builds?label=team", "")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should advertise the default namespace and enabled features", func() {
+		svc.features = []string{"enableQueueing"}
+		w := do("GET", "/v1/info", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var info ServerInfoResponse
+		Expect(json.Unmarshal(w.Body.Bytes(), &info)).To(Succeed())
+		Expect(info.DefaultNamespace).To(Equal("builds"))
+		Expect(info.Features).To(Equal([]string{"enableQueueing"}))
+	})
+
+	It("should scope requests to namespaces the caller may access", func() {
+		w := doIn("team-a", "GET", "/v1/builds/existing", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.namespace).To(Equal("team-a"))
+
+		w = doIn("builds", "GET", "/v1/builds/existing", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.namespace).To(BeEmpty())
+
+		w = doIn("team-b", "GET", "/v1/builds/existing", "")
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+
+		w = doIn("Not_A_Namespace", "GET", "/v1/builds/existing", "")
+		Expect(w.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should only promote into namespaces the caller may create images in", func() {
+		w := do("POST", "/v1/builds/existing/promote", `{"targetNamespace":"team-b","registry":{"url":"quay.io/org/img:1"}}`)
+		Expect(w.Code).To(Equal(http.StatusForbidden))
+		Expect(svc.promoted).To(BeNil())
+
+		w = do("POST", "/v1/builds/existing/promote", `{"targetNamespace":"team-a","registry":{"url":"quay.io/org/img:1"}}`)
+		Expect(w.Code).To(Equal(http.StatusCreated))
+		Expect(svc.promoted.Registry.URL).To(Equal("quay.io/org/img:1"))
+	})
+
+	It("should pass log resume options to the service", func() {
+		w := do("GET", "/v1/builds/existing/logs?step=build&sinceBytes=120&tail=50&sinceTime=2025-01-02T03:04:05Z", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.logOpts.Step).To(Equal("build"))
+		Expect(svc.logOpts.SinceBytes).To(Equal(int64(120)))
+		Expect(*svc.logOpts.TailLines).To(Equal(int64(50)))
+		Expect(svc.logOpts.SinceTime.UTC().Format("15:04:05")).To(Equal("03:04:05"))
+
+		for _, q := range []string{"sinceBytes=-1", "tail=x", "sinceTime=yesterday"} {
+			w = do("GET", "/v1/builds/existing/logs?"+q, "")
+			Expect(w.Code).To(Equal(http.StatusBadRequest), q)
+		}
+	})
+
+	It("should keep quiet log streams alive with heartbeats", func() {
+		defer func(interval time.Duration) { logHeartbeatInterval = interval }(logHeartbeatInterval)
+		logHeartbeatInterval = 30 * time.Millisecond
+		svc.logQuiet = 150 * time.Millisecond
+
+		w := do("GET", "/v1/builds/existing/logs", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Body.String()).NotTo(ContainSubstring("\x00"))
+
+		w = do("GET", "/v1/builds/existing/logs?heartbeat=1", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		body := w.Body.String()
+		Expect(body).To(HavePrefix("Waiting for logs...\n\x00"))
+		Expect(strings.ReplaceAll(body, "\x00", "")).To(Equal(
+			"Waiting for logs...\n\n===== Logs from build =====\n\nbuilt\n\n[Log streaming completed]\n"))
+	})
+
+	It("should parse the stats window in days or as a duration", func() {
+		w := do("GET", "/v1/stats", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.statsWindow).To(Equal(7 * 24 * time.Hour))
+
+		w = do("GET", "/v1/stats?window=30d", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.statsWindow).To(Equal(30 * 24 * time.Hour))
+
+		w = do("GET", "/v1/stats?window=36h", "")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(svc.statsWindow).To(Equal(36 * time.Hour))
+
+		for _, q := range []string{"window=week", "window=0d", "window=-1h"} {
+			w = do("GET", "/v1/stats?"+q, "")
+			Expect(w.Code).To(Equal(http.StatusBadRequest), q)
+		}
+	})
+})
+
+// transferCounters reads the transfer metrics of a direction
+func transferCounters(direction string) (bytes, throttled float64) {
+	var m dto.Metric
+	Expect(transferBytesTotal.WithLabelValues(direction).Write(&m)).To(Succeed())
+	bytes = m.GetCounter().GetValue()
+	Expect(transferThrottledSecondsTotal.WithLabelValues(direction).Write(&m)).To(Succeed())
+	return bytes, m.GetCounter().GetValue()
+}
@@ -0,0 +1,779 @@
+// Package k8s is the Kubernetes access layer of the build API. It hides the controller-runtime client,
+// the typed clientset and pod exec plumbing behind the Cluster interface so the build service can be
+// exercised without a cluster.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/podexec"
+)
+
+// Cluster is the set of Kubernetes operations the build API performs. Namespaced calls act on the namespace
+// carried by their context (see WithNamespace), or on Namespace() if there is none.
+type Cluster interface {
+	// Namespace returns the default namespace
+	Namespace() string
+
+	GetImageBuild(ctx context.Context, name string) (*automotivev1.ImageBuild, error)
+	// ListImageBuilds lists the ImageBuilds matching all of the given labels (all builds if empty)
+	ListImageBuilds(ctx context.Context, labels map[string]string) ([]automotivev1.ImageBuild, error)
+	CreateImageBuild(ctx context.Context, build *automotivev1.ImageBuild) error
+	PatchImageBuild(ctx context.Context, original, modified *automotivev1.ImageBuild) error
+	// DeleteImageBuild deletes a build; the objects it owns are garbage collected in the background
+	DeleteImageBuild(ctx context.Context, name string) error
+	// GetAutomotiveDev reads the operator configuration, which always lives in the default namespace
+	GetAutomotiveDev(ctx context.Context, name string) (*automotivev1.AutomotiveDev, error)
+
+	GetImage(ctx context.Context, name string) (*automotivev1.Image, error)
+	ListImages(ctx context.Context) ([]automotivev1.Image, error)
+	CreateImage(ctx context.Context, image *automotivev1.Image) error
+	PatchImage(ctx context.Context, original, modified *automotivev1.Image) error
+	DeleteImage(ctx context.Context, name string) error
+
+	GetConfigMap(ctx context.Context, name string) (*corev1.ConfigMap, error)
+	CreateConfigMap(ctx context.Context, cm *corev1.ConfigMap) error
+	UpdateConfigMap(ctx context.Context, cm *corev1.ConfigMap) error
+	// ListPersistentVolumeClaims lists the PVCs matching all of the given labels
+	ListPersistentVolumeClaims(ctx context.Context, labels map[string]string) ([]corev1.PersistentVolumeClaim, error)
+	GetPersistentVolumeClaim(ctx context.Context, name string) (*corev1.PersistentVolumeClaim, error)
+	GetSecret(ctx context.Context, name string) (*corev1.Secret, error)
+	CreateSecret(ctx context.Context, secret *corev1.Secret) error
+	DeleteSecret(ctx context.Context, name string) error
+	// SetControllerOwner makes owner the controller of the named object so it is garbage collected with it
+	SetControllerOwner(ctx context.Context, obj client.Object, owner *automotivev1.ImageBuild) error
+
+	GetTaskRun(ctx context.Context, name string) (*tektonv1.TaskRun, error)
+	// FindTaskRunPod returns the pod backing a TaskRun, or nil if it has not been created yet
+	FindTaskRunPod(ctx context.Context, taskRunName string) (*corev1.Pod, error)
+	// FindUploadPod returns the running upload pod of a build, or nil if there is none
+	FindUploadPod(ctx context.Context, buildName string) (*corev1.Pod, error)
+	// WaitForArtifactPod polls until the build's artifact pod has a ready fileserver container.
+	// It returns a nil pod without error if the pod did not become ready within timeout.
+	WaitForArtifactPod(ctx context.Context, buildName string, timeout time.Duration) (*corev1.Pod, error)
+	GetPod(ctx context.Context, name string) (*corev1.Pod, error)
+	// ListEvents lists the Events recorded for the objects named name
+	ListEvents(ctx context.Context, name string) ([]corev1.Event, error)
+	// ProxyGet sends a GET request for path to a port of a pod through the API server's pod proxy. The
+	// caller must close the response body.
+	ProxyGet(ctx context.Context, podName string, port int, path string, header http.Header) (*http.Response, error)
+
+	StreamContainerLogs(ctx context.Context, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+	// Exec runs command in a container and streams its stdout to w; stderr is discarded
+	Exec(ctx context.Context, podName, container string, command []string, w io.Writer) error
+	// ExecWithInput is Exec with stdin streamed to the command
+	ExecWithInput(ctx context.Context, podName, container string, command []string, stdin io.Reader, w io.Writer) error
+	CopyToPod(ctx context.Context, podName, container, localPath, podPath string) error
+
+	// ReviewToken validates a bearer token with a TokenReview and returns the authenticated user and groups
+	ReviewToken(ctx context.Context, token string) (authnv1.UserInfo, bool, error)
+	// ReviewAccess reports whether the holder of token may perform verb on resource in namespace
+	ReviewAccess(ctx context.Context, token, namespace, resource, verb string) (bool, error)
+}
+
+type namespaceKey struct{}
+
+// WithNamespace returns a context that makes Cluster calls act on namespace instead of the default
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFrom returns the namespace selected with WithNamespace, or "" if there is none
+func NamespaceFrom(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
+}
+
+// imageBuildNameLabel is carried by every pod the operator runs for a build, TaskRun pods included
+const imageBuildNameLabel = "automotive.sdv.cloud.redhat.com/imagebuild-name"
+
+// Pod exec calls whose connection fails are retried execRetries times, the first after execRetryDelay
+const (
+	execRetries    = 2
+	execRetryDelay = 500 * time.Millisecond
+)
+
+// Adapter implements Cluster against a real API server. Clients are created on first use so a server can be
+// constructed without cluster access.
+type Adapter struct {
+	namespace string
+
+	mu        sync.Mutex
+	config    *rest.Config
+	client    client.Client
+	clientset kubernetes.Interface
+	exec      *podexec.Client
+	// proxyClient and streamClientset carry pod proxy requests and log streams; unlike the other clients they
+	// have no timeout, as downloads and followed logs last as long as they need
+	proxyClient     *http.Client
+	streamClientset kubernetes.Interface
+	// cache serves ImageBuild, build pod and AutomotiveDev reads once StartCache synced it; reads are live until then
+	cache cache.Cache
+}
+
+var _ Cluster = &Adapter{}
+
+// NewAdapter returns an Adapter operating on namespace, using in-cluster configuration or $KUBECONFIG
+func NewAdapter(namespace string) *Adapter {
+	return &Adapter{namespace: namespace}
+}
+
+// ResolveNamespace returns the namespace the build API manages: $BUILD_API_NAMESPACE, the pod's own namespace, or default
+func ResolveNamespace() string {
+	if ns := strings.TrimSpace(os.Getenv("BUILD_API_NAMESPACE")); ns != "" {
+		return ns
+	}
+	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+		ns := strings.TrimSpace(string(data))
+		if ns != "" {
+			return ns
+		}
+	}
+	return "default"
+}
+
+// RESTConfig returns the in-cluster configuration, falling back to $KUBECONFIG
+func RESTConfig() (*rest.Config, error) {
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := os.Getenv("KUBECONFIG")
+		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build kube config: %w", err)
+		}
+	}
+	cfgCopy := rest.CopyConfig(cfg)
+	cfgCopy.Timeout = 30 * time.Minute
+	return cfgCopy, nil
+}
+
+// NewScheme returns a scheme with every type the build API reads or writes
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := automotivev1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add automotive scheme: %w", err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add core scheme: %w", err)
+	}
+	if err := tektonv1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("failed to add tekton scheme: %w", err)
+	}
+	return scheme, nil
+}
+
+func (a *Adapter) Namespace() string {
+	return a.namespace
+}
+
+// ns returns the namespace calls made with ctx act on
+func (a *Adapter) ns(ctx context.Context) string {
+	if ns := NamespaceFrom(ctx); ns != "" {
+		return ns
+	}
+	return a.namespace
+}
+
+func (a *Adapter) clients() (*rest.Config, client.Client, kubernetes.Interface, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.client != nil {
+		return a.config, a.client, a.clientset, nil
+	}
+
+	cfg, err := RESTConfig()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("k8s client error: %w", err)
+	}
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("k8s client error: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("k8s client error: failed to create k8s client: %w", err)
+	}
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("k8s client error: clientset: %w", err)
+	}
+	executor, err := podexec.NewSPDYExecutor(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("k8s client error: pod exec: %w", err)
+	}
+	a.config, a.client, a.clientset = cfg, c, cs
+	a.exec = podexec.New(executor, podexec.WithRetries(execRetries, execRetryDelay))
+	return a.config, a.client, a.clientset, nil
+}
+
+// podExec returns the client running commands in pods
+func (a *Adapter) podExec() (*podexec.Client, error) {
+	if _, _, _, err := a.clients(); err != nil {
+		return nil, err
+	}
+	return a.exec, nil
+}
+
+func (a *Adapter) ctrlClient() (client.Client, error) {
+	_, c, _, err := a.clients()
+	return c, err
+}
+
+// reader returns the informer cache if it is synced and the live client otherwise. Only ImageBuilds,
+// AutomotiveDevs and pods labelled with imageBuildNameLabel are cached.
+func (a *Adapter) reader() (client.Reader, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cache != nil {
+		return a.cache, nil
+	}
+	return c, nil
+}
+
+// StartCache runs informers for ImageBuilds, AutomotiveDevs and build pods in every namespace so that lists
+// and gets are served from memory. Reads switch to the cache once it has synced; it runs until ctx is done.
+func (a *Adapter) StartCache(ctx context.Context) error {
+	cfg, _, _, err := a.clients()
+	if err != nil {
+		return err
+	}
+	scheme, err := NewScheme()
+	if err != nil {
+		return err
+	}
+	buildPods, err := labels.Parse(imageBuildNameLabel)
+	if err != nil {
+		return err
+	}
+	c, err := cache.New(cfg, cache.Options{
+		Scheme: scheme,
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}: {Label: buildPods},
+		},
+		DefaultTransform:            cache.TransformStripManagedFields(),
+		ReaderFailOnMissingInformer: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create cache: %w", err)
+	}
+	for _, obj := range []client.Object{&automotivev1.ImageBuild{}, &automotivev1.AutomotiveDev{}, &corev1.Pod{}} {
+		if _, err := c.GetInformer(ctx, obj); err != nil {
+			return fmt.Errorf("failed to create informer: %w", err)
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Start(ctx) }()
+	if !c.WaitForCacheSync(ctx) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("cache did not sync: %w", <-errCh)
+	}
+	a.mu.Lock()
+	a.cache = c
+	a.mu.Unlock()
+	return <-errCh
+}
+
+func (a *Adapter) GetImageBuild(ctx context.Context, name string) (*automotivev1.ImageBuild, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	build := &automotivev1.ImageBuild{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, build); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+func (a *Adapter) ListImageBuilds(ctx context.Context, labels map[string]string) ([]automotivev1.ImageBuild, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	list := &automotivev1.ImageBuildList{}
+	if err := c.List(ctx, list, client.InNamespace(a.ns(ctx)), client.MatchingLabels(labels)); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (a *Adapter) CreateImageBuild(ctx context.Context, build *automotivev1.ImageBuild) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	build.Namespace = a.ns(ctx)
+	return c.Create(ctx, build)
+}
+
+func (a *Adapter) PatchImageBuild(ctx context.Context, original, modified *automotivev1.ImageBuild) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	return c.Patch(ctx, modified, client.MergeFrom(original))
+}
+
+func (a *Adapter) DeleteImageBuild(ctx context.Context, name string) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	build := &automotivev1.ImageBuild{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: a.ns(ctx)}}
+	return c.Delete(ctx, build, client.PropagationPolicy(metav1.DeletePropagationBackground))
+}
+
+func (a *Adapter) GetAutomotiveDev(ctx context.Context, name string) (*automotivev1.AutomotiveDev, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	autoDev := &automotivev1.AutomotiveDev{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.namespace}, autoDev); err != nil {
+		return nil, err
+	}
+	return autoDev, nil
+}
+
+func (a *Adapter) GetImage(ctx context.Context, name string) (*automotivev1.Image, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	image := &automotivev1.Image{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, image); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func (a *Adapter) ListImages(ctx context.Context) ([]automotivev1.Image, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	list := &automotivev1.ImageList{}
+	if err := c.List(ctx, list, client.InNamespace(a.ns(ctx))); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (a *Adapter) CreateImage(ctx context.Context, image *automotivev1.Image) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	image.Namespace = a.ns(ctx)
+	return c.Create(ctx, image)
+}
+
+func (a *Adapter) PatchImage(ctx context.Context, original, modified *automotivev1.Image) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	return c.Patch(ctx, modified, client.MergeFrom(original))
+}
+
+func (a *Adapter) DeleteImage(ctx context.Context, name string) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	return c.Delete(ctx, &automotivev1.Image{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: a.ns(ctx)}})
+}
+
+func (a *Adapter) GetConfigMap(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, cm); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+func (a *Adapter) CreateConfigMap(ctx context.Context, cm *corev1.ConfigMap) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	cm.Namespace = a.ns(ctx)
+	return c.Create(ctx, cm)
+}
+
+func (a *Adapter) UpdateConfigMap(ctx context.Context, cm *corev1.ConfigMap) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	cm.Namespace = a.ns(ctx)
+	return c.Update(ctx, cm)
+}
+
+func (a *Adapter) ListPersistentVolumeClaims(ctx context.Context, labels map[string]string) ([]corev1.PersistentVolumeClaim, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, list, client.InNamespace(a.ns(ctx)), client.MatchingLabels(labels)); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (a *Adapter) GetPersistentVolumeClaim(ctx context.Context, name string) (*corev1.PersistentVolumeClaim, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, pvc); err != nil {
+		return nil, err
+	}
+	return pvc, nil
+}
+
+func (a *Adapter) GetSecret(ctx context.Context, name string) (*corev1.Secret, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func (a *Adapter) CreateSecret(ctx context.Context, secret *corev1.Secret) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	secret.Namespace = a.ns(ctx)
+	return c.Create(ctx, secret)
+}
+
+func (a *Adapter) DeleteSecret(ctx context.Context, name string) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	return c.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: a.ns(ctx)}})
+}
+
+func (a *Adapter) SetControllerOwner(ctx context.Context, obj client.Object, owner *automotivev1.ImageBuild) error {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return err
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: a.ns(ctx)}, obj); err != nil {
+		return err
+	}
+	obj.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(owner, automotivev1.GroupVersion.WithKind("ImageBuild")),
+	})
+	return c.Update(ctx, obj)
+}
+
+func (a *Adapter) GetTaskRun(ctx context.Context, name string) (*tektonv1.TaskRun, error) {
+	c, err := a.ctrlClient()
+	if err != nil {
+		return nil, err
+	}
+	tr := &tektonv1.TaskRun{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, tr); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+func (a *Adapter) FindTaskRunPod(ctx context.Context, taskRunName string) (*corev1.Pod, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := c.List(ctx, pods, client.InNamespace(a.ns(ctx)), client.MatchingLabels{"tekton.dev/taskRun": taskRunName}); err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, nil
+	}
+	return &pods.Items[0], nil
+}
+
+func (a *Adapter) FindUploadPod(ctx context.Context, buildName string) (*corev1.Pod, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList,
+		client.InNamespace(a.ns(ctx)),
+		client.MatchingLabels{
+			"automotive.sdv.cloud.redhat.com/imagebuild-name": buildName,
+			"app.kubernetes.io/name":                          "upload-pod",
+		},
+	); err != nil {
+		return nil, err
+	}
+	for i := range podList.Items {
+		p := &podList.Items[i]
+		if p.Status.Phase == corev1.PodRunning {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (a *Adapter) ListEvents(ctx context.Context, name string) ([]corev1.Event, error) {
+	_, _, cs, err := a.clients()
+	if err != nil {
+		return nil, err
+	}
+	events, err := cs.CoreV1().Events(a.ns(ctx)).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", name).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, nil
+}
+
+func (a *Adapter) WaitForArtifactPod(ctx context.Context, buildName string, timeout time.Duration) (*corev1.Pod, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		podList := &corev1.PodList{}
+		if err := c.List(ctx, podList,
+			client.InNamespace(a.ns(ctx)),
+			client.MatchingLabels{
+				"app.kubernetes.io/name":                          "artifact-pod",
+				"automotive.sdv.cloud.redhat.com/imagebuild-name": buildName,
+			}); err != nil {
+			return nil, err
+		}
+
+		for i := range podList.Items {
+			p := &podList.Items[i]
+			if p.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			for _, cs := range p.Status.ContainerStatuses {
+				if cs.Name == "fileserver" && cs.Ready {
+					return p, nil
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (a *Adapter) GetPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	c, err := a.reader()
+	if err != nil {
+		return nil, err
+	}
+	pod := &corev1.Pod{}
+	err = c.Get(ctx, types.NamespacedName{Name: name, Namespace: a.ns(ctx)}, pod)
+	if errors.IsNotFound(err) {
+		// pods without imageBuildNameLabel are not cached
+		_, _, cs, err := a.clients()
+		if err != nil {
+			return nil, err
+		}
+		return cs.CoreV1().Pods(a.ns(ctx)).Get(ctx, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// ServedResources returns the resources the API server serves in groupVersion, e.g. tekton.dev/v1. It returns
+// a NotFound error when the group version is not served at all.
+func (a *Adapter) ServedResources(_ context.Context, groupVersion string) ([]string, error) {
+	_, _, cs, err := a.clients()
+	if err != nil {
+		return nil, err
+	}
+	list, err := cs.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]string, 0, len(list.APIResources))
+	for _, r := range list.APIResources {
+		resources = append(resources, r.Name)
+	}
+	return resources, nil
+}
+
+// streamClients returns the clients of requests that stream for as long as their caller needs: they share the
+// configuration of the other clients but not its timeout, which would cut followed logs and long downloads short
+func (a *Adapter) streamClients() (*http.Client, kubernetes.Interface, error) {
+	cfg, _, _, err := a.clients()
+	if err != nil {
+		return nil, nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.proxyClient != nil {
+		return a.proxyClient, a.streamClientset, nil
+	}
+	streamCfg := rest.CopyConfig(cfg)
+	streamCfg.Timeout = 0
+	hc, err := rest.HTTPClientFor(streamCfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("k8s client error: stream client: %w", err)
+	}
+	cs, err := kubernetes.NewForConfigAndClient(streamCfg, hc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("k8s client error: stream clientset: %w", err)
+	}
+	a.proxyClient, a.streamClientset = hc, cs
+	return hc, cs, nil
+}
+
+func (a *Adapter) StreamContainerLogs(ctx context.Context, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	_, cs, err := a.streamClients()
+	if err != nil {
+		return nil, err
+	}
+	req := cs.CoreV1().Pods(a.ns(ctx)).GetLogs(podName, opts)
+	return req.Stream(ctx)
+}
+
+func (a *Adapter) ProxyGet(ctx context.Context, podName string, port int, path string, header http.Header) (*http.Response, error) {
+	hc, cs, err := a.streamClients()
+	if err != nil {
+		return nil, err
+	}
+
+	u := cs.CoreV1().RESTClient().Get().
+		Namespace(a.ns(ctx)).
+		Resource("pods").
+		Name(fmt.Sprintf("%s:%d", podName, port)).
+		SubResource("proxy").
+		Suffix(path).
+		URL()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return hc.Do(req)
+}
+
+func (a *Adapter) Exec(ctx context.Context, podName, container string, command []string, w io.Writer) error {
+	return a.ExecWithInput(ctx, podName, container, command, nil, w)
+}
+
+func (a *Adapter) ExecWithInput(ctx context.Context, podName, container string, command []string, stdin io.Reader, w io.Writer) error {
+	exec, err := a.podExec()
+	if err != nil {
+		return err
+	}
+	target := podexec.Target{Namespace: a.ns(ctx), Pod: podName, Container: container}
+	return exec.Exec(ctx, target, command, stdin, w)
+}
+
+func (a *Adapter) CopyToPod(ctx context.Context, podName, container, localPath, podPath string) error {
+	exec, err := a.podExec()
+	if err != nil {
+		return err
+	}
+	target := podexec.Target{Namespace: a.ns(ctx), Pod: podName, Container: container}
+	return exec.CopyToPod(ctx, target, localPath, podPath, nil)
+}
+
+func (a *Adapter) ReviewAccess(ctx context.Context, token, namespace, resource, verb string) (bool, error) {
+	cfg, _, _, err := a.clients()
+	if err != nil {
+		return false, err
+	}
+	// Ask as the caller: a SelfSubjectAccessReview made with their token evaluates their own roles and groups
+	userCfg := rest.AnonymousClientConfig(cfg)
+	userCfg.BearerToken = token
+	cs, err := kubernetes.NewForConfig(userCfg)
+	if err != nil {
+		return false, err
+	}
+	review := &authzv1.SelfSubjectAccessReview{
+		Spec: authzv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authzv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     automotivev1.GroupVersion.Group,
+				Resource:  resource,
+			},
+		},
+	}
+	res, err := cs.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return res.Status.Allowed, nil
+}
+
+func (a *Adapter) ReviewToken(ctx context.Context, token string) (authnv1.UserInfo, bool, error) {
+	_, _, cs, err := a.clients()
+	if err != nil {
+		return authnv1.UserInfo{}, false, err
+	}
+	tr := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}
+	res, err := cs.AuthenticationV1().TokenReviews().Create(ctx, tr, metav1.CreateOptions{})
+	if err != nil {
+		return authnv1.UserInfo{}, false, err
+	}
+	return res.Status.User, res.Status.Authenticated, nil
+}
@@ -0,0 +1,318 @@
+package buildapi
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Lint rule types
+const (
+	// LintForbiddenPackages flags rpms matching one of the rule's packages (shell patterns)
+	LintForbiddenPackages = "forbiddenPackages"
+	// LintSELinuxEnforcing flags images that set an SELinux mode other than enforcing
+	LintSELinuxEnforcing = "selinuxEnforcing"
+	// LintMaxImageSize flags images whose image_size, in the manifest or a define, exceeds the rule's maxSize
+	LintMaxImageSize = "maxImageSize"
+	// LintPinnedContainers flags container images that are not referenced by digest
+	LintPinnedContainers = "pinnedContainers"
+)
+
+// Lint rule actions
+const (
+	LintActionWarn  = "warn"
+	LintActionBlock = "block"
+)
+
+// lintRulesKey is the key of the rules in the AutomotiveDev's lint rules ConfigMap
+const lintRulesKey = "rules.yaml"
+
+// LintRequest carries the manifests and defines of a build to lint
+type LintRequest struct {
+	// +required
+	Manifest string `json:"manifest"`
+	// +default=manifest.aib.yml
+	ManifestFileName    string         `json:"manifestFileName"`
+	AdditionalManifests []ManifestFile `json:"additionalManifests,omitempty"`
+	// CustomDefs are KEY=VALUE defines; an image_size define is checked by maxImageSize rules
+	CustomDefs []string `json:"customDefs,omitempty"`
+}
+
+// LintResponse lists the policy violations found; Blocked is set when a violated rule blocks builds
+type LintResponse struct {
+	Violations []LintViolation `json:"violations"`
+	Blocked    bool            `json:"blocked"`
+}
+
+// LintViolation is a manifest breaking one lint rule
+type LintViolation struct {
+	Rule string `json:"rule"`
+	// +enum=warn,block
+	Action string `json:"action"`
+	// File is the manifest the violation was found in; it is empty for defines
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// LintRules is the content of the lint rules ConfigMap
+type LintRules struct {
+	Rules []LintRule `json:"rules"`
+}
+
+// LintRule is one organization policy manifests are checked against
+type LintRule struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Action is warn (the default) or block
+	Action string `json:"action,omitempty"`
+	// Packages are the rpm names or shell patterns a forbiddenPackages rule rejects
+	Packages []string `json:"packages,omitempty"`
+	// MaxSize is the largest image_size a maxImageSize rule accepts, e.g. "8 GiB"
+	MaxSize string `json:"maxSize,omitempty"`
+
+	maxBytes int64
+}
+
+// parseLintRules reads and checks the rules of a lint rules ConfigMap
+func parseLintRules(data string) (*LintRules, error) {
+	var rules LintRules
+	if err := yaml.UnmarshalStrict([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("invalid lint rules: %w", err)
+	}
+	names := map[string]bool{}
+	for i := range rules.Rules {
+		r := &rules.Rules[i]
+		if r.Name == "" {
+			r.Name = r.Type
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate lint rule %q", r.Name)
+		}
+		names[r.Name] = true
+		switch r.Action {
+		case "":
+			r.Action = LintActionWarn
+		case LintActionWarn, LintActionBlock:
+		default:
+			return nil, fmt.Errorf("lint rule %q: unknown action %q (warn, block)", r.Name, r.Action)
+		}
+		switch r.Type {
+		case LintForbiddenPackages:
+			if len(r.Packages) == 0 {
+				return nil, fmt.Errorf("lint rule %q: packages are required", r.Name)
+			}
+			for _, p := range r.Packages {
+				if _, err := path.Match(p, ""); err != nil {
+					return nil, fmt.Errorf("lint rule %q: invalid package pattern %q", r.Name, p)
+				}
+			}
+		case LintMaxImageSize:
+			n, err := parseSize(r.MaxSize)
+			if err != nil {
+				return nil, fmt.Errorf("lint rule %q: invalid maxSize: %w", r.Name, err)
+			}
+			r.maxBytes = n
+		case LintSELinuxEnforcing, LintPinnedContainers:
+		default:
+			return nil, fmt.Errorf("lint rule %q: unknown type %q", r.Name, r.Type)
+		}
+	}
+	return &rules, nil
+}
+
+// Lint checks the main and additional manifests and the defines of a build against the rules
+func (rules *LintRules) Lint(req LintRequest) (*LintResponse, error) {
+	resp := &LintResponse{Violations: []LintViolation{}}
+	if rules == nil || len(rules.Rules) == 0 {
+		return resp, nil
+	}
+
+	files := append([]ManifestFile{{Name: req.ManifestFileName, Content: req.Manifest}}, req.AdditionalManifests...)
+	manifests := make([]map[string]any, len(files))
+	for i, f := range files {
+		if err := yaml.Unmarshal([]byte(f.Content), &manifests[i]); err != nil {
+			return nil, fmt.Errorf("manifest %s is not valid YAML: %w", f.Name, err)
+		}
+	}
+
+	for _, rule := range rules.Rules {
+		report := func(file, format string, args ...any) {
+			resp.Violations = append(resp.Violations, LintViolation{
+				Rule:    rule.Name,
+				Action:  rule.Action,
+				File:    file,
+				Message: fmt.Sprintf(format, args...),
+			})
+			if rule.Action == LintActionBlock {
+				resp.Blocked = true
+			}
+		}
+		for i, m := range manifests {
+			file := files[i].Name
+			switch rule.Type {
+			case LintForbiddenPackages:
+				for _, rpm := range manifestStrings(m, "content", "rpms") {
+					if p := matchPackage(rpm, rule.Packages); p != "" {
+						report(file, "package %s is forbidden (%s)", rpm, p)
+					}
+				}
+				for _, rpm := range manifestStrings(m, "qm", "content", "rpms") {
+					if p := matchPackage(rpm, rule.Packages); p != "" {
+						report(file, "qm package %s is forbidden (%s)", rpm, p)
+					}
+				}
+			case LintSELinuxEnforcing:
+				if mode, ok := manifestValue(m, "image", "selinux_mode").(string); ok && mode != "enforcing" {
+					report(file, "image.selinux_mode is %s; SELinux must be enforcing", mode)
+				}
+			case LintMaxImageSize:
+				var size string
+				switch v := manifestValue(m, "image", "image_size").(type) {
+				case string:
+					size = v
+				case float64:
+					size = strconv.FormatFloat(v, 'f', -1, 64)
+				}
+				if size != "" {
+					checkImageSize(rule, size, "image.image_size", func(format string, args ...any) {
+						report(file, format, args...)
+					})
+				}
+			case LintPinnedContainers:
+				for _, ref := range unpinnedContainers(m) {
+					report(file, "container image %s is not pinned to a digest", ref)
+				}
+			}
+		}
+		if rule.Type == LintMaxImageSize {
+			for _, def := range req.CustomDefs {
+				if k, v, ok := strings.Cut(def, "="); ok && strings.TrimSpace(k) == "image_size" {
+					checkImageSize(rule, v, "define image_size", func(format string, args ...any) {
+						report("", format, args...)
+					})
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
+// lintSummary joins the messages of the violations with the given action
+func lintSummary(violations []LintViolation, action string) string {
+	var msgs []string
+	for _, v := range violations {
+		if v.Action != action {
+			continue
+		}
+		msg := v.Rule + ": " + v.Message
+		if v.File != "" {
+			msg = v.File + ": " + msg
+		}
+		msgs = append(msgs, msg)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func checkImageSize(rule LintRule, value, what string, report func(string, ...any)) {
+	n, err := parseSize(value)
+	if err != nil {
+		report("%s %q is not a size", what, value)
+		return
+	}
+	if n > rule.maxBytes {
+		report("%s %s exceeds the maximum of %s", what, strings.TrimSpace(value), rule.MaxSize)
+	}
+}
+
+// manifestValue returns the value at the given mapping keys of a manifest, or nil
+func manifestValue(m map[string]any, keys ...string) any {
+	var v any = m
+	for _, k := range keys {
+		mm, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = mm[k]
+	}
+	return v
+}
+
+// manifestStrings returns the string items of the list at the given keys of a manifest
+func manifestStrings(m map[string]any, keys ...string) []string {
+	items, _ := manifestValue(m, keys...).([]any)
+	var out []string
+	for _, it := range items {
+		if s, ok := it.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func matchPackage(rpm string, patterns []string) string {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, rpm); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// unpinnedContainers returns the container_images of a manifest and its qm partition without a digest
+func unpinnedContainers(m map[string]any) []string {
+	var out []string
+	for _, keys := range [][]string{{"content", "container_images"}, {"qm", "content", "container_images"}} {
+		images, _ := manifestValue(m, keys...).([]any)
+		for _, img := range images {
+			c, ok := img.(map[string]any)
+			if !ok {
+				continue
+			}
+			source, _ := c["source"].(string)
+			digest, _ := c["digest"].(string)
+			if digest != "" || strings.Contains(source, "@sha256:") {
+				continue
+			}
+			ref := source
+			if tag, _ := c["tag"].(string); tag != "" {
+				ref += ":" + tag
+			}
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// sizeUnits maps size suffixes to bytes; single letters are binary units as in automotive-image-builder
+var sizeUnits = map[string]int64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kib": 1 << 10, "kb": 1000,
+	"m": 1 << 20, "mib": 1 << 20, "mb": 1000 * 1000,
+	"g": 1 << 30, "gib": 1 << 30, "gb": 1000 * 1000 * 1000,
+	"t": 1 << 40, "tib": 1 << 40, "tb": 1000 * 1000 * 1000 * 1000,
+}
+
+// parseSize reads a size such as "8589934592", "8G" or "8 GiB" in bytes
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+	return n * unit, nil
+}
@@ -0,0 +1,63 @@
+package buildapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of a result cache lookup
+const (
+	cacheHit    = "hit"
+	cacheMiss   = "miss"
+	cacheBypass = "bypass"
+)
+
+var (
+	// metricsRegistry holds the build API's own metrics, served from /v1/metrics
+	metricsRegistry = prometheus.NewRegistry()
+
+	cacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automotive_build_cache_lookups_total",
+			Help: "Build requests looked up in the result cache, by result (hit, miss or bypass)",
+		},
+		[]string{"namespace", "result"},
+	)
+
+	cacheSavedSecondsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automotive_build_cache_saved_build_seconds_total",
+			Help: "Build time saved by answering requests from the result cache, estimated from the cached builds",
+		},
+		[]string{"namespace"},
+	)
+
+	transferBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automotive_build_api_transfer_bytes_total",
+			Help: "Bytes of artifact downloads and file uploads moved by the build API, by direction",
+		},
+		[]string{"direction"},
+	)
+
+	transferThrottledSecondsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automotive_build_api_transfer_throttled_seconds_total",
+			Help: "Time downloads and uploads waited for the bandwidth limits of the build API, by direction",
+		},
+		[]string{"direction"},
+	)
+)
+
+func init() {
+	metricsRegistry.MustRegister(cacheLookupsTotal, cacheSavedSecondsTotal, transferBytesTotal, transferThrottledSecondsTotal)
+}
+
+// @Summary Get the build API's Prometheus metrics
+// @ID getMetrics
+// @Success 200 text/plain {string} Metrics in the Prometheus text format
+// @Router /v1/metrics [get]
+func handleMetrics(c *gin.Context) {
+	promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,44 @@
+// Command gen writes the OpenAPI spec of the build API generated from the annotations of its Go source.
+// It is run by go generate in internal/buildapi.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/openapi"
+)
+
+type outputs []string
+
+func (o *outputs) String() string { return strings.Join(*o, ",") }
+
+func (o *outputs) Set(path string) error {
+	*o = append(*o, path)
+	return nil
+}
+
+func main() {
+	dir := flag.String("dir", ".", "Directory of the package whose annotations describe the API")
+	var out outputs
+	flag.Var(&out, "o", "File to write the spec to; may be repeated")
+	flag.Parse()
+
+	spec, err := openapi.Generate(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(out) == 0 {
+		_, _ = os.Stdout.Write(spec)
+		return
+	}
+	for _, path := range out {
+		if err := os.WriteFile(path, spec, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,632 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// header marks the generated spec
+const header = "# Code generated from the annotations of the build API handlers by go generate; DO NOT EDIT.\n"
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Generate parses the Go files of the package in dir, except its tests, and returns its OpenAPI spec as YAML
+func Generate(dir string) ([]byte, error) {
+	doc, err := Build(dir)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString(header)
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode spec: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Build parses the Go files of the package in dir, except its tests, and returns its OpenAPI document
+func Build(dir string) (*Document, error) {
+	g := &generator{
+		fset:  token.NewFileSet(),
+		types: map[string]*typeDecl{},
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Servers: []Server{{URL: "/"}},
+			Paths:   map[string]*PathItem{},
+			Components: Components{
+				Parameters: map[string]*Parameter{},
+				Schemas:    map[string]*Schema{},
+			},
+		},
+		operationIDs: map[string]bool{},
+	}
+	if err := g.parse(dir); err != nil {
+		return nil, err
+	}
+	for _, f := range g.funcs {
+		if err := g.api(f); err != nil {
+			return nil, err
+		}
+	}
+	if g.doc.Info.Title == "" {
+		return nil, fmt.Errorf("no function of %s describes the API with @Title", dir)
+	}
+	for _, f := range g.funcs {
+		if err := g.operation(f); err != nil {
+			return nil, err
+		}
+	}
+	return g.doc, nil
+}
+
+type generator struct {
+	fset         *token.FileSet
+	types        map[string]*typeDecl
+	funcs        []*annotated
+	doc          *Document
+	operationIDs map[string]bool
+}
+
+type typeDecl struct {
+	spec *ast.TypeSpec
+	doc  *ast.CommentGroup
+}
+
+// annotated is a function carrying annotations in its doc comment
+type annotated struct {
+	name  string
+	pos   token.Position
+	lines []annotation
+}
+
+type annotation struct {
+	tag  string
+	rest string
+}
+
+func (f *annotated) errorf(format string, args ...any) error {
+	return fmt.Errorf("%s: %s: %s", f.pos, f.name, fmt.Sprintf(format, args...))
+}
+
+func (g *generator) parse(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, path, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if ts.Assign.IsValid() {
+						continue
+					}
+					doc := ts.Doc
+					if doc == nil && len(d.Specs) == 1 {
+						doc = d.Doc
+					}
+					g.types[ts.Name.Name] = &typeDecl{spec: ts, doc: doc}
+				}
+			case *ast.FuncDecl:
+				if d.Doc == nil {
+					continue
+				}
+				f := &annotated{name: d.Name.Name, pos: g.fset.Position(d.Pos())}
+				for _, c := range d.Doc.List {
+					line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+					if !strings.HasPrefix(line, "@") {
+						continue
+					}
+					tag, rest, _ := strings.Cut(line, " ")
+					f.lines = append(f.lines, annotation{tag: tag, rest: strings.TrimSpace(rest)})
+				}
+				if len(f.lines) > 0 {
+					g.funcs = append(g.funcs, f)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// api reads the annotations describing the API as a whole
+func (g *generator) api(f *annotated) error {
+	for _, a := range f.lines {
+		switch a.tag {
+		case "@Title":
+			g.doc.Info.Title = a.rest
+		case "@Version":
+			g.doc.Info.Version = a.rest
+		case "@Parameter":
+			ref, rest, _ := strings.Cut(a.rest, " ")
+			p, err := g.parameter(strings.Fields(rest))
+			if err != nil {
+				return f.errorf("@Parameter %s: %v", ref, err)
+			}
+			g.doc.Components.Parameters[ref] = p
+		}
+	}
+	return nil
+}
+
+// operation reads the annotations describing the operation a handler serves
+func (g *generator) operation(f *annotated) error {
+	op := &Operation{Responses: map[string]*Response{}}
+	var path, method string
+	var refs, others []*Parameter
+	declared := map[string]*Parameter{}
+	type header struct{ code, name, description string }
+	var headers []header
+	var description []string
+
+	for _, a := range f.lines {
+		fields := strings.Fields(a.rest)
+		switch a.tag {
+		case "@Title", "@Version", "@Parameter":
+			// described the API in api
+		case "@Summary":
+			op.Summary = a.rest
+		case "@Description":
+			description = append(description, a.rest)
+		case "@ID":
+			op.OperationID = a.rest
+		case "@Param":
+			if len(fields) == 1 {
+				if _, ok := g.doc.Components.Parameters[fields[0]]; !ok {
+					return f.errorf("@Param: no shared parameter %s", fields[0])
+				}
+				refs = append(refs, &Parameter{Ref: "#/components/parameters/" + fields[0]})
+				continue
+			}
+			p, err := g.parameter(fields)
+			if err != nil {
+				return f.errorf("@Param %s: %v", a.rest, err)
+			}
+			if p.In == "path" {
+				declared[p.Name] = p
+			} else {
+				others = append(others, p)
+			}
+		case "@Body":
+			if len(fields) != 2 {
+				return f.errorf("@Body wants a media type and a {schema}")
+			}
+			s, err := g.schemaToken(fields[1])
+			if err != nil {
+				return f.errorf("@Body: %v", err)
+			}
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{fields[0]: {Schema: s}}}
+		case "@Success", "@Failure":
+			code, r, err := g.response(fields)
+			if err != nil {
+				return f.errorf("%s %s: %v", a.tag, a.rest, err)
+			}
+			if _, ok := op.Responses[code]; ok {
+				return f.errorf("%s: response %s declared twice", a.tag, code)
+			}
+			op.Responses[code] = r
+		case "@Header":
+			if len(fields) < 2 {
+				return f.errorf("@Header wants a status code and a header name")
+			}
+			headers = append(headers, header{fields[0], fields[1], strings.Join(fields[2:], " ")})
+		case "@Router":
+			if len(fields) != 2 || !strings.HasPrefix(fields[1], "[") || !strings.HasSuffix(fields[1], "]") {
+				return f.errorf("@Router wants a path and a [method]")
+			}
+			path, method = fields[0], strings.ToLower(strings.Trim(fields[1], "[]"))
+		default:
+			return f.errorf("unknown annotation %s", a.tag)
+		}
+	}
+	if path == "" {
+		if op.OperationID != "" || len(op.Responses) > 0 {
+			return f.errorf("operation without @Router")
+		}
+		return nil
+	}
+	if op.OperationID == "" {
+		return f.errorf("operation without @ID")
+	}
+	if g.operationIDs[op.OperationID] {
+		return f.errorf("operationId %s used twice", op.OperationID)
+	}
+	g.operationIDs[op.OperationID] = true
+	if len(op.Responses) == 0 {
+		return f.errorf("operation without responses")
+	}
+	op.Description = strings.Join(description, " ")
+
+	for _, h := range headers {
+		r, ok := op.Responses[h.code]
+		if !ok {
+			return f.errorf("@Header %s for undeclared response %s", h.name, h.code)
+		}
+		if r.Headers == nil {
+			r.Headers = map[string]*Header{}
+		}
+		r.Headers[h.name] = &Header{Description: h.description, Schema: &Schema{Type: "string"}}
+	}
+
+	op.Parameters = refs
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		p, ok := declared[m[1]]
+		if !ok {
+			p = &Parameter{In: "path", Name: m[1], Required: true, Schema: &Schema{Type: "string"}}
+		}
+		delete(declared, m[1])
+		op.Parameters = append(op.Parameters, p)
+	}
+	for name := range declared {
+		return f.errorf("path parameter %s is not in %s", name, path)
+	}
+	op.Parameters = append(op.Parameters, others...)
+
+	item := g.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		g.doc.Paths[path] = item
+	}
+	slot := item.operation(method)
+	if slot == nil {
+		return f.errorf("unsupported method %s", method)
+	}
+	if *slot != nil {
+		return f.errorf("%s %s is served twice", strings.ToUpper(method), path)
+	}
+	*slot = op
+	return nil
+}
+
+// parameter parses <name> <in> <type> <required|optional> [default=<v>] [minimum=<n>] [<description>]
+func (g *generator) parameter(fields []string) (*Parameter, error) {
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("want <name> <in> <type> <required|optional>")
+	}
+	p := &Parameter{Name: fields[0], In: fields[1]}
+	switch p.In {
+	case "path", "query", "header":
+	default:
+		return nil, fmt.Errorf("parameters are in path, query or header, not %s", p.In)
+	}
+	s, err := parameterSchema(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	p.Schema = s
+	switch fields[3] {
+	case "required":
+		p.Required = true
+	case "optional":
+	default:
+		return nil, fmt.Errorf("want required or optional, not %s", fields[3])
+	}
+	rest := fields[4:]
+	for len(rest) > 0 {
+		key, value, ok := strings.Cut(rest[0], "=")
+		if !ok || (key != "default" && key != "minimum") {
+			break
+		}
+		if err := applyMarker(s, key, value); err != nil {
+			return nil, err
+		}
+		rest = rest[1:]
+	}
+	p.Description = strings.Join(rest, " ")
+	return p, nil
+}
+
+func parameterSchema(t string) (*Schema, error) {
+	if elem, ok := strings.CutPrefix(t, "[]"); ok {
+		items, err := parameterSchema(elem)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	}
+	switch t {
+	case "string", "boolean", "integer":
+		return &Schema{Type: t}, nil
+	case "int64":
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case "date-time":
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	}
+	return nil, fmt.Errorf("unknown parameter type %s", t)
+}
+
+// response parses <code> [<media type> {<schema>}] <description>
+func (g *generator) response(fields []string) (string, *Response, error) {
+	if len(fields) < 2 {
+		return "", nil, fmt.Errorf("want a status code and a description")
+	}
+	code := fields[0]
+	if _, err := strconv.Atoi(code); err != nil {
+		return "", nil, fmt.Errorf("invalid status code %s", code)
+	}
+	r := &Response{}
+	rest := fields[1:]
+	if strings.Contains(rest[0], "/") {
+		if len(rest) < 2 {
+			return "", nil, fmt.Errorf("media type %s wants a {schema}", rest[0])
+		}
+		s, err := g.schemaToken(rest[1])
+		if err != nil {
+			return "", nil, err
+		}
+		r.Content = map[string]*MediaType{rest[0]: {Schema: s}}
+		rest = rest[2:]
+	}
+	r.Description = strings.Join(rest, " ")
+	if r.Description == "" {
+		return "", nil, fmt.Errorf("response without description")
+	}
+	return code, r, nil
+}
+
+// schemaToken parses {<schema>}: a type of the package, []<schema>, string, binary or object
+func (g *generator) schemaToken(token string) (*Schema, error) {
+	if !strings.HasPrefix(token, "{") || !strings.HasSuffix(token, "}") {
+		return nil, fmt.Errorf("want a {schema}, not %s", token)
+	}
+	return g.namedSchema(strings.Trim(token, "{}"))
+}
+
+func (g *generator) namedSchema(name string) (*Schema, error) {
+	if elem, ok := strings.CutPrefix(name, "[]"); ok {
+		items, err := g.namedSchema(elem)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	}
+	switch name {
+	case "string", "object":
+		return &Schema{Type: name}, nil
+	case "binary":
+		return &Schema{Type: "string", Format: "binary"}, nil
+	}
+	return g.typeSchema(name)
+}
+
+// typeSchema returns the schema of a type of the package: a reference to its component for structs, which
+// is generated on first use, and the schema of its underlying type otherwise
+func (g *generator) typeSchema(name string) (*Schema, error) {
+	decl, ok := g.types[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	st, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return g.exprSchema(decl.spec.Type)
+	}
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := g.doc.Components.Schemas[name]; ok {
+		return ref, nil
+	}
+	// registered before its fields so recursive types terminate
+	s := &Schema{}
+	g.doc.Components.Schemas[name] = s
+	generated, err := g.structSchema(st, decl.doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	*s = *generated
+	return ref, nil
+}
+
+func (g *generator) exprSchema(expr ast.Expr) (*Schema, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &Schema{Type: "string"}, nil
+		case "bool":
+			return &Schema{Type: "boolean"}, nil
+		case "int", "uint":
+			return &Schema{Type: "integer"}, nil
+		case "int32", "uint32":
+			return &Schema{Type: "integer", Format: "int32"}, nil
+		case "int64", "uint64":
+			return &Schema{Type: "integer", Format: "int64"}, nil
+		case "float32", "float64":
+			return &Schema{Type: "number"}, nil
+		case "any":
+			return &Schema{}, nil
+		}
+		return g.typeSchema(t.Name)
+	case *ast.StarExpr:
+		return g.exprSchema(t.X)
+	case *ast.ArrayType:
+		if elem, ok := t.Elt.(*ast.Ident); ok && elem.Name == "byte" {
+			return &Schema{Type: "string", Format: "binary"}, nil
+		}
+		items, err := g.exprSchema(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		if key, ok := t.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, fmt.Errorf("map keys must be strings")
+		}
+		values, err := g.exprSchema(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.InterfaceType:
+		return &Schema{}, nil
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Time" {
+			return &Schema{Type: "string", Format: "date-time"}, nil
+		}
+	}
+	var b bytes.Buffer
+	_ = printer.Fprint(&b, token.NewFileSet(), expr)
+	return nil, fmt.Errorf("unsupported type %s", b.String())
+}
+
+func (g *generator) structSchema(st *ast.StructType, doc *ast.CommentGroup) (*Schema, error) {
+	description, _ := docText(doc)
+	s := &Schema{Type: "object"}
+	var embedded []*Schema
+
+	for _, field := range st.Fields.List {
+		jsonName := jsonName(field)
+		if jsonName == "-" {
+			continue
+		}
+		if len(field.Names) == 0 {
+			fs, err := g.exprSchema(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			embedded = append(embedded, fs)
+			continue
+		}
+		text, markers := docText(field.Doc)
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			property := jsonName
+			if property == "" {
+				property = name.Name
+			}
+			fs, err := g.exprSchema(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name.Name, err)
+			}
+			for _, m := range markers {
+				key, value, _ := strings.Cut(m, "=")
+				if key == "required" {
+					s.Required = append(s.Required, property)
+					continue
+				}
+				if err := applyMarker(fs, key, value); err != nil {
+					return nil, fmt.Errorf("field %s: %w", name.Name, err)
+				}
+			}
+			if text != "" {
+				if fs.Ref != "" {
+					// siblings of $ref are ignored, so the description goes next to it
+					fs = &Schema{AllOf: []*Schema{fs}}
+				}
+				fs.Description = text
+			}
+			if s.Properties == nil {
+				s.Properties = &Properties{}
+			}
+			s.Properties.add(property, fs)
+		}
+	}
+
+	if len(embedded) == 0 {
+		s.Description = description
+		return s, nil
+	}
+	return &Schema{Description: description, AllOf: append(embedded, s)}, nil
+}
+
+// applyMarker applies a +key=value marker of a field, or a modifier of a parameter, to its schema
+func applyMarker(s *Schema, key, value string) error {
+	switch key {
+	case "format":
+		s.Format = value
+	case "enum":
+		s.Enum = strings.Split(value, ",")
+	case "default":
+		switch s.Type {
+		case "boolean":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid default %s: %w", value, err)
+			}
+			s.Default = b
+		case "integer":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid default %s: %w", value, err)
+			}
+			s.Default = n
+		default:
+			s.Default = value
+		}
+	case "minimum", "maxLength":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %s: %w", key, value, err)
+		}
+		if key == "minimum" {
+			s.Minimum = &n
+		} else {
+			s.MaxLength = &n
+		}
+	default:
+		return fmt.Errorf("unknown marker %s", key)
+	}
+	return nil
+}
+
+// docText splits a doc comment into its text, with lines joined, and its +markers
+func docText(doc *ast.CommentGroup) (string, []string) {
+	if doc == nil {
+		return "", nil
+	}
+	var text, markers []string
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "+"):
+			// kubebuilder markers of API types are not ours
+			if !strings.Contains(line, ":") {
+				markers = append(markers, strings.TrimPrefix(line, "+"))
+			}
+		default:
+			text = append(text, line)
+		}
+	}
+	return strings.Join(text, " "), markers
+}
+
+// jsonName returns the name a field's json tag gives it, if any
+func jsonName(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	name, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+	return name
+}
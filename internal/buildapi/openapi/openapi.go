@@ -0,0 +1,189 @@
+// Package openapi generates the OpenAPI spec of the build API from its Go source, so the spec cannot drift
+// from the handlers and types it describes. Handlers are annotated in their doc comments, swag style:
+//
+//	@Summary     <text>
+//	@Description <text>                     repeatable; the lines form paragraphs
+//	@ID          <operationId>
+//	@Param       <Ref>                      a shared parameter, see @Parameter
+//	@Param       <name> <in> <type> <required|optional> [default=<v>] [minimum=<n>] [<description>]
+//	@Body        <media type> {<schema>}
+//	@Success     <code> [<media type> {<schema>}] <description>
+//	@Failure     <code> [<media type> {<schema>}] <description>
+//	@Header      <code> <name> <description>   a header of a response
+//	@Router      <path> [<method>]
+//
+// Parameter types are string, boolean, integer, int64 and date-time, optionally as []<type>. Schemas are a
+// Go type of the package, []<type>, or one of string, binary and object. Path parameters not declared with
+// @Param are added from the path.
+//
+// The API itself is described on the function registering the routes with @Title, @Version and
+// @Parameter <Ref> <name> <in> <type> <required|optional> [<description>].
+//
+// Schemas are generated from the Go types reachable from the annotations: JSON field names come from the
+// json tags, descriptions from the doc comments of types and fields, and embedded structs become allOf.
+// Doc comment lines of a field starting with + are markers rather than text: +required, +format=<f>,
+// +enum=<a>,<b>, +default=<v>, +minimum=<n> and +maxLength=<n>.
+package openapi
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Document is an OpenAPI 3.0 document, limited to what the build API uses
+type Document struct {
+	OpenAPI    string               `yaml:"openapi"`
+	Info       Info                 `yaml:"info"`
+	Servers    []Server             `yaml:"servers"`
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components Components           `yaml:"components"`
+}
+
+type Info struct {
+	Title   string `yaml:"title"`
+	Version string `yaml:"version"`
+}
+
+type Server struct {
+	URL string `yaml:"url"`
+}
+
+type Components struct {
+	Parameters map[string]*Parameter `yaml:"parameters,omitempty"`
+	Schemas    map[string]*Schema    `yaml:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of a path, one per method
+type PathItem struct {
+	Get    *Operation `yaml:"get,omitempty"`
+	Put    *Operation `yaml:"put,omitempty"`
+	Post   *Operation `yaml:"post,omitempty"`
+	Delete *Operation `yaml:"delete,omitempty"`
+	Patch  *Operation `yaml:"patch,omitempty"`
+}
+
+// Operations returns the operations of the path by HTTP method
+func (p *PathItem) Operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+	for method, op := range map[string]*Operation{"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete, "PATCH": p.Patch} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+// operation returns the slot of method in the path, or nil for unsupported methods
+func (p *PathItem) operation(method string) **Operation {
+	switch method {
+	case "get":
+		return &p.Get
+	case "put":
+		return &p.Put
+	case "post":
+		return &p.Post
+	case "delete":
+		return &p.Delete
+	case "patch":
+		return &p.Patch
+	}
+	return nil
+}
+
+type Operation struct {
+	Summary     string               `yaml:"summary,omitempty"`
+	Description string               `yaml:"description,omitempty"`
+	OperationID string               `yaml:"operationId"`
+	Parameters  []*Parameter         `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody         `yaml:"requestBody,omitempty"`
+	Responses   map[string]*Response `yaml:"responses"`
+}
+
+type Parameter struct {
+	Ref         string  `yaml:"$ref,omitempty"`
+	In          string  `yaml:"in,omitempty"`
+	Name        string  `yaml:"name,omitempty"`
+	Description string  `yaml:"description,omitempty"`
+	Required    bool    `yaml:"required,omitempty"`
+	Schema      *Schema `yaml:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*MediaType `yaml:"content"`
+}
+
+type Response struct {
+	Description string                `yaml:"description"`
+	Headers     map[string]*Header    `yaml:"headers,omitempty"`
+	Content     map[string]*MediaType `yaml:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `yaml:"description,omitempty"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Schema is a JSON schema, in the OpenAPI 3.0 dialect
+type Schema struct {
+	Ref                  string      `yaml:"$ref,omitempty"`
+	AllOf                []*Schema   `yaml:"allOf,omitempty"`
+	Type                 string      `yaml:"type,omitempty"`
+	Format               string      `yaml:"format,omitempty"`
+	Description          string      `yaml:"description,omitempty"`
+	Enum                 []string    `yaml:"enum,omitempty,flow"`
+	Default              any         `yaml:"default,omitempty"`
+	Minimum              *int64      `yaml:"minimum,omitempty"`
+	MaxLength            *int64      `yaml:"maxLength,omitempty"`
+	Required             []string    `yaml:"required,omitempty,flow"`
+	Properties           *Properties `yaml:"properties,omitempty"`
+	Items                *Schema     `yaml:"items,omitempty"`
+	AdditionalProperties *Schema     `yaml:"additionalProperties,omitempty"`
+}
+
+// Properties are the properties of an object schema, kept in the order of the fields of its Go type
+type Properties struct {
+	names   []string
+	schemas map[string]*Schema
+}
+
+func (p *Properties) add(name string, s *Schema) {
+	if p.schemas == nil {
+		p.schemas = map[string]*Schema{}
+	}
+	if _, ok := p.schemas[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.schemas[name] = s
+}
+
+// Get returns the schema of a property
+func (p *Properties) Get(name string) *Schema {
+	return p.schemas[name]
+}
+
+func (p *Properties) MarshalYAML() (any, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range p.names {
+		value := &yaml.Node{}
+		if err := value.Encode(p.schemas[name]); err != nil {
+			return nil, err
+		}
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+	}
+	return n, nil
+}
+
+func (p *Properties) UnmarshalYAML(n *yaml.Node) error {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		s := &Schema{}
+		if err := n.Content[i+1].Decode(s); err != nil {
+			return err
+		}
+		p.add(n.Content[i].Value, s)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package buildapi
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/throttle"
+)
+
+// Directions of the transfers the build API counts
+const (
+	directionDownload = "download"
+	directionUpload   = "upload"
+)
+
+// RateLimitConfig caps the bandwidth of artifact downloads and file uploads, in bytes per second written
+// like curl's --limit-rate (512K, 10M, 1G). Each transfer is held to the per-stream limit of its direction
+// and all transfers of a direction together to its total limit; empty or zero means no limit.
+type RateLimitConfig struct {
+	Download          string
+	DownloadPerStream string
+	Upload            string
+	UploadPerStream   string
+}
+
+// RateLimitConfigFromEnv reads $BUILD_API_DOWNLOAD_RATE_LIMIT, $BUILD_API_DOWNLOAD_STREAM_RATE_LIMIT,
+// $BUILD_API_UPLOAD_RATE_LIMIT and $BUILD_API_UPLOAD_STREAM_RATE_LIMIT
+func RateLimitConfigFromEnv() RateLimitConfig {
+	return RateLimitConfig{
+		Download:          strings.TrimSpace(os.Getenv("BUILD_API_DOWNLOAD_RATE_LIMIT")),
+		DownloadPerStream: strings.TrimSpace(os.Getenv("BUILD_API_DOWNLOAD_STREAM_RATE_LIMIT")),
+		Upload:            strings.TrimSpace(os.Getenv("BUILD_API_UPLOAD_RATE_LIMIT")),
+		UploadPerStream:   strings.TrimSpace(os.Getenv("BUILD_API_UPLOAD_STREAM_RATE_LIMIT")),
+	}
+}
+
+// bandwidthLimits are the parsed limits of one direction
+type bandwidthLimits struct {
+	// total is shared by every transfer of the direction
+	total *rate.Limiter
+	// perStream is the rate of the bucket each transfer gets of its own, 0 for none
+	perStream int64
+}
+
+func parseBandwidthLimits(total, perStream string) (bandwidthLimits, error) {
+	t, err := throttle.ParseRate(total)
+	if err != nil {
+		return bandwidthLimits{}, err
+	}
+	s, err := throttle.ParseRate(perStream)
+	if err != nil {
+		return bandwidthLimits{}, err
+	}
+	return bandwidthLimits{total: throttle.NewLimiter(t), perStream: s}, nil
+}
+
+// ConfigureRateLimits limits the bandwidth of downloads and uploads as cfg says
+func (a *APIServer) ConfigureRateLimits(cfg RateLimitConfig) error {
+	download, err := parseBandwidthLimits(cfg.Download, cfg.DownloadPerStream)
+	if err != nil {
+		return fmt.Errorf("download rate limit: %w", err)
+	}
+	upload, err := parseBandwidthLimits(cfg.Upload, cfg.UploadPerStream)
+	if err != nil {
+		return fmt.Errorf("upload rate limit: %w", err)
+	}
+	a.downloadLimits, a.uploadLimits = download, upload
+	return nil
+}
+
+// observeTransfer counts the bytes a transfer in direction moved and the time it was held back
+func observeTransfer(direction string) throttle.Observer {
+	bytes := transferBytesTotal.WithLabelValues(direction)
+	throttled := transferThrottledSecondsTotal.WithLabelValues(direction)
+	return func(n int, waited time.Duration) {
+		bytes.Add(float64(n))
+		throttled.Add(waited.Seconds())
+	}
+}
+
+// limitDownload returns w held to the download limits for the request of c
+func (a *APIServer) limitDownload(c *gin.Context, w io.Writer) io.Writer {
+	return throttle.Writer(c.Request.Context(), w, observeTransfer(directionDownload),
+		a.downloadLimits.total, throttle.NewLimiter(a.downloadLimits.perStream))
+}
+
+// limitUpload holds the body of the request of c to the upload limits
+func (a *APIServer) limitUpload(c *gin.Context) {
+	body := c.Request.Body
+	limited := throttle.Reader(c.Request.Context(), body, observeTransfer(directionUpload),
+		a.uploadLimits.total, throttle.NewLimiter(a.uploadLimits.perStream))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{limited, body}
+}
@@ -0,0 +1,192 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// dependencyCheckInterval is how often the APIs the build API depends on are looked up again, so that
+// installing a missing one takes effect without a restart
+const dependencyCheckInterval = time.Minute
+
+// dependency is an API the build API cannot serve builds without
+type dependency struct {
+	name         string
+	groupVersion string
+	resources    []string
+	remediation  string
+}
+
+var dependencies = []dependency{
+	{
+		name:         "Automotive Dev CRDs",
+		groupVersion: "automotive.sdv.cloud.redhat.com/v1",
+		resources:    []string{"imagebuilds", "automotivedevs", "images"},
+		remediation:  "Install the operator's CustomResourceDefinitions, e.g. with 'make install' or by installing the operator bundle",
+	},
+	{
+		name:         "Tekton Pipelines",
+		groupVersion: "tekton.dev/v1",
+		resources:    []string{"taskruns", "pipelineruns"},
+		remediation:  "Install the OpenShift Pipelines operator, or Tekton Pipelines v0.50 or newer on other clusters",
+	},
+}
+
+// resourceDiscoverer looks up the resources the API server serves; k8s.Adapter implements it
+type resourceDiscoverer interface {
+	ServedResources(ctx context.Context, groupVersion string) ([]string, error)
+}
+
+// readinessGate checks the dependencies of the build API when it starts and every interval after. Until
+// the first check finished requests are let through, as the dependencies are usually there.
+type readinessGate struct {
+	discoverer resourceDiscoverer
+	interval   time.Duration
+	log        logr.Logger
+
+	mu     sync.RWMutex
+	status ReadyzResponse
+}
+
+func newReadinessGate(discoverer resourceDiscoverer, log logr.Logger) *readinessGate {
+	g := &readinessGate{discoverer: discoverer, interval: dependencyCheckInterval, log: log}
+	for _, d := range dependencies {
+		g.status.Dependencies = append(g.status.Dependencies, DependencyStatus{
+			Name: d.name, APIVersion: d.groupVersion, Message: "not checked yet",
+		})
+	}
+	return g
+}
+
+// run checks the dependencies right away and then every interval until ctx is done
+func (g *readinessGate) run(ctx context.Context) {
+	wait.UntilWithContext(ctx, g.check, g.interval)
+}
+
+func (g *readinessGate) check(ctx context.Context) {
+	status := ReadyzResponse{Ready: true, CheckedAt: time.Now().UTC().Format(time.RFC3339)}
+	for _, d := range dependencies {
+		s := checkDependency(ctx, g.discoverer, d)
+		status.Ready = status.Ready && s.Available
+		status.Dependencies = append(status.Dependencies, s)
+	}
+
+	g.mu.Lock()
+	was := g.status
+	g.status = status
+	g.mu.Unlock()
+
+	if was.CheckedAt == "" || was.Ready != status.Ready {
+		for _, s := range status.Dependencies {
+			if !s.Available {
+				g.log.Info("build API dependency unavailable", "dependency", s.Name, "apiVersion", s.APIVersion, "reason", s.Message, "remediation", s.Remediation)
+			}
+		}
+		if status.Ready {
+			g.log.Info("build API dependencies available")
+		}
+	}
+}
+
+func checkDependency(ctx context.Context, discoverer resourceDiscoverer, d dependency) DependencyStatus {
+	s := DependencyStatus{Name: d.name, APIVersion: d.groupVersion}
+	served, err := discoverer.ServedResources(ctx, d.groupVersion)
+	switch {
+	case k8serrors.IsNotFound(err):
+		s.Missing = d.resources
+		s.Message = fmt.Sprintf("the API server does not serve %s", d.groupVersion)
+		s.Remediation = d.remediation
+	case err != nil:
+		s.Message = fmt.Sprintf("looking up %s: %v", d.groupVersion, err)
+		s.Remediation = "Check that the build API can reach the Kubernetes API server"
+	default:
+		for _, r := range d.resources {
+			if !slices.Contains(served, r) {
+				s.Missing = append(s.Missing, r)
+			}
+		}
+		if len(s.Missing) > 0 {
+			s.Message = fmt.Sprintf("%s does not serve %s", d.groupVersion, strings.Join(s.Missing, ", "))
+			s.Remediation = d.remediation
+		}
+	}
+	s.Available = s.Message == ""
+	return s
+}
+
+// current returns the outcome of the last check
+func (g *readinessGate) current() ReadyzResponse {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.status
+}
+
+// blocking returns the dependencies found missing by the last check, nil before the first check
+func (g *readinessGate) blocking() []DependencyStatus {
+	status := g.current()
+	if status.CheckedAt == "" || status.Ready {
+		return nil
+	}
+	var missing []DependencyStatus
+	for _, s := range status.Dependencies {
+		if !s.Available {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// @Summary Readiness check
+// @Description Reports whether the CRDs of the operator and the Tekton APIs builds run on are installed. They
+// @Description are checked when the server starts and every minute.
+// @ID readyz
+// @Success 200 application/json {ReadyzResponse} Every dependency is installed
+// @Failure 503 application/json {ReadyzResponse} A dependency is missing or has not been checked yet; each tells how to install it
+// @Router /v1/readyz [get]
+func (a *APIServer) handleReadyz(c *gin.Context) {
+	if a.readiness == nil {
+		writeJSON(c, http.StatusOK, ReadyzResponse{Ready: true, Dependencies: []DependencyStatus{}})
+		return
+	}
+	status := a.readiness.current()
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(c, code, status)
+}
+
+// dependencyMiddleware answers 503 while a dependency of the builds endpoints is missing, instead of letting
+// requests fail with the API server's errors about unknown kinds
+func (a *APIServer) dependencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.readiness == nil {
+			c.Next()
+			return
+		}
+		missing := a.readiness.blocking()
+		if len(missing) == 0 {
+			c.Next()
+			return
+		}
+		names := make([]string, 0, len(missing))
+		for _, s := range missing {
+			names = append(names, s.Name)
+		}
+		c.Header("Retry-After", fmt.Sprintf("%d", int(dependencyCheckInterval.Seconds())))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":        fmt.Sprintf("the build API is not ready: %s missing; see /v1/readyz", strings.Join(names, ", ")),
+			"dependencies": missing,
+		})
+	}
+}
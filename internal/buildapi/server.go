@@ -1,57 +1,93 @@
 package buildapi
 
 import (
-	"archive/tar"
 	"context"
 	_ "embed"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"path"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
-	corev1 "k8s.io/api/core/v1"
-	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
-	kscheme "k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/remotecommand"
-	"sigs.k8s.io/controller-runtime/pkg/client"
-
-	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
 	authnv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
 )
 
 type APIServer struct {
-	server *http.Server
-	router *gin.Engine
-	addr   string
-	log    logr.Logger
-}
-
+	server   *http.Server
+	router   *gin.Engine
+	addr     string
+	log      logr.Logger
+	svc      BuildService
+	reviewer TokenReviewer
+	// startCache, if set, runs the informer cache the cluster serves reads from
+	startCache func(context.Context) error
+	// certWatcher, if set, reloads the TLS certificate the server presents
+	certWatcher *certwatcher.CertWatcher
+	// readiness, if set, gates the builds endpoints on the CRDs and Tekton APIs being installed
+	readiness *readinessGate
+	// downloadLimits and uploadLimits cap the bandwidth of transfers; see ConfigureRateLimits
+	downloadLimits bandwidthLimits
+	uploadLimits   bandwidthLimits
+	// uploadSweepInterval, if set, is how often the builds of the server's namespace that were abandoned while
+	// uploading are cancelled; see BuildService.SweepUploads
+	uploadSweepInterval time.Duration
+	// uiDisabled turns off the web UI; see DisableUI
+	uiDisabled bool
+}
+
+// defaultUploadSweepInterval is how often the build API sweeps its namespace for abandoned uploads
+const defaultUploadSweepInterval = 5 * time.Minute
+
+// TokenReviewer authenticates bearer tokens and authorizes their holders
+type TokenReviewer interface {
+	// ReviewToken returns the user and groups of the token's holder and whether the token is valid
+	ReviewToken(ctx context.Context, token string) (authnv1.UserInfo, bool, error)
+	// ReviewAccess reports whether the holder of token may perform verb on resource in namespace
+	ReviewAccess(ctx context.Context, token, namespace, resource, verb string) (bool, error)
+}
+
+//go:generate go run ./openapi/gen -o openapi.yaml -o ../../docs/openapi.yaml
+
+// embeddedOpenAPI is the spec generated from the annotations of the handlers and the types they exchange
+//
 //go:embed openapi.yaml
 var embeddedOpenAPI []byte
 
 type ctxKeyReqID struct{}
 
-// NewAPIServer creates a new API server
+// NewAPIServer creates a new API server backed by the cluster it runs in
 func NewAPIServer(addr string, logger logr.Logger) *APIServer {
+	cluster := k8s.NewAdapter(k8s.ResolveNamespace())
+	artifacts, err := artifactCacheFromEnv()
+	if err != nil {
+		logger.Error(err, "artifact cache disabled")
+	}
+	svc := &buildService{cluster: cluster, artifacts: artifacts, consoleURL: consoleURLFromEnv()}
+	a := NewAPIServerWithService(addr, logger, svc, cluster)
+	a.startCache = cluster.StartCache
+	a.readiness = newReadinessGate(cluster, logger)
+	a.uploadSweepInterval = defaultUploadSweepInterval
+	return a
+}
+
+// NewAPIServerWithService creates an API server on top of an arbitrary BuildService, e.g. a fake in tests
+func NewAPIServerWithService(addr string, logger logr.Logger, svc BuildService, reviewer TokenReviewer) *APIServer {
 	// Gin mode should be controlled by environment, not by which constructor is used
 	if os.Getenv("GIN_MODE") == "" {
 		// Default to release mode for production safety
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	a := &APIServer{addr: addr, log: logger}
+	a := &APIServer{addr: addr, log: logger, svc: svc, reviewer: reviewer}
 	a.router = a.createRouter()
 	a.server = &http.Server{Addr: addr, Handler: a.router}
 	return a
@@ -59,10 +95,34 @@ func NewAPIServer(addr string, logger logr.Logger) *APIServer {
 
 // Start implements manager.Runnable
 func (a *APIServer) Start(ctx context.Context) error {
+	if a.startCache != nil {
+		// requests are answered from the API server until the cache has synced
+		go func() {
+			if err := a.startCache(ctx); err != nil {
+				a.log.Error(err, "build-api cache stopped; reading from the API server")
+			}
+		}()
+	}
+
+	a.startCertWatcher(ctx)
+
+	if a.readiness != nil {
+		go a.readiness.run(ctx)
+	}
+	if a.uploadSweepInterval > 0 {
+		go wait.UntilWithContext(ctx, a.sweepUploads, a.uploadSweepInterval)
+	}
 
 	go func() {
-		a.log.Info("build-api listening", "addr", a.addr)
-		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		a.log.Info("build-api listening", "addr", a.addr, "tls", a.server.TLSConfig != nil)
+		var err error
+		if a.server.TLSConfig != nil {
+			// the certificate comes from the TLS config's GetCertificate
+			err = a.server.ListenAndServeTLS("", "")
+		} else {
+			err = a.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			a.log.Error(err, "build-api server error")
 		}
 	}()
@@ -81,49 +141,143 @@ func (a *APIServer) Start(ctx context.Context) error {
 	return nil
 }
 
+// sweepUploads cancels the builds of the server's namespace that were abandoned while uploading and logs
+// what was reclaimed
+func (a *APIServer) sweepUploads(ctx context.Context) {
+	resp, err := a.svc.SweepUploads(ctx, uploadSweepRequester, false)
+	if err != nil {
+		a.log.Error(err, "upload sweep failed")
+		return
+	}
+	for _, b := range resp.Builds {
+		a.log.Info("cancelled build abandoned while uploading", "build", b.Name, "idle", b.Idle,
+			"workspaces", b.Workspaces, "workspaceBytes", b.WorkspaceBytes, "uploadPod", b.UploadPod)
+	}
+}
+
+// createRouter registers the routes of the API. Every route's handler is annotated with the operation it
+// serves, from which go generate writes openapi.yaml; see package openapi.
+//
+// @Title Automotive Build API
+// @Version 1.0.0
+// @Parameter Namespace X-Build-Namespace header string optional Namespace the request acts on. Defaults to the server's namespace (see /v1/info); other namespaces require the caller to be allowed to get, or for writes create/patch, the resource there.
 func (a *APIServer) createRouter() *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Recovery())
 
 	router.Use(func(c *gin.Context) {
-		reqID := uuid.New().String()
-		c.Set("reqID", reqID)
-		a.log.Info("http request", "method", c.Request.Method, "path", c.Request.URL.Path, "reqID", reqID)
+		ids := requestIDs(c)
+		c.Set("reqID", ids.RequestID)
+		c.Header(correlation.RequestIDHeader, ids.RequestID)
+		if ids.Traceparent != "" {
+			c.Header(correlation.TraceparentHeader, ids.Traceparent)
+		}
+		c.Request = c.Request.WithContext(correlation.WithIDs(c.Request.Context(), ids))
+		kv := []any{"method", c.Request.Method, "path", c.Request.URL.Path, "reqID", ids.RequestID}
+		if ids.Traceparent != "" {
+			kv = append(kv, "traceparent", ids.Traceparent)
+		}
+		a.log.Info("http request", kv...)
 		c.Next()
 	})
 
+	router.GET("/ui", a.handleUIRedirect)
+	router.GET("/ui/*path", a.handleUI)
+
 	v1 := router.Group("/v1")
 	{
-		v1.GET("/healthz", func(c *gin.Context) {
-			c.String(http.StatusOK, "ok")
-		})
+		v1.GET("/healthz", handleHealthz)
+		v1.GET("/readyz", a.handleReadyz)
+		v1.GET("/openapi.yaml", handleOpenAPI)
+		v1.GET("/metrics", handleMetrics)
 
-		v1.GET("/openapi.yaml", func(c *gin.Context) {
-			c.Data(http.StatusOK, "application/yaml", embeddedOpenAPI)
-		})
+		v1.GET("/info", a.authMiddleware(), a.handleServerInfo)
+		v1.GET("/catalog", a.authMiddleware(), a.handleGetCatalog)
+		v1.POST("/lint", a.authMiddleware(), a.handleLint)
+		v1.GET("/stats", a.authMiddleware(), a.namespaceMiddleware("imagebuilds", "create"), a.handleGetStats)
+		v1.GET("/quota", a.authMiddleware(), a.namespaceMiddleware("imagebuilds", "create"), a.handleGetQuota)
+		v1.POST("/maintenance/sweep", a.authMiddleware(), a.namespaceMiddleware("imagebuilds", "patch"), a.handleSweepUploads)
 
-		// Streaming endpoints without authentication (handled by OAuth proxy)
-		v1.GET("/builds/:name/logs/sse", a.handleStreamLogsSSE)
+		// Git providers authenticate their deliveries with the webhook secret instead of a token
+		v1.POST("/hooks/git", a.handleGitHook)
 
 		// Builds endpoints with authentication middleware
 		buildsGroup := v1.Group("/builds")
-		buildsGroup.Use(a.authMiddleware())
+		buildsGroup.Use(a.authMiddleware(), a.dependencyMiddleware(), a.namespaceMiddleware("imagebuilds", "create"), a.buildAccessMiddleware())
 		{
 			buildsGroup.POST("", a.handleCreateBuild)
 			buildsGroup.GET("", a.handleListBuilds)
 			buildsGroup.GET("/:name", a.handleGetBuild)
+			buildsGroup.PATCH("/:name", a.handleUpdateBuild)
+			buildsGroup.DELETE("/:name", a.handleDeleteBuild)
+			buildsGroup.POST("/:name/cancel", a.handleCancelBuild)
+			buildsGroup.POST("/:name/promote", a.handlePromoteBuild)
+			buildsGroup.POST("/:name/convert", a.handleConvertArtifact)
 			buildsGroup.GET("/:name/logs", a.handleStreamLogs)
+			buildsGroup.GET("/:name/logs/archive", a.handleStreamLogsArchive)
+			buildsGroup.GET("/:name/logs/sse", a.handleStreamLogsSSE)
 			buildsGroup.GET("/:name/artifacts", a.handleListArtifacts)
+			buildsGroup.GET("/:name/artifacts.tar", a.handleStreamArtifactsTar)
 			buildsGroup.GET("/:name/artifacts/:file", a.handleStreamArtifactPart)
 			buildsGroup.GET("/:name/artifact/:filename", a.handleStreamArtifactByFilename)
+			buildsGroup.GET("/:name/workspace.tar", a.handleStreamWorkspaceTar)
+			buildsGroup.GET("/:name/scan-report", a.handleStreamScanReport)
 			buildsGroup.GET("/:name/template", a.handleGetBuildTemplate)
+			buildsGroup.GET("/:name/taskrun", a.handleGetTaskRun)
+			buildsGroup.GET("/:name/timeline", a.handleGetBuildTimeline)
+			buildsGroup.GET("/:name/usage", a.handleGetBuildUsage)
 			buildsGroup.POST("/:name/uploads", a.handleUploadFiles)
+			buildsGroup.GET("/:name/uploads/file", a.handleGetUploadedFile)
+			buildsGroup.POST("/:name/uploads/file", a.handleStartUpload)
+			buildsGroup.PUT("/:name/uploads/file", a.handleWriteUploadChunk)
+			buildsGroup.POST("/:name/uploads/file/finish", a.handleFinishUpload)
+			buildsGroup.POST("/:name/uploads/complete", a.handleCompleteUploads)
+			buildsGroup.PUT("/:name/uploads/totals", a.handleSetUploadTotals)
+		}
+
+		imagesGroup := v1.Group("/images")
+		imagesGroup.Use(a.authMiddleware())
+		{
+			imagesGroup.GET("", a.namespaceMiddleware("images", "list"), a.handleListImages)
+			imagesGroup.POST("", a.namespaceMiddleware("images", "create"), a.handleRegisterImage)
+			imagesGroup.GET("/:name", a.namespaceMiddleware("images", "get"), a.handleGetImage)
+			imagesGroup.DELETE("/:name", a.namespaceMiddleware("images", "delete"), a.handleDeleteImage)
+			imagesGroup.POST("/:name/lifecycle", a.namespaceMiddleware("images", "patch"), a.handleSetImageLifecycle)
 		}
 	}
 
 	return router
 }
 
+// requestIDs returns the request ID and traceparent the client sent, generating a request ID when it sent
+// none or one that cannot be logged as is. An invalid traceparent is dropped.
+func requestIDs(c *gin.Context) correlation.IDs {
+	ids := correlation.IDs{RequestID: strings.TrimSpace(c.GetHeader(correlation.RequestIDHeader))}
+	if !correlation.ValidRequestID(ids.RequestID) {
+		ids.RequestID = uuid.New().String()
+	}
+	if tp := strings.TrimSpace(c.GetHeader(correlation.TraceparentHeader)); correlation.ValidTraceparent(tp) {
+		ids.Traceparent = tp
+	}
+	return ids
+}
+
+// @Summary Health check
+// @ID healthz
+// @Success 200 text/plain {string} OK
+// @Router /v1/healthz [get]
+func handleHealthz(c *gin.Context) {
+	c.String(http.StatusOK, "ok")
+}
+
+// @Summary Get this OpenAPI spec
+// @ID getOpenAPI
+// @Success 200 application/yaml {string} OpenAPI spec of the build API
+// @Router /v1/openapi.yaml [get]
+func handleOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", embeddedOpenAPI)
+}
+
 // StartServer starts the REST API server on the given address in a goroutine and returns the server
 func StartServer(addr string, logger logr.Logger) (*http.Server, error) {
 	api := NewAPIServer(addr, logger)
@@ -148,1663 +302,83 @@ func (a *APIServer) authMiddleware() gin.HandlerFunc {
 	}
 }
 
-func (a *APIServer) handleCreateBuild(c *gin.Context) {
-	a.log.Info("create build", "reqID", c.GetString("reqID"))
-	createBuild(c)
-}
-
-func (a *APIServer) handleListBuilds(c *gin.Context) {
-	a.log.Info("list builds", "reqID", c.GetString("reqID"))
-	listBuilds(c)
-}
-
-func (a *APIServer) handleGetBuild(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("get build", "build", name, "reqID", c.GetString("reqID"))
-	getBuild(c, name)
-}
-
-func (a *APIServer) handleStreamLogs(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("logs requested", "build", name, "reqID", c.GetString("reqID"))
-	streamLogs(c, name)
-}
-
-func (a *APIServer) handleStreamLogsSSE(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("logs SSE requested", "build", name, "reqID", c.GetString("reqID"))
-
-	streamLogsSSE(c, name)
-}
-
-func (a *APIServer) handleListArtifacts(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("artifacts list requested", "build", name, "reqID", c.GetString("reqID"))
-	a.listArtifacts(c, name)
-}
-
-func (a *APIServer) handleStreamArtifactPart(c *gin.Context) {
-	name := c.Param("name")
-	file := c.Param("file")
-	a.log.Info("artifact item requested", "build", name, "file", file, "reqID", c.GetString("reqID"))
-	a.streamArtifactPart(c, name, file)
-}
-
-func (a *APIServer) handleStreamArtifactByFilename(c *gin.Context) {
-	name := c.Param("name")
-	filename := c.Param("filename")
-	a.log.Info("artifact by filename requested", "build", name, "filename", filename, "reqID", c.GetString("reqID"))
-	a.streamArtifactByFilename(c, name, filename)
-}
-
-func (a *APIServer) handleGetBuildTemplate(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("template requested", "build", name, "reqID", c.GetString("reqID"))
-	getBuildTemplate(c, name)
-}
-
-func (a *APIServer) handleUploadFiles(c *gin.Context) {
-	name := c.Param("name")
-	a.log.Info("uploads", "build", name, "reqID", c.GetString("reqID"))
-	uploadFiles(c, name)
-}
-
-func streamLogs(c *gin.Context, name string) {
-	namespace := resolveNamespace()
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	ctx := c.Request.Context()
-	var podName string
-
-	ib := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, ib); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	tr := strings.TrimSpace(ib.Status.TaskRunName)
-	if tr == "" {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "logs not available yet"})
-		return
-	}
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	quickCS, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	pods, err := quickCS.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "tekton.dev/taskRun=" + tr})
-	if err != nil || len(pods.Items) == 0 {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "logs not available yet"})
-		return
-	}
-	podName = pods.Items[0].Name
-
-	cfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	cs, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Set up streaming response
-	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	c.Writer.Header().Set("Transfer-Encoding", "chunked")
-	c.Writer.Header().Set("Cache-Control", "no-cache")
-	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Header().Set("X-Accel-Buffering", "no")
-
-	c.Writer.WriteHeader(http.StatusOK)
-	_, _ = c.Writer.Write([]byte("Waiting for logs...\n"))
-	c.Writer.Flush()
-
-	var hadStream bool
-	streamed := make(map[string]bool)
-	var lastErrs []string
-	for {
-		select {
-		case <-ctx.Done():
+// namespaceMiddleware scopes a request to the namespace selected with NamespaceHeader. Outside the default
+// namespace the caller must be allowed to get resource there (for reads), to delete it (for deletions) or to
+// perform writeVerb (for other writes).
+func (a *APIServer) namespaceMiddleware(resource, writeVerb string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ns := strings.TrimSpace(c.GetHeader(NamespaceHeader))
+		if ns == "" || ns == a.svc.DefaultNamespace() {
+			c.Next()
 			return
-		default:
 		}
-
-		pod, err := cs.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
-		if err != nil {
-			fmt.Fprintf(c.Writer, "\n[Error: %v]\n", err)
-			c.Writer.Flush()
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid namespace %q: %s", ns, strings.Join(errs, "; "))})
+			c.Abort()
 			return
 		}
 
-		stepNames := make([]string, 0, len(pod.Spec.Containers))
-		for _, c := range pod.Spec.Containers {
-			if strings.HasPrefix(c.Name, "step-") {
-				stepNames = append(stepNames, c.Name)
-			}
-		}
-		if len(stepNames) == 0 {
-			for _, c := range pod.Spec.Containers {
-				stepNames = append(stepNames, c.Name)
-			}
-		}
-
-		var errs []string
-
-		for _, cName := range stepNames {
-			if streamed[cName] {
-				continue
-			}
-
-			req := cs.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{Container: cName, Follow: true})
-			stream, err := req.Stream(ctx)
-			if err != nil {
-				errs = append(errs, fmt.Sprintf("%s: %v", cName, err))
-				continue
-			}
-
-			if !hadStream {
-				c.Writer.Flush()
-			}
-			hadStream = true
-
-			_, _ = c.Writer.Write([]byte("\n===== Logs from " + strings.TrimPrefix(cName, "step-") + " =====\n\n"))
-			c.Writer.Flush()
-			// Stream with proper error handling and context cancellation
-			func() {
-				defer stream.Close()
-
-				// Create a buffer for chunked reading
-				buf := make([]byte, 4096)
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-
-					n, err := stream.Read(buf)
-					if n > 0 {
-						if _, writeErr := c.Writer.Write(buf[:n]); writeErr != nil {
-							return
-						}
-						c.Writer.Flush()
-					}
-
-					if err != nil {
-						if err != io.EOF {
-							var errMsg []byte
-							errMsg = fmt.Appendf(errMsg, "\n[Stream error: %v]\n", err)
-							_, _ = c.Writer.Write(errMsg)
-							c.Writer.Flush()
-						}
-						return
-					}
-				}
-			}()
-
-			streamed[cName] = true
-		}
-
-		if len(errs) > 0 {
-			lastErrs = errs
-		}
-
-		if len(streamed) == len(stepNames) {
-			break
-		}
-		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
-			break
-		}
-
-		time.Sleep(2 * time.Second)
-		if !hadStream {
-			// keep-alive to prevent router/proxy 504s while waiting
-			_, _ = c.Writer.Write([]byte("."))
-			if f, ok := c.Writer.(http.Flusher); ok {
-				f.Flush()
-			}
-		}
-	}
-
-	if !hadStream {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "logs unavailable: " + strings.Join(lastErrs, "; ")})
-		return
-	}
-
-	_, _ = c.Writer.Write([]byte("\n[Log streaming completed]\n"))
-	if f, ok := c.Writer.(http.Flusher); ok {
-		f.Flush()
-	}
-}
-
-func streamLogsSSE(c *gin.Context, name string) {
-	c.Writer.Header().Set("Content-Type", "text/event-stream")
-	c.Writer.Header().Set("Cache-Control", "no-cache")
-	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-	c.Writer.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
-	c.Writer.Header().Set("X-Accel-Buffering", "no")
-	c.Writer.WriteHeader(http.StatusOK)
-
-	namespace := resolveNamespace()
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		sendSSEEvent(c, "message", "", fmt.Sprintf("ERROR: Client error: %v", err))
-		c.Writer.Flush()
-		return
-	}
-
-	ctx := c.Request.Context()
-	var podName string
-
-	ib := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, ib); err != nil {
-		if k8serrors.IsNotFound(err) {
-			sendSSEEvent(c, "message", "", "ERROR: Build not found")
-		} else {
-			sendSSEEvent(c, "message", "", fmt.Sprintf("ERROR: Build lookup error: %v", err))
-		}
-		c.Writer.Flush()
-		return
-	}
-	tr := strings.TrimSpace(ib.Status.TaskRunName)
-	if tr == "" {
-		sendSSEEvent(c, "waiting", "", "Build not started yet, waiting for logs...")
-		c.Writer.Flush()
-		return
-	}
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		sendSSEEvent(c, "message", "", fmt.Sprintf("ERROR: Config error: %v", err))
-		c.Writer.Flush()
-		return
-	}
-	quickCS, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		sendSSEEvent(c, "message", "", fmt.Sprintf("ERROR: Kubernetes client error: %v", err))
-		c.Writer.Flush()
-		return
-	}
-	pods, err := quickCS.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "tekton.dev/taskRun=" + tr})
-	if err != nil || len(pods.Items) == 0 {
-		sendSSEEvent(c, "waiting", "", "Build pods not ready yet, waiting for logs...")
-		c.Writer.Flush()
-		return
-	}
-	podName = pods.Items[0].Name
-
-	cfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		sendSSEEvent(c, "message", "", fmt.Sprintf("Config error: %v", err))
-		c.Writer.Flush()
-		return
-	}
-	cs, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		sendSSEEvent(c, "message", "", fmt.Sprintf("Kubernetes client error: %v", err))
-		c.Writer.Flush()
-		return
-	}
-
-	sendSSEEvent(c, "connected", "", "Log stream connected")
-	c.Writer.Flush()
-
-	var hadStream bool
-	streamed := make(map[string]bool)
-	var lastErrs []string
-
-	ticker := time.NewTicker(15 * time.Second)
-	defer ticker.Stop()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				sendSSEEvent(c, "ping", "", "")
-				c.Writer.Flush()
-			}
-		}
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
-			sendSSEEvent(c, "disconnected", "", "Connection closed")
-			c.Writer.Flush()
-			return
-		default:
+		verb := writeVerb
+		switch c.Request.Method {
+		case http.MethodGet:
+			verb = "get"
+		case http.MethodDelete:
+			verb = "delete"
 		}
-
-		pod, err := cs.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		allowed, err := a.reviewer.ReviewAccess(c.Request.Context(), bearerToken(c), ns, resource, verb)
 		if err != nil {
-			sendSSEEvent(c, "error", "", fmt.Sprintf("Error: %v", err))
-			c.Writer.Flush()
+			a.log.Error(err, "access review failed", "namespace", ns, "reqID", c.GetString("reqID"))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "access review failed"})
+			c.Abort()
 			return
 		}
-
-		stepNames := make([]string, 0, len(pod.Spec.Containers))
-		for _, container := range pod.Spec.Containers {
-			if strings.HasPrefix(container.Name, "step-") {
-				stepNames = append(stepNames, container.Name)
-			}
-		}
-		if len(stepNames) == 0 {
-			for _, container := range pod.Spec.Containers {
-				stepNames = append(stepNames, container.Name)
-			}
-		}
-
-		var errs []string
-
-		for _, cName := range stepNames {
-			if streamed[cName] {
-				continue
-			}
-
-			req := cs.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{Container: cName, Follow: true})
-			stream, err := req.Stream(ctx)
-			if err != nil {
-				errs = append(errs, fmt.Sprintf("%s: %v", cName, err))
-				continue
-			}
-
-			if !hadStream {
-				c.Writer.Flush()
-			}
-			hadStream = true
-
-			stepName := strings.TrimPrefix(cName, "step-")
-			sendSSEEvent(c, "step", stepName, "===== Logs from "+stepName+" =====")
-			c.Writer.Flush()
-
-			func() {
-				defer stream.Close()
-
-				buf := make([]byte, 4096)
-				var lineBuffer strings.Builder
-
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					default:
-					}
-
-					n, err := stream.Read(buf)
-					if n > 0 {
-						chunk := string(buf[:n])
-						lineBuffer.WriteString(chunk)
-
-						lines := strings.Split(lineBuffer.String(), "\n")
-						lineBuffer.Reset()
-
-						if len(lines) > 1 {
-							lineBuffer.WriteString(lines[len(lines)-1])
-							lines = lines[:len(lines)-1]
-						}
-
-						for _, line := range lines {
-							if strings.TrimSpace(line) != "" {
-								sendSSEEvent(c, "log", stepName, line)
-								c.Writer.Flush()
-							}
-						}
-					}
-
-					if err != nil {
-						if err != io.EOF {
-							sendSSEEvent(c, "error", stepName, fmt.Sprintf("Stream error: %v", err))
-							c.Writer.Flush()
-						}
-						return
-					}
-				}
-			}()
-
-			streamed[cName] = true
-		}
-
-		if len(errs) > 0 {
-			lastErrs = errs
-		}
-
-		if len(streamed) == len(stepNames) {
-			break
-		}
-		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
-			break
-		}
-
-		time.Sleep(2 * time.Second)
-		if !hadStream {
-			sendSSEEvent(c, "waiting", "", "Waiting for logs...")
-			c.Writer.Flush()
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("not allowed to %s %s in namespace %s", verb, resource, ns)})
+			c.Abort()
+			return
 		}
-	}
-
-	if !hadStream {
-		sendSSEEvent(c, "error", "", "logs unavailable: "+strings.Join(lastErrs, "; "))
-		c.Writer.Flush()
-		return
-	}
-
-	sendSSEEvent(c, "completed", "", "Log streaming completed")
-	c.Writer.Flush()
-}
 
-// convertImageBuildList converts a Kubernetes ImageBuildList to the API response format
-func convertImageBuildList(list *automotivev1.ImageBuildList) []BuildListItem {
-	resp := make([]BuildListItem, 0, len(list.Items))
-	for _, b := range list.Items {
-		resp = append(resp, convertImageBuildToListItem(&b))
+		c.Request = c.Request.WithContext(k8s.WithNamespace(c.Request.Context(), ns))
+		c.Next()
 	}
-	return resp
 }
 
-// convertImageBuildToListItem converts a single ImageBuild to BuildListItem
-func convertImageBuildToListItem(b *automotivev1.ImageBuild) BuildListItem {
-	var startStr, compStr string
-	if b.Status.StartTime != nil {
-		startStr = b.Status.StartTime.Time.Format(time.RFC3339)
-	}
-	if b.Status.CompletionTime != nil {
-		compStr = b.Status.CompletionTime.Time.Format(time.RFC3339)
-	}
-	return BuildListItem{
-		Name:           b.Name,
-		Phase:          b.Status.Phase,
-		Message:        b.Status.Message,
-		RequestedBy:    b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
-		CreatedAt:      b.CreationTimestamp.Time.Format(time.RFC3339),
-		StartTime:      startStr,
-		CompletionTime: compStr,
+// bearerToken returns the token from the Authorization header or the one forwarded by the OAuth proxy
+func bearerToken(c *gin.Context) string {
+	token, _ := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Request.Header.Get("X-Forwarded-Access-Token")
 	}
+	return strings.TrimSpace(token)
 }
 
-func sendSSEEvent(c *gin.Context, event, step, data string) {
-	if event != "" {
-		c.Writer.WriteString("event: " + event + "\n")
-	}
-	if step != "" {
-		c.Writer.WriteString("id: " + step + "\n")
-	}
-	if data != "" {
-		escapedData := strings.ReplaceAll(data, "\n", "\\n")
-		c.Writer.WriteString("data: " + escapedData + "\n")
-	}
-	c.Writer.WriteString("\n")
+func (a *APIServer) isAuthenticated(c *gin.Context) bool {
+	_, ok := a.requester(c)
+	return ok
 }
 
-func createRegistrySecret(ctx context.Context, k8sClient client.Client, namespace, buildName string, creds *RegistryCredentials) (string, error) {
-	if creds == nil || !creds.Enabled {
-		return "", nil
-	}
-
-	secretName := fmt.Sprintf("%s-registry-auth", buildName)
-	secretData := make(map[string][]byte)
-
-	switch creds.AuthType {
-	case "username-password":
-		if creds.RegistryURL == "" || creds.Username == "" || creds.Password == "" {
-			return "", fmt.Errorf("registry URL, username, and password are required for username-password authentication")
-		}
-		secretData["REGISTRY_URL"] = []byte(creds.RegistryURL)
-		secretData["REGISTRY_USERNAME"] = []byte(creds.Username)
-		secretData["REGISTRY_PASSWORD"] = []byte(creds.Password)
-	case "token":
-		if creds.RegistryURL == "" || creds.Token == "" {
-			return "", fmt.Errorf("registry URL and token are required for token authentication")
-		}
-		secretData["REGISTRY_URL"] = []byte(creds.RegistryURL)
-		secretData["REGISTRY_TOKEN"] = []byte(creds.Token)
-	case "docker-config":
-		if creds.DockerConfig == "" {
-			return "", fmt.Errorf("docker config is required for docker-config authentication")
-		}
-		secretData["REGISTRY_AUTH_FILE_CONTENT"] = []byte(creds.DockerConfig)
-	default:
-		return "", fmt.Errorf("unsupported authentication type: %s", creds.AuthType)
+// requester returns the user and groups of the caller's token, reviewed once per request
+func (a *APIServer) requester(c *gin.Context) (authnv1.UserInfo, bool) {
+	if v, ok := c.Get(requesterKey); ok {
+		user, ok := v.(authnv1.UserInfo)
+		return user, ok
 	}
-
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      secretName,
-			Namespace: namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by":                  "build-api",
-				"app.kubernetes.io/part-of":                     "automotive-dev",
-				"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
-				"automotive.sdv.cloud.redhat.com/resource-type": "registry-auth",
-				"automotive.sdv.cloud.redhat.com/build-name":    buildName,
-			},
-		},
-		Type: corev1.SecretTypeOpaque,
-		Data: secretData,
+	token := bearerToken(c)
+	if token == "" {
+		return authnv1.UserInfo{}, false
 	}
-
-	if err := k8sClient.Create(ctx, secret); err != nil {
-		return "", fmt.Errorf("failed to create registry secret: %w", err)
+	user, ok, err := a.reviewer.ReviewToken(c.Request.Context(), token)
+	if err != nil || !ok {
+		return authnv1.UserInfo{}, false
 	}
-
-	return secretName, nil
+	c.Set(requesterKey, user)
+	return user, true
 }
 
-func createBuild(c *gin.Context) {
-	var req BuildRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid JSON: %v", err)})
-		return
-	}
-
-	needsUpload := strings.Contains(req.Manifest, "source_path")
-
-	if req.Name == "" || req.Manifest == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "name and manifest are required"})
-		return
-	}
-
-	if req.Distro == "" {
-		req.Distro = "cs9"
-	}
-	if req.Target == "" {
-		req.Target = "qemu"
-	}
-	if req.Architecture == "" {
-		req.Architecture = "arm64"
-	}
-	if req.ExportFormat == "" {
-		req.ExportFormat = "image"
-	}
-	if req.Mode == "" {
-		req.Mode = "image"
-	}
-
-	if strings.TrimSpace(req.Compression) == "" {
-		req.Compression = "gzip"
-	}
-	if req.Compression != "lz4" && req.Compression != "gzip" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid compression: must be lz4 or gzip"})
-		return
-	}
-
-	if !req.Distro.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "distro cannot be empty"})
-		return
-	}
-	if !req.Target.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "target cannot be empty"})
-		return
-	}
-	if !req.Architecture.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "architecture cannot be empty"})
-		return
-	}
-	if !req.ExportFormat.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "exportFormat cannot be empty"})
-		return
-	}
-	if !req.Mode.IsValid() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "mode cannot be empty"})
-		return
-	}
-	if req.AutomotiveImageBuilder == "" {
-		req.AutomotiveImageBuilder = "quay.io/centos-sig-automotive/automotive-image-builder:1.0.0"
-	}
-	if req.ManifestFileName == "" {
-		req.ManifestFileName = "manifest.aib.yml"
-	}
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	ctx := c.Request.Context()
-	namespace := resolveNamespace()
-
-	requestedBy := resolveRequester(c)
-
-	existing := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: req.Name, Namespace: namespace}, existing); err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("ImageBuild %s already exists", req.Name)})
-		return
-	} else if !k8serrors.IsNotFound(err) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error checking existing build: %v", err)})
-		return
-	}
-
-	cfgName := fmt.Sprintf("%s-manifest", req.Name)
-	cmData := map[string]string{req.ManifestFileName: req.Manifest}
-
-	if len(req.CustomDefs) > 0 {
-		cmData["custom-definitions.env"] = strings.Join(req.CustomDefs, "\n")
-	}
-	if len(req.AIBOverrideArgs) > 0 {
-		// If override is provided, prefer it and ignore the regular extra args
-		cmData["aib-override-args.txt"] = strings.Join(req.AIBOverrideArgs, " ")
-	} else if len(req.AIBExtraArgs) > 0 {
-		cmData["aib-extra-args.txt"] = strings.Join(req.AIBExtraArgs, " ")
-	}
-
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      cfgName,
-			Namespace: namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by":                  "build-api",
-				"app.kubernetes.io/part-of":                     "automotive-dev",
-				"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
-				"automotive.sdv.cloud.redhat.com/resource-type": "manifest-config",
-			},
-		},
-		Data: cmData,
-	}
-	if err := k8sClient.Create(ctx, cm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error creating manifest ConfigMap: %v", err)})
-		return
-	}
-
-	labels := map[string]string{
-		"app.kubernetes.io/managed-by":                 "build-api",
-		"app.kubernetes.io/part-of":                    "automotive-dev",
-		"app.kubernetes.io/created-by":                 "automotive-dev-build-api",
-		"automotive.sdv.cloud.redhat.com/distro":       string(req.Distro),
-		"automotive.sdv.cloud.redhat.com/target":       string(req.Target),
-		"automotive.sdv.cloud.redhat.com/architecture": string(req.Architecture),
-	}
-
-	serveExpiryHours := int32(24)
-	{
-		autoDev := &automotivev1.AutomotiveDev{}
-		if err := k8sClient.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: namespace}, autoDev); err == nil {
-			if autoDev.Spec.BuildConfig != nil && autoDev.Spec.BuildConfig.ServeExpiryHours > 0 {
-				serveExpiryHours = autoDev.Spec.BuildConfig.ServeExpiryHours
-			}
-		}
-	}
-
-	var envSecretRef string
-	if req.RegistryCredentials != nil && req.RegistryCredentials.Enabled {
-		secretName, err := createRegistrySecret(ctx, k8sClient, namespace, req.Name, req.RegistryCredentials)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error creating registry secret: %v", err)})
-			return
-		}
-		envSecretRef = secretName
-	}
-
-	imageBuild := &automotivev1.ImageBuild{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      req.Name,
-			Namespace: namespace,
-			Labels:    labels,
-			Annotations: map[string]string{
-				"automotive.sdv.cloud.redhat.com/requested-by": requestedBy,
-			},
-		},
-		Spec: automotivev1.ImageBuildSpec{
-			Distro:                 string(req.Distro),
-			Target:                 string(req.Target),
-			Architecture:           string(req.Architecture),
-			ExportFormat:           string(req.ExportFormat),
-			Mode:                   string(req.Mode),
-			AutomotiveImageBuilder: req.AutomotiveImageBuilder,
-			StorageClass:           req.StorageClass,
-			ServeArtifact:          req.ServeArtifact,
-			ExposeRoute:            req.ServeArtifact,
-			ServeExpiryHours:       serveExpiryHours,
-			ManifestConfigMap:      cfgName,
-			InputFilesServer:       needsUpload,
-			EnvSecretRef:           envSecretRef,
-			Compression:            req.Compression,
-		},
-	}
-	if err := k8sClient.Create(ctx, imageBuild); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error creating ImageBuild: %v", err)})
-		return
-	}
-
-	if err := setOwnerRef(ctx, k8sClient, namespace, cfgName, imageBuild); err != nil {
-		// best-effort
-	}
-
-	if envSecretRef != "" {
-		if err := setOwnerRef(ctx, k8sClient, namespace, envSecretRef, imageBuild); err != nil {
-			// best-effort
-		}
-	}
-
-	writeJSON(c, http.StatusAccepted, BuildResponse{
-		Name:        req.Name,
-		Phase:       "Building",
-		Message:     "Build triggered",
-		RequestedBy: requestedBy,
-	})
-}
-
-func listBuilds(c *gin.Context) {
-	namespace := resolveNamespace()
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	ctx := c.Request.Context()
-	list := &automotivev1.ImageBuildList{}
-	if err := k8sClient.List(ctx, list, client.InNamespace(namespace)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error listing builds: %v", err)})
-		return
-	}
-
-	resp := make([]BuildListItem, 0, len(list.Items))
-	for _, b := range list.Items {
-		var startStr, compStr string
-		if b.Status.StartTime != nil {
-			startStr = b.Status.StartTime.Time.Format(time.RFC3339)
-		}
-		if b.Status.CompletionTime != nil {
-			compStr = b.Status.CompletionTime.Time.Format(time.RFC3339)
-		}
-		resp = append(resp, BuildListItem{
-			Name:           b.Name,
-			Phase:          b.Status.Phase,
-			Message:        b.Status.Message,
-			RequestedBy:    b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
-			CreatedAt:      b.CreationTimestamp.Time.Format(time.RFC3339),
-			StartTime:      startStr,
-			CompletionTime: compStr,
-		})
-	}
-	writeJSON(c, http.StatusOK, resp)
-}
-
-func getBuild(c *gin.Context, name string) {
-	namespace := resolveNamespace()
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	ctx := c.Request.Context()
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	writeJSON(c, http.StatusOK, BuildResponse{
-		Name:             build.Name,
-		Phase:            build.Status.Phase,
-		Message:          build.Status.Message,
-		RequestedBy:      build.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
-		ArtifactURL:      build.Status.ArtifactURL,
-		ArtifactFileName: build.Status.ArtifactFileName,
-		StartTime: func() string {
-			if build.Status.StartTime != nil {
-				return build.Status.StartTime.Time.Format(time.RFC3339)
-			}
-			return ""
-		}(),
-		CompletionTime: func() string {
-			if build.Status.CompletionTime != nil {
-				return build.Status.CompletionTime.Time.Format(time.RFC3339)
-			}
-			return ""
-		}(),
-	})
-}
-
-// getBuildTemplate returns a BuildRequest-like struct representing the inputs that produced a given build
-func getBuildTemplate(c *gin.Context, name string) {
-	namespace := resolveNamespace()
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	ctx := c.Request.Context()
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	cm := &corev1.ConfigMap{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: build.Spec.ManifestConfigMap, Namespace: namespace}, cm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching manifest config: %v", err)})
-		return
-	}
-
-	// Rehydrate advanced args
-	var aibExtra []string
-	var aibOverride []string
-	if v, ok := cm.Data["aib-extra-args.txt"]; ok {
-		fields := strings.Fields(strings.TrimSpace(v))
-		aibExtra = append(aibExtra, fields...)
-	}
-	if v, ok := cm.Data["aib-override-args.txt"]; ok {
-		fields := strings.Fields(strings.TrimSpace(v))
-		aibOverride = append(aibOverride, fields...)
-	}
-
-	manifestFileName := "manifest.aib.yml"
-	var manifest string
-	for k, v := range cm.Data {
-		if k == "custom-definitions.env" || k == "aib-extra-args.txt" || k == "aib-override-args.txt" {
-			continue
-		}
-		manifestFileName = k
-		manifest = v
-		break
-	}
-
-	var sourceFiles []string
-	for _, line := range strings.Split(manifest, "\n") {
-		s := strings.TrimSpace(line)
-		if strings.HasPrefix(s, "source:") || strings.HasPrefix(s, "source_path:") {
-			parts := strings.SplitN(s, ":", 2)
-			if len(parts) == 2 {
-				p := strings.TrimSpace(parts[1])
-				p = strings.Trim(p, "'\"")
-				if p != "" && !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "http") {
-					sourceFiles = append(sourceFiles, p)
-				}
-			}
-		}
-	}
-
-	writeJSON(c, http.StatusOK, BuildTemplateResponse{
-		BuildRequest: BuildRequest{
-			Name:                   build.Name,
-			Manifest:               manifest,
-			ManifestFileName:       manifestFileName,
-			Distro:                 Distro(build.Spec.Distro),
-			Target:                 Target(build.Spec.Target),
-			Architecture:           Architecture(build.Spec.Architecture),
-			ExportFormat:           ExportFormat(build.Spec.ExportFormat),
-			Mode:                   Mode(build.Spec.Mode),
-			AutomotiveImageBuilder: build.Spec.AutomotiveImageBuilder,
-			CustomDefs:             nil,
-			AIBExtraArgs:           aibExtra,
-			AIBOverrideArgs:        aibOverride,
-			ServeArtifact:          build.Spec.ServeArtifact,
-			Compression:            build.Spec.Compression,
-		},
-		SourceFiles: sourceFiles,
-	})
-}
-
-func uploadFiles(c *gin.Context, name string) {
-	namespace := resolveNamespace()
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(c.Request.Context(), types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	// Find upload pod
-	podList := &corev1.PodList{}
-	if err := k8sClient.List(c.Request.Context(), podList,
-		client.InNamespace(namespace),
-		client.MatchingLabels{
-			"automotive.sdv.cloud.redhat.com/imagebuild-name": name,
-			"app.kubernetes.io/name":                          "upload-pod",
-		},
-	); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error listing upload pods: %v", err)})
-		return
-	}
-	var uploadPod *corev1.Pod
-	for i := range podList.Items {
-		p := &podList.Items[i]
-		if p.Status.Phase == corev1.PodRunning {
-			uploadPod = p
-			break
-		}
-	}
-	if uploadPod == nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "upload pod not ready"})
-		return
-	}
-
-	reader, err := c.Request.MultipartReader()
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid multipart: %v", err)})
-		return
-	}
-
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rest config: %v", err)})
-		return
-	}
-
-	for {
-		part, err := reader.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("read part: %v", err)})
-			return
-		}
-		if part.FormName() != "file" {
-			continue
-		}
-		dest := strings.TrimSpace(part.FileName())
-		if dest == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing destination filename"})
-			return
-		}
-
-		cleanDest := path.Clean(dest)
-		if strings.HasPrefix(cleanDest, "..") || strings.HasPrefix(cleanDest, "/") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid destination path: %s", dest)})
-			return
-		}
-
-		tmp, err := os.CreateTemp("", "upload-*")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		tmpName := tmp.Name()
-		defer tmp.Close()
-		defer func() {
-			_ = os.Remove(tmpName)
-		}()
-
-		if _, err := io.Copy(tmp, part); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		if err := copyFileToPod(restCfg, namespace, uploadPod.Name, uploadPod.Spec.Containers[0].Name, tmpName, "/workspace/shared/"+cleanDest); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("stream to pod failed: %v", err)})
-			return
-		}
-	}
-
-	original := build
-	patched := original.DeepCopy()
-	if patched.Annotations == nil {
-		patched.Annotations = map[string]string{}
-	}
-	patched.Annotations["automotive.sdv.cloud.redhat.com/uploads-complete"] = "true"
-	if err := k8sClient.Patch(c.Request.Context(), patched, client.MergeFrom(original)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("mark complete failed: %v", err)})
-		return
-	}
-	writeJSON(c, http.StatusOK, map[string]string{"status": "ok"})
-}
-
-func (a *APIServer) listArtifacts(c *gin.Context, name string) {
-	namespace := resolveNamespace()
-	ctx := c.Request.Context()
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	if build.Status.Phase != "Completed" {
-		c.JSON(http.StatusConflict, gin.H{"error": "artifact not available until build completes"})
-		return
-	}
-
-	artifactFileName := build.Status.ArtifactFileName
-	if artifactFileName == "" {
-		var ext string
-		switch build.Spec.ExportFormat {
-		case "image":
-			ext = ".raw"
-		case "qcow2":
-			ext = ".qcow2"
-		default:
-			ext = "." + build.Spec.ExportFormat
-		}
-		artifactFileName = fmt.Sprintf("%s-%s%s", build.Spec.Distro, build.Spec.Target, ext)
-	}
-
-	var artifactPod *corev1.Pod
-	deadline := time.Now().Add(2 * time.Minute)
-	for {
-		podList := &corev1.PodList{}
-		if err := k8sClient.List(ctx, podList,
-			client.InNamespace(namespace),
-			client.MatchingLabels{
-				"app.kubernetes.io/name":                          "artifact-pod",
-				"automotive.sdv.cloud.redhat.com/imagebuild-name": name,
-			}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error listing artifact pods: %v", err)})
-			return
-		}
-		for i := range podList.Items {
-			p := &podList.Items[i]
-			if p.Status.Phase == corev1.PodRunning {
-				for _, cs := range p.Status.ContainerStatuses {
-					if cs.Name == "fileserver" && cs.Ready {
-						artifactPod = p
-						break
-					}
-				}
-			}
-			if artifactPod != nil {
-				break
-			}
-		}
-		if artifactPod != nil {
-			break
-		}
-		if time.Now().After(deadline) {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "artifact pod not ready"})
-			return
-		}
-		time.Sleep(2 * time.Second)
-	}
-
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rest config: %v", err)})
-		return
-	}
-	clientset, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("clientset: %v", err)})
-		return
-	}
-
-	partsDir := "/workspace/shared/" + artifactFileName + "-parts"
-	listReq := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(artifactPod.Name).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: "fileserver",
-			Command:   []string{"sh", "-c", "set -e; dir=\"" + partsDir + "\"; if [ ! -d \"$dir\" ]; then echo MISSING; exit 0; fi; for f in \"$dir\"/*; do [ -f \"$f\" ] || continue; n=$(basename \"$f\"); s=$(wc -c < \"$f\"); printf '%s:%s\\n' \"$n\" \"$s\"; done"},
-			Stdout:    true,
-			Stderr:    true,
-		}, kscheme.ParameterCodec)
-	listExec, err := remotecommand.NewSPDYExecutor(restCfg, http.MethodPost, listReq.URL())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("executor (list): %v", err)})
-		return
-	}
-	var out strings.Builder
-	if err := listExec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &out, Stderr: io.Discard}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("list stream: %v", err)})
-		return
-	}
-	trim := strings.TrimSpace(out.String())
-	if trim == "" || trim == "MISSING" {
-		// No parts available
-		writeJSON(c, http.StatusOK, map[string]any{"items": []any{}})
-		return
-	}
-	lines := strings.Split(trim, "\n")
-	type item struct {
-		Name      string `json:"name"`
-		SizeBytes string `json:"sizeBytes"`
-	}
-	items := make([]item, 0, len(lines))
-	for _, ln := range lines {
-		p := strings.SplitN(strings.TrimSpace(ln), ":", 2)
-		if len(p) != 2 {
-			continue
-		}
-		items = append(items, item{Name: p[0], SizeBytes: strings.TrimSpace(p[1])})
-	}
-	writeJSON(c, http.StatusOK, map[string]any{"items": items})
-}
-
-func (a *APIServer) streamArtifactPart(c *gin.Context, name, file string) {
-	namespace := resolveNamespace()
-	ctx := c.Request.Context()
-
-	if strings.Contains(file, "/") || strings.Contains(file, "..") || strings.TrimSpace(file) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
-		return
-	}
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	if build.Status.Phase != "Completed" {
-		c.JSON(http.StatusConflict, gin.H{"error": "artifact not available until build completes"})
-		return
-	}
-
-	artifactFileName := build.Status.ArtifactFileName
-	if artifactFileName == "" {
-		var ext string
-		switch build.Spec.ExportFormat {
-		case "image":
-			ext = ".raw"
-		case "qcow2":
-			ext = ".qcow2"
-		default:
-			ext = "." + build.Spec.ExportFormat
-		}
-		artifactFileName = fmt.Sprintf("%s-%s%s", build.Spec.Distro, build.Spec.Target, ext)
-	}
-
-	var artifactPod *corev1.Pod
-	deadline := time.Now().Add(2 * time.Minute)
-	for {
-		podList := &corev1.PodList{}
-		if err := k8sClient.List(ctx, podList,
-			client.InNamespace(namespace),
-			client.MatchingLabels{
-				"app.kubernetes.io/name":                          "artifact-pod",
-				"automotive.sdv.cloud.redhat.com/imagebuild-name": name,
-			}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error listing artifact pods: %v", err)})
-			return
-		}
-		for i := range podList.Items {
-			p := &podList.Items[i]
-			if p.Status.Phase == corev1.PodRunning {
-				for _, cs := range p.Status.ContainerStatuses {
-					if cs.Name == "fileserver" && cs.Ready {
-						artifactPod = p
-						break
-					}
-				}
-			}
-			if artifactPod != nil {
-				break
-			}
-		}
-		if artifactPod != nil {
-			break
-		}
-		if time.Now().After(deadline) {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "artifact pod not ready"})
-			return
-		}
-		time.Sleep(2 * time.Second)
-	}
-
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rest config: %v", err)})
-		return
-	}
-	clientset, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("clientset: %v", err)})
-		return
-	}
-
-	gzPath := "/workspace/shared/" + artifactFileName + "-parts/" + file
-	// Check existence and size
-	sizeReq := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(artifactPod.Name).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: "fileserver",
-			Command:   []string{"sh", "-c", "if [ -f \"" + gzPath + "\" ]; then wc -c < \"" + gzPath + "\"; else echo MISSING; fi"},
-			Stdout:    true,
-			Stderr:    true,
-		}, kscheme.ParameterCodec)
-	sizeExec, err := remotecommand.NewSPDYExecutor(restCfg, http.MethodPost, sizeReq.URL())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("executor (size): %v", err)})
-		return
-	}
-	var sizeStdout strings.Builder
-	if err := sizeExec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &sizeStdout, Stderr: io.Discard}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("size stream: %v", err)})
-		return
-	}
-	sz := strings.TrimSpace(sizeStdout.String())
-	if sz == "" || sz == "MISSING" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "artifact item not found"})
-		return
-	}
-
-	streamReq := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(artifactPod.Name).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: "fileserver",
-			Command:   []string{"cat", gzPath},
-			Stdout:    true,
-			Stderr:    true,
-		}, kscheme.ParameterCodec)
-	streamExec, err := remotecommand.NewSPDYExecutor(restCfg, http.MethodPost, streamReq.URL())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("executor (stream): %v", err)})
-		return
-	}
-
-	c.Writer.Header().Set("Content-Type", "application/gzip")
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file))
-	c.Writer.Header().Set("Content-Length", sz)
-	c.Writer.Header().Set("X-AIB-Artifact-Type", "file")
-	c.Writer.Header().Set("X-AIB-Compression", "gzip")
-	if f, ok := c.Writer.(http.Flusher); ok {
-		f.Flush()
-	}
-
-	_ = streamExec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: c.Writer, Stderr: io.Discard})
-}
-
-// streamArtifactByFilename streams the specified artifact file from the artifact pod to the client over HTTP
-func (a *APIServer) streamArtifactByFilename(c *gin.Context, name, filename string) {
-	namespace := resolveNamespace()
-	ctx := c.Request.Context()
-
-	if strings.Contains(filename, "/") || strings.Contains(filename, "..") || strings.TrimSpace(filename) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
-		return
-	}
-
-	k8sClient, err := getClientFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("k8s client error: %v", err)})
-		return
-	}
-
-	build := &automotivev1.ImageBuild{}
-	if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build); err != nil {
-		if k8serrors.IsNotFound(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error fetching build: %v", err)})
-		return
-	}
-
-	if build.Status.Phase != "Completed" {
-		c.JSON(http.StatusConflict, gin.H{"error": "artifact not available until build completes"})
-		return
-	}
-
-	// Only allow the exact final artifact file name or files from the -parts directory
-	expected := strings.TrimSpace(build.Status.ArtifactFileName)
-	base := path.Base(filename)
-	allowed := base == expected
-
-	if !allowed {
-		// Check if it's a part file (from -parts directory)
-		if strings.HasSuffix(base, ".gz") || strings.HasSuffix(base, ".lz4") {
-			// Allow parts that follow the pattern: <expected>-parts/<filename>
-			if strings.Contains(base, ".tar.") || strings.HasPrefix(base, strings.TrimSuffix(expected, path.Ext(expected))) {
-				allowed = true
-			}
-		}
-	}
-
-	if !allowed {
-		c.JSON(http.StatusForbidden, gin.H{"error": "file not allowed"})
-		return
-	}
-
-	// Get REST config and clientset for pod operations
-	restCfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("rest config: %v", err)})
-		return
-	}
-	clientset, err := kubernetes.NewForConfig(restCfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("clientset: %v", err)})
-		return
-	}
-
-	// Find the artifact pod
-	var artifactPod *corev1.Pod
-	deadline := time.Now().Add(2 * time.Minute)
-	for {
-		podList := &corev1.PodList{}
-		if err := k8sClient.List(ctx, podList,
-			client.InNamespace(namespace),
-			client.MatchingLabels{
-				"app.kubernetes.io/name":                          "artifact-pod",
-				"automotive.sdv.cloud.redhat.com/imagebuild-name": name,
-			}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error listing artifact pods: %v", err)})
-			return
-		}
-
-		for i := range podList.Items {
-			p := &podList.Items[i]
-			if p.Status.Phase == corev1.PodRunning {
-				for _, cs := range p.Status.ContainerStatuses {
-					if cs.Name == "fileserver" && cs.Ready {
-						artifactPod = p
-						break
-					}
-				}
-			}
-			if artifactPod != nil {
-				break
-			}
-		}
-
-		if artifactPod != nil {
-			break
-		}
-		if time.Now().After(deadline) {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "artifact pod not ready"})
-			return
-		}
-		time.Sleep(2 * time.Second)
-	}
-
-	podPath := "/workspace/shared/" + base
-
-	// Check if file exists and get size
-	sizeReq := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(artifactPod.Name).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: "fileserver",
-			Command:   []string{"sh", "-c", "if [ -f '" + podPath + "' ]; then wc -c < '" + podPath + "'; else echo MISSING; fi"},
-			Stdout:    true,
-			Stderr:    true,
-		}, kscheme.ParameterCodec)
-
-	sizeExec, err := remotecommand.NewSPDYExecutor(restCfg, http.MethodPost, sizeReq.URL())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("executor (size): %v", err)})
-		return
-	}
-
-	var sizeStdout strings.Builder
-	if err := sizeExec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &sizeStdout, Stderr: io.Discard}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("size stream: %v", err)})
-		return
-	}
-
-	sz := strings.TrimSpace(sizeStdout.String())
-	if sz == "" || sz == "MISSING" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
-		return
-	}
-
-	// Set appropriate content type based on file extension
-	if strings.HasSuffix(strings.ToLower(base), ".lz4") {
-		c.Writer.Header().Set("Content-Type", "application/x-lz4")
-	} else if strings.Contains(strings.ToLower(base), ".tar.") {
-		// .tar.gz or .tar.lz4
-		if strings.HasSuffix(strings.ToLower(base), ".gz") {
-			c.Writer.Header().Set("Content-Type", "application/gzip")
-		} else {
-			c.Writer.Header().Set("Content-Type", "application/x-lz4")
-		}
-	} else if strings.HasSuffix(strings.ToLower(base), ".gz") {
-		c.Writer.Header().Set("Content-Type", "application/gzip")
-	} else {
-		c.Writer.Header().Set("Content-Type", "application/octet-stream")
-	}
-
-	c.Writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", base))
-	c.Writer.Header().Set("Content-Length", sz)
-
-	if f, ok := c.Writer.(http.Flusher); ok {
-		f.Flush()
-	}
-
-	// Stream the file content
-	streamReq := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(artifactPod.Name).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: "fileserver",
-			Command:   []string{"cat", podPath},
-			Stdout:    true,
-			Stderr:    true,
-		}, kscheme.ParameterCodec)
-
-	streamExec, err := remotecommand.NewSPDYExecutor(restCfg, http.MethodPost, streamReq.URL())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("executor (stream): %v", err)})
-		return
-	}
-
-	_ = streamExec.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: c.Writer, Stderr: io.Discard})
-}
-
-func copyFileToPod(config *rest.Config, namespace, podName, containerName, localPath, podPath string) error {
-	f, err := os.Open(localPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	info, err := f.Stat()
-	if err != nil {
-		return err
-	}
-
-	pr, pw := io.Pipe()
-	go func() {
-		tw := tar.NewWriter(pw)
-		defer func() { tw.Close(); pw.Close() }()
-		hdr := &tar.Header{Name: path.Base(podPath), Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}
-		if err := tw.WriteHeader(hdr); err != nil {
-			pw.CloseWithError(err)
-			return
-		}
-		if _, err := io.Copy(tw, f); err != nil {
-			pw.CloseWithError(err)
-			return
-		}
-	}()
-
-	destDir := path.Dir(podPath)
-	cmd := []string{"/bin/sh", "-c", fmt.Sprintf("mkdir -p %s && tar -x -C %s", destDir, destDir)}
-
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return err
-	}
-	req := clientset.CoreV1().RESTClient().Post().Resource("pods").Name(podName).Namespace(namespace).SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: containerName,
-			Command:   cmd,
-			Stdin:     true,
-			Stdout:    true,
-			Stderr:    true,
-			TTY:       false,
-		}, kscheme.ParameterCodec)
-	executor, err := remotecommand.NewSPDYExecutor(config, http.MethodPost, req.URL())
-	if err != nil {
-		return err
-	}
-	return executor.StreamWithContext(context.Background(), remotecommand.StreamOptions{Stdin: pr, Stdout: io.Discard, Stderr: io.Discard})
-}
-
-func setOwnerRef(ctx context.Context, c client.Client, namespace, configMapName string, owner *automotivev1.ImageBuild) error {
-	cm := &corev1.ConfigMap{}
-	if err := c.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: namespace}, cm); err != nil {
-		return err
-	}
-	cm.OwnerReferences = []metav1.OwnerReference{
-		*metav1.NewControllerRef(owner, automotivev1.GroupVersion.WithKind("ImageBuild")),
-	}
-	return c.Update(ctx, cm)
-}
-
-func writeJSON(c *gin.Context, status int, v any) {
-	c.Header("Cache-Control", "no-store")
-	c.IndentedJSON(status, v)
-}
-
-func resolveNamespace() string {
-	if ns := strings.TrimSpace(os.Getenv("BUILD_API_NAMESPACE")); ns != "" {
-		return ns
-	}
-	if data, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
-		ns := strings.TrimSpace(string(data))
-		if ns != "" {
-			return ns
-		}
-	}
-	return "default"
-}
-
-func getRESTConfigFromRequest(_ *gin.Context) (*rest.Config, error) {
-	var cfg *rest.Config
-	var err error
-	cfg, err = rest.InClusterConfig()
-	if err != nil {
-		kubeconfig := os.Getenv("KUBECONFIG")
-		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			return nil, fmt.Errorf("failed to build kube config: %w", err)
-		}
-	}
-	cfgCopy := rest.CopyConfig(cfg)
-	cfgCopy.Timeout = 30 * time.Minute
-	return cfgCopy, nil
-}
-
-func getClientFromRequest(c *gin.Context) (client.Client, error) {
-	cfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		return nil, err
-	}
-
-	scheme := runtime.NewScheme()
-	if err := automotivev1.AddToScheme(scheme); err != nil {
-		return nil, fmt.Errorf("failed to add automotive scheme: %w", err)
-	}
-	if err := corev1.AddToScheme(scheme); err != nil {
-		return nil, fmt.Errorf("failed to add core scheme: %w", err)
-	}
-
-	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create k8s client: %w", err)
-	}
-	return k8sClient, nil
-}
-
-func (a *APIServer) isAuthenticated(c *gin.Context) bool {
-	authHeader := c.Request.Header.Get("Authorization")
-	token := ""
-	token, _ = strings.CutPrefix(authHeader, "Bearer ")
-	if token == "" {
-		token = c.Request.Header.Get("X-Forwarded-Access-Token")
-	}
-	if strings.TrimSpace(token) == "" {
-		return false
-	}
-	cfg, err := getRESTConfigFromRequest(c)
-	if err != nil {
-		return false
-	}
-	clientset, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return false
-	}
-	tr := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}
-	res, err := clientset.AuthenticationV1().TokenReviews().Create(c.Request.Context(), tr, metav1.CreateOptions{})
-	if err != nil {
-		return false
-	}
-	return res.Status.Authenticated
-}
-
-func resolveRequester(c *gin.Context) string {
-	authHeader := c.Request.Header.Get("Authorization")
-	token := ""
-	token, _ = strings.CutPrefix(authHeader, "Bearer ")
-	if token == "" {
-		token = c.Request.Header.Get("X-Forwarded-Access-Token")
-	}
-
+func (a *APIServer) resolveRequester(c *gin.Context) string {
 	// Attempt TokenReview to obtain canonical username
-	if strings.TrimSpace(token) != "" {
-		cfg, err := getRESTConfigFromRequest(c)
-		if err == nil {
-			clientset, err := kubernetes.NewForConfig(cfg)
-			if err == nil {
-				tr := &authnv1.TokenReview{Spec: authnv1.TokenReviewSpec{Token: token}}
-				if res, err := clientset.AuthenticationV1().TokenReviews().Create(c.Request.Context(), tr, metav1.CreateOptions{}); err == nil {
-					if res.Status.Authenticated && res.Status.User.Username != "" {
-						return res.Status.User.Username
-					}
-				}
-			}
-		}
+	if user, ok := a.requester(c); ok && user.Username != "" {
+		return user.Username
 	}
 
 	// Last resort: consult proxy-provided header (not trusted, used only as fallback)
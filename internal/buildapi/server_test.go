@@ -2,18 +2,37 @@ package buildapi
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/openapi"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
 )
 
+// ginPathParam matches the :name parameters of gin routes
+var ginPathParam = regexp.MustCompile(`:([^/]+)`)
+
 var _ = Describe("APIServer", func() {
 	var (
 		server *APIServer
@@ -53,6 +72,41 @@ var _ = Describe("APIServer", func() {
 		})
 	})
 
+	Context("Request IDs", func() {
+		It("should echo the client's request ID and traceparent, and generate an ID otherwise", func() {
+			tp := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+			req, _ := http.NewRequest("GET", "/v1/healthz", nil)
+			req.Header.Set(correlation.RequestIDHeader, "ci-run-42")
+			req.Header.Set(correlation.TraceparentHeader, tp)
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			Expect(w.Header().Get(correlation.RequestIDHeader)).To(Equal("ci-run-42"))
+			Expect(w.Header().Get(correlation.TraceparentHeader)).To(Equal(tp))
+
+			req, _ = http.NewRequest("GET", "/v1/healthz", nil)
+			req.Header.Set(correlation.RequestIDHeader, "bad id\nwith newline")
+			req.Header.Set(correlation.TraceparentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+			w = httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			Expect(w.Header().Get(correlation.RequestIDHeader)).To(MatchRegexp(`^[0-9a-f-]{36}$`))
+			Expect(w.Header().Get(correlation.TraceparentHeader)).To(BeEmpty())
+		})
+	})
+
+	Context("Metrics Endpoint", func() {
+		It("should serve the result cache metrics without authentication", func() {
+			cacheLookupsTotal.WithLabelValues("metrics-test", cacheMiss).Inc()
+			req, err := http.NewRequest("GET", "/v1/metrics", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(ContainSubstring(`automotive_build_cache_lookups_total{namespace="metrics-test",result="miss"} 1`))
+		})
+	})
+
 	Context("OpenAPI Endpoint", func() {
 		It("should return OpenAPI spec", func() {
 			req, err := http.NewRequest("GET", "/v1/openapi.yaml", nil)
@@ -65,6 +119,76 @@ var _ = Describe("APIServer", func() {
 			Expect(w.Header().Get("Content-Type")).To(Equal("application/yaml"))
 			Expect(w.Body.String()).NotTo(BeEmpty())
 		})
+
+		It("should serve the spec generated from the handlers and types", func() {
+			spec, err := openapi.Generate(".")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(embeddedOpenAPI)).To(Equal(string(spec)), "openapi.yaml is stale; run go generate ./internal/buildapi")
+			docs, err := os.ReadFile("../../docs/openapi.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(docs)).To(Equal(string(spec)), "docs/openapi.yaml is stale; run go generate ./internal/buildapi")
+		})
+
+		It("should document exactly the routes the router serves", func() {
+			doc, err := openapi.Build(".")
+			Expect(err).NotTo(HaveOccurred())
+			var documented []string
+			for path, item := range doc.Paths {
+				for method := range item.Operations() {
+					documented = append(documented, method+" "+path)
+				}
+			}
+
+			var served []string
+			for _, route := range server.router.Routes() {
+				if !strings.HasPrefix(route.Path, "/v1/") {
+					// the web UI is not part of the API
+					continue
+				}
+				served = append(served, route.Method+" "+ginPathParam.ReplaceAllString(route.Path, "{$1}"))
+			}
+			Expect(documented).To(ConsistOf(served))
+		})
+	})
+
+	Context("Web UI", func() {
+		get := func(path string, header ...string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", path, nil)
+			for i := 0; i+1 < len(header); i += 2 {
+				req.Header.Set(header[i], header[i+1])
+			}
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			return w
+		}
+
+		It("should serve the page uncached and its versioned assets for good", func() {
+			Expect(get("/ui").Header().Get("Location")).To(Equal("ui/"))
+
+			page := get("/ui/")
+			Expect(page.Code).To(Equal(http.StatusOK))
+			Expect(page.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+			Expect(page.Header().Get("Cache-Control")).To(Equal("no-cache"))
+			Expect(page.Body.String()).NotTo(ContainSubstring("{{"))
+			Expect(get("/ui/builds/radio").Body.String()).To(Equal(page.Body.String()))
+
+			script := regexp.MustCompile(`src="(app\.js\?v=[0-9a-f]+)"`).FindStringSubmatch(page.Body.String())
+			Expect(script).To(HaveLen(2))
+			asset := get("/ui/" + script[1])
+			Expect(asset.Code).To(Equal(http.StatusOK))
+			Expect(asset.Header().Get("Content-Type")).To(ContainSubstring("javascript"))
+			Expect(asset.Header().Get("Cache-Control")).To(ContainSubstring("immutable"))
+			Expect(get("/ui/app.js?v=stale").Header().Get("Cache-Control")).To(Equal("no-cache"))
+
+			Expect(get("/ui/", "If-None-Match", page.Header().Get("ETag")).Code).To(Equal(http.StatusNotModified))
+			Expect(get("/ui/missing.js").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should answer 404 when disabled", func() {
+			server.DisableUI()
+			Expect(get("/ui/").Code).To(Equal(http.StatusNotFound))
+			Expect(get("/ui").Code).To(Equal(http.StatusNotFound))
+		})
 	})
 
 	Context("Builds Endpoints Authentication", func() {
@@ -75,10 +199,29 @@ var _ = Describe("APIServer", func() {
 			{"GET", "/v1/builds"},
 			{"POST", "/v1/builds"},
 			{"GET", "/v1/builds/test-build"},
+			{"PATCH", "/v1/builds/test-build"},
+			{"DELETE", "/v1/builds/test-build"},
+			{"POST", "/v1/builds/test-build/promote"},
 			{"GET", "/v1/builds/test-build/logs"},
+			{"GET", "/v1/builds/test-build/logs/archive"},
+			{"GET", "/v1/builds/test-build/logs/sse"},
 			{"GET", "/v1/builds/test-build/artifacts"},
+			{"GET", "/v1/builds/test-build/artifacts.tar"},
+			{"GET", "/v1/builds/test-build/workspace.tar"},
+			{"GET", "/v1/builds/test-build/scan-report"},
 			{"GET", "/v1/builds/test-build/template"},
+			{"GET", "/v1/builds/test-build/taskrun"},
 			{"POST", "/v1/builds/test-build/uploads"},
+			{"GET", "/v1/builds/test-build/uploads/file"},
+			{"POST", "/v1/builds/test-build/uploads/file"},
+			{"PUT", "/v1/builds/test-build/uploads/file"},
+			{"POST", "/v1/builds/test-build/uploads/file/finish"},
+			{"POST", "/v1/builds/test-build/uploads/complete"},
+			{"PUT", "/v1/builds/test-build/uploads/totals"},
+			{"POST", "/v1/images/test-image/lifecycle"},
+			{"GET", "/v1/info"},
+			{"GET", "/v1/catalog"},
+			{"GET", "/v1/stats"},
 		}
 
 		It("should require authentication for all builds endpoints", func() {
@@ -96,6 +239,24 @@ var _ = Describe("APIServer", func() {
 		})
 	})
 
+	Context("Image lifecycle transitions", func() {
+		It("should allow promotion, deprecation and revocation", func() {
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleCandidate, automotivev1.ImageLifecycleReleased)).To(Succeed())
+			Expect(validateLifecycleTransition("", automotivev1.ImageLifecycleReleased)).To(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleReleased, automotivev1.ImageLifecycleDeprecated)).To(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleDeprecated, automotivev1.ImageLifecycleReleased)).To(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleDeprecated, automotivev1.ImageLifecycleRevoked)).To(Succeed())
+		})
+
+		It("should reject invalid transitions", func() {
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleCandidate, automotivev1.ImageLifecycleDeprecated)).NotTo(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleReleased, automotivev1.ImageLifecycleCandidate)).NotTo(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleRevoked, automotivev1.ImageLifecycleReleased)).NotTo(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleReleased, automotivev1.ImageLifecycleReleased)).NotTo(Succeed())
+			Expect(validateLifecycleTransition(automotivev1.ImageLifecycleCandidate, "archived")).NotTo(Succeed())
+		})
+	})
+
 	Context("Server Lifecycle", func() {
 		It("should start and stop gracefully", func() {
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -114,6 +275,101 @@ var _ = Describe("APIServer", func() {
 		})
 	})
 
+	Context("TLS", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		get := func(addr string, roots *x509.CertPool, cert *tls.Certificate) (*http.Response, error) {
+			cfg := &tls.Config{RootCAs: roots}
+			if cert != nil {
+				cfg.Certificates = []tls.Certificate{*cert}
+			}
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}, Timeout: 5 * time.Second}
+			return c.Get("https://" + addr + "/v1/healthz")
+		}
+
+		It("should refuse incomplete or unknown settings", func() {
+			serverCA := newTestCA()
+			serverCA.writeLeaf(dir, "server", true)
+			Expect(server.ConfigureTLS(TLSConfig{CertFile: filepath.Join(dir, "server.crt")})).NotTo(Succeed())
+			Expect(server.ConfigureTLS(TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), ClientAuth: ClientAuthOptional})).NotTo(Succeed())
+			Expect(server.ConfigureTLS(TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), ClientCAFile: filepath.Join(dir, "server.crt"), ClientAuth: "sometimes"})).NotTo(Succeed())
+			Expect(server.ConfigureTLS(TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "missing.key")})).NotTo(Succeed())
+		})
+
+		It("should require client certificates of the client CA and follow its rotation", func() {
+			serverCA, oldCA, newCA := newTestCA(), newTestCA(), newTestCA()
+			serverCA.writeLeaf(dir, "server", true)
+			oldClient := oldCA.writeLeaf(dir, "old-client", false)
+			newClient := newCA.writeLeaf(dir, "new-client", false)
+			caFile := filepath.Join(dir, "client-ca.crt")
+			Expect(os.WriteFile(caFile, oldCA.certPEM, 0o600)).To(Succeed())
+
+			Expect(server.ConfigureTLS(TLSConfig{
+				CertFile:     filepath.Join(dir, "server.crt"),
+				KeyFile:      filepath.Join(dir, "server.key"),
+				ClientCAFile: caFile,
+			})).To(Succeed())
+			ln, err := tls.Listen("tcp", "127.0.0.1:0", server.server.TLSConfig)
+			Expect(err).NotTo(HaveOccurred())
+			go func() { _ = server.server.Serve(ln) }()
+			DeferCleanup(server.server.Close)
+			addr := ln.Addr().String()
+
+			resp, err := get(addr, serverCA.pool(), &oldClient)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			_, err = get(addr, serverCA.pool(), nil)
+			Expect(err).To(HaveOccurred())
+
+			Expect(os.WriteFile(caFile, newCA.certPEM, 0o600)).To(Succeed())
+			later := time.Now().Add(time.Minute)
+			Expect(os.Chtimes(caFile, later, later)).To(Succeed())
+			_, err = get(addr, serverCA.pool(), &oldClient)
+			Expect(err).To(HaveOccurred())
+			resp, err = get(addr, serverCA.pool(), &newClient)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("should present a rotated server certificate without a restart", func() {
+			oldCA, newCA := newTestCA(), newTestCA()
+			oldCA.writeLeaf(dir, "server", true)
+			Expect(server.ConfigureTLS(TLSConfig{
+				CertFile: filepath.Join(dir, "server.crt"),
+				KeyFile:  filepath.Join(dir, "server.key"),
+			})).To(Succeed())
+			ctx, cancel := context.WithCancel(context.Background())
+			DeferCleanup(cancel)
+			server.startCertWatcher(ctx)
+			ln, err := tls.Listen("tcp", "127.0.0.1:0", server.server.TLSConfig)
+			Expect(err).NotTo(HaveOccurred())
+			go func() { _ = server.server.Serve(ln) }()
+			DeferCleanup(server.server.Close)
+			addr := ln.Addr().String()
+
+			resp, err := get(addr, oldCA.pool(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+
+			// give the watcher time to start before rotating
+			time.Sleep(500 * time.Millisecond)
+			newCA.writeLeaf(dir, "server", true)
+			Eventually(func() error {
+				resp, err := get(addr, newCA.pool(), nil)
+				if err == nil {
+					resp.Body.Close()
+				}
+				return err
+			}, 10*time.Second, 200*time.Millisecond).Should(Succeed())
+		})
+	})
+
 	Context("Integration with Kubernetes", func() {
 		BeforeEach(func() {
 			if os.Getenv("KUBECONFIG") == "" && os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
@@ -162,3 +418,65 @@ var _ = Describe("APIServer Performance", func() {
 		Expect(w.Code).To(Equal(http.StatusOK))
 	})
 })
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	p := x509.NewCertPool()
+	p.AddCert(ca.cert)
+	return p
+}
+
+// writeLeaf signs a server certificate for 127.0.0.1 or a client certificate and writes it to
+// <name>.crt and <name>.key in dir
+func (ca *testCA) writeLeaf(dir, name string, server bool) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	// the key is written first so the watcher never pairs the new certificate with the old key
+	Expect(os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600)).To(Succeed())
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+	return pair
+}
@@ -0,0 +1,1148 @@
+package buildapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifactname"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/features"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/manifesthash"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+)
+
+// BuildService holds the build API's business logic independently of HTTP. Errors wrap one of the
+// Err* kinds below so handlers can map them to status codes.
+type BuildService interface {
+	// DefaultNamespace returns the namespace used when a request does not select one
+	DefaultNamespace() string
+	// EnabledFeatures returns the feature gates the AutomotiveDev turns on, sorted
+	EnabledFeatures(ctx context.Context) []string
+
+	CreateBuild(ctx context.Context, req BuildRequest, requestedBy string) (*BuildResponse, error)
+	// ListBuilds returns all builds carrying every one of the given labels
+	ListBuilds(ctx context.Context, labels map[string]string) ([]BuildListItem, error)
+	// LintManifests checks manifests against the organization policies of the AutomotiveDev's lint rules
+	LintManifests(ctx context.Context, req LintRequest) (*LintResponse, error)
+	// Catalog lists the distros, targets and architectures builds may use
+	Catalog(ctx context.Context) (*CatalogResponse, error)
+	// AccessPolicy returns the restrictions on who sees builds, nil when everyone with access to a namespace
+	// sees all its builds
+	AccessPolicy(ctx context.Context) (*automotivev1.BuildAccess, error)
+	// BuildStats summarizes the builds created within the last window
+	BuildStats(ctx context.Context, window time.Duration) (*BuildStatsResponse, error)
+	// StorageQuota reports the storage held by the live build workspaces against the namespace's limit
+	StorageQuota(ctx context.Context) (*StorageQuotaResponse, error)
+	// SweepUploads cancels the builds that went without receiving a file in the Uploading phase for longer than
+	// the BuildConfig's UploadTimeoutMinutes, so the operator releases their workspace and upload pod, and
+	// reports them. With dryRun the builds are only reported.
+	SweepUploads(ctx context.Context, requestedBy string, dryRun bool) (*SweepResponse, error)
+	GetBuild(ctx context.Context, name string) (*BuildResponse, error)
+	// CancelBuild asks the operator to stop a build that has not finished; finished builds are an ErrConflict error
+	CancelBuild(ctx context.Context, name, requestedBy string) (*BuildResponse, error)
+	// UpdateBuild changes the serving settings, user labels or registry credentials of a build that has not
+	// started building. Started builds are an ErrConflict error and changes to its distro, target or
+	// architecture an ErrInvalidInput error.
+	UpdateBuild(ctx context.Context, name string, req UpdateBuildRequest, requestedBy string) (*BuildResponse, error)
+	// DeleteBuild deletes a build with the objects it owns and its registry secret. Unfinished builds are an
+	// ErrConflict error unless force is set.
+	DeleteBuild(ctx context.Context, name string, force bool) (*BuildResponse, error)
+	GetBuildTemplate(ctx context.Context, name string) (*BuildTemplateResponse, error)
+	GetTaskRun(ctx context.Context, name string) (*TaskRunResponse, error)
+	// BuildTimeline assembles what happened to a build from its status, its TaskRun and the Events recorded
+	// for it and the objects it runs
+	BuildTimeline(ctx context.Context, name string) (*BuildTimelineResponse, error)
+	// BuildUsage returns what the build step of a finished build consumed. Unfinished builds are an ErrConflict
+	// error and builds that reported no usage an ErrNotFound error.
+	BuildUsage(ctx context.Context, name string) (*BuildUsageResponse, error)
+	// UploadFiles copies files into a build's workspace and verifies them against the checksums the client sent.
+	// On a checksum mismatch it returns the per-file results together with an ErrInvalidInput error. The build
+	// waits until CompleteUploads is called.
+	UploadFiles(ctx context.Context, name string, next NextUploadFile) (*UploadResponse, error)
+	// UploadedFile reports how many bytes of a file a build's workspace holds, zero if none
+	UploadedFile(ctx context.Context, name, path string) (*UploadedFile, error)
+	// StartUpload starts or resumes the chunked upload of a file of the given size and checksum. It reports how
+	// many bytes of that file the workspace holds; a partial file left by an upload of other content is discarded.
+	StartUpload(ctx context.Context, name, path string, size int64, checksum string) (*UploadedFile, error)
+	// WriteUploadChunk writes content into a file of a build's workspace at offset, dropping anything after it.
+	// An offset past the end of the file is an ErrConflict error.
+	WriteUploadChunk(ctx context.Context, name, path string, offset int64, content io.Reader) (*UploadedFile, error)
+	// FinishUpload verifies a file uploaded in chunks against its checksum. A file that does not match is
+	// discarded, so its next upload starts over, and its result is returned together with an ErrInvalidInput error.
+	FinishUpload(ctx context.Context, name, path, checksum string) (*UploadFileResult, error)
+	// CompleteUploads verifies uploaded files against their checksums and lets the build proceed. Files already
+	// verified by UploadFiles need not be listed again.
+	// On a checksum mismatch it returns the per-file results together with an ErrInvalidInput error.
+	CompleteUploads(ctx context.Context, name string, checksums map[string]string) (*UploadResponse, error)
+	// SetUploadTotals records how much the client is about to upload, so the build reports its upload progress
+	// as a percentage
+	SetUploadTotals(ctx context.Context, name string, req UploadTotalsRequest) (*UploadProgress, error)
+
+	// LogPod returns the pod running a build's TaskRun, or an ErrNotReady error if logs are not available yet
+	LogPod(ctx context.Context, name string) (string, error)
+	// FollowLogs streams the logs of the step containers of podName selected by opts to sink until the pod finishes
+	FollowLogs(ctx context.Context, podName string, opts LogOptions, sink LogSink) error
+	// OpenLogsArchive returns the logs of every step of a finished build as a gzipped tar archive
+	OpenLogsArchive(ctx context.Context, name string) (*Artifact, error)
+
+	ListArtifacts(ctx context.Context, name string) ([]ArtifactItem, error)
+	OpenArtifactPart(ctx context.Context, name, file string) (*Artifact, error)
+	OpenArtifactByFilename(ctx context.Context, name, filename string) (*Artifact, error)
+	OpenArtifactsTar(ctx context.Context, name string) (*Artifact, error)
+	// OpenWorkspaceTar returns the workspace a failed build kept for debugging as a tar archive
+	OpenWorkspaceTar(ctx context.Context, name string) (*Artifact, error)
+	// OpenScanReport returns the vulnerability scan report of a completed build
+	OpenScanReport(ctx context.Context, name string) (*Artifact, error)
+
+	// ListImages returns the Images of the catalog matching filter, sorted by name
+	ListImages(ctx context.Context, filter ImageFilter) ([]ImageResponse, error)
+	GetImage(ctx context.Context, name string) (*ImageResponse, error)
+	// RegisterImage catalogs an image built outside the cluster that is already in a registry
+	RegisterImage(ctx context.Context, req RegisterImageRequest, requestedBy string) (*ImageResponse, error)
+	// DeleteImage removes an Image from the catalog without touching the image in its registry
+	DeleteImage(ctx context.Context, name string) (*ImageResponse, error)
+	SetImageLifecycle(ctx context.Context, name string, req ImageLifecycleRequest, requestedBy string) (*ImageLifecycleResponse, error)
+	// PromoteBuild pushes the artifact of a completed build to a registry and catalogs it as an Image in
+	// another namespace
+	PromoteBuild(ctx context.Context, name string, req PromoteRequest, requestedBy string) (*PromoteResponse, error)
+	// ConvertArtifact asks the operator to convert the served artifact of a completed build to another disk
+	// format and returns the progress of the conversion
+	ConvertArtifact(ctx context.Context, name string, req ConvertRequest, requestedBy string) (*ArtifactConversion, error)
+
+	// TriggerGitBuilds starts a build for every Git trigger of the AutomotiveDev matching a pushed branch or
+	// tag. Deliveries that fail authentication are an ErrForbidden error.
+	TriggerGitBuilds(ctx context.Context, hook GitHook) (*GitHookResponse, error)
+}
+
+// Error kinds returned (wrapped) by BuildService
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrGone         = errors.New("gone")
+	ErrNotReady     = errors.New("not ready")
+	// ErrUnprocessable rejects a well-formed request the server's policy does not allow
+	ErrUnprocessable = errors.New("unprocessable")
+)
+
+// serviceError carries a client-facing message while still matching its kind with errors.Is
+type serviceError struct {
+	kind error
+	msg  string
+}
+
+func (e *serviceError) Error() string { return e.msg }
+func (e *serviceError) Unwrap() error { return e.kind }
+
+func newError(kind error, format string, args ...any) error {
+	return &serviceError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// NewBuildService returns the BuildService backed by the given cluster
+func NewBuildService(cluster k8s.Cluster) BuildService {
+	return &buildService{cluster: cluster}
+}
+
+type buildService struct {
+	cluster k8s.Cluster
+	// artifacts caches downloaded artifacts; nil disables caching
+	artifacts *artifactCache
+	// consoleURL is the base URL of the web console builds link to; "" leaves the links out
+	consoleURL string
+
+	// progressRecorded holds when the upload progress of each build waiting for uploads was last recorded
+	progressMu       sync.Mutex
+	progressRecorded map[string]time.Time
+}
+
+var _ BuildService = &buildService{}
+
+func (s *buildService) DefaultNamespace() string {
+	return s.cluster.Namespace()
+}
+
+func (s *buildService) EnabledFeatures(ctx context.Context) []string {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if err != nil {
+		// without an AutomotiveDev every gate is off
+		return nil
+	}
+	return features.EnabledGates(autoDev)
+}
+
+// getBuild fetches an ImageBuild, translating a missing build into ErrNotFound
+func (s *buildService) getBuild(ctx context.Context, name string) (*automotivev1.ImageBuild, error) {
+	build, err := s.cluster.GetImageBuild(ctx, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "not found")
+		}
+		return nil, fmt.Errorf("error fetching build: %w", err)
+	}
+	return build, nil
+}
+
+// registrySecretName is the name of the secret holding the registry credentials a build was submitted with
+func registrySecretName(buildName string) string {
+	return fmt.Sprintf("%s-registry-auth", buildName)
+}
+
+// registrySecretData returns the environment variables the build reads registry credentials from
+func registrySecretData(creds *RegistryCredentials) (map[string][]byte, error) {
+	secretData := make(map[string][]byte)
+
+	switch creds.AuthType {
+	case "username-password":
+		if creds.RegistryURL == "" || creds.Username == "" || creds.Password == "" {
+			return nil, fmt.Errorf("registry URL, username, and password are required for username-password authentication")
+		}
+		secretData["REGISTRY_URL"] = []byte(creds.RegistryURL)
+		secretData["REGISTRY_USERNAME"] = []byte(creds.Username)
+		secretData["REGISTRY_PASSWORD"] = []byte(creds.Password)
+	case "token":
+		if creds.RegistryURL == "" || creds.Token == "" {
+			return nil, fmt.Errorf("registry URL and token are required for token authentication")
+		}
+		secretData["REGISTRY_URL"] = []byte(creds.RegistryURL)
+		secretData["REGISTRY_TOKEN"] = []byte(creds.Token)
+	case "docker-config":
+		if creds.DockerConfig == "" {
+			return nil, fmt.Errorf("docker config is required for docker-config authentication")
+		}
+		secretData["REGISTRY_AUTH_FILE_CONTENT"] = []byte(creds.DockerConfig)
+	default:
+		return nil, fmt.Errorf("unsupported authentication type: %s", creds.AuthType)
+	}
+	return secretData, nil
+}
+
+func (s *buildService) createRegistrySecret(ctx context.Context, buildName string, creds *RegistryCredentials) (string, error) {
+	if creds == nil || !creds.Enabled {
+		return "", nil
+	}
+
+	secretName := registrySecretName(buildName)
+	secretData, err := registrySecretData(creds)
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: secretName,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":                  "build-api",
+				"app.kubernetes.io/part-of":                     "automotive-dev",
+				"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
+				"automotive.sdv.cloud.redhat.com/resource-type": "registry-auth",
+				"automotive.sdv.cloud.redhat.com/build-name":    buildName,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: secretData,
+	}
+
+	if err := s.cluster.CreateSecret(ctx, secret); err != nil {
+		return "", fmt.Errorf("failed to create registry secret: %w", err)
+	}
+
+	return secretName, nil
+}
+
+func (s *buildService) CreateBuild(ctx context.Context, req BuildRequest, requestedBy string) (*BuildResponse, error) {
+	needsUpload := referencesUploads(req.Manifest)
+	for _, m := range req.AdditionalManifests {
+		needsUpload = needsUpload || referencesUploads(m.Content)
+	}
+
+	if (req.Name == "" && req.GenerateName == "") || (req.Manifest == "" && req.ManifestRef == "") {
+		return nil, newError(ErrInvalidInput, "name and manifest are required")
+	}
+	if req.Name != "" && req.GenerateName != "" {
+		return nil, newError(ErrInvalidInput, "name and generateName cannot be combined")
+	}
+	if req.IdempotencyKey != "" {
+		if err := validateIdempotencyKey(req.IdempotencyKey); err != nil {
+			return nil, err
+		}
+		if resp, err := s.replayIdempotentBuild(ctx, req, requestedBy); err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	if err := validateAccessGroups(req.AccessGroups); err != nil {
+		return nil, err
+	}
+	if req.ManifestRef != "" {
+		if req.Manifest != "" || len(req.AdditionalManifests) > 0 {
+			return nil, newError(ErrInvalidInput, "manifest and additionalManifests cannot be combined with manifestRef")
+		}
+		if err := validateManifestRef(req.ManifestRef); err != nil {
+			return nil, err
+		}
+	}
+	if req.Profile != "" {
+		if err := s.applyProfile(ctx, &req); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Distro == "" {
+		req.Distro = "cs9"
+	}
+	if req.Target == "" {
+		req.Target = "qemu"
+	}
+	if req.Architecture == "" {
+		req.Architecture = "arm64"
+	}
+	if req.ExportFormat == "" {
+		req.ExportFormat = "image"
+	}
+	if req.Mode == "" {
+		req.Mode = "image"
+	}
+
+	if strings.TrimSpace(req.Compression) == "" {
+		req.Compression = "gzip"
+	}
+	if req.Compression != "lz4" && req.Compression != "gzip" && req.Compression != "none" {
+		return nil, newError(ErrInvalidInput, "invalid compression: must be lz4, gzip or none")
+	}
+	if req.Cache != "" && req.Cache != cacheBypass {
+		return nil, newError(ErrInvalidInput, "invalid cache: must be bypass")
+	}
+	if req.Cache == cacheBypass && req.ReuseExisting {
+		return nil, newError(ErrInvalidInput, "cache=bypass cannot be combined with reuseExisting")
+	}
+
+	if err := s.validateEncryption(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.validateOSTree(ctx, req); err != nil {
+		return nil, err
+	}
+	if err := s.validateInputPVC(ctx, req.InputPVC); err != nil {
+		return nil, err
+	}
+
+	if req.GitRef != "" && !req.BuildInfo {
+		return nil, newError(ErrInvalidInput, "gitRef is only recorded when buildInfo is enabled")
+	}
+	if len(req.GitRef) > 256 || strings.ContainsFunc(req.GitRef, unicode.IsControl) {
+		return nil, newError(ErrInvalidInput, "invalid gitRef: must be at most 256 characters without control characters")
+	}
+
+	if !req.Distro.IsValid() {
+		return nil, newError(ErrInvalidInput, "distro cannot be empty")
+	}
+	if !req.Target.IsValid() {
+		return nil, newError(ErrInvalidInput, "target cannot be empty")
+	}
+	if !req.Architecture.IsValid() {
+		return nil, newError(ErrInvalidInput, "architecture cannot be empty")
+	}
+	if !req.ExportFormat.IsValid() {
+		return nil, newError(ErrInvalidInput, "exportFormat cannot be empty")
+	}
+	if !req.Mode.IsValid() {
+		return nil, newError(ErrInvalidInput, "mode cannot be empty")
+	}
+	if err := userlabels.Validate(req.Labels); err != nil {
+		return nil, newError(ErrInvalidInput, "%s", err.Error())
+	}
+	if req.WorkspaceSize != "" {
+		if err := validateWorkspaceSize(req.WorkspaceSize); err != nil {
+			return nil, err
+		}
+	}
+	// the build picks the main manifest of an artifact itself unless told which one
+	if req.ManifestFileName == "" && req.ManifestRef == "" {
+		req.ManifestFileName = "manifest.aib.yml"
+	}
+	if req.ManifestFileName != "" {
+		if err := validateManifestFiles(req.ManifestFileName, req.AdditionalManifests); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkBuildSystemReady(ctx); err != nil {
+		return nil, err
+	}
+	// manifests of an artifact are only seen by the build, so they cannot be linted here
+	lint := &LintResponse{}
+	if req.ManifestRef == "" {
+		var err error
+		lint, err = s.LintManifests(ctx, LintRequest{
+			Manifest:            req.Manifest,
+			ManifestFileName:    req.ManifestFileName,
+			AdditionalManifests: req.AdditionalManifests,
+			CustomDefs:          req.CustomDefs,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if lint.Blocked {
+		return nil, newError(ErrInvalidInput, "manifest violates lint policy: %s", lintSummary(lint.Violations, LintActionBlock))
+	}
+	catalog, err := s.Catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := catalog.Validate(req.Distro, req.Target, req.Architecture); err != nil {
+		return nil, newError(ErrInvalidInput, "%s", err.Error())
+	}
+	if err := s.checkUnprivileged(ctx, &req); err != nil {
+		return nil, err
+	}
+	if req.ArtifactNameTemplate != "" {
+		if err := artifactname.Validate(req.ArtifactNameTemplate); err != nil {
+			return nil, newError(ErrInvalidInput, "%s", err.Error())
+		}
+	}
+	if len(req.AIBExtraArgs) > 0 || len(req.AIBOverrideArgs) > 0 {
+		allowed, err := s.allowedAIBArgs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateAIBArgs("aibExtraArgs", req.AIBExtraArgs, allowed); err != nil {
+			return nil, newError(ErrUnprocessable, "%s", err.Error())
+		}
+		if err := validateAIBArgs("aibOverrideArgs", req.AIBOverrideArgs, allowed); err != nil {
+			return nil, newError(ErrUnprocessable, "%s", err.Error())
+		}
+	}
+
+	if req.GenerateName != "" {
+		if req.Name, err = s.generateBuildName(ctx, req.GenerateName); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.cluster.GetImageBuild(ctx, req.Name); err == nil {
+		return nil, newError(ErrConflict, "ImageBuild %s already exists", req.Name)
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error checking existing build: %w", err)
+	}
+
+	// the content of uploaded files is unknown here, so builds using them are neither reused nor reusable;
+	// neither are builds of an artifact tag, which may since have been pushed again, nor encrypted builds,
+	// whose artifacts only open with their own key, nor builds committing to an ostree ref, which must push
+	// a new commit, nor builds reading an input claim, whose files may have changed since
+	encrypted := req.EncryptionKey != "" || req.EncryptionKeySecret != ""
+	var contentHash string
+	if !needsUpload && !encrypted && req.OSTree == nil && req.InputPVC == nil &&
+		(req.ManifestRef == "" || strings.Contains(req.ManifestRef, "@")) {
+		contentHash = buildContentHash(req)
+	}
+	if contentHash != "" {
+		if req.Cache == cacheBypass {
+			cacheLookupsTotal.WithLabelValues(s.requestNamespace(ctx), cacheBypass).Inc()
+		} else if req.ReuseExisting || slices.Contains(s.EnabledFeatures(ctx), features.ResultCache) {
+			cached, err := s.lookupCache(ctx, contentHash, req.Name, requestedBy)
+			if err != nil {
+				return nil, err
+			}
+			if cached != nil {
+				return cached, nil
+			}
+		}
+	}
+
+	cfgName := fmt.Sprintf("%s-manifest", req.Name)
+	cmData := map[string]string{}
+	if req.ManifestRef == "" {
+		cmData[req.ManifestFileName] = req.Manifest
+	}
+	for _, m := range req.AdditionalManifests {
+		cmData[m.Name] = m.Content
+	}
+
+	if len(req.CustomDefs) > 0 {
+		cmData["custom-definitions.env"] = strings.Join(req.CustomDefs, "\n")
+	}
+	if len(req.AIBOverrideArgs) > 0 {
+		// If override is provided, prefer it and ignore the regular extra args
+		cmData["aib-override-args.txt"] = strings.Join(req.AIBOverrideArgs, " ")
+	} else if len(req.AIBExtraArgs) > 0 {
+		cmData["aib-extra-args.txt"] = strings.Join(req.AIBExtraArgs, " ")
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: cfgName,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":                  "build-api",
+				"app.kubernetes.io/part-of":                     "automotive-dev",
+				"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
+				"automotive.sdv.cloud.redhat.com/resource-type": "manifest-config",
+			},
+		},
+		Data: cmData,
+	}
+	userlabels.Apply(cm.Labels, req.Labels)
+	if err := s.cluster.CreateConfigMap(ctx, cm); err != nil {
+		return nil, fmt.Errorf("error creating manifest ConfigMap: %w", err)
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by":                 "build-api",
+		"app.kubernetes.io/part-of":                    "automotive-dev",
+		"app.kubernetes.io/created-by":                 "automotive-dev-build-api",
+		"automotive.sdv.cloud.redhat.com/distro":       string(req.Distro),
+		"automotive.sdv.cloud.redhat.com/target":       string(req.Target),
+		"automotive.sdv.cloud.redhat.com/architecture": string(req.Architecture),
+	}
+	if contentHash != "" {
+		labels[contentHashLabel] = contentHash
+	}
+	if req.IdempotencyKey != "" {
+		labels[idempotencyKeyLabel] = idempotencyKeyHash(req.IdempotencyKey, requestedBy)
+	}
+	userlabels.Apply(labels, req.Labels)
+
+	serveExpiryHours := int32(defaultServeExpiryHours)
+	if autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev"); err == nil {
+		if autoDev.Spec.BuildConfig != nil && autoDev.Spec.BuildConfig.ServeExpiryHours > 0 {
+			serveExpiryHours = autoDev.Spec.BuildConfig.ServeExpiryHours
+		}
+	}
+
+	var envSecretRef string
+	if req.RegistryCredentials != nil && req.RegistryCredentials.Enabled {
+		secretName, err := s.createRegistrySecret(ctx, req.Name, req.RegistryCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("error creating registry secret: %w", err)
+		}
+		envSecretRef = secretName
+	}
+	encryptionKeySecretRef := req.EncryptionKeySecret
+	if req.EncryptionKey != "" {
+		secretName, err := s.createEncryptionKeySecret(ctx, req.Name, req.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating encryption key secret: %w", err)
+		}
+		encryptionKeySecretRef = secretName
+	}
+
+	// manifests of an artifact are only seen by the build, so their workspace cannot be estimated here
+	workspaceSize, workspaceEstimate := req.WorkspaceSize, ""
+	if workspaceSize == "" && req.ManifestRef == "" {
+		exportFormat := string(req.ExportFormat)
+		if len(req.AIBOverrideArgs) > 0 {
+			exportFormat = aibArgValue(req.AIBOverrideArgs, "--export", exportFormat)
+		} else {
+			exportFormat = aibArgValue(req.AIBExtraArgs, "--export", exportFormat)
+		}
+		manifests := append([]ManifestFile{{Name: req.ManifestFileName, Content: req.Manifest}}, req.AdditionalManifests...)
+		workspaceSize, workspaceEstimate = manifestWorkspaceHints(manifests, req.CustomDefs, exportFormat).estimate()
+	}
+
+	imageBuild := &automotivev1.ImageBuild{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   req.Name,
+			Labels: labels,
+			Annotations: correlation.FromContext(ctx).Annotate(map[string]string{
+				"automotive.sdv.cloud.redhat.com/requested-by": requestedBy,
+				requestedByGroupsAnnotation:                    strings.Join(requesterGroupsFrom(ctx), ","),
+				accessGroupsAnnotation:                         strings.Join(buildAccessGroups(req.AccessGroups, requesterGroupsFrom(ctx)), ","),
+			}),
+		},
+		Spec: automotivev1.ImageBuildSpec{
+			Distro:                 string(req.Distro),
+			Target:                 string(req.Target),
+			Architecture:           string(req.Architecture),
+			ExportFormat:           string(req.ExportFormat),
+			Mode:                   string(req.Mode),
+			Profile:                req.Profile,
+			AutomotiveImageBuilder: req.AutomotiveImageBuilder,
+			StorageClass:           req.StorageClass,
+			ServeArtifact:          req.ServeArtifact,
+			ExposeRoute:            req.ServeArtifact,
+			ServeExpiryHours:       serveExpiryHours,
+			ManifestConfigMap:      cfgName,
+			ManifestFile:           req.ManifestFileName,
+			ManifestRef:            req.ManifestRef,
+			InputFilesServer:       needsUpload,
+			EnvSecretRef:           envSecretRef,
+			Compression:            req.Compression,
+			ArtifactNameTemplate:   req.ArtifactNameTemplate,
+			EncryptionKeySecretRef: encryptionKeySecretRef,
+			KeepWorkspaceOnFailure: req.KeepWorkspaceOnFailure,
+			OSTree:                 ostreeSpec(req.OSTree),
+			InputPVC:               inputPVCSpec(req.InputPVC),
+			WorkspaceSize:          workspaceSize,
+			WorkspaceSizeEstimate:  workspaceEstimate,
+		},
+	}
+	imageBuild.Annotations[manifesthash.Annotation] = manifesthash.Sum(cm)
+	if req.BuildInfo {
+		imageBuild.Spec.BuildInfo = &automotivev1.BuildInfo{Enabled: true, GitRef: req.GitRef}
+	}
+	if req.GitCommit != nil {
+		req.GitCommit.Annotate(imageBuild.Annotations)
+	}
+	if err := s.cluster.CreateImageBuild(ctx, imageBuild); err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			// the existence check above reads from a cache that may lag behind
+			return nil, newError(ErrConflict, "ImageBuild %s already exists", req.Name)
+		}
+		return nil, fmt.Errorf("error creating ImageBuild: %w", err)
+	}
+
+	// Owner references are best-effort: a missing one only delays cleanup
+	_ = s.cluster.SetControllerOwner(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cfgName}}, imageBuild)
+	if envSecretRef != "" {
+		_ = s.cluster.SetControllerOwner(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: envSecretRef}}, imageBuild)
+	}
+	if req.EncryptionKey != "" {
+		_ = s.cluster.SetControllerOwner(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: encryptionKeySecretRef}}, imageBuild)
+	}
+
+	return &BuildResponse{
+		Name:                  req.Name,
+		Phase:                 "Building",
+		Message:               "Build triggered",
+		RequestedBy:           requestedBy,
+		Profile:               req.Profile,
+		LintWarnings:          lint.Violations,
+		WorkspaceSize:         workspaceSize,
+		WorkspaceSizeEstimate: workspaceEstimate,
+	}, nil
+}
+
+// reservedManifestKeys are the manifest ConfigMap keys that hold build settings rather than manifests
+var reservedManifestKeys = map[string]bool{
+	"custom-definitions.env": true,
+	"aib-extra-args.txt":     true,
+	"aib-override-args.txt":  true,
+}
+
+// validateManifestRef checks that a manifest artifact reference names a repository, and a tag or digest
+// at most, since the build hands it to oras as is
+func validateManifestRef(ref string) error {
+	if len(ref) > 512 || strings.ContainsFunc(ref, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return newError(ErrInvalidInput, "invalid manifestRef: must be at most 512 characters without spaces")
+	}
+	repo, _, _ := strings.Cut(ref, "@")
+	if strings.HasPrefix(ref, "-") || !strings.Contains(repo, "/") || strings.HasSuffix(repo, "/") {
+		return newError(ErrInvalidInput, "invalid manifestRef %q: must be a registry/repository[:tag][@digest] reference", ref)
+	}
+	return nil
+}
+
+// validateManifestFiles checks that the main and additional manifests can share one ConfigMap
+func validateManifestFiles(main string, additional []ManifestFile) error {
+	seen := map[string]bool{}
+	for _, name := range append([]string{main}, manifestFileNames(additional)...) {
+		if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+			return newError(ErrInvalidInput, "invalid manifest file name %q: %s", name, strings.Join(errs, "; "))
+		}
+		if reservedManifestKeys[name] {
+			return newError(ErrInvalidInput, "manifest file name %q is reserved", name)
+		}
+		if seen[name] {
+			return newError(ErrInvalidInput, "duplicate manifest file name %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func manifestFileNames(files []ManifestFile) []string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func (s *buildService) ListBuilds(ctx context.Context, labels map[string]string) ([]BuildListItem, error) {
+	builds, err := s.cluster.ListImageBuilds(ctx, labels)
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	resp := make([]BuildListItem, 0, len(builds))
+	for i := range builds {
+		resp = append(resp, convertImageBuildToListItem(&builds[i]))
+	}
+	return resp, nil
+}
+
+// convertImageBuildToListItem converts a single ImageBuild to BuildListItem
+func convertImageBuildToListItem(b *automotivev1.ImageBuild) BuildListItem {
+	var startStr, compStr string
+	if b.Status.StartTime != nil {
+		startStr = b.Status.StartTime.Time.Format(time.RFC3339)
+	}
+	if b.Status.CompletionTime != nil {
+		compStr = b.Status.CompletionTime.Time.Format(time.RFC3339)
+	}
+	return BuildListItem{
+		Name:             b.Name,
+		Phase:            b.Status.Phase,
+		Message:          b.Status.Message,
+		RequestedBy:      b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
+		AccessGroups:     splitGroups(b.Annotations[accessGroupsAnnotation]),
+		CreatedAt:        b.CreationTimestamp.Time.Format(time.RFC3339),
+		StartTime:        startStr,
+		CompletionTime:   compStr,
+		Labels:           userlabels.Filter(b.Labels),
+		ArtifactFileName: b.Status.ArtifactFileName,
+		ArtifactSize:     b.Status.ArtifactSize,
+		Encrypted:        b.Spec.EncryptionKeySecretRef != "",
+		UploadProgress:   uploadProgressOf(b),
+	}
+}
+
+func (s *buildService) GetBuild(ctx context.Context, name string) (*BuildResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &BuildResponse{
+		Name:                build.Name,
+		Phase:               build.Status.Phase,
+		Message:             build.Status.Message,
+		RequestedBy:         build.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
+		AccessGroups:        splitGroups(build.Annotations[accessGroupsAnnotation]),
+		RequestID:           build.Annotations[correlation.RequestIDAnnotation],
+		Profile:             build.Spec.Profile,
+		ArtifactURL:         build.Status.ArtifactURL,
+		ArtifactFileName:    build.Status.ArtifactFileName,
+		ArtifactSHA256:      build.Status.ArtifactSHA256,
+		ArtifactSize:        build.Status.ArtifactSize,
+		ArtifactContentType: build.Status.ArtifactContentType,
+		Encrypted:           build.Spec.EncryptionKeySecretRef != "",
+		BuilderImageDigest:  build.Status.BuilderImageDigest,
+		ManifestSHA256:      build.Annotations[manifesthash.Annotation],
+		OSTreeCommit:        build.Status.OSTreeCommit,
+		UploadProgress:      uploadProgressOf(build),
+	}
+	if scan := build.Status.Scan; scan != nil {
+		resp.Scan = &ScanSummary{
+			Critical: scan.Critical,
+			High:     scan.High,
+			Medium:   scan.Medium,
+			Low:      scan.Low,
+			Blocked:  scan.Blocked,
+		}
+	}
+	if cost := build.Status.Cost; cost != nil {
+		resp.Cost = &BuildCost{
+			Total:         cost.Total,
+			Compute:       cost.Compute,
+			Storage:       cost.Storage,
+			Currency:      cost.Currency,
+			InstanceTypes: cost.InstanceTypes,
+		}
+	}
+	if build.Status.WorkspaceExpiryTime != nil {
+		resp.WorkspaceExpiryTime = build.Status.WorkspaceExpiryTime.Time.Format(time.RFC3339)
+	}
+	resp.WorkspaceSize = build.Status.WorkspaceSize
+	if resp.WorkspaceSize == "" {
+		resp.WorkspaceSize = build.Spec.WorkspaceSize
+	}
+	resp.WorkspaceSizeEstimate = build.Spec.WorkspaceSizeEstimate
+	if usage := build.Status.ResourceUsage; usage != nil {
+		resp.WorkspacePeakBytes = usage.WorkspaceBytes + usage.PrunedWorkspaceBytes
+	}
+	if build.Status.StartTime != nil {
+		resp.StartTime = build.Status.StartTime.Time.Format(time.RFC3339)
+	}
+	if build.Status.CompletionTime != nil {
+		resp.CompletionTime = build.Status.CompletionTime.Time.Format(time.RFC3339)
+	}
+	for _, c := range build.Status.Conversions {
+		resp.Conversions = append(resp.Conversions, toArtifactConversion(c))
+	}
+	s.setConsoleLinks(ctx, build, resp)
+	return resp, nil
+}
+
+func (s *buildService) CancelBuild(ctx context.Context, name, requestedBy string) (*BuildResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.Phase == "Completed" || build.Status.Phase == "Failed" {
+		return nil, newError(ErrConflict, "build %s already finished (%s)", name, build.Status.Phase)
+	}
+
+	if _, requested := build.Annotations["automotive.sdv.cloud.redhat.com/cancel-requested-by"]; !requested {
+		patched := build.DeepCopy()
+		if patched.Annotations == nil {
+			patched.Annotations = map[string]string{}
+		}
+		// the controller stops the TaskRun or upload server and fails the build
+		patched.Annotations["automotive.sdv.cloud.redhat.com/cancel-requested-by"] = requestedBy
+		if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+			return nil, fmt.Errorf("error requesting cancellation: %w", err)
+		}
+	}
+
+	resp, err := s.GetBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Message = "Cancellation requested"
+	return resp, nil
+}
+
+func (s *buildService) DeleteBuild(ctx context.Context, name string, force bool) (*BuildResponse, error) {
+	resp, err := s.GetBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !force && resp.Phase != "Completed" && resp.Phase != "Failed" {
+		phase := resp.Phase
+		if phase == "" {
+			phase = "Pending"
+		}
+		return nil, newError(ErrConflict, "build %s has not finished (%s); cancel it first or force the deletion", name, phase)
+	}
+
+	// the TaskRun, workspace, pods and manifest ConfigMap are owned by the build and garbage collected with
+	// it, but the secret's owner reference is set best-effort, so credentials must not rely on it
+	if err := s.cluster.DeleteSecret(ctx, registrySecretName(name)); err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error deleting registry secret: %w", err)
+	}
+	if err := s.cluster.DeleteSecret(ctx, encryptionKeySecretName(name)); err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error deleting encryption key secret: %w", err)
+	}
+	if err := s.cluster.DeleteImageBuild(ctx, name); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "build %s not found", name)
+		}
+		return nil, fmt.Errorf("error deleting build: %w", err)
+	}
+
+	resp.Message = "Build deleted"
+	return resp, nil
+}
+
+// GetBuildTemplate returns a BuildRequest-like struct representing the inputs that produced a given build
+func (s *buildService) GetBuildTemplate(ctx context.Context, name string) (*BuildTemplateResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := s.cluster.GetConfigMap(ctx, build.Spec.ManifestConfigMap)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest config: %w", err)
+	}
+
+	// Rehydrate advanced args
+	var customDefs []string
+	var aibExtra []string
+	var aibOverride []string
+	if v, ok := cm.Data["custom-definitions.env"]; ok {
+		customDefs = strings.Fields(v)
+	}
+	if v, ok := cm.Data["aib-extra-args.txt"]; ok {
+		fields := strings.Fields(strings.TrimSpace(v))
+		aibExtra = append(aibExtra, fields...)
+	}
+	if v, ok := cm.Data["aib-override-args.txt"]; ok {
+		fields := strings.Fields(strings.TrimSpace(v))
+		aibOverride = append(aibOverride, fields...)
+	}
+
+	manifestFileName := build.Spec.ManifestFile
+	var manifestKeys []string
+	for k := range cm.Data {
+		if !reservedManifestKeys[k] {
+			manifestKeys = append(manifestKeys, k)
+		}
+	}
+	sort.Strings(manifestKeys)
+	if manifestFileName == "" && build.Spec.ManifestRef == "" {
+		// builds created before the main manifest was recorded hold a single manifest
+		manifestFileName = "manifest.aib.yml"
+		if len(manifestKeys) > 0 {
+			manifestFileName = manifestKeys[0]
+		}
+	}
+	manifest := cm.Data[manifestFileName]
+	var additional []ManifestFile
+	for _, k := range manifestKeys {
+		if k != manifestFileName {
+			additional = append(additional, ManifestFile{Name: k, Content: cm.Data[k]})
+		}
+	}
+
+	var sourceFiles []string
+	manifests := manifest
+	for _, m := range additional {
+		manifests += "\n" + m.Content
+	}
+	for _, line := range strings.Split(manifests, "\n") {
+		s := strings.TrimSpace(line)
+		if strings.HasPrefix(s, "source:") || strings.HasPrefix(s, "source_path:") {
+			parts := strings.SplitN(s, ":", 2)
+			if len(parts) == 2 {
+				p := strings.TrimSpace(parts[1])
+				p = strings.Trim(p, "'\"")
+				if p != "" && !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "http") {
+					sourceFiles = append(sourceFiles, p)
+				}
+			}
+		}
+	}
+
+	buildInfo, gitRef := false, ""
+	if build.Spec.BuildInfo != nil {
+		buildInfo, gitRef = build.Spec.BuildInfo.Enabled, build.Spec.BuildInfo.GitRef
+	}
+
+	return &BuildTemplateResponse{
+		BuildRequest: BuildRequest{
+			Name:                   build.Name,
+			Manifest:               manifest,
+			ManifestFileName:       manifestFileName,
+			AdditionalManifests:    additional,
+			ManifestRef:            build.Spec.ManifestRef,
+			Distro:                 Distro(build.Spec.Distro),
+			Target:                 Target(build.Spec.Target),
+			Architecture:           Architecture(build.Spec.Architecture),
+			ExportFormat:           ExportFormat(build.Spec.ExportFormat),
+			Mode:                   Mode(build.Spec.Mode),
+			AutomotiveImageBuilder: build.Spec.AutomotiveImageBuilder,
+			CustomDefs:             customDefs,
+			AIBExtraArgs:           aibExtra,
+			AIBOverrideArgs:        aibOverride,
+			ServeArtifact:          build.Spec.ServeArtifact,
+			Compression:            build.Spec.Compression,
+			ArtifactNameTemplate:   build.Spec.ArtifactNameTemplate,
+			Labels:                 userlabels.Filter(build.Labels),
+			AccessGroups:           splitGroups(build.Annotations[accessGroupsAnnotation]),
+			KeepWorkspaceOnFailure: build.Spec.KeepWorkspaceOnFailure,
+			BuildInfo:              buildInfo,
+			GitRef:                 gitRef,
+			OSTree:                 ostreeRequest(build.Spec.OSTree),
+			InputPVC:               inputPVCRequest(build.Spec.InputPVC),
+		},
+		SourceFiles: sourceFiles,
+	}, nil
+}
+
+// GetTaskRun returns a sanitized view of the TaskRun backing a build
+func (s *buildService) GetTaskRun(ctx context.Context, name string) (*TaskRunResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	trName := strings.TrimSpace(build.Status.TaskRunName)
+	if trName == "" {
+		return nil, newError(ErrNotFound, "no TaskRun for this build yet")
+	}
+
+	tr, err := s.cluster.GetTaskRun(ctx, trName)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "taskrun not found")
+		}
+		return nil, fmt.Errorf("error fetching taskrun: %w", err)
+	}
+
+	resp := convertTaskRun(tr)
+	return &resp, nil
+}
+
+func (s *buildService) BuildUsage(ctx context.Context, name string) (*BuildUsageResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.Phase != "Completed" && build.Status.Phase != "Failed" {
+		return nil, newError(ErrConflict, "build %s has not finished", name)
+	}
+	usage := build.Status.ResourceUsage
+	if usage == nil {
+		return nil, newError(ErrNotFound, "build %s reported no resource usage", name)
+	}
+
+	resp := &BuildUsageResponse{
+		Name:                 build.Name,
+		PeakMemoryBytes:      usage.PeakMemoryBytes,
+		CPUSeconds:           usage.CPUSeconds,
+		DiskBytes:            usage.DiskBytes,
+		WorkspaceBytes:       usage.WorkspaceBytes,
+		PrunedWorkspaceBytes: usage.PrunedWorkspaceBytes,
+	}
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error reading build configuration: %w", err)
+	}
+	var buildConfig *automotivev1.BuildConfig
+	if err == nil {
+		buildConfig = autoDev.Spec.BuildConfig
+	}
+	if buildConfig != nil && buildConfig.UseMemoryVolumes {
+		resp.MemoryVolumeSize = buildConfig.MemoryVolumeSize
+	}
+	if usage.WorkspaceBytes > 0 {
+		resp.PVCSize = build.Status.WorkspaceSize
+		if resp.PVCSize == "" {
+			resp.PVCSize = defaultPVCSize
+			if buildConfig != nil && buildConfig.PVCSize != "" {
+				resp.PVCSize = buildConfig.PVCSize
+			}
+		}
+		resp.SuggestedPVCSize = suggestedPVCSize(usage.WorkspaceBytes + usage.PrunedWorkspaceBytes)
+	}
+	return resp, nil
+}
+
+// defaultPVCSize is the size of workspace PVCs when the AutomotiveDev does not set BuildConfig.PVCSize
+const defaultPVCSize = "8Gi"
+
+// suggestedPVCSize rounds the most a workspace held up to whole GiB with a quarter of headroom: pruning
+// frees the intermediates only after the artifact is written, so the PVC must fit both at once
+func suggestedPVCSize(peakBytes int64) string {
+	const gi = int64(1) << 30
+	withHeadroom := peakBytes + peakBytes/4
+	return fmt.Sprintf("%dGi", (withHeadroom+gi-1)/gi)
+}
+
+// convertTaskRun strips a TaskRun down to the fields useful for debugging a build
+func convertTaskRun(tr *tektonv1.TaskRun) TaskRunResponse {
+	resp := TaskRunResponse{
+		Name:    tr.Name,
+		PodName: tr.Status.PodName,
+	}
+	if len(tr.Status.Conditions) > 0 {
+		cond := tr.Status.Conditions[0]
+		resp.Status = string(cond.Status)
+		resp.Reason = cond.Reason
+		resp.Message = cond.Message
+	}
+	if tr.Status.StartTime != nil {
+		resp.StartTime = tr.Status.StartTime.Time.Format(time.RFC3339)
+	}
+	if tr.Status.CompletionTime != nil {
+		resp.CompletionTime = tr.Status.CompletionTime.Time.Format(time.RFC3339)
+	}
+	for _, st := range tr.Status.Steps {
+		step := TaskRunStepStatus{Name: st.Name, Container: st.Container}
+		switch {
+		case st.Terminated != nil:
+			step.State = "Terminated"
+			step.Reason = st.Terminated.Reason
+			if st.TerminationReason != "" {
+				step.Reason = st.TerminationReason
+			}
+			step.Message = st.Terminated.Message
+			step.ExitCode = &st.Terminated.ExitCode
+			step.StartedAt = st.Terminated.StartedAt.Time.Format(time.RFC3339)
+			step.FinishedAt = st.Terminated.FinishedAt.Time.Format(time.RFC3339)
+		case st.Running != nil:
+			step.State = "Running"
+			step.StartedAt = st.Running.StartedAt.Time.Format(time.RFC3339)
+		case st.Waiting != nil:
+			step.State = "Waiting"
+			step.Reason = st.Waiting.Reason
+			step.Message = st.Waiting.Message
+		default:
+			step.State = "Unknown"
+		}
+		resp.Steps = append(resp.Steps, step)
+	}
+	return resp
+}
+
+// allowedLifecycleTransitions lists the states each Image lifecycle state may move to; revoked is terminal
+var allowedLifecycleTransitions = map[automotivev1.ImageLifecycle][]automotivev1.ImageLifecycle{
+	automotivev1.ImageLifecycleCandidate:  {automotivev1.ImageLifecycleReleased, automotivev1.ImageLifecycleRevoked},
+	automotivev1.ImageLifecycleReleased:   {automotivev1.ImageLifecycleDeprecated, automotivev1.ImageLifecycleRevoked},
+	automotivev1.ImageLifecycleDeprecated: {automotivev1.ImageLifecycleReleased, automotivev1.ImageLifecycleRevoked},
+}
+
+func validateLifecycleTransition(from, to automotivev1.ImageLifecycle) error {
+	if from == "" {
+		from = automotivev1.ImageLifecycleCandidate
+	}
+	if _, known := allowedLifecycleTransitions[to]; !known && to != automotivev1.ImageLifecycleRevoked {
+		return fmt.Errorf("unknown lifecycle state %q", to)
+	}
+	if from == to {
+		return fmt.Errorf("image is already %s", to)
+	}
+	for _, next := range allowedLifecycleTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot transition image from %s to %s", from, to)
+}
+
+func (s *buildService) SetImageLifecycle(ctx context.Context, name string, req ImageLifecycleRequest, requestedBy string) (*ImageLifecycleResponse, error) {
+	target := automotivev1.ImageLifecycle(strings.TrimSpace(req.State))
+	if target == "" {
+		return nil, newError(ErrInvalidInput, "state is required")
+	}
+
+	image, err := s.cluster.GetImage(ctx, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "not found")
+		}
+		return nil, fmt.Errorf("error fetching image: %w", err)
+	}
+
+	previous := image.Spec.Lifecycle
+	if previous == "" {
+		previous = automotivev1.ImageLifecycleCandidate
+	}
+	if err := validateLifecycleTransition(previous, target); err != nil {
+		return nil, newError(ErrConflict, "%s", err.Error())
+	}
+
+	changedAt := time.Now().UTC().Format(time.RFC3339)
+	reason := strings.TrimSpace(req.Reason)
+
+	patched := image.DeepCopy()
+	patched.Spec.Lifecycle = target
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	patched.Annotations["automotive.sdv.cloud.redhat.com/lifecycle-changed-by"] = requestedBy
+	patched.Annotations["automotive.sdv.cloud.redhat.com/lifecycle-changed-at"] = changedAt
+	patched.Annotations["automotive.sdv.cloud.redhat.com/lifecycle-previous"] = string(previous)
+	if reason != "" {
+		patched.Annotations["automotive.sdv.cloud.redhat.com/lifecycle-reason"] = reason
+	} else {
+		delete(patched.Annotations, "automotive.sdv.cloud.redhat.com/lifecycle-reason")
+	}
+	if err := s.cluster.PatchImage(ctx, image, patched); err != nil {
+		return nil, fmt.Errorf("error updating image: %w", err)
+	}
+
+	return &ImageLifecycleResponse{
+		Name:      image.Name,
+		Previous:  string(previous),
+		State:     string(target),
+		ChangedBy: requestedBy,
+		ChangedAt: changedAt,
+		Reason:    reason,
+	}, nil
+}
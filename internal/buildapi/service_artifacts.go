@@ -0,0 +1,397 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifactname"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifacttype"
+)
+
+// artifactPodTimeout is how long artifact requests wait for the artifact pod to become ready
+const artifactPodTimeout = 2 * time.Minute
+
+// The artifact pod's nginx serves the artifact and its parts to the build API on artifactProxyPort, to
+// requests carrying the token the ImageBuild controller keeps in the pod's nginx ConfigMap
+const (
+	artifactProxyPort        = 8082
+	artifactProxyTokenKey    = "proxy-token"
+	artifactProxyTokenHeader = "X-Artifact-Proxy-Token"
+)
+
+// artifactsTarExcludes are workspace entries that are build plumbing rather than outputs:
+// the compressed -parts directories duplicate the main artifact, the rest is filesystem or tool state.
+var artifactsTarExcludes = []string{"./lost+found", "./.*", "./*-parts"}
+
+// ArtifactListResponse lists the files of a build's compressed artifact parts
+type ArtifactListResponse struct {
+	Items []ArtifactItem `json:"items"`
+}
+
+// ArtifactItem is one file of a build's compressed artifact parts
+type ArtifactItem struct {
+	Name      string `json:"name"`
+	SizeBytes string `json:"sizeBytes"`
+	// ContentType is the media type of the item once decompressed and decrypted
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Artifact is a file ready to be streamed out of a build's artifact pod
+type Artifact struct {
+	FileName string
+	// ContentType is the media type of the file's payload the build recorded, empty to derive it from FileName
+	ContentType string
+	// Size is the size in bytes as reported by the pod, empty if unknown
+	Size   string
+	stream func(ctx context.Context, w io.Writer) error
+}
+
+// WriteTo streams the artifact content to w
+func (a *Artifact) WriteTo(ctx context.Context, w io.Writer) error {
+	return a.stream(ctx, w)
+}
+
+// completedBuild returns a build whose artifacts may be downloaded: it must have completed, must not be
+// blocked by the scan policy and must not have produced a revoked Image
+func (s *buildService) completedBuild(ctx context.Context, name string) (*automotivev1.ImageBuild, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if build.Status.Phase != "Completed" {
+		return nil, newError(ErrConflict, "artifact not available until build completes")
+	}
+	if build.Status.Scan != nil && build.Status.Scan.Blocked {
+		return nil, newError(ErrForbidden, "artifact of build %s is blocked by the scan policy (%d critical vulnerabilities)", name, build.Status.Scan.Critical)
+	}
+
+	if revoked, err := s.findRevokedImage(ctx, name); err != nil {
+		return nil, fmt.Errorf("error checking image lifecycle: %w", err)
+	} else if revoked != "" {
+		return nil, newError(ErrGone, "image %s has been revoked", revoked)
+	}
+	return build, nil
+}
+
+// artifactFileName returns the name of the build's main artifact in the shared workspace
+func (s *buildService) artifactFileName(ctx context.Context, build *automotivev1.ImageBuild) string {
+	if build.Status.ArtifactFileName != "" {
+		return build.Status.ArtifactFileName
+	}
+	var buildConfig *automotivev1.BuildConfig
+	if autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev"); err == nil {
+		buildConfig = autoDev.Spec.BuildConfig
+	}
+	return artifactname.FileName(build, buildConfig)
+}
+
+// artifactMetadataFileName returns the name of the JSON file the build task writes next to the main artifact,
+// describing it for flashing tools: its size, checksum, compression, build settings and builder image
+func (s *buildService) artifactMetadataFileName(ctx context.Context, build *automotivev1.ImageBuild) string {
+	return s.artifactFileName(ctx, build) + ".metadata.json"
+}
+
+// artifactPod waits for the build's artifact pod to become ready
+func (s *buildService) artifactPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	pod, err := s.cluster.WaitForArtifactPod(ctx, name, artifactPodTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifact pods: %w", err)
+	}
+	if pod == nil {
+		return nil, newError(ErrNotReady, "artifact pod not ready")
+	}
+	return pod, nil
+}
+
+// findRevokedImage returns the name of a revoked Image produced by the given build, if any
+func (s *buildService) findRevokedImage(ctx context.Context, buildName string) (string, error) {
+	images, err := s.cluster.ListImages(ctx)
+	if err != nil {
+		return "", err
+	}
+	for i := range images {
+		img := &images[i]
+		if img.Spec.Metadata == nil || img.Spec.Metadata.SourceImageBuild != buildName {
+			continue
+		}
+		if img.Spec.Lifecycle == automotivev1.ImageLifecycleRevoked {
+			return img.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// fileSize returns the size of podPath in the artifact pod, or an ErrNotFound error carrying notFoundMsg
+func (s *buildService) fileSize(ctx context.Context, pod *corev1.Pod, podPath, notFoundMsg string) (string, error) {
+	var out strings.Builder
+	cmd := shellCommand(`if [ -f "$1" ]; then wc -c < "$1"; else echo MISSING; fi`, podPath)
+	if err := s.cluster.Exec(ctx, pod.Name, "fileserver", cmd, &out); err != nil {
+		return "", fmt.Errorf("size stream: %w", err)
+	}
+	sz := strings.TrimSpace(out.String())
+	if sz == "" || sz == "MISSING" {
+		return "", newError(ErrNotFound, "%s", notFoundMsg)
+	}
+	return sz, nil
+}
+
+// shellCommand runs a fixed shell script in a pod with args as its positional parameters ($1, $2, ...).
+// Paths must only reach the script this way: they are never parsed by the shell, so quotes, spaces and
+// other metacharacters in artifact names stay inert.
+func shellCommand(script string, args ...string) []string {
+	return append([]string{"sh", "-c", script, "sh"}, args...)
+}
+
+func (s *buildService) catArtifact(pod *corev1.Pod, fileName, size, podPath string) *Artifact {
+	return &Artifact{
+		FileName: fileName,
+		Size:     size,
+		stream: func(ctx context.Context, w io.Writer) error {
+			return s.cluster.Exec(ctx, pod.Name, "fileserver", []string{"cat", "--", podPath}, w)
+		},
+	}
+}
+
+// openServedFile opens a file of the workspace that the artifact pod's nginx serves: from the artifact
+// cache when it holds the file, over HTTP through the pod proxy, or with exec when nginx does not answer,
+// e.g. in pods created before it served the build API
+func (s *buildService) openServedFile(ctx context.Context, build *automotivev1.ImageBuild, fileName, podPath, notFoundMsg string) (*Artifact, error) {
+	urlPath := strings.TrimPrefix(podPath, "/workspace/shared")
+	// the UID keeps a rebuild under the same name from being served an older artifact
+	key := string(build.UID) + urlPath
+	if f, size := s.artifacts.open(key); f != nil {
+		return &Artifact{
+			FileName: fileName,
+			Size:     strconv.FormatInt(size, 10),
+			stream: func(_ context.Context, w io.Writer) error {
+				defer f.Close()
+				_, err := io.Copy(w, f)
+				return err
+			},
+		}, nil
+	}
+
+	pod, err := s.artifactPod(ctx, build.Name)
+	if err != nil {
+		return nil, err
+	}
+	if resp := s.proxyArtifact(ctx, build.Name, pod, urlPath); resp != nil {
+		var size string
+		if resp.ContentLength >= 0 {
+			size = strconv.FormatInt(resp.ContentLength, 10)
+		}
+		return &Artifact{
+			FileName: fileName,
+			Size:     size,
+			stream: func(_ context.Context, w io.Writer) error {
+				defer resp.Body.Close()
+				return s.artifacts.copy(key, resp.ContentLength, w, resp.Body)
+			},
+		}, nil
+	}
+
+	sz, err := s.fileSize(ctx, pod, podPath, notFoundMsg)
+	if err != nil {
+		return nil, err
+	}
+	return s.catArtifact(pod, fileName, sz, podPath), nil
+}
+
+// proxyArtifact requests urlPath from the artifact pod's nginx, returning nil if it does not serve it
+func (s *buildService) proxyArtifact(ctx context.Context, name string, pod *corev1.Pod, urlPath string) *http.Response {
+	cm, err := s.cluster.GetConfigMap(ctx, name+"-nginx-config")
+	if err != nil || cm.Data[artifactProxyTokenKey] == "" {
+		return nil
+	}
+	header := http.Header{artifactProxyTokenHeader: []string{cm.Data[artifactProxyTokenKey]}}
+	resp, err := s.cluster.ProxyGet(ctx, pod.Name, artifactProxyPort, urlPath, header)
+	if err != nil {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil
+	}
+	return resp
+}
+
+func (s *buildService) ListArtifacts(ctx context.Context, name string) ([]ArtifactItem, error) {
+	build, err := s.completedBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := s.artifactPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	partsDir := "/workspace/shared/" + s.artifactFileName(ctx, build) + "-parts"
+	metadataPath := "/workspace/shared/" + s.artifactMetadataFileName(ctx, build)
+	// entries are NUL-terminated "size:name" pairs so names may hold colons and newlines; the metadata
+	// file comes first, followed by the compressed parts
+	cmd := shellCommand(`set -e; for f in "$2" "$1"/*; do [ -f "$f" ] || continue; s=$(wc -c < "$f"); printf '%s:%s\0' "$s" "$(basename "$f")"; done`, partsDir, metadataPath)
+	var out strings.Builder
+	if err := s.cluster.Exec(ctx, pod.Name, "fileserver", cmd, &out); err != nil {
+		return nil, fmt.Errorf("list stream: %w", err)
+	}
+	if strings.TrimSpace(out.String()) == "" {
+		// Neither metadata nor parts available
+		return []ArtifactItem{}, nil
+	}
+	entries := strings.Split(strings.TrimSuffix(out.String(), "\x00"), "\x00")
+	items := make([]ArtifactItem, 0, len(entries))
+	for _, entry := range entries {
+		size, name, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			continue
+		}
+		items = append(items, ArtifactItem{Name: name, SizeBytes: strings.TrimSpace(size), ContentType: artifacttype.ForFile(name)})
+	}
+	return items, nil
+}
+
+func (s *buildService) OpenArtifactPart(ctx context.Context, name, file string) (*Artifact, error) {
+	if strings.Contains(file, "/") || strings.Contains(file, "..") || strings.TrimSpace(file) == "" {
+		return nil, newError(ErrInvalidInput, "invalid file name")
+	}
+
+	build, err := s.completedBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if file == s.artifactMetadataFileName(ctx, build) {
+		return s.openServedFile(ctx, build, file, "/workspace/shared/"+file, "artifact item not found")
+	}
+	gzPath := "/workspace/shared/" + s.artifactFileName(ctx, build) + "-parts/" + file
+	return s.openServedFile(ctx, build, file, gzPath, "artifact item not found")
+}
+
+func (s *buildService) OpenArtifactByFilename(ctx context.Context, name, filename string) (*Artifact, error) {
+	if strings.Contains(filename, "/") || strings.Contains(filename, "..") || strings.TrimSpace(filename) == "" {
+		return nil, newError(ErrInvalidInput, "invalid file name")
+	}
+
+	build, err := s.completedBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only allow the exact final artifact file name, its conversions or files from the -parts directory
+	expected := strings.TrimSpace(build.Status.ArtifactFileName)
+	base := path.Base(filename)
+	allowed := base == expected || base == s.artifactMetadataFileName(ctx, build) || completedConversion(build, base)
+
+	if !allowed {
+		// Check if it's a part file (from -parts directory), encrypted with the artifact if it is
+		part := strings.TrimSuffix(base, ".enc")
+		plain := strings.TrimSuffix(expected, ".enc")
+		if strings.HasSuffix(part, ".gz") || strings.HasSuffix(part, ".lz4") {
+			// Allow parts that follow the pattern: <expected>-parts/<filename>
+			if strings.Contains(base, ".tar.") || strings.HasPrefix(base, strings.TrimSuffix(plain, path.Ext(plain))) {
+				allowed = true
+			}
+		}
+	}
+
+	if !allowed {
+		return nil, newError(ErrForbidden, "file not allowed")
+	}
+
+	artifact, err := s.openServedFile(ctx, build, base, "/workspace/shared/"+base, "file not found")
+	if err != nil {
+		return nil, err
+	}
+	if base == expected {
+		artifact.ContentType = build.Status.ArtifactContentType
+	}
+	return artifact, nil
+}
+
+// OpenArtifactsTar returns every output in the build's shared workspace as a single tar archive
+func (s *buildService) OpenArtifactsTar(ctx context.Context, name string) (*Artifact, error) {
+	if _, err := s.completedBuild(ctx, name); err != nil {
+		return nil, err
+	}
+	pod, err := s.artifactPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{"tar", "-C", "/workspace/shared", "-cf", "-"}
+	for _, ex := range artifactsTarExcludes {
+		command = append(command, "--exclude="+ex)
+	}
+	command = append(command, ".")
+
+	// The archive is produced on the fly, so its size is unknown up front
+	return &Artifact{
+		FileName: name + "-artifacts.tar",
+		stream: func(ctx context.Context, w io.Writer) error {
+			return s.cluster.Exec(ctx, pod.Name, "fileserver", command, w)
+		},
+	}, nil
+}
+
+// OpenWorkspaceTar returns the shared workspace of a failed build that kept it, including the build
+// directory logs under _build, as a single tar archive
+func (s *buildService) OpenWorkspaceTar(ctx context.Context, name string) (*Artifact, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.Phase != "Failed" {
+		return nil, newError(ErrConflict, "workspace is only kept for failed builds")
+	}
+	if build.Status.WorkspaceExpiryTime == nil {
+		return nil, newError(ErrNotFound, "build %s has no kept workspace; it expired or keepWorkspaceOnFailure was not set", name)
+	}
+	pod, err := s.artifactPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	command := []string{"tar", "-C", "/workspace/shared", "-cf", "-", "--exclude=./lost+found", "."}
+	return &Artifact{
+		FileName: name + "-workspace.tar",
+		stream: func(ctx context.Context, w io.Writer) error {
+			return s.cluster.Exec(ctx, pod.Name, "fileserver", command, w)
+		},
+	}, nil
+}
+
+// OpenScanReport returns the scanner's JSON report of a completed build. It stays available when the
+// scan policy blocks the artifact itself.
+func (s *buildService) OpenScanReport(ctx context.Context, name string) (*Artifact, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.Phase != "Completed" {
+		return nil, newError(ErrConflict, "scan report not available until build completes")
+	}
+	if build.Status.Scan == nil || build.Status.Scan.ReportFileName == "" {
+		return nil, newError(ErrNotFound, "build %s was not scanned", name)
+	}
+	pod, err := s.artifactPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	podPath := "/workspace/shared/" + path.Base(build.Status.Scan.ReportFileName)
+	sz, err := s.fileSize(ctx, pod, podPath, "scan report not found")
+	if err != nil {
+		return nil, err
+	}
+	return s.catArtifact(pod, path.Base(podPath), sz, podPath), nil
+}
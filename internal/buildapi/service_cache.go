@@ -0,0 +1,210 @@
+package buildapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+)
+
+const (
+	// cacheIndexConfigMap maps the content hashes of the builds of a namespace to the Images they were
+	// promoted to, so that identical requests are answered after the builds themselves are gone
+	cacheIndexConfigMap = "automotive-build-cache"
+	// maxCacheEntries bounds the index well below the size limit of a ConfigMap; the oldest entries go first
+	maxCacheEntries = 1000
+)
+
+// cacheEntry is the value of a content hash in the cache index
+type cacheEntry struct {
+	Build     string `json:"build"`
+	Image     string `json:"image"`
+	Namespace string `json:"namespace"`
+	URL       string `json:"url"`
+	Digest    string `json:"digest"`
+	// BuildSeconds is how long the build took, counted as saved by every hit
+	BuildSeconds float64 `json:"buildSeconds,omitempty"`
+	CachedAt     string  `json:"cachedAt"`
+}
+
+// lookupCache answers a request with content hash from the result cache: a completed build whose artifact
+// is still served, or else an Image an identical build was promoted to. It returns nil on a miss.
+func (s *buildService) lookupCache(ctx context.Context, hash, name, requestedBy string) (*BuildResponse, error) {
+	namespace := s.requestNamespace(ctx)
+	existing, err := s.findReusableBuild(ctx, hash, requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		resp, err := s.reuseBuild(ctx, existing, name, requestedBy)
+		if err != nil {
+			return nil, err
+		}
+		recordCacheHit(namespace, buildSeconds(existing))
+		return resp, nil
+	}
+
+	entry, err := s.cachedImage(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		cacheLookupsTotal.WithLabelValues(namespace, cacheMiss).Inc()
+		return nil, nil
+	}
+	recordCacheHit(namespace, entry.BuildSeconds)
+	return &BuildResponse{
+		Name:  entry.Build,
+		Phase: "Completed",
+		Message: fmt.Sprintf("Build %s with identical manifests and settings was promoted to image %s in namespace %s",
+			entry.Build, entry.Image, entry.Namespace),
+		RequestedBy: requestedBy,
+		Reused:      true,
+		CachedImage: &CachedImage{
+			Name:      entry.Image,
+			Namespace: entry.Namespace,
+			URL:       entry.URL,
+			Digest:    entry.Digest,
+		},
+	}, nil
+}
+
+// cachedImage returns the index entry of a content hash if its Image still exists, is not revoked and
+// still points at the pushed digest
+func (s *buildService) cachedImage(ctx context.Context, hash string) (*cacheEntry, error) {
+	cm, err := s.cluster.GetConfigMap(ctx, cacheIndexConfigMap)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading build cache index: %w", err)
+	}
+	raw, ok := cm.Data[hash]
+	if !ok {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		// an entry that cannot be read is a miss; the next promotion of an identical build replaces it
+		return nil, nil
+	}
+
+	img, err := s.cluster.GetImage(k8s.WithNamespace(ctx, entry.Namespace), entry.Image)
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading image %s: %w", entry.Image, err)
+	}
+	if img.Spec.Lifecycle == automotivev1.ImageLifecycleRevoked {
+		return nil, nil
+	}
+	if reg := img.Spec.Location.Registry; reg == nil || reg.URL != entry.URL || reg.Digest != entry.Digest {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// indexPromotedImage records in the cache index of the build's namespace that the build was promoted to
+// an Image. Builds without a content hash are not cached.
+func (s *buildService) indexPromotedImage(ctx context.Context, build *automotivev1.ImageBuild, promoted *PromoteResponse) error {
+	hash := build.Labels[contentHashLabel]
+	if hash == "" {
+		return nil
+	}
+	raw, err := json.Marshal(cacheEntry{
+		Build:        build.Name,
+		Image:        promoted.Image,
+		Namespace:    promoted.Namespace,
+		URL:          promoted.URL,
+		Digest:       promoted.Digest,
+		BuildSeconds: buildSeconds(build),
+		CachedAt:     promoted.PromotedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	retriable := func(err error) bool { return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) }
+	return retry.OnError(retry.DefaultRetry, retriable, func() error {
+		cm, err := s.cluster.GetConfigMap(ctx, cacheIndexConfigMap)
+		if k8serrors.IsNotFound(err) {
+			return s.cluster.CreateConfigMap(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: cacheIndexConfigMap,
+					Labels: map[string]string{
+						"app.kubernetes.io/managed-by":                  "build-api",
+						"app.kubernetes.io/part-of":                     "automotive-dev",
+						"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
+						"automotive.sdv.cloud.redhat.com/resource-type": "build-cache",
+					},
+				},
+				Data: map[string]string{hash: string(raw)},
+			})
+		}
+		if err != nil {
+			return err
+		}
+		cm = cm.DeepCopy()
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[hash] = string(raw)
+		pruneCacheIndex(cm.Data)
+		return s.cluster.UpdateConfigMap(ctx, cm)
+	})
+}
+
+// pruneCacheIndex drops the oldest entries of an index holding more than maxCacheEntries
+func pruneCacheIndex(data map[string]string) {
+	if len(data) <= maxCacheEntries {
+		return
+	}
+	type cached struct {
+		hash string
+		at   string
+	}
+	entries := make([]cached, 0, len(data))
+	for hash, raw := range data {
+		var entry cacheEntry
+		// unreadable entries sort first and go
+		_ = json.Unmarshal([]byte(raw), &entry)
+		entries = append(entries, cached{hash, entry.CachedAt})
+	}
+	// RFC 3339 timestamps in UTC sort chronologically as strings
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at < entries[j].at })
+	for _, e := range entries[:len(entries)-maxCacheEntries] {
+		delete(data, e.hash)
+	}
+}
+
+// requestNamespace is the namespace a request acts on
+func (s *buildService) requestNamespace(ctx context.Context) string {
+	if ns := k8s.NamespaceFrom(ctx); ns != "" {
+		return ns
+	}
+	return s.cluster.Namespace()
+}
+
+// buildSeconds is how long a completed build ran, or 0 if it did not record it
+func buildSeconds(b *automotivev1.ImageBuild) float64 {
+	if b.Status.StartTime == nil || b.Status.CompletionTime == nil {
+		return 0
+	}
+	return b.Status.CompletionTime.Sub(b.Status.StartTime.Time).Seconds()
+}
+
+func recordCacheHit(namespace string, saved float64) {
+	cacheLookupsTotal.WithLabelValues(namespace, cacheHit).Inc()
+	if saved > 0 {
+		cacheSavedSecondsTotal.WithLabelValues(namespace).Add(saved)
+	}
+}
@@ -0,0 +1,120 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+// Catalog returns the built-in distros, targets and architectures extended with the AutomotiveDev's
+// BuildConfig.Catalog, and the built-in profiles merged with its BuildConfig.Profiles
+func (s *buildService) Catalog(ctx context.Context) (*CatalogResponse, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return newCatalog(nil, nil, nil, nil), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading build catalog: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil {
+		return newCatalog(nil, nil, nil, nil), nil
+	}
+	cfg := autoDev.Spec.BuildConfig
+	if cfg.Catalog == nil {
+		return newCatalog(nil, nil, nil, cfg.Profiles), nil
+	}
+	return newCatalog(cfg.Catalog.Distros, cfg.Catalog.Targets, cfg.Catalog.Architectures, cfg.Profiles), nil
+}
+
+// allowedAIBArgs returns the automotive-image-builder flags builds may pass, the AutomotiveDev's
+// BuildConfig.AllowedAIBArgs or else the built-in list
+func (s *buildService) allowedAIBArgs(ctx context.Context) ([]string, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return defaultAllowedAIBArgs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading allowed AIB args: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil || len(autoDev.Spec.BuildConfig.AllowedAIBArgs) == 0 {
+		return defaultAllowedAIBArgs, nil
+	}
+	return autoDev.Spec.BuildConfig.AllowedAIBArgs, nil
+}
+
+// checkBuildSystemReady refuses builds while the AutomotiveDev reports that its Tekton resources failed to
+// install. A missing AutomotiveDev, or one without conditions yet, does not block builds.
+func (s *buildService) checkBuildSystemReady(ctx context.Context) error {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading build system status: %w", err)
+	}
+	for _, t := range []string{automotivev1.AutomotiveDevTasksReady, automotivev1.AutomotiveDevPipelineReady} {
+		if c := meta.FindStatusCondition(autoDev.Status.Conditions, t); c != nil && c.Status == metav1.ConditionFalse {
+			return newError(ErrNotReady, "build system is not ready (%s): %s", t, c.Message)
+		}
+	}
+	return nil
+}
+
+// checkUnprivileged refuses builds the AutomotiveDev's BuildConfig.Unprivileged mode cannot run: those exporting a
+// format that needs loop devices, whether set by the request or by an --export in its AIB args
+func (s *buildService) checkUnprivileged(ctx context.Context, req *BuildRequest) error {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading build config: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil || !autoDev.Spec.BuildConfig.Unprivileged {
+		return nil
+	}
+	format := string(req.ExportFormat)
+	if len(req.AIBOverrideArgs) > 0 {
+		format = aibArgValue(req.AIBOverrideArgs, "--export", format)
+	} else {
+		format = aibArgValue(req.AIBExtraArgs, "--export", format)
+	}
+	if !slices.Contains(tasks.UnprivilegedExportFormats, format) {
+		return newError(ErrUnprocessable, "export format %s needs loop devices, which this cluster's unprivileged builds "+
+			"do not have (supported: %s)", format, strings.Join(tasks.UnprivilegedExportFormats, ", "))
+	}
+	return nil
+}
+
+// applyProfile merges the catalog profile req selects into it. Settings of the request win; the profile's
+// AIB args and defines are placed before the request's own so that later ones can refine them.
+func (s *buildService) applyProfile(ctx context.Context, req *BuildRequest) error {
+	catalog, err := s.Catalog(ctx)
+	if err != nil {
+		return err
+	}
+	profile, err := catalog.Profile(req.Profile)
+	if err != nil {
+		return newError(ErrInvalidInput, "%s", err.Error())
+	}
+	if req.Target == "" {
+		req.Target = profile.Target
+	}
+	if req.Architecture == "" {
+		req.Architecture = profile.Architecture
+	}
+	if req.ExportFormat == "" {
+		req.ExportFormat = profile.ExportFormat
+	}
+	req.AIBExtraArgs = append(slices.Clone(profile.AIBExtraArgs), req.AIBExtraArgs...)
+	req.CustomDefs = append(slices.Clone(profile.CustomDefs), req.CustomDefs...)
+	return nil
+}
@@ -0,0 +1,39 @@
+package buildapi
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// consoleURLFromEnv returns the base URL of the OpenShift web console that builds link to, from
+// $BUILD_API_CONSOLE_URL (e.g. "https://console-openshift-console.apps.example.com"), or "" for no links
+func consoleURLFromEnv() string {
+	return strings.TrimRight(strings.TrimSpace(os.Getenv("BUILD_API_CONSOLE_URL")), "/")
+}
+
+// setConsoleLinks points resp at the web console pages of the TaskRun, build pod and workspace of build.
+// Links are only set for resources the build has, and not at all without a console URL.
+func (s *buildService) setConsoleLinks(ctx context.Context, build *automotivev1.ImageBuild, resp *BuildResponse) {
+	if s.consoleURL == "" {
+		return
+	}
+	if tr := build.Status.TaskRunName; tr != "" {
+		resp.TaskRunURL = s.consoleLink(build.Namespace, "tekton.dev~v1~TaskRun", tr)
+		if pod, err := s.cluster.FindTaskRunPod(ctx, tr); err == nil && pod != nil {
+			resp.PodLogsURL = s.consoleLink(build.Namespace, "pods", pod.Name) + "/logs"
+		}
+	}
+	if pvc := build.Status.PVCName; pvc != "" {
+		resp.PVCURL = s.consoleLink(build.Namespace, "persistentvolumeclaims", pvc)
+	}
+}
+
+// consoleLink returns the console page of the named resource of kind, a plural resource name or a
+// group~version~Kind reference
+func (s *buildService) consoleLink(namespace, kind, name string) string {
+	return s.consoleURL + "/k8s/ns/" + url.PathEscape(namespace) + "/" + kind + "/" + url.PathEscape(name)
+}
@@ -0,0 +1,91 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// ConvertArtifact adds format to the conversions the build's spec requests; the controller runs them in the
+// build's workspace next to the artifact pod. Requesting a conversion again returns its progress.
+func (s *buildService) ConvertArtifact(ctx context.Context, name string, req ConvertRequest, requestedBy string) (*ArtifactConversion, error) {
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if !slices.Contains(automotivev1.ArtifactConversionFormats, format) {
+		return nil, newError(ErrInvalidInput, "unsupported format %q; use one of %s", req.Format,
+			strings.Join(automotivev1.ArtifactConversionFormats, ", "))
+	}
+
+	build, err := s.completedBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !build.Spec.ServeArtifact || build.Status.ArtifactFileName == "" {
+		return nil, newError(ErrConflict, "build %s does not serve its artifact", name)
+	}
+	if build.Spec.EncryptionKeySecretRef != "" {
+		return nil, newError(ErrConflict, "the artifact of build %s is encrypted and cannot be converted", name)
+	}
+	source := convertibleFormat(build.Status.ArtifactFileName)
+	switch source {
+	case "":
+		return nil, newError(ErrUnprocessable, "artifact %s is not a raw or qcow2 disk image", build.Status.ArtifactFileName)
+	case format:
+		return nil, newError(ErrInvalidInput, "artifact %s is already a %s image", build.Status.ArtifactFileName, format)
+	}
+
+	if i := slices.IndexFunc(build.Status.Conversions, func(c automotivev1.ArtifactConversion) bool {
+		return c.Format == format
+	}); i >= 0 {
+		conversion := toArtifactConversion(build.Status.Conversions[i])
+		return &conversion, nil
+	}
+	if !slices.Contains(build.Spec.Conversions, format) {
+		patched := build.DeepCopy()
+		patched.Spec.Conversions = append(patched.Spec.Conversions, format)
+		if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+			return nil, fmt.Errorf("error requesting conversion: %w", err)
+		}
+	}
+	return &ArtifactConversion{Format: format, Phase: automotivev1.ConversionPending}, nil
+}
+
+// convertibleFormat returns the disk format of an artifact the controller can convert, "raw" or "qcow2", or
+// "" for other artifacts
+func convertibleFormat(artifactFileName string) string {
+	image := strings.TrimSuffix(strings.TrimSuffix(artifactFileName, ".gz"), ".lz4")
+	for _, ext := range []string{"raw", "qcow2"} {
+		if stem, found := strings.CutSuffix(image, "."+ext); found && stem != "" {
+			return ext
+		}
+	}
+	return ""
+}
+
+// completedConversion reports whether fileName is the output of a completed conversion of the build
+func completedConversion(build *automotivev1.ImageBuild, fileName string) bool {
+	return slices.ContainsFunc(build.Status.Conversions, func(c automotivev1.ArtifactConversion) bool {
+		return c.Phase == automotivev1.ConversionCompleted && c.FileName != "" && c.FileName == fileName
+	})
+}
+
+func toArtifactConversion(c automotivev1.ArtifactConversion) ArtifactConversion {
+	conversion := ArtifactConversion{
+		Format:   c.Format,
+		Phase:    c.Phase,
+		FileName: c.FileName,
+		Size:     c.Size,
+		SHA256:   c.SHA256,
+		Message:  c.Message,
+	}
+	if c.StartTime != nil {
+		conversion.StartTime = c.StartTime.Time.Format(time.RFC3339)
+	}
+	if c.CompletionTime != nil {
+		conversion.CompletionTime = c.CompletionTime.Time.Format(time.RFC3339)
+	}
+	return conversion
+}
@@ -0,0 +1,75 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// encryptionKeyEntry is the entry of an encryption key secret holding the passphrase
+	encryptionKeyEntry = "key"
+	// minEncryptionKeyLen keeps clients from encrypting artifacts with guessable passphrases
+	minEncryptionKeyLen = 16
+	maxEncryptionKeyLen = 1024
+)
+
+// encryptionKeySecretName is the name of the secret holding the encryption key a build was submitted with
+func encryptionKeySecretName(buildName string) string {
+	return fmt.Sprintf("%s-encryption-key", buildName)
+}
+
+// validateEncryption checks the encryption settings of a build request. The build reads the passphrase from
+// the first line of the secret entry, so a key supplied by the client cannot span lines.
+func (s *buildService) validateEncryption(ctx context.Context, req BuildRequest) error {
+	if req.EncryptionKey != "" && req.EncryptionKeySecret != "" {
+		return newError(ErrInvalidInput, "encryptionKey and encryptionKeySecret cannot be combined")
+	}
+	if req.EncryptionKey != "" {
+		if len(req.EncryptionKey) < minEncryptionKeyLen || len(req.EncryptionKey) > maxEncryptionKeyLen ||
+			strings.ContainsFunc(req.EncryptionKey, unicode.IsControl) {
+			return newError(ErrInvalidInput, "invalid encryptionKey: must be %d to %d characters without control characters",
+				minEncryptionKeyLen, maxEncryptionKeyLen)
+		}
+	}
+	if req.EncryptionKeySecret != "" {
+		secret, err := s.cluster.GetSecret(ctx, req.EncryptionKeySecret)
+		if k8serrors.IsNotFound(err) {
+			return newError(ErrInvalidInput, "encryption key secret %s not found", req.EncryptionKeySecret)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading encryption key secret %s: %w", req.EncryptionKeySecret, err)
+		}
+		if len(secret.Data[encryptionKeyEntry]) == 0 {
+			return newError(ErrInvalidInput, "encryption key secret %s has no %s entry", req.EncryptionKeySecret, encryptionKeyEntry)
+		}
+	}
+	return nil
+}
+
+func (s *buildService) createEncryptionKeySecret(ctx context.Context, buildName, key string) (string, error) {
+	secretName := encryptionKeySecretName(buildName)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: secretName,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":                  "build-api",
+				"app.kubernetes.io/part-of":                     "automotive-dev",
+				"app.kubernetes.io/created-by":                  "automotive-dev-build-api",
+				"automotive.sdv.cloud.redhat.com/resource-type": "encryption-key",
+				"automotive.sdv.cloud.redhat.com/build-name":    buildName,
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{encryptionKeyEntry: []byte(key)},
+	}
+	if err := s.cluster.CreateSecret(ctx, secret); err != nil {
+		return "", fmt.Errorf("failed to create encryption key secret: %w", err)
+	}
+	return secretName, nil
+}
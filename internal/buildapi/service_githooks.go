@@ -0,0 +1,262 @@
+package buildapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
+)
+
+// gitCommitDefine is the define carrying the pushed commit into triggered builds
+const gitCommitDefine = "GIT_COMMIT"
+
+// GitHook is a delivery of a Git provider's webhook
+type GitHook struct {
+	Provider gitstatus.Provider
+	// Event is the event the provider sent, e.g. "push" for GitHub or "Tag Push Hook" for GitLab
+	Event string
+	// Delivery identifies the event; redeliveries of an event share it
+	Delivery string
+	// Signature is GitHub's X-Hub-Signature-256 header
+	Signature string
+	// Token is GitLab's X-Gitlab-Token header
+	Token   string
+	Payload []byte
+}
+
+// gitPush is the part of a push event triggers act on
+type gitPush struct {
+	repository string
+	ref        string
+	// sha is the pushed commit, "" when the push deleted the ref
+	sha    string
+	pusher string
+}
+
+func (s *buildService) TriggerGitBuilds(ctx context.Context, hook GitHook) (*GitHookResponse, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error reading AutomotiveDev: %w", err)
+	}
+	if err != nil || autoDev.Spec.GitHooks == nil {
+		return nil, newError(ErrNotFound, "Git hooks are not configured")
+	}
+	hooks := autoDev.Spec.GitHooks
+	if err := s.verifyGitHook(ctx, hooks.SecretRef, hook); err != nil {
+		return nil, err
+	}
+
+	push, err := parseGitPush(hook)
+	if err != nil {
+		return nil, err
+	}
+	resp := &GitHookResponse{Builds: []GitHookBuild{}}
+	if push == nil {
+		resp.Message = fmt.Sprintf("ignored %s event", hook.Event)
+		return resp, nil
+	}
+	resp.Repository, resp.Ref, resp.Commit = push.repository, push.ref, push.sha
+	if push.sha == "" {
+		resp.Message = fmt.Sprintf("ignored deletion of %s", push.ref)
+		return resp, nil
+	}
+
+	for _, trigger := range hooks.Triggers {
+		if !gitstatus.SameRepository(trigger.Repository, push.repository) || !matchesGitRef(trigger, push.ref) {
+			continue
+		}
+		build, err := s.triggerGitBuild(ctx, hook, push, trigger)
+		if err != nil {
+			return nil, err
+		}
+		resp.Builds = append(resp.Builds, *build)
+	}
+	if len(resp.Builds) == 0 {
+		resp.Message = fmt.Sprintf("no trigger matches %s of %s", push.ref, push.repository)
+	}
+	return resp, nil
+}
+
+// verifyGitHook authenticates a delivery with the secret the AutomotiveDev's Git hooks name
+func (s *buildService) verifyGitHook(ctx context.Context, secretName string, hook GitHook) error {
+	secret, err := s.cluster.GetSecret(ctx, secretName)
+	if err != nil {
+		return fmt.Errorf("error reading Git hook secret %s: %w", secretName, err)
+	}
+	// secrets created from files often end with a newline the provider does not know about
+	key := bytes.TrimSpace(secret.Data["secret"])
+	if len(key) == 0 {
+		return fmt.Errorf("git hook secret %s has no secret key", secretName)
+	}
+
+	switch hook.Provider {
+	case gitstatus.GitHub:
+		sig, err := hex.DecodeString(strings.TrimPrefix(hook.Signature, "sha256="))
+		if err != nil || !strings.HasPrefix(hook.Signature, "sha256=") {
+			return newError(ErrForbidden, "missing or malformed X-Hub-Signature-256 header")
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(hook.Payload)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return newError(ErrForbidden, "invalid webhook signature")
+		}
+	case gitstatus.GitLab:
+		if subtle.ConstantTimeCompare([]byte(hook.Token), key) != 1 {
+			return newError(ErrForbidden, "invalid webhook token")
+		}
+	default:
+		return newError(ErrInvalidInput, "unsupported Git provider %q", hook.Provider)
+	}
+	return nil
+}
+
+// parseGitPush reads the push of a delivery, or returns nil for events that are not pushes
+func parseGitPush(hook GitHook) (*gitPush, error) {
+	switch {
+	case hook.Provider == gitstatus.GitHub && hook.Event == "push":
+		var event struct {
+			Ref        string `json:"ref"`
+			After      string `json:"after"`
+			Deleted    bool   `json:"deleted"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			Pusher struct {
+				Name string `json:"name"`
+			} `json:"pusher"`
+		}
+		if err := json.Unmarshal(hook.Payload, &event); err != nil {
+			return nil, newError(ErrInvalidInput, "invalid push event: %v", err)
+		}
+		push := &gitPush{repository: event.Repository.FullName, ref: event.Ref, sha: event.After, pusher: event.Pusher.Name}
+		if event.Deleted {
+			push.sha = ""
+		}
+		return push, validateGitPush(push)
+	case hook.Provider == gitstatus.GitLab && (hook.Event == "Push Hook" || hook.Event == "Tag Push Hook"):
+		var event struct {
+			Ref          string `json:"ref"`
+			CheckoutSHA  string `json:"checkout_sha"`
+			UserUsername string `json:"user_username"`
+			Project      struct {
+				PathWithNamespace string `json:"path_with_namespace"`
+			} `json:"project"`
+		}
+		if err := json.Unmarshal(hook.Payload, &event); err != nil {
+			return nil, newError(ErrInvalidInput, "invalid push event: %v", err)
+		}
+		// checkout_sha is null when the push deleted the ref
+		push := &gitPush{repository: event.Project.PathWithNamespace, ref: event.Ref, sha: event.CheckoutSHA, pusher: event.UserUsername}
+		return push, validateGitPush(push)
+	default:
+		return nil, nil
+	}
+}
+
+func validateGitPush(push *gitPush) error {
+	if push.repository == "" || push.ref == "" {
+		return newError(ErrInvalidInput, "push event without repository or ref")
+	}
+	if push.sha != "" && !gitstatus.IsSHA(push.sha) {
+		return newError(ErrInvalidInput, "invalid commit %q in push event", push.sha)
+	}
+	return nil
+}
+
+// matchesGitRef reports whether ref is a branch or tag the trigger starts builds for
+func matchesGitRef(trigger automotivev1.GitTrigger, ref string) bool {
+	var patterns []string
+	name, isBranch := strings.CutPrefix(ref, "refs/heads/")
+	if isBranch {
+		patterns = trigger.Branches
+	} else if tag, isTag := strings.CutPrefix(ref, "refs/tags/"); isTag {
+		name, patterns = tag, trigger.Tags
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerGitBuild starts a build of the pushed commit from the trigger's template build. Redeliveries of
+// the event return the build the first delivery started.
+func (s *buildService) triggerGitBuild(ctx context.Context, hook GitHook, push *gitPush, trigger automotivev1.GitTrigger) (*GitHookBuild, error) {
+	ns := trigger.Namespace
+	if ns == "" {
+		ns = s.cluster.Namespace()
+	}
+	if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+		return nil, newError(ErrUnprocessable, "invalid namespace %q of the trigger of %s: %s", ns, trigger.TemplateBuild, strings.Join(errs, "; "))
+	}
+	ctx = k8s.WithNamespace(ctx, ns)
+
+	template, err := s.getBuild(ctx, trigger.TemplateBuild)
+	if err != nil {
+		return nil, fmt.Errorf("template build %s/%s: %w", ns, trigger.TemplateBuild, err)
+	}
+	if template.Spec.InputFilesServer {
+		return nil, newError(ErrUnprocessable, "template build %s/%s uploads local files and cannot be triggered", ns, template.Name)
+	}
+	tmpl, err := s.GetBuildTemplate(ctx, template.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	req := tmpl.BuildRequest
+	req.Name = ""
+	req.GenerateName = gitBuildNamePrefix(template.Name, push.sha)
+	req.CustomDefs = append(withoutDefine(req.CustomDefs, gitCommitDefine), gitCommitDefine+"="+push.sha)
+	if req.BuildInfo {
+		req.GitRef = push.sha
+	}
+	req.GitCommit = &gitstatus.Commit{Provider: hook.Provider, Repository: push.repository, SHA: push.sha}
+	if hook.Delivery != "" {
+		req.IdempotencyKey = fmt.Sprintf("git:%s:%s", hook.Delivery, template.Name)
+	}
+	requestedBy := "git"
+	if push.pusher != "" {
+		requestedBy = "git:" + push.pusher
+	}
+
+	resp, err := s.CreateBuild(ctx, req, requestedBy)
+	if err != nil {
+		return nil, fmt.Errorf("build of template %s/%s: %w", ns, template.Name, err)
+	}
+	return &GitHookBuild{Name: resp.Name, Namespace: ns, TemplateBuild: template.Name}, nil
+}
+
+// gitBuildNamePrefix names the builds of a template after it and the short commit, leaving room for the
+// generated suffix
+func gitBuildNamePrefix(template, sha string) string {
+	const maxTemplate = validation.DNS1123LabelMaxLength - len("-1234567-") - generatedNameSuffixLen
+	if len(template) > maxTemplate {
+		template = strings.TrimRight(template[:maxTemplate], "-")
+	}
+	return fmt.Sprintf("%s-%s-", template, sha[:7])
+}
+
+// withoutDefine drops the definitions of name from defs
+func withoutDefine(defs []string, name string) []string {
+	var kept []string
+	for _, d := range defs {
+		if k, _, _ := strings.Cut(d, "="); k != name {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,106 @@
+package buildapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// idempotencyKeyLabel marks the build created for an Idempotency-Key. Label values are limited to 63
+	// characters and keys are not, so it carries a prefix of the hex SHA-256 of the requester and key.
+	idempotencyKeyLabel = "automotive.sdv.cloud.redhat.com/idempotency-key"
+
+	maxIdempotencyKeyLen = 255
+
+	// generated names end in generatedNameSuffixLen characters from the alphabet Kubernetes uses for
+	// generateName, which avoids vowels and lookalike digits
+	generatedNameAlphabet  = "bcdfghjklmnpqrstvwxz2456789"
+	generatedNameSuffixLen = 5
+	generatedNameAttempts  = 5
+)
+
+// idempotencyKeyHash scopes key to the requester, so different users cannot see each other's builds by
+// guessing keys
+func idempotencyKeyHash(key, requestedBy string) string {
+	sum := sha256.Sum256([]byte(requestedBy + "\n" + key))
+	return hex.EncodeToString(sum[:])[:contentHashLen]
+}
+
+func validateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLen || strings.ContainsFunc(key, unicode.IsControl) {
+		return newError(ErrInvalidInput, "invalid %s header: must be at most %d characters without control characters",
+			IdempotencyKeyHeader, maxIdempotencyKeyLen)
+	}
+	return nil
+}
+
+// replayIdempotentBuild returns the build created by an earlier request of requestedBy with the same
+// Idempotency-Key, or nil if there was none
+func (s *buildService) replayIdempotentBuild(ctx context.Context, req BuildRequest, requestedBy string) (*BuildResponse, error) {
+	builds, err := s.cluster.ListImageBuilds(ctx, map[string]string{
+		idempotencyKeyLabel: idempotencyKeyHash(req.IdempotencyKey, requestedBy),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	if len(builds) == 0 {
+		return nil, nil
+	}
+	// concurrent retries racing the cache may each have created a build; the oldest one answers them all
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].CreationTimestamp.Before(&builds[j].CreationTimestamp)
+	})
+	original := builds[0].Name
+	if req.Name != "" && req.Name != original {
+		return nil, newError(ErrConflict, "%s was already used for build %s", IdempotencyKeyHeader, original)
+	}
+	resp, err := s.GetBuild(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+	resp.Replayed = true
+	return resp, nil
+}
+
+// generateBuildName returns prefix followed by a random suffix that no ImageBuild uses yet
+func (s *buildService) generateBuildName(ctx context.Context, prefix string) (string, error) {
+	for range generatedNameAttempts {
+		suffix, err := randomNameSuffix()
+		if err != nil {
+			return "", err
+		}
+		name := prefix + suffix
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return "", newError(ErrInvalidInput, "invalid generateName %q: %s", prefix, strings.Join(errs, "; "))
+		}
+		if _, err := s.cluster.GetImageBuild(ctx, name); k8serrors.IsNotFound(err) {
+			return name, nil
+		} else if err != nil {
+			return "", fmt.Errorf("error checking existing build: %w", err)
+		}
+	}
+	return "", newError(ErrConflict, "could not generate an unused build name from %q", prefix)
+}
+
+func randomNameSuffix() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(generatedNameAlphabet)))
+	for range generatedNameSuffixLen {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(generatedNameAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,177 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+// ImageFilter selects the Images ListImages returns; empty fields match every Image
+type ImageFilter struct {
+	Distro       string
+	Architecture string
+	// Tag must be one of the Image's tags
+	Tag string
+}
+
+func (f ImageFilter) matches(image *automotivev1.Image) bool {
+	return (f.Distro == "" || image.Spec.Distro == f.Distro) &&
+		(f.Architecture == "" || image.Spec.Architecture == f.Architecture) &&
+		(f.Tag == "" || slices.Contains(image.Spec.Tags, f.Tag))
+}
+
+func (s *buildService) ListImages(ctx context.Context, filter ImageFilter) ([]ImageResponse, error) {
+	images, err := s.cluster.ListImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %w", err)
+	}
+	resp := []ImageResponse{}
+	for i := range images {
+		if filter.matches(&images[i]) {
+			resp = append(resp, imageResponse(&images[i]))
+		}
+	}
+	slices.SortFunc(resp, func(a, b ImageResponse) int { return strings.Compare(a.Name, b.Name) })
+	return resp, nil
+}
+
+func (s *buildService) GetImage(ctx context.Context, name string) (*ImageResponse, error) {
+	image, err := s.cluster.GetImage(ctx, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "not found")
+		}
+		return nil, fmt.Errorf("error fetching image: %w", err)
+	}
+	resp := imageResponse(image)
+	return &resp, nil
+}
+
+// RegisterImage catalogs an image that was built elsewhere and pushed to a registry; nothing is pulled or pushed
+func (s *buildService) RegisterImage(ctx context.Context, req RegisterImageRequest, requestedBy string) (*ImageResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, newError(ErrInvalidInput, "invalid name %q: %s", name, strings.Join(errs, "; "))
+	}
+	for _, field := range [][2]string{
+		{"distro", req.Distro}, {"target", req.Target}, {"architecture", req.Architecture}, {"exportFormat", req.ExportFormat},
+	} {
+		if strings.TrimSpace(field[1]) == "" {
+			return nil, newError(ErrInvalidInput, "%s is required", field[0])
+		}
+	}
+	ref, err := registry.ParseReference(strings.TrimSpace(req.URL))
+	if err != nil {
+		return nil, newError(ErrInvalidInput, "invalid url: %v", err)
+	}
+	if req.SizeBytes < 0 {
+		return nil, newError(ErrInvalidInput, "sizeBytes must not be negative")
+	}
+	var tags []string
+	for _, t := range req.Tags {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+
+	image := &automotivev1.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "build-api",
+				"app.kubernetes.io/part-of":    "automotive-dev",
+			},
+		},
+		Spec: automotivev1.ImageSpec{
+			Distro:       strings.TrimSpace(req.Distro),
+			Target:       strings.TrimSpace(req.Target),
+			Architecture: strings.TrimSpace(req.Architecture),
+			ExportFormat: strings.TrimSpace(req.ExportFormat),
+			Mode:         strings.TrimSpace(req.Mode),
+			Version:      strings.TrimSpace(req.Version),
+			Tags:         tags,
+			Description:  strings.TrimSpace(req.Description),
+			Lifecycle:    automotivev1.ImageLifecycleCandidate,
+			Location: automotivev1.ImageLocation{
+				Type: "registry",
+				Registry: &automotivev1.RegistryLocation{
+					URL:       ref.String(),
+					Digest:    ref.Digest,
+					SecretRef: strings.TrimSpace(req.SecretRef),
+				},
+			},
+			Metadata: &automotivev1.ImageMetadata{CreatedBy: requestedBy},
+		},
+	}
+	if req.SizeBytes > 0 {
+		size := req.SizeBytes
+		image.Spec.Size = &automotivev1.ImageSize{CompressedBytes: &size}
+	}
+	if err := s.cluster.CreateImage(ctx, image); err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			return nil, newError(ErrConflict, "image %s already exists", name)
+		}
+		return nil, fmt.Errorf("error creating image: %w", err)
+	}
+	resp := imageResponse(image)
+	return &resp, nil
+}
+
+// DeleteImage removes an Image from the catalog and returns it; the image itself stays in its registry
+func (s *buildService) DeleteImage(ctx context.Context, name string) (*ImageResponse, error) {
+	resp, err := s.GetImage(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cluster.DeleteImage(ctx, name); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotFound, "not found")
+		}
+		return nil, fmt.Errorf("error deleting image: %w", err)
+	}
+	return resp, nil
+}
+
+func imageResponse(image *automotivev1.Image) ImageResponse {
+	resp := ImageResponse{
+		Name:         image.Name,
+		Distro:       image.Spec.Distro,
+		Target:       image.Spec.Target,
+		Architecture: image.Spec.Architecture,
+		ExportFormat: image.Spec.ExportFormat,
+		Mode:         image.Spec.Mode,
+		Version:      image.Spec.Version,
+		Tags:         image.Spec.Tags,
+		Description:  image.Spec.Description,
+		Lifecycle:    string(image.Spec.Lifecycle),
+		Phase:        image.Status.Phase,
+	}
+	if resp.Lifecycle == "" {
+		resp.Lifecycle = string(automotivev1.ImageLifecycleCandidate)
+	}
+	if r := image.Spec.Location.Registry; r != nil {
+		resp.URL, resp.Digest = r.URL, r.Digest
+	}
+	if sz := image.Spec.Size; sz != nil && sz.CompressedBytes != nil {
+		resp.SizeBytes = *sz.CompressedBytes
+	}
+	if m := image.Spec.Metadata; m != nil {
+		resp.SourceBuild, resp.CreatedBy = m.SourceImageBuild, m.CreatedBy
+		if m.BuildDate != nil {
+			resp.CreatedAt = m.BuildDate.UTC().Format(time.RFC3339)
+		}
+	}
+	if resp.CreatedAt == "" && !image.CreationTimestamp.IsZero() {
+		resp.CreatedAt = image.CreationTimestamp.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
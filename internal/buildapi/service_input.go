@@ -0,0 +1,78 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+// InputMountPath is where builds find the files of their InputPVC
+const InputMountPath = tasks.InputMountPath
+
+// validateInputPVC checks that the input claim of a build request exists and may be mounted by the build
+func (s *buildService) validateInputPVC(ctx context.Context, input *InputPVC) error {
+	if input == nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(input.ClaimName); len(errs) > 0 {
+		return newError(ErrInvalidInput, "invalid inputPVC claimName %q: %s", input.ClaimName, strings.Join(errs, "; "))
+	}
+	if p := input.SubPath; p != "" && (strings.HasPrefix(p, "/") || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../")) {
+		return newError(ErrInvalidInput, "invalid inputPVC subPath %q: must be a relative path within the claim", p)
+	}
+	pvc, err := s.cluster.GetPersistentVolumeClaim(ctx, input.ClaimName)
+	if k8serrors.IsNotFound(err) {
+		return newError(ErrInvalidInput, "input claim %s not found", input.ClaimName)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading input claim %s: %w", input.ClaimName, err)
+	}
+	if build, ok := pvc.Labels[storage.ImageBuildNameLabel]; ok {
+		return newError(ErrInvalidInput, "input claim %s is the workspace of build %s", input.ClaimName, build)
+	}
+	if pvc.Status.Phase == corev1.ClaimLost {
+		return newError(ErrUnprocessable, "input claim %s lost its volume", input.ClaimName)
+	}
+	return nil
+}
+
+// inputPVCSpec is the ImageBuild spec of the input claim of a build request
+func inputPVCSpec(input *InputPVC) *automotivev1.InputPVC {
+	if input == nil {
+		return nil
+	}
+	return &automotivev1.InputPVC{ClaimName: input.ClaimName, SubPath: input.SubPath, ReadOnly: input.ReadOnly}
+}
+
+// inputPVCRequest is the build request form of the input claim of an ImageBuild
+func inputPVCRequest(input *automotivev1.InputPVC) *InputPVC {
+	if input == nil {
+		return nil
+	}
+	return &InputPVC{ClaimName: input.ClaimName, SubPath: input.SubPath, ReadOnly: input.ReadOnly}
+}
+
+// referencesUploads reports whether a manifest references files the client uploads: any source_path but those
+// of the build's input claim, which are in the cluster already
+func referencesUploads(manifest string) bool {
+	for _, line := range strings.Split(manifest, "\n") {
+		if !strings.Contains(line, "source_path") {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "- "), ":")
+		value = strings.Trim(strings.TrimSpace(value), `'"`)
+		if strings.TrimSpace(key) != "source_path" || !strings.HasPrefix(value, InputMountPath+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+)
+
+// LintManifests checks manifests against the lint rules of the AutomotiveDev. Without rules nothing is reported.
+func (s *buildService) LintManifests(ctx context.Context, req LintRequest) (*LintResponse, error) {
+	if req.Manifest == "" {
+		return nil, newError(ErrInvalidInput, "manifest is required")
+	}
+	if req.ManifestFileName == "" {
+		req.ManifestFileName = "manifest.aib.yml"
+	}
+	if err := validateManifestFiles(req.ManifestFileName, req.AdditionalManifests); err != nil {
+		return nil, err
+	}
+	rules, err := s.lintRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rules.Lint(req)
+	if err != nil {
+		return nil, newError(ErrInvalidInput, "%s", err.Error())
+	}
+	return resp, nil
+}
+
+// lintRules reads the rules from the ConfigMap the AutomotiveDev names, or nil if it names none
+func (s *buildService) lintRules(ctx context.Context) (*LintRules, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading lint configuration: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil || autoDev.Spec.BuildConfig.LintRulesConfigMap == "" {
+		return nil, nil
+	}
+	name := autoDev.Spec.BuildConfig.LintRulesConfigMap
+
+	// the rules live next to the AutomotiveDev, not in the namespace of the request
+	cm, err := s.cluster.GetConfigMap(k8s.WithNamespace(ctx, s.cluster.Namespace()), name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrNotReady, "lint rules ConfigMap %s not found", name)
+		}
+		return nil, fmt.Errorf("error reading lint rules: %w", err)
+	}
+	rules, err := parseLintRules(cm.Data[lintRulesKey])
+	if err != nil {
+		return nil, newError(ErrNotReady, "lint rules ConfigMap %s: %s", name, err.Error())
+	}
+	return rules, nil
+}
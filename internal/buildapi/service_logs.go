@@ -0,0 +1,306 @@
+package buildapi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LogOptions selects the part of a build's logs FollowLogs streams. The zero value streams everything.
+type LogOptions struct {
+	// Step is the step to start from; earlier steps are skipped
+	Step string
+	// SinceBytes skips that many bytes of the first streamed step, so an interrupted stream can resume
+	SinceBytes int64
+	// SinceTime limits every step to log lines written at or after it
+	SinceTime *metav1.Time
+	// TailLines limits every step to its last lines
+	TailLines *int64
+}
+
+// LogSink receives build log output from FollowLogs. Implementations render it for a transport
+// (plain text or server-sent events) and must flush as they go.
+type LogSink interface {
+	// StepStarted is called before the first chunk of a step's logs
+	StepStarted(step string)
+	// Write receives a chunk of a step's logs; returning an error stops streaming that step
+	Write(step string, p []byte) error
+	// StreamError reports that a step's log stream broke before EOF
+	StreamError(step string, err error)
+	// Waiting is called on every poll while no step has produced logs yet
+	Waiting()
+}
+
+func (s *buildService) LogPod(ctx context.Context, name string) (string, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	tr := strings.TrimSpace(build.Status.TaskRunName)
+	if tr == "" {
+		return "", newError(ErrNotReady, "logs not available yet")
+	}
+	pod, err := s.cluster.FindTaskRunPod(ctx, tr)
+	if err != nil || pod == nil {
+		return "", newError(ErrNotReady, "logs not available yet")
+	}
+	return pod.Name, nil
+}
+
+func (s *buildService) FollowLogs(ctx context.Context, podName string, opts LogOptions, sink LogSink) error {
+	var hadStream bool
+	streamed := make(map[string]bool)
+	var lastErrs []string
+	var first string
+	skip := opts.SinceBytes
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		pod, err := s.cluster.GetPod(ctx, podName)
+		if err != nil {
+			return err
+		}
+
+		stepNames := stepContainers(pod)
+		if first == "" {
+			if first, err = startContainer(stepNames, opts.Step); err != nil {
+				return err
+			}
+			// steps before the requested one count as already streamed
+			for _, cName := range stepNames {
+				if cName == first {
+					break
+				}
+				streamed[cName] = true
+			}
+		}
+
+		var errs []string
+		for _, cName := range stepNames {
+			if streamed[cName] {
+				continue
+			}
+
+			logOpts := &corev1.PodLogOptions{
+				Container: cName,
+				Follow:    true,
+				SinceTime: opts.SinceTime,
+				TailLines: opts.TailLines,
+			}
+			stream, err := s.cluster.StreamContainerLogs(ctx, podName, logOpts)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", cName, err))
+				continue
+			}
+			hadStream = true
+
+			step := strings.TrimPrefix(cName, "step-")
+			sink.StepStarted(step)
+			var stepSkip int64
+			if cName == first {
+				stepSkip = skip
+			}
+			s.followStep(ctx, podName, logOpts, stream, step, stepSkip, sink)
+
+			streamed[cName] = true
+		}
+
+		if len(errs) > 0 {
+			lastErrs = errs
+		}
+
+		if len(streamed) == len(stepNames) {
+			break
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			break
+		}
+
+		time.Sleep(2 * time.Second)
+		if !hadStream {
+			sink.Waiting()
+		}
+	}
+
+	if !hadStream {
+		return newError(ErrNotReady, "logs unavailable: %s", strings.Join(lastErrs, "; "))
+	}
+	return nil
+}
+
+// OpenLogsArchive returns a tar.gz archive holding a "<step>.log" file per step of a finished build, read
+// from its TaskRun pod. Logs are only available while Tekton keeps that pod.
+func (s *buildService) OpenLogsArchive(ctx context.Context, name string) (*Artifact, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.Phase != "Completed" && build.Status.Phase != "Failed" {
+		return nil, newError(ErrConflict, "logs archive not available until build finishes")
+	}
+	tr := strings.TrimSpace(build.Status.TaskRunName)
+	if tr == "" {
+		return nil, newError(ErrNotFound, "build %s has no logs; it never started", name)
+	}
+	pod, err := s.cluster.FindTaskRunPod(ctx, tr)
+	if err != nil {
+		return nil, fmt.Errorf("find build pod: %w", err)
+	}
+	if pod == nil {
+		return nil, newError(ErrNotFound, "logs of build %s are no longer available; its pod was removed", name)
+	}
+
+	return &Artifact{
+		FileName: name + "-logs.tar.gz",
+		stream: func(ctx context.Context, w io.Writer) error {
+			return s.writeLogsArchive(ctx, pod, w)
+		},
+	}, nil
+}
+
+// writeLogsArchive writes the logs of pod's step containers to w as a tar.gz archive. A step whose logs
+// cannot be read gets the error in its file instead, so the remaining steps are still archived.
+func (s *buildService) writeLogsArchive(ctx context.Context, pod *corev1.Pod, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	modTime := time.Now()
+	for _, cName := range stepContainers(pod) {
+		// tar headers need the size up front, so each step is read completely first
+		var buf bytes.Buffer
+		stream, err := s.cluster.StreamContainerLogs(ctx, pod.Name, &corev1.PodLogOptions{Container: cName})
+		if err == nil {
+			_, err = io.Copy(&buf, stream)
+			stream.Close()
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(&buf, "\n[logs unavailable: %v]\n", err)
+		}
+
+		hdr := &tar.Header{
+			Name:    strings.TrimPrefix(cName, "step-") + ".log",
+			Mode:    0o644,
+			Size:    int64(buf.Len()),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// stepContainers returns the Tekton step containers of a pod, or all containers if there are none
+func stepContainers(pod *corev1.Pod) []string {
+	names := make([]string, 0, len(pod.Spec.Containers))
+	for _, c := range pod.Spec.Containers {
+		if strings.HasPrefix(c.Name, "step-") {
+			names = append(names, c.Name)
+		}
+	}
+	if len(names) == 0 {
+		for _, c := range pod.Spec.Containers {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// startContainer returns the container of the step logs start from: step, or the first one if step is empty
+func startContainer(stepNames []string, step string) (string, error) {
+	if len(stepNames) == 0 {
+		return "", newError(ErrNotReady, "logs unavailable: pod has no containers")
+	}
+	if step == "" {
+		return stepNames[0], nil
+	}
+	for _, cName := range stepNames {
+		if cName == step || cName == "step-"+step {
+			return cName, nil
+		}
+	}
+	return "", newError(ErrNotFound, "step %q not found", step)
+}
+
+// logStreamReopens is how often in a row a step's broken log stream is reopened without it delivering anything new
+const logStreamReopens = 3
+
+// followStep forwards the logs of a step to sink, discarding their first skip bytes. When the stream breaks
+// before the step ends, e.g. because a proxy or the API server dropped it, it is reopened and resumes after
+// the bytes already read; streams of the last lines of a step cannot be resumed, as those lines move on.
+func (s *buildService) followStep(ctx context.Context, podName string, logOpts *corev1.PodLogOptions,
+	stream io.ReadCloser, step string, skip int64, sink LogSink) {
+	for attempts := 0; ; {
+		read, err := copyLogStream(ctx, stream, step, skip, sink)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		if read > skip {
+			skip, attempts = read, 0
+		}
+		if attempts++; attempts > logStreamReopens || logOpts.TailLines != nil {
+			sink.StreamError(step, err)
+			return
+		}
+		if stream, err = s.cluster.StreamContainerLogs(ctx, podName, logOpts); err != nil {
+			sink.StreamError(step, err)
+			return
+		}
+	}
+}
+
+// copyLogStream forwards stream to sink, discarding its first skip bytes. It returns how many bytes it read,
+// the discarded ones included, and the error that broke the stream, if any.
+func copyLogStream(ctx context.Context, stream io.ReadCloser, step string, skip int64, sink LogSink) (int64, error) {
+	defer stream.Close()
+
+	var read int64
+	buf := make([]byte, 4096)
+	for {
+		select {
+		case <-ctx.Done():
+			return read, nil
+		default:
+		}
+
+		n, err := stream.Read(buf)
+		chunk := buf[:n]
+		if skip > read {
+			d := min(skip-read, int64(len(chunk)))
+			chunk = chunk[d:]
+		}
+		read += int64(n)
+		if len(chunk) > 0 {
+			if writeErr := sink.Write(step, chunk); writeErr != nil {
+				return read, nil
+			}
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				return read, err
+			}
+			return read, nil
+		}
+	}
+}
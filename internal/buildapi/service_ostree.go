@@ -0,0 +1,61 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// The forms the ImageBuild CRD accepts for the ostree repository and ref
+var (
+	ostreeRepositoryPattern = regexp.MustCompile(`^[^\s:/]+:([^\s/][^\s]*|/[^\s/][^\s]*)$`)
+	ostreeRefPattern        = regexp.MustCompile(`^[A-Za-z0-9_][-._A-Za-z0-9]*(/[A-Za-z0-9_][-._A-Za-z0-9]*)*$`)
+)
+
+// validateOSTree checks the ostree settings of a build request
+func (s *buildService) validateOSTree(ctx context.Context, req BuildRequest) error {
+	ostree := req.OSTree
+	if ostree == nil {
+		return nil
+	}
+	if !ostreeRepositoryPattern.MatchString(ostree.RepositoryURL) {
+		return newError(ErrInvalidInput, "invalid ostree repositoryUrl %q: must be an rsync destination such as user@host:/srv/ostree/repo", ostree.RepositoryURL)
+	}
+	if !ostreeRefPattern.MatchString(ostree.Ref) {
+		return newError(ErrInvalidInput, "invalid ostree ref %q", ostree.Ref)
+	}
+	if ostree.SecretRef != "" {
+		secret, err := s.cluster.GetSecret(ctx, ostree.SecretRef)
+		if k8serrors.IsNotFound(err) {
+			return newError(ErrInvalidInput, "ostree secret %s not found", ostree.SecretRef)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading ostree secret %s: %w", ostree.SecretRef, err)
+		}
+		if len(secret.Data[corev1.SSHAuthPrivateKey]) == 0 {
+			return newError(ErrInvalidInput, "ostree secret %s has no %s entry", ostree.SecretRef, corev1.SSHAuthPrivateKey)
+		}
+	}
+	return nil
+}
+
+// ostreeSpec is the ImageBuild spec of the ostree settings of a build request
+func ostreeSpec(ostree *OSTreeUpdate) *automotivev1.OSTreeUpdate {
+	if ostree == nil {
+		return nil
+	}
+	return &automotivev1.OSTreeUpdate{RepositoryURL: ostree.RepositoryURL, Ref: ostree.Ref, SecretRef: ostree.SecretRef}
+}
+
+// ostreeRequest is the build request form of the ostree settings of an ImageBuild
+func ostreeRequest(ostree *automotivev1.OSTreeUpdate) *OSTreeUpdate {
+	if ostree == nil {
+		return nil
+	}
+	return &OSTreeUpdate{RepositoryURL: ostree.RepositoryURL, Ref: ostree.Ref, SecretRef: ostree.SecretRef}
+}
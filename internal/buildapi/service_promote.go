@@ -0,0 +1,210 @@
+package buildapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/oci"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+// Provenance annotations of promoted Images
+const (
+	promotedFromNamespaceAnnotation = "automotive.sdv.cloud.redhat.com/promoted-from-namespace"
+	promotedFromBuildAnnotation     = "automotive.sdv.cloud.redhat.com/promoted-from-build"
+	promotedByAnnotation            = "automotive.sdv.cloud.redhat.com/promoted-by"
+	promotedAtAnnotation            = "automotive.sdv.cloud.redhat.com/promoted-at"
+	artifactSHA256Annotation        = "automotive.sdv.cloud.redhat.com/artifact-sha256"
+)
+
+// PromoteBuild pushes the artifact of a completed build to a registry and creates an Image for it in the
+// target namespace, annotated with where it was promoted from. The caller must have checked that the
+// requester may create Images there.
+func (s *buildService) PromoteBuild(ctx context.Context, name string, req PromoteRequest, requestedBy string) (*PromoteResponse, error) {
+	sourceNamespace := k8s.NamespaceFrom(ctx)
+	if sourceNamespace == "" {
+		sourceNamespace = s.cluster.Namespace()
+	}
+	target := strings.TrimSpace(req.TargetNamespace)
+	if errs := validation.IsDNS1123Label(target); len(errs) > 0 {
+		return nil, newError(ErrInvalidInput, "invalid targetNamespace %q: %s", target, strings.Join(errs, "; "))
+	}
+	if target == sourceNamespace {
+		return nil, newError(ErrInvalidInput, "targetNamespace must differ from the build's namespace")
+	}
+	ref, err := registry.ParseReference(req.Registry.URL)
+	if err != nil {
+		return nil, newError(ErrInvalidInput, "invalid registry url: %v", err)
+	}
+	if ref.Digest != "" {
+		return nil, newError(ErrInvalidInput, "registry url must name a tag, not a digest")
+	}
+	imageName := strings.TrimSpace(req.ImageName)
+	if imageName == "" {
+		imageName = name
+	}
+	if errs := validation.IsDNS1123Subdomain(imageName); len(errs) > 0 {
+		return nil, newError(ErrInvalidInput, "invalid imageName %q: %s", imageName, strings.Join(errs, "; "))
+	}
+
+	build, err := s.completedBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Status.ArtifactSHA256 == "" || build.Status.ArtifactSize <= 0 {
+		return nil, newError(ErrConflict, "build %s did not record the checksum of its artifact; rebuild it to promote it", name)
+	}
+
+	targetCtx := k8s.WithNamespace(ctx, target)
+	if _, err := s.cluster.GetImage(targetCtx, imageName); err == nil {
+		return nil, newError(ErrConflict, "image %s already exists in namespace %s", imageName, target)
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("error checking image %s: %w", imageName, err)
+	}
+
+	rc := &registry.Client{Host: ref.APIHost(), Insecure: req.Registry.Insecure}
+	if secretRef := strings.TrimSpace(req.Registry.SecretRef); secretRef != "" {
+		secret, err := s.cluster.GetSecret(targetCtx, secretRef)
+		if k8serrors.IsNotFound(err) {
+			return nil, newError(ErrInvalidInput, "secret %s not found in namespace %s", secretRef, target)
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading secret %s: %w", secretRef, err)
+		}
+		if rc.Username, rc.Password, err = registry.DockerConfigCredentials(secret.Data[corev1.DockerConfigJsonKey], ref.Host); err != nil {
+			return nil, newError(ErrInvalidInput, "secret %s: %v", secretRef, err)
+		}
+	}
+
+	artifact, err := s.OpenArtifactByFilename(ctx, name, s.artifactFileName(ctx, build))
+	if err != nil {
+		return nil, err
+	}
+	manifest := promotionManifest(build, artifact.FileName)
+	if err := rc.PushBlob(ctx, ref.Repository, *manifest.Config, func(w io.Writer) error {
+		_, err := io.WriteString(w, oci.EmptyConfig)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("error pushing config to %s: %w", ref.Name(), err)
+	}
+	if err := rc.PushBlob(ctx, ref.Repository, manifest.Layers[0], func(w io.Writer) error {
+		return artifact.WriteTo(ctx, w)
+	}); err != nil {
+		return nil, fmt.Errorf("error pushing artifact to %s: %w", ref.Name(), err)
+	}
+	digest, err := rc.PutManifest(ctx, ref.Repository, ref.Tag, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error pushing manifest to %s: %w", ref.String(), err)
+	}
+
+	promotedAt := time.Now().UTC().Format(time.RFC3339)
+	size := build.Status.ArtifactSize
+	image := &automotivev1.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: imageName,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "build-api",
+				"app.kubernetes.io/part-of":    "automotive-dev",
+			},
+			Annotations: map[string]string{
+				promotedFromNamespaceAnnotation: sourceNamespace,
+				promotedFromBuildAnnotation:     name,
+				promotedByAnnotation:            requestedBy,
+				promotedAtAnnotation:            promotedAt,
+				artifactSHA256Annotation:        build.Status.ArtifactSHA256,
+			},
+		},
+		Spec: automotivev1.ImageSpec{
+			Distro:       build.Spec.Distro,
+			Target:       build.Spec.Target,
+			Architecture: build.Spec.Architecture,
+			ExportFormat: build.Spec.ExportFormat,
+			Mode:         build.Spec.Mode,
+			Version:      ref.Tag,
+			Size:         &automotivev1.ImageSize{CompressedBytes: &size},
+			Location: automotivev1.ImageLocation{
+				Type: "registry",
+				Registry: &automotivev1.RegistryLocation{
+					URL:       ref.String(),
+					Digest:    digest,
+					SecretRef: strings.TrimSpace(req.Registry.SecretRef),
+				},
+			},
+			Metadata: &automotivev1.ImageMetadata{
+				CreatedBy:        requestedBy,
+				SourceImageBuild: name,
+				BuildDate:        build.Status.CompletionTime,
+				Annotations:      manifest.Annotations,
+			},
+		},
+	}
+	if err := s.cluster.CreateImage(targetCtx, image); err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			return nil, newError(ErrConflict, "image %s already exists in namespace %s", imageName, target)
+		}
+		return nil, fmt.Errorf("error creating image: %w", err)
+	}
+
+	resp := &PromoteResponse{
+		Image:      imageName,
+		Namespace:  target,
+		URL:        ref.String(),
+		Digest:     digest,
+		PromotedBy: requestedBy,
+		PromotedAt: promotedAt,
+	}
+	// the Image is the result; a build that cannot be indexed is only not answered from the cache
+	_ = s.indexPromotedImage(ctx, build, resp)
+	return resp, nil
+}
+
+// promotionManifest describes the artifact of a build as an automotive image, with the annotations the
+// build task's registry push records
+func promotionManifest(build *automotivev1.ImageBuild, fileName string) *registry.Manifest {
+	annotations := map[string]string{
+		oci.AnnotationDistro:       build.Spec.Distro,
+		oci.AnnotationTarget:       build.Spec.Target,
+		oci.AnnotationExportFormat: build.Spec.ExportFormat,
+		oci.AnnotationImageBuild:   build.Name,
+	}
+	if build.Spec.Architecture != "" {
+		annotations[oci.AnnotationArchitecture] = build.Spec.Architecture
+	}
+	if build.Spec.Mode != "" {
+		annotations[oci.AnnotationMode] = build.Spec.Mode
+	}
+	if build.Status.CompletionTime != nil {
+		annotations[oci.AnnotationCreated] = build.Status.CompletionTime.UTC().Format(time.RFC3339)
+	}
+	if bi := build.Spec.BuildInfo; bi != nil && bi.GitRef != "" {
+		annotations[oci.AnnotationRevision] = bi.GitRef
+	}
+
+	return &registry.Manifest{
+		SchemaVersion: 2,
+		MediaType:     oci.MediaTypeManifest,
+		ArtifactType:  oci.ArtifactType,
+		Config: &registry.Descriptor{
+			MediaType: oci.MediaTypeEmpty,
+			Digest:    fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(oci.EmptyConfig))),
+			Size:      int64(len(oci.EmptyConfig)),
+		},
+		Layers: []registry.Descriptor{{
+			MediaType:   oci.MediaTypeForFormat(build.Spec.ExportFormat),
+			Digest:      "sha256:" + build.Status.ArtifactSHA256,
+			Size:        build.Status.ArtifactSize,
+			Annotations: map[string]string{oci.AnnotationTitle: fileName},
+		}},
+		Annotations: annotations,
+	}
+}
@@ -0,0 +1,172 @@
+package buildapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	// contentHashLabel selects the builds made from the same manifests and settings. Label values are
+	// limited to 63 characters, so it carries a prefix of the hex SHA-256.
+	contentHashLabel = "automotive.sdv.cloud.redhat.com/content-hash"
+	contentHashLen   = 40
+
+	reuseCountAnnotation   = "automotive.sdv.cloud.redhat.com/reuse-count"
+	lastReusedAsAnnotation = "automotive.sdv.cloud.redhat.com/last-reused-as"
+	lastReusedByAnnotation = "automotive.sdv.cloud.redhat.com/last-reused-by"
+	lastReusedAtAnnotation = "automotive.sdv.cloud.redhat.com/last-reused-at"
+)
+
+// defaultServeExpiryHours is how long artifacts are served when neither the build nor the AutomotiveDev says
+const defaultServeExpiryHours = 24
+
+// buildContentHash identifies what a request builds: its manifests and every setting that changes the
+// artifact. Names, labels, credentials and serving options are left out.
+func buildContentHash(req BuildRequest) string {
+	included := append([]ManifestFile(nil), req.AdditionalManifests...)
+	sort.Slice(included, func(i, j int) bool { return included[i].Name < included[j].Name })
+	manifests := append([]ManifestFile{{Name: req.ManifestFileName, Content: req.Manifest}}, included...)
+	content := struct {
+		Manifests              []ManifestFile `json:"manifests"`
+		ManifestRef            string         `json:"manifestRef,omitempty"`
+		Distro                 Distro         `json:"distro"`
+		Target                 Target         `json:"target"`
+		Architecture           Architecture   `json:"architecture"`
+		ExportFormat           ExportFormat   `json:"exportFormat"`
+		Mode                   Mode           `json:"mode"`
+		AutomotiveImageBuilder string         `json:"automotiveImageBuilder"`
+		CustomDefs             []string       `json:"customDefs"`
+		AIBExtraArgs           []string       `json:"aibExtraArgs"`
+		AIBOverrideArgs        []string       `json:"aibOverrideArgs"`
+		Compression            string         `json:"compression"`
+		ArtifactNameTemplate   string         `json:"artifactNameTemplate,omitempty"`
+		BuildInfo              bool           `json:"buildInfo"`
+		GitRef                 string         `json:"gitRef"`
+	}{
+		Manifests:              manifests,
+		ManifestRef:            req.ManifestRef,
+		Distro:                 req.Distro,
+		Target:                 req.Target,
+		Architecture:           req.Architecture,
+		ExportFormat:           req.ExportFormat,
+		Mode:                   req.Mode,
+		AutomotiveImageBuilder: req.AutomotiveImageBuilder,
+		CustomDefs:             req.CustomDefs,
+		AIBExtraArgs:           req.AIBExtraArgs,
+		AIBOverrideArgs:        req.AIBOverrideArgs,
+		Compression:            req.Compression,
+		ArtifactNameTemplate:   req.ArtifactNameTemplate,
+		BuildInfo:              req.BuildInfo,
+		GitRef:                 req.GitRef,
+	}
+	// marshalling plain strings and slices cannot fail
+	b, _ := json.Marshal(content)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:contentHashLen]
+}
+
+// findReusableBuild returns the most recently completed build with the given content hash whose
+// artifact is still served and that requestedBy may see, or nil if there is none
+func (s *buildService) findReusableBuild(ctx context.Context, hash, requestedBy string) (*automotivev1.ImageBuild, error) {
+	builds, err := s.cluster.ListImageBuilds(ctx, map[string]string{contentHashLabel: hash})
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	var candidates []*automotivev1.ImageBuild
+	now := time.Now()
+	for i := range builds {
+		if servesArtifact(&builds[i], now) {
+			candidates = append(candidates, &builds[i])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Status.CompletionTime.After(candidates[j].Status.CompletionTime.Time)
+	})
+
+	revoked, err := s.revokedBuilds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.AccessPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	requester := authnv1.UserInfo{Username: requestedBy, Groups: requesterGroupsFrom(ctx)}
+	for _, b := range candidates {
+		owner := b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"]
+		if !revoked[b.Name] && canAccessBuild(requester, owner, splitGroups(b.Annotations[accessGroupsAnnotation]), policy) {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+// servesArtifact reports whether a build completed and its artifact can still be downloaded
+func servesArtifact(b *automotivev1.ImageBuild, now time.Time) bool {
+	if b.DeletionTimestamp != nil || b.Status.Phase != "Completed" || !b.Spec.ServeArtifact {
+		return false
+	}
+	if b.Status.ArtifactFileName == "" || b.Status.CompletionTime == nil {
+		return false
+	}
+	if b.Status.Scan != nil && b.Status.Scan.Blocked {
+		return false
+	}
+	hours := b.Spec.ServeExpiryHours
+	if hours <= 0 {
+		hours = defaultServeExpiryHours
+	}
+	return now.Before(b.Status.CompletionTime.Add(time.Duration(hours) * time.Hour))
+}
+
+// revokedBuilds returns the names of the builds whose Image has been revoked
+func (s *buildService) revokedBuilds(ctx context.Context) (map[string]bool, error) {
+	images, err := s.cluster.ListImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %w", err)
+	}
+	revoked := map[string]bool{}
+	for _, img := range images {
+		if img.Spec.Lifecycle == automotivev1.ImageLifecycleRevoked && img.Spec.Metadata != nil && img.Spec.Metadata.SourceImageBuild != "" {
+			revoked[img.Spec.Metadata.SourceImageBuild] = true
+		}
+	}
+	return revoked, nil
+}
+
+// reuseBuild records on build that it answered the request name by requestedBy and returns it as the result
+func (s *buildService) reuseBuild(ctx context.Context, build *automotivev1.ImageBuild, name, requestedBy string) (*BuildResponse, error) {
+	patched := build.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	count, _ := strconv.Atoi(patched.Annotations[reuseCountAnnotation])
+	patched.Annotations[reuseCountAnnotation] = strconv.Itoa(count + 1)
+	patched.Annotations[lastReusedAsAnnotation] = name
+	patched.Annotations[lastReusedByAnnotation] = requestedBy
+	patched.Annotations[lastReusedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+		return nil, fmt.Errorf("error recording build reuse: %w", err)
+	}
+
+	resp, err := s.GetBuild(ctx, build.Name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Message = fmt.Sprintf("Reusing build %s with identical manifests and settings", build.Name)
+	resp.Reused = true
+	return resp, nil
+}
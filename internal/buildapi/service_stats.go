@@ -0,0 +1,186 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+func (s *buildService) BuildStats(ctx context.Context, window time.Duration) (*BuildStatsResponse, error) {
+	builds, err := s.cluster.ListImageBuilds(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	return buildStats(builds, time.Now().Add(-window), window), nil
+}
+
+// groupTally accumulates the counts and durations behind a BuildGroupStats
+type groupTally struct {
+	stats     BuildGroupStats
+	durations []float64
+}
+
+func (t *groupTally) add(phase string, duration float64, finished bool) {
+	t.stats.Total++
+	switch phase {
+	case "Completed":
+		t.stats.Completed++
+	case "Failed":
+		t.stats.Failed++
+	}
+	if finished {
+		t.durations = append(t.durations, duration)
+	}
+}
+
+func (t *groupTally) result() BuildGroupStats {
+	t.stats.SuccessRate = successRate(t.stats.Completed, t.stats.Failed)
+	t.stats.AverageDuration = mean(t.durations)
+	return t.stats
+}
+
+// buildStats summarizes the builds created at or after since
+func buildStats(builds []automotivev1.ImageBuild, since time.Time, window time.Duration) *BuildStatsResponse {
+	resp := &BuildStatsResponse{
+		Window:   window.String(),
+		Since:    since.UTC().Format(time.RFC3339),
+		ByPhase:  map[string]int{},
+		ByDistro: map[string]BuildGroupStats{},
+		ByTarget: map[string]BuildGroupStats{},
+	}
+
+	overall := &groupTally{}
+	distros := map[string]*groupTally{}
+	targets := map[string]*groupTally{}
+	costs := map[string]*CostStats{}
+	for i := range builds {
+		b := &builds[i]
+		if b.CreationTimestamp.Time.Before(since) {
+			continue
+		}
+
+		phase := b.Status.Phase
+		if phase == "" {
+			phase = "Pending"
+		}
+		resp.ByPhase[phase]++
+
+		var duration float64
+		finished := (phase == "Completed" || phase == "Failed") &&
+			b.Status.StartTime != nil && b.Status.CompletionTime != nil
+		if finished {
+			duration = b.Status.CompletionTime.Sub(b.Status.StartTime.Time).Seconds()
+		}
+		overall.add(phase, duration, finished)
+		tally(distros, b.Spec.Distro).add(phase, duration, finished)
+		tally(targets, b.Spec.Target).add(phase, duration, finished)
+		addCost(costs, b)
+	}
+
+	totals := overall.result()
+	resp.Total = totals.Total
+	resp.SuccessRate = totals.SuccessRate
+	resp.Durations = durationStats(overall.durations)
+	for k, t := range distros {
+		resp.ByDistro[k] = t.result()
+	}
+	for k, t := range targets {
+		resp.ByTarget[k] = t.result()
+	}
+	for _, c := range costs {
+		c.Total = roundAmount(c.Total)
+		for k, v := range c.ByNamespace {
+			c.ByNamespace[k] = roundAmount(v)
+		}
+		for k, v := range c.ByRequester {
+			c.ByRequester[k] = roundAmount(v)
+		}
+		resp.Costs = append(resp.Costs, *c)
+	}
+	sort.Slice(resp.Costs, func(i, j int) bool { return resp.Costs[i].Currency < resp.Costs[j].Currency })
+	return resp
+}
+
+// addCost adds the estimated cost of a build, if it has one, to the totals of its currency
+func addCost(costs map[string]*CostStats, b *automotivev1.ImageBuild) {
+	if b.Status.Cost == nil {
+		return
+	}
+	amount, err := strconv.ParseFloat(b.Status.Cost.Total, 64)
+	if err != nil {
+		return
+	}
+	c, ok := costs[b.Status.Cost.Currency]
+	if !ok {
+		c = &CostStats{Currency: b.Status.Cost.Currency, ByNamespace: map[string]float64{}, ByRequester: map[string]float64{}}
+		costs[b.Status.Cost.Currency] = c
+	}
+	requester := b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"]
+	if requester == "" {
+		requester = "unknown"
+	}
+	c.Builds++
+	c.Total += amount
+	c.ByNamespace[b.Namespace] += amount
+	c.ByRequester[requester] += amount
+}
+
+// roundAmount drops the floating point noise summing costs leaves beyond the four decimals they have
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*1e4) / 1e4
+}
+
+func tally(groups map[string]*groupTally, key string) *groupTally {
+	if key == "" {
+		key = "unknown"
+	}
+	t, ok := groups[key]
+	if !ok {
+		t = &groupTally{}
+		groups[key] = t
+	}
+	return t
+}
+
+func successRate(completed, failed int) float64 {
+	if completed+failed == 0 {
+		return 0
+	}
+	return float64(completed) / float64(completed+failed)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func durationStats(durations []float64) DurationStats {
+	sort.Float64s(durations)
+	return DurationStats{
+		Count:   len(durations),
+		Average: mean(durations),
+		P50:     percentile(durations, 50),
+		P90:     percentile(durations, 90),
+		P99:     percentile(durations, 99),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,79 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+)
+
+func (s *buildService) StorageQuota(ctx context.Context) (*StorageQuotaResponse, error) {
+	pvcs, err := s.cluster.ListPersistentVolumeClaims(ctx, storage.WorkspaceSelector())
+	if err != nil {
+		return nil, fmt.Errorf("error listing workspaces: %w", err)
+	}
+	builds, err := s.cluster.ListImageBuilds(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	requesters := make(map[string]string, len(builds))
+	for _, b := range builds {
+		requesters[b.Name] = b.Annotations["automotive.sdv.cloud.redhat.com/requested-by"]
+	}
+
+	limit, err := s.workspaceStorageLimit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespace := k8s.NamespaceFrom(ctx)
+	if namespace == "" {
+		namespace = s.cluster.Namespace()
+	}
+
+	var total storage.Usage
+	byRequester := map[string]*storage.Usage{}
+	for _, pvc := range storage.Live(pvcs) {
+		total.Add(&pvc)
+		requester := requesters[pvc.Labels[storage.ImageBuildNameLabel]]
+		if requester == "" {
+			requester = "unknown"
+		}
+		u, ok := byRequester[requester]
+		if !ok {
+			u = &storage.Usage{}
+			byRequester[requester] = u
+		}
+		u.Add(&pvc)
+	}
+
+	resp := &StorageQuotaResponse{
+		Namespace:   namespace,
+		UsedBytes:   total.Bytes,
+		Workspaces:  total.Workspaces,
+		LimitBytes:  limit,
+		ByRequester: make(map[string]StorageUsage, len(byRequester)),
+	}
+	for requester, u := range byRequester {
+		resp.ByRequester[requester] = StorageUsage{UsedBytes: u.Bytes, Workspaces: u.Workspaces}
+	}
+	return resp, nil
+}
+
+// workspaceStorageLimit returns the AutomotiveDev's BuildConfig.MaxWorkspaceStorage in bytes, or 0 if it
+// sets none
+func (s *buildService) workspaceStorageLimit(ctx context.Context) (int64, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading workspace storage limit: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil {
+		return 0, nil
+	}
+	return storage.ParseLimit(autoDev.Spec.BuildConfig.MaxWorkspaceStorage)
+}
@@ -0,0 +1,127 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+)
+
+// defaultUploadTimeout is how long a build may go without receiving a file when BuildConfig does not say
+const defaultUploadTimeout = 120 * time.Minute
+
+// uploadSweepRequester cancels the abandoned uploads the build API sweeps for periodically
+const uploadSweepRequester = "build-api upload sweep"
+
+func (s *buildService) SweepUploads(ctx context.Context, requestedBy string, dryRun bool) (*SweepResponse, error) {
+	timeout, err := s.uploadTimeout(ctx)
+	if err != nil {
+		return nil, err
+	}
+	builds, err := s.cluster.ListImageBuilds(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing builds: %w", err)
+	}
+	namespace := k8s.NamespaceFrom(ctx)
+	if namespace == "" {
+		namespace = s.cluster.Namespace()
+	}
+
+	resp := &SweepResponse{Namespace: namespace, Timeout: timeout.String(), DryRun: dryRun, Builds: []SweptBuild{}}
+	now := time.Now()
+	for i := range builds {
+		build := &builds[i]
+		if build.Status.Phase != "Uploading" {
+			continue
+		}
+		// builds already being cancelled are reclaimed without another request
+		if _, requested := build.Annotations["automotive.sdv.cloud.redhat.com/cancel-requested-by"]; requested {
+			continue
+		}
+		idle := now.Sub(lastUploadActivity(build))
+		if idle <= timeout {
+			continue
+		}
+
+		swept, err := s.sweptBuild(ctx, build, idle)
+		if err != nil {
+			return nil, err
+		}
+		if !dryRun {
+			patched := build.DeepCopy()
+			if patched.Annotations == nil {
+				patched.Annotations = map[string]string{}
+			}
+			// the controller stops the upload server and fails the build, which releases its workspace
+			patched.Annotations["automotive.sdv.cloud.redhat.com/cancel-requested-by"] =
+				fmt.Sprintf("%s: no file received for %s", requestedBy, idle.Round(time.Minute))
+			if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+				if k8serrors.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("error cancelling build %s: %w", build.Name, err)
+			}
+		}
+		resp.Builds = append(resp.Builds, swept)
+		resp.ReclaimedBytes += swept.WorkspaceBytes
+	}
+	sort.Slice(resp.Builds, func(i, j int) bool { return resp.Builds[i].Name < resp.Builds[j].Name })
+	return resp, nil
+}
+
+// sweptBuild describes a build the sweep cancels with the workspace and upload pod cancelling it releases
+func (s *buildService) sweptBuild(ctx context.Context, build *automotivev1.ImageBuild, idle time.Duration) (SweptBuild, error) {
+	swept := SweptBuild{
+		Name:        build.Name,
+		RequestedBy: build.Annotations["automotive.sdv.cloud.redhat.com/requested-by"],
+		Idle:        idle.Round(time.Second).String(),
+	}
+	pvcs, err := s.cluster.ListPersistentVolumeClaims(ctx, storage.WorkspaceLabels(build.Name))
+	if err != nil {
+		return SweptBuild{}, fmt.Errorf("error listing workspaces of build %s: %w", build.Name, err)
+	}
+	for _, pvc := range storage.Live(pvcs) {
+		swept.Workspaces = append(swept.Workspaces, pvc.Name)
+		swept.WorkspaceBytes += storage.Size(&pvc)
+	}
+	pod, err := s.cluster.FindUploadPod(ctx, build.Name)
+	if err != nil {
+		return SweptBuild{}, fmt.Errorf("error finding upload pod of build %s: %w", build.Name, err)
+	}
+	if pod != nil {
+		swept.UploadPod = pod.Name
+	}
+	return swept, nil
+}
+
+// lastUploadActivity is when a build last received a file, or when it was created if it received none
+func lastUploadActivity(build *automotivev1.ImageBuild) time.Time {
+	last := build.CreationTimestamp.Time
+	if progress, ok := uploadprogress.FromAnnotations(build.Annotations); ok && progress.UpdatedAt.After(last) {
+		last = progress.UpdatedAt
+	}
+	return last
+}
+
+// uploadTimeout returns the AutomotiveDev's BuildConfig.UploadTimeoutMinutes, or defaultUploadTimeout if it
+// sets none
+func (s *buildService) uploadTimeout(ctx context.Context) (time.Duration, error) {
+	autoDev, err := s.cluster.GetAutomotiveDev(ctx, "automotive-dev")
+	if k8serrors.IsNotFound(err) {
+		return defaultUploadTimeout, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading upload timeout: %w", err)
+	}
+	if autoDev.Spec.BuildConfig == nil || autoDev.Spec.BuildConfig.UploadTimeoutMinutes <= 0 {
+		return defaultUploadTimeout, nil
+	}
+	return time.Duration(autoDev.Spec.BuildConfig.UploadTimeoutMinutes) * time.Minute, nil
+}
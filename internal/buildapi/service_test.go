@@ -0,0 +1,2328 @@
+package buildapi
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing/iotest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/buildapi/k8s"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/manifesthash"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/oci"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+)
+
+// fakeCluster serves ImageBuilds and Images from memory; unused Cluster methods panic via the nil embedded interface
+type fakeCluster struct {
+	k8s.Cluster
+	builds map[string]*automotivev1.ImageBuild
+	images []automotivev1.Image
+	pod    *corev1.Pod
+	// logs maps container names to their log output
+	logs map[string]string
+	// logBreaks maps container names to the number of bytes after which their next log stream breaks
+	logBreaks map[string]int
+	// files maps pod paths to the content copied there
+	files      map[string]string
+	configMaps map[string]*corev1.ConfigMap
+	pvcs       []corev1.PersistentVolumeClaim
+	secrets    map[string]*corev1.Secret
+	autoDev    *automotivev1.AutomotiveDev
+	// root stands in for the artifact pod's /workspace/shared when commands run locally
+	root string
+	// proxied counts the requests ProxyGet answered
+	proxied int
+	// deletedSecrets lists the secrets DeleteSecret was called for
+	deletedSecrets []string
+	taskRuns       map[string]*tektonv1.TaskRun
+	events         []corev1.Event
+}
+
+func (f *fakeCluster) Namespace() string {
+	return "automotive-dev-operator-system"
+}
+
+func (f *fakeCluster) GetImageBuild(_ context.Context, name string) (*automotivev1.ImageBuild, error) {
+	if b, ok := f.builds[name]; ok {
+		return b.DeepCopy(), nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Group: automotivev1.GroupVersion.Group, Resource: "imagebuilds"}, name)
+}
+
+func (f *fakeCluster) ListImageBuilds(_ context.Context, labels map[string]string) ([]automotivev1.ImageBuild, error) {
+	var out []automotivev1.ImageBuild
+	for _, b := range f.builds {
+		if k8slabels.SelectorFromSet(labels).Matches(k8slabels.Set(b.Labels)) {
+			out = append(out, *b.DeepCopy())
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCluster) ListPersistentVolumeClaims(_ context.Context, labels map[string]string) ([]corev1.PersistentVolumeClaim, error) {
+	var out []corev1.PersistentVolumeClaim
+	for _, pvc := range f.pvcs {
+		if k8slabels.SelectorFromSet(labels).Matches(k8slabels.Set(pvc.Labels)) {
+			out = append(out, pvc)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCluster) GetPersistentVolumeClaim(_ context.Context, name string) (*corev1.PersistentVolumeClaim, error) {
+	for i := range f.pvcs {
+		if f.pvcs[i].Name == name {
+			return &f.pvcs[i], nil
+		}
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "persistentvolumeclaims"}, name)
+}
+
+func (f *fakeCluster) ListImages(_ context.Context) ([]automotivev1.Image, error) {
+	return f.images, nil
+}
+
+// GetImage and CreateImage keep images of every namespace in images, tagged with their namespace
+func (f *fakeCluster) GetImage(ctx context.Context, name string) (*automotivev1.Image, error) {
+	for i := range f.images {
+		if f.images[i].Name == name && f.images[i].Namespace == k8s.NamespaceFrom(ctx) {
+			return f.images[i].DeepCopy(), nil
+		}
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Group: automotivev1.GroupVersion.Group, Resource: "images"}, name)
+}
+
+func (f *fakeCluster) CreateImage(ctx context.Context, image *automotivev1.Image) error {
+	image.Namespace = k8s.NamespaceFrom(ctx)
+	f.images = append(f.images, *image.DeepCopy())
+	return nil
+}
+
+func (f *fakeCluster) DeleteImage(ctx context.Context, name string) error {
+	for i := range f.images {
+		if f.images[i].Name == name && f.images[i].Namespace == k8s.NamespaceFrom(ctx) {
+			f.images = append(f.images[:i], f.images[i+1:]...)
+			return nil
+		}
+	}
+	return k8serrors.NewNotFound(schema.GroupResource{Group: automotivev1.GroupVersion.Group, Resource: "images"}, name)
+}
+
+func (f *fakeCluster) GetPod(_ context.Context, _ string) (*corev1.Pod, error) {
+	return f.pod, nil
+}
+
+func (f *fakeCluster) GetTaskRun(_ context.Context, name string) (*tektonv1.TaskRun, error) {
+	if tr, ok := f.taskRuns[name]; ok {
+		return tr.DeepCopy(), nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Group: "tekton.dev", Resource: "taskruns"}, name)
+}
+
+func (f *fakeCluster) ListEvents(_ context.Context, name string) ([]corev1.Event, error) {
+	var out []corev1.Event
+	for _, e := range f.events {
+		if e.InvolvedObject.Name == name {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeCluster) FindTaskRunPod(_ context.Context, _ string) (*corev1.Pod, error) {
+	return f.pod, nil
+}
+
+func (f *fakeCluster) StreamContainerLogs(_ context.Context, _ string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	logs := f.logs[opts.Container]
+	if n, ok := f.logBreaks[opts.Container]; ok {
+		delete(f.logBreaks, opts.Container)
+		return io.NopCloser(io.MultiReader(strings.NewReader(logs[:n]), iotest.ErrReader(errors.New("stream reset")))), nil
+	}
+	return io.NopCloser(strings.NewReader(logs)), nil
+}
+
+func (f *fakeCluster) GetConfigMap(_ context.Context, name string) (*corev1.ConfigMap, error) {
+	if cm, ok := f.configMaps[name]; ok {
+		return cm, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+}
+
+func (f *fakeCluster) CreateConfigMap(_ context.Context, cm *corev1.ConfigMap) error {
+	f.configMaps[cm.Name] = cm.DeepCopy()
+	return nil
+}
+
+func (f *fakeCluster) UpdateConfigMap(_ context.Context, cm *corev1.ConfigMap) error {
+	f.configMaps[cm.Name] = cm.DeepCopy()
+	return nil
+}
+
+func (f *fakeCluster) CreateImageBuild(_ context.Context, build *automotivev1.ImageBuild) error {
+	f.builds[build.Name] = build.DeepCopy()
+	return nil
+}
+
+func (f *fakeCluster) SetControllerOwner(_ context.Context, _ client.Object, _ *automotivev1.ImageBuild) error {
+	return nil
+}
+
+func (f *fakeCluster) GetSecret(_ context.Context, name string) (*corev1.Secret, error) {
+	if s, ok := f.secrets[name]; ok {
+		return s, nil
+	}
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+}
+
+func (f *fakeCluster) GetAutomotiveDev(_ context.Context, name string) (*automotivev1.AutomotiveDev, error) {
+	if f.autoDev == nil {
+		return nil, k8serrors.NewNotFound(schema.GroupResource{Group: automotivev1.GroupVersion.Group, Resource: "automotivedevs"}, name)
+	}
+	return f.autoDev, nil
+}
+
+func (f *fakeCluster) PatchImageBuild(_ context.Context, _, modified *automotivev1.ImageBuild) error {
+	f.builds[modified.Name] = modified.DeepCopy()
+	return nil
+}
+
+func (f *fakeCluster) DeleteImageBuild(_ context.Context, name string) error {
+	if _, ok := f.builds[name]; !ok {
+		return k8serrors.NewNotFound(schema.GroupResource{Group: automotivev1.GroupVersion.Group, Resource: "imagebuilds"}, name)
+	}
+	delete(f.builds, name)
+	return nil
+}
+
+func (f *fakeCluster) CreateSecret(_ context.Context, secret *corev1.Secret) error {
+	if f.secrets == nil {
+		f.secrets = map[string]*corev1.Secret{}
+	}
+	f.secrets[secret.Name] = secret.DeepCopy()
+	return nil
+}
+
+func (f *fakeCluster) DeleteSecret(_ context.Context, name string) error {
+	f.deletedSecrets = append(f.deletedSecrets, name)
+	return nil
+}
+
+func (f *fakeCluster) FindUploadPod(_ context.Context, _ string) (*corev1.Pod, error) {
+	return f.pod, nil
+}
+
+func (f *fakeCluster) CopyToPod(_ context.Context, _, _, localPath, podPath string) error {
+	b, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	f.files[podPath] = string(b)
+	if rest, ok := strings.CutPrefix(podPath, "/workspace/shared/"); ok && f.root != "" {
+		// commands run below root, such as chmod, see the copied file too
+		local := filepath.Join(f.root, rest)
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(local, b, 0o600)
+	}
+	return nil
+}
+
+func (f *fakeCluster) WaitForArtifactPod(_ context.Context, _ string, _ time.Duration) (*corev1.Pod, error) {
+	return f.pod, nil
+}
+
+// ProxyGet serves files below root to requests carrying the token of the build's nginx ConfigMap
+func (f *fakeCluster) ProxyGet(_ context.Context, _ string, port int, p string, header http.Header) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	cm := f.configMaps["served-nginx-config"]
+	if port != artifactProxyPort || cm == nil || header.Get(artifactProxyTokenHeader) != cm.Data[artifactProxyTokenKey] {
+		rec.WriteHeader(http.StatusForbidden)
+		return rec.Result(), nil
+	}
+	f.proxied++
+	http.ServeFile(rec, httptest.NewRequest(http.MethodGet, p, nil), filepath.Join(f.root, p))
+	resp := rec.Result()
+	resp.ContentLength = int64(rec.Body.Len())
+	return resp, nil
+}
+
+// Exec answers sha256sum for files copied with CopyToPod and runs any other command locally below root
+func (f *fakeCluster) Exec(ctx context.Context, pod, container string, command []string, w io.Writer) error {
+	if command[0] == "sha256sum" {
+		podPath := command[len(command)-1]
+		if content, ok := f.files[podPath]; ok {
+			sum := sha256.Sum256([]byte(content))
+			_, err := fmt.Fprintf(w, "%x  %s\n", sum, podPath)
+			return err
+		}
+	}
+	return f.ExecWithInput(ctx, pod, container, command, nil, w)
+}
+
+func (f *fakeCluster) ExecWithInput(ctx context.Context, _, _ string, command []string, stdin io.Reader, w io.Writer) error {
+	args := make([]string, len(command))
+	for i, arg := range command {
+		if rest, ok := strings.CutPrefix(arg, "/workspace/shared"); ok {
+			arg = f.root + rest
+		}
+		args[i] = arg
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = f.root
+	cmd.Stdin = stdin
+	cmd.Stdout = w
+	return cmd.Run()
+}
+
+// recordingLogSink collects the logs of each step in the order they were streamed
+type recordingLogSink struct {
+	steps []string
+	logs  map[string]string
+}
+
+func (s *recordingLogSink) StepStarted(step string) {
+	s.steps = append(s.steps, step)
+}
+
+func (s *recordingLogSink) Write(step string, p []byte) error {
+	s.logs[step] += string(p)
+	return nil
+}
+
+func (s *recordingLogSink) StreamError(string, error) {}
+
+func (s *recordingLogSink) Waiting() {}
+
+var _ = Describe("BuildService", func() {
+	var (
+		cluster *fakeCluster
+		svc     BuildService
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cluster = &fakeCluster{builds: map[string]*automotivev1.ImageBuild{
+			"running": {
+				ObjectMeta: metav1.ObjectMeta{Name: "running"},
+				Status:     automotivev1.ImageBuildStatus{Phase: "Building"},
+			},
+			"done": {
+				ObjectMeta: metav1.ObjectMeta{Name: "done", Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "build-api",
+					"team":                         "infotainment",
+				}},
+				Status: automotivev1.ImageBuildStatus{Phase: "Completed"},
+			},
+		}}
+		svc = NewBuildService(cluster)
+	})
+
+	It("should report missing builds as ErrNotFound", func() {
+		_, err := svc.GetBuild(ctx, "missing")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should report the known feature gates the AutomotiveDev turns on", func() {
+		Expect(svc.EnabledFeatures(ctx)).To(BeEmpty())
+
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			FeatureGates: map[string]bool{"enableTestBoot": true, "enableQueueing": true, "enableArchival": false, "enableTypo": true},
+		}}
+		Expect(svc.EnabledFeatures(ctx)).To(Equal([]string{"enableQueueing", "enableTestBoot"}))
+	})
+
+	It("should link builds to their resources in the web console when configured", func() {
+		build := cluster.builds["done"]
+		build.Namespace = "team-a"
+		build.Status.TaskRunName = "done-tr"
+		build.Status.PVCName = "done-ws"
+		cluster.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "done-tr-pod"}}
+
+		resp, err := svc.GetBuild(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.TaskRunURL).To(BeEmpty())
+
+		svc.(*buildService).consoleURL = "https://console.example.com"
+		resp, err = svc.GetBuild(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.TaskRunURL).To(Equal("https://console.example.com/k8s/ns/team-a/tekton.dev~v1~TaskRun/done-tr"))
+		Expect(resp.PodLogsURL).To(Equal("https://console.example.com/k8s/ns/team-a/pods/done-tr-pod/logs"))
+		Expect(resp.PVCURL).To(Equal("https://console.example.com/k8s/ns/team-a/persistentvolumeclaims/done-ws"))
+
+		By("leaving out resources the build does not have")
+		build.Status.TaskRunName = ""
+		resp, err = svc.GetBuild(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.TaskRunURL).To(BeEmpty())
+		Expect(resp.PodLogsURL).To(BeEmpty())
+		Expect(resp.PVCURL).NotTo(BeEmpty())
+	})
+
+	It("should refuse artifacts of builds that have not completed", func() {
+		_, err := svc.OpenArtifactsTar(ctx, "running")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+	})
+
+	It("should refuse artifacts of builds that produced a revoked image", func() {
+		cluster.images = []automotivev1.Image{{
+			ObjectMeta: metav1.ObjectMeta{Name: "img"},
+			Spec: automotivev1.ImageSpec{
+				Lifecycle: automotivev1.ImageLifecycleRevoked,
+				Metadata:  &automotivev1.ImageMetadata{SourceImageBuild: "done"},
+			},
+		}}
+		_, err := svc.ListArtifacts(ctx, "done")
+		Expect(errors.Is(err, ErrGone)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("img"))
+	})
+
+	It("should refuse artifacts the scan policy blocked but keep their scan report", func() {
+		cluster.builds["vulnerable"] = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "vulnerable"},
+			Status: automotivev1.ImageBuildStatus{
+				Phase: "Completed",
+				Scan:  &automotivev1.ScanResult{Critical: 3, ReportFileName: "disk.raw.gz.scan.json", Blocked: true},
+			},
+		}
+		_, err := svc.OpenArtifactsTar(ctx, "vulnerable")
+		Expect(errors.Is(err, ErrForbidden)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("3 critical"))
+
+		resp, err := svc.GetBuild(ctx, "vulnerable")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Scan).To(Equal(&ScanSummary{Critical: 3, Blocked: true}))
+
+		_, err = svc.OpenScanReport(ctx, "done")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+		_, err = svc.OpenScanReport(ctx, "running")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+	})
+
+	It("should only serve the workspace of failed builds that kept it", func() {
+		_, err := svc.OpenWorkspaceTar(ctx, "done")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+
+		cluster.builds["failed"] = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "failed"},
+			Status:     automotivev1.ImageBuildStatus{Phase: "Failed"},
+		}
+		_, err = svc.OpenWorkspaceTar(ctx, "failed")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should pass hostile artifact names to pod commands without shell interpolation", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "hostile-artifact-pod"}}
+		artifact := `disk "$(touch pwned)" 'x'.raw.gz`
+		cluster.builds["hostile"] = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "hostile"},
+			Status:     automotivev1.ImageBuildStatus{Phase: "Completed", ArtifactFileName: artifact},
+		}
+		parts := filepath.Join(cluster.root, artifact+"-parts")
+		Expect(os.MkdirAll(parts, 0o755)).To(Succeed())
+		names := []string{"part 1.gz", "a:b'; touch pwned; '.gz", "new\nline.gz"}
+		for i, n := range names {
+			Expect(os.WriteFile(filepath.Join(parts, n), []byte(strings.Repeat("x", i+1)), 0o644)).To(Succeed())
+		}
+
+		items, err := svc.ListArtifacts(ctx, "hostile")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(ConsistOf(
+			ArtifactItem{Name: names[0], SizeBytes: "1", ContentType: "application/octet-stream"},
+			ArtifactItem{Name: names[1], SizeBytes: "2", ContentType: "application/octet-stream"},
+			ArtifactItem{Name: names[2], SizeBytes: "3", ContentType: "application/octet-stream"},
+		))
+
+		part, err := svc.OpenArtifactPart(ctx, "hostile", names[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(part.Size).To(Equal("2"))
+		var buf strings.Builder
+		Expect(part.WriteTo(ctx, &buf)).To(Succeed())
+		Expect(buf.String()).To(Equal("xx"))
+
+		_, err = svc.OpenArtifactPart(ctx, "hostile", "missing '$(touch pwned)'.gz")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+		metadata := artifact + ".metadata.json"
+		Expect(os.WriteFile(filepath.Join(cluster.root, metadata), []byte(`{"sizeBytes": 4}`), 0o644)).To(Succeed())
+		items, err = svc.ListArtifacts(ctx, "hostile")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(HaveLen(4))
+		Expect(items[0]).To(Equal(ArtifactItem{Name: metadata, SizeBytes: "16", ContentType: "application/json"}))
+		part, err = svc.OpenArtifactPart(ctx, "hostile", metadata)
+		Expect(err).NotTo(HaveOccurred())
+		buf.Reset()
+		Expect(part.WriteTo(ctx, &buf)).To(Succeed())
+		Expect(buf.String()).To(Equal(`{"sizeBytes": 4}`))
+
+		Expect(filepath.Join(cluster.root, "pwned")).NotTo(BeAnExistingFile())
+	})
+
+	It("should download artifacts over the pod proxy and serve repeated downloads from the cache", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "served-artifact-pod"}}
+		cluster.builds["served"] = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "served", UID: "uid-1"},
+			Status:     automotivev1.ImageBuildStatus{Phase: "Completed", ArtifactFileName: "disk.raw"},
+		}
+		Expect(os.WriteFile(filepath.Join(cluster.root, "disk.raw"), []byte("image"), 0o644)).To(Succeed())
+		cache, err := newArtifactCache(GinkgoT().TempDir(), 1<<20)
+		Expect(err).NotTo(HaveOccurred())
+		svc = &buildService{cluster: cluster, artifacts: cache}
+
+		download := func() string {
+			artifact, err := svc.OpenArtifactByFilename(ctx, "served", "disk.raw")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(artifact.Size).To(Equal("5"))
+			var buf strings.Builder
+			Expect(artifact.WriteTo(ctx, &buf)).To(Succeed())
+			return buf.String()
+		}
+
+		// without the controller's token the pod is read with exec
+		Expect(download()).To(Equal("image"))
+		Expect(cluster.proxied).To(Equal(0))
+
+		cluster.configMaps = map[string]*corev1.ConfigMap{
+			"served-nginx-config": {Data: map[string]string{artifactProxyTokenKey: "secret"}},
+		}
+		Expect(download()).To(Equal("image"))
+		Expect(cluster.proxied).To(Equal(1))
+		Expect(download()).To(Equal("image"))
+		Expect(cluster.proxied).To(Equal(1))
+	})
+
+	It("should record who cancelled a running build and refuse to cancel finished ones", func() {
+		resp, err := svc.CancelBuild(ctx, "running", "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Message).To(Equal("Cancellation requested"))
+		Expect(cluster.builds["running"].Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/cancel-requested-by", "alice"))
+
+		_, err = svc.CancelBuild(ctx, "running", "bob")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["running"].Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/cancel-requested-by", "alice"))
+
+		_, err = svc.CancelBuild(ctx, "done", "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		_, err = svc.CancelBuild(ctx, "missing", "alice")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should update the settings of a build waiting for its uploads and refuse once it builds", func() {
+		cluster.builds["pending"] = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "build-api",
+				"team":                         "infotainment",
+			}},
+			Spec: automotivev1.ImageBuildSpec{
+				Distro: "cs9", Target: "qemu", Architecture: "arm64",
+				ManifestConfigMap: "pending-manifest", ServeExpiryHours: 24,
+			},
+			Status: automotivev1.ImageBuildStatus{Phase: "Uploading"},
+		}
+		cluster.configMaps = map[string]*corev1.ConfigMap{"pending-manifest": {
+			ObjectMeta: metav1.ObjectMeta{Name: "pending-manifest", Labels: map[string]string{"team": "infotainment"}},
+		}}
+
+		hours := int32(72)
+		expose := true
+		resp, err := svc.UpdateBuild(ctx, "pending", UpdateBuildRequest{
+			ServeExpiryHours: &hours,
+			ExposeRoute:      &expose,
+			Labels:           map[string]string{"release": "r1"},
+			RegistryCredentials: &RegistryCredentials{
+				Enabled: true, AuthType: "token", RegistryURL: "quay.io", Token: "t0ken",
+			},
+			Distro: "cs9",
+		}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Message).To(Equal("Build updated"))
+
+		build := cluster.builds["pending"]
+		Expect(build.Spec.ServeExpiryHours).To(Equal(int32(72)))
+		Expect(build.Spec.ExposeRoute).To(BeTrue())
+		Expect(build.Spec.EnvSecretRef).To(Equal("pending-registry-auth"))
+		Expect(build.Labels).To(Equal(map[string]string{"app.kubernetes.io/managed-by": "build-api", "release": "r1"}))
+		Expect(build.Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/updated-by", "alice"))
+		Expect(cluster.configMaps["pending-manifest"].Labels).To(Equal(map[string]string{"release": "r1"}))
+		Expect(cluster.secrets["pending-registry-auth"].Data).To(HaveKeyWithValue("REGISTRY_TOKEN", []byte("t0ken")))
+
+		_, err = svc.UpdateBuild(ctx, "pending", UpdateBuildRequest{RegistryCredentials: &RegistryCredentials{Enabled: false}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["pending"].Spec.EnvSecretRef).To(BeEmpty())
+		Expect(cluster.deletedSecrets).To(ContainElement("pending-registry-auth"))
+
+		_, err = svc.UpdateBuild(ctx, "pending", UpdateBuildRequest{Target: "rpi4"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.UpdateBuild(ctx, "pending", UpdateBuildRequest{RegistryCredentials: &RegistryCredentials{Enabled: true, AuthType: "token"}}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		Expect(cluster.builds["pending"].Spec.EnvSecretRef).To(BeEmpty())
+
+		_, err = svc.UpdateBuild(ctx, "running", UpdateBuildRequest{ServeExpiryHours: &hours}, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		_, err = svc.UpdateBuild(ctx, "missing", UpdateBuildRequest{}, "alice")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should delete finished builds with their secrets and only force the deletion of running ones", func() {
+		resp, err := svc.DeleteBuild(ctx, "done", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Message).To(Equal("Build deleted"))
+		Expect(cluster.builds).NotTo(HaveKey("done"))
+		Expect(cluster.deletedSecrets).To(ConsistOf("done-registry-auth", "done-encryption-key"))
+
+		_, err = svc.DeleteBuild(ctx, "running", false)
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		Expect(cluster.builds).To(HaveKey("running"))
+
+		_, err = svc.DeleteBuild(ctx, "running", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds).NotTo(HaveKey("running"))
+
+		_, err = svc.DeleteBuild(ctx, "missing", true)
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should reject invalid artifact file names before touching the cluster", func() {
+		_, err := svc.OpenArtifactPart(ctx, "done", "../etc/passwd")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+	})
+
+	It("should reject reserved or malformed build labels", func() {
+		for _, labels := range []map[string]string{
+			{"app.kubernetes.io/name": "x"},
+			{"tekton.dev/task": "x"},
+			{"team": "not a valid value"},
+			{"-bad-key": "x"},
+		} {
+			_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", Labels: labels}, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "labels %v", labels)
+		}
+	})
+
+	It("should reject manifest file names that cannot share the manifest ConfigMap", func() {
+		for _, files := range [][]ManifestFile{
+			{{Name: "../common.aib.yml"}},
+			{{Name: "aib-extra-args.txt"}},
+			{{Name: "manifest.aib.yml"}},
+			{{Name: "a.aib.yml"}, {Name: "a.aib.yml"}},
+		} {
+			_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", AdditionalManifests: files}, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "files %v", files)
+		}
+	})
+
+	It("should only accept a manifest artifact reference in place of the manifests", func() {
+		for _, req := range []BuildRequest{
+			{Name: "b"},
+			{Name: "b", Manifest: "m", ManifestRef: "quay.io/org/manifests:v1"},
+			{Name: "b", ManifestRef: "quay.io/org/manifests:v1", AdditionalManifests: []ManifestFile{{Name: "a.aib.yml"}}},
+			{Name: "b", ManifestRef: "manifests:v1"},
+			{Name: "b", ManifestRef: "quay.io/org/manifests:v1 --insecure"},
+			{Name: "b", ManifestRef: "--output=/etc/quay.io"},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "request %+v", req)
+		}
+
+		cluster.builds["done"].Spec.ManifestConfigMap = "done-manifest"
+		cluster.builds["done"].Spec.ManifestRef = "quay.io/org/manifests@sha256:0123"
+		cluster.configMaps = map[string]*corev1.ConfigMap{"done-manifest": {Data: map[string]string{
+			"aib-extra-args.txt": "--verbose",
+		}}}
+		tpl, err := svc.GetBuildTemplate(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tpl.ManifestRef).To(Equal("quay.io/org/manifests@sha256:0123"))
+		Expect(tpl.Manifest).To(BeEmpty())
+		Expect(tpl.ManifestFileName).To(BeEmpty())
+		Expect(tpl.AIBExtraArgs).To(Equal([]string{"--verbose"}))
+	})
+
+	It("should only accept a printable git ref for builds that record build info", func() {
+		for _, req := range []BuildRequest{
+			{Name: "b", Manifest: "m", GitRef: "abc123"},
+			{Name: "b", Manifest: "m", BuildInfo: true, GitRef: "abc123\nBUILD_NAME=forged"},
+			{Name: "b", Manifest: "m", BuildInfo: true, GitRef: strings.Repeat("a", 257)},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "request %+v", req)
+		}
+	})
+
+	It("should record the correlation IDs of the request that created a build", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		traced := correlation.WithIDs(ctx, correlation.IDs{
+			RequestID:   "ci-run-42",
+			Traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		})
+		_, err := svc.CreateBuild(traced, BuildRequest{Name: "b", Manifest: "m"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["b"].Annotations).To(HaveKeyWithValue(correlation.RequestIDAnnotation, "ci-run-42"))
+		Expect(cluster.builds["b"].Annotations).To(HaveKeyWithValue(correlation.TraceparentAnnotation,
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+
+		resp, err := svc.GetBuild(ctx, "b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.RequestID).To(Equal("ci-run-42"))
+	})
+
+	It("should record the hash of every file of the manifest ConfigMap a build was created with", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		_, err := svc.CreateBuild(ctx, BuildRequest{
+			Name:         "b",
+			Manifest:     "name: demo\n",
+			CustomDefs:   []string{"A=1"},
+			AIBExtraArgs: []string{"--verbose"},
+		}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		cm := cluster.configMaps["b-manifest"]
+		Expect(cm.Data).To(HaveKey("custom-definitions.env"))
+		Expect(cm.Data).To(HaveKey("aib-extra-args.txt"))
+		sum := manifesthash.Sum(cm)
+		Expect(cluster.builds["b"].Annotations).To(HaveKeyWithValue(manifesthash.Annotation, sum))
+
+		resp, err := svc.GetBuild(ctx, "b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.ManifestSHA256).To(Equal(sum))
+
+		cm.Data["aib-extra-args.txt"] = "--define-file /etc/shadow"
+		Expect(manifesthash.Sum(cm)).NotTo(Equal(sum), "the AIB args are pinned with the manifests")
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "from-ref", ManifestRef: "quay.io/org/manifests:v1", ManifestFileName: "main.aib.yml",
+			CustomDefs: []string{"A=1"}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["from-ref"].Annotations).To(HaveKeyWithValue(manifesthash.Annotation,
+			manifesthash.Sum(cluster.configMaps["from-ref-manifest"])))
+	})
+
+	It("should accept builds that skip compressing their artifact", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", Compression: "zstd"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", Compression: "none"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["b"].Spec.Compression).To(Equal("none"))
+	})
+
+	It("should name artifacts after the request's template", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		for _, tmpl := range []string{"{{.Distro}}/{{.Target}}", "{{.Owner}}", "{{.Distro"} {
+			_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", ArtifactNameTemplate: tmpl}, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), tmpl)
+		}
+
+		_, err := svc.CreateBuild(ctx, BuildRequest{
+			Name: "b", Manifest: "m", Distro: "autosd", Target: "qemu", ExportFormat: "qcow2",
+			ArtifactNameTemplate: "{{.Name}}-{{.Target}}",
+		}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["b"].Spec.ArtifactNameTemplate).To(Equal("{{.Name}}-{{.Target}}"))
+		Expect(svc.(*buildService).artifactFileName(ctx, cluster.builds["b"])).To(Equal("b-qemu.qcow2"))
+
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{ArtifactNameTemplate: "{{.Distro}}-{{.Arch}}"},
+		}}
+		cluster.builds["b"].Spec.ArtifactNameTemplate = ""
+		cluster.builds["b"].Spec.Architecture = "arm64"
+		Expect(svc.(*buildService).artifactFileName(ctx, cluster.builds["b"])).To(Equal("autosd-arm64.qcow2"))
+	})
+
+	It("should commit builds to an ostree ref and report the commit", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		cluster.secrets = map[string]*corev1.Secret{
+			"ostree-ssh": {Data: map[string][]byte{corev1.SSHAuthPrivateKey: []byte("key")}},
+			"registry":   {Data: map[string][]byte{"password": []byte("p")}},
+		}
+		for _, ostree := range []OSTreeUpdate{
+			{RepositoryURL: "https://updates.example.com/repo", Ref: "autosd/aarch64/qemu"},
+			{RepositoryURL: "ostree@updates.example.com:/srv/repo", Ref: "../escape"},
+			{RepositoryURL: "ostree@updates.example.com:/srv/repo", Ref: "autosd/qemu", SecretRef: "missing"},
+			{RepositoryURL: "ostree@updates.example.com:/srv/repo", Ref: "autosd/qemu", SecretRef: "registry"},
+		} {
+			_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", OSTree: &ostree}, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "ostree %+v", ostree)
+		}
+
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", ReuseExisting: true, OSTree: &OSTreeUpdate{
+			RepositoryURL: "ostree@updates.example.com:/srv/repo", Ref: "autosd/aarch64/qemu", SecretRef: "ostree-ssh",
+		}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		build := cluster.builds["b"]
+		Expect(build.Spec.OSTree).To(Equal(&automotivev1.OSTreeUpdate{
+			RepositoryURL: "ostree@updates.example.com:/srv/repo", Ref: "autosd/aarch64/qemu", SecretRef: "ostree-ssh",
+		}))
+		// every build must push a commit of its own
+		Expect(build.Labels).NotTo(HaveKey(contentHashLabel))
+
+		build.Status.Phase = "Completed"
+		build.Status.OSTreeCommit = "4c6e0b7a"
+		resp, err := svc.GetBuild(ctx, "b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.OSTreeCommit).To(Equal("4c6e0b7a"))
+	})
+
+	It("should mount an input claim without waiting for uploads of its files", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		cluster.pvcs = []corev1.PersistentVolumeClaim{
+			{ObjectMeta: metav1.ObjectMeta{Name: "vendor-blobs"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "done-ws", Labels: storage.WorkspaceLabels("done")}},
+		}
+		for _, input := range []InputPVC{
+			{ClaimName: "missing"},
+			{ClaimName: "done-ws"},
+			{ClaimName: "Vendor_Blobs"},
+			{ClaimName: "vendor-blobs", SubPath: "../other"},
+			{ClaimName: "vendor-blobs", SubPath: "/abs"},
+		} {
+			_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", InputPVC: &input}, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "input %+v", input)
+		}
+
+		manifest := "content:\n  add_files:\n    - path: /opt/vendor/blob.bin\n      source_path: \"/workspace/input/blob.bin\"\n"
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: manifest, ReuseExisting: true, InputPVC: &InputPVC{
+			ClaimName: "vendor-blobs", SubPath: "bsp/v2", ReadOnly: true,
+		}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		build := cluster.builds["b"]
+		Expect(build.Spec.InputPVC).To(Equal(&automotivev1.InputPVC{ClaimName: "vendor-blobs", SubPath: "bsp/v2", ReadOnly: true}))
+		Expect(build.Spec.InputFilesServer).To(BeFalse())
+		// the files on the claim may change between builds
+		Expect(build.Labels).NotTo(HaveKey(contentHashLabel))
+
+		Expect(referencesUploads(manifest + "    - path: /etc/radio.conf\n      source_path: radio.conf\n")).To(BeTrue())
+		Expect(referencesUploads("content:\n  add_files: [{path: /x, source_path: /workspace/input/x}]\n")).To(BeTrue())
+	})
+
+	It("should encrypt builds with the client's key or an existing secret", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		cluster.secrets = map[string]*corev1.Secret{
+			"oem-key":   {Data: map[string][]byte{"key": []byte("0123456789abcdef")}},
+			"wrong-key": {Data: map[string][]byte{"passphrase": []byte("0123456789abcdef")}},
+		}
+		for _, req := range []BuildRequest{
+			{Name: "b", Manifest: "m", EncryptionKey: "0123456789abcdef", EncryptionKeySecret: "oem-key"},
+			{Name: "b", Manifest: "m", EncryptionKey: "short"},
+			{Name: "b", Manifest: "m", EncryptionKey: "0123456789abcdef\nsecond line"},
+			{Name: "b", Manifest: "m", EncryptionKeySecret: "missing"},
+			{Name: "b", Manifest: "m", EncryptionKeySecret: "wrong-key"},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "request %+v", req)
+		}
+
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "client-key", Manifest: "m", EncryptionKey: "0123456789abcdef"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.secrets).To(HaveKey("client-key-encryption-key"))
+		Expect(cluster.secrets["client-key-encryption-key"].Data).To(HaveKeyWithValue("key", []byte("0123456789abcdef")))
+		build := cluster.builds["client-key"]
+		Expect(build.Spec.EncryptionKeySecretRef).To(Equal("client-key-encryption-key"))
+		// encrypted builds only open with their own key, so they are never reused
+		Expect(build.Labels).NotTo(HaveKey(contentHashLabel))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "secret-key", Manifest: "m", EncryptionKeySecret: "oem-key"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["secret-key"].Spec.EncryptionKeySecretRef).To(Equal("oem-key"))
+		resp, err := svc.GetBuild(ctx, "secret-key")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Encrypted).To(BeTrue())
+
+		cluster.builds["done"].Spec.ServeArtifact = true
+		cluster.builds["done"].Spec.EncryptionKeySecretRef = "oem-key"
+		cluster.builds["done"].Status.ArtifactFileName = "cs9-qemu.raw.gz.enc"
+		_, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "vmdk"}, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+	})
+
+	It("should reject unknown distros, targets and architectures with a suggestion", func() {
+		for _, req := range []BuildRequest{
+			{Name: "b", Manifest: "m", Distro: "sc9"},
+			{Name: "b", Manifest: "m", Target: "qemux"},
+			{Name: "b", Manifest: "m", Architecture: "arm46"},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "request %+v", req)
+			Expect(err.Error()).To(ContainSubstring("did you mean"))
+		}
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", Distro: "cs8"}, "alice")
+		Expect(err).To(MatchError(ContainSubstring("did you mean cs9?")))
+	})
+
+	It("should refuse builds while the AutomotiveDev reports its Tekton resources are not installed", func() {
+		cluster.autoDev = &automotivev1.AutomotiveDev{Status: automotivev1.AutomotiveDevStatus{
+			Conditions: []metav1.Condition{
+				{Type: automotivev1.AutomotiveDevTasksReady, Status: metav1.ConditionTrue},
+				{Type: automotivev1.AutomotiveDevPipelineReady, Status: metav1.ConditionFalse, Message: "pipeline: forbidden"},
+			},
+		}}
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m"}, "alice")
+		Expect(errors.Is(err, ErrNotReady)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("pipeline: forbidden"))
+	})
+
+	It("should answer a repeated request with the completed build that still serves its artifact", func() {
+		req := BuildRequest{Name: "again", Manifest: "content: {}", ReuseExisting: true}
+		defaulted := req
+		defaulted.Distro, defaulted.Target, defaulted.Architecture = "cs9", "qemu", "arm64"
+		defaulted.ExportFormat, defaulted.Mode, defaulted.Compression = "image", "image", "gzip"
+		defaulted.ManifestFileName = "manifest.aib.yml"
+		hash := buildContentHash(defaulted)
+
+		completed := metav1.NewTime(time.Now().Add(-time.Hour))
+		for name, build := range map[string]automotivev1.ImageBuildSpec{
+			"previous": {ServeArtifact: true},
+			"unserved": {},
+		} {
+			cluster.builds[name] = &automotivev1.ImageBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{contentHashLabel: hash}},
+				Spec:       build,
+				Status: automotivev1.ImageBuildStatus{
+					Phase:            "Completed",
+					CompletionTime:   &completed,
+					ArtifactFileName: "disk.raw.gz",
+				},
+			}
+		}
+
+		resp, err := svc.CreateBuild(ctx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeTrue())
+		Expect(resp.Name).To(Equal("previous"))
+		Expect(resp.ArtifactFileName).To(Equal("disk.raw.gz"))
+		Expect(cluster.builds["previous"].Annotations).To(HaveKeyWithValue(lastReusedAsAnnotation, "again"))
+		Expect(cluster.builds["previous"].Annotations).To(HaveKeyWithValue(lastReusedByAnnotation, "alice"))
+		Expect(cluster.builds["previous"].Annotations).To(HaveKeyWithValue(reuseCountAnnotation, "1"))
+
+		reordered := defaulted
+		reordered.Name = "other"
+		Expect(buildContentHash(reordered)).To(Equal(hash))
+		reordered.CustomDefs = []string{"FOO=bar"}
+		Expect(buildContentHash(reordered)).NotTo(Equal(hash))
+	})
+
+	It("should record the requester's groups and only reuse builds the requester may see", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		req := BuildRequest{Name: "shared", Manifest: "content: {}", ReuseExisting: true}
+		groupCtx := withRequesterGroups(ctx, []string{"system:authenticated", "infotainment"})
+		resp, err := svc.CreateBuild(groupCtx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeFalse())
+		Expect(cluster.builds["shared"].Annotations).To(HaveKeyWithValue(requestedByGroupsAnnotation, "system:authenticated,infotainment"))
+		Expect(cluster.builds["shared"].Annotations).To(HaveKeyWithValue(accessGroupsAnnotation, "infotainment"))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "bad", Manifest: "m", AccessGroups: []string{"a,b"}}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		completed := metav1.NewTime(time.Now().Add(-time.Hour))
+		cluster.builds["shared"].Spec.ServeArtifact = true
+		cluster.builds["shared"].Status = automotivev1.ImageBuildStatus{
+			Phase: "Completed", CompletionTime: &completed, ArtifactFileName: "disk.raw.gz",
+		}
+		got, err := svc.GetBuild(ctx, "shared")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.AccessGroups).To(Equal([]string{"infotainment"}))
+
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{Access: &automotivev1.BuildAccess{Restricted: true}},
+		}}
+		req.Name = "mine"
+		resp, err = svc.CreateBuild(withRequesterGroups(ctx, []string{"adas"}), req, "carol")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeFalse())
+
+		req.Name = "theirs"
+		resp, err = svc.CreateBuild(withRequesterGroups(ctx, []string{"infotainment"}), req, "bob")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeTrue())
+		Expect(resp.Name).To(Equal("shared"))
+	})
+
+	It("should answer requests from the images identical builds were promoted to unless bypassed", func() {
+		req := BuildRequest{Name: "again", Manifest: "content: {}"}
+		defaulted := req
+		defaulted.Distro, defaulted.Target, defaulted.Architecture = "cs9", "qemu", "arm64"
+		defaulted.ExportFormat, defaulted.Mode, defaulted.Compression = "image", "image", "gzip"
+		defaulted.ManifestFileName = "manifest.aib.yml"
+		hash := buildContentHash(defaulted)
+
+		started := metav1.NewTime(time.Now().Add(-3 * time.Hour))
+		completed := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		build := &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "gone", Labels: map[string]string{contentHashLabel: hash}},
+			Status:     automotivev1.ImageBuildStatus{StartTime: &started, CompletionTime: &completed},
+		}
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		promoted := &PromoteResponse{Image: "gone", Namespace: "prod", URL: "quay.io/org/img:1.0", Digest: "sha256:abc",
+			PromotedAt: completed.UTC().Format(time.RFC3339)}
+		Expect(svc.(*buildService).indexPromotedImage(ctx, build, promoted)).To(Succeed())
+		Expect(cluster.configMaps[cacheIndexConfigMap].Data).To(HaveKey(hash))
+
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "invalid", Manifest: "content: {}", Cache: "refresh"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "invalid", Manifest: "content: {}", Cache: "bypass", ReuseExisting: true}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		// the image must still exist for the entry to answer
+		misses := cacheLookups(cluster.Namespace(), cacheMiss)
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			FeatureGates: map[string]bool{"enableResultCache": true},
+		}}
+		resp, err := svc.CreateBuild(ctx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeFalse())
+		Expect(cacheLookups(cluster.Namespace(), cacheMiss)).To(Equal(misses + 1))
+
+		cluster.images = []automotivev1.Image{{
+			ObjectMeta: metav1.ObjectMeta{Name: "gone", Namespace: "prod"},
+			Spec: automotivev1.ImageSpec{Location: automotivev1.ImageLocation{
+				Type:     "registry",
+				Registry: &automotivev1.RegistryLocation{URL: "quay.io/org/img:1.0", Digest: "sha256:abc"},
+			}},
+		}}
+		hits, saved := cacheLookups(cluster.Namespace(), cacheHit), cacheSavedSeconds(cluster.Namespace())
+		req.Name = "again-2"
+		resp, err = svc.CreateBuild(ctx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeTrue())
+		Expect(resp.Name).To(Equal("gone"))
+		Expect(resp.CachedImage).To(Equal(&CachedImage{Name: "gone", Namespace: "prod", URL: "quay.io/org/img:1.0", Digest: "sha256:abc"}))
+		Expect(cacheLookups(cluster.Namespace(), cacheHit)).To(Equal(hits + 1))
+		Expect(cacheSavedSeconds(cluster.Namespace())).To(BeNumerically("~", saved+3600, 1))
+
+		bypassed := cacheLookups(cluster.Namespace(), cacheBypass)
+		req.Name, req.Cache = "again-3", "bypass"
+		resp, err = svc.CreateBuild(ctx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeFalse())
+		Expect(cacheLookups(cluster.Namespace(), cacheBypass)).To(Equal(bypassed + 1))
+
+		// a revoked image no longer answers
+		cluster.images[0].Spec.Lifecycle = automotivev1.ImageLifecycleRevoked
+		req.Name, req.Cache = "again-4", ""
+		resp, err = svc.CreateBuild(ctx, req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Reused).To(BeFalse())
+	})
+
+	It("should keep the newest entries of a full cache index", func() {
+		data := map[string]string{}
+		for i := range maxCacheEntries + 2 {
+			raw, _ := json.Marshal(cacheEntry{CachedAt: time.Unix(int64(i), 0).UTC().Format(time.RFC3339)})
+			data[fmt.Sprintf("hash-%d", i)] = string(raw)
+		}
+		pruneCacheIndex(data)
+		Expect(data).To(HaveLen(maxCacheEntries))
+		Expect(data).NotTo(HaveKey("hash-0"))
+		Expect(data).NotTo(HaveKey("hash-1"))
+		Expect(data).To(HaveKey("hash-2"))
+	})
+
+	It("should answer a retried request with the build its Idempotency-Key created", func() {
+		cluster.builds["running"].Labels = map[string]string{idempotencyKeyLabel: idempotencyKeyHash("key-1", "alice")}
+
+		resp, err := svc.CreateBuild(ctx, BuildRequest{GenerateName: "nightly-", Manifest: "m", IdempotencyKey: "key-1"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Replayed).To(BeTrue())
+		Expect(resp.Name).To(Equal("running"))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "other", Manifest: "m", IdempotencyKey: "key-1"}, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+
+		// keys are scoped to the requester
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "running", Manifest: "m", IdempotencyKey: "key-1"}, "bob")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		Expect(err).To(MatchError("ImageBuild running already exists"))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: "m", IdempotencyKey: "bad\nkey"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+	})
+
+	It("should generate unused build names from generateName", func() {
+		name, err := svc.(*buildService).generateBuildName(ctx, "nightly-")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(MatchRegexp(`^nightly-[bcdfghjklmnpqrstvwxz2456789]{5}$`))
+		Expect(cluster.builds).NotTo(HaveKey(name))
+
+		_, err = svc.(*buildService).generateBuildName(ctx, "Nightly_")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "b", GenerateName: "b-", Manifest: "m"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+	})
+
+	It("should not reuse builds whose artifact expired or was blocked", func() {
+		completed := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		build := &automotivev1.ImageBuild{
+			Spec: automotivev1.ImageBuildSpec{ServeArtifact: true, ServeExpiryHours: 1},
+			Status: automotivev1.ImageBuildStatus{
+				Phase:            "Completed",
+				CompletionTime:   &completed,
+				ArtifactFileName: "disk.raw.gz",
+			},
+		}
+		Expect(servesArtifact(build, time.Now())).To(BeFalse())
+		build.Spec.ServeExpiryHours = 3
+		Expect(servesArtifact(build, time.Now())).To(BeTrue())
+		build.Status.Scan = &automotivev1.ScanResult{Blocked: true}
+		Expect(servesArtifact(build, time.Now())).To(BeFalse())
+	})
+
+	It("should lint manifests against the AutomotiveDev's rules and refuse builds violating blocking ones", func() {
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{LintRulesConfigMap: "lint-rules"},
+		}}
+		cluster.configMaps = map[string]*corev1.ConfigMap{"lint-rules": {Data: map[string]string{"rules.yaml": `
+rules:
+- name: no-remote-shells
+  type: forbiddenPackages
+  packages: [telnet*, rsh]
+  action: block
+- type: selinuxEnforcing
+  action: block
+- type: maxImageSize
+  maxSize: 8 GiB
+- type: pinnedContainers
+`}}}
+		manifest := `
+content:
+  rpms: [vim, telnet-server]
+  container_images:
+    - source: quay.io/example/app
+      tag: latest
+    - source: quay.io/example/pinned
+      digest: sha256:abc
+image:
+  selinux_mode: permissive
+  image_size: 16G
+`
+		resp, err := svc.LintManifests(ctx, LintRequest{Manifest: manifest, CustomDefs: []string{"image_size=4G"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Blocked).To(BeTrue())
+		var rules []string
+		for _, v := range resp.Violations {
+			rules = append(rules, v.Rule)
+		}
+		Expect(rules).To(Equal([]string{"no-remote-shells", "selinuxEnforcing", "maxImageSize", "pinnedContainers"}))
+		Expect(resp.Violations[3].Message).To(ContainSubstring("quay.io/example/app:latest"))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "b", Manifest: manifest}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("telnet-server"))
+		Expect(err.Error()).NotTo(ContainSubstring("pinned"))
+
+		resp, err = svc.LintManifests(ctx, LintRequest{Manifest: "content:\n  rpms: [vim]\n"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Violations).To(BeEmpty())
+		Expect(resp.Blocked).To(BeFalse())
+	})
+
+	It("should refuse lint rules it does not understand", func() {
+		for _, rules := range []string{
+			"rules:\n- type: forbiddenPackages\n",
+			"rules:\n- type: maxImageSize\n  maxSize: huge\n",
+			"rules:\n- type: selinuxEnforcing\n  action: fail\n",
+			"rules:\n- type: noSuchRule\n",
+			"rules:\n- type: selinuxEnforcing\n  severity: high\n",
+		} {
+			_, err := parseLintRules(rules)
+			Expect(err).To(HaveOccurred(), rules)
+		}
+		Expect(parseSize("8 GiB")).To(Equal(int64(8 << 30)))
+		Expect(parseSize("8GB")).To(Equal(int64(8_000_000_000)))
+		Expect(parseSize("512")).To(Equal(int64(512)))
+	})
+
+	It("should extend the catalog with the AutomotiveDev's values", func() {
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{Catalog: &automotivev1.BuildCatalog{
+				Distros: []string{"mydistro", "cs9"},
+				Targets: []string{"myboard"},
+			}},
+		}}
+		catalog, err := svc.Catalog(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(catalog.Distros).To(ContainElements("mydistro", "cs9", "autosd"))
+		Expect(catalog.Distros).To(HaveLen(len(defaultDistros) + 1))
+		Expect(catalog.Targets).To(ContainElements("myboard", "qemu"))
+		Expect(catalog.Validate("mydistro", "myboard", "arm64")).To(Succeed())
+	})
+
+	It("should merge the selected profile into the build", func() {
+		catalog, err := svc.Catalog(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		profile, err := catalog.Profile("ridesx4")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(catalog.Validate("cs9", profile.Target, profile.Architecture)).To(Succeed())
+		_, err = catalog.Profile("ridesx")
+		Expect(err).To(MatchError(`unknown profile "ridesx" (did you mean ridesx4?)`))
+
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{Profiles: []automotivev1.BuildProfile{{
+				Name: "ridesx4", Target: "ridesx4", Architecture: "arm64", ExportFormat: "aboot.simg",
+				AIBExtraArgs: []string{"--fusa"}, CustomDefs: []string{"image_size=8G"},
+			}}},
+		}}
+		resp, err := svc.CreateBuild(ctx, BuildRequest{
+			Name: "board", Manifest: "m", Profile: "ridesx4", ExportFormat: "image",
+			AIBExtraArgs: []string{"--verbose"}, CustomDefs: []string{"image_size=16G"},
+		}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Profile).To(Equal("ridesx4"))
+		build := cluster.builds["board"]
+		Expect(build.Spec.Profile).To(Equal("ridesx4"))
+		Expect(build.Spec.Target).To(Equal("ridesx4"))
+		Expect(build.Spec.Architecture).To(Equal("arm64"))
+		Expect(build.Spec.ExportFormat).To(Equal("image"))
+		cm := cluster.configMaps[build.Spec.ManifestConfigMap]
+		Expect(strings.Fields(cm.Data["aib-extra-args.txt"])).To(Equal([]string{"--fusa", "--verbose"}))
+		Expect(cm.Data["custom-definitions.env"]).To(Equal("image_size=8G\nimage_size=16G"))
+
+		got, err := svc.GetBuild(ctx, "board")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Profile).To(Equal("ridesx4"))
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "other", Manifest: "m", Profile: "nosuchboard"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+	})
+
+	It("should only accept AIB args the AutomotiveDev allows", func() {
+		for _, req := range []BuildRequest{
+			{Name: "b", Manifest: "m", AIBExtraArgs: []string{"--build-dir=/etc"}},
+			{Name: "b", Manifest: "m", AIBExtraArgs: []string{"--verbose", "x;rm", "-rf"}},
+			{Name: "b", Manifest: "m", AIBExtraArgs: []string{"--define=A=$(id)"}},
+			{Name: "b", Manifest: "m", AIBExtraArgs: []string{"--define", "A=1 B=2"}},
+			{Name: "b", Manifest: "m", AIBExtraArgs: []string{"manifest.aib.yml"}},
+			{Name: "b", Manifest: "m", AIBOverrideArgs: []string{"--export", "image", "--cache=/tmp"}},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrUnprocessable)).To(BeTrue(), "request %+v", req)
+			Expect(statusForError(err)).To(Equal(http.StatusUnprocessableEntity))
+		}
+		Expect(validateAIBArgs("aibOverrideArgs", []string{"--export", "qcow2", "--define=A=1", "--fusa"},
+			defaultAllowedAIBArgs)).To(Succeed())
+
+		// list and map values of --define keep their quotes and brackets
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		listArgs := []string{
+			"--define", `extra_rpms=["vim-enhanced","git"]`, "--define=extra_repos=[{'id':'epel','baseurl':'https://example.com/epel'}]",
+		}
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "lists", Manifest: "m", AIBExtraArgs: listArgs}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		cm := cluster.configMaps[cluster.builds["lists"].Spec.ManifestConfigMap]
+		Expect(strings.Fields(cm.Data["aib-extra-args.txt"])).To(Equal(listArgs))
+
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{AllowedAIBArgs: []string{"--cache"}},
+		}}
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "c", Manifest: "m", AIBExtraArgs: []string{"--fusa"}}, "alice")
+		Expect(err).To(MatchError(`aibExtraArgs[0]: flag "--fusa" is not allowed (allowed: --cache)`))
+		allowed, err := svc.(*buildService).allowedAIBArgs(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validateAIBArgs("aibExtraArgs", []string{"--cache=/var/cache"}, allowed)).To(Succeed())
+	})
+
+	DescribeTable("AIB args",
+		func(args []string, allowed []string, rejected string) {
+			if allowed == nil {
+				allowed = defaultAllowedAIBArgs
+			}
+			err := validateAIBArgs("aibExtraArgs", args, allowed)
+			if rejected == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(ContainSubstring(rejected)))
+		},
+		Entry("a value after a flag taking one", []string{"--export", "qcow2", "--mode", "image"}, nil, ""),
+		Entry("a value after =", []string{"--define=A=1", "--arch=arm64"}, nil, ""),
+		Entry("switches", []string{"--verbose", "--fusa"}, nil, ""),
+		Entry("a word after a switch", []string{"--fusa", "/etc/x"}, nil, `aibExtraArgs[1] "/etc/x": not a flag nor the value of one`),
+		Entry("a word after --verbose", []string{"--verbose", "extra.aib.yml"}, nil, "not a flag nor the value of one"),
+		Entry("a switch given a value", []string{"--verbose=/etc/x"}, nil, `aibExtraArgs[0]: flag "--verbose" takes no value`),
+		Entry("a flag missing its value at the end", []string{"--verbose", "--define"}, nil, `aibExtraArgs[1]: flag "--define" needs a value`),
+		Entry("a flag missing its value before another", []string{"--export", "--fusa"}, nil, `aibExtraArgs[0]: flag "--export" needs a value`),
+		Entry("--define-file by default", []string{"--define-file", "/etc/shadow"}, nil, `flag "--define-file" is not allowed`),
+		Entry("--include by default", []string{"--include=/var/run/secrets"}, nil, `flag "--include" is not allowed`),
+		Entry("--define-file when the AutomotiveDev allows it", []string{"--define-file", "defs.yml"}, []string{"--define-file"}, ""),
+		Entry("a value after a switch the AutomotiveDev allows", []string{"--dry-run", "x"}, []string{"--dry-run"}, "not a flag nor the value of one"),
+	)
+
+	It("should only accept export formats unprivileged builds can write when the cluster runs them", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{Unprivileged: true},
+		}}
+		for _, req := range []BuildRequest{
+			{Name: "b", Manifest: "m", ExportFormat: "image"},
+			{Name: "b", Manifest: "m", ExportFormat: "tar", AIBExtraArgs: []string{"--export=qcow2"}},
+			{Name: "b", Manifest: "m", ExportFormat: "tar", AIBOverrideArgs: []string{"--export", "image"}},
+		} {
+			_, err := svc.CreateBuild(ctx, req, "alice")
+			Expect(errors.Is(err, ErrUnprocessable)).To(BeTrue(), "request %+v", req)
+			Expect(err).To(MatchError(ContainSubstring("needs loop devices")))
+		}
+
+		_, err := svc.CreateBuild(ctx, BuildRequest{Name: "rootless", Manifest: "m", ExportFormat: "tar"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "rootless-container", Manifest: "m", ExportFormat: "image",
+			AIBExtraArgs: []string{"--export", "container"}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should size the workspace from the manifests unless the request does", func() {
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+		manifest := "image:\n  image_size: 8 GiB\ncontent:\n  rpms: [a, b, c, d, e, f, g, h, i, j]\n"
+
+		resp, err := svc.CreateBuild(ctx, BuildRequest{Name: "estimated", Manifest: manifest, ExportFormat: "qcow2"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		// (2 GiB base + 10 packages x 20 MiB + 2 x 8 GiB) with a quarter of headroom
+		Expect(resp.WorkspaceSize).To(Equal("23Gi"))
+		Expect(resp.WorkspaceSizeEstimate).To(Equal("image_size 8Gi x2 for the qcow2 export, 10 packages"))
+		Expect(cluster.builds["estimated"].Spec.WorkspaceSize).To(Equal("23Gi"))
+
+		resp, err = svc.CreateBuild(ctx, BuildRequest{Name: "defined", Manifest: manifest, ExportFormat: "tar",
+			CustomDefs: []string{"image_size=2G"}}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.WorkspaceSize).To(Equal("6Gi"))
+
+		resp, err = svc.CreateBuild(ctx, BuildRequest{Name: "explicit", Manifest: manifest, WorkspaceSize: "40Gi"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.WorkspaceSize).To(Equal("40Gi"))
+		Expect(resp.WorkspaceSizeEstimate).To(BeEmpty())
+
+		resp, err = svc.CreateBuild(ctx, BuildRequest{Name: "no-hints", Manifest: "name: minimal\n"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.WorkspaceSize).To(BeEmpty())
+
+		_, err = svc.CreateBuild(ctx, BuildRequest{Name: "invalid", Manifest: manifest, WorkspaceSize: "lots"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		build := cluster.builds["estimated"]
+		build.Status.WorkspaceSize = "23Gi"
+		build.Status.ResourceUsage = &automotivev1.ResourceUsage{WorkspaceBytes: 3 << 30, PrunedWorkspaceBytes: 9 << 30}
+		got, err := svc.GetBuild(ctx, "estimated")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.WorkspaceSize).To(Equal("23Gi"))
+		Expect(got.WorkspacePeakBytes).To(Equal(int64(12 << 30)))
+	})
+
+	It("should report the resource usage of finished builds", func() {
+		_, err := svc.BuildUsage(ctx, "running")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		_, err = svc.BuildUsage(ctx, "done")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+		cluster.builds["done"].Status.ResourceUsage = &automotivev1.ResourceUsage{
+			PeakMemoryBytes: 6 << 30,
+			CPUSeconds:      1800,
+			DiskBytes:       12 << 30,
+		}
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{UseMemoryVolumes: true, MemoryVolumeSize: "16Gi"},
+		}}
+		usage, err := svc.BuildUsage(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*usage).To(Equal(BuildUsageResponse{
+			Name:             "done",
+			PeakMemoryBytes:  6 << 30,
+			CPUSeconds:       1800,
+			DiskBytes:        12 << 30,
+			MemoryVolumeSize: "16Gi",
+		}))
+	})
+
+	It("should suggest a workspace PVC size from what the build held before pruning", func() {
+		cluster.builds["done"].Status.ResourceUsage = &automotivev1.ResourceUsage{
+			WorkspaceBytes:       2 << 30,
+			PrunedWorkspaceBytes: 6 << 30,
+		}
+		usage, err := svc.BuildUsage(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage.WorkspaceBytes).To(Equal(int64(2 << 30)))
+		Expect(usage.PrunedWorkspaceBytes).To(Equal(int64(6 << 30)))
+		Expect(usage.PVCSize).To(Equal("8Gi"))
+		Expect(usage.SuggestedPVCSize).To(Equal("10Gi"))
+
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{PVCSize: "20Gi"},
+		}}
+		usage, err = svc.BuildUsage(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(usage.PVCSize).To(Equal("20Gi"))
+		Expect(usage.SuggestedPVCSize).To(Equal("10Gi"))
+	})
+
+	It("should rebuild the main and additional manifests of a build", func() {
+		cluster.builds["done"].Spec.ManifestConfigMap = "done-manifest"
+		cluster.builds["done"].Spec.ManifestFile = "main.aib.yml"
+		cluster.builds["done"].Spec.BuildInfo = &automotivev1.BuildInfo{Enabled: true, GitRef: "v1.2"}
+		cluster.configMaps = map[string]*corev1.ConfigMap{"done-manifest": {Data: map[string]string{
+			"aib-extra-args.txt": "--verbose",
+			"common.aib.yml":     "content:\n  add_files:\n    - path: /etc/radio.conf\n      source_path: radio.conf\n",
+			"main.aib.yml":       "name: main\n",
+		}}}
+
+		tpl, err := svc.GetBuildTemplate(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tpl.ManifestFileName).To(Equal("main.aib.yml"))
+		Expect(tpl.Manifest).To(Equal("name: main\n"))
+		Expect(tpl.AdditionalManifests).To(HaveLen(1))
+		Expect(tpl.AdditionalManifests[0].Name).To(Equal("common.aib.yml"))
+		Expect(tpl.SourceFiles).To(ConsistOf("radio.conf"))
+		Expect(tpl.BuildInfo).To(BeTrue())
+		Expect(tpl.GitRef).To(Equal("v1.2"))
+	})
+
+	It("should filter builds by label and expose only user labels", func() {
+		items, err := svc.ListBuilds(ctx, map[string]string{"team": "infotainment"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(items).To(HaveLen(1))
+		Expect(items[0].Name).To(Equal("done"))
+		Expect(items[0].Labels).To(Equal(map[string]string{"team": "infotainment"}))
+	})
+
+	It("should resume logs from a step and byte offset", func() {
+		cluster.pod = &corev1.Pod{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "step-prepare"}, {Name: "step-build"}, {Name: "step-push"},
+			}},
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+		}
+		cluster.logs = map[string]string{
+			"step-prepare": "prepared\n",
+			"step-build":   "line one\nline two\n",
+			"step-push":    "pushed\n",
+		}
+
+		sink := &recordingLogSink{logs: map[string]string{}}
+		Expect(svc.FollowLogs(ctx, "pod", LogOptions{Step: "build", SinceBytes: 9}, sink)).To(Succeed())
+		Expect(sink.steps).To(Equal([]string{"build", "push"}))
+		Expect(sink.logs).To(Equal(map[string]string{"build": "line two\n", "push": "pushed\n"}))
+
+		err := svc.FollowLogs(ctx, "pod", LogOptions{Step: "missing"}, sink)
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	It("should reopen a broken log stream after the bytes already streamed", func() {
+		cluster.pod = &corev1.Pod{
+			Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "step-build"}}},
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+		}
+		cluster.logs = map[string]string{"step-build": "line one\nline two\nline three\n"}
+
+		cluster.logBreaks = map[string]int{"step-build": 14}
+		sink := &recordingLogSink{logs: map[string]string{}}
+		Expect(svc.FollowLogs(ctx, "pod", LogOptions{}, sink)).To(Succeed())
+		Expect(sink.steps).To(Equal([]string{"build"}))
+		Expect(sink.logs).To(Equal(map[string]string{"build": "line one\nline two\nline three\n"}))
+
+		By("resuming within the skipped bytes of a resumed stream")
+		cluster.logBreaks = map[string]int{"step-build": 4}
+		sink = &recordingLogSink{logs: map[string]string{}}
+		Expect(svc.FollowLogs(ctx, "pod", LogOptions{Step: "build", SinceBytes: 9}, sink)).To(Succeed())
+		Expect(sink.logs).To(Equal(map[string]string{"build": "line two\nline three\n"}))
+	})
+
+	It("should archive the logs of every step of a finished build", func() {
+		_, err := svc.OpenLogsArchive(ctx, "running")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		_, err = svc.OpenLogsArchive(ctx, "done")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+		cluster.builds["done"].Status.TaskRunName = "done-tr"
+		_, err = svc.OpenLogsArchive(ctx, "done")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "done-tr-pod"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "step-build"}, {Name: "step-push"},
+			}},
+		}
+		cluster.logs = map[string]string{"step-build": "built\n", "step-push": "pushed\n"}
+		archive, err := svc.OpenLogsArchive(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(archive.FileName).To(Equal("done-logs.tar.gz"))
+
+		var buf bytes.Buffer
+		Expect(archive.WriteTo(ctx, &buf)).To(Succeed())
+		gz, err := gzip.NewReader(&buf)
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gz)
+		files := map[string]string{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			content, err := io.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			files[hdr.Name] = string(content)
+		}
+		Expect(files).To(Equal(map[string]string{"build.log": "built\n", "push.log": "pushed\n"}))
+	})
+
+	It("should push a promoted artifact and catalog it in the target namespace", func() {
+		blobs := map[string]string{}
+		var manifest registry.Manifest
+		reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodHead:
+				w.WriteHeader(http.StatusNotFound)
+			case r.Method == http.MethodPost && r.URL.Path == "/v2/org/img/blobs/uploads/":
+				w.Header().Set("Location", "/upload/1?state=x")
+				w.WriteHeader(http.StatusAccepted)
+			case r.Method == http.MethodPut && r.URL.Path == "/upload/1":
+				body, _ := io.ReadAll(r.Body)
+				digest := fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+				if r.URL.Query().Get("digest") != digest || r.URL.Query().Get("state") != "x" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				blobs[digest] = string(body)
+				w.WriteHeader(http.StatusCreated)
+			case r.Method == http.MethodPut && r.URL.Path == "/v2/org/img/manifests/1.0":
+				Expect(json.NewDecoder(r.Body).Decode(&manifest)).To(Succeed())
+				w.WriteHeader(http.StatusCreated)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer reg.Close()
+		url := strings.TrimPrefix(reg.URL, "http://") + "/org/img:1.0"
+
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "artifact-pod"}}
+		content := "disk image"
+		Expect(os.WriteFile(filepath.Join(cluster.root, "cs9-qemu.qcow2.gz"), []byte(content), 0o644)).To(Succeed())
+		sum := sha256.Sum256([]byte(content))
+		cluster.builds["done"].Spec = automotivev1.ImageBuildSpec{Distro: "cs9", Target: "qemu", Architecture: "arm64", ExportFormat: "qcow2"}
+		cluster.builds["done"].Status.ArtifactFileName = "cs9-qemu.qcow2.gz"
+		cluster.builds["done"].Status.ArtifactSize = int64(len(content))
+		cluster.builds["done"].Status.ArtifactSHA256 = hex.EncodeToString(sum[:])
+
+		cluster.builds["done"].Labels[contentHashLabel] = "0123abcd"
+		cluster.configMaps = map[string]*corev1.ConfigMap{}
+
+		req := PromoteRequest{TargetNamespace: "prod", Registry: PromoteRegistry{URL: url, Insecure: true}}
+		_, err := svc.PromoteBuild(ctx, "running", req, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+		_, err = svc.PromoteBuild(ctx, "done", PromoteRequest{TargetNamespace: cluster.Namespace(), Registry: req.Registry}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		resp, err := svc.PromoteBuild(ctx, "done", req, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Image).To(Equal("done"))
+		Expect(blobs).To(HaveKeyWithValue("sha256:"+hex.EncodeToString(sum[:]), content))
+		Expect(blobs).To(HaveKeyWithValue(manifest.Config.Digest, "{}"))
+		Expect(manifest.Layers).To(HaveLen(1))
+		Expect(manifest.Layers[0].MediaType).To(Equal(oci.MediaTypeQcow2))
+		Expect(manifest.Annotations).To(HaveKeyWithValue(oci.AnnotationImageBuild, "done"))
+
+		image, err := cluster.GetImage(k8s.WithNamespace(ctx, "prod"), "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image.Spec.Location.Registry.URL).To(Equal(url))
+		Expect(image.Spec.Location.Registry.Digest).To(Equal(resp.Digest))
+		Expect(image.Spec.Metadata.SourceImageBuild).To(Equal("done"))
+		Expect(image.Annotations).To(HaveKeyWithValue(promotedFromNamespaceAnnotation, cluster.Namespace()))
+		Expect(image.Annotations).To(HaveKeyWithValue(promotedByAnnotation, "alice"))
+		Expect(cluster.configMaps[cacheIndexConfigMap].Data).To(HaveKey("0123abcd"))
+
+		_, err = svc.PromoteBuild(ctx, "done", req, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+	})
+
+	It("should request conversions of served disk images and report their progress", func() {
+		cluster.builds["done"].Status.ArtifactFileName = "cs9-qemu.raw.gz"
+		_, err := svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "vmdk"}, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+
+		cluster.builds["done"].Spec.ServeArtifact = true
+		_, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "iso"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.ConvertArtifact(ctx, "running", ConvertRequest{Format: "vmdk"}, "alice")
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+
+		conversion, err := svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "VMDK"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conversion.Phase).To(Equal(automotivev1.ConversionPending))
+		_, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "vmdk"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["done"].Spec.Conversions).To(Equal([]string{"vmdk"}))
+
+		cluster.builds["done"].Status.Conversions = []automotivev1.ArtifactConversion{{
+			Format: "vmdk", Phase: automotivev1.ConversionCompleted, FileName: "cs9-qemu.vmdk", Size: 42,
+		}}
+		conversion, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "vmdk"}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conversion.FileName).To(Equal("cs9-qemu.vmdk"))
+		resp, err := svc.GetBuild(ctx, "done")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Conversions).To(HaveLen(1))
+		Expect(resp.Conversions[0].Size).To(Equal(int64(42)))
+
+		cluster.builds["done"].Status.ArtifactFileName = "cs9-qemu.tar"
+		_, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "qcow2"}, "alice")
+		Expect(errors.Is(err, ErrUnprocessable)).To(BeTrue())
+		cluster.builds["done"].Status.ArtifactFileName = "cs9-qemu.qcow2"
+		_, err = svc.ConvertArtifact(ctx, "done", ConvertRequest{Format: "qcow2"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+	})
+
+	It("should summarize builds created within the window", func() {
+		now := time.Now()
+		build := func(name, distro, phase string, age, duration time.Duration) *automotivev1.ImageBuild {
+			b := &automotivev1.ImageBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+				Spec:       automotivev1.ImageBuildSpec{Distro: distro, Target: "qemu"},
+				Status:     automotivev1.ImageBuildStatus{Phase: phase},
+			}
+			if duration > 0 {
+				start := metav1.NewTime(now.Add(-age))
+				end := metav1.NewTime(start.Add(duration))
+				b.Status.StartTime, b.Status.CompletionTime = &start, &end
+			}
+			return b
+		}
+		cluster.builds = map[string]*automotivev1.ImageBuild{
+			"a": build("a", "autosd", "Completed", time.Hour, 10*time.Minute),
+			"b": build("b", "autosd", "Completed", 2*time.Hour, 20*time.Minute),
+			"c": build("c", "cs9", "Failed", 3*time.Hour, 30*time.Minute),
+			"d": build("d", "cs9", "", time.Minute, 0),
+			"e": build("e", "autosd", "Completed", 10*24*time.Hour, time.Minute),
+		}
+
+		st, err := svc.BuildStats(ctx, 7*24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.Total).To(Equal(4))
+		Expect(st.ByPhase).To(Equal(map[string]int{"Completed": 2, "Failed": 1, "Pending": 1}))
+		Expect(st.SuccessRate).To(BeNumerically("~", 2.0/3, 1e-9))
+		Expect(st.Durations.Count).To(Equal(3))
+		Expect(st.Durations.Average).To(BeNumerically("~", 1200, 1e-6))
+		Expect(st.Durations.P50).To(BeNumerically("~", 1200, 1e-6))
+		Expect(st.Durations.P99).To(BeNumerically("~", 1800, 1e-6))
+
+		Expect(st.ByDistro["autosd"]).To(Equal(BuildGroupStats{Total: 2, Completed: 2, SuccessRate: 1, AverageDuration: 900}))
+		Expect(st.ByDistro["cs9"].Failed).To(Equal(1))
+		Expect(st.ByTarget["qemu"].Total).To(Equal(4))
+		Expect(st.Costs).To(BeEmpty())
+	})
+
+	It("should sum the costs of builds per currency, namespace and requester", func() {
+		build := func(name, requester, total, currency string) *automotivev1.ImageBuild {
+			b := &automotivev1.ImageBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", CreationTimestamp: metav1.Now()},
+				Status:     automotivev1.ImageBuildStatus{Phase: "Completed"},
+			}
+			if requester != "" {
+				b.Annotations = map[string]string{"automotive.sdv.cloud.redhat.com/requested-by": requester}
+			}
+			if total != "" {
+				b.Status.Cost = &automotivev1.BuildCost{Total: total, Currency: currency}
+			}
+			return b
+		}
+		cluster.builds = map[string]*automotivev1.ImageBuild{
+			"a": build("a", "alice", "0.1000", "USD"),
+			"b": build("b", "alice", "0.2000", "USD"),
+			"c": build("c", "", "0.0500", "USD"),
+			"d": build("d", "bob", "1.5000", "EUR"),
+			"e": build("e", "bob", "", ""),
+		}
+
+		st, err := svc.BuildStats(ctx, 7*24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(st.Costs).To(Equal([]CostStats{
+			{
+				Currency: "EUR", Builds: 1, Total: 1.5,
+				ByNamespace: map[string]float64{"team-a": 1.5}, ByRequester: map[string]float64{"bob": 1.5},
+			},
+			{
+				Currency: "USD", Builds: 3, Total: 0.35,
+				ByNamespace: map[string]float64{"team-a": 0.35}, ByRequester: map[string]float64{"alice": 0.3, "unknown": 0.05},
+			},
+		}))
+
+		cluster.builds["a"].Status.Cost.Compute = "0.0900"
+		resp, err := svc.GetBuild(ctx, "a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Cost).To(Equal(&BuildCost{Total: "0.1000", Compute: "0.0900", Currency: "USD"}))
+	})
+
+	It("should sum the live workspaces per requester against the storage limit", func() {
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{MaxWorkspaceStorage: "32Gi"},
+		}}
+		cluster.builds = map[string]*automotivev1.ImageBuild{
+			"a": {ObjectMeta: metav1.ObjectMeta{Name: "a", Annotations: map[string]string{"automotive.sdv.cloud.redhat.com/requested-by": "alice"}}},
+			"b": {ObjectMeta: metav1.ObjectMeta{Name: "b", Annotations: map[string]string{"automotive.sdv.cloud.redhat.com/requested-by": "alice"}}},
+			"c": {ObjectMeta: metav1.ObjectMeta{Name: "c"}},
+		}
+		workspace := func(build, request, capacity string) corev1.PersistentVolumeClaim {
+			pvc := corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: build + "-ws", Labels: storage.WorkspaceLabels(build)},
+				Spec: corev1.PersistentVolumeClaimSpec{Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(request)},
+				}},
+			}
+			if capacity != "" {
+				pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)}
+			}
+			return pvc
+		}
+		deleting := workspace("gone", "8Gi", "")
+		deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		cluster.pvcs = []corev1.PersistentVolumeClaim{
+			workspace("a", "8Gi", "10Gi"),
+			workspace("b", "8Gi", ""),
+			workspace("c", "4Gi", ""),
+			deleting,
+			{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Labels: map[string]string{"app": "db"}}},
+		}
+
+		q, err := svc.StorageQuota(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q.Namespace).To(Equal(cluster.Namespace()))
+		Expect(q.Workspaces).To(Equal(3))
+		Expect(q.UsedBytes).To(Equal(int64(22 << 30)))
+		Expect(q.LimitBytes).To(Equal(int64(32 << 30)))
+		Expect(q.ByRequester).To(Equal(map[string]StorageUsage{
+			"alice":   {UsedBytes: 18 << 30, Workspaces: 2},
+			"unknown": {UsedBytes: 4 << 30, Workspaces: 1},
+		}))
+	})
+
+	It("should cancel builds abandoned while uploading and report what they held", func() {
+		cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{
+			BuildConfig: &automotivev1.BuildConfig{UploadTimeoutMinutes: 60},
+		}}
+		cluster.pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "stale-upload-pod"}}
+		uploading := func(name string, created time.Time, annotations map[string]string) *automotivev1.ImageBuild {
+			return &automotivev1.ImageBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created), Annotations: annotations},
+				Status:     automotivev1.ImageBuildStatus{Phase: "Uploading"},
+			}
+		}
+		progress := map[string]string{}
+		uploadprogress.Progress{ReceivedBytes: 1 << 20, ReceivedFiles: 1, UpdatedAt: time.Now().Add(-10 * time.Minute)}.Annotate(progress)
+		cluster.builds = map[string]*automotivev1.ImageBuild{
+			"stale": uploading("stale", time.Now().Add(-3*time.Hour),
+				map[string]string{"automotive.sdv.cloud.redhat.com/requested-by": "alice"}),
+			"slow":       uploading("slow", time.Now().Add(-3*time.Hour), progress),
+			"fresh":      uploading("fresh", time.Now().Add(-5*time.Minute), nil),
+			"cancelling": uploading("cancelling", time.Now().Add(-3*time.Hour), map[string]string{"automotive.sdv.cloud.redhat.com/cancel-requested-by": "bob"}),
+			"building": {
+				ObjectMeta: metav1.ObjectMeta{Name: "building", CreationTimestamp: metav1.NewTime(time.Now().Add(-3 * time.Hour))},
+				Status:     automotivev1.ImageBuildStatus{Phase: "Building"},
+			},
+		}
+		cluster.pvcs = []corev1.PersistentVolumeClaim{{
+			ObjectMeta: metav1.ObjectMeta{Name: "stale-ws", Labels: storage.WorkspaceLabels("stale")},
+			Status:     corev1.PersistentVolumeClaimStatus{Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("8Gi")}},
+		}}
+
+		dry, err := svc.SweepUploads(ctx, "admin", true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dry.DryRun).To(BeTrue())
+		Expect(dry.Builds).To(HaveLen(1))
+		Expect(cluster.builds["stale"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/cancel-requested-by"))
+
+		resp, err := svc.SweepUploads(ctx, "admin", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Namespace).To(Equal(cluster.Namespace()))
+		Expect(resp.Timeout).To(Equal("1h0m0s"))
+		Expect(resp.ReclaimedBytes).To(Equal(int64(8 << 30)))
+		Expect(resp.Builds).To(HaveLen(1))
+		swept := resp.Builds[0]
+		Expect(swept.Name).To(Equal("stale"))
+		Expect(swept.RequestedBy).To(Equal("alice"))
+		Expect(swept.Workspaces).To(Equal([]string{"stale-ws"}))
+		Expect(swept.WorkspaceBytes).To(Equal(int64(8 << 30)))
+		Expect(swept.UploadPod).To(Equal("stale-upload-pod"))
+		Expect(cluster.builds["stale"].Annotations["automotive.sdv.cloud.redhat.com/cancel-requested-by"]).
+			To(Equal("admin: no file received for 3h0m0s"))
+		Expect(cluster.builds["slow"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/cancel-requested-by"))
+		Expect(cluster.builds["fresh"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/cancel-requested-by"))
+
+		again, err := svc.SweepUploads(ctx, "admin", false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again.Builds).To(BeEmpty())
+	})
+
+	It("should assemble the timeline of a build from its status, TaskRun and recorded events", func() {
+		base := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+		at := func(minutes int) metav1.Time { return metav1.NewTime(base.Add(time.Duration(minutes) * time.Minute)) }
+		progress := map[string]string{"automotive.sdv.cloud.redhat.com/requested-by": "alice"}
+		uploadprogress.Progress{ReceivedBytes: 2 << 20, ReceivedFiles: 2, UpdatedAt: at(3).Time,
+			StartedAt: at(1).Time, CompletedAt: at(3).Time}.Annotate(progress)
+		completed := at(20)
+		cluster.builds = map[string]*automotivev1.ImageBuild{"qemu": {
+			ObjectMeta: metav1.ObjectMeta{Name: "qemu", CreationTimestamp: at(0), Annotations: progress},
+			Status: automotivev1.ImageBuildStatus{
+				Phase:          "Completed",
+				Message:        "Build completed successfully",
+				StartTime:      &metav1.Time{Time: at(4).Time},
+				CompletionTime: &completed,
+				TaskRunName:    "qemu-build-x7k2p",
+				Download:       &automotivev1.DownloadInfo{RouteURL: "https://artifacts.example.com/qemu", ExpiryTime: at(24 * 60)},
+				Conditions: []metav1.Condition{{Type: automotivev1.ImageBuildArtifactServing, Status: metav1.ConditionTrue,
+					LastTransitionTime: at(21), Message: "Artifact pod is serving"}},
+			},
+		}}
+		tr := &tektonv1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "qemu-build-x7k2p", CreationTimestamp: at(4)}}
+		tr.Status.PodName = "qemu-build-x7k2p-pod"
+		tr.Status.StartTime = &metav1.Time{Time: at(5).Time}
+		tr.Status.CompletionTime = &metav1.Time{Time: at(19).Time}
+		tr.Status.Steps = []tektonv1.StepState{
+			{Name: "build-image", ContainerState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: "Completed", StartedAt: at(6), FinishedAt: at(18)}}},
+		}
+		cluster.taskRuns = map[string]*tektonv1.TaskRun{tr.Name: tr}
+		cluster.events = []corev1.Event{
+			{ObjectMeta: metav1.ObjectMeta{UID: "e1"}, InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "qemu-build-x7k2p-pod"},
+				Reason: "Scheduled", Message: "Successfully assigned", FirstTimestamp: at(5)},
+			{ObjectMeta: metav1.ObjectMeta{UID: "e2"}, InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "qemu-build-x7k2p-pod"},
+				Type: corev1.EventTypeWarning, Reason: "BackOff", Message: "Back-off pulling image", Count: 3, FirstTimestamp: at(5)},
+			{ObjectMeta: metav1.ObjectMeta{UID: "e3"}, InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "other-pod"},
+				Reason: "Scheduled", FirstTimestamp: at(2)},
+		}
+
+		resp, err := svc.BuildTimeline(ctx, "qemu")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Name).To(Equal("qemu"))
+		var types []string
+		for _, e := range resp.Events {
+			types = append(types, e.Type)
+		}
+		Expect(types).To(Equal([]string{
+			"Created", "UploadsStarted", "UploadsFinished", "BuildStarted", "TaskRunCreated", "TaskRunStarted",
+			"Scheduled", "BackOff", "StepStarted", "StepFinished", "TaskRunFinished", "Completed", "ArtifactServing",
+			"ArtifactExpiry",
+		}))
+		Expect(resp.Events[0]).To(Equal(TimelineEvent{Time: "2026-10-15T09:00:00Z", Type: "Created", Source: "status",
+			Object: "ImageBuild/qemu", Message: "Requested by alice"}))
+		Expect(resp.Events[7]).To(Equal(TimelineEvent{Time: "2026-10-15T09:05:00Z", Type: "BackOff", Source: "event",
+			Object: "Pod/qemu-build-x7k2p-pod", Message: "Back-off pulling image (3 times)", Warning: true}))
+		Expect(resp.Events[9]).To(Equal(TimelineEvent{Time: "2026-10-15T09:18:00Z", Type: "StepFinished", Source: "taskrun",
+			Object: "TaskRun/qemu-build-x7k2p", Step: "build-image", Message: "Completed, exit code 0"}))
+		Expect(resp.Events[12].Message).To(Equal("Artifact pod is serving at https://artifacts.example.com/qemu"))
+	})
+
+	It("should verify uploads against the checksums the client sent", func() {
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "upload"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "fileserver"}}},
+		}
+		cluster.files = map[string]string{}
+		sum := func(s string) string {
+			h := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(h[:])
+		}
+		uploads := func(files ...*UploadFile) NextUploadFile {
+			return func() (*UploadFile, error) {
+				if len(files) == 0 {
+					return nil, io.EOF
+				}
+				f := files[0]
+				files = files[1:]
+				return f, nil
+			}
+		}
+
+		resp, err := svc.UploadFiles(ctx, "running", uploads(
+			&UploadFile{Path: "a.txt", Content: strings.NewReader("alpha"), Sha256: sum("alpha")},
+			&UploadFile{Path: "dir/b.txt", Content: strings.NewReader("beta")},
+			&UploadFile{Checksums: map[string]string{"dir/b.txt": strings.ToUpper(sum("beta"))}},
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Files).To(HaveLen(2))
+		Expect(resp.Files[0]).To(Equal(UploadFileResult{Path: "a.txt", Sha256: sum("alpha"), ExpectedSha256: sum("alpha"), Verified: true}))
+		Expect(resp.Files[1].Verified).To(BeTrue())
+		Expect(cluster.files).To(HaveKeyWithValue("/workspace/shared/dir/b.txt", "beta"))
+		// a later request may still bring more files, so only an explicit completion lets the build proceed
+		Expect(cluster.builds["running"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/uploads-complete"))
+
+		resp, err = svc.UploadFiles(ctx, "running", uploads(
+			&UploadFile{Path: "a.txt", Content: strings.NewReader("tampered"), Sha256: sum("alpha")},
+			&UploadFile{Checksums: map[string]string{"missing.txt": sum("x")}},
+		))
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("a.txt"))
+		Expect(err.Error()).To(ContainSubstring("missing.txt (not uploaded)"))
+		Expect(resp.Files[0].Verified).To(BeFalse())
+		Expect(resp.Files[0].Sha256).To(Equal(sum("tampered")))
+		Expect(cluster.builds["running"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/uploads-complete"))
+
+		// the files were verified as they arrived, so the completion lists none
+		_, err = svc.CompleteUploads(ctx, "running", map[string]string{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["running"].Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/uploads-complete", "true"))
+	})
+
+	It("should place uploads by the manifest part and keep accepting uploads without one", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "upload"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "fileserver"}}},
+		}
+		cluster.files = map[string]string{}
+		sum := func(s string) string {
+			h := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(h[:])
+		}
+		uploads := func(files ...*UploadFile) NextUploadFile {
+			return func() (*UploadFile, error) {
+				if len(files) == 0 {
+					return nil, io.EOF
+				}
+				f := files[0]
+				files = files[1:]
+				return f, nil
+			}
+		}
+		manifest := &UploadManifest{Entries: []UploadManifestEntry{
+			{Dest: "conf", Type: UploadEntryDir, Mode: "750"},
+			{Source: "0", Dest: `conf/odd "name";.sh`, Mode: "0755", Sha256: sum("#!/bin/sh")},
+			{Source: "1", Dest: "data/b.txt"},
+		}}
+
+		resp, err := svc.UploadFiles(ctx, "running", uploads(
+			&UploadFile{Manifest: manifest},
+			&UploadFile{Path: "1", Content: strings.NewReader("beta")},
+			&UploadFile{Path: "0", Content: strings.NewReader("#!/bin/sh")},
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Files).To(HaveLen(2))
+		Expect(resp.Files[1]).To(Equal(UploadFileResult{Path: `conf/odd "name";.sh`, Sha256: sum("#!/bin/sh"), ExpectedSha256: sum("#!/bin/sh"), Verified: true}))
+		Expect(cluster.files).To(HaveKeyWithValue("/workspace/shared/data/b.txt", "beta"))
+		info, err := os.Stat(filepath.Join(cluster.root, "conf"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o750)))
+		info, err = os.Stat(filepath.Join(cluster.root, "conf", `odd "name";.sh`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o755)))
+
+		// file parts must be listed and every listed file sent
+		_, err = svc.UploadFiles(ctx, "running", uploads(&UploadFile{Manifest: manifest}, &UploadFile{Path: "2", Content: strings.NewReader("x")}))
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.UploadFiles(ctx, "running", uploads(&UploadFile{Manifest: manifest}, &UploadFile{Path: "1", Content: strings.NewReader("x")}))
+		Expect(err).To(MatchError(ContainSubstring("no file part for manifest sources: 0")))
+		_, err = svc.UploadFiles(ctx, "running", uploads(&UploadFile{Path: "a.txt", Content: strings.NewReader("a")}, &UploadFile{Manifest: manifest}))
+		Expect(err).To(MatchError(ContainSubstring("first part")))
+
+		for _, entries := range [][]UploadManifestEntry{
+			{},
+			{{Source: "0", Dest: "../escape"}},
+			{{Source: "0", Dest: "a"}, {Source: "1", Dest: "a"}},
+			{{Source: "0", Dest: "a"}, {Source: "0", Dest: "b"}},
+			{{Dest: "a"}},
+			{{Source: "0", Dest: "a", Mode: "rwx"}},
+			{{Source: "0", Dest: "a", Mode: "1777"}},
+			{{Source: "0", Dest: "a", Type: "symlink"}},
+			{{Source: "0", Dest: "a", Type: UploadEntryDir}},
+			{{Source: "0", Dest: "a", Sha256: "abc"}},
+		} {
+			_, err = svc.UploadFiles(ctx, "running", uploads(&UploadFile{Manifest: &UploadManifest{Entries: entries}}))
+			Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue(), "%v", entries)
+		}
+
+		// old clients name the destination in the filename of the part
+		resp, err = svc.UploadFiles(ctx, "running", uploads(&UploadFile{Path: "legacy/c.txt", Content: strings.NewReader("gamma")}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Files[0].Path).To(Equal("legacy/c.txt"))
+	})
+
+	It("should resume chunked uploads and verify them on completion", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "upload"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "fileserver"}}},
+		}
+		sum := func(s string) string {
+			h := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(h[:])
+		}
+
+		f, err := svc.UploadedFile(ctx, "running", "dir/big.bin")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Size).To(BeZero())
+
+		f, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 0, strings.NewReader("hello "))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(Equal(&UploadedFile{Path: "dir/big.bin", Size: 6}))
+
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 10, strings.NewReader("gap"))
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+
+		// a half-written retry is replaced when the chunk is sent again from the same offset
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 6, strings.NewReader("wor"))
+		Expect(err).NotTo(HaveOccurred())
+		f, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 6, strings.NewReader("world"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Size).To(BeEquivalentTo(11))
+
+		_, err = svc.WriteUploadChunk(ctx, "running", "../escape", 0, strings.NewReader("x"))
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		resp, err := svc.CompleteUploads(ctx, "running", map[string]string{
+			"dir/big.bin": sum("hello world"),
+			"never.txt":   sum("x"),
+		})
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("never.txt (not uploaded)"))
+		Expect(resp.Files).To(ConsistOf(UploadFileResult{
+			Path: "dir/big.bin", Sha256: sum("hello world"), ExpectedSha256: sum("hello world"), Verified: true,
+		}))
+		Expect(cluster.builds["running"].Annotations).NotTo(HaveKey("automotive.sdv.cloud.redhat.com/uploads-complete"))
+
+		_, err = svc.CompleteUploads(ctx, "running", map[string]string{"dir/big.bin": sum("hello world")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.builds["running"].Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/uploads-complete", "true"))
+	})
+
+	It("should record the progress of uploads in the build", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "upload"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "fileserver"}}},
+		}
+		cluster.files = map[string]string{}
+		cluster.builds["running"].Status.Phase = "Uploading"
+		sum := func(s string) string {
+			h := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(h[:])
+		}
+
+		_, err := svc.SetUploadTotals(ctx, "running", UploadTotalsRequest{Files: -1})
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		p, err := svc.SetUploadTotals(ctx, "running", UploadTotalsRequest{Files: 2, Bytes: 20})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Percent).To(BeZero())
+
+		By("counting partly uploaded files but not the state of chunked uploads")
+		_, err = svc.StartUpload(ctx, "running", "dir/big.bin", 15, sum("hello big world"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 0, strings.NewReader("hello "))
+		Expect(err).NotTo(HaveOccurred())
+		build, err := svc.GetBuild(ctx, "running")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.UploadProgress).NotTo(BeNil())
+		Expect(build.UploadProgress.UpdatedAt).NotTo(BeEmpty())
+		build.UploadProgress.UpdatedAt = ""
+		Expect(*build.UploadProgress).To(Equal(UploadProgress{
+			ReceivedBytes: 6, ReceivedFiles: 1, TotalBytes: 20, TotalFiles: 2, Percent: 30,
+		}))
+
+		By("recording a chunk right after another only once a request ends")
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 6, strings.NewReader("big world"))
+		Expect(err).NotTo(HaveOccurred())
+		build, err = svc.GetBuild(ctx, "running")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(build.UploadProgress.ReceivedBytes).To(BeEquivalentTo(6))
+		_, err = svc.UploadFiles(ctx, "running", func() func() (*UploadFile, error) {
+			sent := false
+			return func() (*UploadFile, error) {
+				if sent {
+					return nil, io.EOF
+				}
+				sent = true
+				return &UploadFile{Path: "small.txt", Content: strings.NewReader("tiny!")}, nil
+			}
+		}())
+		Expect(err).NotTo(HaveOccurred())
+		items, err := svc.ListBuilds(ctx, nil)
+		Expect(err).NotTo(HaveOccurred())
+		i := slices.IndexFunc(items, func(it BuildListItem) bool { return it.Name == "running" })
+		Expect(i).To(BeNumerically(">=", 0))
+		Expect(items[i].UploadProgress.ReceivedBytes).To(BeEquivalentTo(20))
+		Expect(items[i].UploadProgress.ReceivedFiles).To(Equal(2))
+		Expect(items[i].UploadProgress.Percent).To(Equal(100))
+
+		_, err = svc.CompleteUploads(ctx, "running", map[string]string{"dir/big.bin": sum("hello big world")})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = svc.SetUploadTotals(ctx, "running", UploadTotalsRequest{Files: 1, Bytes: 1})
+		Expect(errors.Is(err, ErrConflict)).To(BeTrue())
+	})
+
+	It("should resume started uploads of the same content and discard files failing verification", func() {
+		cluster.root = GinkgoT().TempDir()
+		cluster.pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "upload"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "fileserver"}}},
+		}
+		sum := func(s string) string {
+			h := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(h[:])
+		}
+
+		f, err := svc.StartUpload(ctx, "running", "dir/big.bin", 11, sum("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f).To(Equal(&UploadedFile{Path: "dir/big.bin", Size: 0}))
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 0, strings.NewReader("hello "))
+		Expect(err).NotTo(HaveOccurred())
+
+		By("resuming after the stored bytes of the same file")
+		f, err = svc.StartUpload(ctx, "running", "dir/big.bin", 11, strings.ToUpper(sum("hello world")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Size).To(BeEquivalentTo(6))
+
+		By("starting over when the file changed")
+		f, err = svc.StartUpload(ctx, "running", "dir/big.bin", 11, sum("HELLO WORLD"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Size).To(BeZero())
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 0, strings.NewReader("HELLO world"))
+		Expect(err).NotTo(HaveOccurred())
+
+		res, err := svc.FinishUpload(ctx, "running", "dir/big.bin", sum("HELLO WORLD"))
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		Expect(res.Verified).To(BeFalse())
+		Expect(filepath.Join(cluster.root, "dir/big.bin")).NotTo(BeAnExistingFile())
+
+		_, err = svc.StartUpload(ctx, "running", "dir/big.bin", 11, "not-a-checksum")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.StartUpload(ctx, "running", ".uploads/state", 1, sum("x"))
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+
+		By("keeping a verified file for an upload started again")
+		_, err = svc.StartUpload(ctx, "running", "dir/big.bin", 11, sum("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = svc.WriteUploadChunk(ctx, "running", "dir/big.bin", 0, strings.NewReader("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		res, err = svc.FinishUpload(ctx, "running", "dir/big.bin", sum("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res).To(Equal(&UploadFileResult{
+			Path: "dir/big.bin", Sha256: sum("hello world"), ExpectedSha256: sum("hello world"), Verified: true,
+		}))
+		f, err = svc.StartUpload(ctx, "running", "dir/big.bin", 11, sum("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Size).To(BeEquivalentTo(11))
+
+		_, err = svc.CompleteUploads(ctx, "running", map[string]string{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filepath.Join(cluster.root, ".uploads")).NotTo(BeADirectory())
+		Expect(filepath.Join(cluster.root, "dir/big.bin")).To(BeAnExistingFile())
+	})
+
+	It("should catalog promoted and registered images and filter them", func() {
+		size := int64(3 << 30)
+		cluster.images = []automotivev1.Image{{
+			ObjectMeta: metav1.ObjectMeta{Name: "radio-1.0"},
+			Spec: automotivev1.ImageSpec{
+				Distro: "autosd", Target: "qemu", Architecture: "arm64", ExportFormat: "qcow2",
+				Tags:      []string{"radio"},
+				Lifecycle: automotivev1.ImageLifecycleReleased,
+				Size:      &automotivev1.ImageSize{CompressedBytes: &size},
+				Location: automotivev1.ImageLocation{Type: "registry", Registry: &automotivev1.RegistryLocation{
+					URL: "quay.io/org/radio:1.0", Digest: "sha256:abc"}},
+				Metadata: &automotivev1.ImageMetadata{SourceImageBuild: "done"},
+			},
+		}}
+
+		By("registering an external image")
+		resp, err := svc.RegisterImage(ctx, RegisterImageRequest{
+			Name: "vendor-bsp", Distro: "autosd", Target: "ridesx4", Architecture: "amd64", ExportFormat: "image",
+			URL: "quay.io/vendor/bsp:3", Tags: []string{" bsp ", "bsp", ""}, SizeBytes: 1024,
+		}, "alice")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Lifecycle).To(Equal("candidate"))
+		Expect(resp.CreatedBy).To(Equal("alice"))
+		Expect(resp.Tags).To(Equal([]string{"bsp"}))
+		Expect(resp.URL).To(Equal("quay.io/vendor/bsp:3"))
+		Expect(resp.SizeBytes).To(BeEquivalentTo(1024))
+
+		_, err = svc.RegisterImage(ctx, RegisterImageRequest{Name: "Bad_Name", Distro: "autosd", Target: "qemu",
+			Architecture: "amd64", ExportFormat: "image", URL: "quay.io/vendor/bsp:3"}, "alice")
+		Expect(errors.Is(err, ErrInvalidInput)).To(BeTrue())
+		_, err = svc.RegisterImage(ctx, RegisterImageRequest{Name: "no-arch", Distro: "autosd", Target: "qemu",
+			ExportFormat: "image", URL: "quay.io/vendor/bsp:3"}, "alice")
+		Expect(err).To(MatchError(ContainSubstring("architecture is required")))
+
+		By("listing with filters")
+		all, err := svc.ListImages(ctx, ImageFilter{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(all).To(HaveLen(2))
+		Expect(all[0].Name).To(Equal("radio-1.0"))
+		Expect(all[0].SourceBuild).To(Equal("done"))
+		Expect(all[0].SizeBytes).To(Equal(size))
+		arm, err := svc.ListImages(ctx, ImageFilter{Distro: "autosd", Architecture: "arm64"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(arm).To(HaveLen(1))
+		Expect(arm[0].Name).To(Equal("radio-1.0"))
+		tagged, err := svc.ListImages(ctx, ImageFilter{Tag: "bsp"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tagged).To(HaveLen(1))
+		Expect(tagged[0].Name).To(Equal("vendor-bsp"))
+
+		By("deleting an image")
+		deleted, err := svc.DeleteImage(ctx, "vendor-bsp")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted.Name).To(Equal("vendor-bsp"))
+		_, err = svc.GetImage(ctx, "vendor-bsp")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+		_, err = svc.DeleteImage(ctx, "vendor-bsp")
+		Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+	})
+
+	Describe("Git hooks", func() {
+		const sha = "0123456789abcdef0123456789abcdef01234567"
+		var payload []byte
+
+		githubHook := func(event string, body []byte, key string) GitHook {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write(body)
+			return GitHook{
+				Provider:  gitstatus.GitHub,
+				Event:     event,
+				Delivery:  "delivery-1",
+				Signature: "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+				Payload:   body,
+			}
+		}
+
+		BeforeEach(func() {
+			cluster.configMaps = map[string]*corev1.ConfigMap{"nightly-manifest": {Data: map[string]string{
+				"manifest.aib.yml":       "content: {}",
+				"custom-definitions.env": "GIT_COMMIT=stale\nimage_size=8G",
+			}}}
+			cluster.builds["nightly"] = &automotivev1.ImageBuild{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+				Spec: automotivev1.ImageBuildSpec{
+					Distro: "cs9", Target: "qemu", Architecture: "arm64", ExportFormat: "image", Mode: "image",
+					ManifestConfigMap: "nightly-manifest", ManifestFile: "manifest.aib.yml",
+					BuildInfo: &automotivev1.BuildInfo{Enabled: true},
+				},
+			}
+			cluster.secrets = map[string]*corev1.Secret{"hook": {Data: map[string][]byte{"secret": []byte("s3cret\n")}}}
+			cluster.autoDev = &automotivev1.AutomotiveDev{Spec: automotivev1.AutomotiveDevSpec{GitHooks: &automotivev1.GitHooks{
+				SecretRef: "hook",
+				Triggers: []automotivev1.GitTrigger{
+					{Repository: "MyOrg/Manifests", Branches: []string{"main"}, Tags: []string{"v*"}, TemplateBuild: "nightly"},
+					{Repository: "myorg/other", Branches: []string{"*"}, TemplateBuild: "nightly"},
+				},
+			}}}
+			payload = []byte(`{"ref":"refs/heads/main","after":"` + sha + `","repository":{"full_name":"myorg/manifests"},"pusher":{"name":"carol"}}`)
+		})
+
+		It("should start a build of the pushed commit from the matching trigger's template", func() {
+			resp, err := svc.TriggerGitBuilds(ctx, githubHook("push", payload, "s3cret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Commit).To(Equal(sha))
+			Expect(resp.Builds).To(HaveLen(1))
+			Expect(resp.Builds[0].Name).To(HavePrefix("nightly-0123456-"))
+			Expect(resp.Builds[0].Namespace).To(Equal("automotive-dev-operator-system"))
+
+			build := cluster.builds[resp.Builds[0].Name]
+			Expect(build.Annotations).To(HaveKeyWithValue("automotive.sdv.cloud.redhat.com/requested-by", "git:carol"))
+			commit, ok := gitstatus.FromAnnotations(build.Annotations)
+			Expect(ok).To(BeTrue())
+			Expect(commit).To(Equal(gitstatus.Commit{Provider: gitstatus.GitHub, Repository: "myorg/manifests", SHA: sha}))
+			Expect(build.Spec.BuildInfo.GitRef).To(Equal(sha))
+			Expect(cluster.configMaps[build.Spec.ManifestConfigMap].Data).To(HaveKeyWithValue("custom-definitions.env",
+				"image_size=8G\nGIT_COMMIT="+sha))
+
+			// a redelivery returns the same build
+			again, err := svc.TriggerGitBuilds(ctx, githubHook("push", payload, "s3cret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again.Builds).To(Equal(resp.Builds))
+		})
+
+		It("should reject deliveries that fail authentication", func() {
+			_, err := svc.TriggerGitBuilds(ctx, githubHook("push", payload, "wrong"))
+			Expect(errors.Is(err, ErrForbidden)).To(BeTrue())
+
+			hook := githubHook("push", payload, "s3cret")
+			hook.Signature = ""
+			_, err = svc.TriggerGitBuilds(ctx, hook)
+			Expect(errors.Is(err, ErrForbidden)).To(BeTrue())
+
+			_, err = svc.TriggerGitBuilds(ctx, GitHook{Provider: gitstatus.GitLab, Event: "Push Hook", Token: "wrong", Payload: payload})
+			Expect(errors.Is(err, ErrForbidden)).To(BeTrue())
+
+			cluster.autoDev = nil
+			_, err = svc.TriggerGitBuilds(ctx, githubHook("push", payload, "s3cret"))
+			Expect(errors.Is(err, ErrNotFound)).To(BeTrue())
+		})
+
+		It("should start builds for GitLab tag pushes and ignore refs no trigger matches", func() {
+			tag := []byte(`{"ref":"refs/tags/v1.2","checkout_sha":"` + sha + `","project":{"path_with_namespace":"myorg/manifests"}}`)
+			resp, err := svc.TriggerGitBuilds(ctx, GitHook{Provider: gitstatus.GitLab, Event: "Tag Push Hook", Token: "s3cret", Payload: tag})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Builds).To(HaveLen(1))
+			Expect(cluster.builds[resp.Builds[0].Name].Annotations).To(HaveKeyWithValue(gitstatus.ProviderAnnotation, "gitlab"))
+
+			for _, ref := range []string{"refs/heads/feature", "refs/tags/nightly"} {
+				body := []byte(`{"ref":"` + ref + `","after":"` + sha + `","repository":{"full_name":"myorg/manifests"}}`)
+				resp, err := svc.TriggerGitBuilds(ctx, githubHook("push", body, "s3cret"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Builds).To(BeEmpty())
+				Expect(resp.Message).To(ContainSubstring("no trigger matches"))
+			}
+
+			deleted := []byte(`{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000","deleted":true,"repository":{"full_name":"myorg/manifests"}}`)
+			resp, err = svc.TriggerGitBuilds(ctx, githubHook("push", deleted, "s3cret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Builds).To(BeEmpty())
+
+			resp, err = svc.TriggerGitBuilds(ctx, githubHook("ping", []byte(`{}`), "s3cret"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Message).To(Equal("ignored ping event"))
+		})
+
+		It("should refuse template builds that upload local files", func() {
+			cluster.builds["nightly"].Spec.InputFilesServer = true
+			_, err := svc.TriggerGitBuilds(ctx, githubHook("push", payload, "s3cret"))
+			Expect(errors.Is(err, ErrUnprocessable)).To(BeTrue())
+		})
+	})
+})
+
+// cacheLookups and cacheSavedSeconds read the result cache metrics of a namespace
+func cacheLookups(namespace, result string) float64 {
+	var m dto.Metric
+	Expect(cacheLookupsTotal.WithLabelValues(namespace, result).Write(&m)).To(Succeed())
+	return m.GetCounter().GetValue()
+}
+
+func cacheSavedSeconds(namespace string) float64 {
+	var m dto.Metric
+	Expect(cacheSavedSecondsTotal.WithLabelValues(namespace).Write(&m)).To(Succeed())
+	return m.GetCounter().GetValue()
+}
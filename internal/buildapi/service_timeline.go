@@ -0,0 +1,200 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+)
+
+// timeline collects the events of a build before they are sorted
+type timeline struct {
+	events []timedEvent
+}
+
+type timedEvent struct {
+	at    time.Time
+	event TimelineEvent
+}
+
+func (t *timeline) add(at time.Time, event TimelineEvent) {
+	if at.IsZero() {
+		return
+	}
+	t.events = append(t.events, timedEvent{at: at, event: event})
+}
+
+// sorted returns the events oldest first; events at the same time keep the order they were added in
+func (t *timeline) sorted() []TimelineEvent {
+	sort.SliceStable(t.events, func(i, j int) bool { return t.events[i].at.Before(t.events[j].at) })
+	out := make([]TimelineEvent, 0, len(t.events))
+	for _, e := range t.events {
+		e.event.Time = e.at.Format(time.RFC3339)
+		out = append(out, e.event)
+	}
+	return out
+}
+
+func (s *buildService) BuildTimeline(ctx context.Context, name string) (*BuildTimelineResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &timeline{}
+	addStatusEvents(t, build)
+
+	var tr *tektonv1.TaskRun
+	if trName := build.Status.TaskRunName; trName != "" {
+		tr, err = s.cluster.GetTaskRun(ctx, trName)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("error fetching taskrun: %w", err)
+		}
+		if tr != nil {
+			addTaskRunEvents(t, tr)
+		}
+	}
+
+	if err := s.addRecordedEvents(ctx, t, build, tr); err != nil {
+		return nil, err
+	}
+	return &BuildTimelineResponse{Name: build.Name, Events: t.sorted()}, nil
+}
+
+// addStatusEvents adds what the ImageBuild records of its own progress
+func addStatusEvents(t *timeline, build *automotivev1.ImageBuild) {
+	object := "ImageBuild/" + build.Name
+	created := TimelineEvent{Type: "Created", Source: "status", Object: object}
+	if by := build.Annotations["automotive.sdv.cloud.redhat.com/requested-by"]; by != "" {
+		created.Message = "Requested by " + by
+	}
+	t.add(build.CreationTimestamp.Time, created)
+
+	if progress, ok := uploadprogress.FromAnnotations(build.Annotations); ok {
+		t.add(progress.StartedAt, TimelineEvent{Type: "UploadsStarted", Source: "status", Object: object})
+		t.add(progress.CompletedAt, TimelineEvent{Type: "UploadsFinished", Source: "status", Object: object,
+			Message: progress.String()})
+	}
+	if build.Status.StartTime != nil {
+		t.add(build.Status.StartTime.Time, TimelineEvent{Type: "BuildStarted", Source: "status", Object: object})
+	}
+	if build.Status.CompletionTime != nil && (build.Status.Phase == "Completed" || build.Status.Phase == "Failed") {
+		t.add(build.Status.CompletionTime.Time, TimelineEvent{Type: build.Status.Phase, Source: "status", Object: object,
+			Message: build.Status.Message, Warning: build.Status.Phase == "Failed"})
+	}
+	if cond := meta.FindStatusCondition(build.Status.Conditions, automotivev1.ImageBuildArtifactServing); cond != nil &&
+		cond.Status == metav1.ConditionTrue {
+		message := cond.Message
+		if build.Status.Download != nil && build.Status.Download.RouteURL != "" {
+			message += " at " + build.Status.Download.RouteURL
+		}
+		t.add(cond.LastTransitionTime.Time, TimelineEvent{Type: "ArtifactServing", Source: "status",
+			Object: "Pod/" + build.Name + "-artifact-pod", Message: message})
+	}
+	if build.Status.Download != nil {
+		t.add(build.Status.Download.ExpiryTime.Time, TimelineEvent{Type: "ArtifactExpiry", Source: "status", Object: object,
+			Message: "The artifact stops being served"})
+	}
+	if build.Status.WorkspaceExpiryTime != nil {
+		t.add(build.Status.WorkspaceExpiryTime.Time, TimelineEvent{Type: "WorkspaceExpiry", Source: "status", Object: object,
+			Message: "The kept workspace stops being served"})
+	}
+}
+
+// addTaskRunEvents adds the lifecycle of a build's TaskRun and of each of its steps
+func addTaskRunEvents(t *timeline, tr *tektonv1.TaskRun) {
+	object := "TaskRun/" + tr.Name
+	t.add(tr.CreationTimestamp.Time, TimelineEvent{Type: "TaskRunCreated", Source: "taskrun", Object: object})
+	if tr.Status.StartTime != nil {
+		t.add(tr.Status.StartTime.Time, TimelineEvent{Type: "TaskRunStarted", Source: "taskrun", Object: object})
+	}
+	for _, st := range tr.Status.Steps {
+		switch {
+		case st.Terminated != nil:
+			t.add(st.Terminated.StartedAt.Time, TimelineEvent{Type: "StepStarted", Source: "taskrun", Object: object, Step: st.Name})
+			reason := st.Terminated.Reason
+			if st.TerminationReason != "" {
+				reason = st.TerminationReason
+			}
+			t.add(st.Terminated.FinishedAt.Time, TimelineEvent{Type: "StepFinished", Source: "taskrun", Object: object, Step: st.Name,
+				Message: fmt.Sprintf("%s, exit code %d", reason, st.Terminated.ExitCode), Warning: st.Terminated.ExitCode != 0})
+		case st.Running != nil:
+			t.add(st.Running.StartedAt.Time, TimelineEvent{Type: "StepStarted", Source: "taskrun", Object: object, Step: st.Name})
+		}
+	}
+	if tr.Status.CompletionTime != nil {
+		event := TimelineEvent{Type: "TaskRunFinished", Source: "taskrun", Object: object}
+		if len(tr.Status.Conditions) > 0 {
+			cond := tr.Status.Conditions[0]
+			event.Message = cond.Reason
+			if cond.Message != "" {
+				event.Message += ": " + cond.Message
+			}
+			event.Warning = cond.Status == corev1.ConditionFalse
+		}
+		t.add(tr.Status.CompletionTime.Time, event)
+	}
+}
+
+// addRecordedEvents adds the Kubernetes Events recorded for a build, its TaskRun and pods, and its workspace
+func (s *buildService) addRecordedEvents(ctx context.Context, t *timeline, build *automotivev1.ImageBuild, tr *tektonv1.TaskRun) error {
+	names := []string{build.Name, build.Name + "-upload-pod", build.Name + "-artifact-pod"}
+	if tr != nil {
+		names = append(names, tr.Name)
+		if tr.Status.PodName != "" {
+			names = append(names, tr.Status.PodName)
+		}
+	}
+	pvcs, err := s.cluster.ListPersistentVolumeClaims(ctx, storage.WorkspaceLabels(build.Name))
+	if err != nil {
+		return fmt.Errorf("error listing workspaces: %w", err)
+	}
+	for _, pvc := range pvcs {
+		names = append(names, pvc.Name)
+	}
+
+	seen := map[types.UID]bool{}
+	for _, name := range names {
+		events, err := s.cluster.ListEvents(ctx, name)
+		if err != nil {
+			return fmt.Errorf("error listing events: %w", err)
+		}
+		for i := range events {
+			e := &events[i]
+			if seen[e.UID] {
+				continue
+			}
+			seen[e.UID] = true
+			message := e.Message
+			if e.Count > 1 {
+				message = fmt.Sprintf("%s (%d times)", message, e.Count)
+			}
+			t.add(firstSeen(e), TimelineEvent{Type: e.Reason, Source: "event",
+				Object:  e.InvolvedObject.Kind + "/" + e.InvolvedObject.Name,
+				Message: message, Warning: e.Type == corev1.EventTypeWarning})
+		}
+	}
+	return nil
+}
+
+// firstSeen is when an event first occurred
+func firstSeen(e *corev1.Event) time.Time {
+	switch {
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	case !e.FirstTimestamp.IsZero():
+		return e.FirstTimestamp.Time
+	}
+	return e.CreationTimestamp.Time
+}
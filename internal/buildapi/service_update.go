@@ -0,0 +1,128 @@
+package buildapi
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+)
+
+// buildPending reports whether a build has not started building yet: the operator has not picked it up,
+// or it waits for its input files
+func buildPending(b *automotivev1.ImageBuild) bool {
+	return b.Status.Phase == "" || b.Status.Phase == "Uploading"
+}
+
+func (s *buildService) UpdateBuild(ctx context.Context, name string, req UpdateBuildRequest, requestedBy string) (*BuildResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if !buildPending(build) {
+		return nil, newError(ErrConflict, "build %s has already started (%s); only pending builds can be updated", name, build.Status.Phase)
+	}
+	if err := validateUpdate(build, req); err != nil {
+		return nil, err
+	}
+
+	patched := build.DeepCopy()
+	if req.ServeExpiryHours != nil {
+		patched.Spec.ServeExpiryHours = *req.ServeExpiryHours
+	}
+	if req.ExposeRoute != nil {
+		patched.Spec.ExposeRoute = *req.ExposeRoute
+	}
+	if req.Labels != nil {
+		for k := range userlabels.Filter(patched.Labels) {
+			delete(patched.Labels, k)
+		}
+		if patched.Labels == nil {
+			patched.Labels = map[string]string{}
+		}
+		userlabels.Apply(patched.Labels, req.Labels)
+	}
+	if req.RegistryCredentials != nil {
+		// the build task reads the secret once it starts, so replacing it in place is enough
+		if err := s.cluster.DeleteSecret(ctx, registrySecretName(name)); err != nil && !k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("error deleting registry secret: %w", err)
+		}
+		secretName, err := s.createRegistrySecret(ctx, name, req.RegistryCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("error creating registry secret: %w", err)
+		}
+		patched.Spec.EnvSecretRef = secretName
+	}
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	patched.Annotations["automotive.sdv.cloud.redhat.com/updated-by"] = requestedBy
+
+	if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+		return nil, fmt.Errorf("error updating ImageBuild: %w", err)
+	}
+
+	// Owner references and the manifest ConfigMap's labels are best-effort like on creation
+	if patched.Spec.EnvSecretRef != "" && req.RegistryCredentials != nil {
+		_ = s.cluster.SetControllerOwner(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: patched.Spec.EnvSecretRef}}, patched)
+	}
+	if req.Labels != nil && build.Spec.ManifestConfigMap != "" {
+		_ = s.relabelManifestConfigMap(ctx, build.Spec.ManifestConfigMap, req.Labels)
+	}
+
+	resp, err := s.GetBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resp.Message = "Build updated"
+	return resp, nil
+}
+
+// validateUpdate rejects malformed values and changes to the settings a build is identified by
+func validateUpdate(build *automotivev1.ImageBuild, req UpdateBuildRequest) error {
+	immutable := []struct{ field, requested, current string }{
+		{"distro", string(req.Distro), build.Spec.Distro},
+		{"target", string(req.Target), build.Spec.Target},
+		{"architecture", string(req.Architecture), build.Spec.Architecture},
+	}
+	for _, f := range immutable {
+		if f.requested != "" && f.requested != f.current {
+			return newError(ErrInvalidInput, "%s cannot be changed; create a new build instead", f.field)
+		}
+	}
+	if req.ServeExpiryHours != nil && *req.ServeExpiryHours <= 0 {
+		return newError(ErrInvalidInput, "serveExpiryHours must be positive")
+	}
+	if req.Labels != nil {
+		if err := userlabels.Validate(req.Labels); err != nil {
+			return newError(ErrInvalidInput, "%v", err)
+		}
+	}
+	if creds := req.RegistryCredentials; creds != nil && creds.Enabled {
+		if _, err := registrySecretData(creds); err != nil {
+			return newError(ErrInvalidInput, "invalid registry credentials: %v", err)
+		}
+	}
+	return nil
+}
+
+// relabelManifestConfigMap replaces the user labels of a build's manifest ConfigMap
+func (s *buildService) relabelManifestConfigMap(ctx context.Context, name string, labels map[string]string) error {
+	cm, err := s.cluster.GetConfigMap(ctx, name)
+	if err != nil {
+		return err
+	}
+	cm = cm.DeepCopy()
+	for k := range userlabels.Filter(cm.Labels) {
+		delete(cm.Labels, k)
+	}
+	if cm.Labels == nil {
+		cm.Labels = map[string]string{}
+	}
+	userlabels.Apply(cm.Labels, labels)
+	return s.cluster.UpdateConfigMap(ctx, cm)
+}
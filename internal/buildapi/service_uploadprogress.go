@@ -0,0 +1,149 @@
+package buildapi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+)
+
+// uploadProgressInterval is the least time between two recordings of a build's upload progress, so parallel
+// uploads do not patch the ImageBuild for every chunk
+const uploadProgressInterval = 5 * time.Second
+
+func (s *buildService) SetUploadTotals(ctx context.Context, name string, req UploadTotalsRequest) (*UploadProgress, error) {
+	if req.Files < 0 || req.Bytes < 0 {
+		return nil, newError(ErrInvalidInput, "totals must not be negative")
+	}
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if build.Annotations["automotive.sdv.cloud.redhat.com/uploads-complete"] == "true" {
+		return nil, newError(ErrConflict, "uploads of build %s are already complete", name)
+	}
+
+	progress, _ := uploadprogress.FromAnnotations(build.Annotations)
+	progress.TotalFiles, progress.TotalBytes = req.Files, req.Bytes
+	if progress.UpdatedAt.IsZero() {
+		progress.UpdatedAt = time.Now().UTC()
+	}
+	if progress.StartedAt.IsZero() {
+		progress.StartedAt = progress.UpdatedAt
+	}
+	patched := build.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	progress.Annotate(patched.Annotations)
+	if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+		return nil, fmt.Errorf("record upload totals failed: %w", err)
+	}
+	return toUploadProgress(progress), nil
+}
+
+// recordUploadProgress measures what the workspace of a build holds of its uploads and records it in the
+// build's upload progress annotation. Unless force is set it does nothing when the build's progress was
+// recorded less than uploadProgressInterval ago. Progress is informational, so failures are not reported.
+func (s *buildService) recordUploadProgress(ctx context.Context, name string, pod *corev1.Pod, force bool) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return
+	}
+	key := build.Namespace + "/" + build.Name
+	now := time.Now()
+	s.progressMu.Lock()
+	if !force && now.Sub(s.progressRecorded[key]) < uploadProgressInterval {
+		s.progressMu.Unlock()
+		return
+	}
+	if s.progressRecorded == nil {
+		s.progressRecorded = map[string]time.Time{}
+	}
+	s.progressRecorded[key] = now
+	s.progressMu.Unlock()
+
+	files, size, err := s.measureUploads(ctx, pod)
+	if err != nil {
+		return
+	}
+	progress, _ := uploadprogress.FromAnnotations(build.Annotations)
+	if files == progress.ReceivedFiles && size == progress.ReceivedBytes && !progress.UpdatedAt.IsZero() {
+		// nothing arrived since, so UpdatedAt keeps telling when the upload last advanced
+		return
+	}
+	progress.ReceivedFiles, progress.ReceivedBytes, progress.UpdatedAt = files, size, now.UTC()
+	if progress.StartedAt.IsZero() {
+		progress.StartedAt = progress.UpdatedAt
+	}
+	patched := build.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	progress.Annotate(patched.Annotations)
+	_ = s.cluster.PatchImageBuild(ctx, build, patched)
+}
+
+// forgetUploadProgress drops the throttling state of a build whose uploads are complete
+func (s *buildService) forgetUploadProgress(build *automotivev1.ImageBuild) {
+	s.progressMu.Lock()
+	defer s.progressMu.Unlock()
+	delete(s.progressRecorded, build.Namespace+"/"+build.Name)
+}
+
+// measureUploads counts the files below the shared workspace of the upload pod and sums their sizes, leaving
+// out the state of chunked uploads
+func (s *buildService) measureUploads(ctx context.Context, pod *corev1.Pod) (int, int64, error) {
+	var out strings.Builder
+	cmd := shellCommand(`find "$1" -path "$2" -prune -o -type f -exec stat -c %s {} +`,
+		"/workspace/shared", "/workspace/shared/"+uploadStateDir)
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, &out); err != nil {
+		return 0, 0, fmt.Errorf("measure uploads in pod failed: %w", err)
+	}
+	var files int
+	var size int64
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		n, err := strconv.ParseInt(strings.TrimSpace(scanner.Text()), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("unexpected size output %q", scanner.Text())
+		}
+		files++
+		size += n
+	}
+	return files, size, nil
+}
+
+// uploadProgressOf returns the upload progress of a build waiting for local files, nil once it stopped waiting
+// or when none was recorded
+func uploadProgressOf(build *automotivev1.ImageBuild) *UploadProgress {
+	if build.Status.Phase != "Uploading" {
+		return nil
+	}
+	progress, ok := uploadprogress.FromAnnotations(build.Annotations)
+	if !ok {
+		return nil
+	}
+	return toUploadProgress(progress)
+}
+
+func toUploadProgress(p uploadprogress.Progress) *UploadProgress {
+	out := &UploadProgress{
+		ReceivedBytes: p.ReceivedBytes,
+		ReceivedFiles: p.ReceivedFiles,
+		TotalBytes:    p.TotalBytes,
+		TotalFiles:    p.TotalFiles,
+		Percent:       p.Percent(),
+	}
+	if !p.UpdatedAt.IsZero() {
+		out.UpdatedAt = p.UpdatedAt.Format(time.RFC3339)
+	}
+	return out
+}
@@ -0,0 +1,557 @@
+package buildapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+)
+
+// uploadStateDir holds, below the shared workspace, the size and checksum of every file being uploaded in
+// chunks, so a resumed upload can tell whether a partial file belongs to the same content
+const uploadStateDir = ".uploads"
+
+// UploadFile is a single file to be placed in a build's shared workspace, or a manifest of the upload
+type UploadFile struct {
+	// Path is the destination relative to the shared workspace, or the source of a Manifest entry once the
+	// upload sent one
+	Path    string
+	Content io.Reader
+	// Sha256 is the checksum the client sent for the file, if any
+	Sha256 string
+	// Checksums, when set, maps destination paths to expected checksums; the part carries no file
+	Checksums map[string]string
+	// Manifest, when set, maps the sources of the following files to their destinations; the part carries no file
+	Manifest *UploadManifest
+}
+
+// uploadEntry is a validated UploadManifestEntry
+type uploadEntry struct {
+	dest string
+	// mode is the octal permissions to set, "" to keep the defaults
+	mode string
+}
+
+// NextUploadFile returns the next file to upload, or io.EOF when there are no more
+type NextUploadFile func() (*UploadFile, error)
+
+// UploadFiles copies the files of a single multipart request into the build's workspace and verifies them.
+// A build may need several requests, so the uploads are only marked complete by CompleteUploads.
+func (s *buildService) UploadFiles(ctx context.Context, name string, next NextUploadFile) (*UploadResponse, error) {
+	if _, err := s.getBuild(ctx, name); err != nil {
+		return nil, err
+	}
+
+	uploadPod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &UploadResponse{Status: "ok"}
+	// checksums from a manifest part may arrive after the files they describe, so verification waits for the end
+	expected := map[string]string{}
+	// sources maps the sources of the manifest's files to their entries once the upload sent one
+	var sources map[string]uploadEntry
+	parts := 0
+	for {
+		file, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if file.Checksums != nil {
+			for p, sum := range file.Checksums {
+				expected[path.Clean(strings.TrimSpace(p))] = strings.ToLower(strings.TrimSpace(sum))
+			}
+			continue
+		}
+		if file.Manifest != nil {
+			if sources != nil || parts > 0 {
+				return nil, newError(ErrInvalidInput, "the manifest must be the first part of an upload")
+			}
+			var dirs []uploadEntry
+			sources, dirs, err = parseUploadManifest(file.Manifest, expected)
+			if err != nil {
+				return nil, err
+			}
+			for _, dir := range dirs {
+				if err := s.makeUploadDir(ctx, uploadPod, dir); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		parts++
+
+		var entry uploadEntry
+		if sources != nil {
+			var ok bool
+			if entry, ok = sources[file.Path]; !ok {
+				return nil, newError(ErrInvalidInput, "file part %q is not in the manifest or was already uploaded", file.Path)
+			}
+			delete(sources, file.Path)
+		} else {
+			dest, err := uploadDest(file.Path)
+			if err != nil {
+				return nil, err
+			}
+			entry.dest = dest
+		}
+		cleanDest := entry.dest
+
+		podPath := "/workspace/shared/" + cleanDest
+		if err := s.copyUpload(ctx, uploadPod, file.Content, podPath); err != nil {
+			return nil, err
+		}
+		if entry.mode != "" {
+			if err := s.chmodUpload(ctx, uploadPod, entry.mode, podPath); err != nil {
+				return nil, err
+			}
+		}
+		sum, err := s.podChecksum(ctx, uploadPod, podPath)
+		if err != nil {
+			return nil, err
+		}
+		if file.Sha256 != "" {
+			expected[cleanDest] = strings.ToLower(strings.TrimSpace(file.Sha256))
+		}
+		resp.Files = append(resp.Files, UploadFileResult{Path: cleanDest, Sha256: sum})
+		s.recordUploadProgress(ctx, name, uploadPod, false)
+	}
+	// the last file may have come too soon after the one before to be recorded
+	s.recordUploadProgress(ctx, name, uploadPod, true)
+
+	if len(sources) > 0 {
+		missing := make([]string, 0, len(sources))
+		for source := range sources {
+			missing = append(missing, source)
+		}
+		sort.Strings(missing)
+		return resp, newError(ErrInvalidInput, "no file part for manifest sources: %s", strings.Join(missing, ", "))
+	}
+	if err := verifyUploads(resp, expected); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// parseUploadManifest validates the entries of an upload manifest. It returns the file entries by source and
+// the directory entries in manifest order, and adds the checksums of the files to expected.
+func parseUploadManifest(manifest *UploadManifest, expected map[string]string) (map[string]uploadEntry, []uploadEntry, error) {
+	if len(manifest.Entries) == 0 {
+		return nil, nil, newError(ErrInvalidInput, "the manifest has no entries")
+	}
+	sources := map[string]uploadEntry{}
+	var dirs []uploadEntry
+	dests := map[string]bool{}
+	for i, e := range manifest.Entries {
+		dest, err := uploadDest(e.Dest)
+		if err != nil {
+			return nil, nil, newError(ErrInvalidInput, "manifest entry %d: %v", i, err)
+		}
+		if dests[dest] {
+			return nil, nil, newError(ErrInvalidInput, "manifest entry %d: duplicate destination %s", i, dest)
+		}
+		dests[dest] = true
+		entry := uploadEntry{dest: dest}
+		if e.Mode != "" {
+			mode, err := strconv.ParseUint(e.Mode, 8, 32)
+			if err != nil || mode > 0o777 {
+				return nil, nil, newError(ErrInvalidInput, "manifest entry %d: invalid mode %q; use octal permissions such as 0644", i, e.Mode)
+			}
+			entry.mode = fmt.Sprintf("%04o", mode)
+		}
+
+		switch e.Type {
+		case UploadEntryDir:
+			if e.Source != "" || e.Sha256 != "" {
+				return nil, nil, newError(ErrInvalidInput, "manifest entry %d: directory %s cannot have a source or sha256", i, dest)
+			}
+			dirs = append(dirs, entry)
+		case "", UploadEntryFile:
+			if e.Source == "" {
+				return nil, nil, newError(ErrInvalidInput, "manifest entry %d: file %s has no source", i, dest)
+			}
+			if _, dup := sources[e.Source]; dup {
+				return nil, nil, newError(ErrInvalidInput, "manifest entry %d: duplicate source %q", i, e.Source)
+			}
+			if e.Sha256 != "" {
+				sum, err := checksumArg(e.Sha256)
+				if err != nil {
+					return nil, nil, newError(ErrInvalidInput, "manifest entry %d: %v", i, err)
+				}
+				expected[dest] = sum
+			}
+			sources[e.Source] = entry
+		default:
+			return nil, nil, newError(ErrInvalidInput, "manifest entry %d: unknown type %q; use file or dir", i, e.Type)
+		}
+	}
+	return sources, dirs, nil
+}
+
+// makeUploadDir creates a directory of an upload manifest in the upload pod
+func (s *buildService) makeUploadDir(ctx context.Context, pod *corev1.Pod, dir uploadEntry) error {
+	podPath := "/workspace/shared/" + dir.dest
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, []string{"mkdir", "-p", "--", podPath}, io.Discard); err != nil {
+		return fmt.Errorf("creating directory %s failed: %w", dir.dest, err)
+	}
+	if dir.mode == "" {
+		return nil
+	}
+	return s.chmodUpload(ctx, pod, dir.mode, podPath)
+}
+
+// chmodUpload sets the permissions of an uploaded file or directory in the upload pod
+func (s *buildService) chmodUpload(ctx context.Context, pod *corev1.Pod, mode, podPath string) error {
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, []string{"chmod", mode, "--", podPath}, io.Discard); err != nil {
+		return fmt.Errorf("setting the mode of %s failed: %w", strings.TrimPrefix(podPath, "/workspace/shared/"), err)
+	}
+	return nil
+}
+
+// copyUpload spools content to a temporary file so its size is known, then copies it into the upload pod
+func (s *buildService) copyUpload(ctx context.Context, pod *corev1.Pod, content io.Reader, podPath string) error {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := io.Copy(tmp, content); err != nil {
+		return err
+	}
+
+	if err := s.cluster.CopyToPod(ctx, pod.Name, pod.Spec.Containers[0].Name, tmp.Name(), podPath); err != nil {
+		return fmt.Errorf("stream to pod failed: %w", err)
+	}
+	return nil
+}
+
+// podChecksum returns the hex SHA-256 of a file in the upload pod, computed there so it covers the copy
+func (s *buildService) podChecksum(ctx context.Context, pod *corev1.Pod, podPath string) (string, error) {
+	var out bytes.Buffer
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, []string{"sha256sum", "--", podPath}, &out); err != nil {
+		return "", fmt.Errorf("checksum in pod failed: %w", err)
+	}
+	sum, _, _ := strings.Cut(strings.TrimSpace(out.String()), " ")
+	// sha256sum marks lines whose file name it had to escape with a leading backslash
+	sum = strings.TrimPrefix(sum, `\`)
+	if len(sum) != sha256.Size*2 {
+		return "", fmt.Errorf("unexpected sha256sum output %q", out.String())
+	}
+	return sum, nil
+}
+
+// uploadPod returns the running upload pod of a build, or an ErrNotReady error
+func (s *buildService) uploadPod(ctx context.Context, name string) (*corev1.Pod, error) {
+	pod, err := s.cluster.FindUploadPod(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error listing upload pods: %w", err)
+	}
+	if pod == nil {
+		return nil, newError(ErrNotReady, "upload pod not ready")
+	}
+	return pod, nil
+}
+
+// uploadDest cleans a destination path relative to the shared workspace, rejecting paths that leave it
+func uploadDest(p string) (string, error) {
+	dest := strings.TrimSpace(p)
+	if dest == "" {
+		return "", newError(ErrInvalidInput, "missing destination filename")
+	}
+	cleanDest := path.Clean(dest)
+	if cleanDest == "." || strings.HasPrefix(cleanDest, "..") || strings.HasPrefix(cleanDest, "/") ||
+		cleanDest == uploadStateDir || strings.HasPrefix(cleanDest, uploadStateDir+"/") {
+		return "", newError(ErrInvalidInput, "invalid destination path: %s", dest)
+	}
+	return cleanDest, nil
+}
+
+// uploadStatePath is where the state of a chunked upload to dest is kept in the upload pod
+func uploadStatePath(dest string) string {
+	sum := sha256.Sum256([]byte(dest))
+	return "/workspace/shared/" + uploadStateDir + "/" + hex.EncodeToString(sum[:])
+}
+
+// checksumArg validates a hex SHA-256 sent by a client and returns it in lower case
+func checksumArg(sum string) (string, error) {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+		return "", newError(ErrInvalidInput, "invalid sha256 %q", sum)
+	}
+	return sum, nil
+}
+
+// verifyUploads fills in the expected checksums of resp and returns an ErrInvalidInput error naming every file
+// that does not match or was expected but not uploaded
+func verifyUploads(resp *UploadResponse, expected map[string]string) error {
+	var mismatched []string
+	for i := range resp.Files {
+		f := &resp.Files[i]
+		f.ExpectedSha256 = expected[f.Path]
+		delete(expected, f.Path)
+		f.Verified = f.ExpectedSha256 != "" && f.ExpectedSha256 == f.Sha256
+		if f.ExpectedSha256 != "" && !f.Verified {
+			mismatched = append(mismatched, f.Path)
+		}
+	}
+	for p := range expected {
+		mismatched = append(mismatched, p+" (not uploaded)")
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		resp.Status = "checksum mismatch"
+		return newError(ErrInvalidInput, "checksum mismatch: %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// markUploadsComplete lets the controller start the build
+func (s *buildService) markUploadsComplete(ctx context.Context, build *automotivev1.ImageBuild) error {
+	patched := build.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	patched.Annotations["automotive.sdv.cloud.redhat.com/uploads-complete"] = "true"
+	progress, _ := uploadprogress.FromAnnotations(build.Annotations)
+	progress.CompletedAt = time.Now().UTC()
+	if progress.UpdatedAt.IsZero() {
+		progress.UpdatedAt = progress.CompletedAt
+	}
+	progress.Annotate(patched.Annotations)
+	if err := s.cluster.PatchImageBuild(ctx, build, patched); err != nil {
+		return fmt.Errorf("mark complete failed: %w", err)
+	}
+	return nil
+}
+
+func (s *buildService) UploadedFile(ctx context.Context, name, p string) (*UploadedFile, error) {
+	dest, err := uploadDest(p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getBuild(ctx, name); err != nil {
+		return nil, err
+	}
+	pod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := s.uploadedSize(ctx, pod, "/workspace/shared/"+dest)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadedFile{Path: dest, Size: size}, nil
+}
+
+func (s *buildService) StartUpload(ctx context.Context, name, p string, size int64, checksum string) (*UploadedFile, error) {
+	dest, err := uploadDest(p)
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, newError(ErrInvalidInput, "invalid size %d", size)
+	}
+	sum, err := checksumArg(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getBuild(ctx, name); err != nil {
+		return nil, err
+	}
+	pod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the partial file is kept only if it was started for the same size and checksum
+	var out strings.Builder
+	cmd := shellCommand(`if [ "$(cat -- "$2" 2>/dev/null)" != "$3" ]; then
+  mkdir -p -- "$(dirname -- "$1")" "$(dirname -- "$2")" && : > "$1" && printf '%s' "$3" > "$2" || exit 1
+fi
+if [ -f "$1" ]; then wc -c < "$1"; else echo 0; fi`,
+		"/workspace/shared/"+dest, uploadStatePath(dest), fmt.Sprintf("%d %s", size, sum))
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, &out); err != nil {
+		return nil, fmt.Errorf("start upload in pod failed: %w", err)
+	}
+	stored, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected size output %q", out.String())
+	}
+	return &UploadedFile{Path: dest, Size: stored}, nil
+}
+
+func (s *buildService) FinishUpload(ctx context.Context, name, p, checksum string) (*UploadFileResult, error) {
+	dest, err := uploadDest(p)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := checksumArg(checksum)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getBuild(ctx, name); err != nil {
+		return nil, err
+	}
+	pod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	podPath := "/workspace/shared/" + dest
+	size, err := s.uploadedSize(ctx, pod, podPath)
+	if err != nil {
+		return nil, err
+	}
+	result := &UploadFileResult{Path: dest, ExpectedSha256: sum}
+	if size > 0 || sum == emptySha256 {
+		if result.Sha256, err = s.podChecksum(ctx, pod, podPath); err != nil {
+			return nil, err
+		}
+	}
+	result.Verified = result.Sha256 == sum
+
+	if result.Verified {
+		// the state stays until the uploads complete, so an upload started again resumes after the whole file
+		return result, nil
+	}
+	cmd := shellCommand(`rm -f -- "$1" "$2"`, podPath, uploadStatePath(dest))
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, io.Discard); err != nil {
+		return nil, fmt.Errorf("discard upload in pod failed: %w", err)
+	}
+	if result.Sha256 == "" {
+		return result, newError(ErrInvalidInput, "checksum mismatch: %s (not uploaded)", dest)
+	}
+	return result, newError(ErrInvalidInput, "checksum mismatch: %s", dest)
+}
+
+func (s *buildService) WriteUploadChunk(ctx context.Context, name, p string, offset int64, content io.Reader) (*UploadedFile, error) {
+	dest, err := uploadDest(p)
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, newError(ErrInvalidInput, "invalid offset %d", offset)
+	}
+	if _, err := s.getBuild(ctx, name); err != nil {
+		return nil, err
+	}
+	pod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	podPath := "/workspace/shared/" + dest
+	size, err := s.uploadedSize(ctx, pod, podPath)
+	if err != nil {
+		return nil, err
+	}
+	if offset > size {
+		return nil, newError(ErrConflict, "offset %d is past the %d bytes of %s uploaded so far", offset, size, dest)
+	}
+
+	// the file is cut back to offset first, so a retried chunk replaces whatever a failed attempt left
+	cmd := shellCommand(`mkdir -p -- "$(dirname -- "$1")" && truncate -s "$2" -- "$1" && cat >> "$1"`,
+		podPath, strconv.FormatInt(offset, 10))
+	if err := s.cluster.ExecWithInput(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, content, io.Discard); err != nil {
+		return nil, fmt.Errorf("write chunk to pod failed: %w", err)
+	}
+
+	size, err = s.uploadedSize(ctx, pod, podPath)
+	if err != nil {
+		return nil, err
+	}
+	s.recordUploadProgress(ctx, name, pod, false)
+	return &UploadedFile{Path: dest, Size: size}, nil
+}
+
+func (s *buildService) CompleteUploads(ctx context.Context, name string, checksums map[string]string) (*UploadResponse, error) {
+	build, err := s.getBuild(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	pod, err := s.uploadPod(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	dests := make([]string, 0, len(checksums))
+	expected := make(map[string]string, len(checksums))
+	for p, sum := range checksums {
+		dest, err := uploadDest(p)
+		if err != nil {
+			return nil, err
+		}
+		dests = append(dests, dest)
+		expected[dest] = strings.ToLower(strings.TrimSpace(sum))
+	}
+	sort.Strings(dests)
+
+	resp := &UploadResponse{Status: "ok"}
+	for _, dest := range dests {
+		podPath := "/workspace/shared/" + dest
+		size, err := s.uploadedSize(ctx, pod, podPath)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 && expected[dest] != emptySha256 {
+			// leave it in expected so it is reported as not uploaded
+			continue
+		}
+		sum, err := s.podChecksum(ctx, pod, podPath)
+		if err != nil {
+			return nil, err
+		}
+		resp.Files = append(resp.Files, UploadFileResult{Path: dest, Sha256: sum})
+	}
+
+	if err := verifyUploads(resp, expected); err != nil {
+		return resp, err
+	}
+	cmd := shellCommand(`rm -rf -- "$1"`, "/workspace/shared/"+uploadStateDir)
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, io.Discard); err != nil {
+		return nil, fmt.Errorf("remove upload state in pod failed: %w", err)
+	}
+	if err := s.markUploadsComplete(ctx, build); err != nil {
+		return nil, err
+	}
+	s.forgetUploadProgress(build)
+	return resp, nil
+}
+
+// emptySha256 is the checksum of an empty file, which is indistinguishable from one never uploaded
+const emptySha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// uploadedSize returns the size of a file in the upload pod, zero if it does not exist
+func (s *buildService) uploadedSize(ctx context.Context, pod *corev1.Pod, podPath string) (int64, error) {
+	var out strings.Builder
+	cmd := shellCommand(`if [ -f "$1" ]; then wc -c < "$1"; else echo 0; fi`, podPath)
+	if err := s.cluster.Exec(ctx, pod.Name, pod.Spec.Containers[0].Name, cmd, &out); err != nil {
+		return 0, fmt.Errorf("size in pod failed: %w", err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected size output %q", out.String())
+	}
+	return size, nil
+}
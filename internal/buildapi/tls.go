@@ -0,0 +1,144 @@
+package buildapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+// Client certificate policies of a server with a client CA
+const (
+	// ClientAuthRequire refuses connections without a certificate signed by the client CA
+	ClientAuthRequire = "require"
+	// ClientAuthOptional verifies certificates clients present but also lets clients without one connect
+	ClientAuthOptional = "optional"
+)
+
+// TLSConfig selects the certificate the server presents and, for mutual TLS, the CA that signs the
+// certificates of trusted clients. Requests are still authenticated by their bearer token.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, if set, makes clients present a certificate signed by one of its CAs, as ClientAuth says
+	ClientCAFile string
+	// ClientAuth is ClientAuthRequire (the default) or ClientAuthOptional
+	ClientAuth string
+}
+
+// TLSConfigFromEnv reads $BUILD_API_TLS_CERT_FILE, $BUILD_API_TLS_KEY_FILE, $BUILD_API_TLS_CLIENT_CA_FILE
+// and $BUILD_API_TLS_CLIENT_AUTH
+func TLSConfigFromEnv() TLSConfig {
+	return TLSConfig{
+		CertFile:     strings.TrimSpace(os.Getenv("BUILD_API_TLS_CERT_FILE")),
+		KeyFile:      strings.TrimSpace(os.Getenv("BUILD_API_TLS_KEY_FILE")),
+		ClientCAFile: strings.TrimSpace(os.Getenv("BUILD_API_TLS_CLIENT_CA_FILE")),
+		ClientAuth:   strings.TrimSpace(os.Getenv("BUILD_API_TLS_CLIENT_AUTH")),
+	}
+}
+
+// Enabled reports whether the server should listen with TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ConfigureTLS makes the server listen with TLS. The certificate and client CA are read again when
+// their files change, so rotated certificates are picked up without a restart.
+func (a *APIServer) ConfigureTLS(cfg TLSConfig) error {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	clientAuth := tls.RequireAndVerifyClientCert
+	switch cfg.ClientAuth {
+	case "", ClientAuthRequire:
+	case ClientAuthOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	default:
+		return fmt.Errorf("invalid client auth %q: must be %s or %s", cfg.ClientAuth, ClientAuthRequire, ClientAuthOptional)
+	}
+	if cfg.ClientAuth != "" && cfg.ClientCAFile == "" {
+		return fmt.Errorf("client auth %q needs a client CA file", cfg.ClientAuth)
+	}
+
+	watcher, err := certwatcher.New(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: watcher.GetCertificate,
+	}
+	if cfg.ClientCAFile != "" {
+		cas := &clientCAs{path: cfg.ClientCAFile}
+		if _, err := cas.pool(); err != nil {
+			return err
+		}
+		base := tlsConfig.Clone()
+		base.ClientAuth = clientAuth
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			pool, err := cas.pool()
+			if err != nil {
+				// a CA file caught mid-rotation keeps the last one that could be read
+				a.log.Error(err, "failed to reload client CA")
+			}
+			c := base.Clone()
+			c.ClientCAs = pool
+			return c, nil
+		}
+	}
+
+	a.server.TLSConfig = tlsConfig
+	a.certWatcher = watcher
+	return nil
+}
+
+// clientCAs is a client CA bundle read again whenever its file changes
+type clientCAs struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	current *x509.CertPool
+}
+
+// pool returns the CAs of the file, or the last ones read with an error if the file cannot be read now
+func (c *clientCAs) pool() (*x509.CertPool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fi, err := os.Stat(c.path)
+	if err != nil {
+		return c.current, fmt.Errorf("client CA: %w", err)
+	}
+	if c.current != nil && fi.ModTime().Equal(c.modTime) && fi.Size() == c.size {
+		return c.current, nil
+	}
+	pem, err := os.ReadFile(c.path)
+	if err != nil {
+		return c.current, fmt.Errorf("client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return c.current, fmt.Errorf("client CA %s holds no PEM certificates", c.path)
+	}
+	c.current, c.modTime, c.size = pool, fi.ModTime(), fi.Size()
+	return pool, nil
+}
+
+// startCertWatcher watches the TLS certificate for rotation until ctx is done
+func (a *APIServer) startCertWatcher(ctx context.Context) {
+	if a.certWatcher == nil {
+		return
+	}
+	go func() {
+		if err := a.certWatcher.Start(ctx); err != nil {
+			a.log.Error(err, "TLS certificate watcher stopped; rotated certificates need a restart")
+		}
+	}()
+}
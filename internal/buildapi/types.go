@@ -3,6 +3,8 @@ package buildapi
 import (
 	"fmt"
 	"strings"
+
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
 )
 
 type Distro string
@@ -77,24 +79,143 @@ func ParseMode(s string) (Mode, error) {
 
 // BuildRequest is the payload to create a build via the REST API
 type BuildRequest struct {
-	Name                   string               `json:"name"`
-	Manifest               string               `json:"manifest"`
-	ManifestFileName       string               `json:"manifestFileName"`
-	Distro                 Distro               `json:"distro"`
-	Target                 Target               `json:"target"`
-	Architecture           Architecture         `json:"architecture"`
-	ExportFormat           ExportFormat         `json:"exportFormat"`
-	Mode                   Mode                 `json:"mode"`
-	AutomotiveImageBuilder string               `json:"automotiveImageBuilder"`
-	StorageClass           string               `json:"storageClass"`
-	CustomDefs             []string             `json:"customDefs"`
-	AIBExtraArgs           []string             `json:"aibExtraArgs"`
-	AIBOverrideArgs        []string             `json:"aibOverrideArgs"`
-	ServeArtifact          bool                 `json:"serveArtifact"`
-	Compression            string               `json:"compression,omitempty"`
-	RegistryCredentials    *RegistryCredentials `json:"registryCredentials,omitempty"`
+	// Name is the name of the ImageBuild; it is required unless GenerateName is set
+	Name string `json:"name"`
+	// GenerateName has the server name the build: a random suffix is appended to it, as with Kubernetes'
+	// metadata.generateName. The name is returned in the response.
+	GenerateName string `json:"generateName,omitempty"`
+	// IdempotencyKey is sent in the Idempotency-Key header rather than the body
+	IdempotencyKey string `json:"-"`
+	// Manifest is the manifest YAML; it is required unless ManifestRef is set
+	Manifest string `json:"manifest"`
+	// ManifestFileName is the file name of the main manifest, which the build always uses. With ManifestRef
+	// it names a file of the artifact and defaults to its first *.aib.yml or *.mpp.yml file.
+	// +default=manifest.aib.yml
+	ManifestFileName string `json:"manifestFileName"`
+	// AdditionalManifests are manifests the main manifest includes, placed next to it under their names
+	AdditionalManifests []ManifestFile `json:"additionalManifests,omitempty"`
+	// Profile selects a build profile of the catalog, e.g. "ridesx4". Its target, architecture and export
+	// format apply unless the request sets them; its AIB args and defines come before the request's own.
+	Profile      string       `json:"profile,omitempty"`
+	Distro       Distro       `json:"distro"`
+	Target       Target       `json:"target"`
+	Architecture Architecture `json:"architecture"`
+	ExportFormat ExportFormat `json:"exportFormat"`
+	Mode         Mode         `json:"mode"`
+	// AutomotiveImageBuilder is the automotive-image-builder image to build with. It defaults to the
+	// AutomotiveDev buildConfig.images.builder, or quay.io/centos-sig-automotive/automotive-image-builder:1.0.0.
+	AutomotiveImageBuilder string   `json:"automotiveImageBuilder"`
+	StorageClass           string   `json:"storageClass"`
+	CustomDefs             []string `json:"customDefs"`
+	// AIBExtraArgs are added to the automotive-image-builder command, one argument per item. Flags must be
+	// in the AutomotiveDev's buildConfig.allowedAIBArgs and arguments cannot contain shell metacharacters.
+	AIBExtraArgs []string `json:"aibExtraArgs"`
+	// AIBOverrideArgs replace the distro, target, architecture, export and mode arguments of the
+	// automotive-image-builder command; AIBExtraArgs are then ignored. They are checked like AIBExtraArgs.
+	AIBOverrideArgs []string `json:"aibOverrideArgs"`
+	// ServeArtifact creates the artifact serving pod on completion
+	ServeArtifact bool `json:"serveArtifact"`
+	// Compression is the compression of the artifact; "none" skips compressing it, trading transfer size for
+	// build time on local clusters
+	// +enum=gzip,lz4,none
+	// +default=gzip
+	Compression string `json:"compression,omitempty"`
+	// ArtifactNameTemplate is a Go template naming the artifact, without its extension, from the fields .Distro,
+	// .Target, .Arch, .Name and .Date (the UTC day the build was created, e.g. 20261015); it may only hold text
+	// and fields. It defaults to the AutomotiveDev's buildConfig.artifactNameTemplate, or {{.Distro}}-{{.Target}}
+	// +maxLength=256
+	ArtifactNameTemplate string               `json:"artifactNameTemplate,omitempty"`
+	RegistryCredentials  *RegistryCredentials `json:"registryCredentials,omitempty"`
+	// EncryptionKey encrypts the artifacts with this passphrase (AES-256-CBC, PBKDF2 with SHA-256 and
+	// 100000 iterations, as openssl enc -pbkdf2) while they are packaged; they are served encrypted with an
+	// .enc suffix. It is kept in a secret owned by the build and cannot be combined with EncryptionKeySecret.
+	// Encrypted builds are neither reused nor converted.
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// EncryptionKeySecret encrypts the artifacts like EncryptionKey with the "key" entry of this existing
+	// secret of the build namespace
+	EncryptionKeySecret string `json:"encryptionKeySecret,omitempty"`
+	// Labels are user labels applied to the ImageBuild, its manifest ConfigMap, TaskRun and artifact pod.
+	// Keys and values must be valid Kubernetes labels; the app.kubernetes.io/, automotive.sdv.cloud.redhat.com/
+	// and tekton.dev/ prefixes are reserved.
+	Labels map[string]string `json:"labels,omitempty"`
+	// AccessGroups are the groups whose members see the build besides its requester when the AutomotiveDev
+	// restricts access to builds. They default to the requester's groups, except the system: ones.
+	AccessGroups []string `json:"accessGroups,omitempty"`
+	// KeepWorkspaceOnFailure keeps the workspace and build directory logs of the build if it fails and serves
+	// them from /v1/builds/{name}/workspace.tar. It defaults to the AutomotiveDev buildConfig.keepWorkspaceOnFailure.
+	KeepWorkspaceOnFailure *bool `json:"keepWorkspaceOnFailure,omitempty"`
+	// WorkspaceSize is the size of the build's workspace PVC, e.g. 24Gi. Without it the server estimates the size
+	// from the image_size, packages and export format of the manifests, or uses the AutomotiveDev's
+	// buildConfig.pvcSize when they give no hint, as with ManifestRef.
+	WorkspaceSize string `json:"workspaceSize,omitempty"`
+	// BuildInfo bakes the build's provenance into the image as /etc/automotive-build-info: build name,
+	// namespace and UID, distro, target, architecture, builder image, manifest SHA-256, build time and gitRef.
+	BuildInfo bool `json:"buildInfo,omitempty"`
+	// GitRef is the source revision recorded in the build info; it requires BuildInfo
+	// +maxLength=256
+	GitRef string `json:"gitRef,omitempty"`
+	// ReuseExisting returns a completed build with identical manifests and settings whose artifact is
+	// still served instead of starting a new one; the reuse is recorded in annotations of that build.
+	// Builds uploading local files are never reused.
+	ReuseExisting bool `json:"reuseExisting,omitempty"`
+	// Cache set to bypass starts a new build even when the result cache holds an identical one, e.g. to
+	// pick up packages published since. Without it the result cache answers the request when ReuseExisting
+	// is set or the AutomotiveDev enables the enableResultCache feature gate.
+	// +enum=bypass
+	Cache string `json:"cache,omitempty"`
+	// ManifestRef is an OCI artifact holding the manifests to build, e.g. quay.io/org/manifests:v1.2, pulled by
+	// the build with the registry credentials instead of sending Manifest. ManifestFileName then selects the
+	// main manifest among its files. It cannot be combined with Manifest or AdditionalManifests, and the
+	// manifests are not linted. Only builds of artifacts pinned by digest are considered by ReuseExisting.
+	ManifestRef string `json:"manifestRef,omitempty"`
+	// OSTree commits the build's tree to a ref of a remote ostree repository, so devices update over the air by
+	// pulling only what changed. Builds committing to a repository are neither reused nor answered from the
+	// result cache.
+	OSTree *OSTreeUpdate `json:"ostree,omitempty"`
+	// InputPVC mounts an existing claim of the build namespace at /workspace/input, so the manifest references
+	// large files staged on it as source_path: /workspace/input/<file> instead of uploading them. The content
+	// of the claim is unknown, so builds reading one are neither reused nor answered from the result cache.
+	InputPVC *InputPVC `json:"inputPVC,omitempty"`
+	// GitCommit is the commit a Git webhook started the build for, whose status the operator reports
+	GitCommit *gitstatus.Commit `json:"-"`
+}
+
+// OSTreeUpdate names the remote ostree repository and ref a build commits to. The commit is made on top of the
+// ref's current commit and pushed with rsync over SSH.
+type OSTreeUpdate struct {
+	// RepositoryURL is the remote archive mode repository as an rsync destination, e.g.
+	// ostree@updates.example.com:/srv/ostree/repo
+	// +required
+	RepositoryURL string `json:"repositoryUrl"`
+	// Ref is the branch the commit is made on, e.g. autosd/aarch64/qemu
+	// +required
+	Ref string `json:"ref"`
+	// SecretRef is a kubernetes.io/ssh-auth secret of the build namespace logging in to the repository's host;
+	// its optional known_hosts entry pins the host's key
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+// InputPVC names the claim a build reads input files from
+type InputPVC struct {
+	// ClaimName is a PersistentVolumeClaim of the build namespace; ReadWriteOnce claims only mount on the node
+	// using them
+	// +required
+	ClaimName string `json:"claimName"`
+	// SubPath mounts this directory of the claim instead of its root
+	SubPath string `json:"subPath,omitempty"`
+	// ReadOnly mounts the claim read-only
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// ManifestFile is a manifest the main manifest includes, placed next to it in the build's manifest workspace
+type ManifestFile struct {
+	// +required
+	Name string `json:"name"`
+	// +required
+	Content string `json:"content"`
 }
 
+// RegistryCredentials authenticate the build to the registry it pushes to or pulls manifests from
 type RegistryCredentials struct {
 	Enabled      bool   `json:"enabled"`
 	AuthType     string `json:"authType"`
@@ -105,27 +226,133 @@ type RegistryCredentials struct {
 	DockerConfig string `json:"dockerConfig"`
 }
 
+// UpdateBuildRequest changes settings of a build that has not started building. Fields left out keep their
+// value.
+type UpdateBuildRequest struct {
+	// ServeExpiryHours is how long the artifact is served once the build completes
+	ServeExpiryHours *int32 `json:"serveExpiryHours,omitempty"`
+	// ExposeRoute exposes the artifact through a Route
+	ExposeRoute *bool `json:"exposeRoute,omitempty"`
+	// Labels replace the build's user labels; an empty object removes them
+	Labels map[string]string `json:"labels,omitempty"`
+	// RegistryCredentials replace the credentials the build was submitted with; enabled false removes them
+	RegistryCredentials *RegistryCredentials `json:"registryCredentials,omitempty"`
+	// Distro, Target and Architecture cannot be changed; a request setting them to other values is rejected
+	Distro       Distro       `json:"distro,omitempty"`
+	Target       Target       `json:"target,omitempty"`
+	Architecture Architecture `json:"architecture,omitempty"`
+}
+
 // BuildResponse is returned by POST and GET build operations
 type BuildResponse struct {
-	Name             string `json:"name"`
-	Phase            string `json:"phase"`
-	Message          string `json:"message"`
-	RequestedBy      string `json:"requestedBy,omitempty"`
+	Name        string `json:"name"`
+	Phase       string `json:"phase"`
+	Message     string `json:"message"`
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// AccessGroups are the groups the build is shared with when access to builds is restricted
+	AccessGroups []string `json:"accessGroups,omitempty"`
+	// RequestID is the X-Request-ID of the request that created the build, recorded on its resources
+	RequestID        string `json:"requestId,omitempty"`
+	Profile          string `json:"profile,omitempty"`
 	ArtifactURL      string `json:"artifactURL,omitempty"`
 	ArtifactFileName string `json:"artifactFileName,omitempty"`
-	StartTime        string `json:"startTime,omitempty"`
-	CompletionTime   string `json:"completionTime,omitempty"`
+	// ArtifactSHA256 is the hex SHA-256 checksum of the artifact file, when the build recorded one
+	ArtifactSHA256 string `json:"artifactSha256,omitempty"`
+	// ArtifactSize is the size of the artifact in bytes
+	ArtifactSize int64 `json:"artifactSize,omitempty"`
+	// ArtifactContentType is the media type of the artifact once decompressed and decrypted
+	ArtifactContentType string `json:"artifactContentType,omitempty"`
+	// Encrypted is set when the artifacts are encrypted with the build's key and must be decrypted by the client
+	Encrypted bool `json:"encrypted,omitempty"`
+	// +format=date-time
+	StartTime string `json:"startTime,omitempty"`
+	// +format=date-time
+	CompletionTime string `json:"completionTime,omitempty"`
+	// BuilderImageDigest is the digest of the automotive-image-builder image the build runs
+	BuilderImageDigest string `json:"builderImageDigest,omitempty"`
+	// ManifestSHA256 is the hex SHA-256 of the files of the manifest ConfigMap the build was created with
+	ManifestSHA256 string `json:"manifestSha256,omitempty"`
+	// OSTreeCommit is the checksum of the commit a completed build pushed to its ostree ref
+	OSTreeCommit string `json:"ostreeCommit,omitempty"`
+	// WorkspaceExpiryTime is set while the workspace of a failed build is kept; it stops being served then
+	// +format=date-time
+	WorkspaceExpiryTime string `json:"workspaceExpiryTime,omitempty"`
+	// WorkspaceSize is the size of the build's workspace PVC: the one it was created with once it exists, else
+	// the one requested or estimated
+	WorkspaceSize string `json:"workspaceSize,omitempty"`
+	// WorkspaceSizeEstimate tells what the server estimated WorkspaceSize from, when the request did not set it
+	WorkspaceSizeEstimate string `json:"workspaceSizeEstimate,omitempty"`
+	// WorkspacePeakBytes is the most the workspace held, before pruning, once the build finished; compare it
+	// with WorkspaceSize
+	WorkspacePeakBytes int64        `json:"workspacePeakBytes,omitempty"`
+	Scan               *ScanSummary `json:"scan,omitempty"`
+	// Cost is set once a build finished when the operator is configured with prices
+	Cost *BuildCost `json:"cost,omitempty"`
+	// Reused is set when CreateBuild answered with an existing build instead of starting one
+	Reused bool `json:"reused,omitempty"`
+	// CachedImage is set when CreateBuild answered from the result cache with the Image an identical build
+	// was promoted to; the build itself may be gone, so the image is pulled from its registry instead
+	CachedImage *CachedImage `json:"cachedImage,omitempty"`
+	// Replayed is set when CreateBuild answered with the build an earlier request with the same
+	// Idempotency-Key created
+	Replayed bool `json:"replayed,omitempty"`
+	// LintWarnings are the lint violations of rules that only warn
+	LintWarnings []LintViolation `json:"lintWarnings,omitempty"`
+	// Conversions are the conversions of the artifact to other disk formats requested so far
+	Conversions []ArtifactConversion `json:"conversions,omitempty"`
+	// UploadProgress is set while the build waits for local files and the server recorded any
+	UploadProgress *UploadProgress `json:"uploadProgress,omitempty"`
+	// TaskRunURL, PodLogsURL and PVCURL link to the build's TaskRun, the logs of its pod and its workspace
+	// in the OpenShift web console, when the server is configured with the console's URL
+	TaskRunURL string `json:"taskRunURL,omitempty"`
+	PodLogsURL string `json:"podLogsURL,omitempty"`
+	PVCURL     string `json:"pvcURL,omitempty"`
+}
+
+// BuildCost is the estimated cost of a build. Amounts are decimal numbers, e.g. "0.1280"
+type BuildCost struct {
+	// Total is Compute plus Storage
+	Total   string `json:"total"`
+	Compute string `json:"compute,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	// Currency the amounts are in, e.g. "USD"
+	Currency string `json:"currency,omitempty"`
+	// InstanceTypes are the instance types of the nodes the build ran on, when known
+	InstanceTypes []string `json:"instanceTypes,omitempty"`
+}
+
+// ScanSummary counts the vulnerabilities the post-build scan found per severity; it is only set for scanned builds
+type ScanSummary struct {
+	Critical int32 `json:"critical"`
+	High     int32 `json:"high"`
+	Medium   int32 `json:"medium"`
+	Low      int32 `json:"low"`
+	// Blocked is set when the findings exceed the scan policy and the artifact is not served
+	Blocked bool `json:"blocked,omitempty"`
 }
 
 // BuildListItem represents a build in the list API
 type BuildListItem struct {
-	Name           string `json:"name"`
-	Phase          string `json:"phase"`
-	Message        string `json:"message"`
-	RequestedBy    string `json:"requestedBy,omitempty"`
-	CreatedAt      string `json:"createdAt"`
-	StartTime      string `json:"startTime,omitempty"`
-	CompletionTime string `json:"completionTime,omitempty"`
+	Name        string `json:"name"`
+	Phase       string `json:"phase"`
+	Message     string `json:"message"`
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// AccessGroups are the groups the build is shared with when access to builds is restricted
+	AccessGroups []string `json:"accessGroups,omitempty"`
+	// +format=date-time
+	CreatedAt string `json:"createdAt"`
+	// +format=date-time
+	StartTime string `json:"startTime,omitempty"`
+	// +format=date-time
+	CompletionTime string            `json:"completionTime,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	// ArtifactFileName and ArtifactSize describe the artifact of a completed build while it is kept
+	ArtifactFileName string `json:"artifactFileName,omitempty"`
+	ArtifactSize     int64  `json:"artifactSize,omitempty"`
+	// Encrypted is set when the artifacts are encrypted with the build's key
+	Encrypted bool `json:"encrypted,omitempty"`
+	// UploadProgress is set while the build waits for local files and the server recorded any
+	UploadProgress *UploadProgress `json:"uploadProgress,omitempty"`
 }
 
 type (
@@ -138,3 +365,511 @@ type BuildTemplateResponse struct {
 	BuildRequest `json:",inline"`
 	SourceFiles  []string `json:"sourceFiles,omitempty"`
 }
+
+// TaskRunStepStatus is a sanitized view of a single step of the TaskRun backing a build
+type TaskRunStepStatus struct {
+	Name      string `json:"name"`
+	Container string `json:"container,omitempty"`
+	// +enum=Waiting,Running,Terminated,Unknown
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode *int32 `json:"exitCode,omitempty"`
+	// +format=date-time
+	StartedAt string `json:"startedAt,omitempty"`
+	// +format=date-time
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
+
+// TaskRunResponse is a sanitized view of the TaskRun backing a build, for debugging stuck or failed builds
+type TaskRunResponse struct {
+	Name    string `json:"name"`
+	PodName string `json:"podName,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// +format=date-time
+	StartTime string `json:"startTime,omitempty"`
+	// +format=date-time
+	CompletionTime string              `json:"completionTime,omitempty"`
+	Steps          []TaskRunStepStatus `json:"steps,omitempty"`
+}
+
+// BuildTimelineResponse lists what happened to a build, oldest first
+type BuildTimelineResponse struct {
+	// +required
+	Name   string          `json:"name"`
+	Events []TimelineEvent `json:"events"`
+}
+
+// TimelineEvent is something that happened to a build or to an object it runs. Expiry events may lie in the future.
+type TimelineEvent struct {
+	// +format=date-time
+	// +required
+	Time string `json:"time"`
+	// Type is what happened: Created, UploadsStarted, UploadsFinished, BuildStarted, TaskRunCreated,
+	// TaskRunStarted, StepStarted, StepFinished, TaskRunFinished, Completed, Failed, ArtifactServing,
+	// ArtifactExpiry or WorkspaceExpiry, or the reason of a recorded Kubernetes Event such as Scheduled
+	// +required
+	Type string `json:"type"`
+	// Source is where the event was read from: status, taskrun or event
+	// +required
+	Source string `json:"source"`
+	// Object is the kind and name of the object the event happened to, e.g. Pod/qemu-abc12-build-x7k2p-pod
+	Object string `json:"object,omitempty"`
+	// Step is the TaskRun step of step events
+	Step    string `json:"step,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Warning is set for recorded Events of type Warning and for steps that failed
+	Warning bool `json:"warning,omitempty"`
+}
+
+// BuildUsageResponse is what the build step of a finished build consumed, to right-size build resources
+type BuildUsageResponse struct {
+	// +required
+	Name string `json:"name"`
+	// PeakMemoryBytes is the most memory the build step used at once
+	PeakMemoryBytes int64 `json:"peakMemoryBytes,omitempty"`
+	// CPUSeconds is the CPU time the build step used
+	CPUSeconds int64 `json:"cpuSeconds,omitempty"`
+	// DiskBytes is the space the build used in its build, output and osbuild directories
+	DiskBytes int64 `json:"diskBytes,omitempty"`
+	// MemoryVolumeSize is the size limit of those directories when the AutomotiveDev puts them on memory
+	// volumes, for comparison with DiskBytes
+	MemoryVolumeSize string `json:"memoryVolumeSize,omitempty"`
+	// WorkspaceBytes is the space the build's workspace holds after its intermediates were pruned
+	WorkspaceBytes int64 `json:"workspaceBytes,omitempty"`
+	// PrunedWorkspaceBytes is the space pruning the intermediates reclaimed
+	PrunedWorkspaceBytes int64 `json:"prunedWorkspaceBytes,omitempty"`
+	// PVCSize is the size of the build's workspace PVC, for comparison with SuggestedPVCSize
+	PVCSize string `json:"pvcSize,omitempty"`
+	// SuggestedPVCSize is a workspace PVC size that fits what the build held before pruning, with headroom
+	SuggestedPVCSize string `json:"suggestedPvcSize,omitempty"`
+}
+
+// ImageLifecycleRequest asks for an Image to be moved to a new lifecycle state
+type ImageLifecycleRequest struct {
+	// +required
+	// +enum=candidate,released,deprecated,revoked
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PromoteRequest asks for the artifact of a completed build to be pushed to a registry and cataloged as an
+// Image in another namespace
+type PromoteRequest struct {
+	// TargetNamespace is the namespace the Image is created in
+	// +required
+	TargetNamespace string `json:"targetNamespace"`
+	// Registry is where the artifact is pushed to
+	// +required
+	Registry PromoteRegistry `json:"registry"`
+	// ImageName names the Image; it defaults to the build name
+	ImageName string `json:"imageName,omitempty"`
+}
+
+// PromoteRegistry is the registry location a promoted artifact is pushed to
+type PromoteRegistry struct {
+	// URL is the tagged reference to push to, e.g. quay.io/org/image:1.0
+	// +required
+	URL string `json:"url"`
+	// SecretRef names a kubernetes.io/dockerconfigjson secret in the target namespace with push credentials.
+	// The Image refers to it to pull the artifact.
+	SecretRef string `json:"secretRef,omitempty"`
+	// Insecure talks to the registry over plain HTTP
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// PromoteResponse describes the Image a promotion created
+type PromoteResponse struct {
+	Image      string `json:"image"`
+	Namespace  string `json:"namespace"`
+	URL        string `json:"url"`
+	Digest     string `json:"digest"`
+	PromotedBy string `json:"promotedBy,omitempty"`
+	// +format=date-time
+	PromotedAt string `json:"promotedAt"`
+}
+
+// CachedImage is a promoted Image answering a build request from the result cache
+type CachedImage struct {
+	// +required
+	Name string `json:"name"`
+	// +required
+	Namespace string `json:"namespace"`
+	// URL is the registry reference the image was pushed to
+	// +required
+	URL    string `json:"url"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// ConvertRequest asks for the artifact of a completed build to be converted to another disk format
+type ConvertRequest struct {
+	// Format is one of qcow2, vmdk, vdi, vhdx or simg (Android sparse image)
+	// +required
+	Format string `json:"format"`
+}
+
+// ArtifactConversion reports the progress of the conversion of a build's artifact to another disk format.
+// Once completed, FileName is downloaded like the artifact itself.
+type ArtifactConversion struct {
+	Format string `json:"format"`
+	// Phase is Pending, Running, Completed or Failed
+	Phase    string `json:"phase"`
+	FileName string `json:"fileName,omitempty"`
+	// Size is the size of the converted file in bytes
+	Size int64 `json:"size,omitempty"`
+	// SHA256 is the hex SHA-256 checksum of the converted file
+	SHA256  string `json:"sha256,omitempty"`
+	Message string `json:"message,omitempty"`
+	// +format=date-time
+	StartTime string `json:"startTime,omitempty"`
+	// +format=date-time
+	CompletionTime string `json:"completionTime,omitempty"`
+}
+
+// ChecksumHeader is the multipart part header carrying the hex SHA-256 of an uploaded file
+const ChecksumHeader = "X-Checksum-Sha256"
+
+// UploadResponse reports the files placed in a build's workspace by an upload
+type UploadResponse struct {
+	Status string             `json:"status"`
+	Files  []UploadFileResult `json:"files"`
+}
+
+// UploadForm is the multipart form of an upload of local files
+type UploadForm struct {
+	// Manifest is an optional leading part mapping the file parts to their destinations. Without it the filename
+	// of each file part is its destination.
+	Manifest UploadManifest `json:"manifest"`
+	// File parts carry the files, their filename being the destination in the build's shared workspace, or the
+	// source of a manifest entry. Each may carry an X-Checksum-Sha256 part header with the hex SHA-256 of its
+	// content.
+	File []byte `json:"file"`
+	// Checksums is an optional trailing part mapping destination paths to hex SHA-256 checksums, for clients
+	// that cannot set part headers
+	Checksums map[string]string `json:"checksums"`
+}
+
+// UploadManifest lists the files and directories of an upload. Every file part must be named by an entry, and
+// every file entry must be uploaded in the same request.
+type UploadManifest struct {
+	// +required
+	Entries []UploadManifestEntry `json:"entries"`
+}
+
+// UploadManifestEntry places a file part, or creates a directory, in the build's shared workspace
+type UploadManifestEntry struct {
+	// Source is the filename of the file part carrying the file; directories have none
+	Source string `json:"source,omitempty"`
+	// Dest is the destination relative to the build's shared workspace
+	// +required
+	Dest string `json:"dest"`
+	// +enum=file,dir
+	// +default=file
+	Type string `json:"type,omitempty"`
+	// Mode is the octal permissions to set, e.g. "0755"; without it the upload pod's defaults apply
+	Mode string `json:"mode,omitempty"`
+	// Sha256 is the hex SHA-256 of the file, verified like that of its part header
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// Upload manifest entry types
+const (
+	UploadEntryFile = "file"
+	UploadEntryDir  = "dir"
+)
+
+// UploadErrorResponse reports a failed upload, with the results of the files when a checksum did not match
+type UploadErrorResponse struct {
+	Error string             `json:"error"`
+	Files []UploadFileResult `json:"files"`
+}
+
+// UploadedFile reports how many bytes of a file a build's workspace holds, so an interrupted upload can resume
+type UploadedFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// StartUploadRequest announces a file about to be uploaded in chunks
+type StartUploadRequest struct {
+	// Size is the size of the whole file in bytes
+	// +required
+	Size int64 `json:"size"`
+	// Sha256 is the hex SHA-256 of the whole file
+	// +required
+	Sha256 string `json:"sha256"`
+}
+
+// FinishUploadRequest asks to verify a file uploaded in chunks against its hex SHA-256
+type FinishUploadRequest struct {
+	// +required
+	Sha256 string `json:"sha256"`
+}
+
+// UploadTotalsRequest announces how much a client is about to upload, so the build can report its progress as a
+// percentage
+type UploadTotalsRequest struct {
+	// Files is the number of files to upload
+	// +required
+	Files int `json:"files"`
+	// Bytes is the size of all files to upload
+	// +required
+	Bytes int64 `json:"bytes"`
+}
+
+// UploadProgress is how much of its uploads the workspace of a build waiting for local files holds
+type UploadProgress struct {
+	// ReceivedBytes and ReceivedFiles count the files stored so far, including partly uploaded ones
+	ReceivedBytes int64 `json:"receivedBytes"`
+	ReceivedFiles int   `json:"receivedFiles"`
+	// TotalBytes and TotalFiles are what the client announced it uploads, 0 if it did not
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	TotalFiles int   `json:"totalFiles,omitempty"`
+	// Percent is the share of TotalBytes received so far, -1 if the client announced no totals
+	Percent int `json:"percent"`
+	// UpdatedAt is when the workspace last received data; a stalled upload stops advancing it
+	// +format=date-time
+	UpdatedAt string `json:"updatedAt,omitempty"`
+}
+
+// CompleteUploadsRequest lists the files uploaded in chunks, mapping each destination path to its hex SHA-256
+type CompleteUploadsRequest struct {
+	// +required
+	Files map[string]string `json:"files"`
+}
+
+// UploadFileResult describes one uploaded file as found in the upload pod
+type UploadFileResult struct {
+	Path string `json:"path"`
+	// Sha256 is the checksum of the file computed in the upload pod after the copy
+	Sha256 string `json:"sha256"`
+	// ExpectedSha256 is the checksum the client sent, if any
+	ExpectedSha256 string `json:"expectedSha256,omitempty"`
+	// Verified is true when the client sent a checksum and it matches
+	Verified bool `json:"verified"`
+}
+
+// ImageLifecycleResponse reports the outcome of a lifecycle transition
+type ImageLifecycleResponse struct {
+	Name      string `json:"name"`
+	Previous  string `json:"previous"`
+	State     string `json:"state"`
+	ChangedBy string `json:"changedBy,omitempty"`
+	// +format=date-time
+	ChangedAt string `json:"changedAt,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// ImageResponse describes an Image of the catalog
+type ImageResponse struct {
+	Name         string   `json:"name"`
+	Distro       string   `json:"distro"`
+	Target       string   `json:"target"`
+	Architecture string   `json:"architecture"`
+	ExportFormat string   `json:"exportFormat"`
+	Mode         string   `json:"mode,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	// +enum=candidate,released,deprecated,revoked
+	Lifecycle string `json:"lifecycle"`
+	// URL is the registry reference the image is pulled from
+	URL    string `json:"url,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	// SizeBytes is the compressed size of the image, when known
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+	// SourceBuild is the build that produced the image; empty for registered external images
+	SourceBuild string `json:"sourceBuild,omitempty"`
+	CreatedBy   string `json:"createdBy,omitempty"`
+	// +format=date-time
+	CreatedAt string `json:"createdAt,omitempty"`
+	// Phase is the availability the operator last verified
+	Phase string `json:"phase,omitempty"`
+}
+
+// RegisterImageRequest catalogs an image built outside the cluster, which is already pushed to a registry
+type RegisterImageRequest struct {
+	// +required
+	Name string `json:"name"`
+	// +required
+	Distro string `json:"distro"`
+	// +required
+	Target string `json:"target"`
+	// +required
+	Architecture string `json:"architecture"`
+	// +required
+	ExportFormat string `json:"exportFormat"`
+	Mode         string `json:"mode,omitempty"`
+	// URL is the registry reference of the image, e.g. quay.io/org/image:1.0 or one pinned by digest
+	// +required
+	URL string `json:"url"`
+	// SecretRef names a kubernetes.io/dockerconfigjson secret with the credentials to pull the image
+	SecretRef   string   `json:"secretRef,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Description string   `json:"description,omitempty"`
+	// SizeBytes is the compressed size of the image, when known
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+}
+
+// NamespaceHeader selects the namespace a request acts on; requests without it use the server's default namespace
+const NamespaceHeader = "X-Build-Namespace"
+
+// IdempotencyKeyHeader identifies a build creation request, so a retry of it returns the build it created
+// instead of creating another one
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ServerInfoResponse describes the build API instance
+type ServerInfoResponse struct {
+	// DefaultNamespace is the namespace used when a request does not select one
+	DefaultNamespace string `json:"defaultNamespace"`
+	// Features are the feature gates the AutomotiveDev turns on, e.g. enableQueueing
+	Features []string `json:"features,omitempty"`
+}
+
+// ReadyzResponse tells whether the APIs the build API depends on are installed
+type ReadyzResponse struct {
+	// Ready is set once every dependency was found
+	Ready bool `json:"ready"`
+	// CheckedAt is when the dependencies were last checked, empty before the first check finished
+	// +format=date-time
+	CheckedAt    string             `json:"checkedAt,omitempty"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+// DependencyStatus is the state of one API the build API depends on
+type DependencyStatus struct {
+	// Name names the dependency, e.g. "Tekton Pipelines"
+	Name string `json:"name"`
+	// APIVersion is the group version the dependency serves, e.g. tekton.dev/v1
+	APIVersion string `json:"apiVersion"`
+	Available  bool   `json:"available"`
+	// Missing are the resources of APIVersion that are not served
+	Missing []string `json:"missing,omitempty"`
+	// Message tells why the dependency is unavailable
+	Message string `json:"message,omitempty"`
+	// Remediation tells how to install the dependency
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// BuildStatsResponse summarizes the builds created within a time window
+type BuildStatsResponse struct {
+	// Window is the length of the window, e.g. "168h0m0s"
+	Window string `json:"window"`
+	// Since is the start of the window in RFC 3339
+	// +format=date-time
+	Since string `json:"since"`
+	// Total is the number of builds created within the window
+	Total int `json:"total"`
+	// ByPhase counts the builds by phase; builds not picked up yet count as "Pending"
+	ByPhase map[string]int `json:"byPhase"`
+	// SuccessRate is the share of finished builds that completed, between 0 and 1
+	SuccessRate float64 `json:"successRate"`
+	// Durations describes how long finished builds ran
+	Durations DurationStats `json:"durations"`
+	// ByDistro and ByTarget break the builds down by distribution and target
+	ByDistro map[string]BuildGroupStats `json:"byDistro"`
+	ByTarget map[string]BuildGroupStats `json:"byTarget"`
+	// Costs sums the estimated costs of the builds that have one, per currency
+	Costs []CostStats `json:"costs,omitempty"`
+}
+
+// CostStats sums the estimated costs of builds in one currency
+type CostStats struct {
+	Currency string `json:"currency"`
+	// Builds is the number of builds with a cost
+	Builds int     `json:"builds"`
+	Total  float64 `json:"total"`
+	// ByNamespace and ByRequester break the total down by the namespace of the builds and the user who
+	// requested them. Builds not created through the API count as "unknown"
+	ByNamespace map[string]float64 `json:"byNamespace"`
+	ByRequester map[string]float64 `json:"byRequester"`
+}
+
+// StorageQuotaResponse reports the storage held by the live build workspace PVCs of a namespace
+type StorageQuotaResponse struct {
+	Namespace string `json:"namespace"`
+	// UsedBytes is the total size of the workspaces: their capacity once bound and their request until then
+	UsedBytes int64 `json:"usedBytes"`
+	// Workspaces is the number of workspaces
+	Workspaces int `json:"workspaces"`
+	// LimitBytes is the BuildConfig's MaxWorkspaceStorage; 0 means storage is unlimited
+	LimitBytes int64 `json:"limitBytes"`
+	// ByRequester breaks the usage down by the user who requested each build. Builds not created
+	// through the API count as "unknown"
+	ByRequester map[string]StorageUsage `json:"byRequester"`
+}
+
+// StorageUsage is the storage held by a group of build workspaces
+type StorageUsage struct {
+	UsedBytes  int64 `json:"usedBytes"`
+	Workspaces int   `json:"workspaces"`
+}
+
+// SweepResponse reports the builds an upload sweep cancelled for having waited for their files too long
+type SweepResponse struct {
+	Namespace string `json:"namespace"`
+	// Timeout is how long a build may go without receiving a file, the BuildConfig's UploadTimeoutMinutes
+	Timeout string `json:"timeout"`
+	// DryRun is set when the builds were only reported, not cancelled
+	DryRun bool         `json:"dryRun,omitempty"`
+	Builds []SweptBuild `json:"builds"`
+	// ReclaimedBytes is the total size of the workspaces of the builds
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
+// SweptBuild is a build an upload sweep cancelled and what cancelling it releases
+type SweptBuild struct {
+	Name        string `json:"name"`
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// Idle is how long the build went without receiving a file, e.g. "3h12m0s"
+	Idle string `json:"idle"`
+	// Workspaces are the names of the build's workspace PVCs and WorkspaceBytes their size
+	Workspaces     []string `json:"workspaces,omitempty"`
+	WorkspaceBytes int64    `json:"workspaceBytes"`
+	// UploadPod is the upload server of the build, if it was running
+	UploadPod string `json:"uploadPod,omitempty"`
+}
+
+// DurationStats describes a set of build durations in seconds
+type DurationStats struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"averageSeconds"`
+	P50     float64 `json:"p50Seconds"`
+	P90     float64 `json:"p90Seconds"`
+	P99     float64 `json:"p99Seconds"`
+}
+
+// BuildGroupStats summarizes the builds sharing a distribution or target
+type BuildGroupStats struct {
+	Total       int     `json:"total"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	SuccessRate float64 `json:"successRate"`
+	// AverageDuration is the mean duration of the group's finished builds in seconds
+	AverageDuration float64 `json:"averageDurationSeconds"`
+}
+
+// GitHookResponse lists the builds a Git webhook delivery started
+type GitHookResponse struct {
+	// Repository is the full name of the pushed repository
+	Repository string `json:"repository,omitempty"`
+	// Ref is the pushed ref, e.g. refs/heads/main or refs/tags/v1.0
+	Ref string `json:"ref,omitempty"`
+	// Commit is the SHA of the pushed commit, passed to the builds as the GIT_COMMIT define
+	Commit string `json:"commit,omitempty"`
+	// Builds are the builds started, or started by an earlier delivery of the same event
+	Builds []GitHookBuild `json:"builds"`
+	// Message says why a delivery started no build
+	Message string `json:"message,omitempty"`
+}
+
+// GitHookBuild is a build started by a Git trigger
+type GitHookBuild struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// TemplateBuild is the build whose inputs the build reuses
+	TemplateBuild string `json:"templateBuild"`
+}
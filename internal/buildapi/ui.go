@@ -0,0 +1,132 @@
+package buildapi
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uiFiles is the single-page web UI served at /ui/; it is plain HTML, CSS and JavaScript without a build step
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiIndex is the page every UI path without a file extension is answered with
+const uiIndex = "index.html"
+
+// uiAsset is a UI file ready to be served, with the ETag of its content
+type uiAsset struct {
+	content     []byte
+	contentType string
+	etag        string
+	// version is the query parameter index.html references the asset with; requests carrying it may be
+	// cached for good, as a changed asset gets a new one
+	version string
+}
+
+var uiAssets = loadUIAssets()
+
+// loadUIAssets reads the embedded UI and points the {{file}} placeholders of index.html at the versioned
+// assets, so browsers fetch an asset again exactly when it changed
+func loadUIAssets() map[string]*uiAsset {
+	assets := map[string]*uiAsset{}
+	err := fs.WalkDir(uiFiles, "ui", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := uiFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(p, "ui/")
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		assets[name] = &uiAsset{content: content, contentType: contentType}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var replacements []string
+	for name, asset := range assets {
+		sum := sha256.Sum256(asset.content)
+		asset.version = hex.EncodeToString(sum[:6])
+		if name != uiIndex {
+			replacements = append(replacements, "{{"+name+"}}", name+"?v="+asset.version)
+		}
+	}
+	index := assets[uiIndex]
+	index.content = []byte(strings.NewReplacer(replacements...).Replace(string(index.content)))
+	for _, asset := range assets {
+		sum := sha256.Sum256(asset.content)
+		asset.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+	return assets
+}
+
+// UIDisabledFromEnv reports whether $BUILD_API_DISABLE_UI turns the web UI off
+func UIDisabledFromEnv() bool {
+	disabled, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("BUILD_API_DISABLE_UI")))
+	return disabled
+}
+
+// DisableUI stops serving the web UI; /ui/ then answers 404
+func (a *APIServer) DisableUI() {
+	a.uiDisabled = true
+}
+
+func (a *APIServer) handleUIRedirect(c *gin.Context) {
+	if a.uiDisabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the web UI is disabled"})
+		return
+	}
+	// relative, so that it holds behind a path prefix; c.Redirect would make it absolute
+	c.Header("Location", "ui/")
+	c.Status(http.StatusMovedPermanently)
+}
+
+// handleUI serves the files of the web UI. index.html is revalidated on every load, so an upgraded server
+// takes effect at once; it references the other assets by version, so they are cached until they change.
+// The UI calls the API with the browser's session, which the OAuth proxy behind the build-api-ui Route
+// forwards as X-Forwarded-Access-Token.
+func (a *APIServer) handleUI(c *gin.Context) {
+	if a.uiDisabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the web UI is disabled"})
+		return
+	}
+	name := strings.TrimPrefix(c.Param("path"), "/")
+	if name == "" || path.Ext(name) == "" {
+		name = uiIndex
+	}
+	asset, ok := uiAssets[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	if name != uiIndex && c.Query("v") == asset.version {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	c.Header("ETag", asset.etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+	if c.GetHeader("If-None-Match") == asset.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, asset.contentType, asset.content)
+}
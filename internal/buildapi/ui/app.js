@@ -0,0 +1,246 @@
+// The web UI of the build API: a build list, build details with live logs and artifacts, and a build form.
+// It talks to the API it is served by, relative to /ui/, so it also works behind a path prefix.
+"use strict";
+
+const api = new URL("../v1/", document.baseURI);
+const finished = ["Completed", "Failed", "Cancelled"];
+let logStream = null;
+let refreshTimer = null;
+
+async function request(path, options = {}) {
+  const resp = await fetch(new URL(path, api), {
+    credentials: "same-origin",
+    ...options,
+    headers: { "Accept": "application/json", ...(options.headers || {}) },
+  });
+  const body = await resp.text();
+  if (!resp.ok) {
+    let message = body;
+    try {
+      message = JSON.parse(body).error || body;
+    } catch (e) {
+      // not JSON; show the body as is
+    }
+    throw new Error(`${resp.status} ${resp.statusText}: ${message}`);
+  }
+  return body ? JSON.parse(body) : null;
+}
+
+function showError(err) {
+  const el = document.getElementById("error");
+  el.textContent = err ? err.message : "";
+  el.hidden = !err;
+}
+
+function el(tag, text, attrs = {}) {
+  const node = document.createElement(tag);
+  if (text !== undefined && text !== null) {
+    node.textContent = text;
+  }
+  for (const [k, v] of Object.entries(attrs)) {
+    node.setAttribute(k, v);
+  }
+  return node;
+}
+
+function formatSize(bytes) {
+  const n = Number(bytes);
+  if (!n || n <= 0) {
+    return "-";
+  }
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let i = 0;
+  let v = n;
+  while (v >= 1024 && i < units.length - 1) {
+    v /= 1024;
+    i++;
+  }
+  return i === 0 ? `${n} B` : `${v.toFixed(1)} ${units[i]}`;
+}
+
+function buildPath(name, ...rest) {
+  return ["builds", encodeURIComponent(name), ...rest.map(encodeURIComponent)].join("/");
+}
+
+function show(view) {
+  for (const id of ["builds-view", "build-view", "new-view"]) {
+    document.getElementById(id).hidden = id !== view;
+  }
+}
+
+function stopUpdates() {
+  if (logStream) {
+    logStream.close();
+    logStream = null;
+  }
+  clearTimeout(refreshTimer);
+}
+
+async function listBuilds() {
+  show("builds-view");
+  const builds = await request("builds");
+  builds.sort((a, b) => (b.createdAt || "").localeCompare(a.createdAt || ""));
+  const tbody = document.getElementById("builds");
+  tbody.replaceChildren();
+  for (const b of builds) {
+    const row = el("tr");
+    const link = el("a", b.name, { href: `#/builds/${encodeURIComponent(b.name)}` });
+    row.append(el("td"), el("td", b.phase, { class: `phase-${b.phase}` }), el("td", b.requestedBy || "-"),
+      el("td", b.createdAt), el("td", b.artifactFileName ? `${b.artifactFileName} (${formatSize(b.artifactSize)})` : "-"),
+      el("td", b.message));
+    row.firstChild.append(link);
+    tbody.append(row);
+  }
+  document.getElementById("no-builds").hidden = builds.length > 0;
+  refreshTimer = setTimeout(() => route().catch(showError), 10000);
+}
+
+async function showBuild(name) {
+  show("build-view");
+  document.getElementById("build-name").textContent = name;
+  const build = await request(buildPath(name));
+  const details = document.getElementById("build-details");
+  details.replaceChildren();
+  for (const [label, value] of [
+    ["Status", build.phase], ["Message", build.message], ["Requested by", build.requestedBy],
+    ["Started", build.startTime], ["Finished", build.completionTime], ["Workspace", build.workspaceSize],
+  ]) {
+    if (value) {
+      details.append(el("dt", label), el("dd", value));
+    }
+  }
+
+  const artifacts = document.getElementById("artifacts");
+  artifacts.replaceChildren();
+  if (build.phase === "Completed") {
+    if (build.artifactFileName) {
+      const item = el("li");
+      item.append(el("a", build.artifactFileName, {
+        href: new URL(buildPath(name, "artifact", build.artifactFileName), api), download: build.artifactFileName,
+      }));
+      artifacts.append(item);
+    }
+    try {
+      const list = await request(buildPath(name, "artifacts"));
+      for (const part of list.items || []) {
+        const item = el("li");
+        item.append(el("a", part.name, { href: new URL(buildPath(name, "artifacts", part.name), api), download: part.name }),
+          document.createTextNode(` (${formatSize(part.sizeBytes)})`));
+        artifacts.append(item);
+      }
+    } catch (err) {
+      artifacts.append(el("li", `Artifacts are not available: ${err.message}`));
+    }
+  } else {
+    artifacts.append(el("li", "Artifacts are available once the build completes."));
+  }
+
+  followLogs(name);
+  if (!finished.includes(build.phase)) {
+    refreshTimer = setTimeout(() => refreshBuild(name).catch(showError), 10000);
+  }
+}
+
+// refreshBuild re-reads a running build until it finishes, leaving its log stream open
+async function refreshBuild(name) {
+  if (location.hash !== `#/builds/${encodeURIComponent(name)}`) {
+    return;
+  }
+  const build = await request(buildPath(name));
+  if (finished.includes(build.phase)) {
+    stopUpdates();
+    await showBuild(name);
+    return;
+  }
+  refreshTimer = setTimeout(() => refreshBuild(name).catch(showError), 10000);
+}
+
+function followLogs(name) {
+  const logs = document.getElementById("logs");
+  logs.textContent = "";
+  const append = (line) => {
+    const atBottom = logs.scrollTop + logs.clientHeight >= logs.scrollHeight - 4;
+    logs.append(line.replaceAll("\\n", "\n") + "\n");
+    if (atBottom) {
+      logs.scrollTop = logs.scrollHeight;
+    }
+  };
+  logStream = new EventSource(new URL(buildPath(name, "logs", "sse"), api), { withCredentials: true });
+  // EventSource reconnects dropped streams by itself, and a reconnected stream starts over
+  logStream.addEventListener("connected", () => {
+    logs.textContent = "";
+  });
+  for (const event of ["step", "log", "waiting", "error", "message"]) {
+    logStream.addEventListener(event, (e) => {
+      if (e.data) {
+        append(e.data);
+      }
+    });
+  }
+  logStream.addEventListener("completed", () => {
+    logStream.close();
+    logStream = null;
+  });
+}
+
+async function newBuild() {
+  show("new-view");
+  const form = document.getElementById("new-build");
+  const catalog = await request("catalog");
+  for (const [field, values] of [
+    ["distro", catalog.distros], ["target", catalog.targets], ["architecture", catalog.architectures],
+  ]) {
+    const select = form.elements[field];
+    const current = select.value;
+    select.replaceChildren(...(values || []).map((v) => el("option", v, { value: v })));
+    if (current) {
+      select.value = current;
+    }
+  }
+}
+
+async function submitBuild(event) {
+  event.preventDefault();
+  const form = event.target;
+  const file = form.elements.manifestFile.files[0];
+  const req = {
+    name: form.elements.name.value.trim(),
+    distro: form.elements.distro.value,
+    target: form.elements.target.value,
+    architecture: form.elements.architecture.value,
+    exportFormat: form.elements.exportFormat.value.trim(),
+    mode: form.elements.mode.value,
+    manifest: form.elements.manifest.value,
+    manifestFileName: file ? file.name : "manifest.aib.yml",
+    serveArtifact: form.elements.serveArtifact.checked,
+  };
+  const resp = await request("builds", {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(req),
+  });
+  location.hash = `#/builds/${encodeURIComponent(resp.name)}`;
+}
+
+async function route() {
+  stopUpdates();
+  showError(null);
+  const hash = location.hash || "#/";
+  if (hash.startsWith("#/builds/")) {
+    await showBuild(decodeURIComponent(hash.slice("#/builds/".length)));
+  } else if (hash === "#/new") {
+    await newBuild();
+  } else {
+    await listBuilds();
+  }
+}
+
+document.getElementById("new-build").addEventListener("submit", (e) => submitBuild(e).catch(showError));
+document.querySelector("input[name=manifestFile]").addEventListener("change", async (e) => {
+  const file = e.target.files[0];
+  if (file) {
+    e.target.form.elements.manifest.value = await file.text();
+  }
+});
+window.addEventListener("hashchange", () => route().catch(showError));
+route().catch(showError);
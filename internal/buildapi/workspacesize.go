@@ -0,0 +1,105 @@
+package buildapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// Workspace size estimate: the osbuild store holds a base tree and every package of the image, and the export
+// holds the image as many times as its format writes copies of it, e.g. the raw disk and its compressed artifact
+const (
+	workspaceBaseBytes    = int64(2) << 30
+	workspacePackageBytes = int64(20) << 20
+	minWorkspaceBytes     = int64(4) << 30
+)
+
+// exportCopies is how many copies of the image an export format holds in the workspace at once; formats not
+// listed hold one
+var exportCopies = map[string]int64{
+	"image": 2,
+	"qcow2": 2,
+	"simg":  2,
+	"aboot": 2,
+	"ext4":  2,
+}
+
+// workspaceHints are what the manifests and defines of a build tell about the space it needs
+type workspaceHints struct {
+	// ImageSizeBytes is the image_size of the manifest or of a define, which wins; 0 when neither sets it
+	ImageSizeBytes int64
+	// Packages counts the rpms of the manifests, including those of their qm partition
+	Packages     int
+	ExportFormat string
+}
+
+// manifestWorkspaceHints reads the hints of a build's manifests. Manifests that are not valid YAML and sizes
+// that do not parse are ignored; the estimate then rests on what is left.
+func manifestWorkspaceHints(manifests []ManifestFile, customDefs []string, exportFormat string) workspaceHints {
+	hints := workspaceHints{ExportFormat: exportFormat}
+	for _, f := range manifests {
+		var m map[string]any
+		if err := yaml.Unmarshal([]byte(f.Content), &m); err != nil {
+			continue
+		}
+		hints.Packages += len(manifestStrings(m, "content", "rpms")) + len(manifestStrings(m, "qm", "content", "rpms"))
+		var size string
+		switch v := manifestValue(m, "image", "image_size").(type) {
+		case string:
+			size = v
+		case float64:
+			size = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if n, err := parseSize(size); err == nil {
+			hints.ImageSizeBytes = n
+		}
+	}
+	for _, def := range customDefs {
+		if k, v, ok := strings.Cut(def, "="); ok && strings.TrimSpace(k) == "image_size" {
+			if n, err := parseSize(v); err == nil {
+				hints.ImageSizeBytes = n
+			}
+		}
+	}
+	return hints
+}
+
+// estimate returns the workspace PVC size the hints call for, rounded up to whole GiB with a quarter of
+// headroom, and what it was estimated from. It returns an empty size when the manifests give no hint, leaving
+// the build the AutomotiveDev's default.
+func (h workspaceHints) estimate() (string, string) {
+	if h.ImageSizeBytes == 0 && h.Packages == 0 {
+		return "", ""
+	}
+	copies, ok := exportCopies[h.ExportFormat]
+	if !ok {
+		copies = 1
+	}
+	bytes := workspaceBaseBytes + int64(h.Packages)*workspacePackageBytes + copies*h.ImageSizeBytes
+	bytes += bytes / 4
+
+	const gi = int64(1) << 30
+	size := max((bytes+gi-1)/gi*gi, minWorkspaceBytes)
+
+	var basis []string
+	if h.ImageSizeBytes > 0 {
+		basis = append(basis, fmt.Sprintf("image_size %s x%d for the %s export",
+			resource.NewQuantity(h.ImageSizeBytes, resource.BinarySI), copies, h.ExportFormat))
+	}
+	if h.Packages > 0 {
+		basis = append(basis, fmt.Sprintf("%d packages", h.Packages))
+	}
+	return resource.NewQuantity(size, resource.BinarySI).String(), strings.Join(basis, ", ")
+}
+
+// validateWorkspaceSize checks a workspace size a build requests
+func validateWorkspaceSize(size string) error {
+	q, err := resource.ParseQuantity(size)
+	if err != nil || q.Sign() <= 0 {
+		return newError(ErrInvalidInput, "invalid workspaceSize %q: must be a positive quantity such as 24Gi", size)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package artifactname names the artifact files builds write to their workspace. The name is a Go template
+// over the fields of the build, set per build or by the AutomotiveDev's BuildConfig, followed by the extension
+// of the export format. Templates may only hold text and fields: actions such as range, if or function calls
+// are refused, as the build API and the controller run templates of any caller allowed to create builds. The controller passes the name to the build task, the build API expects the artifact
+// under it for builds that did not report one, and caib checks templates before submitting a build.
+package artifactname
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// DefaultTemplate names artifacts after their distro and target, e.g. "autosd-qemu"
+const DefaultTemplate = "{{.Distro}}-{{.Target}}"
+
+// DateFormat is the layout of the Date field
+const DateFormat = "20060102"
+
+const (
+	// MaxTemplateLength bounds the length of a template
+	MaxTemplateLength = 256
+
+	// MaxLength bounds the length of a rendered name, leaving room for extensions and suffixes in a file name
+	MaxLength = 200
+)
+
+// Fields are what a template names an artifact with
+type Fields struct {
+	Distro string
+	Target string
+	Arch   string
+	// Name is the name of the ImageBuild
+	Name string
+	// Date is the UTC day the build was created, e.g. "20261015"
+	Date string
+}
+
+// validName is what a rendered template must look like: a file name without path separators or whitespace
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// Render executes tmpl with f without checking the result beyond its length. tmpl may only hold text and
+// fields of f.
+func Render(tmpl string, f Fields) (string, error) {
+	if len(tmpl) > MaxTemplateLength {
+		return "", fmt.Errorf("artifact name template is longer than %d characters", MaxTemplateLength)
+	}
+	t, err := template.New("artifact-name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact name template %q: %w", tmpl, err)
+	}
+	if t.Tree != nil {
+		if err := checkNodes(t.Tree.Root); err != nil {
+			return "", fmt.Errorf("invalid artifact name template %q: %w", tmpl, err)
+		}
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, f); err != nil {
+		return "", fmt.Errorf("invalid artifact name template %q: %w", tmpl, err)
+	}
+	if out.Len() > MaxLength {
+		return "", fmt.Errorf("artifact name template %q renders a name longer than %d characters", tmpl, MaxLength)
+	}
+	return out.String(), nil
+}
+
+// checkNodes refuses anything but text and plain fields such as {{.Target}}
+func checkNodes(list *parse.ListNode) error {
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 || len(n.Pipe.Cmds) != 1 || len(n.Pipe.Cmds[0].Args) != 1 {
+				return fmt.Errorf("%s: only fields such as {{.Target}} are allowed", n)
+			}
+			field, ok := n.Pipe.Cmds[0].Args[0].(*parse.FieldNode)
+			if !ok || len(field.Ident) != 1 {
+				return fmt.Errorf("%s: only fields such as {{.Target}} are allowed", n)
+			}
+		default:
+			return fmt.Errorf("%s: only fields such as {{.Target}} are allowed", node)
+		}
+	}
+	return nil
+}
+
+// Base renders tmpl with f into the name of an artifact without its extension
+func Base(tmpl string, f Fields) (string, error) {
+	name, err := Render(tmpl, f)
+	if err != nil {
+		return "", err
+	}
+	if !validName.MatchString(name) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("artifact name template %q renders %q, which is not a valid file name", tmpl, name)
+	}
+	return name, nil
+}
+
+// Validate checks that tmpl renders a valid file name
+func Validate(tmpl string) error {
+	_, err := Base(tmpl, Fields{Distro: "autosd", Target: "qemu", Arch: "x86_64", Name: "build", Date: "20060102"})
+	return err
+}
+
+// Extension returns the extension of the artifacts of an export format, e.g. ".raw" for "image"
+func Extension(exportFormat string) string {
+	switch exportFormat {
+	case "image":
+		return ".raw"
+	case "qcow2":
+		return ".qcow2"
+	default:
+		return "." + exportFormat
+	}
+}
+
+// Template returns the template a build names its artifact with: its own, else the BuildConfig's, else
+// DefaultTemplate
+func Template(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) string {
+	if imageBuild.Spec.ArtifactNameTemplate != "" {
+		return imageBuild.Spec.ArtifactNameTemplate
+	}
+	if buildConfig != nil && buildConfig.ArtifactNameTemplate != "" {
+		return buildConfig.ArtifactNameTemplate
+	}
+	return DefaultTemplate
+}
+
+// FieldsOf returns the fields of a build
+func FieldsOf(imageBuild *automotivev1.ImageBuild) Fields {
+	created := imageBuild.CreationTimestamp.Time
+	if created.IsZero() {
+		created = time.Now()
+	}
+	return Fields{
+		Distro: imageBuild.Spec.Distro,
+		Target: imageBuild.Spec.Target,
+		Arch:   imageBuild.Spec.Architecture,
+		Name:   imageBuild.Name,
+		Date:   created.UTC().Format(DateFormat),
+	}
+}
+
+// ForBuild returns the name of a build's artifact without its extension
+func ForBuild(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) (string, error) {
+	return Base(Template(imageBuild, buildConfig), FieldsOf(imageBuild))
+}
+
+// FileName returns the file name of a build's artifact as the build task writes it, before compression.
+// Builds whose template no longer renders a valid name fall back to DefaultTemplate.
+func FileName(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) string {
+	base, err := ForBuild(imageBuild, buildConfig)
+	if err != nil {
+		base, _ = Render(DefaultTemplate, FieldsOf(imageBuild))
+	}
+	return base + Extension(imageBuild.Spec.ExportFormat)
+}
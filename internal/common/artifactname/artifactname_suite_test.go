@@ -0,0 +1,13 @@
+package artifactname
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifactname(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "artifactname Suite")
+}
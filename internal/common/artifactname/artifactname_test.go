@@ -0,0 +1,78 @@
+package artifactname
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Artifact names", func() {
+	fields := Fields{Distro: "autosd", Target: "qemu", Arch: "aarch64", Name: "nightly", Date: "20261015"}
+
+	DescribeTable("should render text and fields",
+		func(tmpl, want string) {
+			Expect(Base(tmpl, fields)).To(Equal(want))
+		},
+		Entry("the default", DefaultTemplate, "autosd-qemu"),
+		Entry("every field", "{{.Name}}_{{.Distro}}-{{.Target}}.{{.Arch}}+{{.Date}}", "nightly_autosd-qemu.aarch64+20261015"),
+		Entry("plain text", "disk", "disk"),
+		Entry("trimmed fields", "{{- .Name -}} - {{- .Date}}", "nightly-20261015"),
+	)
+
+	DescribeTable("should refuse templates that are not only text and fields",
+		func(tmpl string) {
+			_, err := Render(tmpl, fields)
+			Expect(err).To(MatchError(ContainSubstring("only fields")))
+		},
+		Entry("range over a number", "{{range 20000000}}xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx{{end}}"),
+		Entry("nested ranges", "{{range 1000}}{{range 1000}}{{range 1000}}x{{end}}{{end}}{{end}}"),
+		Entry("conditions", `{{if .Name}}a{{end}}`),
+		Entry("functions", `{{printf "%0999999d" 1}}`),
+		Entry("pipelines", `{{.Name | printf "%s"}}`),
+		Entry("variables", `{{$x := .Name}}`),
+		Entry("templates", `{{define "x"}}a{{end}}{{template "x"}}`),
+		Entry("constants", `{{"a"}}`),
+		Entry("the whole build", `{{.}}`),
+		Entry("with", `{{with .Name}}{{.}}{{end}}`),
+	)
+
+	It("should refuse hostile templates quickly and without rendering them", func() {
+		start := time.Now()
+		Expect(Validate("{{range 20000000}}xxxx{{end}}")).To(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("should bound the length of templates and names", func() {
+		_, err := Render(strings.Repeat("x", MaxTemplateLength+1), fields)
+		Expect(err).To(MatchError(ContainSubstring("longer than")))
+
+		long := Fields{Name: strings.Repeat("n", 63)}
+		_, err = Render("{{.Name}}{{.Name}}{{.Name}}{{.Name}}", long)
+		Expect(err).To(MatchError(ContainSubstring("renders a name longer than")))
+	})
+
+	It("should refuse names that are not file names", func() {
+		for _, tmpl := range []string{"../{{.Name}}", "a/b", "{{.Name}} x", ".hidden", "a..b", "{{.Missing}}"} {
+			Expect(Validate(tmpl)).To(HaveOccurred(), tmpl)
+		}
+	})
+
+	It("should fall back to the default template for builds whose template no longer renders", func() {
+		build := &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", CreationTimestamp: metav1.NewTime(time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC))},
+			Spec: automotivev1.ImageBuildSpec{
+				Distro: "autosd", Target: "qemu", ExportFormat: "qcow2",
+				ArtifactNameTemplate: "{{range 3}}x{{end}}",
+			},
+		}
+		Expect(FileName(build, nil)).To(Equal("autosd-qemu.qcow2"))
+
+		build.Spec.ArtifactNameTemplate = ""
+		Expect(FileName(build, &automotivev1.BuildConfig{ArtifactNameTemplate: "{{.Name}}-{{.Date}}"})).To(Equal("b-20261015.qcow2"))
+	})
+})
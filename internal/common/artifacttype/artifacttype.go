@@ -0,0 +1,101 @@
+// Package artifacttype names the media types of the files builds produce. The build task records the type
+// of an artifact when it packages it; the build API serves files with it and caib names downloads after it.
+// ForFile derives a type from a file name for files built before types were recorded and for the parts and
+// conversions of an artifact.
+package artifacttype
+
+import (
+	"path"
+	"strings"
+)
+
+// Media types of build outputs. The types of disk images without a registered type follow the names
+// common tools use for them.
+const (
+	AndroidSparseImage = "application/x-android-sparse-image"
+	AndroidBootImage   = "application/x-android-boot-image"
+	QCOW2              = "application/x-qemu-disk"
+	RawDiskImage       = "application/x-raw-disk-image"
+	VMDK               = "application/x-vmdk"
+	VDI                = "application/x-virtualbox-vdi"
+	VHDX               = "application/x-vhdx"
+	Tar                = "application/x-tar"
+	JSON               = "application/json"
+	OctetStream        = "application/octet-stream"
+)
+
+// wrapperSuffixes are the suffixes of compression and encryption around a file's payload
+var wrapperSuffixes = []string{".enc", ".gz", ".lz4", ".xz"}
+
+// extensions maps the extension of a payload to its type; ForFile treats aboot images apart
+var extensions = map[string]string{
+	".simg":  AndroidSparseImage,
+	".qcow2": QCOW2,
+	".raw":   RawDiskImage,
+	".img":   RawDiskImage,
+	".vmdk":  VMDK,
+	".vdi":   VDI,
+	".vhdx":  VHDX,
+	".tar":   Tar,
+	".json":  JSON,
+}
+
+// Payload returns name without the suffixes of the compression and encryption wrapping its payload, so
+// that disk.raw.gz.enc becomes disk.raw
+func Payload(name string) string {
+	for {
+		lower := strings.ToLower(name)
+		stripped := false
+		for _, s := range wrapperSuffixes {
+			if strings.HasSuffix(lower, s) && len(name) > len(s) {
+				name = name[:len(name)-len(s)]
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			return name
+		}
+	}
+}
+
+// ForFile returns the media type of the payload of the file name, once decompressed and decrypted
+func ForFile(name string) string {
+	payload := strings.ToLower(path.Base(Payload(name)))
+	if payload == "aboot.img" || payload == "boot.img" || strings.HasSuffix(payload, ".aboot") ||
+		strings.HasSuffix(payload, "-aboot.img") || strings.HasSuffix(payload, ".aboot.img") {
+		return AndroidBootImage
+	}
+	if t, ok := extensions[path.Ext(payload)]; ok {
+		return t
+	}
+	return OctetStream
+}
+
+// Extension returns the extension files of contentType are named with, "" for types without one
+func Extension(contentType string) string {
+	switch contentType {
+	case AndroidBootImage:
+		return ".img"
+	case RawDiskImage:
+		return ".raw"
+	}
+	for ext, t := range extensions {
+		if t == contentType {
+			return ext
+		}
+	}
+	return ""
+}
+
+// FileName returns name with the extension of contentType added in front of its compression and encryption
+// suffixes when the name does not tell its type, so that tools picking a file up by its extension see what
+// it holds. Names already matching contentType, or an unknown one, are returned unchanged.
+func FileName(name, contentType string) string {
+	ext := Extension(contentType)
+	if ext == "" || contentType == OctetStream || ForFile(name) == contentType {
+		return name
+	}
+	payload := Payload(name)
+	return payload + ext + name[len(payload):]
+}
@@ -0,0 +1,175 @@
+// Package buildscc manages the SecurityContextConstraints the pods of builds run under on OpenShift and the
+// cluster roles and role bindings allowing service accounts to use them.
+package buildscc
+
+import (
+	"context"
+	"fmt"
+
+	securityv1 "github.com/openshift/api/security/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SCCName is the SecurityContextConstraints of the build TaskRun and PipelineRun pods
+	SCCName = "automotive-dev-build"
+
+	// ClusterRoleName allows using SCCName
+	ClusterRoleName = "automotive-dev-build-scc"
+
+	// HelperSCCName is the built-in SCC of the helper pods: any non-root user, no privileges, no host access
+	HelperSCCName = "nonroot-v2"
+
+	// HelperClusterRoleName allows using HelperSCCName
+	HelperClusterRoleName = "automotive-dev-helper-scc"
+)
+
+func managedLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "automotive-dev-operator",
+		"app.kubernetes.io/part-of":    "automotive-dev",
+	}
+}
+
+// SCC returns the build SCC. osbuild needs a privileged step, running as root with an unconfined SELinux type
+// and the node's /dev mounted to reach loop devices; host namespaces, ports, sysctls and added capabilities stay
+// denied and the other steps get the runtime's default seccomp profile.
+func SCC() *securityv1.SecurityContextConstraints {
+	return &securityv1.SecurityContextConstraints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   SCCName,
+			Labels: managedLabels(),
+		},
+		AllowPrivilegedContainer: true,
+		AllowPrivilegeEscalation: ptr.To(true),
+		AllowHostDirVolumePlugin: true,
+		AllowHostNetwork:         false,
+		AllowHostPorts:           false,
+		AllowHostPID:             false,
+		AllowHostIPC:             false,
+		ReadOnlyRootFilesystem:   false,
+		Volumes: []securityv1.FSType{
+			securityv1.FSTypeConfigMap,
+			securityv1.FSTypeDownwardAPI,
+			securityv1.FSTypeEmptyDir,
+			securityv1.FSTypeHostPath,
+			securityv1.FSTypePersistentVolumeClaim,
+			securityv1.FSProjected,
+			securityv1.FSTypeSecret,
+		},
+		RunAsUser:          securityv1.RunAsUserStrategyOptions{Type: securityv1.RunAsUserStrategyRunAsAny},
+		SELinuxContext:     securityv1.SELinuxContextStrategyOptions{Type: securityv1.SELinuxStrategyRunAsAny},
+		FSGroup:            securityv1.FSGroupStrategyOptions{Type: securityv1.FSGroupStrategyRunAsAny},
+		SupplementalGroups: securityv1.SupplementalGroupsStrategyOptions{Type: securityv1.SupplementalGroupsStrategyRunAsAny},
+		SeccompProfiles:    []string{"runtime/default"},
+		ForbiddenSysctls:   []string{"*"},
+		Users:              []string{},
+		Groups:             []string{},
+	}
+}
+
+// Ensure creates the build SCC and the cluster roles allowing the use of the build and helper SCCs. A build SCC
+// granting more than SCC returns, as earlier operator versions created it, is taken back to it.
+func Ensure(ctx context.Context, c client.Client) error {
+	desired := SCC()
+	scc := &securityv1.SecurityContextConstraints{}
+	err := c.Get(ctx, client.ObjectKey{Name: SCCName}, scc)
+	if errors.IsNotFound(err) {
+		if err := c.Create(ctx, desired); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create build SCC: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get build SCC: %w", err)
+	} else {
+		desired.ObjectMeta = scc.ObjectMeta
+		desired.TypeMeta = scc.TypeMeta
+		if !equality.Semantic.DeepEqual(desired, scc) {
+			if err := c.Update(ctx, desired); err != nil {
+				return fmt.Errorf("failed to update build SCC: %w", err)
+			}
+		}
+	}
+
+	if err := ensureClusterRole(ctx, c, ClusterRoleName, SCCName); err != nil {
+		return err
+	}
+	return ensureClusterRole(ctx, c, HelperClusterRoleName, HelperSCCName)
+}
+
+// ensureClusterRole creates a cluster role allowing the use of an SCC
+func ensureClusterRole(ctx context.Context, c client.Client, name, sccName string) error {
+	cr := &rbacv1.ClusterRole{}
+	err := c.Get(ctx, client.ObjectKey{Name: name}, cr)
+	if errors.IsNotFound(err) {
+		cr = &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: managedLabels(),
+			},
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups:     []string{securityv1.GroupName},
+					Resources:     []string{"securitycontextconstraints"},
+					ResourceNames: []string{sccName},
+					Verbs:         []string{"use"},
+				},
+			},
+		}
+		if err := c.Create(ctx, cr); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create cluster role %s: %w", name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get cluster role %s: %w", name, err)
+	}
+	return nil
+}
+
+// Bind allows a service account to use the SCC of a cluster role through the role binding of the namespace
+// named after the cluster role, adding the service account to the binding's subjects when it exists
+func Bind(ctx context.Context, c client.Client, namespace, clusterRole, serviceAccount string) error {
+	subject := rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      serviceAccount,
+		Namespace: namespace,
+	}
+	rb := &rbacv1.RoleBinding{}
+	err := c.Get(ctx, client.ObjectKey{Name: clusterRole, Namespace: namespace}, rb)
+	if errors.IsNotFound(err) {
+		rb = &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterRole,
+				Namespace: namespace,
+				Labels:    managedLabels(),
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     clusterRole,
+			},
+			Subjects: []rbacv1.Subject{subject},
+		}
+		if err := c.Create(ctx, rb); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create role binding %s: %w", clusterRole, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get role binding %s: %w", clusterRole, err)
+	}
+
+	for _, s := range rb.Subjects {
+		if s.Kind == subject.Kind && s.Name == subject.Name && s.Namespace == subject.Namespace {
+			return nil
+		}
+	}
+	patch := client.MergeFrom(rb.DeepCopy())
+	rb.Subjects = append(rb.Subjects, subject)
+	if err := c.Patch(ctx, rb, patch); err != nil {
+		return fmt.Errorf("failed to update role binding %s: %w", clusterRole, err)
+	}
+	return nil
+}
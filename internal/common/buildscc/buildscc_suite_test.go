@@ -0,0 +1,13 @@
+package buildscc
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBuildscc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "buildscc Suite")
+}
@@ -0,0 +1,93 @@
+package buildscc
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	securityv1 "github.com/openshift/api/security/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newFakeClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(securityv1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+var _ = Describe("Build SCC", func() {
+	ctx := context.Background()
+
+	It("should create the SCC and the cluster roles allowing the build and helper SCCs", func() {
+		c := newFakeClient()
+		Expect(Ensure(ctx, c)).To(Succeed())
+
+		scc := &securityv1.SecurityContextConstraints{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: SCCName}, scc)).To(Succeed())
+		Expect(scc.AllowPrivilegedContainer).To(BeTrue())
+		Expect(scc.AllowedCapabilities).To(BeEmpty())
+		Expect(scc.ForbiddenSysctls).To(Equal([]string{"*"}))
+		for name, sccName := range map[string]string{ClusterRoleName: SCCName, HelperClusterRoleName: HelperSCCName} {
+			role := &rbacv1.ClusterRole{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: name}, role)).To(Succeed())
+			Expect(role.Rules).To(ConsistOf(rbacv1.PolicyRule{
+				APIGroups:     []string{securityv1.GroupName},
+				Resources:     []string{"securitycontextconstraints"},
+				ResourceNames: []string{sccName},
+				Verbs:         []string{"use"},
+			}))
+		}
+	})
+
+	It("should restrict an SCC created by earlier versions and leave it alone afterwards", func() {
+		old := SCC()
+		old.SeccompProfiles = []string{"*"}
+		old.ForbiddenSysctls = nil
+		old.AllowedCapabilities = []corev1.Capability{"*"}
+		old.AllowHostNetwork = true
+		c := newFakeClient(old)
+
+		Expect(Ensure(ctx, c)).To(Succeed())
+		scc := &securityv1.SecurityContextConstraints{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: SCCName}, scc)).To(Succeed())
+		Expect(scc.SeccompProfiles).To(Equal([]string{"runtime/default"}))
+		Expect(scc.ForbiddenSysctls).To(Equal([]string{"*"}))
+		Expect(scc.AllowedCapabilities).To(BeEmpty())
+		Expect(scc.AllowHostNetwork).To(BeFalse())
+
+		Expect(Ensure(ctx, c)).To(Succeed())
+		unchanged := &securityv1.SecurityContextConstraints{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: SCCName}, unchanged)).To(Succeed())
+		Expect(unchanged.ResourceVersion).To(Equal(scc.ResourceVersion))
+	})
+
+	It("should add service accounts to the binding of a cluster role once", func() {
+		existing := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: ClusterRoleName, Namespace: "builds"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: ClusterRoleName},
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "automotive-dev-build", Namespace: "builds"}},
+		}
+		c := newFakeClient(existing)
+		Expect(Bind(ctx, c, "builds", ClusterRoleName, "pipeline")).To(Succeed())
+		Expect(Bind(ctx, c, "builds", ClusterRoleName, "pipeline")).To(Succeed())
+		Expect(Bind(ctx, c, "other", ClusterRoleName, "pipeline")).To(Succeed())
+
+		rb := &rbacv1.RoleBinding{}
+		Expect(c.Get(ctx, client.ObjectKey{Name: ClusterRoleName, Namespace: "builds"}, rb)).To(Succeed())
+		Expect(rb.Subjects).To(ConsistOf(
+			rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "automotive-dev-build", Namespace: "builds"},
+			rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "pipeline", Namespace: "builds"},
+		))
+		Expect(c.Get(ctx, client.ObjectKey{Name: ClusterRoleName, Namespace: "other"}, rb)).To(Succeed())
+		Expect(rb.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "pipeline", Namespace: "other"}))
+		Expect(rb.RoleRef.Name).To(Equal(ClusterRoleName))
+	})
+})
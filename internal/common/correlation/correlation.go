@@ -0,0 +1,99 @@
+// Package correlation carries the request ID and W3C trace context a client sent with a build API call to the
+// ImageBuild it created and the resources created for that build, so that a CLI invocation can be found in
+// the logs of the build API, the operator and the build's pods.
+package correlation
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// RequestIDHeader identifies a call to the build API; the server generates one when the client does not
+	RequestIDHeader = "X-Request-ID"
+	// TraceparentHeader is the W3C Trace Context header
+	TraceparentHeader = "traceparent"
+
+	// RequestIDAnnotation and TraceparentAnnotation record on an ImageBuild, and the resources created for it,
+	// the IDs of the request that created it
+	RequestIDAnnotation   = "automotive.sdv.cloud.redhat.com/request-id"
+	TraceparentAnnotation = "automotive.sdv.cloud.redhat.com/traceparent"
+
+	maxRequestIDLen = 128
+)
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]+$`)
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+)
+
+// IDs are the correlation IDs of a request
+type IDs struct {
+	RequestID   string
+	Traceparent string
+}
+
+// ValidRequestID reports whether a client-supplied request ID can be logged and stored in an annotation as is
+func ValidRequestID(id string) bool {
+	return len(id) <= maxRequestIDLen && requestIDPattern.MatchString(id)
+}
+
+// ValidTraceparent reports whether tp is a W3C traceparent header with non-zero trace and parent IDs
+func ValidTraceparent(tp string) bool {
+	m := traceparentPattern.FindStringSubmatch(tp)
+	if m == nil || strings.HasPrefix(tp, "ff-") {
+		return false
+	}
+	return strings.Trim(m[1], "0") != "" && strings.Trim(m[2], "0") != ""
+}
+
+type contextKey struct{}
+
+// WithIDs returns a context carrying ids
+func WithIDs(ctx context.Context, ids IDs) context.Context {
+	return context.WithValue(ctx, contextKey{}, ids)
+}
+
+// FromContext returns the IDs ctx carries, empty if none
+func FromContext(ctx context.Context) IDs {
+	ids, _ := ctx.Value(contextKey{}).(IDs)
+	return ids
+}
+
+// FromObject returns the IDs recorded in the annotations of obj
+func FromObject(obj metav1.Object) IDs {
+	a := obj.GetAnnotations()
+	return IDs{RequestID: a[RequestIDAnnotation], Traceparent: a[TraceparentAnnotation]}
+}
+
+// Annotate records the IDs that are set in annotations, allocating the map if needed, and returns it
+func (ids IDs) Annotate(annotations map[string]string) map[string]string {
+	if ids.RequestID == "" && ids.Traceparent == "" {
+		return annotations
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if ids.RequestID != "" {
+		annotations[RequestIDAnnotation] = ids.RequestID
+	}
+	if ids.Traceparent != "" {
+		annotations[TraceparentAnnotation] = ids.Traceparent
+	}
+	return annotations
+}
+
+// LogValues returns the IDs that are set as logr key/value pairs
+func (ids IDs) LogValues() []any {
+	var kv []any
+	if ids.RequestID != "" {
+		kv = append(kv, "requestID", ids.RequestID)
+	}
+	if ids.Traceparent != "" {
+		kv = append(kv, "traceparent", ids.Traceparent)
+	}
+	return kv
+}
@@ -0,0 +1,58 @@
+// Package features reads the feature gates of the AutomotiveDev. Large features are shipped behind a gate
+// and turned on one cluster at a time; the operator and the build API read the gates from the AutomotiveDev
+// their informers keep up to date, so turning a gate on or off needs no restart.
+package features
+
+import (
+	"slices"
+	"sort"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// Gates the operator and the build API know; they are off unless the AutomotiveDev turns them on
+const (
+	// Queueing gates queueing builds for the capacity of their namespace
+	Queueing = "enableQueueing"
+	// Archival gates moving the artifacts of finished builds to long-term storage
+	Archival = "enableArchival"
+	// TestBoot gates booting built images in a virtual machine before they are reported complete
+	TestBoot = "enableTestBoot"
+	// ResultCache gates answering every build request from the result cache, not only those asking to reuse
+	// an identical build
+	ResultCache = "enableResultCache"
+)
+
+// Known lists the gates of this version, sorted
+var Known = []string{Archival, Queueing, ResultCache, TestBoot}
+
+// Enabled reports whether autoDev, which may be nil, turns gate on
+func Enabled(autoDev *automotivev1.AutomotiveDev, gate string) bool {
+	return autoDev != nil && autoDev.Spec.FeatureGates[gate]
+}
+
+// EnabledGates returns the known gates autoDev turns on, sorted
+func EnabledGates(autoDev *automotivev1.AutomotiveDev) []string {
+	var enabled []string
+	for _, gate := range Known {
+		if Enabled(autoDev, gate) {
+			enabled = append(enabled, gate)
+		}
+	}
+	return enabled
+}
+
+// Unknown returns the gates autoDev sets that this version does not know, sorted, so typos can be reported
+func Unknown(autoDev *automotivev1.AutomotiveDev) []string {
+	if autoDev == nil {
+		return nil
+	}
+	var unknown []string
+	for gate := range autoDev.Spec.FeatureGates {
+		if !slices.Contains(Known, gate) {
+			unknown = append(unknown, gate)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,177 @@
+// Package gitstatus reports the progress of builds started by Git webhooks as commit statuses. The build
+// API records the commit a build was started for in annotations of its ImageBuild, and the controller sets
+// the status of that commit as the build moves through its phases.
+package gitstatus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ProviderAnnotation names the Git provider the commit of a build is hosted on
+	ProviderAnnotation = "automotive.sdv.cloud.redhat.com/git-provider"
+	// RepositoryAnnotation is the full name of the repository of the commit of a build
+	RepositoryAnnotation = "automotive.sdv.cloud.redhat.com/git-repository"
+	// CommitAnnotation is the SHA of the commit a build was started for
+	CommitAnnotation = "automotive.sdv.cloud.redhat.com/git-commit"
+
+	// DefaultContext names the statuses of GitStatusReporting configurations that do not name them
+	DefaultContext = "automotive-dev/build"
+
+	// maxDescription is the longest description GitHub accepts
+	maxDescription = 140
+)
+
+// Provider is a Git provider whose webhooks start builds
+type Provider string
+
+const (
+	GitHub Provider = "github"
+	GitLab Provider = "gitlab"
+)
+
+// Commit is the commit a build was started for
+type Commit struct {
+	Provider Provider
+	// Repository is the full name of the repository, e.g. "myorg/manifests"
+	Repository string
+	SHA        string
+}
+
+// Annotate records c in the annotations of a build
+func (c Commit) Annotate(annotations map[string]string) {
+	annotations[ProviderAnnotation] = string(c.Provider)
+	annotations[RepositoryAnnotation] = c.Repository
+	annotations[CommitAnnotation] = c.SHA
+}
+
+// FromAnnotations returns the commit recorded in the annotations of a build, if any
+func FromAnnotations(annotations map[string]string) (Commit, bool) {
+	c := Commit{
+		Provider:   Provider(annotations[ProviderAnnotation]),
+		Repository: annotations[RepositoryAnnotation],
+		SHA:        annotations[CommitAnnotation],
+	}
+	return c, c.Provider != "" && c.Repository != "" && IsSHA(c.SHA)
+}
+
+// IsSHA reports whether s is a full SHA-1 or SHA-256 commit hash
+func IsSHA(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return !('0' <= r && r <= '9' || 'a' <= r && r <= 'f')
+	})
+}
+
+// SameRepository reports whether two full repository names name the same repository; providers treat
+// them case-insensitively
+func SameRepository(a, b string) bool {
+	return strings.EqualFold(strings.Trim(a, "/"), strings.Trim(b, "/"))
+}
+
+// State is the state of a commit status
+type State string
+
+const (
+	Pending State = "pending"
+	Success State = "success"
+	Failure State = "failure"
+)
+
+// Status is the status of a build set on its commit
+type Status struct {
+	State State
+	// Context names the status among the others of the commit
+	Context     string
+	Description string
+}
+
+// Reporter sets commit statuses through the API of a Git provider
+type Reporter struct {
+	// APIURL is the root of the provider's API, e.g. https://api.github.com or https://gitlab.example.com/api/v4
+	APIURL string
+	Token  string
+	// HTTPClient is used for all requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Report sets status on commit
+func (r *Reporter) Report(ctx context.Context, commit Commit, status Status) error {
+	if len(status.Description) > maxDescription {
+		status.Description = status.Description[:maxDescription-3] + "..."
+	}
+
+	var endpoint string
+	var body any
+	header := http.Header{"Content-Type": {"application/json"}}
+	switch commit.Provider {
+	case GitHub:
+		owner, repo, ok := strings.Cut(commit.Repository, "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return fmt.Errorf("invalid GitHub repository %q", commit.Repository)
+		}
+		endpoint = joinPath(r.APIURL, "repos", owner, repo, "statuses", commit.SHA)
+		body = map[string]string{"state": string(status.State), "context": status.Context, "description": status.Description}
+		header.Set("Authorization", "Bearer "+r.Token)
+		header.Set("Accept", "application/vnd.github+json")
+	case GitLab:
+		state := string(status.State)
+		switch status.State {
+		case Pending:
+			state = "running"
+		case Failure:
+			state = "failed"
+		}
+		// GitLab takes the URL-encoded full name of a project in place of its ID
+		endpoint = joinPath(r.APIURL, "projects", commit.Repository, "statuses", commit.SHA)
+		body = map[string]string{"state": state, "name": status.Context, "description": status.Description}
+		header.Set("PRIVATE-TOKEN", r.Token)
+	default:
+		return fmt.Errorf("unsupported Git provider %q", commit.Provider)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (r *Reporter) httpClient() *http.Client {
+	if r.HTTPClient != nil {
+		return r.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// joinPath appends segments to base, escaping each so that none can leave the API it names
+func joinPath(base string, segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, s := range segments {
+		escaped[i] = url.PathEscape(s)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(escaped, "/")
+}
@@ -0,0 +1,45 @@
+// Package intermediateregistry names the in-cluster registry builds push intermediate container content to.
+// The AutomotiveDev controller runs it in the operator namespace when BuildConfig.IntermediateRegistry enables
+// it; the ImageBuild controller hands every build a repository of its own and deletes it when the build is done
+// with it.
+package intermediateregistry
+
+import (
+	"fmt"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	// Name is the name of the registry's Deployment, Service and PersistentVolumeClaim
+	Name = "automotive-dev-registry"
+	// Port is the port the registry's Service serves plain HTTP on
+	Port = 5000
+	// DefaultStorageSize is the size of the registry's PersistentVolumeClaim unless configured
+	DefaultStorageSize = "20Gi"
+)
+
+// Enabled reports whether buildConfig runs the intermediate registry
+func Enabled(buildConfig *automotivev1.BuildConfig) bool {
+	return buildConfig != nil && buildConfig.IntermediateRegistry != nil && buildConfig.IntermediateRegistry.Enabled
+}
+
+// Host returns the address of the registry running in namespace
+func Host(namespace string) string {
+	return fmt.Sprintf("%s.%s.svc:%d", Name, namespace, Port)
+}
+
+// Repository returns the repository of a build in the registry. The build may also push to repositories below it.
+func Repository(buildNamespace, buildName string) string {
+	return buildNamespace + "/" + buildName
+}
+
+// BuildOf returns the namespace and name of the build a repository of the registry belongs to
+func BuildOf(repository string) (namespace, name string, ok bool) {
+	parts := strings.SplitN(repository, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,42 @@
+// Package manifesthash pins the manifest ConfigMap a build was created with. ConfigMaps can be edited after the
+// fact, so the build API records the SHA-256 of every file of a build's ConfigMap, the manifests as well as the
+// defines and AIB arguments, in an annotation when it creates the build. The controller copies it to the status
+// and refuses to build from a ConfigMap that no longer matches it, and the build step checks it once more
+// against the files it mounted.
+package manifesthash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotation holds the hex SHA-256 of a build's manifest ConfigMap as it was when the build was created
+const Annotation = "automotive.sdv.cloud.redhat.com/manifest-sha256"
+
+// Sum returns the hex SHA-256 of the files of a ConfigMap: of a "<sha256 of the content>  <key>" line per key
+// in byte order, which is what sha256sum prints for the files the ConfigMap is mounted as
+func Sum(cm *corev1.ConfigMap) string {
+	files := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.Data {
+		files[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		files[k] = v
+	}
+	var lines strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(files)) {
+		fmt.Fprintf(&lines, "%s  %s\n", sum(files[k]), k)
+	}
+	return sum([]byte(lines.String()))
+}
+
+func sum(content []byte) string {
+	s := sha256.Sum256(content)
+	return hex.EncodeToString(s[:])
+}
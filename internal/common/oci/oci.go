@@ -0,0 +1,79 @@
+// Package oci defines the media types and annotations of the automotive images the operator pushes
+// to OCI registries, so registry-side tooling and image discovery can recognise and describe them.
+package oci
+
+const (
+	// ArtifactType is the artifact type of every automotive image manifest
+	ArtifactType = "application/vnd.redhat.automotive.image.v1"
+
+	// Layer media types, one per disk image format
+	MediaTypeRaw   = "application/vnd.redhat.automotive.image.raw.v1"
+	MediaTypeQcow2 = "application/vnd.redhat.automotive.image.qcow2.v1"
+	MediaTypeSimg  = "application/vnd.redhat.automotive.image.simg.v1"
+	MediaTypeAboot = "application/vnd.redhat.automotive.image.aboot.v1"
+
+	// MediaTypeGeneric is used for export formats without a dedicated media type
+	MediaTypeGeneric = "application/vnd.oci.image.layer.v1.tar"
+
+	// MediaTypeManifest is the media type of the manifests automotive images are pushed with
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeEmpty is the media type of EmptyConfig, the config of artifacts that need none
+	MediaTypeEmpty = "application/vnd.oci.empty.v1+json"
+	EmptyConfig    = "{}"
+)
+
+// Manifest annotations describing how an image was built
+const (
+	AnnotationDistro       = "automotive.sdv.cloud.redhat.com/distro"
+	AnnotationTarget       = "automotive.sdv.cloud.redhat.com/target"
+	AnnotationArchitecture = "automotive.sdv.cloud.redhat.com/architecture"
+	AnnotationExportFormat = "automotive.sdv.cloud.redhat.com/export-format"
+	AnnotationMode         = "automotive.sdv.cloud.redhat.com/mode"
+	AnnotationImageBuild   = "automotive.sdv.cloud.redhat.com/imagebuild-name"
+
+	// Standard OCI annotations
+	AnnotationVersion     = "org.opencontainers.image.version"
+	AnnotationDescription = "org.opencontainers.image.description"
+	AnnotationCreated     = "org.opencontainers.image.created"
+	// AnnotationRevision holds the git ref the image was built from
+	AnnotationRevision = "org.opencontainers.image.revision"
+	// AnnotationTitle holds the file name of a layer
+	AnnotationTitle = "org.opencontainers.image.title"
+)
+
+// formats maps export formats to their layer media types; "image" is the builder's name for raw
+var formats = []struct {
+	format    string
+	mediaType string
+}{
+	{"image", MediaTypeRaw},
+	{"raw", MediaTypeRaw},
+	{"qcow2", MediaTypeQcow2},
+	{"simg", MediaTypeSimg},
+	{"aboot", MediaTypeAboot},
+}
+
+// MediaTypeForFormat returns the layer media type of an export format
+func MediaTypeForFormat(format string) string {
+	for _, f := range formats {
+		if f.format == format {
+			return f.mediaType
+		}
+	}
+	return MediaTypeGeneric
+}
+
+// FormatForMediaType returns the export format of an automotive layer media type, or "" if it is not one
+func FormatForMediaType(mediaType string) string {
+	for _, f := range formats {
+		if f.mediaType == mediaType {
+			return f.format
+		}
+	}
+	return ""
+}
+
+// IsAutomotiveMediaType reports whether mediaType is the artifact type or a layer media type defined here
+func IsAutomotiveMediaType(mediaType string) bool {
+	return mediaType == ArtifactType || FormatForMediaType(mediaType) != ""
+}
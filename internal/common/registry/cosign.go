@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// cosignSignatureAnnotation holds the base64 signature of a cosign simple-signing payload layer
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// ParsePublicKey parses a PEM-encoded public key as written by "cosign generate-key-pair"
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	return key, nil
+}
+
+// VerifyCosignSignature checks that the image repo@digest has a cosign signature made with key. Signatures
+// are looked up under the "sha256-<hex>.sig" tag cosign attaches them to; keyless signatures are not supported.
+func (c *Client) VerifyCosignSignature(ctx context.Context, repo, digest string, key crypto.PublicKey) error {
+	algo, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return fmt.Errorf("invalid digest %q", digest)
+	}
+	sigs, _, err := c.GetManifest(ctx, repo, algo+"-"+hex+".sig")
+	if err != nil {
+		return fmt.Errorf("no cosign signature found for %s@%s: %w", repo, digest, err)
+	}
+
+	var lastErr error
+	for _, layer := range sigs.Layers {
+		sig := layer.Annotations[cosignSignatureAnnotation]
+		if sig == "" {
+			continue
+		}
+		payload, err := c.GetBlob(ctx, repo, layer.Digest)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if lastErr = verifyCosignPayload(payload, sig, digest, key); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("signature manifest has no signed layers")
+	}
+	return fmt.Errorf("no valid cosign signature for %s@%s: %w", repo, digest, lastErr)
+}
+
+// verifyCosignPayload checks a simple-signing payload's signature and that it covers digest
+func verifyCosignPayload(payload []byte, signature, digest string, key crypto.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if k, ok := key.(ed25519.PublicKey); ok {
+		if !ed25519.Verify(k, payload, sig) {
+			return fmt.Errorf("signature does not match the public key")
+		}
+	} else if err := verifySHA256Signature(sha256.Sum256(payload), sig, key); err != nil {
+		return err
+	}
+
+	var p struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for %s, not %s", p.Critical.Image.DockerManifestDigest, digest)
+	}
+	return nil
+}
+
+// VerifyBlobSignature checks a "cosign sign-blob" signature, base64 as cosign writes it, of a blob with the
+// sha256 checksum sum. Ed25519 keys sign the whole blob rather than its checksum and are not supported.
+func VerifyBlobSignature(sum [sha256.Size]byte, signature string, key crypto.PublicKey) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	return verifySHA256Signature(sum, sig, key)
+}
+
+// verifySHA256Signature checks an ECDSA or RSA signature over a sha256 hash
+func verifySHA256Signature(hash [sha256.Size]byte, sig []byte, key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, hash[:], sig) {
+			return fmt.Errorf("signature does not match the public key")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], sig); err != nil {
+			return fmt.Errorf("signature does not match the public key")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dockerHub is the registry of references without a host, and dockerHubAPI the host serving its API
+const (
+	dockerHub    = "docker.io"
+	dockerHubAPI = "registry-1.docker.io"
+)
+
+// Reference is a parsed image reference such as quay.io/org/image:tag or quay.io/org/image@sha256:...
+type Reference struct {
+	// Host is the registry host, optionally with a port
+	Host string
+	// Repository is the repository path within the registry
+	Repository string
+	// Tag is empty if the reference only has a digest
+	Tag string
+	// Digest is empty if the reference is not pinned
+	Digest string
+}
+
+// ParseReference parses an image reference. A reference without a registry host refers to Docker Hub,
+// and one with neither tag nor digest to the "latest" tag.
+func ParseReference(ref string) (Reference, error) {
+	r := Reference{}
+	rest := strings.TrimSpace(ref)
+	if rest == "" {
+		return r, fmt.Errorf("empty image reference")
+	}
+
+	if name, digest, ok := strings.Cut(rest, "@"); ok {
+		if !strings.Contains(digest, ":") {
+			return r, fmt.Errorf("invalid digest in image reference %q", ref)
+		}
+		rest, r.Digest = name, digest
+	}
+	// a colon after the last slash separates the tag; earlier ones belong to a registry port
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		rest, r.Tag = rest[:i], rest[i+1:]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	host, repo, ok := strings.Cut(rest, "/")
+	if !ok || !(strings.ContainsAny(host, ".:") || host == "localhost") {
+		host, repo = dockerHub, rest
+		if !strings.Contains(repo, "/") {
+			repo = "library/" + repo
+		}
+	}
+	if repo == "" {
+		return r, fmt.Errorf("invalid image reference %q", ref)
+	}
+	r.Host, r.Repository = host, repo
+	return r, nil
+}
+
+// APIHost returns the host serving the registry API for the reference
+func (r Reference) APIHost() string {
+	if r.Host == dockerHub {
+		return dockerHubAPI
+	}
+	return r.Host
+}
+
+// Name returns the reference without tag or digest
+func (r Reference) Name() string {
+	return r.Host + "/" + r.Repository
+}
+
+// WithDigest returns the reference pinned to digest, dropping its tag
+func (r Reference) WithDigest(digest string) string {
+	return r.Name() + "@" + digest
+}
+
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
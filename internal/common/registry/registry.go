@@ -0,0 +1,463 @@
+// Package registry is a minimal client for the OCI distribution API, covering what the operator needs to
+// inspect images in registries: listing tags, reading manifests and blobs, and resolving tags to digests.
+// It also deletes manifests of images garbage collected by the operator and of builds in its intermediate
+// registry, and pushes the artifacts of promoted builds.
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// manifestAccept lists the manifest media types the client understands
+var manifestAccept = strings.Join([]string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}, ", ")
+
+// ErrDeleteUnsupported is returned by DeleteManifest when the registry does not allow deleting manifests
+var ErrDeleteUnsupported = errors.New("registry does not allow deleting manifests")
+
+// Descriptor is the subset of an OCI content descriptor used by the operator
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest,omitempty"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is the subset of an OCI image manifest or index used by the operator
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion,omitempty"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        *Descriptor       `json:"config,omitempty"`
+	Layers        []Descriptor      `json:"layers,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Client talks to a single registry over the OCI distribution API
+type Client struct {
+	// HTTPClient is used for all requests; http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Host is the registry host, optionally with a port
+	Host string
+	// Insecure uses plain HTTP instead of HTTPS
+	Insecure bool
+	// Username and Password are sent as basic auth, or to the registry's token service
+	Username string
+	Password string
+
+	// token is the bearer token obtained from the registry's token service, reused until rejected
+	token string
+}
+
+// SplitRepository splits "host/path/to/repo" into its registry host and repository path
+func SplitRepository(repository string) (string, string, error) {
+	host, repo, ok := strings.Cut(strings.TrimSpace(repository), "/")
+	if !ok || host == "" || repo == "" {
+		return "", "", fmt.Errorf("repository %q must be of the form host/path", repository)
+	}
+	return host, repo, nil
+}
+
+// ListTags returns every tag of repo, following pagination links
+func (c *Client) ListTags(ctx context.Context, repo string) ([]string, error) {
+	next := c.url("/v2/%s/tags/list", repo)
+	var tags []string
+	for next != "" {
+		resp, err := c.get(ctx, next, repo, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding tag list: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+		next, err = nextPage(next, link)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// ListRepositories returns every repository of the registry, following pagination links. Registries serving
+// many users usually refuse to list their catalog; this is meant for registries the operator runs itself.
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	next := c.url("/v2/_catalog")
+	var repos []string
+	for next != "" {
+		resp, err := c.get(ctx, next, "", "application/json")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Repositories []string `json:"repositories"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding repository list: %w", err)
+		}
+		repos = append(repos, page.Repositories...)
+		next, err = nextPage(next, link)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repos, nil
+}
+
+// nextPage resolves the URL of an RFC 5988 Link header with rel="next" against the current page
+func nextPage(current, link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+	target, _, ok := strings.Cut(link, ";")
+	if !ok || !strings.Contains(link, `rel="next"`) {
+		return "", nil
+	}
+	ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Link header %q: %w", link, err)
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// GetManifest fetches the manifest of repo:ref and returns it with its digest
+func (c *Client) GetManifest(ctx context.Context, repo, ref string) (*Manifest, string, error) {
+	resp, err := c.get(ctx, c.url("/v2/%s/manifests/%s", repo, ref), repo, manifestAccept)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	m := &Manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(m); err != nil {
+		return nil, "", fmt.Errorf("decoding manifest %s:%s: %w", repo, ref, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return m, resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// ResolveDigest returns the digest of the manifest repo:tag currently points to
+func (c *Client) ResolveDigest(ctx context.Context, repo, tag string) (string, error) {
+	_, digest, err := c.GetManifest(ctx, repo, tag)
+	if err != nil {
+		return "", err
+	}
+	if digest == "" {
+		return "", fmt.Errorf("registry %s did not report a digest for %s:%s", c.Host, repo, tag)
+	}
+	return digest, nil
+}
+
+// DeleteManifest deletes the manifest repo@digest, and with it every tag pointing to it. A manifest that
+// is already gone is not an error.
+func (c *Client) DeleteManifest(ctx context.Context, repo, digest string) error {
+	endpoint := c.url("/v2/%s/manifests/%s", repo, digest)
+	resp, err := c.send(ctx, http.MethodDelete, endpoint, repo, "pull,push,delete", manifestAccept, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted, http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	case http.StatusMethodNotAllowed:
+		return fmt.Errorf("%w: %s", ErrDeleteUnsupported, c.Host)
+	default:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("DELETE %s: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(b)))
+	}
+}
+
+// maxBlobSize bounds GetBlob, which is only meant for small metadata blobs such as signature payloads
+const maxBlobSize = 4 << 20
+
+// GetBlob fetches a blob of repo, reading at most maxBlobSize bytes
+func (c *Client) GetBlob(ctx context.Context, repo, digest string) ([]byte, error) {
+	resp, err := c.get(ctx, c.url("/v2/%s/blobs/%s", repo, digest), repo, "*/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+}
+
+func (c *Client) url(format string, args ...any) string {
+	scheme := "https"
+	if c.Insecure {
+		scheme = "http"
+	}
+	return scheme + "://" + c.Host + fmt.Sprintf(format, args...)
+}
+
+// get performs an authenticated GET and fails unless the registry answers 200 OK
+func (c *Client) get(ctx context.Context, endpoint, repo, accept string) (*http.Response, error) {
+	resp, err := c.send(ctx, http.MethodGet, endpoint, repo, "pull", accept, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return resp, nil
+}
+
+// send performs an authenticated request, answering the registry's auth challenge once with a token for
+// the comma-separated actions on repo. A body of contentType is sent again after the challenge.
+func (c *Client) send(ctx context.Context, method, endpoint, repo, actions, accept, contentType string, body []byte) (*http.Response, error) {
+	resp, err := c.do(ctx, method, endpoint, accept, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err := c.authenticate(ctx, challenge, repo, actions); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, method, endpoint, accept, contentType, body); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// PushBlob uploads the blob desc describes to repo, streaming its content from write. A blob the repository
+// already holds is not uploaded again. The registry verifies the content against desc.Digest.
+func (c *Client) PushBlob(ctx context.Context, repo string, desc Descriptor, write func(w io.Writer) error) error {
+	resp, err := c.send(ctx, http.MethodHead, c.url("/v2/%s/blobs/%s", repo, desc.Digest), repo, "pull,push", "*/*", "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	// starting the upload answers the auth challenge, so the streamed PUT below never has to be repeated
+	endpoint := c.url("/v2/%s/blobs/uploads/", repo)
+	resp, err = c.send(ctx, http.MethodPost, endpoint, repo, "pull,push", "*/*", "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("POST %s: %s", endpoint, resp.Status)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("POST %s: registry returned no upload location", endpoint)
+	}
+	upload, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	upload = upload.ResolveReference(location)
+	q := upload.Query()
+	q.Set("digest", desc.Digest)
+	upload.RawQuery = q.Encode()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(write(pw))
+	}()
+	defer pr.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, upload.String(), pr)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = desc.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	c.authorize(req)
+	resp, err = c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading blob %s: %w", desc.Digest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("uploading blob %s: %s: %s", desc.Digest, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// PutManifest uploads m as repo:tag and returns its digest. The blobs it refers to must have been pushed.
+func (c *Client) PutManifest(ctx context.Context, repo, tag string, m *Manifest) (string, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	endpoint := c.url("/v2/%s/manifests/%s", repo, tag)
+	resp, err := c.send(ctx, http.MethodPut, endpoint, repo, "pull,push", "*/*", m.MediaType, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("PUT %s: %s: %s", endpoint, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(body)), nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) do(ctx context.Context, method, endpoint, accept, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.authorize(req)
+	return c.httpClient().Do(req)
+}
+
+// authorize adds the token obtained from the registry, or else the basic auth credentials, to req
+func (c *Client) authorize(req *http.Request) {
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// authenticate handles a Bearer challenge by fetching a token for actions on repo from the advertised realm
+func (c *Client) authenticate(ctx context.Context, challenge, repo, actions string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry %s requires unsupported authentication %q", c.Host, challenge)
+	}
+	attrs := parseChallenge(params)
+	if attrs["realm"] == "" {
+		return fmt.Errorf("registry %s sent a bearer challenge without realm", c.Host)
+	}
+
+	u, err := url.Parse(attrs["realm"])
+	if err != nil {
+		return fmt.Errorf("invalid token realm %q: %w", attrs["realm"], err)
+	}
+	q := u.Query()
+	if attrs["service"] != "" {
+		q.Set("service", attrs["service"])
+	}
+	scope := "repository:" + repo + ":" + actions
+	if repo == "" {
+		// requests outside any repository only list the catalog
+		scope = "registry:catalog:*"
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("requesting registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requesting registry token: %s", resp.Status)
+	}
+
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return fmt.Errorf("decoding registry token: %w", err)
+	}
+	c.token = tok.Token
+	if c.token == "" {
+		c.token = tok.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("registry %s returned an empty token", c.Host)
+	}
+	return nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of a WWW-Authenticate header
+func parseChallenge(params string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(params, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		attrs[strings.ToLower(k)] = strings.Trim(v, `"`)
+	}
+	return attrs
+}
+
+// DockerConfigCredentials returns the username and password for host from a .dockerconfigjson payload
+func DockerConfigCredentials(data []byte, host string) (string, string, error) {
+	var cfg struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", "", fmt.Errorf("invalid docker config: %w", err)
+	}
+	for key, entry := range cfg.Auths {
+		if strings.TrimPrefix(strings.TrimPrefix(strings.TrimSuffix(key, "/"), "https://"), "http://") != host {
+			continue
+		}
+		if entry.Username != "" {
+			return entry.Username, entry.Password, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid auth entry for %s: %w", host, err)
+		}
+		user, pass, _ := strings.Cut(string(decoded), ":")
+		return user, pass, nil
+	}
+	return "", "", nil
+}
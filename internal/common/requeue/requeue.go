@@ -0,0 +1,179 @@
+// Package requeue paces how reconcilers requeue their objects. Transient failures are retried through the
+// controller's rate-limited workqueue, which backs off exponentially per object and caps the overall retry
+// rate, while objects waiting on work outside the controller are polled at configurable, jittered
+// intervals so a large fleet does not requeue in lockstep. Every requeue is counted in the manager's
+// metrics registry to tune the intervals.
+package requeue
+
+import (
+	"flag"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Kinds of requeues, the kind label of the metrics
+const (
+	KindRetry = "retry"
+	KindPoll  = "poll"
+	KindSync  = "sync"
+)
+
+var (
+	requeues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "automotive_controller_requeues_total",
+		Help: "Requeues requested by reconcilers, by controller, kind (retry, poll or sync) and reason",
+	}, []string{"controller", "kind", "reason"})
+	requeueDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "automotive_controller_requeue_delay_seconds",
+		Help:    "Delay before requeued objects are reconciled again, by controller and kind",
+		Buckets: prometheus.ExponentialBuckets(0.05, 4, 9),
+	}, []string{"controller", "kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requeues, requeueDelay)
+}
+
+// Config holds the requeue settings shared by the operator's controllers
+type Config struct {
+	// BaseDelay is the first retry delay of an object; it doubles with every consecutive retry
+	BaseDelay time.Duration
+	// MaxDelay caps the retry delay of an object
+	MaxDelay time.Duration
+	// QPS and Burst cap the rate of retries across all objects of a controller
+	QPS   float64
+	Burst int
+	// PollInterval is how often objects waiting on pods, uploads or routes are checked
+	PollInterval time.Duration
+	// SyncInterval is how often running builds and served images are resynced
+	SyncInterval time.Duration
+	// Jitter spreads poll and sync requeues by up to this fraction of their interval
+	Jitter float64
+}
+
+// DefaultConfig returns the settings used when no flags are given
+func DefaultConfig() Config {
+	return Config{
+		BaseDelay:    100 * time.Millisecond,
+		MaxDelay:     5 * time.Minute,
+		QPS:          10,
+		Burst:        100,
+		PollInterval: 10 * time.Second,
+		SyncInterval: 30 * time.Second,
+		Jitter:       0.2,
+	}
+}
+
+// BindFlags registers the settings as command line flags, defaulting to the current values
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.BaseDelay, "requeue-base-delay", c.BaseDelay,
+		"First retry delay of an object after a transient failure; doubles with every consecutive failure.")
+	fs.DurationVar(&c.MaxDelay, "requeue-max-delay", c.MaxDelay, "Maximum retry delay of an object.")
+	fs.Float64Var(&c.QPS, "requeue-qps", c.QPS, "Maximum rate of retries per controller, across all objects.")
+	fs.IntVar(&c.Burst, "requeue-burst", c.Burst, "Burst of retries per controller allowed above --requeue-qps.")
+	fs.DurationVar(&c.PollInterval, "requeue-poll-interval", c.PollInterval,
+		"How often objects waiting on pods, uploads or routes are checked.")
+	fs.DurationVar(&c.SyncInterval, "requeue-sync-interval", c.SyncInterval,
+		"How often running builds and served images are resynced.")
+	fs.Float64Var(&c.Jitter, "requeue-jitter", c.Jitter,
+		"Fraction of the poll and sync intervals by which requeues are randomly delayed.")
+}
+
+// withDefaults fills the unset settings of c, so reconcilers built without a Config keep working
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = d.BaseDelay
+	}
+	if c.MaxDelay < c.BaseDelay {
+		c.MaxDelay = max(d.MaxDelay, c.BaseDelay)
+	}
+	if c.QPS <= 0 {
+		c.QPS = d.QPS
+	}
+	if c.Burst <= 0 {
+		c.Burst = d.Burst
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = d.PollInterval
+	}
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = d.SyncInterval
+	}
+	if c.Jitter < 0 {
+		c.Jitter = 0
+	}
+	return c
+}
+
+// Pacer decides when the objects of one controller are requeued
+type Pacer struct {
+	Config
+	// Controller names the controller in the metrics
+	Controller string
+}
+
+// RateLimiter returns the workqueue rate limiter of the controller: per-object exponential backoff from
+// BaseDelay to MaxDelay, capped overall at QPS with Burst
+func (p Pacer) RateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	c := p.withDefaults()
+	return &meteredRateLimiter{
+		TypedRateLimiter: workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](c.BaseDelay, c.MaxDelay),
+			&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(c.QPS), c.Burst)},
+		),
+		controller: p.Controller,
+	}
+}
+
+// Retry requeues an object after a transient failure, such as a conflicting status update; the
+// controller's rate limiter delays it with the object's exponential backoff
+func (p Pacer) Retry(reason string) ctrl.Result {
+	requeues.WithLabelValues(p.Controller, KindRetry, reason).Inc()
+	return ctrl.Result{Requeue: true}
+}
+
+// Poll requeues an object waiting on something outside the controller after the jittered poll interval
+func (p Pacer) Poll(reason string) ctrl.Result {
+	return p.after(KindPoll, reason, p.withDefaults().PollInterval)
+}
+
+// Sync requeues an object whose progress is checked periodically after the jittered sync interval
+func (p Pacer) Sync(reason string) ctrl.Result {
+	return p.after(KindSync, reason, p.withDefaults().SyncInterval)
+}
+
+// SyncEvery requeues an object after the jittered interval, for objects with their own resync period
+func (p Pacer) SyncEvery(reason string, interval time.Duration) ctrl.Result {
+	return p.after(KindSync, reason, interval)
+}
+
+func (p Pacer) after(kind, reason string, interval time.Duration) ctrl.Result {
+	delay := interval
+	// wait.Jitter treats a zero factor as 1, so no jitter is handled here
+	if jitter := p.withDefaults().Jitter; jitter > 0 {
+		delay = wait.Jitter(interval, jitter)
+	}
+	requeues.WithLabelValues(p.Controller, kind, reason).Inc()
+	requeueDelay.WithLabelValues(p.Controller, kind).Observe(delay.Seconds())
+	return ctrl.Result{RequeueAfter: delay}
+}
+
+// meteredRateLimiter records the retry delays of a rate limiter
+type meteredRateLimiter struct {
+	workqueue.TypedRateLimiter[reconcile.Request]
+	controller string
+}
+
+func (m *meteredRateLimiter) When(item reconcile.Request) time.Duration {
+	delay := m.TypedRateLimiter.When(item)
+	requeueDelay.WithLabelValues(m.controller, KindRetry).Observe(delay.Seconds())
+	return delay
+}
@@ -0,0 +1,13 @@
+package requeue
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestRequeue(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "requeue Suite")
+}
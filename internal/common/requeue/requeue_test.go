@@ -0,0 +1,72 @@
+package requeue
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Pacer", func() {
+	item := reconcile.Request{NamespacedName: types.NamespacedName{Name: "b", Namespace: "ns"}}
+
+	DescribeTable("should back off retries exponentially up to the cap",
+		func(config Config, want []time.Duration) {
+			limiter := Pacer{Config: config, Controller: "test"}.RateLimiter()
+			var got []time.Duration
+			for range want {
+				got = append(got, limiter.When(item))
+			}
+			Expect(got).To(Equal(want))
+			Expect(limiter.NumRequeues(item)).To(Equal(len(want)))
+
+			limiter.Forget(item)
+			Expect(limiter.When(item)).To(Equal(want[0]))
+		},
+		Entry("doubling from the base delay", Config{BaseDelay: time.Second, MaxDelay: time.Minute},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}),
+		Entry("capped at the max delay", Config{BaseDelay: time.Second, MaxDelay: 5 * time.Second},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}),
+		Entry("with the defaults when unset", Config{},
+			[]time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}),
+		Entry("with a max delay below the base delay raised to the default", Config{BaseDelay: time.Second, MaxDelay: time.Millisecond},
+			[]time.Duration{time.Second, 2 * time.Second}),
+	)
+
+	It("should retry through the rate limiter", func() {
+		result := Pacer{Controller: "test"}.Retry("status")
+		Expect(result.Requeue).To(BeTrue())
+		Expect(result.RequeueAfter).To(BeZero())
+	})
+
+	DescribeTable("should jitter polls and syncs within their bounds",
+		func(config Config, requeue func(Pacer) time.Duration, interval time.Duration, jitter float64) {
+			p := Pacer{Config: config, Controller: "test"}
+			upper := interval + time.Duration(float64(interval)*jitter)
+			for i := 0; i < 200; i++ {
+				delay := requeue(p)
+				Expect(delay).To(BeNumerically(">=", interval))
+				Expect(delay).To(BeNumerically("<=", upper))
+			}
+		},
+		Entry("polls with the default jitter", DefaultConfig(),
+			func(p Pacer) time.Duration { return p.Poll("pod").RequeueAfter }, 10*time.Second, 0.2),
+		Entry("syncs with the default jitter", DefaultConfig(),
+			func(p Pacer) time.Duration { return p.Sync("build").RequeueAfter }, 30*time.Second, 0.2),
+		Entry("syncs with their own interval", Config{Jitter: 0.5},
+			func(p Pacer) time.Duration { return p.SyncEvery("image", time.Hour).RequeueAfter }, time.Hour, 0.5),
+		Entry("polls without jitter", Config{PollInterval: time.Minute},
+			func(p Pacer) time.Duration { return p.Poll("pod").RequeueAfter }, time.Minute, 0.0),
+	)
+
+	It("should spread requeues instead of using the same delay", func() {
+		p := Pacer{Config: DefaultConfig(), Controller: "test"}
+		delays := map[time.Duration]bool{}
+		for i := 0; i < 20; i++ {
+			delays[p.Poll("pod").RequeueAfter] = true
+		}
+		Expect(len(delays)).To(BeNumerically(">", 1))
+	})
+})
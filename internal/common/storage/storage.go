@@ -0,0 +1,82 @@
+// Package storage accounts for the workspace PVCs the operator creates for builds. The controller checks
+// a namespace's usage against the BuildConfig limit before creating a workspace and the build API reports
+// it, so both count the same claims the same way.
+package storage
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// ImageBuildNameLabel names the ImageBuild a workspace PVC belongs to
+	ImageBuildNameLabel = "automotive.sdv.cloud.redhat.com/imagebuild-name"
+	managedByLabel      = "app.kubernetes.io/managed-by"
+	managedByValue      = "automotive-dev-operator"
+)
+
+// WorkspaceLabels returns the labels of a workspace PVC created for the named build
+func WorkspaceLabels(buildName string) map[string]string {
+	return map[string]string{
+		managedByLabel:      managedByValue,
+		ImageBuildNameLabel: buildName,
+	}
+}
+
+// WorkspaceSelector returns the labels every workspace PVC carries, to list them
+func WorkspaceSelector() map[string]string {
+	return map[string]string{managedByLabel: managedByValue}
+}
+
+// Size returns the storage a workspace PVC holds: its provisioned capacity once bound, which may exceed the
+// request, and its request until then
+func Size(pvc *corev1.PersistentVolumeClaim) int64 {
+	if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		return q.Value()
+	}
+	q := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	return q.Value()
+}
+
+// Usage is the storage held by a set of workspace PVCs
+type Usage struct {
+	Bytes      int64
+	Workspaces int
+}
+
+// Add counts pvc
+func (u *Usage) Add(pvc *corev1.PersistentVolumeClaim) {
+	u.Bytes += Size(pvc)
+	u.Workspaces++
+}
+
+// Live returns the workspace PVCs among pvcs that still hold storage. Claims being deleted are left out
+// since their builds are gone and they no longer count against the limit.
+func Live(pvcs []corev1.PersistentVolumeClaim) []corev1.PersistentVolumeClaim {
+	live := make([]corev1.PersistentVolumeClaim, 0, len(pvcs))
+	for _, pvc := range pvcs {
+		if pvc.DeletionTimestamp != nil || pvc.Labels[ImageBuildNameLabel] == "" {
+			continue
+		}
+		live = append(live, pvc)
+	}
+	return live
+}
+
+// ParseLimit parses a BuildConfig storage limit such as "100Gi". It returns 0 for an empty limit, which
+// leaves storage unlimited.
+func ParseLimit(limit string) (int64, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid workspace storage limit %q: %w", limit, err)
+	}
+	if q.Sign() <= 0 {
+		return 0, fmt.Errorf("invalid workspace storage limit %q: must be positive", limit)
+	}
+	return q.Value(), nil
+}
@@ -0,0 +1,148 @@
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+// Default images of the tools builds run besides automotive-image-builder and the scanner
+const (
+	DefaultYqImage         = "quay.io/konflux-ci/yq:latest"
+	DefaultOrasImage       = "ghcr.io/oras-project/oras:v1.2.0"
+	DefaultFileServerImage = "quay.io/nginx/nginx-unprivileged:latest"
+	DefaultOAuthProxyImage = "registry.redhat.io/openshift4/ose-oauth-proxy:latest"
+	DefaultRegistryImage   = "docker.io/library/registry:2.8.3"
+)
+
+// Images returns the images builds run: the defaults with the BuildConfig's overrides applied
+func Images(buildConfig *automotivev1.BuildConfig) automotivev1.ImageOverrides {
+	images := automotivev1.ImageOverrides{
+		Builder:    AutomotiveImageBuilder,
+		Yq:         DefaultYqImage,
+		Oras:       DefaultOrasImage,
+		FileServer: DefaultFileServerImage,
+		OAuthProxy: DefaultOAuthProxyImage,
+		Registry:   DefaultRegistryImage,
+	}
+	if buildConfig == nil || buildConfig.Images == nil {
+		return images
+	}
+	overrides := buildConfig.Images
+	if overrides.Builder != "" {
+		images.Builder = overrides.Builder
+	}
+	if overrides.Yq != "" {
+		images.Yq = overrides.Yq
+	}
+	if overrides.Oras != "" {
+		images.Oras = overrides.Oras
+	}
+	if overrides.FileServer != "" {
+		images.FileServer = overrides.FileServer
+	}
+	if overrides.OAuthProxy != "" {
+		images.OAuthProxy = overrides.OAuthProxy
+	}
+	if overrides.Registry != "" {
+		images.Registry = overrides.Registry
+	}
+	return images
+}
+
+// imageDigest matches the digests images may be pinned by
+var imageDigest = regexp.MustCompile(`^(sha256:[0-9a-f]{64}|sha512:[0-9a-f]{128})$`)
+
+// imageCheck is an image ValidateImages checks, named after its BuildConfig field; resolved images are pinned
+// to a digest when builds start
+type imageCheck struct {
+	field, image string
+	resolved     bool
+}
+
+// ValidateImages checks that the images builds run are valid references with well-formed digests and, when
+// the BuildConfig requires digests, that each of them is pinned by one
+func ValidateImages(buildConfig *automotivev1.BuildConfig) error {
+	images := Images(buildConfig)
+	checks := []imageCheck{
+		{"builder", images.Builder, builderResolved(buildConfig)},
+		{"yq", images.Yq, false},
+		{"oras", images.Oras, false},
+		{"fileServer", images.FileServer, false},
+		{"oauthProxy", images.OAuthProxy, false},
+		{"registry", images.Registry, false},
+	}
+	if buildConfig != nil && buildConfig.Scan != nil && buildConfig.Scan.Enabled {
+		scanner := buildConfig.Scan.Image
+		if scanner == "" {
+			scanner = DefaultScannerImage
+		}
+		checks = append(checks, imageCheck{"scan.image", scanner, false})
+	}
+	if err := checkImages(checks, RequiresDigests(buildConfig)); err != nil {
+		return fmt.Errorf("invalid build images: %w", err)
+	}
+	return nil
+}
+
+// BuilderImageParam is how steps refer to the builder image a build resolved
+const BuilderImageParam = "$(params.automotive-image-builder)"
+
+// StepImage is the image of a step the operator did not generate, e.g. one of a custom pipeline
+type StepImage struct {
+	// Step names the step, e.g. "build/compile"
+	Step  string
+	Image string
+}
+
+// ValidateStepImages holds the step images of a custom pipeline to the BuildConfig's digest policy: when it
+// requires digests, each must be pinned by one or be the builder image param, which builds resolve themselves
+func ValidateStepImages(buildConfig *automotivev1.BuildConfig, images []StepImage) error {
+	if !RequiresDigests(buildConfig) {
+		return nil
+	}
+	var checks []imageCheck
+	for _, img := range images {
+		if img.Image == BuilderImageParam && builderResolved(buildConfig) {
+			continue
+		}
+		checks = append(checks, imageCheck{img.Step, img.Image, false})
+	}
+	if err := checkImages(checks, true); err != nil {
+		return fmt.Errorf("invalid step images: %w", err)
+	}
+	return nil
+}
+
+// RequiresDigests reports whether the BuildConfig requires images to be pinned by digest
+func RequiresDigests(buildConfig *automotivev1.BuildConfig) bool {
+	return buildConfig != nil && buildConfig.Images != nil && buildConfig.Images.RequireDigests
+}
+
+// builderResolved reports whether builds resolve the builder image to a digest themselves
+func builderResolved(buildConfig *automotivev1.BuildConfig) bool {
+	return buildConfig == nil || buildConfig.BuilderImage == nil ||
+		!buildConfig.BuilderImage.DisableDigestPinning || buildConfig.BuilderImage.CosignPublicKeySecretRef != ""
+}
+
+func checkImages(checks []imageCheck, requireDigests bool) error {
+	var problems []string
+	for _, c := range checks {
+		ref, err := registry.ParseReference(c.image)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: %v", c.field, err))
+		case ref.Digest != "" && !imageDigest.MatchString(ref.Digest):
+			problems = append(problems, fmt.Sprintf("%s: invalid digest %q", c.field, ref.Digest))
+		case requireDigests && ref.Digest == "" && !c.resolved:
+			problems = append(problems, fmt.Sprintf("%s: %s is not pinned by digest", c.field, c.image))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
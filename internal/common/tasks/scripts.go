@@ -4,6 +4,9 @@ import (
 	_ "embed"
 )
 
+//go:embed scripts/pull_manifests.sh
+var PullManifestsScript string
+
 //go:embed scripts/find_manifest.sh
 var FindManifestScript string
 
@@ -12,3 +15,18 @@ var BuildImageScript string
 
 //go:embed scripts/push_artifact.sh
 var PushArtifactScript string
+
+//go:embed scripts/scan_artifact.sh
+var ScanArtifactScript string
+
+//go:embed scripts/convert_artifact.sh
+var ConvertArtifactScript string
+
+//go:embed scripts/prune_workspace.sh
+var PruneWorkspaceScript string
+
+//go:embed scripts/fetch_ostree_parent.sh
+var FetchOSTreeParentScript string
+
+//go:embed scripts/push_ostree.sh
+var PushOSTreeScript string
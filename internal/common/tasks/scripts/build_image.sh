@@ -1,6 +1,9 @@
 #!/bin/sh
 set -e
 
+if [ -n "${REQUEST_ID:-}" ]; then
+  echo "Request ID: ${REQUEST_ID}${TRACEPARENT:+, traceparent: ${TRACEPARENT}}"
+fi
 
 # Make the internal registry trusted
 # TODO think about whether this is really the right approach
@@ -95,19 +98,29 @@ if mountpoint -q "$osbuildPath"; then
     exit 0
 fi
 
-rootType="system_u:object_r:root_t:s0"
-chcon "$rootType" "$storePath"
+# unprivileged builds run osbuild in a user namespace of their own, which needs no relabeling or mounts here
+if [ "$UNPRIVILEGED" = "true" ]; then
+  if ! unshare --user --map-root-user --mount true 2>/dev/null; then
+    echo "Error: this build runs unprivileged (buildConfig.unprivileged) but the node does not let it create a user namespace."
+    echo "Unprivileged builds need user namespaces enabled on the nodes (user.max_user_namespaces > 0) and allowed by the pod's seccomp profile."
+    exit 1
+  fi
+  echo "Running unprivileged: osbuild runs in a user namespace"
+else
+  rootType="system_u:object_r:root_t:s0"
+  chcon "$rootType" "$storePath"
 
-installType="system_u:object_r:install_exec_t:s0"
-if ! mountpoint -q "$runTmp"; then
-  mount -t tmpfs tmpfs "$runTmp"
-fi
+  installType="system_u:object_r:install_exec_t:s0"
+  if ! mountpoint -q "$runTmp"; then
+    mount -t tmpfs tmpfs "$runTmp"
+  fi
 
-destPath="$runTmp/osbuild"
-cp -p "$osbuildPath" "$destPath"
-chcon "$installType" "$destPath"
+  destPath="$runTmp/osbuild"
+  cp -p "$osbuildPath" "$destPath"
+  chcon "$installType" "$destPath"
 
-mount --bind "$destPath" "$osbuildPath"
+  mount --bind "$destPath" "$osbuildPath"
+fi
 
 cd $(workspaces.shared-workspace.path)
 
@@ -119,7 +132,11 @@ else
   file_extension=".$(params.export-format)"
 fi
 
-cleanName=$(params.distro)-$(params.target)
+# the controller renders the artifact's name from the build's artifact name template
+cleanName="$(params.artifact-name)"
+if [ -z "$cleanName" ]; then
+  cleanName="$DEFAULT_ARTIFACT_NAME"
+fi
 exportFile=${cleanName}${file_extension}
 
 mode_param=""
@@ -140,6 +157,20 @@ else
   echo "No custom-definitions.env file found"
 fi
 
+# the build's repository in the operator's in-cluster registry, for intermediate container content
+if [ -n "$INTERMEDIATE_REGISTRY" ]; then
+  echo "Intermediate registry: $INTERMEDIATE_REGISTRY"
+  CUSTOM_DEFS+=" --define intermediate_registry=$INTERMEDIATE_REGISTRY"
+fi
+
+# builds of an ostree ref commit to the repository the fetch-ostree-parent step prepared
+OSTREE_ARGS=""
+if [ -n "$OSTREE_REF" ]; then
+  echo "Committing to ostree ref $OSTREE_REF"
+  OSTREE_ARGS="--ostree-repo /output/ostree-repo"
+  CUSTOM_DEFS+=" --define ostree_ref=$OSTREE_REF"
+fi
+
 AIB_OVERRIDE_ARGS_FILE="$(workspaces.manifest-config-workspace.path)/aib-override-args.txt"
 AIB_EXTRA_ARGS_FILE="$(workspaces.manifest-config-workspace.path)/aib-extra-args.txt"
 AIB_ARGS=""
@@ -180,8 +211,6 @@ if [ -f "$AIB_OVERRIDE_ARGS_FILE" ]; then
   override_export=$(get_flag_value "--export" $AIB_ARGS)
   override_distro=$(get_flag_value "--distro" $AIB_ARGS)
   override_target=$(get_flag_value "--target" $AIB_ARGS)
-  [ -n "$override_distro" ] && cleanName="$override_distro-${cleanName#*-}"
-  [ -n "$override_target" ] && cleanName="${cleanName%-*}-$override_target"
   if [ -n "$override_export" ]; then
     case "$override_export" in
       image)
@@ -195,30 +224,56 @@ if [ -f "$AIB_OVERRIDE_ARGS_FILE" ]; then
   exportFile=${cleanName}${file_extension}
 fi
 
+# without loop devices, unprivileged builds can only write the export formats that need none
+if [ "$UNPRIVILEGED" = "true" ]; then
+  export_format="$(params.export-format)"
+  if [ "$USE_OVERRIDE" = true ] && [ -n "$override_export" ]; then
+    export_format="$override_export"
+  fi
+  case " $UNPRIVILEGED_EXPORT_FORMATS " in
+    *" $export_format "*) ;;
+    *)
+      echo "Error: export format $export_format needs loop devices, which unprivileged builds (buildConfig.unprivileged) do not have."
+      echo "Unprivileged builds support the export formats: $UNPRIVILEGED_EXPORT_FORMATS"
+      exit 1
+      ;;
+  esac
+fi
+
+# the build command is kept in the positional parameters rather than in a string passed to eval, so the
+# AIB args reach automotive-image-builder as words and are never run by the shell; set -f stops them
+# from being expanded as globs
+set -f
 if [ "$USE_OVERRIDE" = true ]; then
-  build_command="automotive-image-builder --verbose \
+  set -- automotive-image-builder --verbose \
   build \
   $CUSTOM_DEFS \
   --build-dir=/output/_build \
   --osbuild-manifest=/output/image.json \
+  $OSTREE_ARGS \
   $AIB_ARGS \
-  $MANIFEST_FILE \
-  /output/${exportFile}"
+  "$MANIFEST_FILE" \
+  "/output/${exportFile}"
 else
-  build_command="automotive-image-builder --verbose \
+  set -- automotive-image-builder --verbose \
   build \
   $CUSTOM_DEFS \
-  --distro $(params.distro) \
-  --target $(params.target) \
-  --arch=${arch} \
+  --distro "$(params.distro)" \
+  --target "$(params.target)" \
+  --arch="${arch}" \
   --build-dir=/output/_build \
-  --export $(params.export-format) \
+  --export "$(params.export-format)" \
   --osbuild-manifest=/output/image.json \
   $mode_param \
+  $OSTREE_ARGS \
   $AIB_ARGS \
-  $MANIFEST_FILE \
-  /output/${exportFile}"
+  "$MANIFEST_FILE" \
+  "/output/${exportFile}"
 fi
+if [ "$UNPRIVILEGED" = "true" ]; then
+  set -- unshare --user --map-root-user --mount "$@"
+fi
+set +f
 
 echo "contents of shared workspace before build:"
 ls -la $(workspaces.shared-workspace.path)/
@@ -226,8 +281,52 @@ echo "contents of working manifest:"
 cat "$MANIFEST_FILE"
 
 
-echo "Running the build command: $build_command"
-eval "$build_command"
+# keep_failed_workspace copies the text files of the build directory, such as osbuild logs and
+# generated manifests, to the shared workspace so they can be inspected after the pod is gone
+keep_failed_workspace() {
+  debugDir="$(workspaces.shared-workspace.path)/_build"
+  echo "keeping build directory logs in $debugDir"
+  mkdir -p "$debugDir"
+  if [ -d /output/_build ]; then
+    (cd /output/_build && find . -type f -size -50M \( -name '*.log' -o -name '*.json' -o -name '*.txt' -o -name '*.yml' -o -name '*.yaml' \) \
+      -exec cp --parents {} "$debugDir"/ \;) || echo "Failed to copy build directory logs"
+  fi
+  cp -v /output/image.json "$debugDir"/ 2>/dev/null || true
+  cp -v "$MANIFEST_FILE" "$debugDir"/ || true
+}
+
+# record_resource_usage writes the peak memory and CPU time of this step, read from its cgroup (v2 or v1),
+# and the space the build used in its directories as the resource-usage result; what cannot be read is left out
+record_resource_usage() {
+  usage=""
+  if [ -f /sys/fs/cgroup/memory.peak ]; then
+    usage="memory-peak=$(cat /sys/fs/cgroup/memory.peak)"
+  elif [ -f /sys/fs/cgroup/memory/memory.max_usage_in_bytes ]; then
+    usage="memory-peak=$(cat /sys/fs/cgroup/memory/memory.max_usage_in_bytes)"
+  fi
+  cpu_usec=""
+  if [ -f /sys/fs/cgroup/cpu.stat ]; then
+    cpu_usec=$(sed -n 's/^usage_usec //p' /sys/fs/cgroup/cpu.stat)
+  elif [ -f /sys/fs/cgroup/cpuacct/cpuacct.usage ]; then
+    cpu_usec=$(( $(cat /sys/fs/cgroup/cpuacct/cpuacct.usage) / 1000 ))
+  fi
+  [ -n "$cpu_usec" ] && usage="$usage cpu-seconds=$(( cpu_usec / 1000000 ))"
+  disk=$(du -scxb /output /_build /run/osbuild 2>/dev/null | tail -n1 | cut -f1)
+  [ -n "$disk" ] && usage="$usage disk=$disk"
+  echo "Resource usage of the build:$usage"
+  printf '%s' "${usage# }" > /tekton/results/resource-usage || true
+}
+
+echo "Running the build command: $*"
+if ! "$@"; then
+  echo "Build command failed"
+  record_resource_usage
+  if [ "$(params.keep-workspace-on-failure)" = "true" ]; then
+    keep_failed_workspace
+  fi
+  exit 1
+fi
+record_resource_usage
 
 pushd /output
 ln -sf ./${exportFile} ./disk.img
@@ -302,6 +401,16 @@ tar_dir_gzip() {
   tar -C $(workspaces.shared-workspace.path) -czf "$out" "$dir"
 }
 
+compress_file_none() {
+  src="$1"; dest="$2"
+  cp "$src" "$dest"
+}
+
+tar_dir_none() {
+  dir="$1"; out="$2"
+  tar -C $(workspaces.shared-workspace.path) -cf "$out" "$dir"
+}
+
 tar_dir_lz4() {
   dir="$1"; out="$2"
   tar -C $(workspaces.shared-workspace.path) -cf - "$dir" | lz4 -z -f -q > "$out"
@@ -311,6 +420,7 @@ compress_file() {
   src="$1"; dest="$2"
   case "$COMPRESSION" in
     lz4) compress_file_lz4 "$src" "$dest" ;;
+    none) compress_file_none "$src" "$dest" ;;
     gzip|*) compress_file_gzip "$src" "$dest" ;;
   esac
 }
@@ -319,6 +429,7 @@ tar_dir() {
   dir="$1"; out="$2"
   case "$COMPRESSION" in
     lz4) tar_dir_lz4 "$dir" "$out" ;;
+    none) tar_dir_none "$dir" "$out" ;;
     gzip|*) tar_dir_gzip "$dir" "$out" ;;
   esac
 }
@@ -328,12 +439,43 @@ case "$COMPRESSION" in
     EXT_FILE=".lz4"
     EXT_DIR=".tar.lz4"
     ;;
+  none)
+    EXT_FILE=""
+    EXT_DIR=".tar"
+    ;;
   gzip|*)
     EXT_FILE=".gz"
     EXT_DIR=".tar.gz"
     ;;
 esac
 
+# payload_type names the media type of a file before compression and encryption, matching the
+# artifacttype package of the operator
+payload_type() {
+  base=$(basename "$1")
+  case "$base" in
+    aboot.img|boot.img|*.aboot|*-aboot.img|*.aboot.img) echo "application/x-android-boot-image" ;;
+    *.simg) echo "application/x-android-sparse-image" ;;
+    *.qcow2) echo "application/x-qemu-disk" ;;
+    *.raw|*.img) echo "application/x-raw-disk-image" ;;
+    *.vmdk) echo "application/x-vmdk" ;;
+    *.vdi) echo "application/x-virtualbox-vdi" ;;
+    *.vhdx) echo "application/x-vhdx" ;;
+    *.tar) echo "application/x-tar" ;;
+    *.json) echo "application/json" ;;
+    *) echo "application/octet-stream" ;;
+  esac
+}
+
+# The type is recorded before compression renames the export: directory exports are served as tar archives
+content_type=""
+part_types=""
+if [ -d "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
+  content_type="application/x-tar"
+elif [ -f "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
+  content_type=$(payload_type "$exportFile")
+fi
+
 final_name=""
 if [ -d "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
   echo "Preparing compressed parts for directory ${exportFile}..."
@@ -348,12 +490,18 @@ if [ -d "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
       if [ -f "$item" ]; then
         echo "Creating $parts_dir/${base}${EXT_FILE}"
         compress_file "$item" "$parts_dir/${base}${EXT_FILE}" || echo "Failed to create $parts_dir/${base}${EXT_FILE}"
+        printf '%s\t%s\n' "${base}${EXT_FILE}" "$(payload_type "$base")" >> "$parts_dir/.types"
       elif [ -d "$item" ]; then
         echo "Creating $parts_dir/${base}${EXT_DIR}"
         tar_dir "${exportFile}/$base" "$parts_dir/${base}${EXT_DIR}" || echo "Failed to create $parts_dir/${base}${EXT_DIR}"
+        printf '%s\t%s\n' "${base}${EXT_DIR}" "application/x-tar" >> "$parts_dir/.types"
       fi
     done
   )
+  if [ -f "$parts_dir/.types" ]; then
+    part_types=$(cat "$parts_dir/.types")
+    rm -f "$parts_dir/.types"
+  fi
   echo "Creating compressed archive ${final_compressed_name} in shared workspace..."
   tar_dir "${exportFile}" "$(workspaces.shared-workspace.path)/${final_compressed_name}" || echo "Failed to create ${final_compressed_name}"
   echo "Compressed archive size:" && ls -lah $(workspaces.shared-workspace.path)/${final_compressed_name} || true
@@ -371,6 +519,10 @@ if [ -d "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
       ls -la "$(workspaces.shared-workspace.path)/${final_compressed_name}-parts/" || true
     fi
   fi
+elif [ -f "$(workspaces.shared-workspace.path)/${exportFile}" ] && [ "$COMPRESSION" = "none" ]; then
+  # local clusters spend more time compressing than they save transferring
+  echo "Compression disabled, serving ${exportFile} as is"
+  final_name="${exportFile}"
 elif [ -f "$(workspaces.shared-workspace.path)/${exportFile}" ]; then
   echo "Creating compressed file ${exportFile}${EXT_FILE} in shared workspace..."
   compress_file "$(workspaces.shared-workspace.path)/${exportFile}" "$(workspaces.shared-workspace.path)/${exportFile}${EXT_FILE}" || echo "Failed to create ${exportFile}${EXT_FILE}"
@@ -389,6 +541,101 @@ if [ -z "$final_name" ]; then
     final_name=$(basename "$guess")
   fi
 fi
+
+# Encrypted builds leave no plaintext artifact in the workspace the artifact pod serves
+ENCRYPTION="none"
+if [ "$(workspaces.encryption-key.bound)" = "true" ] && [ -n "$final_name" ]; then
+  if ! command -v openssl >/dev/null 2>&1; then
+    echo "openssl is required to encrypt the artifacts"
+    exit 1
+  fi
+  # CBC alone does not detect tampering, so an HMAC-SHA256 of the ciphertext, keyed with the HMAC of the
+  # passphrase under "artifact-mac", is appended to every encrypted file
+  IFS= read -r passphrase < "$(workspaces.encryption-key.path)/key" || true
+  mac_key=$(printf '%s' "$passphrase" | openssl dgst -sha256 -hmac "artifact-mac" -r | cut -d' ' -f1)
+  unset passphrase
+  encrypt_file() {
+    openssl enc -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -salt \
+      -pass "file:$(workspaces.encryption-key.path)/key" -in "$1" -out "$1.enc" &&
+      openssl dgst -sha256 -mac HMAC -macopt "hexkey:${mac_key}" -binary -out "$1.mac" "$1.enc" &&
+      cat "$1.mac" >> "$1.enc" && rm -f "$1" "$1.mac"
+  }
+  pushd $(workspaces.shared-workspace.path)
+  if [ -d "${final_name}-parts" ]; then
+    for part in "${final_name}-parts"/*; do
+      [ -f "$part" ] || continue
+      encrypt_file "$part" || { echo "Failed to encrypt $part"; exit 1; }
+    done
+    mv "${final_name}-parts" "${final_name}.enc-parts"
+  fi
+  echo "Encrypting ${final_name}..."
+  encrypt_file "$final_name" || { echo "Failed to encrypt ${final_name}"; exit 1; }
+  rm -rf "${exportFile}"
+  final_name="${final_name}.enc"
+  ln -sf ${final_name} disk.img
+  popd
+  ENCRYPTION="aes-256-cbc-pbkdf2-hmac-sha256"
+fi
+
 if [ -n "$final_name" ]; then
+  artifact_path="$(workspaces.shared-workspace.path)/${final_name}"
+  artifact_size=$(du -sbL "$artifact_path" 2>/dev/null | cut -f1)
+  artifact_sha256=""
+  if [ -f "$artifact_path" ]; then
+    artifact_sha256=$(sha256sum "$artifact_path" | cut -d' ' -f1)
+  fi
   echo "$final_name" > /tekton/results/artifact-filename || true
+  echo "${artifact_size}" > /tekton/results/artifact-size || true
+
+  json_str() {
+    printf '%s' "$1" | sed 's/\\/\\\\/g; s/"/\\"/g'
+  }
+
+  # Results that can outgrow Tekton's result size limit are passed to the operator in the workspace
+  json_name=$(json_str "$final_name")
+  cat > "$(workspaces.shared-workspace.path)/.automotive-results.json" <<EOF
+{"artifactFileName": "${json_name}", "artifactSize": ${artifact_size:-0}, "artifactSha256": "${artifact_sha256}", "artifactContentType": "$(json_str "$content_type")"}
+EOF
+
+  # The metadata file next to the artifact is the contract for tools that flash or verify it
+  builder_image="$(params.automotive-image-builder)"
+  builder_digest=""
+  case "$builder_image" in
+    *@sha256:*) builder_digest="${builder_image##*@}" ;;
+  esac
+  # parts keep their names through encryption but gain its suffix
+  parts_json=""
+  if [ -n "$part_types" ]; then
+    part_suffix=""
+    if [ "$ENCRYPTION" != "none" ]; then
+      part_suffix=".enc"
+    fi
+    parts_json=$(printf '%s\n' "$part_types" | while IFS="$(printf '\t')" read -r part type; do
+      if [ -n "$part" ]; then
+        printf '{"name": "%s", "contentType": "%s"},' "$(json_str "${part}${part_suffix}")" "$(json_str "$type")"
+      fi
+    done)
+    parts_json="[${parts_json%,}]"
+  fi
+  cat > "${artifact_path}.metadata.json" <<EOF
+{
+  "name": "${json_name}",
+  "buildName": "$(json_str "$(params.build-name)")",
+  "sizeBytes": ${artifact_size:-0},
+  "sha256": "${artifact_sha256}",
+  "contentType": "$(json_str "$content_type")",
+  "parts": ${parts_json:-[]},
+  "compression": "$(json_str "$COMPRESSION")",
+  "encryption": "${ENCRYPTION}",
+  "distro": "$(json_str "${override_distro:-$(params.distro)}")",
+  "target": "$(json_str "${override_target:-$(params.target)}")",
+  "architecture": "$(json_str "$(params.target-architecture)")",
+  "exportFormat": "$(json_str "${override_export:-$(params.export-format)}")",
+  "created": "$(date -u +%Y-%m-%dT%H:%M:%SZ)",
+  "builderImage": "$(json_str "$builder_image")",
+  "builderDigest": "${builder_digest}"
+}
+EOF
+  echo "Wrote ${final_name}.metadata.json:"
+  cat "${artifact_path}.metadata.json"
 fi
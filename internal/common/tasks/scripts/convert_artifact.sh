@@ -0,0 +1,51 @@
+#!/bin/sh
+# Converts the artifact of a completed build to another disk image format next to it in the workspace.
+# Arguments: workspace, uncompressed image, artifact, output file name and format. The size and checksum
+# of the converted file are written to the termination log as "size=N sha256=HEX".
+set -eu
+
+workspace="$1"
+image_name="$2"
+artifact="$workspace/$3"
+output_name="$4"
+format="$5"
+
+source="$workspace/$image_name"
+tmp="$workspace/.${output_name}.tmp"
+scratch="$workspace/.${output_name}.src"
+trap 'rm -f "$tmp" "$scratch" "$scratch.raw"' EXIT
+
+# the build keeps the uncompressed image next to the compressed artifact; restore it if it is gone
+if [ ! -f "$source" ]; then
+  echo "Decompressing $3..."
+  case "$artifact" in
+    *.lz4) lz4 -d -c "$artifact" > "$scratch" ;;
+    *.gz) gzip -d -c "$artifact" > "$scratch" ;;
+    *) echo "$image_name is not in the workspace" >&2; exit 1 ;;
+  esac
+  source="$scratch"
+fi
+
+echo "Converting $image_name to $format..."
+case "$format" in
+  simg)
+    # img2simg only reads raw images
+    case "$image_name" in
+      *.raw) raw="$source" ;;
+      *)
+        raw="$scratch.raw"
+        qemu-img convert -p -O raw "$source" "$raw"
+        ;;
+    esac
+    img2simg "$raw" "$tmp"
+    ;;
+  *)
+    qemu-img convert -p -O "$format" "$source" "$tmp"
+    ;;
+esac
+
+mv "$tmp" "$workspace/$output_name"
+size=$(stat -c %s "$workspace/$output_name")
+sha256=$(sha256sum "$workspace/$output_name" | cut -d' ' -f1)
+echo "Converted $image_name to $output_name ($size bytes)"
+printf 'size=%s sha256=%s' "$size" "$sha256" > /dev/termination-log
@@ -0,0 +1,62 @@
+#!/bin/sh
+set -e
+
+if [ -z "$OSTREE_REF" ]; then
+  echo "no ostree repository, nothing to fetch"
+  exit 0
+fi
+
+# ensure_rsync installs rsync into builder images that lack it
+ensure_rsync() {
+  if ! command -v rsync >/dev/null 2>&1; then
+    echo "rsync not found. Attempting to install..."
+    dnf -y install rsync openssh-clients || microdnf install -y rsync openssh-clients || yum -y install rsync openssh-clients || true
+  fi
+  if ! command -v rsync >/dev/null 2>&1; then
+    echo "rsync is required to reach the ostree repository"
+    exit 1
+  fi
+}
+
+# setup_ssh logs in with the key of the ostree-auth workspace, pinning the host key when it holds known_hosts
+setup_ssh() {
+  ssh_opts="-o BatchMode=yes -o UserKnownHostsFile=$HOME/.ssh/known_hosts"
+  mkdir -p "$HOME/.ssh"
+  chmod 700 "$HOME/.ssh"
+  if [ "$(workspaces.ostree-auth.bound)" = "true" ]; then
+    cp "$(workspaces.ostree-auth.path)/ssh-privatekey" "$HOME/.ssh/id_ostree"
+    chmod 600 "$HOME/.ssh/id_ostree"
+    ssh_opts="$ssh_opts -i $HOME/.ssh/id_ostree"
+    if [ -f "$(workspaces.ostree-auth.path)/known_hosts" ]; then
+      cp "$(workspaces.ostree-auth.path)/known_hosts" "$HOME/.ssh/known_hosts"
+      ssh_opts="$ssh_opts -o StrictHostKeyChecking=yes"
+    else
+      ssh_opts="$ssh_opts -o StrictHostKeyChecking=accept-new"
+    fi
+  fi
+  export RSYNC_RSH="ssh $ssh_opts"
+}
+
+ensure_rsync
+setup_ssh
+
+# automotive-image-builder commits on top of the commit the local repository holds for the ref
+repo=/output/ostree-repo
+ostree init --repo="$repo" --mode=archive
+
+remote="${OSTREE_REPOSITORY_URL%/}"
+mkdir -p "$repo/refs/heads/$(dirname "$OSTREE_REF")"
+if ! rsync "$remote/refs/heads/$OSTREE_REF" "$repo/refs/heads/$OSTREE_REF"; then
+  echo "ref $OSTREE_REF is not in $remote yet, the commit will have no parent"
+  rm -f "$repo/refs/heads/$OSTREE_REF"
+  exit 0
+fi
+
+parent=$(cat "$repo/refs/heads/$OSTREE_REF")
+prefix=$(printf '%s' "$parent" | cut -c1-2)
+rest=$(printf '%s' "$parent" | cut -c3-)
+echo "parent commit of $OSTREE_REF: $parent"
+# only the commit object is fetched; the objects of its tree stay in the remote repository
+mkdir -p "$repo/objects/$prefix" "$repo/state"
+rsync "$remote/objects/$prefix/$rest.commit" "$repo/objects/$prefix/$rest.commit"
+touch "$repo/state/$parent.commitpartial"
@@ -1,57 +1,135 @@
 #!/bin/sh
 set -e
 
+MANIFEST_DIR=$(workspaces.manifest-config-workspace.path)
+# manifests pulled from an OCI artifact take the place of the ones in the ConfigMap
+if [ -n "$(ls -A /manifest-bundle 2>/dev/null)" ]; then
+  MANIFEST_DIR=/manifest-bundle
+fi
+REQUESTED_MANIFEST="$(params.manifest-file)"
+
 echo "looking for manifest file..."
 
 echo "listing contents of manifest config workspace:"
-ls -la $(workspaces.manifest-config-workspace.path)
-
-MANIFEST_FILE=$(find $(workspaces.manifest-config-workspace.path) -name '*.mpp.yml' -o -name '*.aib.yml' -type f | head -n 1)
+ls -la "$MANIFEST_DIR"
+
+if [ -n "$REQUESTED_MANIFEST" ]; then
+  MANIFEST_FILE="$MANIFEST_DIR/$REQUESTED_MANIFEST"
+  if [ ! -e "$MANIFEST_FILE" ]; then
+    echo "Manifest file $REQUESTED_MANIFEST not found in $MANIFEST_DIR"
+    exit 1
+  fi
+else
+  # the first in byte order
+  MANIFEST_FILE=$(find "$MANIFEST_DIR" -maxdepth 1 \( -name '*.mpp.yml' -o -name '*.aib.yml' \) | LC_ALL=C sort | head -n 1)
+fi
 
 if [ -z "$MANIFEST_FILE" ]; then
-  echo "No manifest file found in the ConfigMap"
+  echo "No manifest file found in $MANIFEST_DIR"
   exit 1
 fi
 
 echo "found manifest file at $MANIFEST_FILE"
 
-manifest_basename=$(basename "$MANIFEST_FILE")
-workspace_manifest="/manifest-work/$manifest_basename"
-
-cp "$MANIFEST_FILE" "$workspace_manifest"
-echo "created working copy of manifest at $workspace_manifest"
-
-cat "$workspace_manifest" > "$workspace_manifest.tmp"
-
-if yq eval '.content.add_files' "$workspace_manifest.tmp" | grep -q '^[^#]'; then
-  indices=$(yq eval '.content.add_files | to_entries | .[] | select(.value.source != null and .value.text == null) | .key' "$workspace_manifest.tmp")
-
-  for idx in $indices; do
-    yq eval -i ".content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.content.add_files[$idx].source // \"\")" "$workspace_manifest.tmp"
-  done
-
-  sp_indices=$(yq eval '.content.add_files | to_entries | .[] | select(.value.source_path != null and (.value.source_path | test("^/") | not) and .value.text == null) | .key' "$workspace_manifest.tmp")
-  for idx in $sp_indices; do
-    yq eval -i ".content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.content.add_files[$idx].source_path // \"\")" "$workspace_manifest.tmp"
-  done
+# ConfigMaps can be edited after the fact; refuse to build from anything but the files the build was created with
+if [ -n "$EXPECTED_MANIFEST_SHA256" ]; then
+  # one "<sha256>  <key>" line per file of the ConfigMap in byte order, as the controller hashes it
+  config_file_sums() {
+    (
+      cd "$(workspaces.manifest-config-workspace.path)"
+      for f in $(LC_ALL=C ls); do
+        printf '%s  %s\n' "$(sha256sum < "$f" | cut -d' ' -f1)" "$f"
+      done
+    )
+  }
+  actual_sha256=$(config_file_sums | sha256sum | cut -d' ' -f1)
+  if [ "$actual_sha256" != "$EXPECTED_MANIFEST_SHA256" ]; then
+    echo "the manifest ConfigMap was modified after the build was created:"
+    echo "  expected sha256 $EXPECTED_MANIFEST_SHA256"
+    echo "  found sha256    $actual_sha256 of"
+    config_file_sums | sed 's/^/    /'
+    exit 1
+  fi
+  echo "manifest ConfigMap sha256 $actual_sha256 matches the one recorded when the build was created"
 fi
 
-if yq eval '.qm.content.add_files' "$workspace_manifest.tmp" | grep -q '^[^#]'; then
-  indices=$(yq eval '.qm.content.add_files | to_entries | .[] | select(.value.source != null and .value.text == null) | .key' "$workspace_manifest.tmp")
-
-  for idx in $indices; do
-    yq eval -i ".qm.content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.qm.content.add_files[$idx].source // \"\")" "$workspace_manifest.tmp"
-  done
+manifest_basename=$(basename "$MANIFEST_FILE")
+workspace_manifest="/manifest-work/$manifest_basename"
 
-  sp_indices=$(yq eval '.qm.content.add_files | to_entries | .[] | select(.value.source_path != null and (.value.source_path | test("^/") | not) and .value.text == null) | .key' "$workspace_manifest.tmp")
-  for idx in $sp_indices; do
-    yq eval -i ".qm.content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.qm.content.add_files[$idx].source_path // \"\")" "$workspace_manifest.tmp"
-  done
+# rewrite_sources points relative add_files sources of a manifest at the shared workspace
+rewrite_sources() {
+  file="$1"
+  cat "$file" > "$file.tmp"
+
+  if yq eval '.content.add_files' "$file.tmp" | grep -q '^[^#]'; then
+    indices=$(yq eval '.content.add_files | to_entries | .[] | select(.value.source != null and .value.text == null) | .key' "$file.tmp")
+
+    for idx in $indices; do
+      yq eval -i ".content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.content.add_files[$idx].source // \"\")" "$file.tmp"
+    done
+
+    sp_indices=$(yq eval '.content.add_files | to_entries | .[] | select(.value.source_path != null and (.value.source_path | test("^/") | not) and .value.text == null) | .key' "$file.tmp")
+    for idx in $sp_indices; do
+      yq eval -i ".content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.content.add_files[$idx].source_path // \"\")" "$file.tmp"
+    done
+  fi
+
+  if yq eval '.qm.content.add_files' "$file.tmp" | grep -q '^[^#]'; then
+    indices=$(yq eval '.qm.content.add_files | to_entries | .[] | select(.value.source != null and .value.text == null) | .key' "$file.tmp")
+
+    for idx in $indices; do
+      yq eval -i ".qm.content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.qm.content.add_files[$idx].source // \"\")" "$file.tmp"
+    done
+
+    sp_indices=$(yq eval '.qm.content.add_files | to_entries | .[] | select(.value.source_path != null and (.value.source_path | test("^/") | not) and .value.text == null) | .key' "$file.tmp")
+    for idx in $sp_indices; do
+      yq eval -i ".qm.content.add_files[$idx].source_path = \"$(workspaces.shared-workspace.path)/\" + (.qm.content.add_files[$idx].source_path // \"\")" "$file.tmp"
+    done
+  fi
+
+  # Replace original with processed file
+  mv "$file.tmp" "$file"
+}
+
+# Every manifest in the ConfigMap or artifact is copied next to the main one so relative includes resolve
+for f in "$MANIFEST_DIR"/*; do
+  name=$(basename "$f")
+  case "$name" in
+    custom-definitions.env|aib-extra-args.txt|aib-override-args.txt)
+      continue
+      ;;
+  esac
+  cp -r "$f" "/manifest-work/$name"
+  echo "created working copy of $name"
+  case "$name" in
+    *.yml|*.yaml)
+      rewrite_sources "/manifest-work/$name"
+      ;;
+  esac
+done
+
+# The controller hands over the build provenance it knows; the manifest hash and build time are added here
+if [ -n "$BUILD_INFO" ]; then
+  case "$workspace_manifest" in
+    *.mpp.yml)
+      echo "warning: build info is only supported for *.aib.yml manifests, skipping"
+      ;;
+    *)
+      info_file="$(workspaces.shared-workspace.path)/.automotive-build-info"
+      {
+        printf '%s' "$BUILD_INFO"
+        printf 'MANIFEST_SHA256="%s"\n' "$(sha256sum "$MANIFEST_FILE" | cut -d' ' -f1)"
+        printf 'BUILD_TIME="%s"\n' "$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+      } > "$info_file"
+      INFO_FILE="$info_file" yq eval -i \
+        '.content.add_files += [{"path": "/etc/automotive-build-info", "source_path": strenv(INFO_FILE)}]' \
+        "$workspace_manifest"
+      echo "added build info:"
+      cat "$info_file"
+      ;;
+  esac
 fi
 
-# Replace original with processed file
-mv "$workspace_manifest.tmp" "$workspace_manifest"
-
 echo "updated manifest contents:"
 cat "$workspace_manifest"
 
@@ -0,0 +1,62 @@
+#!/bin/sh
+set -e
+
+# Everything the build step left in the workspace besides what is served with the artifact, such as
+# the uncompressed export next to the compressed artifact, is an intermediate the PVC does not need
+# to hold for the rest of the build's life.
+WORKSPACE="$(workspaces.shared-workspace.path)"
+ARTIFACT=$(cat /tekton/results/artifact-filename 2>/dev/null | tr -d '\n' || true)
+if [ -z "$ARTIFACT" ]; then
+  echo "No artifact was produced, leaving the workspace as is"
+  exit 0
+fi
+
+KEEP_PATTERNS="$(params.workspace-keep)"
+
+workspace_bytes() {
+  du -sxb "$WORKSPACE" 2>/dev/null | cut -f1
+}
+
+keep() {
+  case "$1" in
+    "$ARTIFACT" | "${ARTIFACT}-parts" | "${ARTIFACT}.metadata.json" | "${ARTIFACT}.scan.json" | \
+      disk.img | image.json | .automotive-results.json | lost+found)
+      return 0
+      ;;
+  esac
+  # the patterns are matched, not expanded against the working directory
+  set -f
+  for pattern in $KEEP_PATTERNS; do
+    # shellcheck disable=SC2254
+    case "$1" in
+      $pattern)
+        set +f
+        return 0
+        ;;
+    esac
+  done
+  set +f
+  return 1
+}
+
+before=$(workspace_bytes)
+cd "$WORKSPACE"
+for entry in * .[!.]* ..?*; do
+  [ -e "$entry" ] || [ -L "$entry" ] || continue
+  if keep "$entry"; then
+    continue
+  fi
+  echo "Removing ${entry}"
+  rm -rf -- "$entry"
+done
+if [ -L disk.img ] && [ ! -e disk.img ]; then
+  rm -f disk.img
+fi
+after=$(workspace_bytes)
+
+pruned=$(( ${before:-0} - ${after:-0} ))
+if [ "$pruned" -lt 0 ]; then
+  pruned=0
+fi
+echo "Workspace holds ${after} bytes, reclaimed ${pruned} bytes ($(( pruned / 1048576 )) MiB)"
+printf 'workspace=%s workspace-pruned=%s' "${after:-0}" "$pruned" > /tekton/results/workspace-usage
@@ -0,0 +1,34 @@
+#!/bin/sh
+set -e
+
+if [ -z "$MANIFEST_REF" ]; then
+  echo "no manifest artifact, using the manifest ConfigMap"
+  exit 0
+fi
+
+# oras reads registry credentials from $DOCKER_CONFIG/config.json
+export DOCKER_CONFIG=/tmp/.docker
+mkdir -p "$DOCKER_CONFIG"
+if [ -n "$REGISTRY_AUTH_FILE_CONTENT" ]; then
+  echo "Using provided registry auth file content"
+  printf '%s' "$REGISTRY_AUTH_FILE_CONTENT" > "$DOCKER_CONFIG/config.json"
+elif [ -n "$REGISTRY_USERNAME" ] && [ -n "$REGISTRY_PASSWORD" ] && [ -n "$REGISTRY_URL" ]; then
+  echo "Creating registry auth from username/password for $REGISTRY_URL"
+  printf '{"auths":{"%s":{"auth":"%s"}}}' "$REGISTRY_URL" \
+    "$(printf '%s:%s' "$REGISTRY_USERNAME" "$REGISTRY_PASSWORD" | base64 -w0)" > "$DOCKER_CONFIG/config.json"
+elif [ -n "$REGISTRY_TOKEN" ] && [ -n "$REGISTRY_URL" ]; then
+  echo "Creating registry auth from token for $REGISTRY_URL"
+  printf '{"auths":{"%s":{"auth":"%s"}}}' "$REGISTRY_URL" \
+    "$(printf 'token:%s' "$REGISTRY_TOKEN" | base64 -w0)" > "$DOCKER_CONFIG/config.json"
+fi
+
+echo "pulling manifests from $MANIFEST_REF"
+oras pull --output /manifest-bundle "$MANIFEST_REF"
+
+if [ -z "$(ls -A /manifest-bundle)" ]; then
+  echo "Manifest artifact $MANIFEST_REF contains no files"
+  exit 1
+fi
+
+echo "listing contents of the manifest artifact:"
+ls -la /manifest-bundle
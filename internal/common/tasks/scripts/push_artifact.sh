@@ -1,19 +1,51 @@
 #!/bin/sh
 set -ex
 
-if [ "$(params.export-format)" = "image" ]; then
-  file_extension=".raw"
-elif [ "$(params.export-format)" = "qcow2" ]; then
-  file_extension=".qcow2"
-else
-  file_extension="$(params.export-format)"
+# Media types and annotations are defined in internal/common/oci
+case "$(params.export-format)" in
+  image|raw)
+    file_extension=".raw"
+    media_type="application/vnd.redhat.automotive.image.raw.v1" ;;
+  qcow2)
+    file_extension=".qcow2"
+    media_type="application/vnd.redhat.automotive.image.qcow2.v1" ;;
+  simg)
+    file_extension=".simg"
+    media_type="application/vnd.redhat.automotive.image.simg.v1" ;;
+  aboot)
+    file_extension=".aboot"
+    media_type="application/vnd.redhat.automotive.image.aboot.v1" ;;
+  *)
+    file_extension=".$(params.export-format)"
+    media_type="application/vnd.oci.image.layer.v1.tar" ;;
+esac
+
+artifactName="$(params.artifact-name)"
+if [ -z "$artifactName" ]; then
+  artifactName="$DEFAULT_ARTIFACT_NAME"
 fi
+exportFile=${artifactName}${file_extension}
 
-exportFile=$(params.distro)-$(params.target)-$(params.export-format)${file_extension}
+set -- \
+  --annotation "automotive.sdv.cloud.redhat.com/distro=$(params.distro)" \
+  --annotation "automotive.sdv.cloud.redhat.com/target=$(params.target)" \
+  --annotation "automotive.sdv.cloud.redhat.com/export-format=$(params.export-format)" \
+  --annotation "org.opencontainers.image.created=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+if [ -n "$(params.arch)" ]; then
+  set -- "$@" --annotation "automotive.sdv.cloud.redhat.com/architecture=$(params.arch)"
+fi
+if [ -n "$(params.build-name)" ]; then
+  set -- "$@" --annotation "automotive.sdv.cloud.redhat.com/imagebuild-name=$(params.build-name)"
+fi
+if [ -n "$(params.git-ref)" ]; then
+  set -- "$@" --annotation "org.opencontainers.image.revision=$(params.git-ref)"
+fi
 
 echo "Pushing image to $(params.repository-url)"
 oras push --disable-path-validation \
+  --artifact-type "application/vnd.redhat.automotive.image.v1" \
+  "$@" \
   $(params.repository-url) \
-  $exportFile:application/vnd.oci.image.layer.v1.tar
+  "$exportFile:$media_type"
 
 echo "Image pushed successfully to registry"
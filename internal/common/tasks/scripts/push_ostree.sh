@@ -0,0 +1,69 @@
+#!/bin/sh
+set -e
+
+# the result is always written, as pipelines declare it whether or not the build has an ostree ref
+if [ -z "$OSTREE_REF" ]; then
+  echo "no ostree repository, nothing to push"
+  printf '' > /tekton/results/ostree-commit
+  exit 0
+fi
+
+ensure_rsync() {
+  if ! command -v rsync >/dev/null 2>&1; then
+    echo "rsync not found. Attempting to install..."
+    dnf -y install rsync openssh-clients || microdnf install -y rsync openssh-clients || yum -y install rsync openssh-clients || true
+  fi
+  if ! command -v rsync >/dev/null 2>&1; then
+    echo "rsync is required to reach the ostree repository"
+    exit 1
+  fi
+}
+
+setup_ssh() {
+  ssh_opts="-o BatchMode=yes -o UserKnownHostsFile=$HOME/.ssh/known_hosts"
+  mkdir -p "$HOME/.ssh"
+  chmod 700 "$HOME/.ssh"
+  if [ "$(workspaces.ostree-auth.bound)" = "true" ]; then
+    cp "$(workspaces.ostree-auth.path)/ssh-privatekey" "$HOME/.ssh/id_ostree"
+    chmod 600 "$HOME/.ssh/id_ostree"
+    ssh_opts="$ssh_opts -i $HOME/.ssh/id_ostree"
+    if [ -f "$(workspaces.ostree-auth.path)/known_hosts" ]; then
+      cp "$(workspaces.ostree-auth.path)/known_hosts" "$HOME/.ssh/known_hosts"
+      ssh_opts="$ssh_opts -o StrictHostKeyChecking=yes"
+    else
+      ssh_opts="$ssh_opts -o StrictHostKeyChecking=accept-new"
+    fi
+  fi
+  export RSYNC_RSH="ssh $ssh_opts"
+}
+
+repo=/output/ostree-repo
+commit=$(ostree rev-parse --repo="$repo" "$OSTREE_REF" 2>/dev/null || true)
+if [ -z "$commit" ]; then
+  echo "The build made no commit on $OSTREE_REF"
+  exit 1
+fi
+echo "commit of $OSTREE_REF: $commit"
+ostree log --repo="$repo" "$OSTREE_REF" 2>/dev/null | head -n 20 || true
+
+ensure_rsync
+setup_ssh
+
+remote="${OSTREE_REPOSITORY_URL%/}"
+cd "$repo"
+# objects go first so the ref never points at a commit the repository does not hold; those the remote
+# repository already has, such as the parent's, are skipped
+echo "pushing the objects of $commit to $remote"
+rsync -rlt --ignore-existing --stats objects/ "$remote/objects/"
+rsync -rltR "refs/heads/$OSTREE_REF" "$remote/"
+
+# the summary lists the refs for clients; it is regenerated on the repository's host when ostree is installed there
+host="${remote%%:*}"
+path="${remote#*:}"
+# shellcheck disable=SC2086
+if ! ssh $ssh_opts "$host" ostree summary --update --repo="$path"; then
+  echo "Warning: could not update the summary of $remote; clients pulling by ref are not affected"
+fi
+
+printf '%s' "$commit" > /tekton/results/ostree-commit
+echo "Pushed $commit to $OSTREE_REF in $remote"
@@ -0,0 +1,34 @@
+#!/bin/sh
+set -e
+
+# The build step leaves the uncompressed export behind /output/disk.img; scanning it avoids
+# unpacking the compressed artifact again.
+ARTIFACT=$(cat /tekton/results/artifact-filename 2>/dev/null || true)
+if [ -z "$ARTIFACT" ]; then
+  echo "No artifact was produced, nothing to scan"
+  exit 1
+fi
+
+TARGET=$(readlink -f /output/disk.img)
+REPORT_NAME="${ARTIFACT}.scan.json"
+REPORT="$(workspaces.shared-workspace.path)/${REPORT_NAME}"
+
+if [ -d "$TARGET" ]; then
+  echo "Scanning root file system ${TARGET}..."
+  trivy rootfs --scanners vuln --format json --output "$REPORT" "$TARGET"
+else
+  echo "Scanning disk image ${TARGET}..."
+  trivy vm --scanners vuln --format json --output "$REPORT" "$TARGET"
+fi
+
+SEVERITIES=$(trivy convert --format template \
+  --template '{{ range . }}{{ range .Vulnerabilities }}{{ .Severity }}{{ "\n" }}{{ end }}{{ end }}' \
+  "$REPORT")
+
+count() {
+  printf '%s\n' "$SEVERITIES" | grep -c "^$1\$" || true
+}
+
+SUMMARY="critical=$(count CRITICAL) high=$(count HIGH) medium=$(count MEDIUM) low=$(count LOW) report=${REPORT_NAME}"
+echo "Scan summary: ${SUMMARY}"
+echo "$SUMMARY" > /tekton/results/scan-summary
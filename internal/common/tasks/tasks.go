@@ -2,9 +2,12 @@ package tasks
 
 import (
 	_ "embed"
+	"slices"
+	"strings"
 	"time"
 
 	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifactname"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -14,8 +17,14 @@ import (
 
 const AutomotiveImageBuilder = "quay.io/centos-sig-automotive/automotive-image-builder:1.0.0"
 
+// DefaultScannerImage runs the post-build scan when the AutomotiveDev scan policy does not name an image
+const DefaultScannerImage = "docker.io/aquasec/trivy:0.57.1"
+
+// InputMountPath is where the build task mounts the input workspace, an ImageBuild's InputPVC
+const InputMountPath = "/workspace/input"
+
 // GeneratePushArtifactRegistryTask creates a Tekton Task for pushing artifacts to a registry
-func GeneratePushArtifactRegistryTask(namespace string) *tektonv1.Task {
+func GeneratePushArtifactRegistryTask(namespace string, buildConfig *automotivev1.BuildConfig) *tektonv1.Task {
 	return &tektonv1.Task{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "tekton.dev/v1",
@@ -56,6 +65,42 @@ func GeneratePushArtifactRegistryTask(namespace string) *tektonv1.Task {
 					Type:        tektonv1.ParamTypeString,
 					Description: "Name of the secret containing registry credentials",
 				},
+				{
+					Name:        "arch",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Architecture of the image, recorded as a manifest annotation",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "build-name",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Name of the build that produced the image, recorded as a manifest annotation",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "git-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Git ref the image was built from, recorded as a manifest annotation",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "artifact-name",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Name of the artifact without its extension; named after the distro and target when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
 			},
 			Workspaces: []tektonv1.WorkspaceDeclaration{
 				{
@@ -67,12 +112,16 @@ func GeneratePushArtifactRegistryTask(namespace string) *tektonv1.Task {
 			Steps: []tektonv1.Step{
 				{
 					Name:  "push-artifact",
-					Image: "ghcr.io/oras-project/oras:v1.2.0",
+					Image: Images(buildConfig).Oras,
 					Env: []corev1.EnvVar{
 						{
 							Name:  "DOCKER_CONFIG",
 							Value: "/tekton/home/.docker",
 						},
+						{
+							Name:  "DEFAULT_ARTIFACT_NAME",
+							Value: defaultArtifactName("$(params.arch)"),
+						},
 					},
 					Script:     PushArtifactScript,
 					WorkingDir: "/workspace/shared",
@@ -144,19 +193,118 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 				{
 					Name:        "compression",
 					Type:        tektonv1.ParamTypeString,
-					Description: "Compression algorithm for artifacts (lz4, gzip)",
+					Description: "Compression algorithm for artifacts (lz4, gzip, none)",
 					Default: &tektonv1.ParamValue{
 						Type:      tektonv1.ParamTypeString,
 						StringVal: "gzip",
 					},
 				},
+				{
+					Name:        "manifest-file",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Key of the main manifest in the manifest ConfigMap; the first *.aib.yml or *.mpp.yml file when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "manifest-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "OCI artifact to pull the manifests from instead of the manifest ConfigMap; the ConfigMap's manifests when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "build-info",
+					Type:        tektonv1.ParamTypeString,
+					Description: "os-release style provenance written to /etc/automotive-build-info in the image; no file when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "manifest-sha256",
+					Type:        tektonv1.ParamTypeString,
+					Description: "SHA-256 the files of the manifest ConfigMap must have; the build fails when it was modified since. Not checked when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "intermediate-registry",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Repository of the build in the in-cluster registry for intermediate container content, passed as the intermediate_registry define; none when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "artifact-name",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Name of the artifact without its extension, rendered from the build's artifact name template; named after the distro and target when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "build-name",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Name of the ImageBuild, recorded in the artifact's metadata file",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "ostree-repository-url",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Remote ostree repository the commit is pushed to with rsync, as user@host:/path; none when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "ostree-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "ostree ref the build commits to and pushes; no commit when empty",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "keep-workspace-on-failure",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Copy the build directory logs to the shared workspace when the build fails (true, false)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "false",
+					},
+				},
+				{
+					Name:        "workspace-keep",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Space separated shell patterns of workspace files kept besides the artifact when intermediates are pruned",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: workspaceKeep(buildConfig),
+					},
+				},
 				{
 					Name:        "automotive-image-builder",
 					Type:        tektonv1.ParamTypeString,
 					Description: "automotive-image-builder container image to use",
 					Default: &tektonv1.ParamValue{
 						Type:      tektonv1.ParamTypeString,
-						StringVal: AutomotiveImageBuilder,
+						StringVal: Images(buildConfig).Builder,
 					},
 				},
 			},
@@ -169,6 +317,22 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 					Name:        "artifact-filename",
 					Description: "artifact filename placed in the shared workspace",
 				},
+				{
+					Name:        "artifact-size",
+					Description: "size of the artifact in bytes",
+				},
+				{
+					Name:        "resource-usage",
+					Description: "Peak memory, CPU seconds and disk usage of the build step, as key=value pairs",
+				},
+				{
+					Name:        "workspace-usage",
+					Description: "Space the workspace holds after intermediates were pruned and the space reclaimed, as key=value pairs",
+				},
+				{
+					Name:        "ostree-commit",
+					Description: "Checksum of the commit pushed to the ostree ref",
+				},
 			},
 			Workspaces: []tektonv1.WorkspaceDeclaration{
 				{
@@ -181,17 +345,81 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 					Description: "Workspace for manifest configuration",
 					MountPath:   "/workspace/manifest-config",
 				},
+				{
+					Name:        "encryption-key",
+					Description: "Secret whose key entry is the passphrase the artifacts are encrypted with (optional)",
+					MountPath:   "/workspace/encryption-key",
+					ReadOnly:    true,
+					Optional:    true,
+				},
+				{
+					Name:        "ostree-auth",
+					Description: "SSH auth secret logging in to the ostree repository's host, with an optional known_hosts entry (optional)",
+					MountPath:   "/workspace/ostree-auth",
+					ReadOnly:    true,
+					Optional:    true,
+				},
+				{
+					Name:        "input",
+					Description: "Claim with input files staged for the build, which manifests reference below its mount path (optional)",
+					MountPath:   InputMountPath,
+					Optional:    true,
+				},
 			},
 			Steps: []tektonv1.Step{
 				{
-					Name:   "find-manifest-file",
-					Image:  "quay.io/konflux-ci/yq:latest",
+					Name:  "pull-manifests",
+					Image: Images(buildConfig).Oras,
+					Env: []corev1.EnvVar{
+						{
+							Name:  "MANIFEST_REF",
+							Value: "$(params.manifest-ref)",
+						},
+					},
+					Script:  PullManifestsScript,
+					EnvFrom: buildEnvFrom(envSecretRef),
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "manifest-bundle",
+							MountPath: "/manifest-bundle",
+						},
+					},
+				},
+				{
+					Name:  "find-manifest-file",
+					Image: Images(buildConfig).Yq,
+					Env: []corev1.EnvVar{
+						{
+							Name:  "BUILD_INFO",
+							Value: "$(params.build-info)",
+						},
+						{
+							Name:  "EXPECTED_MANIFEST_SHA256",
+							Value: "$(params.manifest-sha256)",
+						},
+					},
 					Script: FindManifestScript,
 					VolumeMounts: []corev1.VolumeMount{
 						{
 							Name:      "manifest-work",
 							MountPath: "/manifest-work",
 						},
+						{
+							Name:      "manifest-bundle",
+							MountPath: "/manifest-bundle",
+						},
+					},
+				},
+				{
+					Name:   "fetch-ostree-parent",
+					Image:  "$(params.automotive-image-builder)",
+					Env:    ostreeEnv(),
+					Script: FetchOSTreeParentScript,
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "output-dir",
+							MountPath: "/output",
+						},
 					},
 				},
 				{
@@ -206,6 +434,20 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 							Add: []corev1.Capability{},
 						},
 					},
+					Env: []corev1.EnvVar{
+						{
+							Name:  "INTERMEDIATE_REGISTRY",
+							Value: "$(params.intermediate-registry)",
+						},
+						{
+							Name:  "DEFAULT_ARTIFACT_NAME",
+							Value: defaultArtifactName("$(params.target-architecture)"),
+						},
+						{
+							Name:  "OSTREE_REF",
+							Value: "$(params.ostree-ref)",
+						},
+					},
 					Script:  BuildImageScript,
 					EnvFrom: buildEnvFrom(envSecretRef),
 					VolumeMounts: []corev1.VolumeMount{
@@ -239,6 +481,12 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 						EmptyDir: &corev1.EmptyDirVolumeSource{},
 					},
 				},
+				{
+					Name: "manifest-bundle",
+					VolumeSource: corev1.VolumeSource{
+						EmptyDir: &corev1.EmptyDirVolumeSource{},
+					},
+				},
 				{
 					Name: "build-dir",
 					VolumeSource: corev1.VolumeSource{
@@ -269,6 +517,49 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 		},
 	}
 
+	if buildConfig != nil && buildConfig.Scan != nil && buildConfig.Scan.Enabled {
+		scannerImage := buildConfig.Scan.Image
+		if scannerImage == "" {
+			scannerImage = DefaultScannerImage
+		}
+		task.Spec.Results = append(task.Spec.Results, tektonv1.TaskResult{
+			Name:        "scan-summary",
+			Description: "Vulnerability counts per severity and the report file name, as key=value pairs",
+		})
+		task.Spec.Steps = append(task.Spec.Steps, tektonv1.Step{
+			Name:   "scan-artifact",
+			Image:  scannerImage,
+			Script: ScanArtifactScript,
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "output-dir",
+					MountPath: "/output",
+				},
+			},
+		})
+	}
+
+	// The commit is pushed once the build and its scan succeeded
+	task.Spec.Steps = append(task.Spec.Steps, tektonv1.Step{
+		Name:   "push-ostree",
+		Image:  "$(params.automotive-image-builder)",
+		Env:    ostreeEnv(),
+		Script: PushOSTreeScript,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "output-dir",
+				MountPath: "/output",
+			},
+		},
+	})
+
+	// Pruning runs last, once nothing reads the intermediates anymore
+	task.Spec.Steps = append(task.Spec.Steps, tektonv1.Step{
+		Name:   "prune-workspace",
+		Image:  "$(params.automotive-image-builder)",
+		Script: PruneWorkspaceScript,
+	})
+
 	if buildConfig != nil && buildConfig.UseMemoryVolumes {
 		for i := range task.Spec.Volumes {
 			vol := &task.Spec.Volumes[i]
@@ -286,11 +577,66 @@ func GenerateBuildAutomotiveImageTask(namespace string, buildConfig *automotivev
 		}
 	}
 
+	if buildConfig != nil && buildConfig.Unprivileged {
+		makeUnprivileged(task)
+	}
+
 	return task
 }
 
+// UnprivilegedExportFormats are the export formats unprivileged builds support: the ones osbuild writes
+// without a loop device
+var UnprivilegedExportFormats = []string{"container", "ostree-commit", "rpmlist", "tar"}
+
+// makeUnprivileged turns the build step of task into a rootless one: it drops the privileged security context
+// and the /dev host path, and has the build script run osbuild in a user namespace
+func makeUnprivileged(task *tektonv1.Task) {
+	for i := range task.Spec.Steps {
+		step := &task.Spec.Steps[i]
+		if step.Name != "build-image" {
+			continue
+		}
+		step.SecurityContext = &corev1.SecurityContext{
+			Privileged:               ptr.To(false),
+			AllowPrivilegeEscalation: ptr.To(false),
+		}
+		step.VolumeMounts = slices.DeleteFunc(step.VolumeMounts, func(m corev1.VolumeMount) bool { return m.Name == "dev" })
+		step.Env = append(step.Env,
+			corev1.EnvVar{Name: "UNPRIVILEGED", Value: "true"},
+			corev1.EnvVar{Name: "UNPRIVILEGED_EXPORT_FORMATS", Value: strings.Join(UnprivilegedExportFormats, " ")},
+		)
+	}
+	task.Spec.Volumes = slices.DeleteFunc(task.Spec.Volumes, func(v corev1.Volume) bool { return v.Name == "dev" })
+}
+
+// ostreeEnv passes the ostree repository and ref of the build to the steps that fetch and push its commits
+func ostreeEnv() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  "OSTREE_REPOSITORY_URL",
+			Value: "$(params.ostree-repository-url)",
+		},
+		{
+			Name:  "OSTREE_REF",
+			Value: "$(params.ostree-ref)",
+		},
+	}
+}
+
+// defaultArtifactName names the artifact of runs that do not pass an artifact-name after the default template,
+// with references to the task's params for Tekton to substitute
+func defaultArtifactName(archParam string) string {
+	name, _ := artifactname.Render(artifactname.DefaultTemplate, artifactname.Fields{
+		Distro: "$(params.distro)",
+		Target: "$(params.target)",
+		Arch:   archParam,
+		Name:   "$(params.build-name)",
+	})
+	return name
+}
+
 // GenerateTektonPipeline creates a Tekton Pipeline for automotive building process
-func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
+func GenerateTektonPipeline(name, namespace string, buildConfig *automotivev1.BuildConfig) *tektonv1.Pipeline {
 	pipeline := &tektonv1.Pipeline{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "tekton.dev/v1",
@@ -357,7 +703,7 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 						Type:      tektonv1.ParamTypeString,
 						StringVal: "lz4",
 					},
-					Description: "Compression algorithm for artifacts (lz4, gzip)",
+					Description: "Compression algorithm for artifacts (lz4, gzip, none)",
 				},
 				{
 					Name:        "storage-class",
@@ -373,10 +719,69 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 					Type: tektonv1.ParamTypeString,
 					Default: &tektonv1.ParamValue{
 						Type:      tektonv1.ParamTypeString,
-						StringVal: AutomotiveImageBuilder,
+						StringVal: Images(buildConfig).Builder,
 					},
 					Description: "automotive-image-builder container image to use for building",
 				},
+				{
+					Name:        "manifest-file",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Key of the main manifest in the manifest ConfigMap (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "manifest-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "OCI artifact to pull the manifests from instead of the manifest ConfigMap (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "manifest-sha256",
+					Type:        tektonv1.ParamTypeString,
+					Description: "SHA-256 the files of the manifest ConfigMap must have (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "intermediate-registry",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Repository of the build in the in-cluster registry for intermediate container content (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "artifact-name",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Name of the artifact without its extension, e.g. cs9-qemu",
+				},
+				{
+					Name:        "ostree-repository-url",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Remote ostree repository to push the commit to, as user@host:/path (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
+				{
+					Name:        "ostree-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "ostree ref to commit to and push (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
 				{
 					Name:        "repository-url",
 					Type:        tektonv1.ParamTypeString,
@@ -395,10 +800,29 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 						StringVal: "",
 					},
 				},
+				{
+					Name:        "git-ref",
+					Type:        tektonv1.ParamTypeString,
+					Description: "Git ref the manifest was taken from, recorded on the pushed image (optional)",
+					Default: &tektonv1.ParamValue{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "",
+					},
+				},
 			},
 			Workspaces: []tektonv1.PipelineWorkspaceDeclaration{
 				{Name: "shared-workspace"},
 				{Name: "manifest-config-workspace"},
+				{Name: "encryption-key", Optional: true},
+				{Name: "ostree-auth", Optional: true},
+				{Name: "input", Optional: true},
+			},
+			Results: []tektonv1.PipelineResult{
+				{
+					Name:        "ostree-commit",
+					Description: "Checksum of the commit pushed to the ostree ref",
+					Value:       *tektonv1.NewStructuredValues("$(tasks.build-image.results.ostree-commit)"),
+				},
 			},
 			Tasks: []tektonv1.PipelineTask{
 				{
@@ -481,10 +905,70 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 								StringVal: "$(params.automotive-image-builder)",
 							},
 						},
+						{
+							Name: "manifest-file",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.manifest-file)",
+							},
+						},
+						{
+							Name: "manifest-ref",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.manifest-ref)",
+							},
+						},
+						{
+							Name: "manifest-sha256",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.manifest-sha256)",
+							},
+						},
+						{
+							Name: "intermediate-registry",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.intermediate-registry)",
+							},
+						},
+						{
+							Name: "artifact-name",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.artifact-name)",
+							},
+						},
+						{
+							Name: "ostree-repository-url",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.ostree-repository-url)",
+							},
+						},
+						{
+							Name: "ostree-ref",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.ostree-ref)",
+							},
+						},
+						{
+							// push-registry pushes the uncompressed export the build leaves next to the artifact
+							Name: "workspace-keep",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: strings.TrimSpace(workspaceKeep(buildConfig) + " $(params.artifact-name).*"),
+							},
+						},
 					},
 					Workspaces: []tektonv1.WorkspacePipelineTaskBinding{
 						{Name: "shared-workspace", Workspace: "shared-workspace"},
 						{Name: "manifest-config-workspace", Workspace: "manifest-config-workspace"},
+						{Name: "encryption-key", Workspace: "encryption-key"},
+						{Name: "ostree-auth", Workspace: "ostree-auth"},
+						{Name: "input", Workspace: "input"},
 					},
 					Timeout: &metav1.Duration{Duration: 1 * time.Hour},
 				},
@@ -554,6 +1038,34 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 								StringVal: "$(params.secret-ref)",
 							},
 						},
+						{
+							Name: "arch",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.arch)",
+							},
+						},
+						{
+							Name: "build-name",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(context.pipelineRun.name)",
+							},
+						},
+						{
+							Name: "git-ref",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.git-ref)",
+							},
+						},
+						{
+							Name: "artifact-name",
+							Value: tektonv1.ParamValue{
+								Type:      tektonv1.ParamTypeString,
+								StringVal: "$(params.artifact-name)",
+							},
+						},
 					},
 					Workspaces: []tektonv1.WorkspacePipelineTaskBinding{
 						{Name: "shared-workspace", Workspace: "shared-workspace"},
@@ -579,6 +1091,14 @@ func GenerateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
 	return pipeline
 }
 
+// workspaceKeep is the BuildConfig's keep-list as the workspace-keep param of the build task
+func workspaceKeep(buildConfig *automotivev1.BuildConfig) string {
+	if buildConfig == nil {
+		return ""
+	}
+	return strings.Join(buildConfig.WorkspaceKeep, " ")
+}
+
 func buildEnvFrom(envSecretRef string) []corev1.EnvFromSource {
 	if envSecretRef == "" {
 		return nil
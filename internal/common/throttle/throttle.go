@@ -0,0 +1,154 @@
+// Package throttle limits the bandwidth of streams with token buckets. A stream is limited by several buckets
+// at once, e.g. one of its own and one shared by every stream of a server, and moves at the pace of the
+// slowest of them.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minBurst is the least a bucket holds, so that slow limits still move data in chunks worth a syscall
+const minBurst = 32 << 10
+
+// NewLimiter returns a token bucket refilled with bytesPerSecond, nil for no limit. It holds a tenth of
+// a second's worth of bytes, so that streams do not exceed the limit by more than that in a burst.
+func NewLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(max(bytesPerSecond/10, minBurst)))
+}
+
+// ParseRate parses a rate in bytes per second like curl's --limit-rate: a number of bytes, optionally
+// followed by K, M or G for KiB, MiB or GiB, e.g. "512K" or "1.5M". Empty and zero mean no limit.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	number, multiplier := s, 1.0
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		number = s[:len(s)-1]
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid rate %q: want bytes per second, e.g. 512K, 10M or 1G", s)
+	}
+	return int64(v * multiplier), nil
+}
+
+// Observer is told how many bytes a stream moved and how long it waited for the buckets before moving them
+type Observer func(n int, waited time.Duration)
+
+type stream struct {
+	ctx      context.Context
+	limiters []*rate.Limiter
+	observe  Observer
+	chunk    int
+}
+
+func newStream(ctx context.Context, observe Observer, limiters []*rate.Limiter) *stream {
+	s := &stream{ctx: ctx, observe: observe}
+	for _, l := range limiters {
+		if l == nil {
+			continue
+		}
+		s.limiters = append(s.limiters, l)
+		if s.chunk == 0 || l.Burst() < s.chunk {
+			s.chunk = l.Burst()
+		}
+	}
+	return s
+}
+
+// wait takes n bytes from every bucket
+func (s *stream) wait(n int) error {
+	start := time.Now()
+	for _, l := range s.limiters {
+		if err := l.WaitN(s.ctx, n); err != nil {
+			return err
+		}
+	}
+	if s.observe != nil {
+		s.observe(n, time.Since(start))
+	}
+	return nil
+}
+
+type reader struct {
+	*stream
+	r io.Reader
+}
+
+// Reader returns r limited to the rate of every non-nil limiter until ctx is done. observe, if set, is called
+// after every read.
+func Reader(ctx context.Context, r io.Reader, observe Observer, limiters ...*rate.Limiter) io.Reader {
+	s := newStream(ctx, observe, limiters)
+	if len(s.limiters) == 0 && observe == nil {
+		return r
+	}
+	return &reader{stream: s, r: r}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.chunk > 0 && len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.wait(n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+type writer struct {
+	*stream
+	w io.Writer
+}
+
+// Writer returns w limited to the rate of every non-nil limiter until ctx is done. observe, if set, is called
+// before every write.
+func Writer(ctx context.Context, w io.Writer, observe Observer, limiters ...*rate.Limiter) io.Writer {
+	s := newStream(ctx, observe, limiters)
+	if len(s.limiters) == 0 && observe == nil {
+		return w
+	}
+	return &writer{stream: s, w: w}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if w.chunk > 0 && len(chunk) > w.chunk {
+			chunk = chunk[:w.chunk]
+		}
+		if err := w.wait(len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
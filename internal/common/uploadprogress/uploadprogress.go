@@ -0,0 +1,76 @@
+// Package uploadprogress records how far the uploads of a build's local files have come. The build API
+// measures the files stored in the build's workspace as they arrive and records them in an annotation of its
+// ImageBuild, which the controller turns into the status message of the Uploading phase.
+package uploadprogress
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Annotation holds the progress of a build's uploads as JSON
+const Annotation = "automotive.sdv.cloud.redhat.com/upload-progress"
+
+// Progress is what a build's workspace holds of its uploads
+type Progress struct {
+	// ReceivedBytes and ReceivedFiles count the files stored so far, including partly uploaded ones
+	ReceivedBytes int64 `json:"receivedBytes"`
+	ReceivedFiles int   `json:"receivedFiles"`
+	// TotalBytes and TotalFiles are what the client announced it uploads, 0 if it did not
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	TotalFiles int   `json:"totalFiles,omitempty"`
+	// UpdatedAt is when the workspace last received data
+	UpdatedAt time.Time `json:"updatedAt"`
+	// StartedAt is when the client started uploading and CompletedAt when it completed the uploads
+	StartedAt   time.Time `json:"startedAt,omitzero"`
+	CompletedAt time.Time `json:"completedAt,omitzero"`
+}
+
+// Annotate records p in the annotations of a build
+func (p Progress) Annotate(annotations map[string]string) {
+	// a struct of numbers and a time always marshals
+	data, _ := json.Marshal(p)
+	annotations[Annotation] = string(data)
+}
+
+// FromAnnotations returns the progress recorded in the annotations of a build, if any
+func FromAnnotations(annotations map[string]string) (Progress, bool) {
+	var p Progress
+	value, ok := annotations[Annotation]
+	if !ok || json.Unmarshal([]byte(value), &p) != nil {
+		return Progress{}, false
+	}
+	return p, true
+}
+
+// Percent is the share of the announced bytes received so far, or -1 if the client announced none
+func (p Progress) Percent() int {
+	if p.TotalBytes <= 0 {
+		return -1
+	}
+	return int(min(p.ReceivedBytes*100/p.TotalBytes, 100))
+}
+
+// String describes the progress for status messages, e.g. "1.5 GiB of 3.0 GiB (50%), 2 of 5 files"
+func (p Progress) String() string {
+	if p.TotalBytes <= 0 {
+		return fmt.Sprintf("%s, %d files", formatBytes(p.ReceivedBytes), p.ReceivedFiles)
+	}
+	return fmt.Sprintf("%s of %s (%d%%), %d of %d files", formatBytes(p.ReceivedBytes), formatBytes(p.TotalBytes),
+		p.Percent(), p.ReceivedFiles, p.TotalFiles)
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
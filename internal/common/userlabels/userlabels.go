@@ -0,0 +1,83 @@
+// Package userlabels handles the free-form labels users attach to builds. They are stored on the
+// ImageBuild next to the operator's own labels and copied to the resources created for the build.
+package userlabels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ReservedPrefixes are label key prefixes owned by the operator and its dependencies; users may not set them
+var ReservedPrefixes = []string{
+	"app.kubernetes.io/",
+	"automotive.sdv.cloud.redhat.com/",
+	"tekton.dev/",
+}
+
+// IsReserved reports whether key belongs to the operator rather than the user
+func IsReserved(key string) bool {
+	for _, p := range ReservedPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that every key and value is a valid Kubernetes label and that no key is reserved
+func Validate(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if IsReserved(k) {
+			return fmt.Errorf("label %q uses a reserved prefix", k)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(labels[k]); len(errs) > 0 {
+			return fmt.Errorf("invalid value for label %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// Filter returns the user labels among labels, or nil if there are none
+func Filter(labels map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range labels {
+		if IsReserved(k) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Apply copies the user labels of from into to, never overwriting a key to already has
+func Apply(to, from map[string]string) {
+	for k, v := range Filter(from) {
+		if _, ok := to[k]; !ok {
+			to[k] = v
+		}
+	}
+}
+
+// Parse reads a KEY=VALUE pair as used by label query parameters and command-line flags
+func Parse(s string) (string, string, error) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return "", "", fmt.Errorf("invalid label %q: expected KEY=VALUE", s)
+	}
+	return strings.TrimSpace(k), strings.TrimSpace(v), nil
+}
@@ -0,0 +1,38 @@
+package automotivedev
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	securityv1 "github.com/openshift/api/security/v1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+func TestAutomotiveDev(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AutomotiveDev Controller Suite")
+}
+
+// newTestReconciler returns a reconciler whose client is a fake holding objs
+func newTestReconciler(objs ...client.Object) *AutomotiveDevReconciler {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(automotivev1.AddToScheme(scheme))
+	utilruntime.Must(securityv1.AddToScheme(scheme))
+	utilruntime.Must(tektonv1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&automotivev1.AutomotiveDev{}).
+		Build()
+	return &AutomotiveDevReconciler{Client: c, Scheme: scheme, Log: logr.Discard(), Ready: make(chan struct{})}
+}
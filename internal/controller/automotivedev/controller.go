@@ -8,14 +8,21 @@ import (
 
 	"github.com/go-logr/logr"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
-	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/buildscc"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/features"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/pkg/tektongen"
 )
 
 // AutomotiveDevReconciler reconciles a AutomotiveDev object
@@ -24,10 +31,15 @@ type AutomotiveDevReconciler struct {
 	Scheme *runtime.Scheme
 	Log    logr.Logger
 	Ready  chan struct{}
+	// Version is the operator version recorded in the AutomotiveDev status
+	Version string
 }
 
 const (
 	TektonResourcesNamespace = "automotive-dev-operator-system"
+
+	// pipelineServiceAccountName is the service account OpenShift Pipelines runs PipelineRuns as by default
+	pipelineServiceAccountName = "pipeline"
 )
 
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=automotivedevs,verbs=get;list;watch;create;update;patch;delete
@@ -55,87 +67,183 @@ func (r *AutomotiveDevReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	log.Info("AutomotiveDev fetched successfully", "name", av.Name)
+	if unknown := features.Unknown(av); len(unknown) > 0 {
+		log.Info("Ignoring unknown feature gates", "gates", unknown, "known", features.Known)
+	}
 
-	tasks := generateTektonTasks(TektonResourcesNamespace, av.Spec.BuildConfig)
-	for _, task := range tasks {
+	result := r.reconcileTektonResources(ctx, av)
+	result.monitoringErr = r.reconcileMonitoring(ctx, av)
+	result.prePull, result.prePullErr = r.reconcilePrePull(ctx, av)
+	result.registry, result.registryErr = r.reconcileRegistry(ctx, av)
+	if err := r.updateStatus(ctx, av, result); err != nil {
+		if result.err() != nil {
+			log.Error(err, "Failed to update AutomotiveDev status")
+			return ctrl.Result{}, result.err()
+		}
+		return ctrl.Result{}, err
+	}
+	if err := result.err(); err != nil {
+		return ctrl.Result{}, err
+	}
+	// a missing Prometheus Operator is reported in the status; retrying will not install it
+	if err := result.monitoringErr; err != nil && !meta.IsNoMatchError(err) {
+		return ctrl.Result{}, err
+	}
+	if err := result.prePullErr; err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := result.registryErr; err != nil {
+		return ctrl.Result{}, err
+	}
+
+	select {
+	case <-r.Ready:
+	default:
+		close(r.Ready)
+	}
+
+	log.Info("Successfully reconciled ")
+	return ctrl.Result{}, nil
+}
+
+// reconcileTektonResources creates or updates the Tekton tasks and pipeline the AutomotiveDev's BuildConfig
+// describes. The pipeline is reconciled even if a task fails so the status reports each on its own.
+func (r *AutomotiveDevReconciler) reconcileTektonResources(ctx context.Context, av *automotivev1.AutomotiveDev) *tektonResult {
+	log := r.Log.WithValues("automotivedev", client.ObjectKeyFromObject(av))
+	result := &tektonResult{}
+
+	opts := tektongen.Options{Namespace: TektonResourcesNamespace, BuildConfig: av.Spec.BuildConfig}
+	// tasks running images the BuildConfig refuses are worse than the tasks installed before
+	var taskList []*tektonv1.Task
+	if err := tektongen.Validate(opts); err != nil {
+		log.Error(err, "Not installing Tekton tasks")
+		result.tasksErr = err
+	} else {
+		taskList = tektongen.Tasks(opts)
+	}
+	for _, task := range taskList {
 		task.Labels["automotive.sdv.cloud.redhat.com/managed-by"] = av.Name
 
 		if err := controllerutil.SetControllerReference(av, task, r.Scheme); err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to set controller reference: %w", err)
+			result.tasksErr = fmt.Errorf("failed to set controller reference: %w", err)
+			break
 		}
 
-		if err := r.createOrUpdateTask(ctx, task); err != nil {
+		op, err := r.createOrUpdateTask(ctx, task)
+		if err != nil {
 			log.Error(err, "Failed to create/update Task", "task", task.Name)
-			return ctrl.Result{}, err
+			result.tasksErr = fmt.Errorf("task %s: %w", task.Name, err)
+			break
 		}
-
-		log.Info("Task created successfully", "name", task.Name)
+		if op != controllerutil.OperationResultNone {
+			log.Info("Task reconciled", "name", task.Name, "operation", op)
+		}
+		result.resources = append(result.resources, managedResource("Task", task.Name, task.Spec))
 	}
 
-	pipeline := generateTektonPipeline("automotive-build-pipeline", TektonResourcesNamespace)
+	pipeline := tektongen.Pipeline(tektongen.PipelineOptions{Options: opts})
 
 	pipeline.Labels["automotive.sdv.cloud.redhat.com/managed-by"] = av.Name
 
 	if err := controllerutil.SetControllerReference(av, pipeline, r.Scheme); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to set controller reference: %w", err)
+		result.pipelineErr = fmt.Errorf("failed to set controller reference: %w", err)
+		return result
 	}
 
-	if err := r.createOrUpdatePipeline(ctx, pipeline); err != nil {
+	op, err := r.createOrUpdatePipeline(ctx, pipeline)
+	if err != nil {
 		log.Error(err, "Failed to create/update Pipeline")
-		return ctrl.Result{}, err
+		result.pipelineErr = fmt.Errorf("pipeline %s: %w", pipeline.Name, err)
+		return result
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("Pipeline reconciled", "name", pipeline.Name, "operation", op)
 	}
+	result.resources = append(result.resources, managedResource("Pipeline", pipeline.Name, pipeline.Spec))
 
-	select {
-	case <-r.Ready:
-	default:
-		close(r.Ready)
+	// PipelineRuns of the Pipeline that name no service account run as the one OpenShift Pipelines creates
+	// in every namespace; clusters without SCCs admit its pods as they are
+	if err := r.bindPipelineServiceAccount(ctx); err != nil && !meta.IsNoMatchError(err) {
+		log.Error(err, "Failed to allow the pipeline service account to use the build SCC")
+		result.pipelineErr = err
 	}
+	return result
+}
 
-	log.Info("Successfully reconciled ")
-	return ctrl.Result{}, nil
+// bindPipelineServiceAccount allows the default pipeline service account of the Tekton resources namespace to
+// use the build SCC
+func (r *AutomotiveDevReconciler) bindPipelineServiceAccount(ctx context.Context) error {
+	if err := buildscc.Ensure(ctx, r.Client); err != nil {
+		return err
+	}
+	return buildscc.Bind(ctx, r.Client, TektonResourcesNamespace, buildscc.ClusterRoleName, pipelineServiceAccountName)
 }
 
-func (r *AutomotiveDevReconciler) createOrUpdatePipeline(ctx context.Context, pipeline *tektonv1.Pipeline) error {
+func (r *AutomotiveDevReconciler) createOrUpdatePipeline(ctx context.Context, pipeline *tektonv1.Pipeline) (controllerutil.OperationResult, error) {
+	// written as Tekton's webhook would store it, so it compares equal to the existing Pipeline
+	pipeline.SetDefaults(ctx)
 	existingPipeline := &tektonv1.Pipeline{}
 	err := r.Get(ctx, client.ObjectKey{Name: pipeline.Name, Namespace: pipeline.Namespace}, existingPipeline)
 	if err != nil {
 		if !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to get Pipeline: %w", err)
+			return controllerutil.OperationResultNone, fmt.Errorf("failed to get Pipeline: %w", err)
 		}
-		return r.Create(ctx, pipeline)
+		return controllerutil.OperationResultCreated, r.Create(ctx, pipeline)
 	}
 
+	if !tektonNeedsUpdate(pipeline, existingPipeline, pipeline.Spec, existingPipeline.Spec) {
+		return controllerutil.OperationResultNone, nil
+	}
 	pipeline.ResourceVersion = existingPipeline.ResourceVersion
-	return r.Update(ctx, pipeline)
+	return controllerutil.OperationResultUpdated, r.Update(ctx, pipeline)
 }
 
-func (r *AutomotiveDevReconciler) createOrUpdateTask(ctx context.Context, task *tektonv1.Task) error {
+func (r *AutomotiveDevReconciler) createOrUpdateTask(ctx context.Context, task *tektonv1.Task) (controllerutil.OperationResult, error) {
+	// written as Tekton's webhook would store it, so it compares equal to the existing Task
+	task.SetDefaults(ctx)
 	existingTask := &tektonv1.Task{}
 	err := r.Get(ctx, client.ObjectKey{Name: task.Name, Namespace: task.Namespace}, existingTask)
 	if err != nil {
 		if !errors.IsNotFound(err) {
-			return fmt.Errorf("failed to get Task: %w", err)
+			return controllerutil.OperationResultNone, fmt.Errorf("failed to get Task: %w", err)
 		}
-		return r.Create(ctx, task)
+		return controllerutil.OperationResultCreated, r.Create(ctx, task)
 	}
 
+	if !tektonNeedsUpdate(task, existingTask, task.Spec, existingTask.Spec) {
+		return controllerutil.OperationResultNone, nil
+	}
 	task.ResourceVersion = existingTask.ResourceVersion
-	return r.Update(ctx, task)
+	return controllerutil.OperationResultUpdated, r.Update(ctx, task)
 }
 
-func (r *AutomotiveDevReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&automotivev1.AutomotiveDev{}).
-		Complete(r)
+// needsUpdate reports whether an existing object differs from the desired one in its spec, labels,
+// annotations or owner references. Fields left unset in desired are ignored, so values the API server
+// defaults do not count as a difference.
+func needsUpdate(desired, existing client.Object, desiredSpec, existingSpec any) bool {
+	return !equality.Semantic.DeepDerivative(desiredSpec, existingSpec) || metadataChanged(desired, existing)
 }
 
-func generateTektonTasks(namespace string, buildConfig *automotivev1.BuildConfig) []*tektonv1.Task {
-	return []*tektonv1.Task{
-		tasks.GenerateBuildAutomotiveImageTask(namespace, buildConfig, ""),
-		tasks.GeneratePushArtifactRegistryTask(namespace),
-	}
+// tektonNeedsUpdate is needsUpdate for Tasks and Pipelines, whose desired spec is already defaulted the way
+// Tekton's webhook defaults it. Their spec has to match exactly, so a cleared field or a removed step counts.
+func tektonNeedsUpdate(desired, existing client.Object, desiredSpec, existingSpec any) bool {
+	return !equality.Semantic.DeepEqual(desiredSpec, existingSpec) || metadataChanged(desired, existing)
+}
+
+// metadataChanged reports whether desired sets labels or annotations existing lacks, or other owners
+func metadataChanged(desired, existing client.Object) bool {
+	return !equality.Semantic.DeepDerivative(desired.GetLabels(), existing.GetLabels()) ||
+		!equality.Semantic.DeepDerivative(desired.GetAnnotations(), existing.GetAnnotations()) ||
+		!equality.Semantic.DeepEqual(desired.GetOwnerReferences(), existing.GetOwnerReferences())
 }
 
-func generateTektonPipeline(name, namespace string) *tektonv1.Pipeline {
-	return tasks.GenerateTektonPipeline(name, namespace)
+func (r *AutomotiveDevReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// status updates, including the discovery controller's, do not change what the tasks look like
+		For(&automotivev1.AutomotiveDev{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// the pre-pull DaemonSet's status tracks how far its images are pulled
+		Owns(&appsv1.DaemonSet{}).
+		// and the intermediate registry's Deployment whether it is available
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
 }
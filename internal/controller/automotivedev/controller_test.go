@@ -0,0 +1,193 @@
+package automotivedev
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/intermediateregistry"
+)
+
+var _ = Describe("Tekton resources", func() {
+	ctx := context.Background()
+
+	var (
+		r  *AutomotiveDevReconciler
+		av *automotivev1.AutomotiveDev
+	)
+
+	BeforeEach(func() {
+		av = &automotivev1.AutomotiveDev{
+			ObjectMeta: metav1.ObjectMeta{Name: "automotive-dev", Namespace: TektonResourcesNamespace, UID: "av-uid"},
+			Spec:       automotivev1.AutomotiveDevSpec{BuildConfig: &automotivev1.BuildConfig{}},
+		}
+		r = newTestReconciler(av)
+	})
+
+	// reconcile installs the Tekton resources for the current spec and returns the build Task
+	reconcile := func() *tektonv1.Task {
+		result := r.reconcileTektonResources(ctx, av)
+		Expect(result.tasksErr).NotTo(HaveOccurred())
+		task := &tektonv1.Task{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "build-automotive-image", Namespace: TektonResourcesNamespace}, task)).
+			To(Succeed())
+		return task
+	}
+	volume := func(task *tektonv1.Task, name string) corev1.Volume {
+		for _, v := range task.Spec.Volumes {
+			if v.Name == name {
+				return v
+			}
+		}
+		Fail("task has no volume " + name)
+		return corev1.Volume{}
+	}
+	stepNames := func(task *tektonv1.Task) []string {
+		var names []string
+		for _, s := range task.Spec.Steps {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	It("should drop memory-backed volumes once UseMemoryVolumes is turned off", func() {
+		av.Spec.BuildConfig.UseMemoryVolumes = true
+		av.Spec.BuildConfig.MemoryVolumeSize = "2Gi"
+		task := reconcile()
+		Expect(volume(task, "build-dir").EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+		Expect(volume(task, "build-dir").EmptyDir.SizeLimit).NotTo(BeNil())
+
+		av.Spec.BuildConfig.UseMemoryVolumes = false
+		av.Spec.BuildConfig.MemoryVolumeSize = ""
+		task = reconcile()
+		Expect(volume(task, "build-dir").EmptyDir.Medium).To(BeEmpty())
+		Expect(volume(task, "build-dir").EmptyDir.SizeLimit).To(BeNil())
+		Expect(volume(task, "run-dir").EmptyDir.Medium).To(BeEmpty())
+	})
+
+	It("should remove the scan step once scanning is disabled", func() {
+		av.Spec.BuildConfig.Scan = &automotivev1.ScanPolicy{Enabled: true}
+		Expect(stepNames(reconcile())).To(ContainElement("scan-artifact"))
+
+		av.Spec.BuildConfig.Scan.Enabled = false
+		task := reconcile()
+		Expect(stepNames(task)).NotTo(ContainElement("scan-artifact"))
+		for _, res := range task.Spec.Results {
+			Expect(res.Name).NotTo(Equal("scan-summary"))
+		}
+	})
+
+	It("should leave an unchanged Task alone", func() {
+		before := reconcile()
+		after := reconcile()
+		Expect(after.ResourceVersion).To(Equal(before.ResourceVersion))
+	})
+})
+
+// the fake client does not default objects the way the API server does, so these tests add the defaults
+// to the stored objects themselves
+var _ = Describe("Objects the API server defaults", func() {
+	ctx := context.Background()
+
+	var (
+		r  *AutomotiveDevReconciler
+		av *automotivev1.AutomotiveDev
+	)
+
+	BeforeEach(func() {
+		av = &automotivev1.AutomotiveDev{
+			ObjectMeta: metav1.ObjectMeta{Name: "automotive-dev", Namespace: TektonResourcesNamespace, UID: "av-uid"},
+			Spec: automotivev1.AutomotiveDevSpec{BuildConfig: &automotivev1.BuildConfig{
+				PrePull:              &automotivev1.PrePullPolicy{Enabled: true},
+				IntermediateRegistry: &automotivev1.IntermediateRegistry{Enabled: true},
+			}},
+		}
+		r = newTestReconciler(av)
+	})
+
+	// defaultPodTemplate fills in what the API server defaults in a pod template
+	defaultPodTemplate := func(spec *corev1.PodSpec) {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+		spec.DNSPolicy = corev1.DNSClusterFirst
+		spec.SchedulerName = corev1.DefaultSchedulerName
+		for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+			for i := range containers {
+				containers[i].TerminationMessagePath = corev1.TerminationMessagePathDefault
+				containers[i].TerminationMessagePolicy = corev1.TerminationMessageReadFile
+				containers[i].ImagePullPolicy = corev1.PullIfNotPresent
+			}
+		}
+	}
+
+	It("should leave a defaulted pre-pull DaemonSet alone and report its progress", func() {
+		_, err := r.reconcilePrePull(ctx, av)
+		Expect(err).NotTo(HaveOccurred())
+
+		ds := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: prePullDaemonSetName, Namespace: TektonResourcesNamespace}, ds)).To(Succeed())
+		ds.Generation = 1
+		ds.Spec.RevisionHistoryLimit = ptr.To[int32](10)
+		ds.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType}
+		defaultPodTemplate(&ds.Spec.Template.Spec)
+		Expect(r.Update(ctx, ds)).To(Succeed())
+		ds.Status = appsv1.DaemonSetStatus{
+			ObservedGeneration:     1,
+			DesiredNumberScheduled: 3,
+			UpdatedNumberScheduled: 3,
+			NumberReady:            2,
+		}
+		Expect(r.Status().Update(ctx, ds)).To(Succeed())
+
+		state, err := r.reconcilePrePull(ctx, av)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state.rolledOut).To(BeTrue())
+		Expect(state.status.DesiredNodes).To(BeEquivalentTo(3))
+		Expect(state.status.ReadyNodes).To(BeEquivalentTo(2))
+		after := &appsv1.DaemonSet{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(ds), after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(ds.ResourceVersion))
+	})
+
+	It("should leave a defaulted registry Service and Deployment alone", func() {
+		_, err := r.reconcileRegistry(ctx, av)
+		Expect(err).NotTo(HaveOccurred())
+
+		key := client.ObjectKey{Name: intermediateregistry.Name, Namespace: TektonResourcesNamespace}
+		svc := &corev1.Service{}
+		Expect(r.Get(ctx, key, svc)).To(Succeed())
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.ClusterIP = "172.30.0.10"
+		svc.Spec.ClusterIPs = []string{"172.30.0.10"}
+		svc.Spec.SessionAffinity = corev1.ServiceAffinityNone
+		svc.Spec.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol}
+		Expect(r.Update(ctx, svc)).To(Succeed())
+
+		deploy := &appsv1.Deployment{}
+		Expect(r.Get(ctx, key, deploy)).To(Succeed())
+		deploy.Spec.Replicas = ptr.To[int32](1)
+		deploy.Spec.RevisionHistoryLimit = ptr.To[int32](10)
+		deploy.Spec.ProgressDeadlineSeconds = ptr.To[int32](600)
+		defaultPodTemplate(&deploy.Spec.Template.Spec)
+		Expect(r.Update(ctx, deploy)).To(Succeed())
+		deploy.Status = appsv1.DeploymentStatus{ReadyReplicas: 1, AvailableReplicas: 1}
+		Expect(r.Status().Update(ctx, deploy)).To(Succeed())
+
+		got, err := r.reconcileRegistry(ctx, av)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Status.AvailableReplicas).To(BeEquivalentTo(1))
+		afterSvc := &corev1.Service{}
+		Expect(r.Get(ctx, key, afterSvc)).To(Succeed())
+		Expect(afterSvc.ResourceVersion).To(Equal(svc.ResourceVersion))
+		afterDeploy := &appsv1.Deployment{}
+		Expect(r.Get(ctx, key, afterDeploy)).To(Succeed())
+		Expect(afterDeploy.ResourceVersion).To(Equal(deploy.ResourceVersion))
+	})
+})
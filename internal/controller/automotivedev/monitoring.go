@@ -0,0 +1,409 @@
+package automotivedev
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	prometheusRuleName     = "automotive-dev-builds"
+	dashboardConfigMapName = "automotive-dev-dashboard"
+	dashboardFileName      = "automotive-dev-builds.json"
+
+	defaultBuildFailureRatePercent = 25
+	defaultUploadingStuckMinutes   = 60
+	defaultPVCPendingMinutes       = 5
+)
+
+// prometheusRuleGVK is the Prometheus Operator's PrometheusRule, used unstructured so the operator does not
+// depend on its API and runs on clusters without it
+var prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+
+// monitoringSettings are the thresholds of a MonitoringConfig with the defaults of unset fields filled in
+type monitoringSettings struct {
+	failureRatePercent int32
+	uploadingMinutes   int32
+	pvcPendingMinutes  int32
+	ruleLabels         map[string]string
+	dashboardLabels    map[string]string
+}
+
+func monitoringSettingsFor(cfg *automotivev1.MonitoringConfig) monitoringSettings {
+	s := monitoringSettings{
+		failureRatePercent: defaultBuildFailureRatePercent,
+		uploadingMinutes:   defaultUploadingStuckMinutes,
+		pvcPendingMinutes:  defaultPVCPendingMinutes,
+		ruleLabels:         cfg.RuleLabels,
+		dashboardLabels:    cfg.DashboardLabels,
+	}
+	if cfg.BuildFailureRatePercent > 0 {
+		s.failureRatePercent = cfg.BuildFailureRatePercent
+	}
+	if cfg.UploadingStuckMinutes > 0 {
+		s.uploadingMinutes = cfg.UploadingStuckMinutes
+	}
+	if cfg.PVCPendingMinutes > 0 {
+		s.pvcPendingMinutes = cfg.PVCPendingMinutes
+	}
+	if len(s.dashboardLabels) == 0 {
+		s.dashboardLabels = map[string]string{"grafana_dashboard": "1"}
+	}
+	return s
+}
+
+// monitoringEnabled reports whether the AutomotiveDev asks for its monitoring resources
+func monitoringEnabled(av *automotivev1.AutomotiveDev) bool {
+	return av.Spec.Monitoring != nil && av.Spec.Monitoring.Enabled
+}
+
+// reconcileMonitoring installs the PrometheusRule and dashboard ConfigMap of the AutomotiveDev's monitoring
+// when it is enabled and removes them when it is not
+func (r *AutomotiveDevReconciler) reconcileMonitoring(ctx context.Context, av *automotivev1.AutomotiveDev) error {
+	if !monitoringEnabled(av) {
+		return r.deleteMonitoring(ctx, av)
+	}
+	settings := monitoringSettingsFor(av.Spec.Monitoring)
+
+	rule, err := generatePrometheusRule(TektonResourcesNamespace, settings)
+	if err != nil {
+		return err
+	}
+	if err := r.applyMonitoringObject(ctx, av, rule, rule.Object["spec"], func(existing client.Object) any {
+		return existing.(*unstructured.Unstructured).Object["spec"]
+	}); err != nil {
+		return fmt.Errorf("prometheusrule %s: %w", rule.GetName(), err)
+	}
+
+	dashboard, err := generateDashboardConfigMap(TektonResourcesNamespace, settings)
+	if err != nil {
+		return err
+	}
+	if err := r.applyMonitoringObject(ctx, av, dashboard, dashboard.Data, func(existing client.Object) any {
+		return existing.(*corev1.ConfigMap).Data
+	}); err != nil {
+		return fmt.Errorf("configmap %s: %w", dashboard.Name, err)
+	}
+	return nil
+}
+
+// applyMonitoringObject creates obj, owned by the AutomotiveDev, or updates it when spec, the part of it the
+// operator generates, changed
+func (r *AutomotiveDevReconciler) applyMonitoringObject(ctx context.Context, av *automotivev1.AutomotiveDev,
+	obj client.Object, spec any, specOf func(client.Object) any) error {
+	log := r.Log.WithValues("automotivedev", client.ObjectKeyFromObject(av))
+
+	labels := obj.GetLabels()
+	labels["automotive.sdv.cloud.redhat.com/managed-by"] = av.Name
+	obj.SetLabels(labels)
+	if err := controllerutil.SetControllerReference(av, obj, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set controller reference: %w", err)
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if err := r.Create(ctx, obj); err != nil {
+			return err
+		}
+		log.Info("Monitoring resource created", "kind", obj.GetObjectKind().GroupVersionKind().Kind, "name", obj.GetName())
+		return nil
+	}
+	if !needsUpdate(obj, existing, spec, specOf(existing)) {
+		return nil
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return r.Update(ctx, obj)
+}
+
+// deleteMonitoring removes the monitoring resources of the AutomotiveDev, if it installed them
+func (r *AutomotiveDevReconciler) deleteMonitoring(ctx context.Context, av *automotivev1.AutomotiveDev) error {
+	rule := &unstructured.Unstructured{}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetName(prometheusRuleName)
+	dashboard := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: dashboardConfigMapName}}
+
+	for _, obj := range []client.Object{rule, dashboard} {
+		key := client.ObjectKey{Namespace: TektonResourcesNamespace, Name: obj.GetName()}
+		if err := r.Get(ctx, key, obj); err != nil {
+			// without the PrometheusRule CRD there is no rule to delete
+			if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(obj, av) {
+			continue
+		}
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s: %w", key.Name, err)
+		}
+	}
+	return nil
+}
+
+// Rules of the PrometheusRule, in the Prometheus Operator's schema
+type ruleGroup struct {
+	Name  string `json:"name"`
+	Rules []rule `json:"rules"`
+}
+
+type rule struct {
+	Alert       string            `json:"alert"`
+	Expr        string            `json:"expr"`
+	For         string            `json:"for"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// generatePrometheusRule alerts on a high share of failed builds, builds stuck waiting for their uploads
+// and build workspaces whose PVC is not bound, with the thresholds of the settings
+func generatePrometheusRule(namespace string, s monitoringSettings) (*unstructured.Unstructured, error) {
+	groups := []ruleGroup{{
+		Name: "automotive-dev-builds",
+		Rules: []rule{
+			{
+				Alert: "AutomotiveBuildFailureRateHigh",
+				Expr: fmt.Sprintf(`100 * sum by (namespace) (increase(automotive_imagebuilds_finished_total{phase="Failed"}[1h]))`+
+					` / sum by (namespace) (increase(automotive_imagebuilds_finished_total[1h])) > %d`, s.failureRatePercent),
+				For:    "15m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "Many image builds are failing",
+					"description": fmt.Sprintf("{{ $value | humanize }}%% of the builds finished in namespace {{ $labels.namespace }} "+
+						"in the last hour failed, more than %d%%.", s.failureRatePercent),
+				},
+			},
+			{
+				Alert:  "AutomotiveBuildStuckUploading",
+				Expr:   fmt.Sprintf("automotive_imagebuild_uploading_seconds > %d", s.uploadingMinutes*60),
+				For:    "1m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "An image build is stuck waiting for its uploads",
+					"description": fmt.Sprintf("Build {{ $labels.namespace }}/{{ $labels.name }} has waited for its file "+
+						"uploads for more than %d minutes.", s.uploadingMinutes),
+				},
+			},
+			{
+				Alert:  "AutomotiveBuildWorkspacePending",
+				Expr:   fmt.Sprintf("automotive_imagebuild_workspace_pending_seconds > %d", s.pvcPendingMinutes*60),
+				For:    "1m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "The workspace of an image build is not bound",
+					"description": fmt.Sprintf("The workspace PVC of build {{ $labels.namespace }}/{{ $labels.name }} has "+
+						"been pending for more than %d minutes.", s.pvcPendingMinutes),
+				},
+			},
+		},
+	}}
+
+	// round-trip through JSON so the spec holds the same types as one read back from the API server
+	data, err := json.Marshal(map[string]any{"groups": groups})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal rules: %w", err)
+	}
+	spec := map[string]any{}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal rules: %w", err)
+	}
+
+	rule := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetName(prometheusRuleName)
+	rule.SetNamespace(namespace)
+	rule.SetLabels(copyLabels(s.ruleLabels))
+	return rule, nil
+}
+
+// Grafana dashboard model, limited to what the generated dashboard uses
+type dashboard struct {
+	UID           string        `json:"uid"`
+	Title         string        `json:"title"`
+	Tags          []string      `json:"tags"`
+	SchemaVersion int           `json:"schemaVersion"`
+	Refresh       string        `json:"refresh"`
+	Time          dashboardTime `json:"time"`
+	Templating    templating    `json:"templating"`
+	Panels        []panel       `json:"panels"`
+}
+
+type dashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []templateVariable `json:"list"`
+}
+
+type templateVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type panel struct {
+	ID          int         `json:"id"`
+	Type        string      `json:"type"`
+	Title       string      `json:"title"`
+	Datasource  datasource  `json:"datasource"`
+	GridPos     gridPos     `json:"gridPos"`
+	Targets     []target    `json:"targets"`
+	FieldConfig fieldConfig `json:"fieldConfig"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit       string      `json:"unit,omitempty"`
+	Thresholds *thresholds `json:"thresholds,omitempty"`
+	Custom     *fieldStyle `json:"custom,omitempty"`
+}
+
+type thresholds struct {
+	Mode  string      `json:"mode"`
+	Steps []threshold `json:"steps"`
+}
+
+type threshold struct {
+	Color string   `json:"color"`
+	Value *float64 `json:"value"`
+}
+
+type fieldStyle struct {
+	ThresholdsStyle struct {
+		Mode string `json:"mode"`
+	} `json:"thresholdsStyle"`
+}
+
+// alertThreshold colors a panel red above the value an alert fires at, drawing it on time series
+func alertThreshold(value float64) fieldDefaults {
+	style := &fieldStyle{}
+	style.ThresholdsStyle.Mode = "line"
+	return fieldDefaults{
+		Thresholds: &thresholds{Mode: "absolute", Steps: []threshold{{Color: "green"}, {Color: "red", Value: &value}}},
+		Custom:     style,
+	}
+}
+
+// generateDashboardConfigMap builds the Grafana dashboard of the operator's builds and controllers, drawing
+// the alert thresholds of the settings, in a ConfigMap labelled for Grafana's dashboard sidecar
+func generateDashboardConfigMap(namespace string, s monitoringSettings) (*corev1.ConfigMap, error) {
+	ds := datasource{Type: "prometheus", UID: "${datasource}"}
+	timeSeries := func(id int, title string, pos gridPos, defaults fieldDefaults, targets ...target) panel {
+		return panel{ID: id, Type: "timeseries", Title: title, Datasource: ds, GridPos: pos, Targets: targets,
+			FieldConfig: fieldConfig{Defaults: defaults}}
+	}
+
+	failureRate := alertThreshold(float64(s.failureRatePercent))
+	failureRate.Unit = "percent"
+	uploading := alertThreshold(float64(s.uploadingMinutes * 60))
+	uploading.Unit = "s"
+	pvcPending := alertThreshold(float64(s.pvcPendingMinutes * 60))
+	pvcPending.Unit = "s"
+
+	d := dashboard{
+		UID:           "automotive-dev-builds",
+		Title:         "Automotive Dev Builds",
+		Tags:          []string{"automotive-dev"},
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          dashboardTime{From: "now-24h", To: "now"},
+		Templating: templating{List: []templateVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		}},
+		Panels: []panel{
+			{
+				ID: 1, Type: "stat", Title: "Builds by phase", Datasource: ds, GridPos: gridPos{H: 6, W: 24, X: 0, Y: 0},
+				Targets: []target{{RefID: "A", Expr: "sum by (phase) (automotive_imagebuilds)", LegendFormat: "{{phase}}"}},
+			},
+			timeSeries(2, "Build failure rate (last hour)", gridPos{H: 8, W: 12, X: 0, Y: 6}, failureRate, target{
+				RefID: "A",
+				Expr: `100 * sum by (namespace) (increase(automotive_imagebuilds_finished_total{phase="Failed"}[1h]))` +
+					` / sum by (namespace) (increase(automotive_imagebuilds_finished_total[1h]))`,
+				LegendFormat: "{{namespace}}",
+			}),
+			timeSeries(3, "Finished builds per hour", gridPos{H: 8, W: 12, X: 12, Y: 6}, fieldDefaults{}, target{
+				RefID: "A", Expr: "sum by (phase) (increase(automotive_imagebuilds_finished_total[1h]))", LegendFormat: "{{phase}}",
+			}),
+			timeSeries(4, "Builds waiting for uploads", gridPos{H: 8, W: 12, X: 0, Y: 14}, uploading, target{
+				RefID: "A", Expr: "automotive_imagebuild_uploading_seconds", LegendFormat: "{{namespace}}/{{name}}",
+			}),
+			timeSeries(5, "Pending build workspaces", gridPos{H: 8, W: 12, X: 12, Y: 14}, pvcPending, target{
+				RefID: "A", Expr: "automotive_imagebuild_workspace_pending_seconds", LegendFormat: "{{namespace}}/{{name}}",
+			}),
+			timeSeries(6, "Controller requeues", gridPos{H: 8, W: 24, X: 0, Y: 22}, fieldDefaults{Unit: "reqps"}, target{
+				RefID: "A", Expr: "sum by (controller, kind) (rate(automotive_controller_requeues_total[5m]))",
+				LegendFormat: "{{controller}} {{kind}}",
+			}),
+			timeSeries(7, "Workspace wait by requester (p90, last hour)", gridPos{H: 8, W: 12, X: 0, Y: 30}, fieldDefaults{Unit: "s"}, target{
+				RefID:        "A",
+				Expr:         "histogram_quantile(0.9, sum by (requester, le) (rate(automotive_imagebuild_workspace_wait_seconds_bucket[1h])))",
+				LegendFormat: "{{requester}}",
+			}),
+			timeSeries(8, "Builds waiting for workspace storage", gridPos{H: 8, W: 12, X: 12, Y: 30}, fieldDefaults{Unit: "s"}, target{
+				RefID: "A", Expr: "automotive_imagebuild_queued_seconds", LegendFormat: "{{requester}} {{namespace}}/{{name}}",
+			}),
+		},
+	}
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal dashboard: %w", err)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dashboardConfigMapName,
+			Namespace: namespace,
+			Labels:    copyLabels(s.dashboardLabels),
+		},
+		Data: map[string]string{dashboardFileName: string(data)},
+	}, nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
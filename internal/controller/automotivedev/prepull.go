@@ -0,0 +1,210 @@
+package automotivedev
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+const prePullDaemonSetName = "automotive-dev-prepull"
+
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch;create;update;patch;delete
+
+// prePullEnabled reports whether the AutomotiveDev asks for images to be pulled on the build nodes
+func prePullEnabled(av *automotivev1.AutomotiveDev) bool {
+	return av.Spec.BuildConfig != nil && av.Spec.BuildConfig.PrePull != nil && av.Spec.BuildConfig.PrePull.Enabled
+}
+
+// prePullImages returns the images the DaemonSet pulls: those of the PrePullPolicy, or the builder image
+func prePullImages(buildConfig *automotivev1.BuildConfig) []string {
+	if buildConfig.PrePull != nil && len(buildConfig.PrePull.Images) > 0 {
+		return buildConfig.PrePull.Images
+	}
+	return []string{tasks.Images(buildConfig).Builder}
+}
+
+// generatePrePullDaemonSet pulls every image in an init container on the nodes builds run on. Its pods
+// then idle in the first image so that they report ready once all images are pulled and the kubelet does
+// not garbage collect the images while the DaemonSet runs.
+func generatePrePullDaemonSet(namespace string, buildConfig *automotivev1.BuildConfig) *appsv1.DaemonSet {
+	images := prePullImages(buildConfig)
+	labels := map[string]string{
+		"app.kubernetes.io/name":       prePullDaemonSetName,
+		"app.kubernetes.io/managed-by": "automotive-dev-operator",
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("16Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+	securityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+
+	initContainers := make([]corev1.Container, 0, len(images))
+	for i, image := range images {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            fmt.Sprintf("pull-%d", i),
+			Image:           image,
+			Command:         []string{"/bin/sh", "-c", "true"},
+			Resources:       resources,
+			SecurityContext: securityContext,
+		})
+	}
+
+	var pullSecrets []corev1.LocalObjectReference
+	if buildConfig.BuilderImage != nil && buildConfig.BuilderImage.PullSecretRef != "" {
+		pullSecrets = []corev1.LocalObjectReference{{Name: buildConfig.BuilderImage.PullSecretRef}}
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prePullDaemonSetName,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": prePullDaemonSetName},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector:                  buildConfig.NodeSelector,
+					ImagePullSecrets:              pullSecrets,
+					AutomountServiceAccountToken:  ptr.To(false),
+					TerminationGracePeriodSeconds: ptr.To[int64](1),
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsUser:    ptr.To[int64](1000),
+						RunAsGroup:   ptr.To[int64](1000),
+						RunAsNonRoot: ptr.To(true),
+					},
+					InitContainers: initContainers,
+					Containers: []corev1.Container{{
+						Name:            "idle",
+						Image:           images[0],
+						Command:         []string{"/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 3600 & wait $!; done"},
+						Resources:       resources,
+						SecurityContext: securityContext,
+					}},
+				},
+			},
+		},
+	}
+}
+
+// prePullState is the progress of the pre-pull DaemonSet
+type prePullState struct {
+	status automotivev1.PrePullStatus
+	// rolledOut is false until the DaemonSet controller has scheduled pods of the current template on every node
+	rolledOut bool
+}
+
+// reconcilePrePull runs the pre-pull DaemonSet when pre-pulling is enabled and removes it when it is not.
+// It returns the DaemonSet's progress, or nil when it is disabled.
+func (r *AutomotiveDevReconciler) reconcilePrePull(ctx context.Context, av *automotivev1.AutomotiveDev) (*prePullState, error) {
+	if !prePullEnabled(av) {
+		return nil, r.deletePrePull(ctx, av)
+	}
+	log := r.Log.WithValues("automotivedev", client.ObjectKeyFromObject(av))
+
+	ds := generatePrePullDaemonSet(TektonResourcesNamespace, av.Spec.BuildConfig)
+	ds.Labels["automotive.sdv.cloud.redhat.com/managed-by"] = av.Name
+	if err := controllerutil.SetControllerReference(av, ds, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set controller reference: %w", err)
+	}
+
+	existing := &appsv1.DaemonSet{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(ds), existing)
+	switch {
+	case errors.IsNotFound(err):
+		if err := r.Create(ctx, ds); err != nil {
+			return nil, fmt.Errorf("daemonset %s: %w", ds.Name, err)
+		}
+		log.Info("Pre-pull DaemonSet created", "images", prePullImages(av.Spec.BuildConfig))
+		return prePullProgress(ds), nil
+	case err != nil:
+		return nil, fmt.Errorf("daemonset %s: %w", ds.Name, err)
+	}
+
+	if !needsUpdate(ds, existing, ds.Spec, existing.Spec) {
+		return prePullProgress(existing), nil
+	}
+	ds.ResourceVersion = existing.ResourceVersion
+	if err := r.Update(ctx, ds); err != nil {
+		return nil, fmt.Errorf("daemonset %s: %w", ds.Name, err)
+	}
+	// the update bumped the generation, so the pods of the previous template do not count as ready
+	return prePullProgress(ds), nil
+}
+
+// prePullProgress reports how many nodes of ds run a ready pod of its current template, that is pulled
+// every image
+func prePullProgress(ds *appsv1.DaemonSet) *prePullState {
+	state := &prePullState{status: automotivev1.PrePullStatus{DesiredNodes: ds.Status.DesiredNumberScheduled}}
+	for _, c := range ds.Spec.Template.Spec.InitContainers {
+		state.status.Images = append(state.status.Images, c.Image)
+	}
+	state.rolledOut = ds.Generation > 0 && ds.Status.ObservedGeneration == ds.Generation &&
+		ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled
+	if state.rolledOut {
+		state.status.ReadyNodes = ds.Status.NumberReady
+	}
+	return state
+}
+
+// deletePrePull removes the pre-pull DaemonSet of the AutomotiveDev, if it created one
+func (r *AutomotiveDevReconciler) deletePrePull(ctx context.Context, av *automotivev1.AutomotiveDev) error {
+	ds := &appsv1.DaemonSet{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: TektonResourcesNamespace, Name: prePullDaemonSetName}, ds); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(ds, av) {
+		return nil
+	}
+	if err := r.Delete(ctx, ds); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to delete %s: %w", ds.Name, err)
+	}
+	return nil
+}
+
+// prePullCondition reports whether the images are pulled on every build node
+func prePullCondition(generation int64, state *prePullState, err error) metav1.Condition {
+	cond := metav1.Condition{
+		Type:               automotivev1.AutomotiveDevImagesPrePulled,
+		Status:             metav1.ConditionFalse,
+		Reason:             "Pulling",
+		ObservedGeneration: generation,
+	}
+	switch {
+	case err != nil:
+		return readyCondition(automotivev1.AutomotiveDevImagesPrePulled, generation, err, "")
+	case !state.rolledOut:
+		cond.Message = "The pre-pull DaemonSet is rolling out"
+	case state.status.DesiredNodes == 0:
+		cond.Reason = "NoNodes"
+		cond.Message = "No node matches the build node selector"
+	case state.status.ReadyNodes < state.status.DesiredNodes:
+		cond.Message = fmt.Sprintf("Images are pulled on %d of %d build nodes", state.status.ReadyNodes, state.status.DesiredNodes)
+	default:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "Pulled"
+		cond.Message = fmt.Sprintf("Images are pulled on all %d build nodes", state.status.DesiredNodes)
+	}
+	return cond
+}
@@ -0,0 +1,277 @@
+package automotivedev
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/intermediateregistry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+
+// registryStorageDir is where the registry keeps its content, on its PersistentVolumeClaim
+const registryStorageDir = "/var/lib/registry"
+
+func registryLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       intermediateregistry.Name,
+		"app.kubernetes.io/managed-by": "automotive-dev-operator",
+	}
+}
+
+// generateRegistryPVC is the storage of the intermediate registry
+func generateRegistryPVC(namespace string, cfg *automotivev1.IntermediateRegistry) (*corev1.PersistentVolumeClaim, error) {
+	size := cfg.StorageSize
+	if size == "" {
+		size = intermediateregistry.DefaultStorageSize
+	}
+	quantity, err := resource.ParseQuantity(size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid intermediate registry storage size %q: %w", size, err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      intermediateregistry.Name,
+			Namespace: namespace,
+			Labels:    registryLabels(),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: quantity},
+			},
+		},
+	}
+	if cfg.StorageClass != "" {
+		pvc.Spec.StorageClassName = ptr.To(cfg.StorageClass)
+	}
+	return pvc, nil
+}
+
+// generateRegistryDeployment runs a single registry on the PersistentVolumeClaim. Deleting manifests is
+// allowed so that repositories of builds can be removed; the blobs no manifest refers to any more are
+// garbage collected whenever the pod starts, before the registry accepts pushes.
+func generateRegistryDeployment(namespace string, buildConfig *automotivev1.BuildConfig) *appsv1.Deployment {
+	labels := registryLabels()
+	image := tasks.Images(buildConfig).Registry
+	env := []corev1.EnvVar{
+		{Name: "REGISTRY_STORAGE_FILESYSTEM_ROOTDIRECTORY", Value: registryStorageDir},
+		{Name: "REGISTRY_STORAGE_DELETE_ENABLED", Value: "true"},
+		{Name: "REGISTRY_HTTP_ADDR", Value: fmt.Sprintf(":%d", intermediateregistry.Port)},
+	}
+	securityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+	volumeMounts := []corev1.VolumeMount{{Name: "storage", MountPath: registryStorageDir}}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      intermediateregistry.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": intermediateregistry.Name},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			// two registries must not share the volume, not even while rolling out
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					AutomountServiceAccountToken: ptr.To(false),
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot: ptr.To(true),
+						RunAsUser:    ptr.To[int64](1000),
+						FSGroup:      ptr.To[int64](1000),
+					},
+					InitContainers: []corev1.Container{{
+						Name:            "garbage-collect",
+						Image:           image,
+						Command:         []string{"registry", "garbage-collect", "--delete-untagged", "/etc/docker/registry/config.yml"},
+						Env:             env,
+						VolumeMounts:    volumeMounts,
+						SecurityContext: securityContext,
+					}},
+					Containers: []corev1.Container{{
+						Name:  "registry",
+						Image: image,
+						Env:   env,
+						Ports: []corev1.ContainerPort{{
+							Name:          "registry",
+							ContainerPort: intermediateregistry.Port,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{Path: "/v2/", Port: intstr.FromString("registry")},
+							},
+							PeriodSeconds: 10,
+						},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("50m"),
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+							Limits: corev1.ResourceList{
+								corev1.ResourceMemory: resource.MustParse("512Mi"),
+							},
+						},
+						VolumeMounts:    volumeMounts,
+						SecurityContext: securityContext,
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "storage",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: intermediateregistry.Name},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// generateRegistryService gives the registry the address builds are told
+func generateRegistryService(namespace string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      intermediateregistry.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": intermediateregistry.Name},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: registryLabels(),
+			Ports: []corev1.ServicePort{{
+				Name:       "registry",
+				Port:       intermediateregistry.Port,
+				TargetPort: intstr.FromString("registry"),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// reconcileRegistry runs the intermediate registry when it is enabled and removes it with its storage when it
+// is not. It returns the registry's Deployment, or nil when it is disabled.
+func (r *AutomotiveDevReconciler) reconcileRegistry(ctx context.Context, av *automotivev1.AutomotiveDev) (*appsv1.Deployment, error) {
+	if !intermediateregistry.Enabled(av.Spec.BuildConfig) {
+		return nil, r.deleteRegistry(ctx, av)
+	}
+	log := r.Log.WithValues("automotivedev", client.ObjectKeyFromObject(av))
+
+	pvc, err := generateRegistryPVC(TektonResourcesNamespace, av.Spec.BuildConfig.IntermediateRegistry)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(av, pvc, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set controller reference: %w", err)
+	}
+	// the claim is only created: its size and class cannot change in place
+	if err := r.Create(ctx, pvc); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("persistentvolumeclaim %s: %w", pvc.Name, err)
+	}
+
+	svc := generateRegistryService(TektonResourcesNamespace)
+	if err := controllerutil.SetControllerReference(av, svc, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set controller reference: %w", err)
+	}
+	existingSvc := &corev1.Service{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(svc), existingSvc)
+	switch {
+	case errors.IsNotFound(err):
+		if err := r.Create(ctx, svc); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+	case needsUpdate(svc, existingSvc, svc.Spec, existingSvc.Spec):
+		existingSvc.Labels = svc.Labels
+		existingSvc.OwnerReferences = svc.OwnerReferences
+		existingSvc.Spec.Selector = svc.Spec.Selector
+		existingSvc.Spec.Ports = svc.Spec.Ports
+		if err := r.Update(ctx, existingSvc); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+
+	deploy := generateRegistryDeployment(TektonResourcesNamespace, av.Spec.BuildConfig)
+	deploy.Labels["automotive.sdv.cloud.redhat.com/managed-by"] = av.Name
+	if err := controllerutil.SetControllerReference(av, deploy, r.Scheme); err != nil {
+		return nil, fmt.Errorf("failed to set controller reference: %w", err)
+	}
+	existing := &appsv1.Deployment{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(deploy), existing)
+	switch {
+	case errors.IsNotFound(err):
+		if err := r.Create(ctx, deploy); err != nil {
+			return nil, fmt.Errorf("deployment %s: %w", deploy.Name, err)
+		}
+		log.Info("Intermediate registry created", "address", intermediateregistry.Host(TektonResourcesNamespace))
+		return deploy, nil
+	case err != nil:
+		return nil, fmt.Errorf("deployment %s: %w", deploy.Name, err)
+	}
+	if !needsUpdate(deploy, existing, deploy.Spec, existing.Spec) {
+		return existing, nil
+	}
+	deploy.ResourceVersion = existing.ResourceVersion
+	if err := r.Update(ctx, deploy); err != nil {
+		return nil, fmt.Errorf("deployment %s: %w", deploy.Name, err)
+	}
+	return deploy, nil
+}
+
+// deleteRegistry removes the intermediate registry of the AutomotiveDev and its storage, if it created them
+func (r *AutomotiveDevReconciler) deleteRegistry(ctx context.Context, av *automotivev1.AutomotiveDev) error {
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &corev1.Service{}, &corev1.PersistentVolumeClaim{}} {
+		if err := r.Get(ctx, client.ObjectKey{Namespace: TektonResourcesNamespace, Name: intermediateregistry.Name}, obj); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			continue
+		}
+		if !metav1.IsControlledBy(obj, av) {
+			continue
+		}
+		if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete %s: %w", obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// registryCondition reports whether the intermediate registry serves requests
+func registryCondition(generation int64, deploy *appsv1.Deployment, err error) metav1.Condition {
+	if err != nil {
+		return readyCondition(automotivev1.AutomotiveDevIntermediateRegistryReady, generation, err, "")
+	}
+	if deploy == nil || deploy.Status.ObservedGeneration != deploy.Generation || deploy.Status.AvailableReplicas < 1 {
+		return metav1.Condition{
+			Type:               automotivev1.AutomotiveDevIntermediateRegistryReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "Starting",
+			Message:            "The intermediate registry is starting",
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               automotivev1.AutomotiveDevIntermediateRegistryReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Available",
+		Message:            fmt.Sprintf("The intermediate registry serves builds at %s", intermediateregistry.Host(TektonResourcesNamespace)),
+		ObservedGeneration: generation,
+	}
+}
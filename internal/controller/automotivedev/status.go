@@ -0,0 +1,166 @@
+package automotivedev
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/features"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/intermediateregistry"
+)
+
+// tektonResult is the outcome of installing an AutomotiveDev's Tekton resources
+type tektonResult struct {
+	tasksErr    error
+	pipelineErr error
+	// resources lists the resources that were installed
+	resources []automotivev1.ManagedResource
+	// monitoringErr is the outcome of installing enabled monitoring, which does not fail the reconcile of
+	// the Tekton resources
+	monitoringErr error
+	// prePull is the progress of enabled pre-pulling and prePullErr the outcome of installing it; neither fails
+	// the reconcile of the Tekton resources either
+	prePull    *prePullState
+	prePullErr error
+	// registry is the Deployment of the enabled intermediate registry and registryErr the outcome of installing it
+	registry    *appsv1.Deployment
+	registryErr error
+}
+
+func (t *tektonResult) err() error {
+	if t.tasksErr != nil {
+		return t.tasksErr
+	}
+	return t.pipelineErr
+}
+
+// managedResource records a resource together with the hash of the spec it was installed with
+func managedResource(kind, name string, spec any) automotivev1.ManagedResource {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		// Tekton specs always marshal; an empty hash just marks the resource as changed next time
+		return automotivev1.ManagedResource{Kind: kind, Name: name}
+	}
+	sum := sha256.Sum256(data)
+	return automotivev1.ManagedResource{Kind: kind, Name: name, Hash: hex.EncodeToString(sum[:])}
+}
+
+// readyCondition turns the outcome of installing a kind of resource into its condition
+func readyCondition(conditionType string, generation int64, err error, readyMessage string) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:               conditionType,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InstallFailed",
+			Message:            err.Error(),
+			ObservedGeneration: generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Installed",
+		Message:            readyMessage,
+		ObservedGeneration: generation,
+	}
+}
+
+// monitoringCondition reports whether enabled monitoring is installed, telling a cluster without the
+// Prometheus Operator apart from a failed install
+func monitoringCondition(generation int64, err error) metav1.Condition {
+	if meta.IsNoMatchError(err) {
+		return metav1.Condition{
+			Type:               automotivev1.AutomotiveDevMonitoringReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             "PrometheusOperatorMissing",
+			Message:            "the PrometheusRule CRD of the Prometheus Operator is not installed",
+			ObservedGeneration: generation,
+		}
+	}
+	return readyCondition(automotivev1.AutomotiveDevMonitoringReady, generation, err,
+		"Build alerts and dashboard are installed")
+}
+
+// updateStatus records the outcome of a reconcile of the AutomotiveDev's current generation, patching the
+// status only when it changes so that a no-op reconcile writes nothing
+func (r *AutomotiveDevReconciler) updateStatus(ctx context.Context, av *automotivev1.AutomotiveDev, result *tektonResult) error {
+	fresh := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(av), fresh); err != nil {
+		return fmt.Errorf("failed to get fresh AutomotiveDev: %w", err)
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	before := fresh.Status.DeepCopy()
+	status := &fresh.Status
+
+	meta.SetStatusCondition(&status.Conditions, readyCondition(automotivev1.AutomotiveDevTasksReady,
+		av.Generation, result.tasksErr, "Tekton tasks are installed"))
+	meta.SetStatusCondition(&status.Conditions, readyCondition(automotivev1.AutomotiveDevPipelineReady,
+		av.Generation, result.pipelineErr, "Tekton pipeline is installed"))
+
+	if monitoringEnabled(av) {
+		meta.SetStatusCondition(&status.Conditions, monitoringCondition(av.Generation, result.monitoringErr))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, automotivev1.AutomotiveDevMonitoringReady)
+	}
+
+	if prePullEnabled(av) {
+		meta.SetStatusCondition(&status.Conditions, prePullCondition(av.Generation, result.prePull, result.prePullErr))
+		if result.prePull != nil {
+			status.PrePull = result.prePull.status.DeepCopy()
+		}
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, automotivev1.AutomotiveDevImagesPrePulled)
+		status.PrePull = nil
+	}
+
+	if intermediateregistry.Enabled(av.Spec.BuildConfig) {
+		meta.SetStatusCondition(&status.Conditions, registryCondition(av.Generation, result.registry, result.registryErr))
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, automotivev1.AutomotiveDevIntermediateRegistryReady)
+	}
+
+	if err := result.err(); err != nil {
+		status.Phase = "Failed"
+		status.Message = err.Error()
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               automotivev1.AutomotiveDevVersionInstalled,
+			Status:             metav1.ConditionFalse,
+			Reason:             "InstallFailed",
+			Message:            fmt.Sprintf("Tekton resources of operator version %s are not fully installed", r.Version),
+			ObservedGeneration: av.Generation,
+		})
+	} else {
+		status.Phase = "Ready"
+		status.Message = "Tekton tasks and pipeline are up to date"
+		status.OperatorVersion = r.Version
+		status.ManagedResources = result.resources
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               automotivev1.AutomotiveDevVersionInstalled,
+			Status:             metav1.ConditionTrue,
+			Reason:             "Installed",
+			Message:            fmt.Sprintf("Tekton resources of operator version %s are installed", r.Version),
+			ObservedGeneration: av.Generation,
+		})
+	}
+	status.EnabledFeatures = features.EnabledGates(av)
+	status.ObservedGeneration = av.Generation
+
+	if equality.Semantic.DeepEqual(before, status) {
+		return nil
+	}
+	status.LastUpdated = &metav1.Time{Time: time.Now()}
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return fmt.Errorf("failed to update AutomotiveDev status: %w", err)
+	}
+	return nil
+}
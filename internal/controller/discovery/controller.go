@@ -0,0 +1,308 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/oci"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+const (
+	// defaultInterval is used when ImageDiscovery.IntervalMinutes is unset
+	defaultInterval = time.Hour
+
+	// discoveredFromAnnotation records the repository:tag an Image was imported from
+	discoveredFromAnnotation = "automotive.sdv.cloud.redhat.com/discovered-from"
+)
+
+// ImageDiscoveryReconciler periodically scans the registries configured in an AutomotiveDev and
+// creates or updates an Image for every automotive image it finds
+type ImageDiscoveryReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Log    logr.Logger
+	// HTTPClient is used to talk to registries; http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=automotivedevs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=automotivedevs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=images,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile scans the configured repositories once the discovery interval has elapsed
+func (r *ImageDiscoveryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("automotivedev", req.NamespacedName)
+
+	av := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, req.NamespacedName, av); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	cfg := av.Spec.ImageDiscovery
+	if cfg == nil || !cfg.Enabled || len(cfg.Repositories) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	interval := defaultInterval
+	if cfg.IntervalMinutes > 0 {
+		interval = time.Duration(cfg.IntervalMinutes) * time.Minute
+	}
+	if st := av.Status.Discovery; st != nil && st.LastScanTime != nil {
+		if wait := time.Until(st.LastScanTime.Add(interval)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	log.Info("Scanning registries for images", "repositories", len(cfg.Repositories))
+	var imported int32
+	var failures []string
+	for _, repo := range cfg.Repositories {
+		n, err := r.scanRepository(ctx, av.Namespace, repo)
+		imported += n
+		if err != nil {
+			log.Error(err, "Failed to scan repository", "repository", repo.Repository)
+			failures = append(failures, fmt.Sprintf("%s: %v", repo.Repository, err))
+		}
+	}
+	log.Info("Registry scan finished", "imported", imported, "failed", len(failures))
+
+	if err := r.updateDiscoveryStatus(ctx, av, imported, strings.Join(failures, "; ")); err != nil {
+		log.Error(err, "Failed to update discovery status")
+		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// scanRepository imports the automotive images of one repository and returns how many Images changed
+func (r *ImageDiscoveryReconciler) scanRepository(ctx context.Context, namespace string, repo automotivev1.DiscoveryRepository) (int32, error) {
+	host, repoPath, err := registry.SplitRepository(repo.Repository)
+	if err != nil {
+		return 0, err
+	}
+
+	rc := &registry.Client{HTTPClient: r.HTTPClient, Host: host, Insecure: repo.Insecure}
+	if repo.SecretRef != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: repo.SecretRef, Namespace: namespace}, secret); err != nil {
+			return 0, fmt.Errorf("failed to get pull secret %s: %w", repo.SecretRef, err)
+		}
+		if rc.Username, rc.Password, err = registry.DockerConfigCredentials(secret.Data[corev1.DockerConfigJsonKey], host); err != nil {
+			return 0, err
+		}
+	}
+
+	tags, err := rc.ListTags(ctx, repoPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var imported int32
+	for _, tag := range tags {
+		if repo.TagPattern != "" {
+			if ok, err := path.Match(repo.TagPattern, tag); err != nil {
+				return imported, fmt.Errorf("invalid tag pattern %q: %w", repo.TagPattern, err)
+			} else if !ok {
+				continue
+			}
+		}
+
+		m, digest, err := rc.GetManifest(ctx, repoPath, tag)
+		if err != nil {
+			return imported, err
+		}
+		if !isAutomotiveImage(m, repo.MediaTypes) {
+			continue
+		}
+
+		changed, err := r.importImage(ctx, namespace, repo, tag, m, digest)
+		if err != nil {
+			return imported, err
+		}
+		if changed {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// isAutomotiveImage reports whether a manifest describes an automotive image
+func isAutomotiveImage(m *registry.Manifest, mediaTypes []string) bool {
+	if m.Annotations[oci.AnnotationDistro] != "" || oci.IsAutomotiveMediaType(m.ArtifactType) {
+		return true
+	}
+	if m.ArtifactType != "" && slices.Contains(mediaTypes, m.ArtifactType) {
+		return true
+	}
+	return m.Config != nil && slices.Contains(mediaTypes, m.Config.MediaType)
+}
+
+// exportFormat returns the export format of a manifest from its annotation, or from the media type of its first
+// automotive layer for images pushed without annotations
+func exportFormat(m *registry.Manifest) string {
+	if f := m.Annotations[oci.AnnotationExportFormat]; f != "" {
+		return f
+	}
+	for _, l := range m.Layers {
+		if f := oci.FormatForMediaType(l.MediaType); f != "" {
+			return f
+		}
+	}
+	return "unknown"
+}
+
+// importImage creates the Image for repository:tag or updates it when its digest changed. Images that
+// exist but were not discovered from the same reference are left alone.
+func (r *ImageDiscoveryReconciler) importImage(ctx context.Context, namespace string, repo automotivev1.DiscoveryRepository,
+	tag string, m *registry.Manifest, digest string) (bool, error) {
+	log := r.Log.WithValues("repository", repo.Repository, "tag", tag)
+	ref := repo.Repository + ":" + tag
+	_, repoPath, _ := registry.SplitRepository(repo.Repository)
+	name := imageName(repoPath, tag)
+	spec := imageSpec(repo, ref, tag, m, digest)
+
+	existing := &automotivev1.Image{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, existing)
+	if errors.IsNotFound(err) {
+		img := &automotivev1.Image{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by":               "automotive-dev-operator",
+					"app.kubernetes.io/part-of":                  "automotive-dev",
+					"automotive.sdv.cloud.redhat.com/discovered": "true",
+				},
+				Annotations: map[string]string{
+					discoveredFromAnnotation: ref,
+				},
+			},
+			Spec: spec,
+		}
+		if err := r.Create(ctx, img); err != nil {
+			return false, fmt.Errorf("failed to create Image %s: %w", name, err)
+		}
+		log.Info("Imported image", "image", name, "digest", digest)
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get Image %s: %w", name, err)
+	}
+
+	if existing.Annotations[discoveredFromAnnotation] != ref {
+		log.Info("Skipping image, an Image with the same name was not discovered from this reference", "image", name)
+		return false, nil
+	}
+	if existing.Spec.Location.Registry != nil && existing.Spec.Location.Registry.Digest == digest {
+		return false, nil
+	}
+
+	patch := client.MergeFrom(existing.DeepCopy())
+	// The lifecycle belongs to whoever curates the catalog, not to the registry
+	spec.Lifecycle = existing.Spec.Lifecycle
+	existing.Spec = spec
+	if err := r.Patch(ctx, existing, patch); err != nil {
+		return false, fmt.Errorf("failed to update Image %s: %w", name, err)
+	}
+	log.Info("Updated image", "image", name, "digest", digest)
+	return true, nil
+}
+
+// imageSpec builds the Image spec of a discovered image from its manifest annotations
+func imageSpec(repo automotivev1.DiscoveryRepository, ref, tag string, m *registry.Manifest, digest string) automotivev1.ImageSpec {
+	a := m.Annotations
+	spec := automotivev1.ImageSpec{
+		Distro:       valueOr(a[oci.AnnotationDistro], "unknown"),
+		Target:       valueOr(a[oci.AnnotationTarget], "unknown"),
+		Architecture: valueOr(a[oci.AnnotationArchitecture], "unknown"),
+		ExportFormat: exportFormat(m),
+		Mode:         a[oci.AnnotationMode],
+		Version:      valueOr(a[oci.AnnotationVersion], tag),
+		Description:  a[oci.AnnotationDescription],
+		Location: automotivev1.ImageLocation{
+			Type: "registry",
+			Registry: &automotivev1.RegistryLocation{
+				URL:       ref,
+				Digest:    digest,
+				SecretRef: repo.SecretRef,
+			},
+		},
+		Metadata: &automotivev1.ImageMetadata{
+			CreatedBy:        "image-discovery",
+			SourceImageBuild: a[oci.AnnotationImageBuild],
+			Annotations:      a,
+		},
+	}
+	if created, err := time.Parse(time.RFC3339, a[oci.AnnotationCreated]); err == nil {
+		spec.Metadata.BuildDate = &metav1.Time{Time: created}
+	}
+	if len(m.Layers) > 0 {
+		var total int64
+		for _, l := range m.Layers {
+			total += l.Size
+		}
+		spec.Size = &automotivev1.ImageSize{CompressedBytes: &total}
+	}
+	return spec
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// imageName derives an Image name from the last repository path segment and the tag
+func imageName(repoPath, tag string) string {
+	name := strings.ToLower(path.Base(repoPath) + "-" + tag)
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func (r *ImageDiscoveryReconciler) updateDiscoveryStatus(ctx context.Context, av *automotivev1.AutomotiveDev, imported int32, message string) error {
+	fresh := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, types.NamespacedName{Name: av.Name, Namespace: av.Namespace}, fresh); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(fresh.DeepCopy())
+	now := metav1.Now()
+	fresh.Status.Discovery = &automotivev1.ImageDiscoveryStatus{
+		LastScanTime:   &now,
+		ImportedImages: imported,
+		Message:        message,
+	}
+	return r.Status().Patch(ctx, fresh, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ImageDiscoveryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("imagediscovery").
+		For(&automotivev1.AutomotiveDev{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
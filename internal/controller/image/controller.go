@@ -3,28 +3,40 @@ package image
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/go-logr/logr"
 	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/requeue"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 )
 
 // ImageReconciler reconciles an Image object
 type ImageReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
-	Log    logr.Logger
+	Scheme   *runtime.Scheme
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	// HTTPClient is used to delete garbage collected images from registries; http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Requeue paces the requeues of images waiting on verification and status updates
+	Requeue requeue.Pacer
 }
 
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=images,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=images/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=images/finalizers,verbs=update
+// +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=automotivedevs,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile Image
 func (r *ImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -35,6 +47,20 @@ func (r *ImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	lifecycle := effectiveLifecycle(image)
+	if lifecycle != image.Status.ObservedLifecycle {
+		return r.handleLifecycleChange(ctx, image, lifecycle)
+	}
+
+	// Revoked images are never re-verified or made available again
+	if lifecycle == automotivev1.ImageLifecycleRevoked {
+		return ctrl.Result{}, nil
+	}
+
+	if result, collected, err := r.collectGarbage(ctx, image, lifecycle); collected || err != nil {
+		return result, err
+	}
+
 	// Handle different phases
 	switch image.Status.Phase {
 	case "":
@@ -51,9 +77,50 @@ func (r *ImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	}
 }
 
+// effectiveLifecycle returns the image lifecycle, treating an unset value as candidate
+func effectiveLifecycle(image *automotivev1.Image) automotivev1.ImageLifecycle {
+	if image.Spec.Lifecycle == "" {
+		return automotivev1.ImageLifecycleCandidate
+	}
+	return image.Spec.Lifecycle
+}
+
+func (r *ImageReconciler) handleLifecycleChange(ctx context.Context, image *automotivev1.Image, lifecycle automotivev1.ImageLifecycle) (ctrl.Result, error) {
+	log := r.Log.WithValues("image", types.NamespacedName{Name: image.Name, Namespace: image.Namespace})
+	previous := image.Status.ObservedLifecycle
+
+	if previous != "" {
+		log.Info("Image lifecycle changed", "from", previous, "to", lifecycle)
+		r.recordEvent(image, corev1.EventTypeNormal, "LifecycleChanged",
+			fmt.Sprintf("Lifecycle changed from %s to %s", previous, lifecycle))
+	}
+
+	if err := r.updateObservedLifecycle(ctx, image, lifecycle); err != nil {
+		log.Error(err, "Failed to update observed lifecycle")
+		return r.Requeue.Retry("status"), nil
+	}
+
+	if lifecycle == automotivev1.ImageLifecycleRevoked {
+		r.recordEvent(image, corev1.EventTypeWarning, "Revoked", "Image has been revoked and must not be distributed")
+		if err := r.updateStatus(ctx, image, "Unavailable", "Image has been revoked"); err != nil {
+			return r.Requeue.Retry("status"), nil
+		}
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{Requeue: true}, nil
+}
+
+func (r *ImageReconciler) recordEvent(image *automotivev1.Image, eventType, reason, message string) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Event(image, eventType, reason, message)
+}
+
 func (r *ImageReconciler) handleInitialState(ctx context.Context, image *automotivev1.Image) (ctrl.Result, error) {
 	if err := r.updateStatus(ctx, image, "Verifying", "Starting image location verification"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
 	return ctrl.Result{Requeue: true}, nil
 }
@@ -66,26 +133,26 @@ func (r *ImageReconciler) handleVerifyingState(ctx context.Context, image *autom
 	if err != nil {
 		log.Error(err, "Failed to verify image location")
 		if err := r.updateStatus(ctx, image, "Unavailable", fmt.Sprintf("Verification failed: %v", err)); err != nil {
-			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			return r.Requeue.Retry("status"), nil
 		}
-		return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+		return r.Requeue.SyncEvery("verify", 5*time.Minute), nil
 	}
 
 	if accessible {
 		if err := r.updateStatus(ctx, image, "Available", "Image location verified and accessible"); err != nil {
-			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+			return r.Requeue.Retry("status"), nil
 		}
 		// Set LastVerified timestamp
 		if err := r.updateLastVerified(ctx, image); err != nil {
 			log.Error(err, "Failed to update LastVerified timestamp")
 		}
-		return ctrl.Result{RequeueAfter: time.Hour * 1}, nil // Recheck every hour
+		return r.Requeue.SyncEvery("verify", time.Hour), nil // Recheck every hour
 	}
 
 	if err := r.updateStatus(ctx, image, "Unavailable", "Image location is not accessible"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	return r.Requeue.SyncEvery("verify", 5*time.Minute), nil
 }
 
 func (r *ImageReconciler) handleAvailableState(ctx context.Context, image *automotivev1.Image) (ctrl.Result, error) {
@@ -93,7 +160,7 @@ func (r *ImageReconciler) handleAvailableState(ctx context.Context, image *autom
 	accessible, err := r.verifyImageLocation(ctx, image)
 	if err != nil || !accessible {
 		if err := r.updateStatus(ctx, image, "Verifying", "Re-verifying image location"); err != nil {
-			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+			return r.Requeue.Retry("status"), nil
 		}
 		return ctrl.Result{Requeue: true}, nil
 	}
@@ -103,13 +170,13 @@ func (r *ImageReconciler) handleAvailableState(ctx context.Context, image *autom
 		r.Log.Error(err, "Failed to update LastVerified timestamp")
 	}
 
-	return ctrl.Result{RequeueAfter: time.Hour * 1}, nil // Recheck every hour
+	return r.Requeue.SyncEvery("verify", time.Hour), nil // Recheck every hour
 }
 
 func (r *ImageReconciler) handleUnavailableState(ctx context.Context, image *automotivev1.Image) (ctrl.Result, error) {
 	// Try to verify again after some time
 	if err := r.updateStatus(ctx, image, "Verifying", "Retrying image location verification"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
 	return ctrl.Result{Requeue: true}, nil
 }
@@ -198,9 +265,25 @@ func (r *ImageReconciler) updateLastVerified(ctx context.Context, image *automot
 	return r.Status().Patch(ctx, fresh, patch)
 }
 
+func (r *ImageReconciler) updateObservedLifecycle(ctx context.Context, image *automotivev1.Image, lifecycle automotivev1.ImageLifecycle) error {
+	fresh := &automotivev1.Image{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      image.Name,
+		Namespace: image.Namespace,
+	}, fresh); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.ObservedLifecycle = lifecycle
+
+	return r.Status().Patch(ctx, fresh, patch)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ImageReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&automotivev1.Image{}).
+		WithOptions(controller.Options{RateLimiter: r.Requeue.RateLimiter()}).
 		Complete(r)
 }
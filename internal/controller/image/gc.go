@@ -0,0 +1,203 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// OperatorNamespace is where the AutomotiveDev holding the garbage collection policy lives
+	OperatorNamespace = "automotive-dev-operator-system"
+
+	// defaultGCMaxAgeDays is used when ImageGCPolicy.MaxAgeDays is unset
+	defaultGCMaxAgeDays = 90
+)
+
+// GarbageCollected condition reasons
+const (
+	gcReasonDeprecated   = "Deprecated"
+	gcReasonDeleteFailed = "RegistryDeleteFailed"
+)
+
+// defaultGCLifecycles are collected when ImageGCPolicy.Lifecycles is unset; released images are kept
+var defaultGCLifecycles = []automotivev1.ImageLifecycle{automotivev1.ImageLifecycleCandidate, automotivev1.ImageLifecycleDeprecated}
+
+// getGCPolicy returns the garbage collection policy of the operator's AutomotiveDev, or nil if it is not enabled
+func (r *ImageReconciler) getGCPolicy(ctx context.Context) (*automotivev1.ImageGCPolicy, error) {
+	autoDev := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get AutomotiveDev configuration: %w", err)
+	}
+	if policy := autoDev.Spec.ImageGC; policy != nil && policy.Enabled {
+		return policy, nil
+	}
+	return nil, nil
+}
+
+// isStale reports whether the policy collects an image with the given lifecycle at now
+func isStale(image *automotivev1.Image, lifecycle automotivev1.ImageLifecycle, policy *automotivev1.ImageGCPolicy, now time.Time) bool {
+	lifecycles := policy.Lifecycles
+	if len(lifecycles) == 0 {
+		lifecycles = defaultGCLifecycles
+	}
+	if lifecycle == automotivev1.ImageLifecycleRevoked || !slices.Contains(lifecycles, lifecycle) {
+		return false
+	}
+	if policy.MinAccesses > 0 && image.Status.AccessCount >= policy.MinAccesses {
+		return false
+	}
+	maxAgeDays := int32(defaultGCMaxAgeDays)
+	if policy.MaxAgeDays > 0 {
+		maxAgeDays = policy.MaxAgeDays
+	}
+	return now.Sub(lastUsed(image)) > time.Duration(maxAgeDays)*24*time.Hour
+}
+
+// lastUsed is when an image was last accessed, or built or created if it never was
+func lastUsed(image *automotivev1.Image) time.Time {
+	if image.Status.LastAccessed != nil {
+		return image.Status.LastAccessed.Time
+	}
+	if image.Spec.Metadata != nil && image.Spec.Metadata.BuildDate != nil {
+		return image.Spec.Metadata.BuildDate.Time
+	}
+	return image.CreationTimestamp.Time
+}
+
+// collectGarbage applies the garbage collection policy to an image. It reports whether the image was
+// collected, in which case the returned result ends the reconcile.
+func (r *ImageReconciler) collectGarbage(ctx context.Context, image *automotivev1.Image, lifecycle automotivev1.ImageLifecycle) (ctrl.Result, bool, error) {
+	policy, err := r.getGCPolicy(ctx)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+	if policy == nil || !isStale(image, lifecycle, policy, time.Now()) {
+		return ctrl.Result{}, false, nil
+	}
+
+	reason := fmt.Sprintf("Image was not accessed since %s and has %d accesses",
+		lastUsed(image).UTC().Format(time.RFC3339), image.Status.AccessCount)
+	if policy.Action == automotivev1.ImageGCDelete {
+		return r.deleteStaleImage(ctx, image, reason)
+	}
+	if lifecycle == automotivev1.ImageLifecycleDeprecated {
+		return ctrl.Result{}, false, nil
+	}
+	return r.deprecateStaleImage(ctx, image, reason)
+}
+
+// deprecateStaleImage marks a stale image deprecated, which the lifecycle handling then reports
+func (r *ImageReconciler) deprecateStaleImage(ctx context.Context, image *automotivev1.Image, reason string) (ctrl.Result, bool, error) {
+	log := r.Log.WithValues("image", types.NamespacedName{Name: image.Name, Namespace: image.Namespace})
+
+	fresh := &automotivev1.Image{}
+	if err := r.Get(ctx, types.NamespacedName{Name: image.Name, Namespace: image.Namespace}, fresh); err != nil {
+		return ctrl.Result{}, true, client.IgnoreNotFound(err)
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Spec.Lifecycle = automotivev1.ImageLifecycleDeprecated
+	if err := r.Patch(ctx, fresh, patch); err != nil {
+		return ctrl.Result{}, true, fmt.Errorf("failed to deprecate stale image: %w", err)
+	}
+
+	log.Info("Deprecated stale image", "reason", reason)
+	r.recordEvent(image, corev1.EventTypeNormal, "GarbageCollected", reason+"; deprecated it")
+	if err := r.setGarbageCollectedCondition(ctx, image, metav1.ConditionTrue, gcReasonDeprecated, reason); err != nil {
+		log.Error(err, "Failed to update GarbageCollected condition")
+	}
+	return ctrl.Result{Requeue: true}, true, nil
+}
+
+// deleteStaleImage deletes the artifact of a stale image from its registry and then the image. An image whose
+// artifact cannot be deleted is kept and marked, so its owners can delete the artifact themselves.
+func (r *ImageReconciler) deleteStaleImage(ctx context.Context, image *automotivev1.Image, reason string) (ctrl.Result, bool, error) {
+	log := r.Log.WithValues("image", types.NamespacedName{Name: image.Name, Namespace: image.Namespace})
+
+	if err := r.deleteRegistryArtifact(ctx, image); err != nil {
+		log.Error(err, "Failed to delete stale image from its registry")
+		r.recordEvent(image, corev1.EventTypeWarning, "GarbageCollectionFailed",
+			fmt.Sprintf("%s but it could not be deleted from its registry: %v", reason, err))
+		if err := r.setGarbageCollectedCondition(ctx, image, metav1.ConditionFalse, gcReasonDeleteFailed, err.Error()); err != nil {
+			log.Error(err, "Failed to update GarbageCollected condition")
+		}
+		return r.Requeue.SyncEvery("gc", time.Hour), true, nil
+	}
+
+	log.Info("Deleted stale image", "reason", reason)
+	r.recordEvent(image, corev1.EventTypeNormal, "GarbageCollected", reason+"; deleted it from its registry")
+	if err := r.Delete(ctx, image); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, true, fmt.Errorf("failed to delete stale image: %w", err)
+	}
+	return ctrl.Result{}, true, nil
+}
+
+// deleteRegistryArtifact deletes the manifest an image's registry location points to
+func (r *ImageReconciler) deleteRegistryArtifact(ctx context.Context, image *automotivev1.Image) error {
+	loc := image.Spec.Location.Registry
+	if image.Spec.Location.Type != "registry" || loc == nil || loc.URL == "" {
+		return fmt.Errorf("image has no registry location")
+	}
+	ref, err := registry.ParseReference(loc.URL)
+	if err != nil {
+		return err
+	}
+
+	rc := &registry.Client{HTTPClient: r.HTTPClient, Host: ref.APIHost()}
+	if loc.SecretRef != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: loc.SecretRef, Namespace: image.Namespace}, secret); err != nil {
+			return fmt.Errorf("failed to get registry secret %s: %w", loc.SecretRef, err)
+		}
+		if rc.Username, rc.Password, err = registry.DockerConfigCredentials(secret.Data[corev1.DockerConfigJsonKey], ref.Host); err != nil {
+			return err
+		}
+	}
+
+	digest := loc.Digest
+	if digest == "" {
+		digest = ref.Digest
+	}
+	if digest == "" {
+		if digest, err = rc.ResolveDigest(ctx, ref.Repository, ref.Tag); err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", loc.URL, err)
+		}
+	}
+	return rc.DeleteManifest(ctx, ref.Repository, digest)
+}
+
+// setGarbageCollectedCondition records the GarbageCollected condition, patching the status only when it changes
+func (r *ImageReconciler) setGarbageCollectedCondition(ctx context.Context, image *automotivev1.Image, status metav1.ConditionStatus, reason, message string) error {
+	existing := meta.FindStatusCondition(image.Status.Conditions, automotivev1.ImageGarbageCollected)
+	if existing != nil && existing.Status == status && existing.Reason == reason && existing.Message == message {
+		return nil
+	}
+
+	fresh := &automotivev1.Image{}
+	if err := r.Get(ctx, types.NamespacedName{Name: image.Name, Namespace: image.Namespace}, fresh); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	meta.SetStatusCondition(&fresh.Status.Conditions, metav1.Condition{
+		Type:               automotivev1.ImageGarbageCollected,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: fresh.Generation,
+	})
+	return r.Status().Patch(ctx, fresh, patch)
+}
@@ -0,0 +1,164 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// artifactServingCheckInterval is how often the artifact pod of a completed build is checked
+	artifactServingCheckInterval = time.Minute
+
+	// artifactPodUnreadyTimeout is how long an artifact pod may stay unready before it is replaced; the
+	// kubelet restarting its containers gets that long to bring it back
+	artifactPodUnreadyTimeout = 3 * time.Minute
+)
+
+// ArtifactServing condition reasons
+const (
+	artifactServingReasonReady      = "PodReady"
+	artifactServingReasonNotReady   = "PodNotReady"
+	artifactServingReasonRecreating = "PodRecreating"
+	artifactServingReasonExpired    = "Expired"
+)
+
+// checkArtifactServing replaces a missing, exited or long unready artifact pod and records the outcome in the
+// ArtifactServing condition. It returns when the pod should be checked again.
+func (r *ImageBuildReconciler) checkArtifactServing(ctx context.Context, imageBuild *automotivev1.ImageBuild) (time.Duration, error) {
+	log := r.buildLog(imageBuild)
+
+	podName := fmt.Sprintf("%s-artifact-pod", imageBuild.Name)
+	pod := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Name: podName, Namespace: imageBuild.Namespace}, pod)
+	if errors.IsNotFound(err) {
+		log.Info("Artifact pod is missing, recreating it", "pod", podName)
+		if err := r.recreateArtifactServing(ctx, imageBuild); err != nil {
+			_ = r.setArtifactServingCondition(ctx, imageBuild, metav1.ConditionFalse, artifactServingReasonRecreating, err.Error())
+			return 0, err
+		}
+		return artifactServingCheckInterval, r.setArtifactServingCondition(ctx, imageBuild, metav1.ConditionTrue, artifactServingReasonReady, "Artifact pod was recreated and is serving")
+	} else if err != nil {
+		return 0, fmt.Errorf("error checking artifact pod: %w", err)
+	}
+
+	if pod.DeletionTimestamp != nil {
+		return 5 * time.Second, nil
+	}
+	if err := r.ensureArtifactExposed(ctx, imageBuild); err != nil {
+		return 0, err
+	}
+
+	ready, since := podReadiness(pod)
+	switch {
+	case ready:
+		return artifactServingCheckInterval, r.setArtifactServingCondition(ctx, imageBuild, metav1.ConditionTrue, artifactServingReasonReady, "Artifact pod is serving")
+	case pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded || time.Since(since) > artifactPodUnreadyTimeout:
+		log.Info("Artifact pod is unhealthy, replacing it", "pod", podName, "phase", pod.Status.Phase, "unreadySince", since)
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to delete unhealthy artifact pod: %w", err)
+		}
+		// the pod is recreated once it is gone
+		return 5 * time.Second, r.setArtifactServingCondition(ctx, imageBuild, metav1.ConditionFalse, artifactServingReasonRecreating,
+			fmt.Sprintf("Artifact pod was not ready since %s and is being replaced", since.UTC().Format(time.RFC3339)))
+	default:
+		return artifactServingCheckInterval, r.setArtifactServingCondition(ctx, imageBuild, metav1.ConditionFalse, artifactServingReasonNotReady,
+			fmt.Sprintf("Artifact pod is not ready since %s", since.UTC().Format(time.RFC3339)))
+	}
+}
+
+// podReadiness reports whether a pod is ready, and otherwise since when it has not been
+func podReadiness(pod *corev1.Pod) (bool, time.Time) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			if c.Status == corev1.ConditionTrue {
+				return true, c.LastTransitionTime.Time
+			}
+			if !c.LastTransitionTime.IsZero() {
+				return false, c.LastTransitionTime.Time
+			}
+		}
+	}
+	return false, pod.CreationTimestamp.Time
+}
+
+// recreateArtifactServing creates the artifact pod again, and the Service and Route exposing it if they are gone
+func (r *ImageBuildReconciler) recreateArtifactServing(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	if err := r.createArtifactPod(ctx, imageBuild); err != nil {
+		return err
+	}
+	if !exposesArtifact(imageBuild) {
+		return nil
+	}
+	return r.createArtifactServingResources(ctx, imageBuild)
+}
+
+// exposesArtifact reports whether the artifact pod of a build is exposed by a Service and Route. Artifacts
+// blocked by the scan policy stay private so the build API can still hand out the scan report.
+func exposesArtifact(imageBuild *automotivev1.ImageBuild) bool {
+	return imageBuild.Spec.ExposeRoute && (imageBuild.Status.Scan == nil || !imageBuild.Status.Scan.Blocked)
+}
+
+// ensureArtifactExposed recreates the Service and the Route, Ingress or HTTPRoute of a running artifact pod
+// if either was deleted
+func (r *ImageBuildReconciler) ensureArtifactExposed(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	if !exposesArtifact(imageBuild) {
+		return nil
+	}
+
+	svcName := fmt.Sprintf("%s-artifact-service", imageBuild.Name)
+	svcErr := r.Get(ctx, types.NamespacedName{Name: svcName, Namespace: imageBuild.Namespace}, &corev1.Service{})
+	if svcErr != nil && !errors.IsNotFound(svcErr) {
+		return fmt.Errorf("error checking artifact Service: %w", svcErr)
+	}
+	exposure, err := r.getExposure(ctx)
+	if err != nil {
+		return err
+	}
+	routeErr := r.Get(ctx, types.NamespacedName{Name: artifactExposureName(imageBuild), Namespace: imageBuild.Namespace}, exposureObject(exposure))
+	if routeErr != nil && !errors.IsNotFound(routeErr) {
+		return fmt.Errorf("error checking artifact %s: %w", exposure.Type, routeErr)
+	}
+	if svcErr == nil && routeErr == nil {
+		return nil
+	}
+
+	r.Log.Info("Artifact Service or its exposure is missing, recreating it", "exposure", exposure.Type,
+		"imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace},
+		"serviceMissing", svcErr != nil, "exposureMissing", routeErr != nil)
+	return r.createArtifactServingResources(ctx, imageBuild)
+}
+
+// setArtifactServingCondition records the ArtifactServing condition, patching the status only when it changes
+func (r *ImageBuildReconciler) setArtifactServingCondition(ctx context.Context, imageBuild *automotivev1.ImageBuild, status metav1.ConditionStatus, reason, message string) error {
+	existing := meta.FindStatusCondition(imageBuild.Status.Conditions, automotivev1.ImageBuildArtifactServing)
+	if existing != nil && existing.Status == status && existing.Reason == reason && existing.Message == message {
+		return nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	meta.SetStatusCondition(&fresh.Status.Conditions, metav1.Condition{
+		Type:               automotivev1.ImageBuildArtifactServing,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: fresh.Generation,
+	})
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return fmt.Errorf("failed to update ArtifactServing condition: %w", err)
+	}
+	imageBuild.Status.Conditions = fresh.Status.Conditions
+	return nil
+}
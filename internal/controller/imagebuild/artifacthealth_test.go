@@ -0,0 +1,130 @@
+package imagebuild
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Artifact serving", func() {
+	ctx := context.Background()
+	podKey := client.ObjectKey{Name: "b-artifact-pod", Namespace: "ns"}
+
+	completed := func() *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns"},
+			Status: automotivev1.ImageBuildStatus{
+				Phase:   "Completed",
+				PVCName: "ws",
+			},
+		}
+	}
+	// artifactPod returns the artifact pod in a phase, ready or not since some time ago
+	artifactPod := func(phase corev1.PodPhase, ready corev1.ConditionStatus, since time.Duration) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              podKey.Name,
+				Namespace:         podKey.Namespace,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodReady,
+					Status:             ready,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+				}},
+			},
+		}
+	}
+	// startingPods makes the pods r creates run right away, as createArtifactPod waits for that
+	startingPods := func(r *ImageBuildReconciler) {
+		r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if pod, ok := obj.(*corev1.Pod); ok {
+					pod.Status.Phase = corev1.PodRunning
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		})
+	}
+	serving := func(r *ImageBuildReconciler) *metav1.Condition {
+		fresh := &automotivev1.ImageBuild{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "b", Namespace: "ns"}, fresh)).To(Succeed())
+		return meta.FindStatusCondition(fresh.Status.Conditions, automotivev1.ImageBuildArtifactServing)
+	}
+
+	It("should keep a ready pod", func() {
+		imageBuild := completed()
+		r := newTestReconciler(imageBuild, artifactPod(corev1.PodRunning, corev1.ConditionTrue, time.Hour))
+
+		next, err := r.checkArtifactServing(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(Equal(artifactServingCheckInterval))
+		Expect(serving(r).Reason).To(Equal(artifactServingReasonReady))
+		Expect(r.Get(ctx, podKey, &corev1.Pod{})).To(Succeed())
+	})
+
+	It("should give an unready pod time to recover", func() {
+		imageBuild := completed()
+		r := newTestReconciler(imageBuild, artifactPod(corev1.PodRunning, corev1.ConditionFalse, time.Minute))
+
+		next, err := r.checkArtifactServing(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(Equal(artifactServingCheckInterval))
+		Expect(serving(r).Reason).To(Equal(artifactServingReasonNotReady))
+		Expect(r.Get(ctx, podKey, &corev1.Pod{})).To(Succeed())
+	})
+
+	DescribeTable("should replace an unhealthy pod",
+		func(pod *corev1.Pod) {
+			imageBuild := completed()
+			r := newTestReconciler(imageBuild, pod)
+			startingPods(r)
+
+			next, err := r.checkArtifactServing(ctx, imageBuild)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(next).To(BeNumerically("<", artifactServingCheckInterval))
+			Expect(errors.IsNotFound(r.Get(ctx, podKey, &corev1.Pod{}))).To(BeTrue())
+			cond := serving(r)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(artifactServingReasonRecreating))
+
+			// the next check finds the pod gone and creates it again
+			next, err = r.checkArtifactServing(ctx, imageBuild)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(next).To(Equal(artifactServingCheckInterval))
+			recreated := &corev1.Pod{}
+			Expect(r.Get(ctx, podKey, recreated)).To(Succeed())
+			Expect(recreated.Spec.ServiceAccountName).To(Equal(HelperServiceAccountName))
+			Expect(recreated.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("ws"))
+			Expect(serving(r).Message).To(Equal("Artifact pod was recreated and is serving"))
+		},
+		Entry("that failed", artifactPod(corev1.PodFailed, corev1.ConditionFalse, time.Minute)),
+		Entry("that exited", artifactPod(corev1.PodSucceeded, corev1.ConditionFalse, time.Minute)),
+		Entry("that stayed unready too long", artifactPod(corev1.PodRunning, corev1.ConditionFalse, 2*artifactPodUnreadyTimeout)),
+	)
+
+	It("should wait for a pod being deleted to be gone", func() {
+		imageBuild := completed()
+		pod := artifactPod(corev1.PodFailed, corev1.ConditionFalse, time.Minute)
+		pod.Finalizers = []string{"example.com/hold"}
+		r := newTestReconciler(imageBuild, pod)
+		Expect(r.Delete(ctx, pod)).To(Succeed())
+
+		next, err := r.checkArtifactServing(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next).To(BeNumerically("<", artifactServingCheckInterval))
+		Expect(serving(r)).To(BeNil())
+	})
+})
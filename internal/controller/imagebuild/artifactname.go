@@ -0,0 +1,38 @@
+package imagebuild
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifactname"
+)
+
+// errArtifactNameRejected marks builds whose artifact name template does not render a file name, so the build
+// fails instead of retrying
+var errArtifactNameRejected = stderrors.New("artifact name rejected")
+
+func isArtifactNameRejected(err error) bool {
+	return stderrors.Is(err, errArtifactNameRejected)
+}
+
+// buildArtifactName returns the name, without extension, the build task writes the build's artifact under
+func buildArtifactName(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) (string, error) {
+	name, err := artifactname.ForBuild(imageBuild, buildConfig)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errArtifactNameRejected, err)
+	}
+	return name, nil
+}
+
+// artifactFileName is the artifact the build task reported, or the name it is expected to have
+func (r *ImageBuildReconciler) artifactFileName(ctx context.Context, imageBuild *automotivev1.ImageBuild) string {
+	if fileName := strings.TrimSpace(imageBuild.Status.ArtifactFileName); fileName != "" {
+		return fileName
+	}
+	// without the AutomotiveDev, the build's own template or the default still names the artifact
+	buildConfig, _ := r.getBuildConfig(ctx)
+	return artifactname.FileName(imageBuild, buildConfig)
+}
@@ -0,0 +1,362 @@
+package imagebuild
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	routev1 "github.com/openshift/api/route/v1"
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// fileServerPort is where nginx serves the workspace; with OAuth it only listens on localhost
+	fileServerPort = 8080
+	// oauthProxyPort is where the oauth-proxy sidecar of an OAuth protected artifact pod listens
+	oauthProxyPort = 8081
+	// artifactProxyPort is where nginx serves downloads to the build API, whatever the Route's auth.
+	// Requests must carry the token kept in the nginx ConfigMap under artifactProxyTokenKey.
+	artifactProxyPort = 8082
+	// artifactHealthPort is where nginx answers the kubelet's probes, outside any auth
+	artifactHealthPort = 8083
+
+	artifactProxyTokenKey    = "proxy-token"
+	artifactProxyTokenHeader = "X-Artifact-Proxy-Token"
+
+	// htpasswdSecretKey is the key of the htpasswd file in a Basic auth secret
+	htpasswdSecretKey = "auth"
+
+	oauthRedirectReferencePrefix = "serviceaccounts.openshift.io/oauth-redirectreference."
+)
+
+// artifactFileNamePattern matches the file names nginx may serve; anything else is never exposed
+var artifactFileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// routeAuth returns how the build's artifact Route is protected, the ImageBuild overriding the default
+func routeAuth(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) (automotivev1.RouteAuth, error) {
+	auth := automotivev1.RouteAuth{Type: automotivev1.RouteAuthNone}
+	if imageBuild.Spec.RouteAuth != nil {
+		auth = *imageBuild.Spec.RouteAuth
+	} else if buildConfig != nil && buildConfig.RouteAuth != nil {
+		auth = *buildConfig.RouteAuth
+	}
+	switch auth.Type {
+	case "", automotivev1.RouteAuthNone:
+		auth.Type = automotivev1.RouteAuthNone
+	case automotivev1.RouteAuthBasic:
+		if auth.HtpasswdSecretRef == "" {
+			return auth, fmt.Errorf("route auth Basic requires htpasswdSecretRef")
+		}
+	case automotivev1.RouteAuthOAuth:
+	default:
+		return auth, fmt.Errorf("unknown route auth type %q", auth.Type)
+	}
+	return auth, nil
+}
+
+// artifactServingPort is the pod port the artifact Service and Route point at
+func artifactServingPort(auth automotivev1.RouteAuth) int {
+	if auth.Type == automotivev1.RouteAuthOAuth {
+		return oauthProxyPort
+	}
+	return fileServerPort
+}
+
+// artifactRouteTLS terminates TLS at the router for protected routes so credentials never travel in clear text
+func artifactRouteTLS(auth automotivev1.RouteAuth) *routev1.TLSConfig {
+	if auth.Type == automotivev1.RouteAuthNone {
+		return nil
+	}
+	return &routev1.TLSConfig{
+		Termination:                   routev1.TLSTerminationEdge,
+		InsecureEdgeTerminationPolicy: routev1.InsecureEdgeTerminationPolicyRedirect,
+	}
+}
+
+// nginxConfig renders the file server configuration. Only the artifact, its compressed -parts
+// directory and the files it can be converted to are served; every other path of the workspace answers
+// 404. A second server on artifactProxyPort serves the same files to the build API holding proxyToken.
+func nginxConfig(artifactFileName string, auth automotivev1.RouteAuth, proxyToken string) string {
+	served := artifactFileNamePattern.MatchString(artifactFileName)
+	var converted strings.Builder
+	for _, name := range conversionFileNames(artifactFileName) {
+		fmt.Fprintf(&converted, "\n    location = /%s {\n        try_files $uri =404;\n    }\n", name)
+	}
+
+	var b strings.Builder
+	b.WriteString("server {\n")
+	if auth.Type == automotivev1.RouteAuthOAuth {
+		// only the oauth-proxy sidecar may reach nginx
+		fmt.Fprintf(&b, "    listen 127.0.0.1:%d;\n", fileServerPort)
+	} else {
+		fmt.Fprintf(&b, "    listen %d;\n", fileServerPort)
+	}
+	b.WriteString(`    server_name localhost;
+
+    root /workspace/shared;
+    sendfile on;
+    tcp_nopush on;
+    add_header Cache-Control "no-store" always;
+    add_header X-Content-Type-Options nosniff always;
+`)
+	if auth.Type == automotivev1.RouteAuthBasic {
+		fmt.Fprintf(&b, "    auth_basic \"Artifacts\";\n    auth_basic_user_file /etc/nginx/auth/%s;\n", htpasswdSecretKey)
+	}
+	if served {
+		fmt.Fprintf(&b, `
+    location = /%[1]s {
+        try_files $uri =404;
+    }
+
+    location /%[1]s-parts/ {
+        autoindex on;
+        autoindex_exact_size off;
+        autoindex_localtime on;
+        try_files $uri $uri/ =404;
+    }
+`, artifactFileName)
+		b.WriteString(converted.String())
+	}
+	b.WriteString(`
+    location / {
+        return 404;
+    }
+}
+`)
+
+	fmt.Fprintf(&b, `
+server {
+    listen %d;
+    server_name _;
+
+    root /workspace/shared;
+    sendfile on;
+    tcp_nopush on;
+
+    if ($http_%s != "%s") {
+        return 403;
+    }
+`, artifactProxyPort, strings.ToLower(strings.ReplaceAll(artifactProxyTokenHeader, "-", "_")), proxyToken)
+	if served {
+		fmt.Fprintf(&b, `
+    location = /%[1]s {
+        try_files $uri =404;
+    }
+
+    location /%[1]s-parts/ {
+        try_files $uri =404;
+    }
+`, artifactFileName)
+		b.WriteString(converted.String())
+	}
+	b.WriteString(`
+    location / {
+        return 404;
+    }
+}
+`)
+
+	// a workspace volume that went away makes the pod unready, so the controller replaces it
+	fmt.Fprintf(&b, `
+server {
+    listen %d;
+    server_name _;
+    access_log off;
+
+    location = /healthz {
+        if (!-d /workspace/shared) {
+            return 503;
+        }
+        return 200 "ok\n";
+    }
+
+    location / {
+        return 404;
+    }
+}
+`, artifactHealthPort)
+	return b.String()
+}
+
+// newArtifactProxyToken returns a random token guarding the artifact pod's build API server
+func newArtifactProxyToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate artifact proxy token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// oauthProxyContainer is the sidecar of an OAuth protected artifact pod. It lets in users who can
+// get the ImageBuild and forwards them to nginx on localhost.
+func oauthProxyContainer(imageBuild *automotivev1.ImageBuild, image string) (corev1.Container, error) {
+	sar, err := json.Marshal(map[string]string{
+		"group":        automotivev1.GroupVersion.Group,
+		"resource":     "imagebuilds",
+		"resourceName": imageBuild.Name,
+		"namespace":    imageBuild.Namespace,
+		"verb":         "get",
+	})
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	return corev1.Container{
+		Name:  "oauth-proxy",
+		Image: image,
+		Args: []string{
+			"--provider=openshift",
+			"--https-address=",
+			fmt.Sprintf("--http-address=:%d", oauthProxyPort),
+			fmt.Sprintf("--upstream=http://127.0.0.1:%d", fileServerPort),
+			"--openshift-service-account=" + HelperServiceAccountName,
+			"--openshift-sar=" + string(sar),
+			"--cookie-secret=$(COOKIE_SECRET)",
+			"--email-domain=*",
+			"--skip-provider-button=true",
+			"--upstream-timeout=0",
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name: "COOKIE_SECRET",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: artifactProxySecretName(imageBuild)},
+						Key:                  "cookie-secret",
+					},
+				},
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: oauthProxyPort,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		LivenessProbe:  artifactProbe("/oauth/healthz", oauthProxyPort),
+		ReadinessProbe: artifactProbe("/oauth/healthz", oauthProxyPort),
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("32Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("128Mi"),
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: ptr.To(false),
+		},
+	}, nil
+}
+
+// artifactProbe checks a container of the artifact pod over HTTP; a pod failing it for a minute is
+// restarted by the kubelet, and replaced by the controller if it stays unready
+func artifactProbe(path string, port int) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: path,
+				Port: intstr.FromInt(port),
+			},
+		},
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       15,
+		TimeoutSeconds:      5,
+		FailureThreshold:    4,
+	}
+}
+
+func artifactProxySecretName(imageBuild *automotivev1.ImageBuild) string {
+	return fmt.Sprintf("%s-artifact-proxy", imageBuild.Name)
+}
+
+// ensureOAuthProxy creates the cookie secret of the build's oauth-proxy and registers the artifact
+// Route as an OAuth redirect of the build service account, which the proxy logs users in as
+func (r *ImageBuildReconciler) ensureOAuthProxy(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	secretName := artifactProxySecretName(imageBuild)
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: imageBuild.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		cookie := make([]byte, 16)
+		if _, err := rand.Read(cookie); err != nil {
+			return fmt.Errorf("failed to generate oauth-proxy cookie secret: %w", err)
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      secretName,
+				Namespace: imageBuild.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by":                    "automotive-dev-operator",
+					"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion:         imageBuild.APIVersion,
+						Kind:               imageBuild.Kind,
+						Name:               imageBuild.Name,
+						UID:                imageBuild.UID,
+						Controller:         ptr.To(true),
+						BlockOwnerDeletion: ptr.To(true),
+					},
+				},
+			},
+			StringData: map[string]string{"cookie-secret": hex.EncodeToString(cookie)},
+		}
+		if err := r.Create(ctx, secret); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create oauth-proxy secret: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get oauth-proxy secret: %w", err)
+	}
+
+	reference, err := json.Marshal(map[string]any{
+		"kind":       "OAuthRedirectReference",
+		"apiVersion": "v1",
+		"reference":  map[string]string{"kind": "Route", "name": fmt.Sprintf("%s-artifacts", imageBuild.Name)},
+	})
+	if err != nil {
+		return err
+	}
+	return r.setHelperServiceAccountAnnotation(ctx, imageBuild.Namespace, oauthRedirectReferencePrefix+imageBuild.Name, string(reference))
+}
+
+// setHelperServiceAccountAnnotation sets an annotation of the namespace's helper service account, which the
+// oauth-proxy of artifact pods runs as, or removes it when value is empty
+func (r *ImageBuildReconciler) setHelperServiceAccountAnnotation(ctx context.Context, namespace, key, value string) error {
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Name: HelperServiceAccountName, Namespace: namespace}, sa); err != nil {
+		if errors.IsNotFound(err) && value == "" {
+			return nil
+		}
+		return fmt.Errorf("failed to get helper service account: %w", err)
+	}
+	if sa.Annotations[key] == value {
+		return nil
+	}
+	patch := client.MergeFrom(sa.DeepCopy())
+	if value == "" {
+		delete(sa.Annotations, key)
+	} else {
+		if sa.Annotations == nil {
+			sa.Annotations = map[string]string{}
+		}
+		sa.Annotations[key] = value
+	}
+	if err := r.Patch(ctx, sa, patch); err != nil {
+		return fmt.Errorf("failed to annotate helper service account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package imagebuild
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	routev1 "github.com/openshift/api/route/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Artifact route auth", func() {
+	none := automotivev1.RouteAuth{Type: automotivev1.RouteAuthNone}
+	basic := automotivev1.RouteAuth{Type: automotivev1.RouteAuthBasic, HtpasswdSecretRef: "htpasswd"}
+	oauth := automotivev1.RouteAuth{Type: automotivev1.RouteAuthOAuth}
+
+	imageBuild := func(auth *automotivev1.RouteAuth) *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "radio", Namespace: "team-a", UID: "uid-1"},
+			Spec:       automotivev1.ImageBuildSpec{RouteAuth: auth},
+		}
+	}
+
+	DescribeTable("routeAuth",
+		func(build, defaults *automotivev1.RouteAuth, want automotivev1.RouteAuth, problem string) {
+			var buildConfig *automotivev1.BuildConfig
+			if defaults != nil {
+				buildConfig = &automotivev1.BuildConfig{RouteAuth: defaults}
+			}
+			auth, err := routeAuth(imageBuild(build), buildConfig)
+			if problem != "" {
+				Expect(err).To(MatchError(ContainSubstring(problem)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(auth).To(Equal(want))
+		},
+		Entry("is None without either", nil, nil, none, ""),
+		Entry("defaults to the BuildConfig's", nil, &oauth, oauth, ""),
+		Entry("lets the build override the default", &basic, &oauth, basic, ""),
+		Entry("treats an empty type as None", &automotivev1.RouteAuth{}, &oauth, none, ""),
+		Entry("requires a secret for Basic", &automotivev1.RouteAuth{Type: automotivev1.RouteAuthBasic}, nil, none,
+			"requires htpasswdSecretRef"),
+		Entry("refuses unknown types", &automotivev1.RouteAuth{Type: "Digest"}, nil, none, `unknown route auth type "Digest"`),
+	)
+
+	Describe("nginxConfig", func() {
+		// server returns the server block of config listening on listen
+		server := func(config, listen string) string {
+			for _, block := range strings.Split(config, "\nserver {") {
+				if strings.Contains(block, "listen "+listen+";") {
+					return block
+				}
+			}
+			Fail("no server listens on " + listen)
+			return ""
+		}
+
+		It("should serve the artifact, its parts and conversions to everyone without auth", func() {
+			config := nginxConfig("radio.raw", none, "tok")
+			public := server(config, "8080")
+			Expect(public).To(ContainSubstring("location = /radio.raw {"))
+			Expect(public).To(ContainSubstring("location /radio.raw-parts/ {"))
+			Expect(public).To(ContainSubstring("location = /radio.qcow2 {"))
+			Expect(public).To(ContainSubstring("location / {\n        return 404;"))
+			Expect(public).NotTo(ContainSubstring("auth_basic"))
+		})
+
+		It("should check the htpasswd file with Basic auth", func() {
+			public := server(nginxConfig("radio.raw", basic, "tok"), "8080")
+			Expect(public).To(ContainSubstring(`auth_basic "Artifacts";`))
+			Expect(public).To(ContainSubstring("auth_basic_user_file /etc/nginx/auth/auth;"))
+		})
+
+		It("should only listen on localhost behind the oauth-proxy", func() {
+			config := nginxConfig("radio.raw", oauth, "tok")
+			Expect(config).To(ContainSubstring("listen 127.0.0.1:8080;"))
+			Expect(config).NotTo(ContainSubstring("listen 8080;"))
+			Expect(config).NotTo(ContainSubstring("auth_basic"))
+		})
+
+		It("should require the proxy token on the build API's server whatever the auth", func() {
+			for _, auth := range []automotivev1.RouteAuth{none, basic, oauth} {
+				proxy := server(nginxConfig("radio.raw", auth, "s3cret"), "8082")
+				Expect(proxy).To(ContainSubstring("if ($http_x_artifact_proxy_token != \"s3cret\") {\n        return 403;"))
+				Expect(proxy).To(ContainSubstring("location = /radio.raw {"))
+				Expect(proxy).NotTo(ContainSubstring("auth_basic"))
+			}
+		})
+
+		It("should answer probes on the health port", func() {
+			health := server(nginxConfig("radio.raw", oauth, "tok"), "8083")
+			Expect(health).To(ContainSubstring("location = /healthz {"))
+		})
+
+		It("should not serve artifact names it cannot quote safely", func() {
+			config := nginxConfig("../etc/passwd", none, "tok")
+			Expect(config).NotTo(ContainSubstring("passwd"))
+			Expect(config).To(ContainSubstring("location / {\n        return 404;"))
+		})
+	})
+
+	Describe("oauthProxyContainer", func() {
+		It("should let in users who can get the ImageBuild and forward them to nginx", func() {
+			container, err := oauthProxyContainer(imageBuild(&oauth), "registry.example.com/oauth-proxy:1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.Image).To(Equal("registry.example.com/oauth-proxy:1"))
+			Expect(container.Args).To(ContainElements(
+				"--provider=openshift",
+				"--http-address=:8081",
+				"--upstream=http://127.0.0.1:8080",
+				"--openshift-service-account="+HelperServiceAccountName,
+				"--cookie-secret=$(COOKIE_SECRET)",
+			))
+
+			var sar map[string]string
+			for _, arg := range container.Args {
+				if value, ok := strings.CutPrefix(arg, "--openshift-sar="); ok {
+					Expect(json.Unmarshal([]byte(value), &sar)).To(Succeed())
+				}
+			}
+			Expect(sar).To(Equal(map[string]string{
+				"group":        automotivev1.GroupVersion.Group,
+				"resource":     "imagebuilds",
+				"resourceName": "radio",
+				"namespace":    "team-a",
+				"verb":         "get",
+			}))
+
+			Expect(container.Env).To(HaveLen(1))
+			Expect(container.Env[0].ValueFrom.SecretKeyRef.Name).To(Equal("radio-artifact-proxy"))
+			Expect(container.Env[0].ValueFrom.SecretKeyRef.Key).To(Equal("cookie-secret"))
+			Expect(container.Ports[0].ContainerPort).To(Equal(int32(oauthProxyPort)))
+		})
+	})
+
+	DescribeTable("the artifact Route",
+		func(auth automotivev1.RouteAuth, port int, tls bool) {
+			Expect(artifactServingPort(auth)).To(Equal(port))
+			exposed, err := newArtifactExposure(imageBuild(&auth), &automotivev1.Exposure{Type: automotivev1.ExposureRoute},
+				"radio-artifact-service", artifactServingPort(auth), auth, nil)
+			Expect(err).NotTo(HaveOccurred())
+			route := exposed.(*routev1.Route)
+			Expect(route.Spec.Port.TargetPort).To(Equal(intstr.FromInt(port)))
+			if tls {
+				Expect(route.Spec.TLS).NotTo(BeNil())
+				Expect(route.Spec.TLS.Termination).To(Equal(routev1.TLSTerminationEdge))
+			} else {
+				Expect(route.Spec.TLS).To(BeNil())
+			}
+		},
+		Entry("targets nginx without auth", none, fileServerPort, false),
+		Entry("targets nginx, over TLS, with Basic auth", basic, fileServerPort, true),
+		Entry("targets the oauth-proxy, over TLS, with OAuth", oauth, oauthProxyPort, true),
+	)
+
+	Describe("ensureOAuthProxy", func() {
+		ctx := context.Background()
+
+		It("should create the cookie secret once and register the Route as a redirect of the helper account", func() {
+			build := imageBuild(&oauth)
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: HelperServiceAccountName, Namespace: "team-a"}}
+			r := newTestReconciler(build, sa)
+
+			Expect(r.ensureOAuthProxy(ctx, build)).To(Succeed())
+			secret := &corev1.Secret{}
+			key := types.NamespacedName{Name: "radio-artifact-proxy", Namespace: "team-a"}
+			Expect(r.Get(ctx, key, secret)).To(Succeed())
+			cookie := secret.StringData["cookie-secret"] + string(secret.Data["cookie-secret"])
+			Expect(cookie).To(HaveLen(32))
+			Expect(secret.OwnerReferences).To(HaveLen(1))
+			Expect(secret.OwnerReferences[0].UID).To(Equal(build.UID))
+
+			Expect(r.Get(ctx, types.NamespacedName{Name: HelperServiceAccountName, Namespace: "team-a"}, sa)).To(Succeed())
+			var reference struct {
+				Kind      string            `json:"kind"`
+				Reference map[string]string `json:"reference"`
+			}
+			Expect(json.Unmarshal([]byte(sa.Annotations[oauthRedirectReferencePrefix+"radio"]), &reference)).To(Succeed())
+			Expect(reference.Kind).To(Equal("OAuthRedirectReference"))
+			Expect(reference.Reference).To(Equal(map[string]string{"kind": "Route", "name": "radio-artifacts"}))
+
+			// a second reconcile keeps the cookie, so signed in users stay signed in
+			Expect(r.ensureOAuthProxy(ctx, build)).To(Succeed())
+			again := &corev1.Secret{}
+			Expect(r.Get(ctx, key, again)).To(Succeed())
+			Expect(again.StringData["cookie-secret"] + string(again.Data["cookie-secret"])).To(Equal(cookie))
+		})
+	})
+})
@@ -0,0 +1,108 @@
+package imagebuild
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// cosignPublicKeyKey is the key of the cosign public key in BuilderImagePolicy.CosignPublicKeySecretRef
+const cosignPublicKeyKey = "cosign.pub"
+
+// errBuilderImageRejected marks builder images that may never run, so the build fails instead of retrying
+var errBuilderImageRejected = stderrors.New("builder image rejected")
+
+func isBuilderImageRejected(err error) bool {
+	return stderrors.Is(err, errBuilderImageRejected)
+}
+
+// errStepImagesRejected marks BuildConfigs whose step images are invalid or not pinned as required, so builds
+// fail instead of running other images than the BuildConfig allows
+var errStepImagesRejected = stderrors.New("step images rejected")
+
+func isStepImagesRejected(err error) bool {
+	return stderrors.Is(err, errStepImagesRejected)
+}
+
+// resolveBuilderImage returns the automotive-image-builder image a build runs. Unless pinning is disabled the
+// image is resolved to a digest once, recorded in the ImageBuild status and reused for any later TaskRun, and
+// verified against the configured cosign key.
+func (r *ImageBuildReconciler) resolveBuilderImage(ctx context.Context, imageBuild *automotivev1.ImageBuild,
+	buildConfig *automotivev1.BuildConfig) (string, error) {
+	image := imageBuild.Spec.AutomotiveImageBuilder
+	if image == "" {
+		image = tasks.Images(buildConfig).Builder
+	}
+
+	policy := automotivev1.BuilderImagePolicy{}
+	if buildConfig != nil && buildConfig.BuilderImage != nil {
+		policy = *buildConfig.BuilderImage
+	}
+	// a verified image is always run by digest, or the tag could move between verification and pull
+	verify := policy.CosignPublicKeySecretRef != ""
+	if policy.DisableDigestPinning && !verify {
+		return image, nil
+	}
+
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errBuilderImageRejected, err)
+	}
+
+	rc := &registry.Client{HTTPClient: r.HTTPClient, Host: ref.APIHost()}
+	if policy.PullSecretRef != "" {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: policy.PullSecretRef, Namespace: OperatorNamespace}, secret); err != nil {
+			return "", fmt.Errorf("failed to get builder image pull secret %s: %w", policy.PullSecretRef, err)
+		}
+		if rc.Username, rc.Password, err = registry.DockerConfigCredentials(secret.Data[corev1.DockerConfigJsonKey], ref.Host); err != nil {
+			return "", err
+		}
+	}
+
+	digest := imageBuild.Status.BuilderImageDigest
+	if digest == "" {
+		digest = ref.Digest
+	}
+	if digest == "" {
+		if digest, err = rc.ResolveDigest(ctx, ref.Repository, ref.Tag); err != nil {
+			return "", fmt.Errorf("failed to resolve builder image %s: %w", image, err)
+		}
+	}
+
+	if verify {
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Name: policy.CosignPublicKeySecretRef, Namespace: OperatorNamespace}, secret); err != nil {
+			return "", fmt.Errorf("failed to get cosign public key secret %s: %w", policy.CosignPublicKeySecretRef, err)
+		}
+		key, err := registry.ParsePublicKey(secret.Data[cosignPublicKeyKey])
+		if err != nil {
+			return "", fmt.Errorf("%w: secret %s: %v", errBuilderImageRejected, policy.CosignPublicKeySecretRef, err)
+		}
+		if err := rc.VerifyCosignSignature(ctx, ref.Repository, digest, key); err != nil {
+			return "", fmt.Errorf("%w: %v", errBuilderImageRejected, err)
+		}
+	}
+
+	if imageBuild.Status.BuilderImageDigest != digest {
+		fresh := &automotivev1.ImageBuild{}
+		if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+			return "", fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+		}
+		patch := client.MergeFrom(fresh.DeepCopy())
+		fresh.Status.BuilderImageDigest = digest
+		if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+			return "", fmt.Errorf("failed to record builder image digest: %w", err)
+		}
+		imageBuild.Status.BuilderImageDigest = digest
+	}
+
+	return ref.WithDigest(digest), nil
+}
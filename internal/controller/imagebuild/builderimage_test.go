@@ -0,0 +1,137 @@
+package imagebuild
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+var _ = Describe("Builder image", func() {
+	ctx := context.Background()
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	build := func(image string) *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns"},
+			Spec:       automotivev1.ImageBuildSpec{AutomotiveImageBuilder: image},
+		}
+	}
+	// withBuilder returns a BuildConfig with policy that overrides the builder image unless image is empty
+	withBuilder := func(image string, policy *automotivev1.BuilderImagePolicy) *automotivev1.BuildConfig {
+		buildConfig := &automotivev1.BuildConfig{BuilderImage: policy}
+		if image != "" {
+			buildConfig.Images = &automotivev1.ImageOverrides{Builder: image}
+		}
+		return buildConfig
+	}
+
+	DescribeTable("which image a build runs",
+		func(specImage, configImage, expected string) {
+			imageBuild := build(specImage)
+			buildConfig := withBuilder(configImage, &automotivev1.BuilderImagePolicy{DisableDigestPinning: true})
+
+			image, err := newTestReconciler(imageBuild).resolveBuilderImage(ctx, imageBuild, buildConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image).To(Equal(expected))
+		},
+		Entry("the spec's image over the AutomotiveDev's",
+			"quay.io/me/aib:dev", "quay.io/org/aib:2.0", "quay.io/me/aib:dev"),
+		Entry("the spec's image over the default",
+			"quay.io/me/aib:dev", "", "quay.io/me/aib:dev"),
+		Entry("the AutomotiveDev's image over the default",
+			"", "quay.io/org/aib:2.0", "quay.io/org/aib:2.0"),
+		Entry("the default otherwise",
+			"", "", tasks.AutomotiveImageBuilder),
+	)
+
+	It("should run the default image by its recorded digest without a BuildConfig", func() {
+		imageBuild := build("")
+		imageBuild.Status.BuilderImageDigest = digest
+
+		image, err := newTestReconciler(imageBuild).resolveBuilderImage(ctx, imageBuild, nil)
+		Expect(err).NotTo(HaveOccurred())
+		ref, err := registry.ParseReference(tasks.AutomotiveImageBuilder)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(image).To(Equal(ref.WithDigest(digest)))
+	})
+
+	Context("with digest pinning", func() {
+		var (
+			server    *httptest.Server
+			host      string
+			requested []string
+		)
+
+		BeforeEach(func() {
+			requested = nil
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = append(requested, r.URL.Path)
+				w.Header().Set("Content-Type", "application/vnd.oci.image.index.v1+json")
+				w.Header().Set("Docker-Content-Digest", digest)
+				_, _ = w.Write([]byte(`{"schemaVersion":2}`))
+			}))
+			DeferCleanup(server.Close)
+			host = strings.TrimPrefix(server.URL, "https://")
+		})
+
+		resolve := func(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) (string, error) {
+			r := newTestReconciler(imageBuild)
+			r.HTTPClient = server.Client()
+			return r.resolveBuilderImage(ctx, imageBuild, buildConfig)
+		}
+
+		DescribeTable("which image is resolved",
+			func(specRepo, configRepo, expectedRepo string) {
+				specImage, configImage := "", ""
+				if specRepo != "" {
+					specImage = host + "/" + specRepo + ":dev"
+				}
+				if configRepo != "" {
+					configImage = host + "/" + configRepo + ":2.0"
+				}
+
+				image, err := resolve(build(specImage), withBuilder(configImage, nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(image).To(Equal(host + "/" + expectedRepo + "@" + digest))
+				Expect(requested).To(HaveLen(1))
+				Expect(requested[0]).To(HavePrefix("/v2/" + expectedRepo + "/manifests/"))
+			},
+			Entry("the spec's image over the AutomotiveDev's", "me/aib", "org/aib", "me/aib"),
+			Entry("the AutomotiveDev's image without one in the spec", "", "org/aib", "org/aib"),
+		)
+
+		It("should record the digest and reuse it for later runs", func() {
+			imageBuild := build(host + "/me/aib:dev")
+			r := newTestReconciler(imageBuild)
+			r.HTTPClient = server.Client()
+
+			_, err := r.resolveBuilderImage(ctx, imageBuild, nil)
+			Expect(err).NotTo(HaveOccurred())
+			stored := &automotivev1.ImageBuild{}
+			Expect(r.Get(ctx, client.ObjectKeyFromObject(imageBuild), stored)).To(Succeed())
+			Expect(stored.Status.BuilderImageDigest).To(Equal(digest))
+
+			image, err := r.resolveBuilderImage(ctx, stored, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image).To(Equal(host + "/me/aib@" + digest))
+			Expect(requested).To(HaveLen(1))
+		})
+
+		It("should not resolve an image the spec already pins", func() {
+			image, err := resolve(build(host+"/me/aib@"+digest), withBuilder(host+"/org/aib:2.0", nil))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image).To(Equal(host + "/me/aib@" + digest))
+			Expect(requested).To(BeEmpty())
+		})
+	})
+})
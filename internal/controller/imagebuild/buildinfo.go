@@ -0,0 +1,43 @@
+package imagebuild
+
+import (
+	"fmt"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// buildInfoContent renders the part of /etc/automotive-build-info the controller knows, or "" when the
+// build does not ask for the file. The build task appends the manifest hash and the build time.
+func buildInfoContent(imageBuild *automotivev1.ImageBuild, builderImage string) string {
+	if imageBuild.Spec.BuildInfo == nil || !imageBuild.Spec.BuildInfo.Enabled {
+		return ""
+	}
+	fields := [][2]string{
+		{"BUILD_NAME", imageBuild.Name},
+		{"BUILD_NAMESPACE", imageBuild.Namespace},
+		{"BUILD_UID", string(imageBuild.UID)},
+		{"GIT_REF", imageBuild.Spec.BuildInfo.GitRef},
+		{"DISTRO", imageBuild.Spec.Distro},
+		{"TARGET", imageBuild.Spec.Target},
+		{"ARCH", imageBuild.Spec.Architecture},
+		{"BUILDER_IMAGE", builderImage},
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s=%s\n", f[0], osReleaseQuote(f[1]))
+	}
+	return b.String()
+}
+
+// osReleaseQuote quotes a value the way os-release(5) expects, so the file can be sourced by a shell
+func osReleaseQuote(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, v)
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(v) + `"`
+}
@@ -0,0 +1,93 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// cancelRequestedAnnotation is set by the build API to the user who asked to cancel a build
+const cancelRequestedAnnotation = "automotive.sdv.cloud.redhat.com/cancel-requested-by"
+
+// cancelRequested reports whether a build that has not finished was asked to stop
+func cancelRequested(imageBuild *automotivev1.ImageBuild) bool {
+	if _, ok := imageBuild.Annotations[cancelRequestedAnnotation]; !ok {
+		return false
+	}
+	return imageBuild.Status.Phase != "Completed" && imageBuild.Status.Phase != "Failed"
+}
+
+// cancelBuild stops the build's upload server and TaskRun or PipelineRun and fails the build
+func (r *ImageBuildReconciler) cancelBuild(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
+	if err := r.stopBuild(ctx, imageBuild); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	message := "Build cancelled"
+	if by := imageBuild.Annotations[cancelRequestedAnnotation]; by != "" {
+		message = fmt.Sprintf("Build cancelled by %s", by)
+	}
+	if err := r.updateStatus(ctx, imageBuild, "Failed", message); err != nil {
+		return r.Requeue.Retry("status"), nil
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// stopBuild stops the build's upload server and every TaskRun or PipelineRun it started
+func (r *ImageBuildReconciler) stopBuild(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	log := r.buildLog(imageBuild)
+
+	if imageBuild.Status.Phase == "Uploading" {
+		if err := r.shutdownUploadPod(ctx, imageBuild); err != nil {
+			return err
+		}
+	}
+
+	// the TaskRun may not be recorded in the status yet, so every TaskRun of the build is stopped
+	taskRuns := &tektonv1.TaskRunList{}
+	if err := r.List(ctx, taskRuns,
+		client.InNamespace(imageBuild.Namespace),
+		client.MatchingLabels{"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list task runs: %w", err)
+	}
+	for i := range taskRuns.Items {
+		taskRun := &taskRuns.Items[i]
+		if isTaskRunCompleted(taskRun) || taskRun.IsCancelled() {
+			continue
+		}
+		patch := client.MergeFrom(taskRun.DeepCopy())
+		taskRun.Spec.Status = tektonv1.TaskRunSpecStatusCancelled
+		if err := r.Patch(ctx, taskRun, patch); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to cancel TaskRun: %w", err)
+		}
+		log.Info("Cancelled TaskRun", "taskRun", taskRun.Name)
+	}
+
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns,
+		client.InNamespace(imageBuild.Namespace),
+		client.MatchingLabels{"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name},
+	); err != nil {
+		return fmt.Errorf("failed to list pipeline runs: %w", err)
+	}
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if pipelineRun.Status.CompletionTime != nil || pipelineRun.IsCancelled() {
+			continue
+		}
+		patch := client.MergeFrom(pipelineRun.DeepCopy())
+		pipelineRun.Spec.Status = tektonv1.PipelineRunSpecStatusCancelled
+		if err := r.Patch(ctx, pipelineRun, patch); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to cancel PipelineRun: %w", err)
+		}
+		log.Info("Cancelled PipelineRun", "pipelineRun", pipelineRun.Name)
+	}
+	return nil
+}
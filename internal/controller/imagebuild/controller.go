@@ -3,26 +3,39 @@ package imagebuild
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	routev1 "github.com/openshift/api/route/v1"
 	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/artifacttype"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/requeue"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
 	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/uploadprogress"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/pkg/tektongen"
 	pod "github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 )
 
 const (
@@ -34,15 +47,23 @@ type ImageBuildReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 	Log    logr.Logger
+	// HTTPClient is used to resolve builder images in their registry; http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Clientset reads the logs of the results helper pod; without it only TaskRun results are used
+	Clientset kubernetes.Interface
+	// Requeue paces the requeues of builds waiting on their pods, runs and status updates
+	Requeue requeue.Pacer
 }
 
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=imagebuilds,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=imagebuilds/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=automotive.sdv.cloud.redhat.com,resources=imagebuilds/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=list
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,verbs=get;list;watch;create;update;patch;delete;use
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
@@ -51,17 +72,21 @@ type ImageBuildReconciler struct {
 // +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 // +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=pods/proxy,verbs=get
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=route.openshift.io,resources=routes,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile ImageBuild
 func (r *ImageBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("imagebuild", req.NamespacedName)
-
 	imageBuild := &automotivev1.ImageBuild{}
 	if err := r.Get(ctx, req.NamespacedName, imageBuild); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	log := r.buildLog(imageBuild)
+
+	if cancelRequested(imageBuild) {
+		return r.cancelBuild(ctx, imageBuild)
+	}
 
 	switch imageBuild.Status.Phase {
 	case "":
@@ -73,26 +98,48 @@ func (r *ImageBuildReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	case "Completed":
 		return r.handleCompletedState(ctx, imageBuild)
 	case "Failed":
-		return ctrl.Result{}, nil
+		return r.handleFailedState(ctx, imageBuild)
 	default:
 		log.Info("Unknown phase", "phase", imageBuild.Status.Phase)
 		return ctrl.Result{}, nil
 	}
 }
 
+// correlationEnv passes the IDs of the request that created an ImageBuild to the steps of its build, which
+// log them; TRACEPARENT is the variable OpenTelemetry tooling reads
+func correlationEnv(imageBuild *automotivev1.ImageBuild) []corev1.EnvVar {
+	ids := correlation.FromObject(imageBuild)
+	var env []corev1.EnvVar
+	if ids.RequestID != "" {
+		env = append(env, corev1.EnvVar{Name: "REQUEST_ID", Value: ids.RequestID})
+	}
+	if ids.Traceparent != "" {
+		env = append(env, corev1.EnvVar{Name: "TRACEPARENT", Value: ids.Traceparent})
+	}
+	return env
+}
+
+// buildLog returns the logger for an ImageBuild, with the IDs of the request that created it
+func (r *ImageBuildReconciler) buildLog(imageBuild *automotivev1.ImageBuild) logr.Logger {
+	return r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}).
+		WithValues(correlation.FromObject(imageBuild).LogValues()...)
+}
+
 func (r *ImageBuildReconciler) handleInitialState(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
 	if imageBuild.Spec.InputFilesServer {
-		if err := r.createUploadPod(ctx, imageBuild); err != nil {
+		if err := r.createUploadPod(ctx, imageBuild); isWorkspaceStorageExceeded(err) {
+			return r.waitForWorkspaceStorage(ctx, imageBuild, err)
+		} else if err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to create upload server: %w", err)
 		}
-		if err := r.updateStatus(ctx, imageBuild, "Uploading", "Waiting for file uploads"); err != nil {
-			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		if err := r.updateStatus(ctx, imageBuild, "Uploading", uploadWaitMessage); err != nil {
+			return r.Requeue.Retry("status"), nil
 		}
 		return ctrl.Result{Requeue: true}, nil
 	}
 
 	if err := r.updateStatus(ctx, imageBuild, "Building", "Build started"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
 	return ctrl.Result{Requeue: true}, nil
 }
@@ -101,26 +148,50 @@ func (r *ImageBuildReconciler) handleUploadingState(ctx context.Context, imageBu
 	uploadsComplete := imageBuild.Annotations != nil &&
 		imageBuild.Annotations["automotive.sdv.cloud.redhat.com/uploads-complete"] == "true"
 
+	if result, unbound, err := r.checkWorkspaceBound(ctx, imageBuild); unbound || err != nil {
+		return result, err
+	}
 	if !uploadsComplete {
-		return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+		if message := uploadStatusMessage(imageBuild); message != imageBuild.Status.Message {
+			if err := r.updateStatus(ctx, imageBuild, "Uploading", message); err != nil {
+				return r.Requeue.Retry("status"), nil
+			}
+		}
+		return r.Requeue.Poll("uploads"), nil
 	}
 
 	if err := r.shutdownUploadPod(ctx, imageBuild); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, fmt.Errorf("failed to shutdown upload server: %w", err)
+		return ctrl.Result{}, fmt.Errorf("failed to shutdown upload server: %w", err)
 	}
 
 	if err := r.updateStatus(ctx, imageBuild, "Building", "Build started"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
 	return ctrl.Result{Requeue: true}, nil
 }
 
+// uploadWaitMessage is the status message of a build waiting for its uploads before any progress was recorded
+const uploadWaitMessage = "Waiting for file uploads"
+
+// uploadStatusMessage describes the progress the build API recorded for the uploads of a build, so a stalled
+// upload can be told from a slow one
+func uploadStatusMessage(imageBuild *automotivev1.ImageBuild) string {
+	progress, ok := uploadprogress.FromAnnotations(imageBuild.Annotations)
+	if !ok {
+		return uploadWaitMessage
+	}
+	return fmt.Sprintf("%s: %s, last received %s", uploadWaitMessage, progress, progress.UpdatedAt.Format(time.RFC3339))
+}
+
 func (r *ImageBuildReconciler) handleBuildingState(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
-	if imageBuild.Status.TaskRunName != "" {
+	if imageBuild.Status.TaskRunName != "" || imageBuild.Status.PipelineRunName != "" {
 		return r.checkBuildProgress(ctx, imageBuild)
 	}
+	if imageBuild.Spec.PipelineRef != nil {
+		return r.adoptPipelineRun(ctx, imageBuild)
+	}
 
 	taskRunList := &tektonv1.TaskRunList{}
 	if err := r.List(ctx, taskRunList,
@@ -141,7 +212,7 @@ func (r *ImageBuildReconciler) handleBuildingState(ctx context.Context, imageBui
 				Namespace: imageBuild.Namespace,
 			}, latestImageBuild); err != nil {
 				log.Error(err, "Failed to get latest ImageBuild")
-				return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+				return r.Requeue.Retry("status"), nil
 			}
 
 			patch := client.MergeFrom(latestImageBuild.DeepCopy())
@@ -149,10 +220,10 @@ func (r *ImageBuildReconciler) handleBuildingState(ctx context.Context, imageBui
 
 			if err := r.Status().Patch(ctx, latestImageBuild, patch); err != nil {
 				log.Error(err, "Failed to patch ImageBuild with existing TaskRun name")
-				return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+				return r.Requeue.Retry("status"), nil
 			}
 
-			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
+			return r.Requeue.Sync("build"), nil
 		}
 	}
 
@@ -164,7 +235,7 @@ func (r *ImageBuildReconciler) handleCompletedState(ctx context.Context, imageBu
 		return ctrl.Result{}, nil
 	}
 
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	expiryHours := int32(24)
 	if imageBuild.Spec.ServeExpiryHours > 0 {
@@ -172,25 +243,74 @@ func (r *ImageBuildReconciler) handleCompletedState(ctx context.Context, imageBu
 	}
 
 	if imageBuild.Status.CompletionTime == nil {
-		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		return r.Requeue.Poll("completion"), nil
 	}
 
 	expiryAt := imageBuild.Status.CompletionTime.Time.Add(time.Duration(expiryHours) * time.Hour)
 	now := time.Now()
 	if now.Before(expiryAt) {
-		return ctrl.Result{RequeueAfter: time.Until(expiryAt)}, nil
+		next, err := r.checkArtifactServing(ctx, imageBuild)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		converting, err := r.syncConversions(ctx, imageBuild)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if converting {
+			next = min(next, conversionCheckInterval)
+		}
+		if err := r.syncDownloadInfo(ctx, imageBuild, expiryAt); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update download info: %w", err)
+		}
+		if until := time.Until(expiryAt); until < next {
+			return ctrl.Result{RequeueAfter: until}, nil
+		}
+		return r.Requeue.SyncEvery("artifact-serving", next), nil
 	}
 
+	r.deleteArtifactServing(ctx, imageBuild)
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err == nil {
+		patch := client.MergeFrom(fresh.DeepCopy())
+		fresh.Status.ArtifactURL = ""
+		fresh.Status.ArtifactFileName = ""
+		fresh.Status.ArtifactSize = 0
+		fresh.Status.ArtifactSHA256 = ""
+		fresh.Status.ArtifactContentType = ""
+		fresh.Status.ArtifactPath = ""
+		fresh.Status.Download = nil
+		fresh.Status.Message = "Build expired"
+		meta.SetStatusCondition(&fresh.Status.Conditions, metav1.Condition{
+			Type:               automotivev1.ImageBuildArtifactServing,
+			Status:             metav1.ConditionFalse,
+			Reason:             artifactServingReasonExpired,
+			Message:            "Artifact is no longer served",
+			ObservedGeneration: fresh.Generation,
+		})
+		if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+			log.Error(err, "failed to update ImageBuild status after expiry cleanup")
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// deleteArtifactServing removes the artifact pod and the resources exposing it; failures are only logged
+func (r *ImageBuildReconciler) deleteArtifactServing(ctx context.Context, imageBuild *automotivev1.ImageBuild) {
+	log := r.buildLog(imageBuild)
+
 	svcName := fmt.Sprintf("%s-artifact-service", imageBuild.Name)
 	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: svcName, Namespace: imageBuild.Namespace}}
 	if err := r.Delete(ctx, svc); err != nil && !errors.IsNotFound(err) {
 		log.Error(err, "failed to delete artifact Service", "service", svcName)
 	}
 
-	routeName := fmt.Sprintf("%s-artifacts", imageBuild.Name)
-	route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: routeName, Namespace: imageBuild.Namespace}}
-	if err := r.Delete(ctx, route); err != nil && !errors.IsNotFound(err) {
-		log.Error(err, "failed to delete artifact Route", "route", routeName)
+	for _, obj := range artifactExposureObjects(imageBuild) {
+		if err := ignoreMissing(r.Delete(ctx, obj)); err != nil {
+			log.Error(err, "failed to delete artifact exposure", "kind", fmt.Sprintf("%T", obj), "name", obj.GetName())
+		}
 	}
 
 	podName := fmt.Sprintf("%s-artifact-pod", imageBuild.Name)
@@ -205,53 +325,126 @@ func (r *ImageBuildReconciler) handleCompletedState(ctx context.Context, imageBu
 		log.Error(err, "failed to delete nginx ConfigMap", "configMap", cmName)
 	}
 
-	fresh := &automotivev1.ImageBuild{}
-	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err == nil {
-		patch := client.MergeFrom(fresh.DeepCopy())
-		fresh.Status.ArtifactURL = ""
-		fresh.Status.ArtifactFileName = ""
-		fresh.Status.ArtifactPath = ""
-		fresh.Status.Message = "Build expired"
-		if err := r.Status().Patch(ctx, fresh, patch); err != nil {
-			log.Error(err, "failed to update ImageBuild status after expiry cleanup")
+	for _, format := range imageBuild.Spec.Conversions {
+		podName := conversionPodName(imageBuild, format)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: imageBuild.Namespace}}
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "failed to delete conversion Pod", "pod", podName)
 		}
 	}
 
-	return ctrl.Result{}, nil
+	secretName := artifactProxySecretName(imageBuild)
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: imageBuild.Namespace}}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "failed to delete oauth-proxy Secret", "secret", secretName)
+	}
+	if err := r.setHelperServiceAccountAnnotation(ctx, imageBuild.Namespace, oauthRedirectReferencePrefix+imageBuild.Name, ""); err != nil {
+		log.Error(err, "failed to remove the artifact Route OAuth redirect")
+	}
 }
 
 func (r *ImageBuildReconciler) checkBuildProgress(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
-	taskRun := &tektonv1.TaskRun{}
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      imageBuild.Status.TaskRunName,
-		Namespace: imageBuild.Namespace,
-	}, taskRun)
-	if err != nil && !errors.IsNotFound(err) {
+	run, err := r.getBuildRun(ctx, imageBuild)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if errors.IsNotFound(err) {
+	if run == nil {
 		return r.startNewBuild(ctx, imageBuild)
 	}
 
-	if !isTaskRunCompleted(taskRun) {
-		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	if !run.completed {
+		if result, unbound, err := r.checkWorkspaceBound(ctx, imageBuild); unbound || err != nil {
+			return result, err
+		}
+		if result, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild); unschedulable || err != nil {
+			return result, err
+		}
+		return r.Requeue.Sync("build"), nil
 	}
 
-	if isTaskRunSuccessful(taskRun) {
-		for _, res := range taskRun.Status.TaskRunStatusFields.Results {
-			if res.Name == "artifact-filename" && res.Value.StringVal != "" {
-				fresh := &automotivev1.ImageBuild{}
-				if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err == nil {
-					patch := client.MergeFrom(fresh.DeepCopy())
-					fresh.Status.ArtifactFileName = res.Value.StringVal
-					_ = r.Status().Patch(ctx, fresh, patch)
+	if err := r.recordResourceUsage(ctx, imageBuild, run); err != nil {
+		return r.Requeue.Retry("status"), nil
+	}
+	if err := r.recordCost(ctx, imageBuild); err != nil {
+		r.buildLog(imageBuild).Error(err, "Failed to record build cost")
+		return r.Requeue.Retry("status"), nil
+	}
+
+	if run.succeeded {
+		buildConfig, err := r.getBuildConfig(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		var scanPolicy *automotivev1.ScanPolicy
+		if buildConfig != nil {
+			scanPolicy = buildConfig.Scan
+		}
+		scan, err := scanResult(run, scanPolicy)
+		if err != nil {
+			if err := r.updateStatus(ctx, imageBuild, "Failed", err.Error()); err != nil {
+				return r.Requeue.Retry("status"), nil
+			}
+			return ctrl.Result{}, nil
+		}
+
+		results, done, err := r.readBuildResults(ctx, imageBuild, buildConfig)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return r.Requeue.Poll("results"), nil
+		}
+
+		var artifactSHA256, artifactContentType string
+		ostreeCommit := strings.TrimSpace(run.results["ostree-commit"])
+		artifactFileName := strings.TrimSpace(run.results["artifact-filename"])
+		// a missing or malformed size only leaves it out of the status
+		artifactSize, _ := strconv.ParseInt(strings.TrimSpace(run.results["artifact-size"]), 10, 64)
+		// the workspace results file is not bound by Tekton's result size limit and wins over the run results
+		if results != nil {
+			if results.ArtifactFileName != "" {
+				artifactFileName = results.ArtifactFileName
+			}
+			if results.ArtifactSize > 0 {
+				artifactSize = results.ArtifactSize
+			}
+			artifactSHA256 = results.ArtifactSHA256
+			artifactContentType = results.ArtifactContentType
+		}
+		if artifactFileName != "" && artifactContentType == "" {
+			artifactContentType = artifacttype.ForFile(artifactFileName)
+		}
+		if artifactFileName != "" || artifactSize > 0 || artifactSHA256 != "" || scan != nil || ostreeCommit != "" {
+			fresh := &automotivev1.ImageBuild{}
+			if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err == nil {
+				patch := client.MergeFrom(fresh.DeepCopy())
+				if artifactFileName != "" {
+					fresh.Status.ArtifactFileName = artifactFileName
+				}
+				if artifactSize > 0 {
+					fresh.Status.ArtifactSize = artifactSize
+				}
+				if artifactSHA256 != "" {
+					fresh.Status.ArtifactSHA256 = artifactSHA256
+				}
+				if artifactContentType != "" {
+					fresh.Status.ArtifactContentType = artifactContentType
+				}
+				if ostreeCommit != "" {
+					fresh.Status.OSTreeCommit = ostreeCommit
 				}
-				break
+				fresh.Status.Scan = scan
+				_ = r.Status().Patch(ctx, fresh, patch)
 			}
 		}
-		if err := r.updateStatus(ctx, imageBuild, "Completed", "Build completed successfully"); err != nil {
-			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+
+		message := "Build completed successfully"
+		if scan != nil && scan.Blocked {
+			message = scanBlockedMessage(scan, scanPolicy)
+		}
+		if err := r.updateStatus(ctx, imageBuild, "Completed", message); err != nil {
+			return r.Requeue.Retry("status"), nil
 		}
 
 		if imageBuild.Spec.ServeArtifact {
@@ -259,6 +452,11 @@ func (r *ImageBuildReconciler) checkBuildProgress(ctx context.Context, imageBuil
 				return ctrl.Result{}, err
 			}
 
+			if scan != nil && scan.Blocked {
+				// the pod stays private so the build API can still hand out the scan report
+				return ctrl.Result{}, nil
+			}
+
 			if imageBuild.Spec.ExposeRoute {
 				if err := r.createArtifactServingResources(ctx, imageBuild); err != nil {
 					return ctrl.Result{}, err
@@ -272,14 +470,17 @@ func (r *ImageBuildReconciler) checkBuildProgress(ctx context.Context, imageBuil
 		return ctrl.Result{}, nil
 	}
 
-	if err := r.updateStatus(ctx, imageBuild, "Failed", "Build failed"); err != nil {
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	if err := r.updateStatus(ctx, imageBuild, "Failed", r.buildFailedMessage(ctx, imageBuild)); err != nil {
+		return r.Requeue.Retry("status"), nil
 	}
 	return ctrl.Result{}, nil
 }
 
 func (r *ImageBuildReconciler) startNewBuild(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
-	pvcName, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
+	pvcName, pvcSize, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
+	if isWorkspaceStorageExceeded(err) {
+		return r.waitForWorkspaceStorage(ctx, imageBuild, err)
+	}
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to get or create workspace PVC: %w", err)
 	}
@@ -291,6 +492,7 @@ func (r *ImageBuildReconciler) startNewBuild(ctx context.Context, imageBuild *au
 		}
 
 		fresh.Status.PVCName = pvcName
+		fresh.Status.WorkspaceSize = pvcSize.String()
 		if err := r.Status().Update(ctx, fresh); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to update ImageBuild status with PVC name: %w", err)
 		}
@@ -298,16 +500,24 @@ func (r *ImageBuildReconciler) startNewBuild(ctx context.Context, imageBuild *au
 		imageBuild.Status.PVCName = pvcName
 	}
 
-	if err := r.createBuildTaskRun(ctx, imageBuild); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to create build task run: %w", err)
+	if err := r.createBuildRun(ctx, imageBuild); err != nil {
+		if isBuilderImageRejected(err) || isPipelineRejected(err) || isResourcesRejected(err) || isManifestModified(err) ||
+			isArtifactNameRejected(err) || isStepImagesRejected(err) || isInputRejected(err) {
+			if err := r.updateStatus(ctx, imageBuild, "Failed", err.Error()); err != nil {
+				return r.Requeue.Retry("status"), nil
+			}
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to create build run: %w", err)
 	}
 
-	return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	return r.Requeue.Sync("build"), nil
 }
 
-func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
-	log.Info("Creating TaskRun for ImageBuild")
+// createBuildRun starts the build: a TaskRun of the operator's build task, or a PipelineRun of the
+// ImageBuild's PipelineRef
+func (r *ImageBuildReconciler) createBuildRun(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	log := r.buildLog(imageBuild)
 
 	autoDev := &automotivev1.AutomotiveDev{}
 	err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev)
@@ -319,10 +529,32 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 	if err == nil && autoDev.Spec.BuildConfig != nil {
 		buildConfig = autoDev.Spec.BuildConfig
 	}
-	buildTask := tasks.GenerateBuildAutomotiveImageTask(OperatorNamespace, buildConfig, imageBuild.Spec.EnvSecretRef)
+	if err := checkExtendedResources(imageBuild, buildConfig); err != nil {
+		return err
+	}
+	artifactName, err := buildArtifactName(imageBuild, buildConfig)
+	if err != nil {
+		return err
+	}
+	// the same task the AutomotiveDev installs, run inline
+	taskOpts := tektongen.Options{Namespace: OperatorNamespace, BuildConfig: buildConfig}
+	if err := tektongen.Validate(taskOpts); err != nil {
+		return fmt.Errorf("%w: %v", errStepImagesRejected, err)
+	}
+	buildTask := tektongen.BuildTask(tektongen.BuildTaskOptions{
+		Options:      taskOpts,
+		EnvSecretRef: imageBuild.Spec.EnvSecretRef,
+	})
+
+	serviceAccountName := resolveServiceAccountName(imageBuild, buildConfig)
+	if serviceAccountName == BuildServiceAccountName {
+		if err := r.ensureBuildServiceAccount(ctx, imageBuild.Namespace); err != nil {
+			return err
+		}
+	}
 
 	if imageBuild.Status.PVCName == "" {
-		workspacePVCName, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
+		workspacePVCName, workspaceSize, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
 		if err != nil {
 			return err
 		}
@@ -333,6 +565,7 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 		}
 
 		fresh.Status.PVCName = workspacePVCName
+		fresh.Status.WorkspaceSize = workspaceSize.String()
 		if err := r.Status().Update(ctx, fresh); err != nil {
 			return fmt.Errorf("failed to update ImageBuild status with PVC name: %w", err)
 		}
@@ -342,6 +575,15 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 
 	workspacePVCName := imageBuild.Status.PVCName
 
+	builderImage, err := r.resolveBuilderImage(ctx, imageBuild, buildConfig)
+	if err != nil {
+		return err
+	}
+	manifestSHA256, err := r.recordManifestSHA256(ctx, imageBuild)
+	if err != nil {
+		return err
+	}
+
 	params := []tektonv1.Param{
 		{
 			Name: "target-architecture",
@@ -382,7 +624,7 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 			Name: "automotive-image-builder",
 			Value: tektonv1.ParamValue{
 				Type:      tektonv1.ParamTypeString,
-				StringVal: imageBuild.Spec.AutomotiveImageBuilder,
+				StringVal: builderImage,
 			},
 		},
 		{
@@ -392,6 +634,74 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 				StringVal: imageBuild.Spec.Compression,
 			},
 		},
+		{
+			Name: "manifest-file",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: imageBuild.Spec.ManifestFile,
+			},
+		},
+		{
+			Name: "manifest-ref",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: imageBuild.Spec.ManifestRef,
+			},
+		},
+		{
+			Name: "build-name",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: imageBuild.Name,
+			},
+		},
+		{
+			Name: "keep-workspace-on-failure",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: strconv.FormatBool(keepWorkspaceOnFailure(imageBuild, buildConfig)),
+			},
+		},
+		{
+			Name: "build-info",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: buildInfoContent(imageBuild, builderImage),
+			},
+		},
+		{
+			Name: "manifest-sha256",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: manifestSHA256,
+			},
+		},
+		{
+			Name: "intermediate-registry",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: intermediateRegistryRepository(imageBuild, buildConfig),
+			},
+		},
+		{
+			Name: "artifact-name",
+			Value: tektonv1.ParamValue{
+				Type:      tektonv1.ParamTypeString,
+				StringVal: artifactName,
+			},
+		},
+	}
+	if ostree := imageBuild.Spec.OSTree; ostree != nil {
+		params = append(params,
+			tektonv1.Param{
+				Name:  "ostree-repository-url",
+				Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: ostree.RepositoryURL},
+			},
+			tektonv1.Param{
+				Name:  "ostree-ref",
+				Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: ostree.Ref},
+			},
+		)
 	}
 
 	workspaces := []tektonv1.WorkspaceBinding{
@@ -411,6 +721,26 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 		},
 	}
 
+	if imageBuild.Spec.EncryptionKeySecretRef != "" {
+		workspaces = append(workspaces, tektonv1.WorkspaceBinding{
+			Name:   "encryption-key",
+			Secret: &corev1.SecretVolumeSource{SecretName: imageBuild.Spec.EncryptionKeySecretRef},
+		})
+	}
+	if ostree := imageBuild.Spec.OSTree; ostree != nil && ostree.SecretRef != "" {
+		workspaces = append(workspaces, tektonv1.WorkspaceBinding{
+			Name:   "ostree-auth",
+			Secret: &corev1.SecretVolumeSource{SecretName: ostree.SecretRef},
+		})
+	}
+	if imageBuild.Spec.InputPVC != nil {
+		input, err := r.inputWorkspace(ctx, imageBuild)
+		if err != nil {
+			return err
+		}
+		workspaces = append(workspaces, input)
+	}
+
 	nodeAffinity := &corev1.NodeAffinity{
 		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
 			NodeSelectorTerms: []corev1.NodeSelectorTerm{
@@ -434,10 +764,21 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 	if buildConfig != nil && buildConfig.RuntimeClassName != "" {
 		podTemplate.RuntimeClassName = &buildConfig.RuntimeClassName
 	}
+	if buildConfig != nil {
+		podTemplate.NodeSelector = buildConfig.NodeSelector
+	}
 	if imageBuild.Spec.RuntimeClassName != "" {
 		log.Info("Setting RuntimeClassName from ImageBuild spec", "runtimeClassName", imageBuild.Spec.RuntimeClassName)
 		podTemplate.RuntimeClassName = &imageBuild.Spec.RuntimeClassName
 	}
+	applyExtendedResources(&buildTask.Spec, podTemplate, imageBuild.Spec.ExtendedResources)
+	podTemplate.Env = append(podTemplate.Env, correlationEnv(imageBuild)...)
+
+	if imageBuild.Spec.PipelineRef != nil {
+		return r.createBuildPipelineRun(ctx, imageBuild, buildConfig, params, workspaces, podTemplate, serviceAccountName)
+	}
+
+	log.Info("Creating TaskRun for ImageBuild")
 	taskRun := &tektonv1.TaskRun{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: fmt.Sprintf("%s-build-", imageBuild.Name),
@@ -446,6 +787,7 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 				tektonv1.ManagedByLabelKey:                        "automotive-dev-operator",
 				"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
 			},
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: imageBuild.APIVersion,
@@ -457,13 +799,16 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 			},
 		},
 		Spec: tektonv1.TaskRunSpec{
-			TaskSpec:    &buildTask.Spec,
-			Params:      params,
-			Workspaces:  workspaces,
-			PodTemplate: podTemplate,
+			TaskSpec:           &buildTask.Spec,
+			Params:             params,
+			Workspaces:         workspaces,
+			PodTemplate:        podTemplate,
+			ServiceAccountName: serviceAccountName,
 		},
 	}
 
+	userlabels.Apply(taskRun.Labels, imageBuild.Labels)
+
 	if err := r.Create(ctx, taskRun); err != nil {
 		return fmt.Errorf("failed to create TaskRun: %w", err)
 	}
@@ -483,7 +828,7 @@ func (r *ImageBuildReconciler) createBuildTaskRun(ctx context.Context, imageBuil
 }
 
 func (r *ImageBuildReconciler) updateArtifactInfo(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	latestImageBuild := &automotivev1.ImageBuild{}
 	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, latestImageBuild); err != nil {
@@ -497,23 +842,7 @@ func (r *ImageBuildReconciler) updateArtifactInfo(ctx context.Context, imageBuil
 		return ctrl.Result{}, fmt.Errorf("no PVC name found in ImageBuild status")
 	}
 
-	var fileExtension string
-	switch latestImageBuild.Spec.ExportFormat {
-	case "image":
-		fileExtension = ".raw"
-	case "qcow2":
-		fileExtension = ".qcow2"
-	default:
-		fileExtension = fmt.Sprintf(".%s", latestImageBuild.Spec.ExportFormat)
-	}
-
-	fileName := strings.TrimSpace(latestImageBuild.Status.ArtifactFileName)
-	if fileName == "" {
-		fileName = fmt.Sprintf("%s-%s%s",
-			latestImageBuild.Spec.Distro,
-			latestImageBuild.Spec.Target,
-			fileExtension)
-	}
+	fileName := r.artifactFileName(ctx, latestImageBuild)
 
 	log.Info("Setting artifact info", "pvc", pvcName, "fileName", fileName)
 
@@ -524,51 +853,45 @@ func (r *ImageBuildReconciler) updateArtifactInfo(ctx context.Context, imageBuil
 
 	if err := r.Status().Patch(ctx, latestImageBuild, patch); err != nil {
 		log.Error(err, "Failed to patch status with artifact info")
-		return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		return r.Requeue.Retry("status"), nil
 	}
 
 	if latestImageBuild.Spec.ExposeRoute {
-		routeName := "ado-build-api"
+		exposure, err := r.getExposure(ctx)
+		if err != nil {
+			log.Error(err, "Failed to get exposure configuration")
+			return r.Requeue.Retry("exposure"), nil
+		}
 		timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
 
-		route := &routev1.Route{}
-		err := wait.PollUntilContextTimeout(
+		var artifactURL string
+		err = wait.PollUntilContextTimeout(
 			timeoutCtx,
 			time.Second,
 			30*time.Second,
 			false,
 			func(ctx context.Context) (bool, error) {
-				if err := r.Get(ctx, client.ObjectKey{Name: routeName, Namespace: latestImageBuild.Namespace}, route); err != nil {
-					log.Error(err, "Error getting route")
+				url, err := r.exposedURL(ctx, exposure, buildAPIExposureName, latestImageBuild.Namespace)
+				if err != nil {
+					log.Error(err, "Error getting build API exposure", "kind", exposure.Type)
 					return false, nil
 				}
-				return len(route.Status.Ingress) > 0 && route.Status.Ingress[0].Host != "", nil
+				artifactURL = url
+				return url != "", nil
 			},
 		)
-		if err != nil {
-			log.Error(err, "timed out waiting for route hostname")
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
-		}
-
-		if len(route.Status.Ingress) == 0 || route.Status.Ingress[0].Host == "" {
-			log.Info("route status not yet populated with host", "route", routeName)
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+		if err != nil || artifactURL == "" {
+			log.Error(err, "timed out waiting for the build API host", "kind", exposure.Type, "name", buildAPIExposureName)
+			return r.Requeue.Poll("route"), nil
 		}
 
-		scheme := "https"
-		if route.Spec.TLS == nil {
-			scheme = "http"
-			log.Info("TLS is not enabled")
-		}
-
-		artifactURL := fmt.Sprintf("%s://%s", scheme, route.Status.Ingress[0].Host)
 		log.Info("setting artifact URL in status", "url", artifactURL)
 
 		freshBuild := &automotivev1.ImageBuild{}
 		if err := r.Get(ctx, types.NamespacedName{Name: latestImageBuild.Name, Namespace: latestImageBuild.Namespace}, freshBuild); err != nil {
 			log.Error(err, "Failed to get fresh ImageBuild for URL update")
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			return r.Requeue.Retry("status"), nil
 		}
 
 		urlPatch := client.MergeFrom(freshBuild.DeepCopy())
@@ -576,17 +899,17 @@ func (r *ImageBuildReconciler) updateArtifactInfo(ctx context.Context, imageBuil
 
 		if err := r.Status().Patch(ctx, freshBuild, urlPatch); err != nil {
 			log.Error(err, "failed to update ImageBuild status with route URL")
-			return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+			return r.Requeue.Retry("status"), nil
 		}
 
-		log.Info("artifact serving resources created and status updated", "route", route.Status.Ingress[0].Host)
+		log.Info("artifact serving resources created and status updated", "url", artifactURL)
 	}
 
 	return ctrl.Result{}, nil
 }
 
 func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	podName := fmt.Sprintf("%s-artifact-pod", imageBuild.Name)
 	existingPod := &corev1.Pod{}
@@ -606,8 +929,9 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 
 	workspacePVCName := imageBuild.Status.PVCName
 	if workspacePVCName == "" {
+		var workspaceSize resource.Quantity
 		var err error
-		workspacePVCName, err = r.getOrCreateWorkspacePVC(ctx, imageBuild)
+		workspacePVCName, workspaceSize, err = r.getOrCreateWorkspacePVC(ctx, imageBuild)
 		if err != nil {
 			return err
 		}
@@ -618,6 +942,7 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 		}
 
 		fresh.Status.PVCName = workspacePVCName
+		fresh.Status.WorkspaceSize = workspaceSize.String()
 		if err := r.Status().Update(ctx, fresh); err != nil {
 			return fmt.Errorf("failed to update ImageBuild status with PVC name: %w", err)
 		}
@@ -625,7 +950,26 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 		imageBuild.Status.PVCName = workspacePVCName
 	}
 
-	nginxConfigMapName, err := r.createNginxConfigMap(ctx, imageBuild)
+	if err := r.ensureHelperServiceAccount(ctx, imageBuild.Namespace); err != nil {
+		return err
+	}
+
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	auth, err := routeAuth(imageBuild, buildConfig)
+	if err != nil {
+		return err
+	}
+	if auth.Type == automotivev1.RouteAuthOAuth {
+		if err := r.ensureOAuthProxy(ctx, imageBuild); err != nil {
+			return err
+		}
+	}
+
+	nginxConfigMapName, err := r.createNginxConfigMap(ctx, imageBuild, auth)
 	if err != nil {
 		return fmt.Errorf("failed to create nginx config map: %w", err)
 	}
@@ -635,12 +979,14 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 		"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
 		"app.kubernetes.io/name":                          "artifact-pod",
 	}
+	userlabels.Apply(labels, imageBuild.Labels)
 
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: imageBuild.Namespace,
-			Labels:    labels,
+			Name:        podName,
+			Namespace:   imageBuild.Namespace,
+			Labels:      labels,
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         imageBuild.APIVersion,
@@ -653,6 +999,7 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 			},
 		},
 		Spec: corev1.PodSpec{
+			ServiceAccountName: HelperServiceAccountName,
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser:    ptr.To[int64](1000),
 				RunAsGroup:   ptr.To[int64](1000),
@@ -662,13 +1009,25 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 			Containers: []corev1.Container{
 				{
 					Name:  "fileserver",
-					Image: "quay.io/nginx/nginx-unprivileged:latest",
+					Image: tasks.Images(buildConfig).FileServer,
 					Ports: []corev1.ContainerPort{
 						{
-							ContainerPort: 8080,
+							ContainerPort: fileServerPort,
+							Protocol:      corev1.ProtocolTCP,
+						},
+						{
+							Name:          "build-api",
+							ContainerPort: artifactProxyPort,
+							Protocol:      corev1.ProtocolTCP,
+						},
+						{
+							Name:          "health",
+							ContainerPort: artifactHealthPort,
 							Protocol:      corev1.ProtocolTCP,
 						},
 					},
+					LivenessProbe:  artifactProbe("/healthz", artifactHealthPort),
+					ReadinessProbe: artifactProbe("/healthz", artifactHealthPort),
 					Resources: corev1.ResourceRequirements{
 						Requests: corev1.ResourceList{
 							corev1.ResourceCPU:    resource.MustParse("100m"),
@@ -716,6 +1075,31 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 		},
 	}
 
+	switch auth.Type {
+	case automotivev1.RouteAuthBasic:
+		fileServer := &pod.Spec.Containers[0]
+		fileServer.VolumeMounts = append(fileServer.VolumeMounts, corev1.VolumeMount{
+			Name:      "htpasswd",
+			MountPath: "/etc/nginx/auth",
+			ReadOnly:  true,
+		})
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "htpasswd",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: auth.HtpasswdSecretRef,
+					Items:      []corev1.KeyToPath{{Key: htpasswdSecretKey, Path: htpasswdSecretKey}},
+				},
+			},
+		})
+	case automotivev1.RouteAuthOAuth:
+		proxy, err := oauthProxyContainer(imageBuild, tasks.Images(buildConfig).OAuthProxy)
+		if err != nil {
+			return err
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, proxy)
+	}
+
 	if err := r.Create(ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create artifact pod: %w", err)
 	}
@@ -741,10 +1125,47 @@ func (r *ImageBuildReconciler) createArtifactPod(ctx context.Context, imageBuild
 	return nil
 }
 
-func (r *ImageBuildReconciler) createNginxConfigMap(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, error) {
+// createNginxConfigMap writes the file server configuration for the build's current artifact, updating
+// a ConfigMap left with another one
+func (r *ImageBuildReconciler) createNginxConfigMap(ctx context.Context, imageBuild *automotivev1.ImageBuild, auth automotivev1.RouteAuth) (string, error) {
 	configMapName := fmt.Sprintf("%s-nginx-config", imageBuild.Name)
 
-	configMap := &corev1.ConfigMap{
+	// the artifact file name is recorded in the status just before the pod is created
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return "", fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	getErr := r.Get(ctx, types.NamespacedName{Name: configMapName, Namespace: imageBuild.Namespace}, configMap)
+	if getErr != nil && !errors.IsNotFound(getErr) {
+		return "", fmt.Errorf("failed to get nginx config ConfigMap: %w", getErr)
+	}
+	// the proxy token outlives config updates so the build API keeps reaching a running pod
+	token := configMap.Data[artifactProxyTokenKey]
+	if token == "" {
+		var err error
+		if token, err = newArtifactProxyToken(); err != nil {
+			return "", err
+		}
+	}
+	data := map[string]string{
+		"default.conf":        nginxConfig(r.artifactFileName(ctx, fresh), auth, token),
+		artifactProxyTokenKey: token,
+	}
+
+	if getErr == nil {
+		if configMap.Data["default.conf"] == data["default.conf"] {
+			return configMapName, nil
+		}
+		configMap.Data = data
+		if err := r.Update(ctx, configMap); err != nil {
+			return "", fmt.Errorf("failed to update nginx config ConfigMap: %w", err)
+		}
+		return configMapName, nil
+	}
+
+	configMap = &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      configMapName,
 			Namespace: imageBuild.Namespace,
@@ -759,31 +1180,7 @@ func (r *ImageBuildReconciler) createNginxConfigMap(ctx context.Context, imageBu
 				},
 			},
 		},
-		Data: map[string]string{
-			"default.conf": `
-server {
-    listen 8080;
-    server_name localhost;
-
-    # Serve artifacts directly from the mounted PVC
-    root /workspace/shared;
-    autoindex on;
-    autoindex_exact_size off;
-    autoindex_localtime on;
-
-    location / {
-        try_files $uri =404;
-        add_header Cache-Control "no-store" always;
-        add_header X-Content-Type-Options nosniff always;
-    }
-
-    error_page   500 502 503 504  /50x.html;
-    location = /50x.html {
-        root   /usr/share/nginx/html;
-    }
-}
-    `,
-		},
+		Data: data,
 	}
 
 	if err := r.Create(ctx, configMap); err != nil {
@@ -797,10 +1194,29 @@ server {
 }
 
 func (r *ImageBuildReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := registerMetrics(mgr.GetClient()); err != nil {
+		return fmt.Errorf("failed to register build metrics: %w", err)
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&automotivev1.ImageBuild{}).
 		Owns(&tektonv1.TaskRun{}).
+		Owns(&tektonv1.PipelineRun{}).
 		Owns(&corev1.Pod{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		// deleting the Service or the Route, Ingress or HTTPRoute of a served artifact has it recreated right away
+		Owns(&corev1.Service{})
+	if _, err := mgr.GetRESTMapper().RESTMapping(routev1.GroupVersion.WithKind("Route").GroupKind(), routev1.GroupVersion.Version); err == nil {
+		b = b.Owns(&routev1.Route{})
+	} else if !meta.IsNoMatchError(err) {
+		return fmt.Errorf("failed to look up the Route API: %w", err)
+	}
+	b = b.Owns(&networkingv1.Ingress{})
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err == nil {
+		b = b.Owns(exposureObject(&automotivev1.Exposure{Type: automotivev1.ExposureGateway}))
+	} else if !meta.IsNoMatchError(err) {
+		return fmt.Errorf("failed to look up the Gateway API: %w", err)
+	}
+	return b.WithOptions(controller.Options{RateLimiter: r.Requeue.RateLimiter()}).
 		Complete(r)
 }
 
@@ -818,7 +1234,7 @@ func isTaskRunSuccessful(taskRun *tektonv1.TaskRun) bool {
 }
 
 func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	podName := fmt.Sprintf("%s-upload-pod", imageBuild.Name)
 	existingPod := &corev1.Pod{}
@@ -836,7 +1252,7 @@ func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *
 		return fmt.Errorf("error checking for existing pod: %w", err)
 	}
 
-	workspacePVCName, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
+	workspacePVCName, workspaceSize, err := r.getOrCreateWorkspacePVC(ctx, imageBuild)
 	if err != nil {
 		return err
 	}
@@ -848,6 +1264,7 @@ func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *
 		}
 
 		fresh.Status.PVCName = workspacePVCName
+		fresh.Status.WorkspaceSize = workspaceSize.String()
 		if err := r.Status().Update(ctx, fresh); err != nil {
 			return fmt.Errorf("failed to update ImageBuild status with PVC name: %w", err)
 		}
@@ -861,11 +1278,21 @@ func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *
 		"app.kubernetes.io/name":                          "upload-pod",
 	}
 
+	if err := r.ensureHelperServiceAccount(ctx, imageBuild.Namespace); err != nil {
+		return err
+	}
+
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      podName,
-			Namespace: imageBuild.Namespace,
-			Labels:    labels,
+			Name:        podName,
+			Namespace:   imageBuild.Namespace,
+			Labels:      labels,
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         imageBuild.APIVersion,
@@ -878,6 +1305,7 @@ func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *
 			},
 		},
 		Spec: corev1.PodSpec{
+			ServiceAccountName: HelperServiceAccountName,
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsUser:    ptr.To[int64](1000),
 				RunAsGroup:   ptr.To[int64](1000),
@@ -887,7 +1315,7 @@ func (r *ImageBuildReconciler) createUploadPod(ctx context.Context, imageBuild *
 			Containers: []corev1.Container{
 				{
 					Name:    "fileserver",
-					Image:   "quay.io/nginx/nginx-unprivileged:latest",
+					Image:   tasks.Images(buildConfig).FileServer,
 					Command: []string{"sleep", "infinity"},
 					Resources: corev1.ResourceRequirements{
 						Requests: corev1.ResourceList{
@@ -955,6 +1383,8 @@ func (r *ImageBuildReconciler) updateStatus(ctx context.Context, imageBuild *aut
 	}
 
 	patch := client.MergeFrom(fresh.DeepCopy())
+	finishing := isFinished(phase) && !isFinished(fresh.Status.Phase)
+	previousPhase := fresh.Status.Phase
 
 	fresh.Status.Phase = phase
 	fresh.Status.Message = message
@@ -967,11 +1397,20 @@ func (r *ImageBuildReconciler) updateStatus(ctx context.Context, imageBuild *aut
 		fresh.Status.CompletionTime = &now
 	}
 
-	return r.Status().Patch(ctx, fresh, patch)
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return err
+	}
+	if finishing {
+		buildsFinished.WithLabelValues(fresh.Namespace, phase).Inc()
+	}
+	r.reportCommitStatus(ctx, fresh, previousPhase)
+	return nil
 }
 
-func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, error) {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+// getOrCreateWorkspacePVC returns the name of the build's workspace PVC and the size it was created with,
+// creating it first when the build has none
+func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, resource.Quantity, error) {
+	log := r.buildLog(imageBuild)
 
 	if imageBuild.Status.PVCName != "" {
 		existingPVC := &corev1.PersistentVolumeClaim{}
@@ -982,7 +1421,7 @@ func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imag
 
 		if err == nil && existingPVC.DeletionTimestamp == nil {
 			log.Info("Using existing workspace PVC from status", "pvc", imageBuild.Status.PVCName)
-			return imageBuild.Status.PVCName, nil
+			return imageBuild.Status.PVCName, existingPVC.Spec.Resources.Requests[corev1.ResourceStorage], nil
 		}
 
 		log.Info("PVC from status is not available, creating a new one",
@@ -992,10 +1431,16 @@ func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imag
 	autoDev := &automotivev1.AutomotiveDev{}
 	err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev)
 
-	storageSize := resource.MustParse("8Gi")
-	if err == nil && autoDev.Spec.BuildConfig != nil && autoDev.Spec.BuildConfig.PVCSize != "" {
-		storageSize = resource.MustParse(autoDev.Spec.BuildConfig.PVCSize)
-		log.Info("Using BuildConfig PVCSize", "size", autoDev.Spec.BuildConfig.PVCSize)
+	var buildConfig *automotivev1.BuildConfig
+	if err == nil {
+		buildConfig = autoDev.Spec.BuildConfig
+	}
+
+	storageSize := workspaceSize(imageBuild, buildConfig)
+	log.Info("Sizing workspace", "size", storageSize.String(), "requested", imageBuild.Spec.WorkspaceSize,
+		"estimate", imageBuild.Spec.WorkspaceSizeEstimate)
+	if err := r.checkWorkspaceStorage(ctx, imageBuild, storageSize, buildConfig); err != nil {
+		return "", storageSize, err
 	}
 
 	timestamp := fmt.Sprintf("%d", time.Now().Unix())
@@ -1005,10 +1450,7 @@ func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imag
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      uniquePVCName,
 			Namespace: imageBuild.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/managed-by":                    "automotive-dev-operator",
-				"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
-			},
+			Labels:    storage.WorkspaceLabels(imageBuild.Name),
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion:         imageBuild.APIVersion,
@@ -1037,15 +1479,19 @@ func (r *ImageBuildReconciler) getOrCreateWorkspacePVC(ctx context.Context, imag
 	}
 
 	if err := r.Create(ctx, pvc); err != nil {
-		return "", fmt.Errorf("failed to create workspace PVC: %w", err)
+		return "", storageSize, fmt.Errorf("failed to create workspace PVC: %w", err)
 	}
 
 	log.Info("Created new workspace PVC with unique name", "pvc", uniquePVCName)
-	return uniquePVCName, nil
+	if imageBuild.Status.PVCName == "" {
+		workspaceWait.WithLabelValues(imageBuild.Namespace, requester(imageBuild)).
+			Observe(time.Since(imageBuild.CreationTimestamp.Time).Seconds())
+	}
+	return uniquePVCName, storageSize, nil
 }
 
 func (r *ImageBuildReconciler) shutdownUploadPod(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	podName := fmt.Sprintf("%s-upload-pod", imageBuild.Name)
 	pod := &corev1.Pod{
@@ -1064,7 +1510,7 @@ func (r *ImageBuildReconciler) shutdownUploadPod(ctx context.Context, imageBuild
 }
 
 func (r *ImageBuildReconciler) createArtifactServingResources(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
-	log := r.Log.WithValues("imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+	log := r.buildLog(imageBuild)
 
 	podList := &corev1.PodList{}
 	if err := r.List(ctx, podList,
@@ -1081,9 +1527,19 @@ func (r *ImageBuildReconciler) createArtifactServingResources(ctx context.Contex
 	}
 	artifactPod := &podList.Items[0]
 
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return err
+	}
+	auth, err := routeAuth(imageBuild, buildConfig)
+	if err != nil {
+		return err
+	}
+	port := artifactServingPort(auth)
+
 	svcName := fmt.Sprintf("%s-artifact-service", imageBuild.Name)
 	svc := &corev1.Service{}
-	err := r.Get(ctx, types.NamespacedName{Name: svcName, Namespace: imageBuild.Namespace}, svc)
+	err = r.Get(ctx, types.NamespacedName{Name: svcName, Namespace: imageBuild.Namespace}, svc)
 	if errors.IsNotFound(err) {
 		log.Info("Creating artifact service", "name", svcName)
 		svc = &corev1.Service{
@@ -1107,8 +1563,8 @@ func (r *ImageBuildReconciler) createArtifactServingResources(ctx context.Contex
 				Ports: []corev1.ServicePort{
 					{
 						Name:       "http",
-						Port:       8080,
-						TargetPort: intstr.FromInt(8080),
+						Port:       artifactServicePort,
+						TargetPort: intstr.FromInt(port),
 					},
 				},
 			},
@@ -1122,44 +1578,24 @@ func (r *ImageBuildReconciler) createArtifactServingResources(ctx context.Contex
 		log.Info("Artifact service already exists", "name", svcName)
 	}
 
-	routeName := fmt.Sprintf("%s-artifacts", imageBuild.Name)
-	route := &routev1.Route{}
-	err = r.Get(ctx, types.NamespacedName{Name: routeName, Namespace: imageBuild.Namespace}, route)
+	exposure, err := r.getExposure(ctx)
+	if err != nil {
+		return err
+	}
+	exposed, err := newArtifactExposure(imageBuild, exposure, svcName, port, auth, artifactPod.Labels)
+	if err != nil {
+		return err
+	}
+	err = r.Get(ctx, client.ObjectKeyFromObject(exposed), exposureObject(exposure))
 	if errors.IsNotFound(err) {
-		log.Info("Creating artifact route", "name", routeName)
-		route = &routev1.Route{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      routeName,
-				Namespace: imageBuild.Namespace,
-				Labels:    artifactPod.Labels,
-				OwnerReferences: []metav1.OwnerReference{
-					{
-						APIVersion:         imageBuild.APIVersion,
-						Kind:               imageBuild.Kind,
-						Name:               imageBuild.Name,
-						UID:                imageBuild.UID,
-						Controller:         ptr.To(true),
-						BlockOwnerDeletion: ptr.To(true),
-					},
-				},
-			},
-			Spec: routev1.RouteSpec{
-				To: routev1.RouteTargetReference{
-					Kind: "Service",
-					Name: svcName,
-				},
-				Port: &routev1.RoutePort{
-					TargetPort: intstr.FromInt(8080),
-				},
-			},
-		}
-		if err := r.Create(ctx, route); err != nil {
-			return fmt.Errorf("failed to create route: %w", err)
+		log.Info("Creating artifact exposure", "kind", exposure.Type, "name", exposed.GetName())
+		if err := r.Create(ctx, exposed); err != nil {
+			return fmt.Errorf("failed to create artifact %s: %w", exposure.Type, err)
 		}
 	} else if err != nil {
-		return fmt.Errorf("failed to check for existing route: %w", err)
+		return fmt.Errorf("failed to check for existing artifact %s: %w", exposure.Type, err)
 	} else {
-		log.Info("Artifact route already exists", "name", routeName)
+		log.Info("Artifact exposure already exists", "kind", exposure.Type, "name", exposed.GetName())
 	}
 
 	return nil
@@ -0,0 +1,313 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+)
+
+// conversionCheckInterval is how often running conversions are checked besides their pod events
+const conversionCheckInterval = 15 * time.Second
+
+// convertibleImage returns the uncompressed disk image of an artifact, the stem converted files are named
+// after and the image's format, e.g. "cs9-qemu.raw", "cs9-qemu" and "raw" for "cs9-qemu.raw.gz". ok is false
+// for artifacts that are not raw or qcow2 images.
+func convertibleImage(artifactFileName string) (image, stem, ext string, ok bool) {
+	image = strings.TrimSuffix(strings.TrimSuffix(artifactFileName, ".gz"), ".lz4")
+	for _, ext := range []string{"raw", "qcow2"} {
+		if stem, found := strings.CutSuffix(image, "."+ext); found && stem != "" && artifactFileNamePattern.MatchString(image) {
+			return image, stem, ext, true
+		}
+	}
+	return "", "", "", false
+}
+
+// conversionFileNames are the files an artifact can be converted to, which its artifact pod serves
+func conversionFileNames(artifactFileName string) []string {
+	_, stem, ext, ok := convertibleImage(artifactFileName)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, format := range automotivev1.ArtifactConversionFormats {
+		if format != ext {
+			names = append(names, stem+"."+format)
+		}
+	}
+	return names
+}
+
+func conversionPodName(imageBuild *automotivev1.ImageBuild, format string) string {
+	return fmt.Sprintf("%s-convert-%s", imageBuild.Name, format)
+}
+
+// syncConversions runs the conversions of a served artifact the spec requests and records their progress
+// in the status. It returns whether any is still in progress.
+func (r *ImageBuildReconciler) syncConversions(ctx context.Context, imageBuild *automotivev1.ImageBuild) (bool, error) {
+	if len(imageBuild.Spec.Conversions) == 0 {
+		return false, nil
+	}
+
+	inProgress := false
+	conversions := make([]automotivev1.ArtifactConversion, 0, len(imageBuild.Spec.Conversions))
+	for _, format := range imageBuild.Spec.Conversions {
+		conversion := automotivev1.ArtifactConversion{Format: format, Phase: automotivev1.ConversionPending}
+		if i := slices.IndexFunc(imageBuild.Status.Conversions, func(c automotivev1.ArtifactConversion) bool {
+			return c.Format == format
+		}); i >= 0 {
+			conversion = *imageBuild.Status.Conversions[i].DeepCopy()
+		}
+		if conversion.Phase != automotivev1.ConversionCompleted && conversion.Phase != automotivev1.ConversionFailed {
+			if err := r.runConversion(ctx, imageBuild, &conversion); err != nil {
+				return false, err
+			}
+		}
+		inProgress = inProgress || conversion.Phase == automotivev1.ConversionPending || conversion.Phase == automotivev1.ConversionRunning
+		conversions = append(conversions, conversion)
+	}
+	if equality.Semantic.DeepEqual(conversions, imageBuild.Status.Conversions) {
+		return inProgress, nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return false, err
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.Conversions = conversions
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return false, fmt.Errorf("failed to record artifact conversions: %w", err)
+	}
+	imageBuild.Status.Conversions = conversions
+	return inProgress, nil
+}
+
+// runConversion starts the pod converting the artifact to the conversion's format, or updates the conversion
+// from the pod already started
+func (r *ImageBuildReconciler) runConversion(ctx context.Context, imageBuild *automotivev1.ImageBuild, conversion *automotivev1.ArtifactConversion) error {
+	log := r.buildLog(imageBuild).WithValues("format", conversion.Format)
+
+	artifact := r.artifactFileName(ctx, imageBuild)
+	image, stem, ext, ok := convertibleImage(artifact)
+	switch {
+	case imageBuild.Spec.EncryptionKeySecretRef != "":
+		failConversion(conversion, fmt.Sprintf("artifact %s is encrypted", artifact))
+		return nil
+	case !ok:
+		failConversion(conversion, fmt.Sprintf("artifact %s is not a raw or qcow2 disk image", artifact))
+		return nil
+	case conversion.Format == ext:
+		failConversion(conversion, fmt.Sprintf("artifact %s is already a %s image", artifact, ext))
+		return nil
+	case imageBuild.Status.PVCName == "":
+		failConversion(conversion, "the build has no workspace")
+		return nil
+	}
+	conversion.FileName = stem + "." + conversion.Format
+
+	podName := conversionPodName(imageBuild, conversion.Format)
+	pod := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Name: podName, Namespace: imageBuild.Namespace}, pod)
+	if errors.IsNotFound(err) {
+		if conversion.Phase == automotivev1.ConversionRunning {
+			failConversion(conversion, "the conversion pod was deleted")
+			return nil
+		}
+		if err := r.createConversionPod(ctx, imageBuild, podName, image, conversion.FileName, conversion.Format); err != nil {
+			return err
+		}
+		log.Info("Started artifact conversion", "pod", podName)
+		conversion.Phase = automotivev1.ConversionPending
+		conversion.StartTime = ptr.To(metav1.Now())
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error getting conversion pod: %w", err)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		conversion.Phase = automotivev1.ConversionRunning
+	case corev1.PodSucceeded:
+		size, sha256, err := parseConversionResult(terminationMessage(pod))
+		if err != nil {
+			failConversion(conversion, err.Error())
+			return nil
+		}
+		conversion.Phase = automotivev1.ConversionCompleted
+		conversion.Size, conversion.SHA256 = size, sha256
+		conversion.CompletionTime = ptr.To(metav1.Now())
+		log.Info("Artifact conversion completed", "file", conversion.FileName, "size", size)
+		// only failed conversion pods are kept, for their logs
+		if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete conversion pod: %w", err)
+		}
+	case corev1.PodFailed:
+		message := strings.TrimSpace(terminationMessage(pod))
+		if message == "" {
+			message = "conversion pod failed"
+		}
+		failConversion(conversion, message)
+		log.Info("Artifact conversion failed", "pod", podName, "message", message)
+	}
+	return nil
+}
+
+func failConversion(conversion *automotivev1.ArtifactConversion, message string) {
+	conversion.Phase = automotivev1.ConversionFailed
+	conversion.Message = message
+	conversion.CompletionTime = ptr.To(metav1.Now())
+}
+
+// terminationMessage is the termination message of the pod's first terminated container
+func terminationMessage(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			return status.State.Terminated.Message
+		}
+	}
+	return ""
+}
+
+// parseConversionResult parses the "size=N sha256=HEX" line the conversion script writes on success
+func parseConversionResult(result string) (int64, string, error) {
+	var size int64
+	var sha256 string
+	for _, field := range strings.Fields(result) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "size":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return 0, "", fmt.Errorf("malformed size in conversion result: %w", err)
+			}
+			size = n
+		case "sha256":
+			sha256 = value
+		}
+	}
+	if size <= 0 || sha256 == "" {
+		return 0, "", fmt.Errorf("conversion finished without reporting its output: %q", result)
+	}
+	return size, sha256, nil
+}
+
+// createConversionPod starts the pod converting image to output in the build's workspace. The workspace
+// volume may only be attached to one node, so the pod runs next to the artifact pod.
+func (r *ImageBuildReconciler) createConversionPod(ctx context.Context, imageBuild *automotivev1.ImageBuild, podName, image, output, format string) error {
+	if err := r.ensureHelperServiceAccount(ctx, imageBuild.Namespace); err != nil {
+		return err
+	}
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return err
+	}
+	// the builder image the build ran with has qemu-img and img2simg
+	builderImage, err := r.resolveBuilderImage(ctx, imageBuild, buildConfig)
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by":                    "automotive-dev-operator",
+		"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
+		"app.kubernetes.io/name":                          "artifact-conversion",
+	}
+	userlabels.Apply(labels, imageBuild.Labels)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        podName,
+			Namespace:   imageBuild.Namespace,
+			Labels:      labels,
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         imageBuild.APIVersion,
+					Kind:               imageBuild.Kind,
+					Name:               imageBuild.Name,
+					UID:                imageBuild.UID,
+					Controller:         ptr.To(true),
+					BlockOwnerDeletion: ptr.To(true),
+				},
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: HelperServiceAccountName,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser:    ptr.To[int64](1000),
+				RunAsGroup:   ptr.To[int64](1000),
+				FSGroup:      ptr.To[int64](1000),
+				RunAsNonRoot: ptr.To(true),
+			},
+			Affinity: &corev1.Affinity{
+				PodAffinity: &corev1.PodAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{
+							"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
+							"app.kubernetes.io/name":                          "artifact-pod",
+						}},
+						TopologyKey: corev1.LabelHostname,
+					}},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    "convert",
+					Image:   builderImage,
+					Command: []string{"sh", "-c", tasks.ConvertArtifactScript, "sh"},
+					Args: []string{
+						"/workspace/shared", image, r.artifactFileName(ctx, imageBuild), output, format,
+					},
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("500m"),
+							corev1.ResourceMemory: resource.MustParse("256Mi"),
+						},
+						Limits: corev1.ResourceList{
+							corev1.ResourceMemory: resource.MustParse("2Gi"),
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "workspace",
+							MountPath: "/workspace/shared",
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "workspace",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: imageBuild.Status.PVCName,
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := r.Create(ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create conversion pod: %w", err)
+	}
+	return nil
+}
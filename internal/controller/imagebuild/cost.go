@@ -0,0 +1,188 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	// defaultCurrency is the currency of price tables that name none
+	defaultCurrency = "USD"
+	// hoursPerMonth turns monthly storage prices into hourly ones
+	hoursPerMonth = 730
+)
+
+// priceTable is a BuildPricing with its prices parsed
+type priceTable struct {
+	currency         string
+	defaultHourly    float64
+	hourly           map[string]float64
+	storageGiBHourly float64
+}
+
+func parsePrice(field, value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 {
+		return 0, fmt.Errorf("invalid %s %q", field, value)
+	}
+	return price, nil
+}
+
+func parsePricing(pricing *automotivev1.BuildPricing) (*priceTable, error) {
+	table := &priceTable{currency: pricing.Currency, hourly: map[string]float64{}}
+	if table.currency == "" {
+		table.currency = defaultCurrency
+	}
+	var err error
+	if table.defaultHourly, err = parsePrice("defaultHourlyPrice", pricing.DefaultHourlyPrice); err != nil {
+		return nil, err
+	}
+	monthly, err := parsePrice("storageGiBMonthPrice", pricing.StorageGiBMonthPrice)
+	if err != nil {
+		return nil, err
+	}
+	table.storageGiBHourly = monthly / hoursPerMonth
+	for _, it := range pricing.InstanceTypes {
+		price, err := parsePrice("hourlyPrice of "+it.InstanceType, it.HourlyPrice)
+		if err != nil {
+			return nil, err
+		}
+		table.hourly[it.InstanceType] = price
+	}
+	return table, nil
+}
+
+func (t *priceTable) hourlyPrice(instanceType string) float64 {
+	if price, ok := t.hourly[instanceType]; ok {
+		return price
+	}
+	return t.defaultHourly
+}
+
+// formatAmount renders an amount of a BuildCost
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 4, 64)
+}
+
+// podRunTime is how long a pod ran: from its start to the end of its last container, or to now while one
+// still runs
+func podRunTime(pod *corev1.Pod, now time.Time) time.Duration {
+	if pod.Status.StartTime == nil {
+		return 0
+	}
+	var end time.Time
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, cs := range statuses {
+			if cs.State.Terminated == nil {
+				if cs.State.Running != nil {
+					end = now
+				}
+				continue
+			}
+			if finished := cs.State.Terminated.FinishedAt.Time; finished.After(end) {
+				end = finished
+			}
+		}
+	}
+	if end.IsZero() {
+		end = now
+	}
+	if d := end.Sub(pod.Status.StartTime.Time); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// recordCost estimates the cost of a build whose run finished from the AutomotiveDev's price table and stores
+// it in the status. Pods whose node is gone are priced at the default hourly price.
+func (r *ImageBuildReconciler) recordCost(ctx context.Context, imageBuild *automotivev1.ImageBuild) error {
+	if imageBuild.Status.Cost != nil {
+		return nil
+	}
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if buildConfig == nil || buildConfig.Pricing == nil {
+		return nil
+	}
+	prices, err := parsePricing(buildConfig.Pricing)
+	if err != nil {
+		r.buildLog(imageBuild).Error(err, "ignoring the AutomotiveDev's build pricing")
+		return nil
+	}
+
+	now := time.Now()
+	pods, err := r.buildPods(ctx, imageBuild)
+	if err != nil {
+		return err
+	}
+	var compute float64
+	var instanceTypes []string
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		instanceType := ""
+		node := &corev1.Node{}
+		if err := r.Get(ctx, types.NamespacedName{Name: pod.Spec.NodeName}, node); err == nil {
+			instanceType = node.Labels[corev1.LabelInstanceTypeStable]
+		} else if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get node %s: %w", pod.Spec.NodeName, err)
+		}
+		if instanceType != "" && !slices.Contains(instanceTypes, instanceType) {
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+		compute += prices.hourlyPrice(instanceType) * podRunTime(pod, now).Hours()
+	}
+
+	var storage float64
+	if imageBuild.Status.PVCName != "" && imageBuild.Status.StartTime != nil && prices.storageGiBHourly > 0 {
+		pvc := &corev1.PersistentVolumeClaim{}
+		err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Status.PVCName, Namespace: imageBuild.Namespace}, pvc)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get workspace PVC %s: %w", imageBuild.Status.PVCName, err)
+		}
+		if err == nil {
+			size, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+			if !ok {
+				size = pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			}
+			gib := float64(size.Value()) / (1 << 30)
+			storage = gib * prices.storageGiBHourly * now.Sub(imageBuild.Status.StartTime.Time).Hours()
+		}
+	}
+	slices.Sort(instanceTypes)
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.Cost = &automotivev1.BuildCost{
+		Total:         formatAmount(compute + storage),
+		Compute:       formatAmount(compute),
+		Storage:       formatAmount(storage),
+		Currency:      prices.currency,
+		InstanceTypes: instanceTypes,
+	}
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return err
+	}
+	imageBuild.Status.Cost = fresh.Status.Cost
+	return nil
+}
@@ -0,0 +1,80 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// downloadInfo describes how to retrieve the outputs of a completed build served until expiryAt. routeURL
+// is the base URL of the build's artifact Route, or "" if it has none. It returns nil while the artifact
+// cannot be downloaded.
+func downloadInfo(imageBuild *automotivev1.ImageBuild, routeURL string, expiryAt time.Time) *automotivev1.DownloadInfo {
+	fileName := imageBuild.Status.ArtifactFileName
+	if fileName == "" || (imageBuild.Status.Scan != nil && imageBuild.Status.Scan.Blocked) {
+		return nil
+	}
+	info := &automotivev1.DownloadInfo{
+		APIPath:        fmt.Sprintf("/v1/builds/%s/artifact/%s", imageBuild.Name, fileName),
+		ArchiveAPIPath: fmt.Sprintf("/v1/builds/%s/artifacts.tar", imageBuild.Name),
+		Artifacts:      []string{fileName, fileName + ".metadata.json"},
+		ExpiryTime:     metav1.NewTime(expiryAt.UTC().Truncate(time.Second)),
+	}
+	if routeURL != "" && artifactFileNamePattern.MatchString(fileName) {
+		info.RouteURL = routeURL + "/" + fileName
+	}
+	if imageBuild.Status.Scan != nil && imageBuild.Status.Scan.ReportFileName != "" {
+		info.Artifacts = append(info.Artifacts, imageBuild.Status.Scan.ReportFileName)
+	}
+	for _, c := range imageBuild.Status.Conversions {
+		if c.Phase == automotivev1.ConversionCompleted {
+			info.Artifacts = append(info.Artifacts, c.FileName)
+		}
+	}
+	return info
+}
+
+// artifactRouteURL returns the base URL of the build's artifact Route, Ingress or HTTPRoute, or "" until it
+// has a host
+func (r *ImageBuildReconciler) artifactRouteURL(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, error) {
+	if !imageBuild.Spec.ExposeRoute {
+		return "", nil
+	}
+	exposure, err := r.getExposure(ctx)
+	if err != nil {
+		return "", err
+	}
+	url, err := r.exposedURL(ctx, exposure, artifactExposureName(imageBuild), imageBuild.Namespace)
+	if err != nil {
+		return "", fmt.Errorf("error getting artifact %s: %w", exposure.Type, err)
+	}
+	return url, nil
+}
+
+// syncDownloadInfo records in the status how to retrieve the outputs of a completed build served until
+// expiryAt, so the ImageBuild alone tells users where its artifact is
+func (r *ImageBuildReconciler) syncDownloadInfo(ctx context.Context, imageBuild *automotivev1.ImageBuild, expiryAt time.Time) error {
+	routeURL, err := r.artifactRouteURL(ctx, imageBuild)
+	if err != nil {
+		return err
+	}
+	info := downloadInfo(imageBuild, routeURL, expiryAt)
+	if equality.Semantic.DeepEqual(info, imageBuild.Status.Download) {
+		return nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.Download = info
+	return r.Status().Patch(ctx, fresh, patch)
+}
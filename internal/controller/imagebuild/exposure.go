@@ -0,0 +1,265 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+
+	routev1 "github.com/openshift/api/route/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+
+// buildAPIExposureName is the Route, Ingress or HTTPRoute the build API is reached through
+const buildAPIExposureName = "ado-build-api"
+
+// artifactServicePort is the port of the artifact Service that Ingresses and HTTPRoutes point at
+const artifactServicePort = 8080
+
+// httpRouteGVK is the Gateway API's HTTPRoute, used unstructured so the operator does not depend on its API
+// and runs on clusters without it
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// getExposure returns the AutomotiveDev's exposure, Routes when it sets none
+func (r *ImageBuildReconciler) getExposure(ctx context.Context) (*automotivev1.Exposure, error) {
+	autoDev := &automotivev1.AutomotiveDev{}
+	err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get AutomotiveDev configuration: %w", err)
+	}
+	exposure := &automotivev1.Exposure{}
+	if err == nil && autoDev.Spec.Exposure != nil {
+		exposure = autoDev.Spec.Exposure.DeepCopy()
+	}
+	switch exposure.Type {
+	case "":
+		exposure.Type = automotivev1.ExposureRoute
+	case automotivev1.ExposureRoute:
+	case automotivev1.ExposureIngress, automotivev1.ExposureGateway:
+		if exposure.Domain == "" {
+			return nil, fmt.Errorf("exposure type %s requires a domain", exposure.Type)
+		}
+		if exposure.Type == automotivev1.ExposureGateway && (exposure.Gateway == nil || exposure.Gateway.Name == "") {
+			return nil, fmt.Errorf("exposure type Gateway requires the name of a gateway")
+		}
+	default:
+		return nil, fmt.Errorf("unknown exposure type %q", exposure.Type)
+	}
+	return exposure, nil
+}
+
+// exposureObject returns an empty object of the kind exposure exposes services with
+func exposureObject(exposure *automotivev1.Exposure) client.Object {
+	switch exposure.Type {
+	case automotivev1.ExposureIngress:
+		return &networkingv1.Ingress{}
+	case automotivev1.ExposureGateway:
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		return route
+	default:
+		return &routev1.Route{}
+	}
+}
+
+// exposedURL returns the base URL of the Route, Ingress or HTTPRoute of that name, or "" while it does not
+// exist or has no host yet
+func (r *ImageBuildReconciler) exposedURL(ctx context.Context, exposure *automotivev1.Exposure, name, namespace string) (string, error) {
+	obj := exposureObject(exposure)
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	scheme, host := "https", ""
+	switch o := obj.(type) {
+	case *routev1.Route:
+		if len(o.Status.Ingress) > 0 {
+			host = o.Status.Ingress[0].Host
+		}
+		if o.Spec.TLS == nil {
+			scheme = "http"
+		}
+	case *networkingv1.Ingress:
+		if len(o.Spec.Rules) > 0 {
+			host = o.Spec.Rules[0].Host
+		}
+		if host == "" && len(o.Status.LoadBalancer.Ingress) > 0 {
+			lb := o.Status.LoadBalancer.Ingress[0]
+			host = lb.Hostname
+			if host == "" {
+				host = lb.IP
+			}
+		}
+		if len(o.Spec.TLS) == 0 {
+			scheme = "http"
+		}
+	case *unstructured.Unstructured:
+		hostnames, _, _ := unstructured.NestedStringSlice(o.Object, "spec", "hostnames")
+		if len(hostnames) > 0 {
+			host = hostnames[0]
+		}
+		if exposure.Gateway == nil || !exposure.Gateway.HTTPS {
+			scheme = "http"
+		}
+	}
+	if host == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("%s://%s", scheme, host), nil
+}
+
+// artifactExposureName is the Route, Ingress or HTTPRoute exposing the artifact of a build
+func artifactExposureName(imageBuild *automotivev1.ImageBuild) string {
+	return fmt.Sprintf("%s-artifacts", imageBuild.Name)
+}
+
+// artifactHost is the host a build's artifact is served at with Ingresses and HTTPRoutes
+func artifactHost(imageBuild *automotivev1.ImageBuild, exposure *automotivev1.Exposure) string {
+	return fmt.Sprintf("%s-%s.%s", artifactExposureName(imageBuild), imageBuild.Namespace, exposure.Domain)
+}
+
+// newArtifactExposure returns the Route, Ingress or HTTPRoute exposing the artifact Service svcName, whose
+// pods serve on port
+func newArtifactExposure(imageBuild *automotivev1.ImageBuild, exposure *automotivev1.Exposure, svcName string, port int,
+	auth automotivev1.RouteAuth, labels map[string]string) (client.Object, error) {
+	if auth.Type == automotivev1.RouteAuthOAuth && exposure.Type != automotivev1.ExposureRoute {
+		return nil, fmt.Errorf("route auth OAuth requires Route exposure, not %s", exposure.Type)
+	}
+
+	var obj client.Object
+	switch exposure.Type {
+	case automotivev1.ExposureIngress:
+		obj = newArtifactIngress(imageBuild, exposure, svcName)
+	case automotivev1.ExposureGateway:
+		obj = newArtifactHTTPRoute(imageBuild, exposure, svcName)
+	default:
+		obj = &routev1.Route{
+			Spec: routev1.RouteSpec{
+				To: routev1.RouteTargetReference{
+					Kind: "Service",
+					Name: svcName,
+				},
+				Port: &routev1.RoutePort{
+					TargetPort: intstr.FromInt(port),
+				},
+				TLS: artifactRouteTLS(auth),
+			},
+		}
+	}
+	obj.SetName(artifactExposureName(imageBuild))
+	obj.SetNamespace(imageBuild.Namespace)
+	obj.SetLabels(labels)
+	if exposure.Type != automotivev1.ExposureRoute && len(exposure.Annotations) > 0 {
+		obj.SetAnnotations(exposure.Annotations)
+	}
+	obj.SetOwnerReferences([]metav1.OwnerReference{
+		{
+			APIVersion:         imageBuild.APIVersion,
+			Kind:               imageBuild.Kind,
+			Name:               imageBuild.Name,
+			UID:                imageBuild.UID,
+			Controller:         ptr.To(true),
+			BlockOwnerDeletion: ptr.To(true),
+		},
+	})
+	return obj, nil
+}
+
+func newArtifactIngress(imageBuild *automotivev1.ImageBuild, exposure *automotivev1.Exposure, svcName string) *networkingv1.Ingress {
+	host := artifactHost(imageBuild, exposure)
+	ingress := &networkingv1.Ingress{
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: ptr.To(networkingv1.PathTypePrefix),
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: svcName,
+											Port: networkingv1.ServiceBackendPort{Number: artifactServicePort},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if cfg := exposure.Ingress; cfg != nil {
+		if cfg.ClassName != "" {
+			ingress.Spec.IngressClassName = ptr.To(cfg.ClassName)
+		}
+		if cfg.TLSSecretName != "" {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{host}, SecretName: cfg.TLSSecretName}}
+		}
+	}
+	return ingress
+}
+
+func newArtifactHTTPRoute(imageBuild *automotivev1.ImageBuild, exposure *automotivev1.Exposure, svcName string) *unstructured.Unstructured {
+	parent := map[string]any{"name": exposure.Gateway.Name}
+	if exposure.Gateway.Namespace != "" {
+		parent["namespace"] = exposure.Gateway.Namespace
+	}
+	if exposure.Gateway.SectionName != "" {
+		parent["sectionName"] = exposure.Gateway.SectionName
+	}
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"parentRefs": []any{parent},
+			"hostnames":  []any{artifactHost(imageBuild, exposure)},
+			"rules": []any{
+				map[string]any{
+					"backendRefs": []any{
+						map[string]any{"name": svcName, "port": int64(artifactServicePort)},
+					},
+				},
+			},
+		},
+	}}
+	route.SetGroupVersionKind(httpRouteGVK)
+	return route
+}
+
+// artifactExposureObjects are the objects of every kind that may expose a build's artifact, so that
+// deleting them also catches those created before the exposure type changed
+func artifactExposureObjects(imageBuild *automotivev1.ImageBuild) []client.Object {
+	var objs []client.Object
+	for _, t := range []string{automotivev1.ExposureRoute, automotivev1.ExposureIngress, automotivev1.ExposureGateway} {
+		obj := exposureObject(&automotivev1.Exposure{Type: t})
+		obj.SetName(artifactExposureName(imageBuild))
+		obj.SetNamespace(imageBuild.Namespace)
+		objs = append(objs, obj)
+	}
+	return objs
+}
+
+// ignoreMissing drops the errors of objects, or whole APIs, that do not exist
+func ignoreMissing(err error) error {
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	return err
+}
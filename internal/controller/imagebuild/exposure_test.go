@@ -0,0 +1,242 @@
+package imagebuild
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	routev1 "github.com/openshift/api/route/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Artifact exposure", func() {
+	ctx := context.Background()
+	const host = "radio-artifacts-ns.apps.example.com"
+
+	imageBuild := &automotivev1.ImageBuild{
+		TypeMeta:   metav1.TypeMeta{APIVersion: automotivev1.GroupVersion.String(), Kind: "ImageBuild"},
+		ObjectMeta: metav1.ObjectMeta{Name: "radio", Namespace: "ns", UID: "uid"},
+	}
+	labels := map[string]string{"app.kubernetes.io/name": "artifact-server"}
+	none := automotivev1.RouteAuth{Type: automotivev1.RouteAuthNone}
+
+	withExposure := func(exposure *automotivev1.Exposure) *automotivev1.AutomotiveDev {
+		return &automotivev1.AutomotiveDev{
+			ObjectMeta: metav1.ObjectMeta{Name: "automotive-dev", Namespace: OperatorNamespace},
+			Spec:       automotivev1.AutomotiveDevSpec{Exposure: exposure},
+		}
+	}
+
+	Describe("getExposure", func() {
+		It("should expose with Routes without an AutomotiveDev", func() {
+			exposure, err := newTestReconciler().getExposure(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exposure.Type).To(Equal(automotivev1.ExposureRoute))
+		})
+
+		DescribeTable("the AutomotiveDev's exposure",
+			func(exposure *automotivev1.Exposure, expectedType, rejected string) {
+				got, err := newTestReconciler(withExposure(exposure)).getExposure(ctx)
+				if rejected != "" {
+					Expect(err).To(MatchError(ContainSubstring(rejected)))
+					return
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(got.Type).To(Equal(expectedType))
+			},
+			Entry("Routes when it sets none", nil, automotivev1.ExposureRoute, ""),
+			Entry("Routes when it sets no type", &automotivev1.Exposure{}, automotivev1.ExposureRoute, ""),
+			Entry("Routes", &automotivev1.Exposure{Type: automotivev1.ExposureRoute}, automotivev1.ExposureRoute, ""),
+			Entry("Ingresses",
+				&automotivev1.Exposure{Type: automotivev1.ExposureIngress, Domain: "apps.example.com"},
+				automotivev1.ExposureIngress, ""),
+			Entry("Ingresses without a domain",
+				&automotivev1.Exposure{Type: automotivev1.ExposureIngress}, "", "exposure type Ingress requires a domain"),
+			Entry("HTTPRoutes",
+				&automotivev1.Exposure{Type: automotivev1.ExposureGateway, Domain: "apps.example.com",
+					Gateway: &automotivev1.GatewayExposure{Name: "gw"}},
+				automotivev1.ExposureGateway, ""),
+			Entry("HTTPRoutes without a domain",
+				&automotivev1.Exposure{Type: automotivev1.ExposureGateway, Gateway: &automotivev1.GatewayExposure{Name: "gw"}},
+				"", "exposure type Gateway requires a domain"),
+			Entry("HTTPRoutes without a gateway",
+				&automotivev1.Exposure{Type: automotivev1.ExposureGateway, Domain: "apps.example.com"},
+				"", "requires the name of a gateway"),
+			Entry("an unknown type", &automotivev1.Exposure{Type: "NodePort"}, "", `unknown exposure type "NodePort"`),
+		)
+	})
+
+	Describe("newArtifactExposure", func() {
+		expectOwnedByBuild := func(obj client.Object) {
+			Expect(obj.GetName()).To(Equal("radio-artifacts"))
+			Expect(obj.GetNamespace()).To(Equal("ns"))
+			Expect(obj.GetLabels()).To(Equal(labels))
+			Expect(obj.GetOwnerReferences()).To(HaveLen(1))
+			Expect(obj.GetOwnerReferences()[0].Name).To(Equal("radio"))
+			Expect(*obj.GetOwnerReferences()[0].Controller).To(BeTrue())
+		}
+
+		It("should create a Route to the artifact Service", func() {
+			exposure := &automotivev1.Exposure{Type: automotivev1.ExposureRoute, Annotations: map[string]string{"a": "b"}}
+
+			obj, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, none, labels)
+			Expect(err).NotTo(HaveOccurred())
+			expectOwnedByBuild(obj)
+			route, ok := obj.(*routev1.Route)
+			Expect(ok).To(BeTrue())
+			Expect(route.Spec.To.Name).To(Equal("radio-artifacts"))
+			Expect(route.Spec.Port.TargetPort).To(Equal(intstr.FromInt(fileServerPort)))
+			Expect(route.Spec.Host).To(BeEmpty(), "OpenShift assigns the hosts of Routes")
+			Expect(route.GetAnnotations()).To(BeEmpty(), "annotations are for Ingresses and HTTPRoutes")
+		})
+
+		It("should create an Ingress for the build's host", func() {
+			exposure := &automotivev1.Exposure{
+				Type:        automotivev1.ExposureIngress,
+				Domain:      "apps.example.com",
+				Annotations: map[string]string{"cert-manager.io/cluster-issuer": "letsencrypt"},
+				Ingress:     &automotivev1.IngressExposure{ClassName: "nginx", TLSSecretName: "wildcard"},
+			}
+
+			obj, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, none, labels)
+			Expect(err).NotTo(HaveOccurred())
+			expectOwnedByBuild(obj)
+			ingress, ok := obj.(*networkingv1.Ingress)
+			Expect(ok).To(BeTrue())
+			Expect(ingress.GetAnnotations()).To(HaveKeyWithValue("cert-manager.io/cluster-issuer", "letsencrypt"))
+			Expect(*ingress.Spec.IngressClassName).To(Equal("nginx"))
+			Expect(ingress.Spec.TLS).To(ConsistOf(networkingv1.IngressTLS{Hosts: []string{host}, SecretName: "wildcard"}))
+			Expect(ingress.Spec.Rules).To(HaveLen(1))
+			Expect(ingress.Spec.Rules[0].Host).To(Equal(host))
+			backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+			Expect(backend.Name).To(Equal("radio-artifacts"))
+			Expect(backend.Port.Number).To(BeEquivalentTo(artifactServicePort))
+		})
+
+		It("should serve plain HTTP Ingresses without a TLS secret", func() {
+			exposure := &automotivev1.Exposure{Type: automotivev1.ExposureIngress, Domain: "apps.example.com"}
+
+			obj, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, none, labels)
+			Expect(err).NotTo(HaveOccurred())
+			ingress := obj.(*networkingv1.Ingress)
+			Expect(ingress.Spec.TLS).To(BeEmpty())
+			Expect(ingress.Spec.IngressClassName).To(BeNil())
+		})
+
+		It("should create an HTTPRoute attached to the gateway", func() {
+			exposure := &automotivev1.Exposure{
+				Type:    automotivev1.ExposureGateway,
+				Domain:  "apps.example.com",
+				Gateway: &automotivev1.GatewayExposure{Name: "gw", Namespace: "gateways", SectionName: "https"},
+			}
+
+			obj, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, none, labels)
+			Expect(err).NotTo(HaveOccurred())
+			expectOwnedByBuild(obj)
+			route, ok := obj.(*unstructured.Unstructured)
+			Expect(ok).To(BeTrue())
+			Expect(route.GroupVersionKind()).To(Equal(httpRouteGVK))
+			parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+			Expect(parents).To(ConsistOf(map[string]any{"name": "gw", "namespace": "gateways", "sectionName": "https"}))
+			hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+			Expect(hostnames).To(ConsistOf(host))
+			rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+			Expect(rules).To(ConsistOf(map[string]any{
+				"backendRefs": []any{map[string]any{"name": "radio-artifacts", "port": int64(artifactServicePort)}},
+			}))
+		})
+
+		DescribeTable("should refuse OAuth route auth without Routes",
+			func(exposure *automotivev1.Exposure) {
+				oauth := automotivev1.RouteAuth{Type: automotivev1.RouteAuthOAuth}
+				_, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, oauth, labels)
+				Expect(err).To(MatchError(ContainSubstring("route auth OAuth requires Route exposure")))
+			},
+			Entry("with Ingresses", &automotivev1.Exposure{Type: automotivev1.ExposureIngress, Domain: "apps.example.com"}),
+			Entry("with HTTPRoutes", &automotivev1.Exposure{Type: automotivev1.ExposureGateway, Domain: "apps.example.com",
+				Gateway: &automotivev1.GatewayExposure{Name: "gw"}}),
+		)
+	})
+
+	Describe("exposedURL", func() {
+		url := func(exposure *automotivev1.Exposure, objs ...client.Object) string {
+			u, err := newTestReconciler(objs...).exposedURL(ctx, exposure, "radio-artifacts", "ns")
+			Expect(err).NotTo(HaveOccurred())
+			return u
+		}
+		meta := metav1.ObjectMeta{Name: "radio-artifacts", Namespace: "ns"}
+
+		It("should be empty while nothing is exposed", func() {
+			Expect(url(&automotivev1.Exposure{Type: automotivev1.ExposureRoute})).To(BeEmpty())
+			Expect(url(&automotivev1.Exposure{Type: automotivev1.ExposureIngress})).To(BeEmpty())
+		})
+
+		It("should use the host OpenShift admitted the Route with", func() {
+			route := &routev1.Route{ObjectMeta: meta, Spec: routev1.RouteSpec{TLS: &routev1.TLSConfig{}}}
+			exposure := &automotivev1.Exposure{Type: automotivev1.ExposureRoute}
+			Expect(url(exposure, route)).To(BeEmpty(), "a Route is not reachable before it is admitted")
+
+			route.Status.Ingress = []routev1.RouteIngress{{Host: "radio-artifacts-ns.apps.cluster"}}
+			Expect(url(exposure, route)).To(Equal("https://radio-artifacts-ns.apps.cluster"))
+
+			route.Spec.TLS = nil
+			Expect(url(exposure, route)).To(Equal("http://radio-artifacts-ns.apps.cluster"))
+		})
+
+		It("should use the host of the Ingress's rule", func() {
+			ingress := &networkingv1.Ingress{ObjectMeta: meta, Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{Host: host}},
+				TLS:   []networkingv1.IngressTLS{{Hosts: []string{host}, SecretName: "wildcard"}},
+			}}
+			exposure := &automotivev1.Exposure{Type: automotivev1.ExposureIngress, Domain: "apps.example.com"}
+			Expect(url(exposure, ingress)).To(Equal("https://" + host))
+
+			ingress.Spec.TLS = nil
+			Expect(url(exposure, ingress)).To(Equal("http://" + host))
+		})
+
+		It("should fall back to the Ingress's load balancer", func() {
+			ingress := &networkingv1.Ingress{ObjectMeta: meta, Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{}},
+			}}
+			ingress.Status.LoadBalancer.Ingress = []networkingv1.IngressLoadBalancerIngress{{IP: "192.0.2.10"}}
+
+			Expect(url(&automotivev1.Exposure{Type: automotivev1.ExposureIngress}, ingress)).To(Equal("http://192.0.2.10"))
+		})
+
+		It("should use the HTTPRoute's hostname, over HTTPS if the gateway terminates TLS", func() {
+			exposure := &automotivev1.Exposure{
+				Type:    automotivev1.ExposureGateway,
+				Domain:  "apps.example.com",
+				Gateway: &automotivev1.GatewayExposure{Name: "gw"},
+			}
+			obj, err := newArtifactExposure(imageBuild, exposure, "radio-artifacts", fileServerPort, none, labels)
+			Expect(err).NotTo(HaveOccurred())
+			obj.SetOwnerReferences(nil)
+
+			Expect(url(exposure, obj)).To(Equal("http://" + host))
+
+			exposure.Gateway.HTTPS = true
+			Expect(url(exposure, obj)).To(Equal("https://" + host))
+		})
+	})
+
+	It("should name an object of every exposure kind for cleanup", func() {
+		objs := artifactExposureObjects(imageBuild)
+
+		Expect(objs).To(HaveLen(3))
+		Expect(objs[0]).To(BeAssignableToTypeOf(&routev1.Route{}))
+		Expect(objs[1]).To(BeAssignableToTypeOf(&networkingv1.Ingress{}))
+		Expect(objs[2].GetObjectKind().GroupVersionKind()).To(Equal(httpRouteGVK))
+		for _, obj := range objs {
+			Expect(obj.GetName()).To(Equal("radio-artifacts"))
+			Expect(obj.GetNamespace()).To(Equal("ns"))
+		}
+	})
+})
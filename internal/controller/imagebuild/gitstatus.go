@@ -0,0 +1,96 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/gitstatus"
+)
+
+// commitStatusTimeout bounds a commit status request, which holds up the reconcile that sends it
+const commitStatusTimeout = 10 * time.Second
+
+// commitState is the commit status of a build in phase, or "" before the build starts
+func commitState(phase string) gitstatus.State {
+	switch phase {
+	case "":
+		return ""
+	case "Completed":
+		return gitstatus.Success
+	case "Failed":
+		return gitstatus.Failure
+	default:
+		return gitstatus.Pending
+	}
+}
+
+// reportCommitStatus sets the status of the commit a Git webhook started the build for when the build moves
+// from previousPhase into a phase of another commit state. Statuses are only reported for repositories of
+// the AutomotiveDev's Git triggers. Failures are logged: the build does not depend on them.
+func (r *ImageBuildReconciler) reportCommitStatus(ctx context.Context, imageBuild *automotivev1.ImageBuild, previousPhase string) {
+	commit, ok := gitstatus.FromAnnotations(imageBuild.Annotations)
+	state := commitState(imageBuild.Status.Phase)
+	if !ok || state == commitState(previousPhase) {
+		return
+	}
+	log := r.buildLog(imageBuild).WithValues("repository", commit.Repository, "commit", commit.SHA)
+
+	autoDev := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev); err != nil {
+		log.Error(err, "Failed to get AutomotiveDev; commit status not reported")
+		return
+	}
+	hooks := autoDev.Spec.GitHooks
+	if hooks == nil || hooks.Status == nil {
+		return
+	}
+	if !slices.ContainsFunc(hooks.Triggers, func(t automotivev1.GitTrigger) bool {
+		return gitstatus.SameRepository(t.Repository, commit.Repository)
+	}) {
+		log.Info("Repository has no Git trigger; commit status not reported")
+		return
+	}
+
+	token, err := r.gitStatusToken(ctx, hooks.Status.TokenSecretRef)
+	if err != nil {
+		log.Error(err, "Commit status not reported")
+		return
+	}
+	statusContext := hooks.Status.Context
+	if statusContext == "" {
+		statusContext = gitstatus.DefaultContext
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commitStatusTimeout)
+	defer cancel()
+	reporter := &gitstatus.Reporter{APIURL: hooks.Status.APIURL, Token: token, HTTPClient: r.HTTPClient}
+	err = reporter.Report(ctx, commit, gitstatus.Status{
+		State:       state,
+		Context:     statusContext,
+		Description: fmt.Sprintf("%s: %s", imageBuild.Name, imageBuild.Status.Message),
+	})
+	if err != nil {
+		log.Error(err, "Failed to report commit status", "state", state)
+		return
+	}
+	log.Info("Commit status reported", "state", state)
+}
+
+// gitStatusToken reads the token of the secret GitStatusReporting names
+func (r *ImageBuildReconciler) gitStatusToken(ctx context.Context, secretName string) (string, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: OperatorNamespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get git status token secret %s: %w", secretName, err)
+	}
+	token := string(secret.Data["token"])
+	if token == "" {
+		return "", fmt.Errorf("git status token secret %s has no token key", secretName)
+	}
+	return token, nil
+}
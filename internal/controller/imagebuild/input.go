@@ -0,0 +1,50 @@
+package imagebuild
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+)
+
+// errInputRejected marks input claims a build can never mount, so the build fails instead of its pod waiting
+// for a volume forever
+var errInputRejected = stderrors.New("input claim rejected")
+
+func isInputRejected(err error) bool {
+	return stderrors.Is(err, errInputRejected)
+}
+
+// inputWorkspace binds the input workspace of the build task to the build's InputPVC, once the claim exists
+func (r *ImageBuildReconciler) inputWorkspace(ctx context.Context, imageBuild *automotivev1.ImageBuild) (tektonv1.WorkspaceBinding, error) {
+	input := imageBuild.Spec.InputPVC
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, types.NamespacedName{Name: input.ClaimName, Namespace: imageBuild.Namespace}, pvc); err != nil {
+		if errors.IsNotFound(err) {
+			return tektonv1.WorkspaceBinding{}, fmt.Errorf("%w: persistentvolumeclaim %s not found", errInputRejected, input.ClaimName)
+		}
+		return tektonv1.WorkspaceBinding{}, fmt.Errorf("failed to get input claim %s: %w", input.ClaimName, err)
+	}
+	// the workspaces of other builds are theirs alone
+	if build, ok := pvc.Labels[storage.ImageBuildNameLabel]; ok {
+		return tektonv1.WorkspaceBinding{}, fmt.Errorf("%w: persistentvolumeclaim %s is the workspace of build %s", errInputRejected, input.ClaimName, build)
+	}
+	if pvc.Status.Phase == corev1.ClaimLost {
+		return tektonv1.WorkspaceBinding{}, fmt.Errorf("%w: persistentvolumeclaim %s lost its volume", errInputRejected, input.ClaimName)
+	}
+	return tektonv1.WorkspaceBinding{
+		Name:    "input",
+		SubPath: input.SubPath,
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: input.ClaimName,
+			ReadOnly:  input.ReadOnly,
+		},
+	}, nil
+}
@@ -0,0 +1,152 @@
+package imagebuild
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/intermediateregistry"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/registry"
+)
+
+// intermediateRegistryCleanupInterval is how often repositories of builds that are done are deleted
+const intermediateRegistryCleanupInterval = time.Hour
+
+// Intermediate registry cleanup metrics
+var (
+	intermediateRepositoriesDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "automotive_intermediate_registry_repositories_cleaned_total",
+		Help: "Repositories of the intermediate registry deleted because their build is done with them",
+	})
+	intermediateRegistryCleanupErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "automotive_intermediate_registry_cleanup_errors_total",
+		Help: "Errors listing or deleting repositories of the intermediate registry during cleanup",
+	})
+)
+
+// intermediateRegistryRepository returns the repository a build pushes intermediate content to, "" when the
+// AutomotiveDev does not run the intermediate registry
+func intermediateRegistryRepository(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) string {
+	if !intermediateregistry.Enabled(buildConfig) {
+		return ""
+	}
+	return intermediateregistry.Host(OperatorNamespace) + "/" + intermediateregistry.Repository(imageBuild.Namespace, imageBuild.Name)
+}
+
+// needsIntermediateRepository reports whether a build may still use its repository: while it runs, and while
+// it serves its artifact
+func needsIntermediateRepository(imageBuild *automotivev1.ImageBuild) bool {
+	if !isFinished(imageBuild.Status.Phase) {
+		return true
+	}
+	if imageBuild.Status.Phase != "Completed" || !imageBuild.Spec.ServeArtifact {
+		return false
+	}
+	serving := meta.FindStatusCondition(imageBuild.Status.Conditions, automotivev1.ImageBuildArtifactServing)
+	return serving == nil || serving.Reason != artifactServingReasonExpired
+}
+
+// IntermediateRegistryCleanup deletes, every hour, the repositories of the intermediate registry whose build is
+// done with them: it finished without serving its artifact, stopped serving it, or no longer exists. The registry
+// reclaims the space of their blobs when it restarts.
+type IntermediateRegistryCleanup struct {
+	// Reader gets the AutomotiveDev and builds without the manager's cache
+	Reader client.Reader
+	Log    logr.Logger
+	// HTTPClient talks to the registry; http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Interval overrides intermediateRegistryCleanupInterval
+	Interval time.Duration
+}
+
+// Start runs the cleanup right away and then every interval until ctx is done
+func (c *IntermediateRegistryCleanup) Start(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = intermediateRegistryCleanupInterval
+	}
+	wait.JitterUntilWithContext(ctx, c.cleanup, interval, 0.1, true)
+	return nil
+}
+
+// NeedLeaderElection keeps replicas of the operator from cleaning up at the same time
+func (c *IntermediateRegistryCleanup) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *IntermediateRegistryCleanup) cleanup(ctx context.Context) {
+	autoDev := &automotivev1.AutomotiveDev{}
+	if err := c.Reader.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev); err != nil {
+		if !errors.IsNotFound(err) {
+			intermediateRegistryCleanupErrors.Inc()
+			c.Log.Error(err, "Failed to get AutomotiveDev configuration")
+		}
+		return
+	}
+	if !intermediateregistry.Enabled(autoDev.Spec.BuildConfig) {
+		return
+	}
+
+	rc := &registry.Client{HTTPClient: c.HTTPClient, Host: intermediateregistry.Host(OperatorNamespace), Insecure: true}
+	repos, err := rc.ListRepositories(ctx)
+	if err != nil {
+		intermediateRegistryCleanupErrors.Inc()
+		c.Log.Error(err, "Failed to list repositories of the intermediate registry")
+		return
+	}
+
+	deleted := 0
+	for _, repo := range repos {
+		namespace, name, ok := intermediateregistry.BuildOf(repo)
+		if !ok {
+			continue
+		}
+		build := &automotivev1.ImageBuild{}
+		err := c.Reader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, build)
+		if err != nil && !errors.IsNotFound(err) {
+			intermediateRegistryCleanupErrors.Inc()
+			c.Log.Error(err, "Failed to check the build of a repository", "repository", repo)
+			continue
+		}
+		if err == nil && needsIntermediateRepository(build) {
+			continue
+		}
+		if err := deleteRepository(ctx, rc, repo); err != nil {
+			intermediateRegistryCleanupErrors.Inc()
+			c.Log.Error(err, "Failed to delete repository of the intermediate registry", "repository", repo)
+			continue
+		}
+		intermediateRepositoriesDeleted.Inc()
+		c.Log.Info("Deleted repository of a build that is done with it", "repository", repo)
+		deleted++
+	}
+	c.Log.V(1).Info("Intermediate registry cleanup finished", "checked", len(repos), "deleted", deleted)
+}
+
+// deleteRepository deletes the manifest of every tag of repo; the registry drops a repository without tags
+// from its catalog once it garbage collects
+func deleteRepository(ctx context.Context, rc *registry.Client, repo string) error {
+	tags, err := rc.ListTags(ctx, repo)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		digest, err := rc.ResolveDigest(ctx, repo, tag)
+		if err != nil {
+			return err
+		}
+		if err := rc.DeleteManifest(ctx, repo, digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
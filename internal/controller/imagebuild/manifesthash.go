@@ -0,0 +1,105 @@
+package imagebuild
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/manifesthash"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errManifestModified marks builds whose manifest ConfigMap was edited after they were created, so they fail
+// instead of building something else than was requested
+var errManifestModified = stderrors.New("manifest modified after the build was created")
+
+func isManifestModified(err error) bool {
+	return stderrors.Is(err, errManifestModified)
+}
+
+// currentManifestSHA256 hashes every file the build's ConfigMap holds now. It returns "" when the build has no
+// manifest ConfigMap.
+func (r *ImageBuildReconciler) currentManifestSHA256(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, error) {
+	if imageBuild.Spec.ManifestConfigMap == "" {
+		return "", nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Spec.ManifestConfigMap, Namespace: imageBuild.Namespace}, cm); err != nil {
+		return "", fmt.Errorf("failed to get manifest ConfigMap %s: %w", imageBuild.Spec.ManifestConfigMap, err)
+	}
+	return manifesthash.Sum(cm), nil
+}
+
+func manifestModifiedError(imageBuild *automotivev1.ImageBuild, current, expected string) error {
+	return fmt.Errorf("%w: ConfigMap %s has sha256 %s, expected %s",
+		errManifestModified, imageBuild.Spec.ManifestConfigMap, current, expected)
+}
+
+// expectedManifestSHA256 returns the manifest hash a build was created with, "" when none was recorded yet
+func expectedManifestSHA256(imageBuild *automotivev1.ImageBuild) string {
+	if imageBuild.Status.ManifestSHA256 != "" {
+		return imageBuild.Status.ManifestSHA256
+	}
+	return imageBuild.Annotations[manifesthash.Annotation]
+}
+
+// recordManifestSHA256 returns the hash of the manifest ConfigMap a build runs, for the build step to verify. The
+// hash the build API annotated the build with is copied to the status; a build created without one snapshots the
+// files its ConfigMap holds when the build starts. A ConfigMap that no longer matches fails the build.
+func (r *ImageBuildReconciler) recordManifestSHA256(ctx context.Context, imageBuild *automotivev1.ImageBuild) (string, error) {
+	current, err := r.currentManifestSHA256(ctx, imageBuild)
+	if err != nil || current == "" {
+		return "", err
+	}
+	expected := expectedManifestSHA256(imageBuild)
+	if expected != "" && expected != current {
+		return "", manifestModifiedError(imageBuild, current, expected)
+	}
+
+	if imageBuild.Annotations[manifesthash.Annotation] == "" {
+		fresh := &automotivev1.ImageBuild{}
+		if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+			return "", fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+		}
+		patch := client.MergeFrom(fresh.DeepCopy())
+		if fresh.Annotations == nil {
+			fresh.Annotations = map[string]string{}
+		}
+		fresh.Annotations[manifesthash.Annotation] = current
+		if err := r.Patch(ctx, fresh, patch); err != nil {
+			return "", fmt.Errorf("failed to annotate manifest hash: %w", err)
+		}
+		imageBuild.Annotations = fresh.Annotations
+	}
+
+	if imageBuild.Status.ManifestSHA256 != current {
+		fresh := &automotivev1.ImageBuild{}
+		if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+			return "", fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+		}
+		patch := client.MergeFrom(fresh.DeepCopy())
+		fresh.Status.ManifestSHA256 = current
+		if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+			return "", fmt.Errorf("failed to record manifest hash: %w", err)
+		}
+		imageBuild.Status.ManifestSHA256 = current
+	}
+	return current, nil
+}
+
+// buildFailedMessage explains why a build's run failed when the controller can tell, which for now is a
+// manifest edited while the build ran
+func (r *ImageBuildReconciler) buildFailedMessage(ctx context.Context, imageBuild *automotivev1.ImageBuild) string {
+	expected := expectedManifestSHA256(imageBuild)
+	if expected == "" {
+		return "Build failed"
+	}
+	current, err := r.currentManifestSHA256(ctx, imageBuild)
+	if err != nil || current == "" || current == expected {
+		return "Build failed"
+	}
+	return fmt.Sprintf("Build failed: %v", manifestModifiedError(imageBuild, current, expected))
+}
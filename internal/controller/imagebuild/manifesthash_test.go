@@ -0,0 +1,93 @@
+package imagebuild
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/manifesthash"
+)
+
+var _ = Describe("Manifest hash", func() {
+	ctx := context.Background()
+
+	var (
+		cm         *corev1.ConfigMap
+		imageBuild *automotivev1.ImageBuild
+	)
+
+	BeforeEach(func() {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "b-manifest", Namespace: "ns"},
+			Data: map[string]string{
+				"main.aib.yml":           "name: demo\n",
+				"include.aib.yml":        "name: included\n",
+				"custom-definitions.env": "A=1",
+				"aib-extra-args.txt":     "--verbose",
+			},
+		}
+		imageBuild = &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "b",
+				Namespace:   "ns",
+				Annotations: map[string]string{manifesthash.Annotation: manifesthash.Sum(cm)},
+			},
+			Spec: automotivev1.ImageBuildSpec{ManifestConfigMap: "b-manifest", ManifestFile: "main.aib.yml"},
+		}
+	})
+
+	It("should record the hash the build was created with", func() {
+		r := newTestReconciler(imageBuild, cm)
+
+		sum, err := r.recordManifestSHA256(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sum).To(Equal(manifesthash.Sum(cm)))
+		stored := &automotivev1.ImageBuild{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(imageBuild), stored)).To(Succeed())
+		Expect(stored.Status.ManifestSHA256).To(Equal(sum))
+	})
+
+	DescribeTable("should fail builds whose ConfigMap changed since",
+		func(edit func(data map[string]string)) {
+			r := newTestReconciler(imageBuild, cm)
+			edit(cm.Data)
+			Expect(r.Update(ctx, cm)).To(Succeed())
+
+			_, err := r.recordManifestSHA256(ctx, imageBuild)
+			Expect(isManifestModified(err)).To(BeTrue(), "got %v", err)
+			Expect(err.Error()).To(ContainSubstring("ConfigMap b-manifest"))
+		},
+		Entry("the main manifest", func(data map[string]string) { data["main.aib.yml"] = "name: other\n" }),
+		Entry("an included manifest", func(data map[string]string) { data["include.aib.yml"] = "name: other\n" }),
+		Entry("the defines", func(data map[string]string) { data["custom-definitions.env"] = "A=2" }),
+		Entry("the AIB args", func(data map[string]string) { data["aib-extra-args.txt"] = "--define-file /etc/x" }),
+		Entry("an added file", func(data map[string]string) { data["aib-override-args.txt"] = "--verbose" }),
+		Entry("a removed file", func(data map[string]string) { delete(data, "custom-definitions.env") }),
+	)
+
+	It("should snapshot the ConfigMap of a build created without a hash", func() {
+		imageBuild.Annotations = nil
+		r := newTestReconciler(imageBuild, cm)
+
+		sum, err := r.recordManifestSHA256(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sum).To(Equal(manifesthash.Sum(cm)))
+		stored := &automotivev1.ImageBuild{}
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(imageBuild), stored)).To(Succeed())
+		Expect(stored.Annotations).To(HaveKeyWithValue(manifesthash.Annotation, sum))
+	})
+
+	It("should not hash builds without a manifest ConfigMap", func() {
+		imageBuild.Spec.ManifestConfigMap = ""
+		imageBuild.Spec.ManifestRef = "quay.io/org/manifests@sha256:0123"
+
+		sum, err := newTestReconciler(imageBuild).recordManifestSHA256(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sum).To(BeEmpty())
+	})
+})
@@ -0,0 +1,116 @@
+package imagebuild
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// Build metrics, which the alerts and dashboard of the AutomotiveDev's monitoring are written against
+var (
+	buildsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "automotive_imagebuilds_finished_total",
+		Help: "ImageBuilds that finished, by namespace and phase (Completed or Failed)",
+	}, []string{"namespace", "phase"})
+	workspaceWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "automotive_imagebuild_workspace_wait_seconds",
+		Help:    "Time from the creation of an ImageBuild to the creation of its workspace, by namespace and requester",
+		Buckets: []float64{1, 10, 30, 60, 300, 900, 1800, 3600, 7200, 14400},
+	}, []string{"namespace", "requester"})
+
+	buildsDesc = prometheus.NewDesc("automotive_imagebuilds",
+		"ImageBuilds by namespace and phase", []string{"namespace", "phase"}, nil)
+	uploadingDesc = prometheus.NewDesc("automotive_imagebuild_uploading_seconds",
+		"How long an ImageBuild in the Uploading phase has waited for its files", []string{"namespace", "name"}, nil)
+	workspacePendingDesc = prometheus.NewDesc("automotive_imagebuild_workspace_pending_seconds",
+		"How long the workspace PVC of a running ImageBuild has waited to be bound", []string{"namespace", "name"}, nil)
+	queuedDesc = prometheus.NewDesc("automotive_imagebuild_queued_seconds",
+		"How long an ImageBuild waiting for workspace storage has existed", []string{"namespace", "name", "requester"}, nil)
+)
+
+// collectTimeout bounds the listing of ImageBuilds during a scrape
+const collectTimeout = 10 * time.Second
+
+// buildCollector reports the ImageBuilds in the cache when metrics are scraped, so gauges of deleted builds
+// disappear with them
+type buildCollector struct {
+	reader client.Reader
+}
+
+func (c *buildCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- buildsDesc
+	ch <- uploadingDesc
+	ch <- workspacePendingDesc
+	ch <- queuedDesc
+}
+
+func (c *buildCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	builds := &automotivev1.ImageBuildList{}
+	if err := c.reader.List(ctx, builds); err != nil {
+		logf.Log.WithName("metrics").Error(err, "Failed to list ImageBuilds")
+		return
+	}
+
+	now := time.Now()
+	type phaseKey struct{ namespace, phase string }
+	counts := map[phaseKey]int{}
+	for i := range builds.Items {
+		build := &builds.Items[i]
+		phase := build.Status.Phase
+		if phase == "" {
+			phase = "Pending"
+		}
+		counts[phaseKey{build.Namespace, phase}]++
+
+		if phase == "Uploading" {
+			ch <- prometheus.MustNewConstMetric(uploadingDesc, prometheus.GaugeValue,
+				now.Sub(build.CreationTimestamp.Time).Seconds(), build.Namespace, build.Name)
+		}
+		if isFinished(phase) {
+			continue
+		}
+		if strings.HasPrefix(build.Status.Message, workspaceWaitMessage) {
+			ch <- prometheus.MustNewConstMetric(queuedDesc, prometheus.GaugeValue,
+				now.Sub(build.CreationTimestamp.Time).Seconds(), build.Namespace, build.Name, requester(build))
+		}
+		if cond := meta.FindStatusCondition(build.Status.Conditions, automotivev1.ImageBuildWorkspaceBound); cond != nil &&
+			cond.Status == metav1.ConditionFalse {
+			ch <- prometheus.MustNewConstMetric(workspacePendingDesc, prometheus.GaugeValue,
+				now.Sub(cond.LastTransitionTime.Time).Seconds(), build.Namespace, build.Name)
+		}
+	}
+	for k, n := range counts {
+		ch <- prometheus.MustNewConstMetric(buildsDesc, prometheus.GaugeValue, float64(n), k.namespace, k.phase)
+	}
+}
+
+func isFinished(phase string) bool {
+	return phase == "Completed" || phase == "Failed"
+}
+
+// registerMetrics registers the build metrics with the manager's registry; the collector reads builds
+// through reader, the manager's cache
+func registerMetrics(reader client.Reader) error {
+	for _, c := range []prometheus.Collector{buildsFinished, workspaceWait, &buildCollector{reader: reader},
+		registrySecretsDeleted, registrySecretCleanupErrors, registrySecretCleanupLastRun,
+		intermediateRepositoriesDeleted, intermediateRegistryCleanupErrors} {
+		if err := metrics.Registry.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
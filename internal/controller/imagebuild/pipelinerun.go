@@ -0,0 +1,332 @@
+package imagebuild
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/userlabels"
+)
+
+// pipelineParams are the params a custom build pipeline must declare. Other params the operator passes are
+// only handed to pipelines that declare them, while every workspace it binds must be declared.
+var pipelineParams = []string{"distro", "target", "target-architecture", "mode", "export-format"}
+
+// errPipelineRejected marks PipelineRefs that can never run, so the build fails instead of retrying
+var errPipelineRejected = stderrors.New("pipeline rejected")
+
+func isPipelineRejected(err error) bool {
+	return stderrors.Is(err, errPipelineRejected)
+}
+
+// buildRun is what the controller reads from the Tekton run of a build: the TaskRun of the build task
+// or the PipelineRun of a PipelineRef
+type buildRun struct {
+	completed bool
+	succeeded bool
+	// results maps result names to their values
+	results map[string]string
+}
+
+// getBuildRun reads the build's active run, or returns nil if it no longer exists
+func (r *ImageBuildReconciler) getBuildRun(ctx context.Context, imageBuild *automotivev1.ImageBuild) (*buildRun, error) {
+	if imageBuild.Status.PipelineRunName != "" {
+		pipelineRun := &tektonv1.PipelineRun{}
+		err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Status.PipelineRunName, Namespace: imageBuild.Namespace}, pipelineRun)
+		if errors.IsNotFound(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		run := &buildRun{
+			completed: pipelineRun.Status.CompletionTime != nil,
+			results:   map[string]string{},
+		}
+		if conditions := pipelineRun.Status.Conditions; len(conditions) > 0 {
+			run.succeeded = conditions[0].Status == corev1.ConditionTrue
+		}
+		for _, res := range pipelineRun.Status.Results {
+			run.results[res.Name] = res.Value.StringVal
+		}
+		return run, nil
+	}
+
+	taskRun := &tektonv1.TaskRun{}
+	err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Status.TaskRunName, Namespace: imageBuild.Namespace}, taskRun)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	run := &buildRun{
+		completed: isTaskRunCompleted(taskRun),
+		succeeded: isTaskRunSuccessful(taskRun),
+		results:   map[string]string{},
+	}
+	for _, res := range taskRun.Status.TaskRunStatusFields.Results {
+		run.results[res.Name] = res.Value.StringVal
+	}
+	return run, nil
+}
+
+// adoptPipelineRun records a PipelineRun a previous reconcile created but could not record, or starts the build
+func (r *ImageBuildReconciler) adoptPipelineRun(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
+	log := r.buildLog(imageBuild)
+
+	pipelineRuns := &tektonv1.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns,
+		client.InNamespace(imageBuild.Namespace),
+		client.MatchingLabels{"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name},
+	); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list existing pipeline runs: %w", err)
+	}
+
+	for _, pr := range pipelineRuns.Items {
+		if pr.DeletionTimestamp != nil {
+			continue
+		}
+		log.Info("Found existing PipelineRun for this ImageBuild", "pipelineRun", pr.Name)
+		fresh := &automotivev1.ImageBuild{}
+		if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+			return r.Requeue.Retry("status"), nil
+		}
+		patch := client.MergeFrom(fresh.DeepCopy())
+		fresh.Status.PipelineRunName = pr.Name
+		if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+			log.Error(err, "Failed to patch ImageBuild with existing PipelineRun name")
+			return r.Requeue.Retry("status"), nil
+		}
+		return r.Requeue.Sync("build"), nil
+	}
+
+	return r.startNewBuild(ctx, imageBuild)
+}
+
+// createBuildPipelineRun runs the build's PipelineRef with the params and workspaces the build task would get
+func (r *ImageBuildReconciler) createBuildPipelineRun(ctx context.Context, imageBuild *automotivev1.ImageBuild,
+	buildConfig *automotivev1.BuildConfig, params []tektonv1.Param, workspaces []tektonv1.WorkspaceBinding,
+	podTemplate *pod.PodTemplate, serviceAccountName string) error {
+	log := r.buildLog(imageBuild)
+
+	ref := imageBuild.Spec.PipelineRef
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = imageBuild.Namespace
+	}
+	// the pipeline is read with the operator's credentials and runs as the build service account
+	if !pipelineAllowed(imageBuild.Namespace, namespace, ref.Name, buildConfig) {
+		return fmt.Errorf("%w: pipeline %s/%s is not in the AutomotiveDev's allowedPipelines", errPipelineRejected, namespace, ref.Name)
+	}
+	pipeline := &tektonv1.Pipeline{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, pipeline); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("%w: pipeline %s/%s not found", errPipelineRejected, namespace, ref.Name)
+		}
+		return fmt.Errorf("failed to get pipeline %s/%s: %w", namespace, ref.Name, err)
+	}
+	params, err := pipelineRunParams(&pipeline.Spec, params, workspaces)
+	if err != nil {
+		return fmt.Errorf("%w: pipeline %s/%s %v", errPipelineRejected, namespace, ref.Name, err)
+	}
+	// a pipeline that does not take the manifest artifact would build an empty ConfigMap instead
+	if imageBuild.Spec.ManifestRef != "" && !slices.ContainsFunc(params, func(p tektonv1.Param) bool { return p.Name == "manifest-ref" }) {
+		return fmt.Errorf("%w: pipeline %s/%s does not declare param manifest-ref", errPipelineRejected, namespace, ref.Name)
+	}
+	// a pipeline that does not take the ref would complete without committing to it
+	if imageBuild.Spec.OSTree != nil && !slices.ContainsFunc(params, func(p tektonv1.Param) bool { return p.Name == "ostree-ref" }) {
+		return fmt.Errorf("%w: pipeline %s/%s does not declare param ostree-ref", errPipelineRejected, namespace, ref.Name)
+	}
+
+	if tasks.RequiresDigests(buildConfig) {
+		images, err := r.pipelineStepImages(ctx, pipeline)
+		if err == nil {
+			err = tasks.ValidateStepImages(buildConfig, images)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: pipeline %s/%s %v", errPipelineRejected, namespace, ref.Name, err)
+		}
+	}
+
+	pipelineRef := &tektonv1.PipelineRef{Name: ref.Name}
+	if namespace != imageBuild.Namespace {
+		pipelineRef = &tektonv1.PipelineRef{
+			ResolverRef: tektonv1.ResolverRef{
+				Resolver: "cluster",
+				Params: []tektonv1.Param{
+					{Name: "kind", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: "pipeline"}},
+					{Name: "name", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: ref.Name}},
+					{Name: "namespace", Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: namespace}},
+				},
+			},
+		}
+	}
+
+	log.Info("Creating PipelineRun for ImageBuild", "pipeline", namespace+"/"+ref.Name)
+	pipelineRun := &tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-build-", imageBuild.Name),
+			Namespace:    imageBuild.Namespace,
+			Labels: map[string]string{
+				tektonv1.ManagedByLabelKey:                        "automotive-dev-operator",
+				"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
+			},
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: imageBuild.APIVersion,
+					Kind:       imageBuild.Kind,
+					Name:       imageBuild.Name,
+					UID:        imageBuild.UID,
+					Controller: ptr.To(true),
+				},
+			},
+		},
+		Spec: tektonv1.PipelineRunSpec{
+			PipelineRef: pipelineRef,
+			Params:      params,
+			Workspaces:  workspaces,
+			TaskRunTemplate: tektonv1.PipelineTaskRunTemplate{
+				PodTemplate:        podTemplate,
+				ServiceAccountName: serviceAccountName,
+			},
+		},
+	}
+
+	userlabels.Apply(pipelineRun.Labels, imageBuild.Labels)
+
+	if err := r.Create(ctx, pipelineRun); err != nil {
+		return fmt.Errorf("failed to create PipelineRun: %w", err)
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+	}
+
+	fresh.Status.PipelineRunName = pipelineRun.Name
+	if err := r.Status().Update(ctx, fresh); err != nil {
+		return fmt.Errorf("failed to update ImageBuild with PipelineRun name: %w", err)
+	}
+
+	log.Info("Successfully created PipelineRun", "name", pipelineRun.Name)
+	return nil
+}
+
+// pipelineAllowed reports whether builds of a namespace may run the named pipeline: those of their own
+// namespace and the ones the BuildConfig allows
+func pipelineAllowed(buildNamespace, namespace, name string, buildConfig *automotivev1.BuildConfig) bool {
+	if namespace == buildNamespace {
+		return true
+	}
+	if buildConfig == nil {
+		return false
+	}
+	return slices.ContainsFunc(buildConfig.AllowedPipelines, func(p automotivev1.AllowedPipeline) bool {
+		return p.Namespace == namespace && (p.Name == "" || p.Name == name)
+	})
+}
+
+// pipelineStepImages returns the images of the steps and sidecars of a pipeline's tasks, reading the Tasks
+// it refers to from its namespace. Tasks fetched by a resolver and steps of StepActions cannot be checked.
+func (r *ImageBuildReconciler) pipelineStepImages(ctx context.Context, pipeline *tektonv1.Pipeline) ([]tasks.StepImage, error) {
+	var images []tasks.StepImage
+	for _, pt := range append(slices.Clone(pipeline.Spec.Tasks), pipeline.Spec.Finally...) {
+		var spec tektonv1.TaskSpec
+		if pt.TaskSpec != nil {
+			spec = pt.TaskSpec.TaskSpec
+		} else {
+			taskRef := pt.TaskRef
+			if taskRef == nil || taskRef.Resolver != "" || taskRef.Name == "" ||
+				(taskRef.Kind != "" && taskRef.Kind != tektonv1.NamespacedTaskKind) {
+				return nil, fmt.Errorf("task %s is not a Task of the pipeline's namespace, so its images cannot be checked", pt.Name)
+			}
+			task := &tektonv1.Task{}
+			if err := r.Get(ctx, types.NamespacedName{Name: taskRef.Name, Namespace: pipeline.Namespace}, task); err != nil {
+				return nil, fmt.Errorf("task %s: %w", pt.Name, err)
+			}
+			spec = task.Spec
+		}
+		for _, step := range spec.Steps {
+			if step.Ref != nil {
+				return nil, fmt.Errorf("step %s/%s runs a StepAction, so its image cannot be checked", pt.Name, step.Name)
+			}
+			images = append(images, tasks.StepImage{Step: pt.Name + "/" + step.Name, Image: step.Image})
+		}
+		for _, sidecar := range spec.Sidecars {
+			images = append(images, tasks.StepImage{Step: pt.Name + "/" + sidecar.Name, Image: sidecar.Image})
+		}
+	}
+	return images, nil
+}
+
+// pipelineRunParams checks that a pipeline declares the build interface, including every workspace the
+// operator binds, and that the operator can run it, and returns the params it declares out of the build's params
+func pipelineRunParams(spec *tektonv1.PipelineSpec, params []tektonv1.Param, workspaces []tektonv1.WorkspaceBinding) ([]tektonv1.Param, error) {
+	var problems []string
+
+	declaredWorkspaces := map[string]bool{}
+	for _, ws := range spec.Workspaces {
+		declaredWorkspaces[ws.Name] = true
+	}
+	bound := map[string]bool{}
+	for _, ws := range workspaces {
+		bound[ws.Name] = true
+		// a pipeline that does not take a workspace would build without it, e.g. serve unencrypted
+		// artifacts without the encryption-key or miss the files staged on the input claim
+		if !declaredWorkspaces[ws.Name] {
+			problems = append(problems, fmt.Sprintf("does not declare workspace %s", ws.Name))
+		}
+	}
+	for _, ws := range spec.Workspaces {
+		if !ws.Optional && !bound[ws.Name] {
+			problems = append(problems, fmt.Sprintf("requires workspace %s the operator does not bind", ws.Name))
+		}
+	}
+
+	passed := map[string]tektonv1.Param{}
+	for _, p := range params {
+		passed[p.Name] = p
+	}
+	declaredParams := map[string]bool{}
+	var out []tektonv1.Param
+	for _, ps := range spec.Params {
+		declaredParams[ps.Name] = true
+		p, ok := passed[ps.Name]
+		if !ok {
+			if ps.Default == nil {
+				problems = append(problems, fmt.Sprintf("requires param %s the operator does not pass", ps.Name))
+			}
+			continue
+		}
+		if ps.Type != "" && ps.Type != tektonv1.ParamTypeString {
+			problems = append(problems, fmt.Sprintf("declares param %s as %s instead of string", ps.Name, ps.Type))
+			continue
+		}
+		out = append(out, p)
+	}
+	for _, name := range pipelineParams {
+		if !declaredParams[name] {
+			problems = append(problems, fmt.Sprintf("does not declare param %s", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return out, nil
+}
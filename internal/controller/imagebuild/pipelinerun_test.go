@@ -0,0 +1,182 @@
+package imagebuild
+
+import (
+	"context"
+	"slices"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+var _ = Describe("PipelineRef", func() {
+	ctx := context.Background()
+
+	str := func(name, value string) tektonv1.Param {
+		return tektonv1.Param{Name: name, Value: tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: value}}
+	}
+	// passed are the params and workspaces of a build with an encryption key
+	passed := []tektonv1.Param{
+		str("distro", "autosd"), str("target", "qemu"), str("target-architecture", "arm64"),
+		str("mode", "image"), str("export-format", "qcow2"), str("build-name", "b1"),
+	}
+	bound := []tektonv1.WorkspaceBinding{
+		{Name: "shared-workspace"}, {Name: "manifest-config-workspace"}, {Name: "encryption-key"},
+	}
+	declare := func(names ...string) []tektonv1.ParamSpec {
+		var specs []tektonv1.ParamSpec
+		for _, name := range names {
+			specs = append(specs, tektonv1.ParamSpec{Name: name, Type: tektonv1.ParamTypeString})
+		}
+		return specs
+	}
+	standard := declare("distro", "target", "target-architecture", "mode", "export-format")
+	ws := func(name string, optional bool) tektonv1.PipelineWorkspaceDeclaration {
+		return tektonv1.PipelineWorkspaceDeclaration{Name: name, Optional: optional}
+	}
+	standardWorkspaces := []tektonv1.PipelineWorkspaceDeclaration{
+		ws("shared-workspace", false), ws("manifest-config-workspace", false), ws("encryption-key", true),
+	}
+
+	DescribeTable("pipelineRunParams",
+		func(spec tektonv1.PipelineSpec, want []string, problem string) {
+			params, err := pipelineRunParams(&spec, passed, bound)
+			if problem != "" {
+				Expect(err).To(MatchError(ContainSubstring(problem)))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, p := range params {
+				names = append(names, p.Name)
+			}
+			Expect(names).To(Equal(want))
+		},
+		Entry("passes the declared params",
+			tektonv1.PipelineSpec{Params: append(declare("build-name"), standard...), Workspaces: standardWorkspaces},
+			[]string{"build-name", "distro", "target", "target-architecture", "mode", "export-format"}, ""),
+		Entry("accepts the workspaces the build binds as required",
+			tektonv1.PipelineSpec{Params: standard, Workspaces: []tektonv1.PipelineWorkspaceDeclaration{
+				ws("shared-workspace", false), ws("manifest-config-workspace", false), ws("encryption-key", false),
+			}},
+			[]string{"distro", "target", "target-architecture", "mode", "export-format"}, ""),
+		Entry("accepts optional workspaces the build does not bind",
+			tektonv1.PipelineSpec{Params: standard, Workspaces: append(slices.Clone(standardWorkspaces), ws("input", true), ws("ostree-auth", true))},
+			[]string{"distro", "target", "target-architecture", "mode", "export-format"}, ""),
+		Entry("rejects a required workspace the build does not bind",
+			tektonv1.PipelineSpec{Params: standard, Workspaces: append(slices.Clone(standardWorkspaces), ws("input", false))},
+			nil, "requires workspace input the operator does not bind"),
+		Entry("rejects a pipeline missing a workspace the build binds",
+			tektonv1.PipelineSpec{Params: standard, Workspaces: standardWorkspaces[:2]},
+			nil, "does not declare workspace encryption-key"),
+		Entry("rejects a pipeline missing a standard param",
+			tektonv1.PipelineSpec{Params: standard[1:], Workspaces: standardWorkspaces},
+			nil, "does not declare param distro"),
+		Entry("rejects a required param the operator does not pass",
+			tektonv1.PipelineSpec{Params: append(declare("board"), standard...), Workspaces: standardWorkspaces},
+			nil, "requires param board the operator does not pass"),
+		Entry("accepts an unpassed param with a default",
+			tektonv1.PipelineSpec{Params: append([]tektonv1.ParamSpec{{Name: "board", Default: &tektonv1.ParamValue{Type: tektonv1.ParamTypeString, StringVal: "x"}}}, standard...), Workspaces: standardWorkspaces},
+			[]string{"distro", "target", "target-architecture", "mode", "export-format"}, ""),
+		Entry("rejects a passed param declared as an array",
+			tektonv1.PipelineSpec{Params: append([]tektonv1.ParamSpec{{Name: "build-name", Type: tektonv1.ParamTypeArray}}, standard...), Workspaces: standardWorkspaces},
+			nil, "declares param build-name as array instead of string"),
+	)
+
+	DescribeTable("pipelineAllowed",
+		func(namespace, name string, allowed []automotivev1.AllowedPipeline, want bool) {
+			buildConfig := &automotivev1.BuildConfig{AllowedPipelines: allowed}
+			Expect(pipelineAllowed("team-a", namespace, name, buildConfig)).To(Equal(want))
+		},
+		Entry("the build's namespace", "team-a", "custom", nil, true),
+		Entry("another namespace by default", "shared", "custom", nil, false),
+		Entry("a listed pipeline", "shared", "custom", []automotivev1.AllowedPipeline{{Namespace: "shared", Name: "custom"}}, true),
+		Entry("another pipeline of a listed one's namespace", "shared", "other", []automotivev1.AllowedPipeline{{Namespace: "shared", Name: "custom"}}, false),
+		Entry("any pipeline of a listed namespace", "shared", "other", []automotivev1.AllowedPipeline{{Namespace: "shared"}}, true),
+	)
+
+	Describe("step images", func() {
+		const pinned = "@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		requireDigests := &automotivev1.BuildConfig{Images: &automotivev1.ImageOverrides{RequireDigests: true}}
+
+		pipeline := func(pipelineTasks ...tektonv1.PipelineTask) *tektonv1.Pipeline {
+			return &tektonv1.Pipeline{
+				ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "shared"},
+				Spec:       tektonv1.PipelineSpec{Tasks: pipelineTasks},
+			}
+		}
+		embedded := func(name string, images ...string) tektonv1.PipelineTask {
+			spec := tektonv1.TaskSpec{}
+			for _, image := range images {
+				spec.Steps = append(spec.Steps, tektonv1.Step{Name: "step", Image: image})
+			}
+			return tektonv1.PipelineTask{Name: name, TaskSpec: &tektonv1.EmbeddedTask{TaskSpec: spec}}
+		}
+		check := func(r *ImageBuildReconciler, p *tektonv1.Pipeline) error {
+			images, err := r.pipelineStepImages(ctx, p)
+			if err != nil {
+				return err
+			}
+			return tasks.ValidateStepImages(requireDigests, images)
+		}
+
+		It("should accept pinned images and the builder image param", func() {
+			p := pipeline(embedded("build", "quay.io/x/tool"+pinned, tasks.BuilderImageParam))
+			Expect(check(newTestReconciler(), p)).To(Succeed())
+		})
+
+		It("should refuse unpinned images of embedded and referenced tasks", func() {
+			Expect(check(newTestReconciler(), pipeline(embedded("build", "quay.io/x/tool:latest")))).
+				To(MatchError(ContainSubstring("build/step: quay.io/x/tool:latest is not pinned by digest")))
+
+			task := &tektonv1.Task{
+				ObjectMeta: metav1.ObjectMeta{Name: "push", Namespace: "shared"},
+				Spec: tektonv1.TaskSpec{
+					Steps:    []tektonv1.Step{{Name: "push", Image: "quay.io/x/oras" + pinned}},
+					Sidecars: []tektonv1.Sidecar{{Name: "proxy", Image: "quay.io/x/proxy:1"}},
+				},
+			}
+			p := pipeline(tektonv1.PipelineTask{Name: "publish", TaskRef: &tektonv1.TaskRef{Name: "push"}})
+			Expect(check(newTestReconciler(task), p)).To(MatchError(ContainSubstring("publish/proxy")))
+		})
+
+		It("should refuse tasks and steps whose images it cannot read", func() {
+			resolved := pipeline(tektonv1.PipelineTask{Name: "remote", TaskRef: &tektonv1.TaskRef{
+				ResolverRef: tektonv1.ResolverRef{Resolver: "git"},
+			}})
+			Expect(check(newTestReconciler(), resolved)).To(MatchError(ContainSubstring("task remote")))
+
+			action := embedded("build")
+			action.TaskSpec.Steps = []tektonv1.Step{{Name: "act", Ref: &tektonv1.Ref{Name: "action"}}}
+			Expect(check(newTestReconciler(), pipeline(action))).To(MatchError(ContainSubstring("StepAction")))
+		})
+
+		It("should not check images when digests are not required", func() {
+			images := []tasks.StepImage{{Step: "build/step", Image: "quay.io/x/tool:latest"}}
+			Expect(tasks.ValidateStepImages(&automotivev1.BuildConfig{}, images)).To(Succeed())
+			Expect(tasks.ValidateStepImages(nil, images)).To(Succeed())
+		})
+	})
+
+	It("should refuse pipelines of namespaces the AutomotiveDev does not allow before reading them", func() {
+		imageBuild := &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b1", Namespace: "team-a"},
+			Spec: automotivev1.ImageBuildSpec{
+				PipelineRef: &automotivev1.PipelineRef{Name: "custom", Namespace: "shared"},
+			},
+		}
+		r := newTestReconciler(imageBuild)
+		err := r.createBuildPipelineRun(ctx, imageBuild, nil, passed, bound, nil, "")
+		Expect(isPipelineRejected(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("not in the AutomotiveDev's allowedPipelines")))
+
+		// once allowed, it is looked up
+		buildConfig := &automotivev1.BuildConfig{AllowedPipelines: []automotivev1.AllowedPipeline{{Namespace: "shared"}}}
+		err = r.createBuildPipelineRun(ctx, imageBuild, buildConfig, passed, bound, nil, "")
+		Expect(err).To(MatchError(ContainSubstring("pipeline shared/custom not found")))
+	})
+})
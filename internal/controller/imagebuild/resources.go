@@ -0,0 +1,110 @@
+package imagebuild
+
+import (
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// extendedResourcesStep is the step of the build task that gets the build's extended resources
+const extendedResourcesStep = "build-image"
+
+// errResourcesRejected marks extended resource requests the BuildConfig does not allow, so the build fails
+// instead of retrying
+var errResourcesRejected = stderrors.New("extended resources rejected")
+
+func isResourcesRejected(err error) bool {
+	return stderrors.Is(err, errResourcesRejected)
+}
+
+// checkExtendedResources verifies that the AutomotiveDev allows every extended resource a build requests,
+// in the quantity it requests
+func checkExtendedResources(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) error {
+	requested := imageBuild.Spec.ExtendedResources
+	if len(requested) == 0 {
+		return nil
+	}
+	if imageBuild.Spec.PipelineRef != nil {
+		return fmt.Errorf("%w: builds with a pipelineRef cannot request extended resources", errResourcesRejected)
+	}
+
+	var allowed []automotivev1.AllowedExtendedResource
+	if buildConfig != nil {
+		allowed = buildConfig.ExtendedResources
+	}
+	var problems []string
+	for _, name := range sortedResourceNames(requested) {
+		quantity := requested[name]
+		if !isExtendedResourceName(name) {
+			problems = append(problems, fmt.Sprintf("%s is not an extended resource", name))
+			continue
+		}
+		i := slices.IndexFunc(allowed, func(a automotivev1.AllowedExtendedResource) bool { return a.Name == name })
+		switch {
+		case i < 0:
+			problems = append(problems, fmt.Sprintf("%s is not allowed", name))
+		case quantity.Sign() <= 0:
+			problems = append(problems, fmt.Sprintf("%s must be a positive quantity", name))
+		case allowed[i].Max != nil && quantity.Cmp(*allowed[i].Max) > 0:
+			problems = append(problems, fmt.Sprintf("%s: %s exceeds the maximum of %s", name, quantity.String(), allowed[i].Max.String()))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errResourcesRejected, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isExtendedResourceName reports whether name is a resource outside the kubernetes.io domain, which only
+// device plugins and cluster operators advertise
+func isExtendedResourceName(name corev1.ResourceName) bool {
+	domain, _, found := strings.Cut(string(name), "/")
+	if !found || strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+		return false
+	}
+	return domain != "kubernetes.io" && !strings.HasSuffix(domain, ".kubernetes.io")
+}
+
+// applyExtendedResources requests a build's extended resources for the build step and lets the build pod
+// tolerate the taints of nodes dedicated to them, as the ExtendedResourceToleration admission plugin would
+func applyExtendedResources(taskSpec *tektonv1.TaskSpec, podTemplate *pod.PodTemplate, resources corev1.ResourceList) {
+	if len(resources) == 0 {
+		return
+	}
+	for i := range taskSpec.Steps {
+		step := &taskSpec.Steps[i]
+		if step.Name != extendedResourcesStep {
+			continue
+		}
+		if step.ComputeResources.Limits == nil {
+			step.ComputeResources.Limits = corev1.ResourceList{}
+		}
+		// extended resources cannot be overcommitted: the request defaults to the limit
+		for name, quantity := range resources {
+			step.ComputeResources.Limits[name] = quantity.DeepCopy()
+		}
+	}
+	for _, name := range sortedResourceNames(resources) {
+		podTemplate.Tolerations = append(podTemplate.Tolerations, corev1.Toleration{
+			Key:      string(name),
+			Operator: corev1.TolerationOpExists,
+			Effect:   corev1.TaintEffectNoSchedule,
+		})
+	}
+}
+
+func sortedResourceNames(resources corev1.ResourceList) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,83 @@
+package imagebuild
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Extended resources", func() {
+	const gpu = corev1.ResourceName("nvidia.com/gpu")
+
+	build := func(resources corev1.ResourceList) *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{Spec: automotivev1.ImageBuildSpec{ExtendedResources: resources}}
+	}
+	allowing := func(allowed ...automotivev1.AllowedExtendedResource) *automotivev1.BuildConfig {
+		return &automotivev1.BuildConfig{ExtendedResources: allowed}
+	}
+	upTo := func(max string) *resource.Quantity {
+		q := resource.MustParse(max)
+		return &q
+	}
+
+	DescribeTable("checkExtendedResources",
+		func(requested corev1.ResourceList, buildConfig *automotivev1.BuildConfig, rejected string) {
+			err := checkExtendedResources(build(requested), buildConfig)
+			if rejected == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(isResourcesRejected(err)).To(BeTrue(), "got %v", err)
+			Expect(err.Error()).To(ContainSubstring(rejected))
+		},
+		Entry("nothing requested", nil, nil, ""),
+		Entry("requested but not allowed",
+			corev1.ResourceList{gpu: resource.MustParse("1")},
+			allowing(automotivev1.AllowedExtendedResource{Name: "amd.com/gpu"}),
+			"nvidia.com/gpu is not allowed"),
+		Entry("requested without a build config",
+			corev1.ResourceList{gpu: resource.MustParse("1")}, nil, "nvidia.com/gpu is not allowed"),
+		Entry("allowed without a maximum",
+			corev1.ResourceList{gpu: resource.MustParse("8")},
+			allowing(automotivev1.AllowedExtendedResource{Name: gpu}), ""),
+		Entry("allowed up to the maximum",
+			corev1.ResourceList{gpu: resource.MustParse("2")},
+			allowing(automotivev1.AllowedExtendedResource{Name: gpu, Max: upTo("2")}), ""),
+		Entry("more than the maximum",
+			corev1.ResourceList{gpu: resource.MustParse("3")},
+			allowing(automotivev1.AllowedExtendedResource{Name: gpu, Max: upTo("2")}),
+			"nvidia.com/gpu: 3 exceeds the maximum of 2"),
+		Entry("zero quantity",
+			corev1.ResourceList{gpu: resource.MustParse("0")},
+			allowing(automotivev1.AllowedExtendedResource{Name: gpu}),
+			"nvidia.com/gpu must be a positive quantity"),
+		Entry("a kubernetes.io resource",
+			corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			allowing(automotivev1.AllowedExtendedResource{Name: corev1.ResourceCPU}),
+			"cpu is not an extended resource"),
+		Entry("hugepages",
+			corev1.ResourceList{"hugepages-2Mi": resource.MustParse("1Gi")},
+			allowing(automotivev1.AllowedExtendedResource{Name: "hugepages-2Mi"}),
+			"hugepages-2Mi is not an extended resource"),
+	)
+
+	It("should reject extended resources for builds with a pipelineRef", func() {
+		imageBuild := build(corev1.ResourceList{gpu: resource.MustParse("1")})
+		imageBuild.Spec.PipelineRef = &automotivev1.PipelineRef{Name: "custom"}
+
+		err := checkExtendedResources(imageBuild, allowing(automotivev1.AllowedExtendedResource{Name: gpu}))
+		Expect(isResourcesRejected(err)).To(BeTrue())
+	})
+
+	It("should report every rejected resource", func() {
+		err := checkExtendedResources(build(corev1.ResourceList{
+			gpu:                resource.MustParse("4"),
+			"example.com/fpga": resource.MustParse("1"),
+		}), allowing(automotivev1.AllowedExtendedResource{Name: gpu, Max: upTo("1")}))
+
+		Expect(err).To(MatchError(ContainSubstring("example.com/fpga is not allowed; nvidia.com/gpu: 4 exceeds the maximum of 1")))
+	})
+})
@@ -0,0 +1,184 @@
+package imagebuild
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/correlation"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// buildResultsFile is written to the root of the workspace by the build task. Results that can
+	// outgrow Tekton's result size limit, such as checksums, are only passed this way.
+	buildResultsFile = ".automotive-results.json"
+
+	// buildResultsTimeout is how long the results helper pod may take before the TaskRun results are used
+	buildResultsTimeout = 2 * time.Minute
+)
+
+// buildResults is the content of the workspace results file
+type buildResults struct {
+	ArtifactFileName string `json:"artifactFileName,omitempty"`
+	ArtifactSize     int64  `json:"artifactSize,omitempty"`
+	ArtifactSHA256   string `json:"artifactSha256,omitempty"`
+	// ArtifactContentType is missing from the results of builds from before types were recorded
+	ArtifactContentType string `json:"artifactContentType,omitempty"`
+}
+
+func buildResultsPodName(imageBuild *automotivev1.ImageBuild) string {
+	return fmt.Sprintf("%s-results", imageBuild.Name)
+}
+
+// readBuildResults reads the results file of a finished build from its workspace with a short-lived
+// helper pod. done is false while the pod runs; the results are nil when the file cannot be read, in
+// which case the caller falls back to the TaskRun results.
+func (r *ImageBuildReconciler) readBuildResults(ctx context.Context, imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) (*buildResults, bool, error) {
+	log := r.buildLog(imageBuild)
+	if r.Clientset == nil || imageBuild.Status.PVCName == "" {
+		return nil, true, nil
+	}
+
+	podName := buildResultsPodName(imageBuild)
+	pod := &corev1.Pod{}
+	err := r.Get(ctx, types.NamespacedName{Name: podName, Namespace: imageBuild.Namespace}, pod)
+	if errors.IsNotFound(err) {
+		if err := r.createBuildResultsPod(ctx, imageBuild, buildConfig); err != nil {
+			return nil, false, err
+		}
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("error checking for results pod: %w", err)
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+	case corev1.PodFailed:
+		log.Info("Results pod failed, using TaskRun results", "pod", podName)
+		return nil, true, r.deleteBuildResultsPod(ctx, pod)
+	default:
+		if time.Since(pod.CreationTimestamp.Time) > buildResultsTimeout {
+			log.Info("Results pod did not finish in time, using TaskRun results", "pod", podName)
+			return nil, true, r.deleteBuildResultsPod(ctx, pod)
+		}
+		return nil, false, nil
+	}
+
+	out, err := r.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{}).DoRaw(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read results pod logs: %w", err)
+	}
+	if err := r.deleteBuildResultsPod(ctx, pod); err != nil {
+		return nil, false, err
+	}
+
+	if strings.TrimSpace(string(out)) == "" {
+		// builds from before the results file was introduced
+		return nil, true, nil
+	}
+	results := &buildResults{}
+	if err := json.Unmarshal(out, results); err != nil {
+		log.Error(err, "invalid build results file, using TaskRun results")
+		return nil, true, nil
+	}
+	return results, true, nil
+}
+
+func (r *ImageBuildReconciler) createBuildResultsPod(ctx context.Context, imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) error {
+	if err := r.ensureHelperServiceAccount(ctx, imageBuild.Namespace); err != nil {
+		return err
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        buildResultsPodName(imageBuild),
+			Namespace:   imageBuild.Namespace,
+			Annotations: correlation.FromObject(imageBuild).Annotate(nil),
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by":                    "automotive-dev-operator",
+				"automotive.sdv.cloud.redhat.com/imagebuild-name": imageBuild.Name,
+				"app.kubernetes.io/name":                          "results-pod",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         imageBuild.APIVersion,
+					Kind:               imageBuild.Kind,
+					Name:               imageBuild.Name,
+					UID:                imageBuild.UID,
+					Controller:         ptr.To(true),
+					BlockOwnerDeletion: ptr.To(true),
+				},
+			},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: HelperServiceAccountName,
+			RestartPolicy:      corev1.RestartPolicyNever,
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsUser:    ptr.To[int64](1000),
+				RunAsGroup:   ptr.To[int64](1000),
+				FSGroup:      ptr.To[int64](1000),
+				RunAsNonRoot: ptr.To(true),
+			},
+			Containers: []corev1.Container{
+				{
+					Name:  "results",
+					Image: tasks.Images(buildConfig).FileServer,
+					// an absent file prints nothing, which falls back to the TaskRun results
+					Command: []string{"sh", "-c", `cat "$1" 2>/dev/null || true`, "sh", "/workspace/shared/" + buildResultsFile},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("10m"),
+							corev1.ResourceMemory: resource.MustParse("16Mi"),
+						},
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("100m"),
+							corev1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+					},
+					SecurityContext: &corev1.SecurityContext{
+						AllowPrivilegeEscalation: ptr.To(false),
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "workspace",
+							MountPath: "/workspace/shared",
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "workspace",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: imageBuild.Status.PVCName,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := r.Create(ctx, pod); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create results pod: %w", err)
+	}
+	return nil
+}
+
+func (r *ImageBuildReconciler) deleteBuildResultsPod(ctx context.Context, pod *corev1.Pod) error {
+	if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete results pod: %w", err)
+	}
+	return nil
+}
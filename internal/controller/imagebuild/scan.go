@@ -0,0 +1,65 @@
+package imagebuild
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// scanResult reads the scan-summary result of a finished build run and applies the scan policy to it.
+// It returns nil when the build was not scanned.
+func scanResult(run *buildRun, policy *automotivev1.ScanPolicy) (*automotivev1.ScanResult, error) {
+	summary := strings.TrimSpace(run.results["scan-summary"])
+	if summary == "" {
+		return nil, nil
+	}
+	result, err := parseScanSummary(summary)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil && policy.MaxCritical != nil && result.Critical > *policy.MaxCritical {
+		result.Blocked = true
+	}
+	return result, nil
+}
+
+// parseScanSummary parses the "critical=N high=N medium=N low=N report=FILE" line the scan step writes
+func parseScanSummary(summary string) (*automotivev1.ScanResult, error) {
+	result := &automotivev1.ScanResult{}
+	for _, field := range strings.Fields(summary) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed scan summary field %q", field)
+		}
+		var count *int32
+		switch key {
+		case "critical":
+			count = &result.Critical
+		case "high":
+			count = &result.High
+		case "medium":
+			count = &result.Medium
+		case "low":
+			count = &result.Low
+		case "report":
+			result.ReportFileName = value
+			continue
+		default:
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s count in scan summary: %w", key, err)
+		}
+		*count = int32(n)
+	}
+	return result, nil
+}
+
+// scanBlockedMessage explains why a blocked build's artifact is not served
+func scanBlockedMessage(result *automotivev1.ScanResult, policy *automotivev1.ScanPolicy) string {
+	return fmt.Sprintf("Build completed but its artifact is blocked by the scan policy: %d critical vulnerabilities (at most %d allowed)",
+		result.Critical, *policy.MaxCritical)
+}
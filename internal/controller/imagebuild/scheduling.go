@@ -0,0 +1,124 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodScheduled condition reasons
+const (
+	podScheduledReasonScheduled     = "Scheduled"
+	podScheduledReasonUnschedulable = "Unschedulable"
+)
+
+// unschedulableMessagePrefix starts the status message of builds whose pod waits for a node
+const unschedulableMessagePrefix = "Pod unschedulable: "
+
+func unschedulableTimeout(buildConfig *automotivev1.BuildConfig) time.Duration {
+	if buildConfig == nil || buildConfig.UnschedulableTimeoutMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(buildConfig.UnschedulableTimeoutMinutes) * time.Minute
+}
+
+// buildPods lists the pods of the build's run
+func (r *ImageBuildReconciler) buildPods(ctx context.Context, imageBuild *automotivev1.ImageBuild) ([]corev1.Pod, error) {
+	labels := client.MatchingLabels{"tekton.dev/taskRun": imageBuild.Status.TaskRunName}
+	if imageBuild.Status.PipelineRunName != "" {
+		labels = client.MatchingLabels{"tekton.dev/pipelineRun": imageBuild.Status.PipelineRunName}
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(imageBuild.Namespace), labels); err != nil {
+		return nil, fmt.Errorf("failed to list build pods: %w", err)
+	}
+	return pods.Items, nil
+}
+
+// unschedulablePod returns a pod of the build's run the scheduler could not place, with its PodScheduled
+// condition, or nil when every pod is scheduled
+func (r *ImageBuildReconciler) unschedulablePod(ctx context.Context, imageBuild *automotivev1.ImageBuild) (*corev1.Pod, *corev1.PodCondition, error) {
+	pods, err := r.buildPods(ctx, imageBuild)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for j := range pod.Status.Conditions {
+			c := &pod.Status.Conditions[j]
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				return pod, c, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// checkBuildScheduling reports a build pod the scheduler cannot place in the build's status, e.g. when no node
+// has the build's architecture, and fails the build once the pod stays unschedulable past the timeout the
+// BuildConfig sets, if any. It reports whether a pod is unschedulable, in which case the returned result ends
+// the reconcile.
+func (r *ImageBuildReconciler) checkBuildScheduling(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, bool, error) {
+	if imageBuild.Status.TaskRunName == "" && imageBuild.Status.PipelineRunName == "" {
+		return ctrl.Result{}, false, nil
+	}
+	pod, cond, err := r.unschedulablePod(ctx, imageBuild)
+	if err != nil {
+		return ctrl.Result{}, false, err
+	}
+	if pod == nil {
+		if meta.IsStatusConditionFalse(imageBuild.Status.Conditions, automotivev1.ImageBuildPodScheduled) {
+			if err := r.setPodScheduledCondition(ctx, imageBuild, metav1.ConditionTrue, podScheduledReasonScheduled,
+				"Build pods are scheduled"); err != nil {
+				return ctrl.Result{}, false, err
+			}
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+	since := cond.LastTransitionTime.Time
+	if since.IsZero() {
+		since = pod.CreationTimestamp.Time
+	}
+	if timeout := unschedulableTimeout(buildConfig); timeout > 0 && time.Since(since) > timeout {
+		r.buildLog(imageBuild).Info("Build pod could not be scheduled in time, failing the build", "pod", pod.Name, "reason", cond.Message)
+		if err := r.stopBuild(ctx, imageBuild); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		message := fmt.Sprintf("Build pod %s could not be scheduled within %s: %s", pod.Name, timeout, cond.Message)
+		if err := r.setPodScheduledCondition(ctx, imageBuild, metav1.ConditionFalse, podScheduledReasonUnschedulable, message); err != nil {
+			return r.Requeue.Retry("status"), true, nil
+		}
+		if err := r.updateStatus(ctx, imageBuild, "Failed", message); err != nil {
+			return r.Requeue.Retry("status"), true, nil
+		}
+		return ctrl.Result{}, true, nil
+	}
+
+	message := unschedulableMessagePrefix + cond.Message
+	if err := r.setPodScheduledCondition(ctx, imageBuild, metav1.ConditionFalse, podScheduledReasonUnschedulable, message); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	// build pods are not owned by the ImageBuild, so their scheduling does not requeue it
+	return r.Requeue.Sync("scheduling"), true, nil
+}
+
+// setPodScheduledCondition records the PodScheduled condition; the status message follows it while a pod waits
+// for a node
+func (r *ImageBuildReconciler) setPodScheduledCondition(ctx context.Context, imageBuild *automotivev1.ImageBuild, status metav1.ConditionStatus, reason, message string) error {
+	return r.setWaitCondition(ctx, imageBuild, automotivev1.ImageBuildPodScheduled, unschedulableMessagePrefix, status, reason, message)
+}
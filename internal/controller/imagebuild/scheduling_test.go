@@ -0,0 +1,147 @@
+package imagebuild
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Build scheduling", func() {
+	ctx := context.Background()
+	const noNode = "0/3 nodes are available: 3 node(s) didn't match Pod's node affinity/selector."
+
+	// building returns a build whose run is the TaskRun "b-build"
+	building := func() *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns"},
+			Status: automotivev1.ImageBuildStatus{
+				Phase:       "Building",
+				Message:     "Build started",
+				TaskRunName: "b-build",
+			},
+		}
+	}
+	// pod returns a pod of the run in a phase with a PodScheduled condition that changed some time ago
+	pod := func(phase corev1.PodPhase, scheduled corev1.ConditionStatus, reason string, since time.Duration) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "b-build-pod",
+				Namespace:         "ns",
+				Labels:            map[string]string{"tekton.dev/taskRun": "b-build"},
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-since)),
+			},
+			Status: corev1.PodStatus{
+				Phase: phase,
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodScheduled,
+					Status:             scheduled,
+					Reason:             reason,
+					Message:            noNode,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-since)),
+				}},
+			},
+		}
+	}
+	withTimeout := func(minutes int32) *automotivev1.AutomotiveDev {
+		return &automotivev1.AutomotiveDev{
+			ObjectMeta: metav1.ObjectMeta{Name: "automotive-dev", Namespace: OperatorNamespace},
+			Spec: automotivev1.AutomotiveDevSpec{
+				BuildConfig: &automotivev1.BuildConfig{UnschedulableTimeoutMinutes: minutes},
+			},
+		}
+	}
+	fetch := func(r *ImageBuildReconciler) *automotivev1.ImageBuild {
+		fresh := &automotivev1.ImageBuild{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "b", Namespace: "ns"}, fresh)).To(Succeed())
+		return fresh
+	}
+
+	It("should report an unschedulable pod and keep waiting for a node", func() {
+		imageBuild := building()
+		r := newTestReconciler(imageBuild, withTimeout(30),
+			pod(corev1.PodPending, corev1.ConditionFalse, corev1.PodReasonUnschedulable, 5*time.Minute))
+
+		result, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unschedulable).To(BeTrue())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Phase).To(Equal("Building"))
+		Expect(fresh.Status.Message).To(Equal(unschedulableMessagePrefix + noNode))
+		Expect(meta.IsStatusConditionFalse(fresh.Status.Conditions, automotivev1.ImageBuildPodScheduled)).To(BeTrue())
+	})
+
+	It("should fail the build once the pod stays unschedulable past the timeout", func() {
+		imageBuild := building()
+		r := newTestReconciler(imageBuild, withTimeout(30),
+			pod(corev1.PodPending, corev1.ConditionFalse, corev1.PodReasonUnschedulable, time.Hour))
+
+		result, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unschedulable).To(BeTrue())
+		Expect(result.IsZero()).To(BeTrue())
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Phase).To(Equal("Failed"))
+		Expect(fresh.Status.Message).To(Equal("Build pod b-build-pod could not be scheduled within 30m0s: " + noNode))
+	})
+
+	It("should wait without a timeout when the BuildConfig sets none", func() {
+		imageBuild := building()
+		r := newTestReconciler(imageBuild,
+			pod(corev1.PodPending, corev1.ConditionFalse, corev1.PodReasonUnschedulable, 24*time.Hour))
+
+		_, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unschedulable).To(BeTrue())
+		Expect(fetch(r).Status.Phase).To(Equal("Building"))
+	})
+
+	It("should leave a pod that is pending for another reason alone", func() {
+		imageBuild := building()
+		// not looked at by the scheduler yet, or placed and pulling its images
+		fresh := pod(corev1.PodPending, corev1.ConditionTrue, "", time.Hour)
+		fresh.Status.Conditions = nil
+		pulling := pod(corev1.PodPending, corev1.ConditionTrue, "", time.Hour)
+		pulling.Name = "b-build-pod-2"
+		r := newTestReconciler(imageBuild, withTimeout(30), fresh, pulling)
+
+		result, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unschedulable).To(BeFalse())
+		Expect(result.IsZero()).To(BeTrue())
+
+		status := fetch(r).Status
+		Expect(status.Message).To(Equal("Build started"))
+		Expect(meta.FindStatusCondition(status.Conditions, automotivev1.ImageBuildPodScheduled)).To(BeNil())
+	})
+
+	It("should mark the pods scheduled and restore the phase's message once a node is found", func() {
+		imageBuild := building()
+		imageBuild.Status.Message = unschedulableMessagePrefix + noNode
+		meta.SetStatusCondition(&imageBuild.Status.Conditions, metav1.Condition{
+			Type:    automotivev1.ImageBuildPodScheduled,
+			Status:  metav1.ConditionFalse,
+			Reason:  podScheduledReasonUnschedulable,
+			Message: imageBuild.Status.Message,
+		})
+		r := newTestReconciler(imageBuild, pod(corev1.PodRunning, corev1.ConditionTrue, "", time.Minute))
+
+		_, unschedulable, err := r.checkBuildScheduling(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unschedulable).To(BeFalse())
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Message).To(Equal("Build started"))
+		Expect(meta.IsStatusConditionTrue(fresh.Status.Conditions, automotivev1.ImageBuildPodScheduled)).To(BeTrue())
+	})
+})
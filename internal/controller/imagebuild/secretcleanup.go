@@ -0,0 +1,111 @@
+package imagebuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+const (
+	// registrySecretCleanupInterval is how often orphaned registry secrets are looked for
+	registrySecretCleanupInterval = 24 * time.Hour
+	// registrySecretGracePeriod spares secrets of builds still being created: the build API creates the
+	// secret before the ImageBuild
+	registrySecretGracePeriod = time.Hour
+)
+
+// Registry secret cleanup metrics
+var (
+	registrySecretsDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "automotive_registry_secrets_cleaned_total",
+		Help: "Registry credentials secrets deleted because their ImageBuild no longer exists, by namespace",
+	}, []string{"namespace"})
+	registrySecretCleanupErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "automotive_registry_secret_cleanup_errors_total",
+		Help: "Errors listing, checking or deleting registry credentials secrets during cleanup",
+	})
+	registrySecretCleanupLastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "automotive_registry_secret_cleanup_last_run_timestamp_seconds",
+		Help: "When the cleanup of orphaned registry credentials secrets last ran",
+	})
+)
+
+// RegistrySecretCleanup deletes, once a day, the <build>-registry-auth secrets the build API created for
+// ImageBuilds that no longer exist. Owner references remove them with their build, but a build that was
+// force-deleted, or never created because CreateBuild failed after creating its secret, leaves its secret
+// behind with the registry credentials in it.
+type RegistrySecretCleanup struct {
+	client.Client
+	// Reader lists secrets and gets builds without the manager's cache, so that secrets of every namespace
+	// are not cached for a daily check
+	Reader client.Reader
+	Log    logr.Logger
+	// Interval overrides registrySecretCleanupInterval
+	Interval time.Duration
+}
+
+// Start runs the cleanup right away and then every interval until ctx is done
+func (c *RegistrySecretCleanup) Start(ctx context.Context) error {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = registrySecretCleanupInterval
+	}
+	wait.JitterUntilWithContext(ctx, c.cleanup, interval, 0.1, true)
+	return nil
+}
+
+// NeedLeaderElection keeps replicas of the operator from cleaning up at the same time
+func (c *RegistrySecretCleanup) NeedLeaderElection() bool {
+	return true
+}
+
+func (c *RegistrySecretCleanup) cleanup(ctx context.Context) {
+	registrySecretCleanupLastRun.SetToCurrentTime()
+
+	secrets := &corev1.SecretList{}
+	if err := c.Reader.List(ctx, secrets, client.MatchingLabels{
+		"app.kubernetes.io/managed-by":                  "build-api",
+		"automotive.sdv.cloud.redhat.com/resource-type": "registry-auth",
+	}); err != nil {
+		registrySecretCleanupErrors.Inc()
+		c.Log.Error(err, "Failed to list registry secrets")
+		return
+	}
+
+	deleted := 0
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		buildName := secret.Labels["automotive.sdv.cloud.redhat.com/build-name"]
+		if buildName == "" || secret.DeletionTimestamp != nil || time.Since(secret.CreationTimestamp.Time) < registrySecretGracePeriod {
+			continue
+		}
+		err := c.Reader.Get(ctx, types.NamespacedName{Name: buildName, Namespace: secret.Namespace}, &automotivev1.ImageBuild{})
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			registrySecretCleanupErrors.Inc()
+			c.Log.Error(err, "Failed to check the build of a registry secret", "namespace", secret.Namespace, "secret", secret.Name)
+			continue
+		}
+		if err := c.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+			registrySecretCleanupErrors.Inc()
+			c.Log.Error(err, "Failed to delete orphaned registry secret", "namespace", secret.Namespace, "secret", secret.Name)
+			continue
+		}
+		registrySecretsDeleted.WithLabelValues(secret.Namespace).Inc()
+		c.Log.Info("Deleted registry secret of a build that no longer exists",
+			"namespace", secret.Namespace, "secret", secret.Name, "imagebuild", buildName)
+		deleted++
+	}
+	c.Log.V(1).Info("Registry secret cleanup finished", "checked", len(secrets.Items), "deleted", deleted)
+}
@@ -0,0 +1,98 @@
+package imagebuild
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Registry secret cleanup", func() {
+	ctx := context.Background()
+
+	// registrySecret returns a secret the build API created for a build some time ago
+	registrySecret := func(name, build string, age time.Duration) *corev1.Secret {
+		labels := map[string]string{
+			"app.kubernetes.io/managed-by":                  "build-api",
+			"automotive.sdv.cloud.redhat.com/resource-type": "registry-auth",
+		}
+		if build != "" {
+			labels["automotive.sdv.cloud.redhat.com/build-name"] = build
+		}
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "ns",
+			Labels:            labels,
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}}
+	}
+	// cleanup runs the cleanup once over objs and returns the client to check the outcome with
+	cleanup := func(objs ...client.Object) client.Client {
+		c := newTestReconciler(objs...).Client
+		(&RegistrySecretCleanup{Client: c, Reader: c, Log: logr.Discard()}).cleanup(ctx)
+		return c
+	}
+	exists := func(c client.Client, name string) bool {
+		err := c.Get(ctx, client.ObjectKey{Name: name, Namespace: "ns"}, &corev1.Secret{})
+		if errors.IsNotFound(err) {
+			return false
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return true
+	}
+
+	It("should delete the secrets of builds that no longer exist", func() {
+		c := cleanup(registrySecret("gone-registry-auth", "gone", 2*time.Hour))
+
+		Expect(exists(c, "gone-registry-auth")).To(BeFalse())
+	})
+
+	It("should keep the secrets of builds that still exist", func() {
+		c := cleanup(
+			registrySecret("live-registry-auth", "live", 48*time.Hour),
+			&automotivev1.ImageBuild{ObjectMeta: metav1.ObjectMeta{Name: "live", Namespace: "ns"}},
+		)
+
+		Expect(exists(c, "live-registry-auth")).To(BeTrue())
+	})
+
+	It("should keep the secrets of builds that may still be being created", func() {
+		c := cleanup(registrySecret("new-registry-auth", "new", time.Minute))
+
+		Expect(exists(c, "new-registry-auth")).To(BeTrue())
+	})
+
+	It("should keep secrets without a build name", func() {
+		c := cleanup(registrySecret("unnamed-registry-auth", "", 2*time.Hour))
+
+		Expect(exists(c, "unnamed-registry-auth")).To(BeTrue())
+	})
+
+	It("should leave secrets the build API did not create alone", func() {
+		secret := registrySecret("other", "gone", 2*time.Hour)
+		delete(secret.Labels, "app.kubernetes.io/managed-by")
+
+		c := cleanup(secret)
+
+		Expect(exists(c, "other")).To(BeTrue())
+	})
+
+	It("should only delete the orphaned secrets of a namespace", func() {
+		c := cleanup(
+			registrySecret("live-registry-auth", "live", 2*time.Hour),
+			registrySecret("gone-registry-auth", "gone", 2*time.Hour),
+			&automotivev1.ImageBuild{ObjectMeta: metav1.ObjectMeta{Name: "live", Namespace: "ns"}},
+		)
+
+		Expect(exists(c, "live-registry-auth")).To(BeTrue())
+		Expect(exists(c, "gone-registry-auth")).To(BeFalse())
+	})
+})
@@ -0,0 +1,76 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/buildscc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// BuildServiceAccountName is the operator-managed service account used for builds when none is configured
+	BuildServiceAccountName = "automotive-dev-build"
+
+	// HelperServiceAccountName is the operator-managed service account of the upload, artifact, results and
+	// conversion pods, which need none of the privileges of the build
+	HelperServiceAccountName = "automotive-dev-helper"
+)
+
+// resolveServiceAccountName returns the service account for a build, preferring the ImageBuild
+// spec, then the AutomotiveDev BuildConfig, then the operator-managed default
+func resolveServiceAccountName(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) string {
+	if imageBuild.Spec.ServiceAccountName != "" {
+		return imageBuild.Spec.ServiceAccountName
+	}
+	if buildConfig != nil && buildConfig.ServiceAccountName != "" {
+		return buildConfig.ServiceAccountName
+	}
+	return BuildServiceAccountName
+}
+
+// ensureBuildServiceAccount makes sure the operator-managed build service account exists in the namespace
+// and is allowed to use the build SCC
+func (r *ImageBuildReconciler) ensureBuildServiceAccount(ctx context.Context, namespace string) error {
+	return r.ensureServiceAccount(ctx, namespace, BuildServiceAccountName, buildscc.ClusterRoleName)
+}
+
+// ensureHelperServiceAccount makes sure the operator-managed helper service account exists in the namespace
+// and is allowed to use the unprivileged helper SCC
+func (r *ImageBuildReconciler) ensureHelperServiceAccount(ctx context.Context, namespace string) error {
+	return r.ensureServiceAccount(ctx, namespace, HelperServiceAccountName, buildscc.HelperClusterRoleName)
+}
+
+// ensureServiceAccount makes sure an operator-managed service account exists in the namespace and is bound
+// to a cluster role of the buildscc package
+func (r *ImageBuildReconciler) ensureServiceAccount(ctx context.Context, namespace, name, clusterRole string) error {
+	if err := buildscc.Ensure(ctx, r.Client); err != nil {
+		return err
+	}
+
+	sa := &corev1.ServiceAccount{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, sa)
+	if errors.IsNotFound(err) {
+		sa = &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "automotive-dev-operator",
+					"app.kubernetes.io/part-of":    "automotive-dev",
+				},
+			},
+		}
+		if err := r.Create(ctx, sa); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create service account %s: %w", name, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get service account %s: %w", name, err)
+	}
+
+	return buildscc.Bind(ctx, r.Client, namespace, clusterRole, name)
+}
@@ -0,0 +1,74 @@
+package imagebuild
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	securityv1 "github.com/openshift/api/security/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/buildscc"
+)
+
+var _ = Describe("Build service accounts", func() {
+	ctx := context.Background()
+
+	// binding returns a role binding of the builds namespace
+	binding := func(r *ImageBuildReconciler, name string) *rbacv1.RoleBinding {
+		rb := &rbacv1.RoleBinding{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: name, Namespace: "builds"}, rb)).To(Succeed())
+		return rb
+	}
+
+	It("should prefer the ImageBuild's service account, then the BuildConfig's", func() {
+		imageBuild := &automotivev1.ImageBuild{}
+		Expect(resolveServiceAccountName(imageBuild, nil)).To(Equal(BuildServiceAccountName))
+		buildConfig := &automotivev1.BuildConfig{ServiceAccountName: "team-builder"}
+		Expect(resolveServiceAccountName(imageBuild, buildConfig)).To(Equal("team-builder"))
+		imageBuild.Spec.ServiceAccountName = "mine"
+		Expect(resolveServiceAccountName(imageBuild, buildConfig)).To(Equal("mine"))
+	})
+
+	It("should bind the build service account to the build SCC and nothing else", func() {
+		r := newTestReconciler()
+		Expect(r.ensureBuildServiceAccount(ctx, "builds")).To(Succeed())
+		Expect(r.ensureBuildServiceAccount(ctx, "builds")).To(Succeed())
+
+		Expect(r.Get(ctx, client.ObjectKey{Name: BuildServiceAccountName, Namespace: "builds"}, &corev1.ServiceAccount{})).To(Succeed())
+		rb := binding(r, buildscc.ClusterRoleName)
+		Expect(rb.RoleRef.Name).To(Equal(buildscc.ClusterRoleName))
+		Expect(rb.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: BuildServiceAccountName, Namespace: "builds"}))
+
+		scc := &securityv1.SecurityContextConstraints{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: buildscc.SCCName}, scc)).To(Succeed())
+		Expect(scc.AllowHostNetwork || scc.AllowHostPID || scc.AllowHostIPC || scc.AllowHostPorts).To(BeFalse())
+		Expect(scc.SeccompProfiles).To(Equal([]string{"runtime/default"}))
+	})
+
+	It("should run helper pods as an unprivileged service account", func() {
+		r := newTestReconciler()
+		imageBuild := &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "build", Namespace: "builds"},
+			Status:     automotivev1.ImageBuildStatus{PVCName: "build-ws"},
+		}
+		Expect(r.createBuildResultsPod(ctx, imageBuild, nil)).To(Succeed())
+
+		pod := &corev1.Pod{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: buildResultsPodName(imageBuild), Namespace: "builds"}, pod)).To(Succeed())
+		Expect(pod.Spec.ServiceAccountName).To(Equal(HelperServiceAccountName))
+		rb := binding(r, buildscc.HelperClusterRoleName)
+		Expect(rb.Subjects).To(ConsistOf(rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: HelperServiceAccountName, Namespace: "builds"}))
+
+		role := &rbacv1.ClusterRole{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: buildscc.HelperClusterRoleName}, role)).To(Succeed())
+		Expect(role.Rules).To(HaveLen(1))
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{buildscc.HelperSCCName}))
+		err := r.Get(ctx, client.ObjectKey{Name: buildscc.ClusterRoleName, Namespace: "builds"}, &rbacv1.RoleBinding{})
+		Expect(err).To(HaveOccurred(), "the helper account must not get the build SCC")
+	})
+})
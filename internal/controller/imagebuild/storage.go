@@ -0,0 +1,199 @@
+package imagebuild
+
+import (
+	"cmp"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+)
+
+// errWorkspaceStorageExceeded marks a build that cannot get its workspace yet: the workspace would take its
+// namespace over the BuildConfig's MaxWorkspaceStorage, MaxConcurrentBuilds builds are running or other builds
+// are ahead in the queue. The build waits instead of failing.
+var errWorkspaceStorageExceeded = stderrors.New("workspace storage limit reached")
+
+// workspaceWaitMessage prefixes the status message of builds waiting for workspace storage, which marks them
+// as queued
+const workspaceWaitMessage = "Waiting for workspace storage"
+
+// requestedByAnnotation names who requested a build; the workspace queue takes turns between them
+const requestedByAnnotation = "automotive.sdv.cloud.redhat.com/requested-by"
+
+func isWorkspaceStorageExceeded(err error) bool {
+	return stderrors.Is(err, errWorkspaceStorageExceeded)
+}
+
+// workspaceSize returns the size of the workspace PVC of a build: the size it requested, or the BuildConfig's
+// PVCSize
+func workspaceSize(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) resource.Quantity {
+	if size := imageBuild.Spec.WorkspaceSize; size != "" {
+		if q, err := resource.ParseQuantity(size); err == nil && q.Sign() > 0 {
+			return q
+		}
+	}
+	if buildConfig != nil && buildConfig.PVCSize != "" {
+		return resource.MustParse(buildConfig.PVCSize)
+	}
+	return resource.MustParse("8Gi")
+}
+
+// checkWorkspaceStorage verifies that a build may get a new workspace of size: that it fits in the namespace's
+// workspace storage limit, that fewer builds than the BuildConfig allows are running, and that it is the build's
+// turn. Builds waiting for a workspace form one queue across namespaces, which takes turns between requesters
+// so that one requester's batch of builds cannot starve the others.
+func (r *ImageBuildReconciler) checkWorkspaceStorage(ctx context.Context, imageBuild *automotivev1.ImageBuild, size resource.Quantity, buildConfig *automotivev1.BuildConfig) error {
+	var limit int64
+	if buildConfig != nil && buildConfig.MaxWorkspaceStorage != "" {
+		var err error
+		if limit, err = storage.ParseLimit(buildConfig.MaxWorkspaceStorage); err != nil {
+			return err
+		}
+	}
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcs, client.MatchingLabels(storage.WorkspaceSelector())); err != nil {
+		return fmt.Errorf("failed to list workspace PVCs: %w", err)
+	}
+	live := storage.Live(pvcs.Items)
+	used := map[string]*storage.Usage{}
+	for _, pvc := range live {
+		if used[pvc.Namespace] == nil {
+			used[pvc.Namespace] = &storage.Usage{}
+		}
+		used[pvc.Namespace].Add(&pvc)
+	}
+	fits := func(b *automotivev1.ImageBuild, size resource.Quantity) bool {
+		return limit == 0 || used[b.Namespace] == nil || used[b.Namespace].Bytes+size.Value() <= limit
+	}
+	if !fits(imageBuild, size) {
+		inUse := used[imageBuild.Namespace]
+		return fmt.Errorf("%w: %d workspaces use %s of %s, a new one needs %s", errWorkspaceStorageExceeded,
+			inUse.Workspaces, resource.NewQuantity(inUse.Bytes, resource.BinarySI), buildConfig.MaxWorkspaceStorage, size.String())
+	}
+
+	builds := &automotivev1.ImageBuildList{}
+	if err := r.List(ctx, builds); err != nil {
+		return fmt.Errorf("failed to list builds: %w", err)
+	}
+	if buildConfig != nil && buildConfig.MaxConcurrentBuilds > 0 {
+		if running := runningBuilds(builds.Items, live); running >= int(buildConfig.MaxConcurrentBuilds) {
+			return fmt.Errorf("%w: %d builds are running, as many as may run at once", errWorkspaceStorageExceeded, running)
+		}
+	}
+	next := nextInWorkspaceQueue(imageBuild, builds.Items, live, func(b *automotivev1.ImageBuild) bool {
+		return fits(b, workspaceSize(b, buildConfig))
+	})
+	if next.Name != imageBuild.Name || next.Namespace != imageBuild.Namespace {
+		return fmt.Errorf("%w: build %s/%s of %s is next in line", errWorkspaceStorageExceeded, next.Namespace, next.Name, requester(next))
+	}
+	return nil
+}
+
+// buildKey identifies a build across namespaces
+func buildKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// runningBuilds counts the unfinished builds holding a live workspace
+func runningBuilds(builds []automotivev1.ImageBuild, live []corev1.PersistentVolumeClaim) int {
+	hasWorkspace := map[string]bool{}
+	for _, pvc := range live {
+		hasWorkspace[buildKey(pvc.Namespace, pvc.Labels[storage.ImageBuildNameLabel])] = true
+	}
+	running := 0
+	for i := range builds {
+		b := &builds[i]
+		if hasWorkspace[buildKey(b.Namespace, b.Name)] && b.DeletionTimestamp == nil && !isFinished(b.Status.Phase) {
+			running++
+		}
+	}
+	return running
+}
+
+// nextInWorkspaceQueue returns the build that gets the next workspace among imageBuild and the builds of all
+// namespaces waiting for one whose workspace fits. Requesters, told apart by namespace and requested-by
+// annotation, take turns: the one whose newest workspace among live is the oldest, or who has none, goes first,
+// with its oldest waiting build. Ties go to the requester who has waited longest.
+func nextInWorkspaceQueue(imageBuild *automotivev1.ImageBuild, builds []automotivev1.ImageBuild, live []corev1.PersistentVolumeClaim,
+	fits func(*automotivev1.ImageBuild) bool) *automotivev1.ImageBuild {
+	requesters := make(map[string]string, len(builds))
+	for i := range builds {
+		requesters[buildKey(builds[i].Namespace, builds[i].Name)] = queueRequester(&builds[i])
+	}
+	lastServed := map[string]time.Time{}
+	hasWorkspace := map[string]bool{}
+	for _, pvc := range live {
+		key := buildKey(pvc.Namespace, pvc.Labels[storage.ImageBuildNameLabel])
+		hasWorkspace[key] = true
+		if who, ok := requesters[key]; ok && pvc.CreationTimestamp.After(lastServed[who]) {
+			lastServed[who] = pvc.CreationTimestamp.Time
+		}
+	}
+
+	queue := []*automotivev1.ImageBuild{imageBuild}
+	for i := range builds {
+		b := &builds[i]
+		if (b.Name != imageBuild.Name || b.Namespace != imageBuild.Namespace) && b.DeletionTimestamp == nil &&
+			!hasWorkspace[buildKey(b.Namespace, b.Name)] && (b.Status.Phase == "" || b.Status.Phase == "Building") &&
+			strings.HasPrefix(b.Status.Message, workspaceWaitMessage) && fits(b) {
+			queue = append(queue, b)
+		}
+	}
+	// oldest first, so the first build of each requester is its oldest
+	slices.SortFunc(queue, func(a, b *automotivev1.ImageBuild) int {
+		return cmp.Or(a.CreationTimestamp.Time.Compare(b.CreationTimestamp.Time),
+			cmp.Compare(a.Namespace, b.Namespace), cmp.Compare(a.Name, b.Name))
+	})
+	next := queue[0]
+	for _, b := range queue[1:] {
+		if lastServed[queueRequester(b)].Before(lastServed[queueRequester(next)]) {
+			next = b
+		}
+	}
+	return next
+}
+
+// queueRequester is who the workspace queue takes turns between: a requester of a namespace
+func queueRequester(imageBuild *automotivev1.ImageBuild) string {
+	return imageBuild.Namespace + "/" + requester(imageBuild)
+}
+
+// requester names who requested a build, for the workspace queue and its metrics
+func requester(imageBuild *automotivev1.ImageBuild) string {
+	if who := imageBuild.Annotations[requestedByAnnotation]; who != "" {
+		return who
+	}
+	return "unknown"
+}
+
+// waitForWorkspaceStorage records why a build cannot get its workspace yet and polls until other builds
+// release enough storage, or it is its turn to get it
+func (r *ImageBuildReconciler) waitForWorkspaceStorage(ctx context.Context, imageBuild *automotivev1.ImageBuild, cause error) (ctrl.Result, error) {
+	message := fmt.Sprintf("%s: %v", workspaceWaitMessage, cause)
+	if imageBuild.Status.Message == message {
+		return r.Requeue.Poll("workspace-storage"), nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return r.Requeue.Retry("status"), nil
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.Message = message
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return r.Requeue.Retry("status"), nil
+	}
+	return r.Requeue.Poll("workspace-storage"), nil
+}
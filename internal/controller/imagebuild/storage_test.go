@@ -0,0 +1,179 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/storage"
+)
+
+var _ = Describe("Workspace queue", func() {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) metav1.Time { return metav1.NewTime(start.Add(time.Duration(minutes) * time.Minute)) }
+
+	// build returns a build of a requester created at a minute; waiting builds carry the workspace wait message
+	build := func(namespace, name, who string, minute int, waiting bool) automotivev1.ImageBuild {
+		b := automotivev1.ImageBuild{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         namespace,
+			CreationTimestamp: at(minute),
+			Annotations:       map[string]string{requestedByAnnotation: who},
+		}}
+		if waiting {
+			b.Status.Message = workspaceWaitMessage + ": build x is next in line"
+		}
+		return b
+	}
+	// workspace returns the live workspace PVC of a build, created at a minute
+	workspace := func(namespace, buildName string, minute int) corev1.PersistentVolumeClaim {
+		return corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Name:              buildName + "-ws",
+			Namespace:         namespace,
+			CreationTimestamp: at(minute),
+			Labels:            storage.WorkspaceLabels(buildName),
+		}, Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("8Gi")},
+			},
+		}}
+	}
+	all := func(*automotivev1.ImageBuild) bool { return true }
+
+	Describe("nextInWorkspaceQueue", func() {
+		It("should go to the oldest waiting build when nobody got a workspace yet", func() {
+			builds := []automotivev1.ImageBuild{
+				build("ns", "a1", "alice", 1, true),
+				build("ns", "b1", "bob", 2, true),
+			}
+			self := build("ns", "c1", "carol", 3, false)
+			Expect(nextInWorkspaceQueue(&self, builds, nil, all).Name).To(Equal("a1"))
+		})
+
+		It("should take turns between requesters instead of serving a batch in order", func() {
+			builds := []automotivev1.ImageBuild{
+				build("ns", "a1", "alice", 1, false),
+				build("ns", "a2", "alice", 2, true),
+				build("ns", "a3", "alice", 3, true),
+				build("ns", "b1", "bob", 10, true),
+			}
+			live := []corev1.PersistentVolumeClaim{workspace("ns", "a1", 5)}
+			self := builds[1]
+			Expect(nextInWorkspaceQueue(&self, builds, live, all).Name).To(Equal("b1"))
+
+			// once bob got one, alice's oldest waiting build is next
+			builds[3].Status.Message = ""
+			live = append(live, workspace("ns", "b1", 12))
+			Expect(nextInWorkspaceQueue(&self, builds, live, all).Name).To(Equal("a2"))
+		})
+
+		It("should interleave namespaces even when the requester is the same", func() {
+			builds := []automotivev1.ImageBuild{
+				build("team-a", "x1", "", 1, false),
+				build("team-a", "x2", "", 2, true),
+				build("team-b", "y1", "", 5, true),
+			}
+			live := []corev1.PersistentVolumeClaim{workspace("team-a", "x1", 3)}
+			self := builds[1]
+			next := nextInWorkspaceQueue(&self, builds, live, all)
+			Expect(next.Namespace).To(Equal("team-b"))
+			Expect(next.Name).To(Equal("y1"))
+		})
+
+		It("should tell apart builds of the same name in different namespaces", func() {
+			builds := []automotivev1.ImageBuild{
+				build("team-a", "nightly", "alice", 1, false),
+				build("team-b", "nightly", "bob", 2, true),
+			}
+			// team-a/nightly has a workspace, which must not take team-b/nightly out of the queue
+			live := []corev1.PersistentVolumeClaim{workspace("team-a", "nightly", 3)}
+			self := build("team-a", "later", "alice", 4, false)
+			next := nextInWorkspaceQueue(&self, builds, live, all)
+			Expect(next.Namespace).To(Equal("team-b"))
+		})
+
+		It("should skip builds that are not waiting or whose workspace does not fit", func() {
+			finished := build("ns", "done", "bob", 1, true)
+			finished.Status.Phase = "Failed"
+			deleting := build("ns", "gone", "bob", 1, true)
+			deleting.DeletionTimestamp = ptr.To(at(20))
+			builds := []automotivev1.ImageBuild{
+				finished,
+				deleting,
+				build("ns", "idle", "bob", 1, false),
+				build("ns", "huge", "dave", 1, true),
+			}
+			self := build("ns", "a1", "alice", 5, false)
+			fits := func(b *automotivev1.ImageBuild) bool { return b.Name != "huge" }
+			Expect(nextInWorkspaceQueue(&self, builds, nil, fits).Name).To(Equal("a1"))
+		})
+	})
+
+	Describe("checkWorkspaceStorage", func() {
+		ctx := context.Background()
+		size := resource.MustParse("8Gi")
+
+		running := func(namespace, name string) []client.Object {
+			b := build(namespace, name, "alice", 0, false)
+			b.Status.Phase = "Building"
+			pvc := workspace(namespace, name, 0)
+			return []client.Object{&b, &pvc}
+		}
+
+		It("should queue builds without a storage limit once MaxConcurrentBuilds builds run", func() {
+			var objs []client.Object
+			for _, name := range []string{"r0", "r1", "r2", "r3", "r4", "r5", "r6", "r7", "r8", "r9"} {
+				objs = append(objs, running("ns", name)...)
+			}
+			r := newTestReconciler(objs...)
+			self := build("other", "new", "bob", 30, false)
+			Expect(isWorkspaceStorageExceeded(r.checkWorkspaceStorage(ctx, &self, size, &automotivev1.BuildConfig{MaxConcurrentBuilds: 10}))).To(BeTrue())
+			Expect(r.checkWorkspaceStorage(ctx, &self, size, &automotivev1.BuildConfig{MaxConcurrentBuilds: 11})).To(Succeed())
+		})
+
+		It("should not cap running builds unless MaxConcurrentBuilds is set", func() {
+			var objs []client.Object
+			for i := range 20 {
+				objs = append(objs, running("ns", fmt.Sprintf("r%d", i))...)
+			}
+			r := newTestReconciler(objs...)
+			self := build("other", "new", "bob", 30, false)
+			Expect(r.checkWorkspaceStorage(ctx, &self, size, nil)).To(Succeed())
+			Expect(r.checkWorkspaceStorage(ctx, &self, size, &automotivev1.BuildConfig{})).To(Succeed())
+		})
+
+		It("should make builds wait for their turn without a storage limit", func() {
+			objs := running("ns", "a1")
+			waiting := build("team-b", "b1", "bob", 10, true)
+			objs = append(objs, &waiting)
+			r := newTestReconciler(objs...)
+
+			self := build("ns", "a2", "alice", 5, true)
+			err := r.checkWorkspaceStorage(ctx, &self, size, nil)
+			Expect(isWorkspaceStorageExceeded(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("team-b/b1 of bob is next in line")))
+			Expect(r.checkWorkspaceStorage(ctx, &waiting, size, nil)).To(Succeed())
+		})
+
+		It("should not let a build over its namespace's storage hold up other namespaces", func() {
+			objs := running("full", "a1")
+			stuck := build("full", "a2", "alice", 1, true)
+			objs = append(objs, &stuck)
+			r := newTestReconciler(objs...)
+			buildConfig := &automotivev1.BuildConfig{MaxWorkspaceStorage: "10Gi"}
+
+			Expect(isWorkspaceStorageExceeded(r.checkWorkspaceStorage(ctx, &stuck, size, buildConfig))).To(BeTrue())
+			self := build("free", "b1", "bob", 5, false)
+			Expect(r.checkWorkspaceStorage(ctx, &self, size, buildConfig)).To(Succeed())
+		})
+	})
+})
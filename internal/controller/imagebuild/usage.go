@@ -0,0 +1,73 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+// parseResourceUsage parses the "memory-peak=BYTES cpu-seconds=N disk=BYTES" line the build step writes,
+// followed by the "workspace=BYTES workspace-pruned=BYTES" line of the prune step. Unknown keys are skipped,
+// so the steps may report more than the operator knows about.
+func parseResourceUsage(line string) (*automotivev1.ResourceUsage, error) {
+	usage := &automotivev1.ResourceUsage{}
+	for _, field := range strings.Fields(line) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed resource usage field %q", field)
+		}
+		var n *int64
+		switch key {
+		case "memory-peak":
+			n = &usage.PeakMemoryBytes
+		case "cpu-seconds":
+			n = &usage.CPUSeconds
+		case "disk":
+			n = &usage.DiskBytes
+		case "workspace":
+			n = &usage.WorkspaceBytes
+		case "workspace-pruned":
+			n = &usage.PrunedWorkspaceBytes
+		default:
+			continue
+		}
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed %s in resource usage: %w", key, err)
+		}
+		*n = v
+	}
+	return usage, nil
+}
+
+// recordResourceUsage stores the resource-usage and workspace-usage results of a finished build run in the
+// status. Builds that did not report them, or reported them malformed, are left without usage.
+func (r *ImageBuildReconciler) recordResourceUsage(ctx context.Context, imageBuild *automotivev1.ImageBuild, run *buildRun) error {
+	line := strings.TrimSpace(run.results["resource-usage"] + " " + run.results["workspace-usage"])
+	if line == "" {
+		return nil
+	}
+	usage, err := parseResourceUsage(line)
+	if err != nil {
+		r.Log.Error(err, "ignoring resource usage of build", "imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace})
+		return nil
+	}
+	if equality.Semantic.DeepEqual(usage, imageBuild.Status.ResourceUsage) {
+		return nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	fresh.Status.ResourceUsage = usage
+	return r.Status().Patch(ctx, fresh, patch)
+}
@@ -0,0 +1,268 @@
+package imagebuild
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultFailedWorkspaceTTLHours is how long a kept failed-build workspace is served when BuildConfig does not say
+const defaultFailedWorkspaceTTLHours = 6
+
+// getBuildConfig returns the BuildConfig of the operator's AutomotiveDev, or nil if there is none
+func (r *ImageBuildReconciler) getBuildConfig(ctx context.Context) (*automotivev1.BuildConfig, error) {
+	autoDev := &automotivev1.AutomotiveDev{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "automotive-dev", Namespace: OperatorNamespace}, autoDev); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get AutomotiveDev configuration: %w", err)
+	}
+	return autoDev.Spec.BuildConfig, nil
+}
+
+// keepWorkspaceOnFailure reports whether a failed build's workspace is kept, the ImageBuild overriding the default
+func keepWorkspaceOnFailure(imageBuild *automotivev1.ImageBuild, buildConfig *automotivev1.BuildConfig) bool {
+	if imageBuild.Spec.KeepWorkspaceOnFailure != nil {
+		return *imageBuild.Spec.KeepWorkspaceOnFailure
+	}
+	return buildConfig != nil && buildConfig.KeepWorkspaceOnFailure
+}
+
+func failedWorkspaceTTL(buildConfig *automotivev1.BuildConfig) time.Duration {
+	hours := int32(defaultFailedWorkspaceTTLHours)
+	if buildConfig != nil && buildConfig.FailedWorkspaceTTLHours > 0 {
+		hours = buildConfig.FailedWorkspaceTTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// handleFailedState serves the workspace of a failed build through the artifact pod when the build asked
+// to keep it, and tears the pod down once the TTL has passed
+func (r *ImageBuildReconciler) handleFailedState(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, error) {
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !keepWorkspaceOnFailure(imageBuild, buildConfig) || imageBuild.Status.PVCName == "" {
+		return ctrl.Result{}, nil
+	}
+	if imageBuild.Status.CompletionTime == nil {
+		return r.Requeue.Poll("completion"), nil
+	}
+
+	log := r.buildLog(imageBuild)
+
+	expiryAt := imageBuild.Status.CompletionTime.Add(failedWorkspaceTTL(buildConfig))
+	if time.Now().Before(expiryAt) {
+		if imageBuild.Status.WorkspaceExpiryTime == nil {
+			if err := r.createArtifactPod(ctx, imageBuild); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			fresh := &automotivev1.ImageBuild{}
+			if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+				return ctrl.Result{}, err
+			}
+			patch := client.MergeFrom(fresh.DeepCopy())
+			fresh.Status.WorkspaceExpiryTime = &metav1.Time{Time: expiryAt}
+			fresh.Status.Message = fmt.Sprintf("Build failed; workspace kept for debugging until %s", expiryAt.UTC().Format(time.RFC3339))
+			if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to record workspace expiry: %w", err)
+			}
+			log.Info("Keeping failed build workspace", "until", expiryAt)
+		}
+		return ctrl.Result{RequeueAfter: time.Until(expiryAt)}, nil
+	}
+
+	r.deleteArtifactServing(ctx, imageBuild)
+
+	if imageBuild.Status.WorkspaceExpiryTime != nil {
+		fresh := &automotivev1.ImageBuild{}
+		if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err == nil {
+			patch := client.MergeFrom(fresh.DeepCopy())
+			fresh.Status.WorkspaceExpiryTime = nil
+			fresh.Status.Message = "Build failed; debug workspace expired"
+			if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+				log.Error(err, "failed to update ImageBuild status after workspace cleanup")
+			}
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// defaultPVCBindTimeoutMinutes is how long a workspace PVC may stay unbound when BuildConfig does not say
+const defaultPVCBindTimeoutMinutes = 10
+
+// WorkspaceBound condition reasons
+const (
+	workspaceBoundReasonBound   = "Bound"
+	workspaceBoundReasonPending = "Pending"
+)
+
+// pvcPendingMessagePrefix starts the status message of builds waiting for their workspace
+const pvcPendingMessagePrefix = "PVC pending: "
+
+func pvcBindTimeout(buildConfig *automotivev1.BuildConfig) time.Duration {
+	minutes := int32(defaultPVCBindTimeoutMinutes)
+	if buildConfig != nil && buildConfig.PVCBindTimeoutMinutes > 0 {
+		minutes = buildConfig.PVCBindTimeoutMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// checkWorkspaceBound reports a workspace PVC that is not bound yet in the build's status and fails the build
+// once the PVC stays unbound past the bind timeout. It reports whether the PVC is unbound, in which case the
+// returned result ends the reconcile.
+func (r *ImageBuildReconciler) checkWorkspaceBound(ctx context.Context, imageBuild *automotivev1.ImageBuild) (ctrl.Result, bool, error) {
+	if imageBuild.Status.PVCName == "" {
+		return ctrl.Result{}, false, nil
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Status.PVCName, Namespace: imageBuild.Namespace}, pvc); err != nil {
+		// a missing PVC is recreated when the build is started again
+		return ctrl.Result{}, false, client.IgnoreNotFound(err)
+	}
+
+	if pvc.Status.Phase == corev1.ClaimBound {
+		if meta.IsStatusConditionFalse(imageBuild.Status.Conditions, automotivev1.ImageBuildWorkspaceBound) {
+			if err := r.setWorkspaceBoundCondition(ctx, imageBuild, metav1.ConditionTrue, workspaceBoundReasonBound,
+				fmt.Sprintf("Workspace PVC %s is bound", pvc.Name)); err != nil {
+				return ctrl.Result{}, false, err
+			}
+		}
+		return ctrl.Result{}, false, nil
+	}
+
+	buildConfig, err := r.getBuildConfig(ctx)
+	if err != nil {
+		return ctrl.Result{}, true, err
+	}
+	reason := r.pvcPendingReason(ctx, pvc)
+	storageClass := "the default storage class"
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		storageClass = fmt.Sprintf("storage class %q", *pvc.Spec.StorageClassName)
+	}
+
+	timeout := pvcBindTimeout(buildConfig)
+	if pending := time.Since(pvc.CreationTimestamp.Time); pending > timeout {
+		r.Log.Info("Workspace PVC was not bound in time, failing the build",
+			"imagebuild", types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, "pvc", pvc.Name, "reason", reason)
+		if err := r.stopBuild(ctx, imageBuild); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		message := fmt.Sprintf("Workspace PVC %s was not bound within %s; check that %s can provision volumes in namespace %s: %s",
+			pvc.Name, timeout, storageClass, pvc.Namespace, reason)
+		if err := r.setWorkspaceBoundCondition(ctx, imageBuild, metav1.ConditionFalse, workspaceBoundReasonPending, message); err != nil {
+			return r.Requeue.Retry("status"), true, nil
+		}
+		if err := r.updateStatus(ctx, imageBuild, "Failed", message); err != nil {
+			return r.Requeue.Retry("status"), true, nil
+		}
+		return ctrl.Result{}, true, nil
+	}
+
+	message := fmt.Sprintf("%s%s (%s)", pvcPendingMessagePrefix, reason, storageClass)
+	if err := r.setWorkspaceBoundCondition(ctx, imageBuild, metav1.ConditionFalse, workspaceBoundReasonPending, message); err != nil {
+		return ctrl.Result{}, true, err
+	}
+	// PVC updates requeue the build, but provisioning errors only show in events
+	return r.Requeue.Poll("workspace"), true, nil
+}
+
+// pvcPendingReason describes why a PVC is not bound from its most recent event, preferring warnings
+func (r *ImageBuildReconciler) pvcPendingReason(ctx context.Context, pvc *corev1.PersistentVolumeClaim) string {
+	const fallback = "waiting for a volume to be provisioned"
+	if r.Clientset == nil {
+		return fallback
+	}
+	events, err := r.Clientset.CoreV1().Events(pvc.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pvc.UID)).String(),
+	})
+	if err != nil || len(events.Items) == 0 {
+		return fallback
+	}
+	var latest *corev1.Event
+	for i := range events.Items {
+		e := &events.Items[i]
+		switch {
+		case latest == nil:
+			latest = e
+		case (e.Type == corev1.EventTypeWarning) != (latest.Type == corev1.EventTypeWarning):
+			if e.Type == corev1.EventTypeWarning {
+				latest = e
+			}
+		case eventTime(e).After(eventTime(latest)):
+			latest = e
+		}
+	}
+	return fmt.Sprintf("%s: %s", latest.Reason, strings.TrimSpace(latest.Message))
+}
+
+// eventTime is when an event was last seen
+func eventTime(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil:
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// setWorkspaceBoundCondition records the WorkspaceBound condition, patching the status only when it changes.
+// The status message follows the condition while the build waits for its workspace.
+func (r *ImageBuildReconciler) setWorkspaceBoundCondition(ctx context.Context, imageBuild *automotivev1.ImageBuild, status metav1.ConditionStatus, reason, message string) error {
+	return r.setWaitCondition(ctx, imageBuild, automotivev1.ImageBuildWorkspaceBound, pvcPendingMessagePrefix, status, reason, message)
+}
+
+// setWaitCondition records a condition that is False while the build waits for a resource, patching the status
+// only when it changes. While it is False with a message starting with messagePrefix the status message
+// follows it, and returns to the one of the phase once it turns True.
+func (r *ImageBuildReconciler) setWaitCondition(ctx context.Context, imageBuild *automotivev1.ImageBuild, conditionType, messagePrefix string,
+	status metav1.ConditionStatus, reason, message string) error {
+	existing := meta.FindStatusCondition(imageBuild.Status.Conditions, conditionType)
+	if existing != nil && existing.Status == status && existing.Reason == reason && existing.Message == message {
+		return nil
+	}
+
+	fresh := &automotivev1.ImageBuild{}
+	if err := r.Get(ctx, types.NamespacedName{Name: imageBuild.Name, Namespace: imageBuild.Namespace}, fresh); err != nil {
+		return fmt.Errorf("failed to get fresh ImageBuild: %w", err)
+	}
+	patch := client.MergeFrom(fresh.DeepCopy())
+	meta.SetStatusCondition(&fresh.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: fresh.Generation,
+	})
+	if status == metav1.ConditionFalse && strings.HasPrefix(message, messagePrefix) {
+		fresh.Status.Message = message
+	} else if status == metav1.ConditionTrue && strings.HasPrefix(fresh.Status.Message, messagePrefix) {
+		// back to the message the phase started with
+		fresh.Status.Message = "Build started"
+		if fresh.Status.Phase == "Uploading" {
+			fresh.Status.Message = uploadStatusMessage(fresh)
+		}
+	}
+	if err := r.Status().Patch(ctx, fresh, patch); err != nil {
+		return fmt.Errorf("failed to update %s condition: %w", conditionType, err)
+	}
+	imageBuild.Status.Conditions = fresh.Status.Conditions
+	return nil
+}
@@ -0,0 +1,137 @@
+package imagebuild
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+)
+
+var _ = Describe("Workspace binding", func() {
+	ctx := context.Background()
+
+	// building returns a running build whose workspace is the PVC "ws"
+	building := func() *automotivev1.ImageBuild {
+		return &automotivev1.ImageBuild{
+			ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "ns"},
+			Status: automotivev1.ImageBuildStatus{
+				Phase:   "Building",
+				Message: "Build started",
+				PVCName: "ws",
+			},
+		}
+	}
+	pvc := func(phase corev1.PersistentVolumeClaimPhase, age time.Duration) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "ws",
+				Namespace:         "ns",
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Spec:   corev1.PersistentVolumeClaimSpec{StorageClassName: ptr.To("slow")},
+			Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+		}
+	}
+	fetch := func(r *ImageBuildReconciler) *automotivev1.ImageBuild {
+		fresh := &automotivev1.ImageBuild{}
+		Expect(r.Get(ctx, client.ObjectKey{Name: "b", Namespace: "ns"}, fresh)).To(Succeed())
+		return fresh
+	}
+
+	It("should report a pending PVC and poll until it binds", func() {
+		imageBuild := building()
+		r := newTestReconciler(imageBuild, pvc(corev1.ClaimPending, time.Minute))
+
+		result, unbound, err := r.checkWorkspaceBound(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbound).To(BeTrue())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Phase).To(Equal("Building"))
+		Expect(fresh.Status.Message).To(HavePrefix(pvcPendingMessagePrefix))
+		Expect(fresh.Status.Message).To(ContainSubstring(`storage class "slow"`))
+		Expect(meta.IsStatusConditionFalse(fresh.Status.Conditions, automotivev1.ImageBuildWorkspaceBound)).To(BeTrue())
+	})
+
+	It("should fail the build and stop its runs when the PVC is not bound in time", func() {
+		imageBuild := building()
+		taskRun := &tektonv1.TaskRun{ObjectMeta: metav1.ObjectMeta{
+			Name:      "b-build",
+			Namespace: "ns",
+			Labels:    map[string]string{"automotive.sdv.cloud.redhat.com/imagebuild-name": "b"},
+		}}
+		r := newTestReconciler(imageBuild, taskRun, pvc(corev1.ClaimPending, 11*time.Minute))
+
+		result, unbound, err := r.checkWorkspaceBound(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbound).To(BeTrue())
+		Expect(result.IsZero()).To(BeTrue())
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Phase).To(Equal("Failed"))
+		Expect(fresh.Status.Message).To(ContainSubstring("Workspace PVC ws was not bound within 10m0s"))
+		Expect(fresh.Status.CompletionTime).NotTo(BeNil())
+		Expect(meta.IsStatusConditionFalse(fresh.Status.Conditions, automotivev1.ImageBuildWorkspaceBound)).To(BeTrue())
+
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(taskRun), taskRun)).To(Succeed())
+		Expect(taskRun.Spec.Status).To(Equal(tektonv1.TaskRunSpecStatus(tektonv1.TaskRunSpecStatusCancelled)))
+	})
+
+	It("should honour the bind timeout of the BuildConfig", func() {
+		imageBuild := building()
+		autoDev := &automotivev1.AutomotiveDev{
+			ObjectMeta: metav1.ObjectMeta{Name: "automotive-dev", Namespace: OperatorNamespace},
+			Spec: automotivev1.AutomotiveDevSpec{
+				BuildConfig: &automotivev1.BuildConfig{PVCBindTimeoutMinutes: 30},
+			},
+		}
+		r := newTestReconciler(imageBuild, autoDev, pvc(corev1.ClaimPending, 11*time.Minute))
+
+		_, unbound, err := r.checkWorkspaceBound(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbound).To(BeTrue())
+		Expect(fetch(r).Status.Phase).To(Equal("Building"))
+	})
+
+	It("should mark the workspace bound and restore the phase's message once the PVC binds", func() {
+		imageBuild := building()
+		imageBuild.Status.Message = pvcPendingMessagePrefix + "waiting for a volume to be provisioned"
+		meta.SetStatusCondition(&imageBuild.Status.Conditions, metav1.Condition{
+			Type:    automotivev1.ImageBuildWorkspaceBound,
+			Status:  metav1.ConditionFalse,
+			Reason:  workspaceBoundReasonPending,
+			Message: imageBuild.Status.Message,
+		})
+		r := newTestReconciler(imageBuild, pvc(corev1.ClaimBound, time.Minute))
+
+		result, unbound, err := r.checkWorkspaceBound(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbound).To(BeFalse())
+		Expect(result.IsZero()).To(BeTrue())
+
+		fresh := fetch(r)
+		Expect(fresh.Status.Phase).To(Equal("Building"))
+		Expect(fresh.Status.Message).To(Equal("Build started"))
+		Expect(meta.IsStatusConditionTrue(fresh.Status.Conditions, automotivev1.ImageBuildWorkspaceBound)).To(BeTrue())
+	})
+
+	It("should leave builds without a workspace alone", func() {
+		imageBuild := building()
+		imageBuild.Status.PVCName = ""
+		r := newTestReconciler(imageBuild)
+
+		_, unbound, err := r.checkWorkspaceBound(ctx, imageBuild)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(unbound).To(BeFalse())
+	})
+})
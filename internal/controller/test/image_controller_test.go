@@ -18,10 +18,17 @@ package test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -102,4 +109,123 @@ var _ = Describe("Image Controller", func() {
 			}).Should(BeTrue())
 		})
 	})
+
+	Context("When garbage collecting stale images", func() {
+		ctx := context.Background()
+
+		autoDevName := types.NamespacedName{Name: "automotive-dev", Namespace: image.OperatorNamespace}
+
+		var registry *httptest.Server
+		var deleted []string
+
+		// createImage creates an image built long ago that was accessed accessCount times
+		createImage := func(name string, accessCount int64) types.NamespacedName {
+			built := metav1.NewTime(time.Now().Add(-30 * 24 * time.Hour))
+			img := &automotivev1.Image{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: automotivev1.ImageSpec{
+					Distro:       "autosd",
+					Target:       "qemu",
+					Architecture: "amd64",
+					ExportFormat: "qcow2",
+					Location: automotivev1.ImageLocation{
+						Type: "registry",
+						Registry: &automotivev1.RegistryLocation{
+							URL:    strings.TrimPrefix(registry.URL, "https://") + "/org/autosd:" + name,
+							Digest: "sha256:" + name,
+						},
+					},
+					Metadata: &automotivev1.ImageMetadata{BuildDate: &built},
+				},
+			}
+			Expect(k8sClient.Create(ctx, img)).To(Succeed())
+			img.Status.AccessCount = accessCount
+			Expect(k8sClient.Status().Update(ctx, img)).To(Succeed())
+			return types.NamespacedName{Name: name, Namespace: "default"}
+		}
+
+		setPolicy := func(policy *automotivev1.ImageGCPolicy) {
+			av := &automotivev1.AutomotiveDev{}
+			Expect(k8sClient.Get(ctx, autoDevName, av)).To(Succeed())
+			av.Spec.ImageGC = policy
+			Expect(k8sClient.Update(ctx, av)).To(Succeed())
+		}
+
+		reconcileImage := func(name types.NamespacedName) {
+			controllerReconciler := &image.ImageReconciler{
+				Client:     k8sClient,
+				Scheme:     k8sClient.Scheme(),
+				Log:        logr.Discard(),
+				HTTPClient: registry.Client(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: name})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			By("starting a fake registry that records manifest deletions")
+			deleted = nil
+			registry = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				deleted = append(deleted, r.URL.Path)
+				w.WriteHeader(http.StatusAccepted)
+			}))
+
+			By("creating the operator namespace and its AutomotiveDev")
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: image.OperatorNamespace}}
+			if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(k8sClient.Create(ctx, &automotivev1.AutomotiveDev{
+				ObjectMeta: metav1.ObjectMeta{Name: autoDevName.Name, Namespace: autoDevName.Namespace},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			registry.Close()
+
+			By("Cleanup the AutomotiveDev and the images")
+			av := &automotivev1.AutomotiveDev{}
+			Expect(k8sClient.Get(ctx, autoDevName, av)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, av)).To(Succeed())
+			for _, name := range []string{"stale", "popular"} {
+				img := &automotivev1.Image{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, img); err == nil {
+					Expect(k8sClient.Delete(ctx, img)).To(Succeed())
+				}
+			}
+		})
+
+		It("should deprecate stale images and keep images accessed often enough", func() {
+			setPolicy(&automotivev1.ImageGCPolicy{Enabled: true, MaxAgeDays: 7, MinAccesses: 5})
+			stale := createImage("stale", 2)
+			popular := createImage("popular", 10)
+
+			reconcileImage(stale)
+			reconcileImage(popular)
+
+			img := &automotivev1.Image{}
+			Expect(k8sClient.Get(ctx, stale, img)).To(Succeed())
+			Expect(img.Spec.Lifecycle).To(Equal(automotivev1.ImageLifecycleDeprecated))
+			Expect(meta.IsStatusConditionTrue(img.Status.Conditions, automotivev1.ImageGarbageCollected)).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, popular, img)).To(Succeed())
+			Expect(img.Spec.Lifecycle).To(Equal(automotivev1.ImageLifecycleCandidate))
+			Expect(meta.FindStatusCondition(img.Status.Conditions, automotivev1.ImageGarbageCollected)).To(BeNil())
+			Expect(deleted).To(BeEmpty())
+		})
+
+		It("should delete stale images from the registry and remove them", func() {
+			setPolicy(&automotivev1.ImageGCPolicy{Enabled: true, MaxAgeDays: 7, Action: automotivev1.ImageGCDelete})
+			stale := createImage("stale", 0)
+
+			reconcileImage(stale)
+
+			Expect(deleted).To(Equal([]string{"/v2/org/autosd/manifests/sha256:stale"}))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, stale, &automotivev1.Image{}))).To(BeTrue())
+		})
+	})
 })
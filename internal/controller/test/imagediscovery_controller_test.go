@@ -0,0 +1,144 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/controller/discovery"
+)
+
+var _ = Describe("ImageDiscovery Controller", func() {
+	Context("When scanning a registry", func() {
+		const resourceName = "test-discovery"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		var registry *httptest.Server
+
+		BeforeEach(func() {
+			By("starting a fake registry with an annotated, a typed and an unrelated tag")
+			mux := http.NewServeMux()
+			mux.HandleFunc("/v2/org/autosd/tags/list", func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"name":"org/autosd","tags":["v1","v2","other"]}`))
+			})
+			mux.HandleFunc("/v2/org/autosd/manifests/v1", func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Docker-Content-Digest", "sha256:aaaa")
+				_, _ = w.Write([]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json",
+					"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","size":1024}],
+					"annotations":{"automotive.sdv.cloud.redhat.com/distro":"autosd",
+					"automotive.sdv.cloud.redhat.com/target":"qemu",
+					"automotive.sdv.cloud.redhat.com/export-format":"qcow2"}}`))
+			})
+			mux.HandleFunc("/v2/org/autosd/manifests/v2", func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Docker-Content-Digest", "sha256:cccc")
+				_, _ = w.Write([]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json",
+					"artifactType":"application/vnd.redhat.automotive.image.v1",
+					"layers":[{"mediaType":"application/vnd.redhat.automotive.image.simg.v1","size":2048}]}`))
+			})
+			mux.HandleFunc("/v2/org/autosd/manifests/other", func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Docker-Content-Digest", "sha256:bbbb")
+				_, _ = w.Write([]byte(`{"mediaType":"application/vnd.oci.image.manifest.v1+json"}`))
+			})
+			registry = httptest.NewServer(mux)
+
+			By("creating an AutomotiveDev with discovery enabled")
+			resource := &automotivev1.AutomotiveDev{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: automotivev1.AutomotiveDevSpec{
+					ImageDiscovery: &automotivev1.ImageDiscovery{
+						Enabled: true,
+						Repositories: []automotivev1.DiscoveryRepository{{
+							Repository: strings.TrimPrefix(registry.URL, "http://") + "/org/autosd",
+							Insecure:   true,
+						}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			registry.Close()
+
+			By("Cleanup the AutomotiveDev and discovered Images")
+			resource := &automotivev1.AutomotiveDev{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &automotivev1.Image{}, client.InNamespace("default"),
+				client.MatchingLabels{"automotive.sdv.cloud.redhat.com/discovered": "true"})).To(Succeed())
+		})
+
+		It("should import annotated images and record the scan", func() {
+			controllerReconciler := &discovery.ImageDiscoveryReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Log:    logr.Discard(),
+			}
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Checking only the automotive tags became Images")
+			images := &automotivev1.ImageList{}
+			Expect(k8sClient.List(ctx, images, client.InNamespace("default"),
+				client.MatchingLabels{"automotive.sdv.cloud.redhat.com/discovered": "true"})).To(Succeed())
+			Expect(images.Items).To(HaveLen(2))
+
+			img := &automotivev1.Image{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "autosd-v1", Namespace: "default"}, img)).To(Succeed())
+			Expect(img.Spec.Distro).To(Equal("autosd"))
+			Expect(img.Spec.ExportFormat).To(Equal("qcow2"))
+			Expect(img.Spec.Location.Registry.Digest).To(Equal("sha256:aaaa"))
+			Expect(*img.Spec.Size.CompressedBytes).To(Equal(int64(1024)))
+
+			By("Checking the export format of an unannotated image comes from its layer media type")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "autosd-v2", Namespace: "default"}, img)).To(Succeed())
+			Expect(img.Spec.ExportFormat).To(Equal("simg"))
+
+			By("Checking the scan is recorded on the AutomotiveDev")
+			av := &automotivev1.AutomotiveDev{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, av)).To(Succeed())
+			Expect(av.Status.Discovery).NotTo(BeNil())
+			Expect(av.Status.Discovery.ImportedImages).To(Equal(int32(2)))
+			Expect(av.Status.Discovery.Message).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,309 @@
+// Package podexec runs commands in pods over the exec subresource and moves files in and out of them with
+// it. The transport is an Executor, the SPDY executor of client-go against a cluster, so that callers and
+// their tests share the same copy, retry and error handling.
+package podexec
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	kscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// maxStderr bounds the stderr of a failed command quoted in its error
+const maxStderr = 4096
+
+// Target is the container a command runs in
+type Target struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// Streams are the standard streams of a command; nil streams are not attached
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Executor runs a command in a container until it exits or ctx is done. A command that ran and exited
+// with a non-zero status is reported as a k8s.io/client-go/util/exec.ExitError.
+type Executor interface {
+	Stream(ctx context.Context, target Target, command []string, streams Streams) error
+}
+
+// Progress is called with the number of bytes transferred so far as a copy advances. A retried copy starts
+// counting again from zero.
+type Progress func(transferred int64)
+
+// spdyExecutor runs commands through the API server with the SPDY protocol
+type spdyExecutor struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+}
+
+// NewSPDYExecutor returns the Executor reaching pods through the API server of config
+func NewSPDYExecutor(config *rest.Config) (Executor, error) {
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &spdyExecutor{config: config, clientset: cs}, nil
+}
+
+func (e *spdyExecutor) Stream(ctx context.Context, target Target, command []string, streams Streams) error {
+	req := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(target.Pod).
+		Namespace(target.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: target.Container,
+			Command:   command,
+			Stdin:     streams.Stdin != nil,
+			Stdout:    streams.Stdout != nil,
+			Stderr:    streams.Stderr != nil,
+		}, kscheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(e.config, http.MethodPost, req.URL())
+	if err != nil {
+		return fmt.Errorf("executor: %w", err)
+	}
+	return exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  streams.Stdin,
+		Stdout: streams.Stdout,
+		Stderr: streams.Stderr,
+	})
+}
+
+// Client runs commands and copies files with an Executor
+type Client struct {
+	executor   Executor
+	retries    int
+	retryDelay time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithRetries retries a failed transfer up to retries times, waiting delay before the first retry and
+// doubling it after each. Only failures of the connection are retried, and only when the attempt can be
+// repeated: commands that exited with an error, and streams the caller supplied that were partly consumed
+// or written, are not.
+func WithRetries(retries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryDelay = delay
+	}
+}
+
+// New returns a Client running commands with executor, without retries unless WithRetries says otherwise
+func New(executor Executor, opts ...Option) *Client {
+	c := &Client{executor: executor}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Exec runs command in target with stdin, which may be nil, and streams its stdout to stdout. The stderr of
+// a command that fails is quoted in the error.
+func (c *Client) Exec(ctx context.Context, target Target, command []string, stdin io.Reader, stdout io.Writer) error {
+	in := &countingReader{r: stdin}
+	out := &countingWriter{w: stdout}
+	return c.retry(ctx, func() (bool, error) {
+		var streams Streams
+		if stdin != nil {
+			streams.Stdin = in
+		}
+		if stdout != nil {
+			streams.Stdout = out
+		}
+		err := c.stream(ctx, target, command, streams)
+		return in.n == 0 && out.n == 0, err
+	})
+}
+
+// StreamFile writes the content of the file at podPath in target to w
+func (c *Client) StreamFile(ctx context.Context, target Target, podPath string, w io.Writer, progress Progress) error {
+	out := &countingWriter{w: w, progress: progress}
+	return c.retry(ctx, func() (bool, error) {
+		err := c.stream(ctx, target, []string{"cat", "--", podPath}, Streams{Stdout: out})
+		return out.n == 0, err
+	})
+}
+
+// CopyFromPod copies the file at podPath in target to localPath. The file is written next to localPath and
+// renamed over it once complete, so localPath never holds a partial copy.
+func (c *Client) CopyFromPod(ctx context.Context, target Target, podPath, localPath string, progress Progress) error {
+	return c.retry(ctx, func() (bool, error) {
+		return true, c.copyFromPod(ctx, target, podPath, localPath, progress)
+	})
+}
+
+func (c *Client) copyFromPod(ctx context.Context, target Target, podPath, localPath string, progress Progress) error {
+	tmp, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	out := &countingWriter{w: tmp, progress: progress}
+	if err := c.stream(ctx, target, []string{"cat", "--", podPath}, Streams{Stdout: out}); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), localPath)
+}
+
+// CopyToPod copies the local file at localPath to podPath in target, creating the parent directories of
+// podPath as needed. It streams a tar archive, so the container needs sh, mkdir and tar.
+func (c *Client) CopyToPod(ctx context.Context, target Target, localPath, podPath string, progress Progress) error {
+	return c.retry(ctx, func() (bool, error) {
+		return true, c.copyToPod(ctx, target, localPath, podPath, progress)
+	})
+}
+
+func (c *Client) copyToPod(ctx context.Context, target Target, localPath, podPath string, progress Progress) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	// closing the reader stops the writer when the command exits before reading everything
+	defer pr.Close()
+	go func() {
+		tw := tar.NewWriter(pw)
+		hdr := &tar.Header{Name: path.Base(podPath), Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(tw, &countingReader{r: f, progress: progress}); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(tw.Close())
+	}()
+
+	// the directory is passed as a positional parameter so the shell never parses it
+	cmd := []string{"/bin/sh", "-c", `mkdir -p -- "$1" && tar -x -C "$1"`, "sh", path.Dir(podPath)}
+	return c.stream(ctx, target, cmd, Streams{Stdin: pr})
+}
+
+// stream runs command, quoting its stderr in the error if it fails
+func (c *Client) stream(ctx context.Context, target Target, command []string, streams Streams) error {
+	stderr := &tailBuffer{max: maxStderr}
+	streams.Stderr = stderr
+	err := c.executor.Stream(ctx, target, command, streams)
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%w: %s", err, msg)
+	}
+	return err
+}
+
+// retry calls attempt until it succeeds, returns an error that is not worth retrying, or the retries are
+// used up. attempt reports whether it may be repeated.
+func (c *Client) retry(ctx context.Context, attempt func() (repeatable bool, err error)) error {
+	delay := c.retryDelay
+	for i := 0; ; i++ {
+		repeatable, err := attempt()
+		// commands that exited with an error would fail the same way again
+		if err == nil || !repeatable || i >= c.retries || IsExitError(err) || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// IsExitError reports whether err is a command that ran and exited with a non-zero status, as opposed to
+// a command that could not be run
+func IsExitError(err error) bool {
+	var exitErr utilexec.ExitError
+	return errors.As(err, &exitErr)
+}
+
+type countingReader struct {
+	r        io.Reader
+	n        int64
+	progress Progress
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		if r.progress != nil {
+			r.progress(r.n)
+		}
+	}
+	return n, err
+}
+
+type countingWriter struct {
+	w        io.Writer
+	n        int64
+	progress Progress
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.n += int64(n)
+		if w.progress != nil {
+			w.progress(w.n)
+		}
+	}
+	return n, err
+}
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = b.buf[over:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,13 @@
+package podexec
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestPodexec(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "podexec Suite")
+}
@@ -0,0 +1,137 @@
+package podexec
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// fakeExecutor answers each call with the next of its responses, recording the commands it ran
+type fakeExecutor struct {
+	responses []func(streams Streams) error
+	commands  [][]string
+}
+
+func (f *fakeExecutor) Stream(_ context.Context, _ Target, command []string, streams Streams) error {
+	f.commands = append(f.commands, command)
+	respond := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+	return respond(streams)
+}
+
+var errConnection = errors.New("connection reset")
+
+var _ = Describe("Client", func() {
+	ctx := context.Background()
+	target := Target{Namespace: "ns", Pod: "pod", Container: "c"}
+
+	It("should stream stdin and stdout and quote the stderr of a failing command", func() {
+		fake := &fakeExecutor{responses: []func(Streams) error{
+			func(s Streams) error {
+				in, _ := io.ReadAll(s.Stdin)
+				_, _ = io.WriteString(s.Stdout, strings.ToUpper(string(in)))
+				return nil
+			},
+			func(s Streams) error {
+				_, _ = io.WriteString(s.Stderr, "tar: short read\n")
+				return utilexec.CodeExitError{Err: errors.New("command terminated with exit code 2"), Code: 2}
+			},
+		}}
+		c := New(fake, WithRetries(3, time.Millisecond))
+
+		var out strings.Builder
+		Expect(c.Exec(ctx, target, []string{"tr"}, strings.NewReader("abc"), &out)).To(Succeed())
+		Expect(out.String()).To(Equal("ABC"))
+
+		err := c.Exec(ctx, target, []string{"tar"}, nil, io.Discard)
+		Expect(err).To(MatchError(ContainSubstring("exit code 2: tar: short read")))
+		Expect(IsExitError(err)).To(BeTrue())
+		Expect(fake.commands).To(HaveLen(2), "exit errors are not retried")
+	})
+
+	It("should retry connection failures only while nothing was streamed", func() {
+		fake := &fakeExecutor{responses: []func(Streams) error{
+			func(Streams) error { return errConnection },
+			func(s Streams) error {
+				_, _ = io.WriteString(s.Stdout, "partial")
+				return errConnection
+			},
+		}}
+		c := New(fake, WithRetries(3, time.Millisecond))
+
+		var out strings.Builder
+		Expect(c.StreamFile(ctx, target, "/data/a b", &out, nil)).To(MatchError(errConnection))
+		Expect(out.String()).To(Equal("partial"))
+		Expect(fake.commands).To(Equal([][]string{{"cat", "--", "/data/a b"}, {"cat", "--", "/data/a b"}}))
+	})
+
+	It("should copy a file into a pod as a tar archive, retrying from the start", func() {
+		local := filepath.Join(GinkgoT().TempDir(), "upload")
+		Expect(os.WriteFile(local, []byte("manifest"), 0o600)).To(Succeed())
+
+		var received map[string]string
+		fake := &fakeExecutor{responses: []func(Streams) error{
+			func(s Streams) error {
+				_, _ = io.CopyN(io.Discard, s.Stdin, 10)
+				return errConnection
+			},
+			func(s Streams) error {
+				received = map[string]string{}
+				tr := tar.NewReader(s.Stdin)
+				for {
+					hdr, err := tr.Next()
+					if err == io.EOF {
+						return nil
+					}
+					if err != nil {
+						return err
+					}
+					b, _ := io.ReadAll(tr)
+					received[hdr.Name] = string(b)
+				}
+			},
+		}}
+		var progress []int64
+		c := New(fake, WithRetries(1, time.Millisecond))
+
+		Expect(c.CopyToPod(ctx, target, local, "/workspace/my dir/x.aib.yml", func(n int64) {
+			progress = append(progress, n)
+		})).To(Succeed())
+		Expect(received).To(Equal(map[string]string{"x.aib.yml": "manifest"}))
+		Expect(fake.commands).To(HaveLen(2))
+		Expect(fake.commands[1]).To(Equal([]string{"/bin/sh", "-c", `mkdir -p -- "$1" && tar -x -C "$1"`, "sh", "/workspace/my dir"}))
+		Expect(progress).To(ContainElement(int64(len("manifest"))))
+	})
+
+	It("should only leave a complete file behind when copying from a pod", func() {
+		local := filepath.Join(GinkgoT().TempDir(), "artifact.img")
+		fake := &fakeExecutor{responses: []func(Streams) error{
+			func(s Streams) error {
+				_, _ = io.WriteString(s.Stdout, "trunc")
+				return errConnection
+			},
+		}}
+		Expect(New(fake).CopyFromPod(ctx, target, "/out/artifact.img", local, nil)).To(MatchError(errConnection))
+		Expect(local).NotTo(BeAnExistingFile())
+		entries, _ := os.ReadDir(filepath.Dir(local))
+		Expect(entries).To(BeEmpty())
+
+		fake.responses = []func(Streams) error{func(s Streams) error {
+			_, _ = io.WriteString(s.Stdout, "image content")
+			return nil
+		}}
+		Expect(New(fake).CopyFromPod(ctx, target, "/out/artifact.img", local, nil)).To(Succeed())
+		Expect(os.ReadFile(local)).To(Equal([]byte("image content")))
+	})
+})
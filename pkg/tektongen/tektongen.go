@@ -0,0 +1,121 @@
+// Package tektongen generates the Tekton Tasks and Pipeline the operator installs to build automotive images,
+// for pipeline authors who manage them outside the operator, e.g. in a GitOps repository. The functions and
+// options of this package are stable: new options are added as fields whose zero value keeps the current
+// output, and the resources change only with the builds they run, which the golden files under testdata record.
+package tektongen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+// DefaultPipelineName is the name of the Pipeline the operator installs
+const DefaultPipelineName = "automotive-build-pipeline"
+
+// Options configure every generated resource
+type Options struct {
+	// Namespace of the resources; empty leaves it unset, so they are created in the namespace they are applied to
+	Namespace string
+	// BuildConfig tunes the builds like the AutomotiveDev's spec.buildConfig, e.g. with the images they run;
+	// nil uses the operator's defaults
+	BuildConfig *automotivev1.BuildConfig
+}
+
+// BuildTaskOptions configure the build Task
+type BuildTaskOptions struct {
+	Options
+	// EnvSecretRef names a secret whose keys the build steps get as environment variables; empty for none
+	EnvSecretRef string
+}
+
+// PipelineOptions configure the Pipeline
+type PipelineOptions struct {
+	Options
+	// Name of the Pipeline; DefaultPipelineName if empty
+	Name string
+}
+
+// Validate reports the images of opts' BuildConfig that builds must not run: invalid references and digests,
+// and images without a digest when the BuildConfig requires digests
+func Validate(opts Options) error {
+	return tasks.ValidateImages(opts.BuildConfig)
+}
+
+// BuildTask returns the build-automotive-image Task, which builds an image and serves or pushes its artifact
+func BuildTask(opts BuildTaskOptions) *tektonv1.Task {
+	return tasks.GenerateBuildAutomotiveImageTask(opts.Namespace, opts.BuildConfig, opts.EnvSecretRef)
+}
+
+// PushArtifactTask returns the push-artifact-registry Task, which pushes a built artifact to an OCI registry
+func PushArtifactTask(opts Options) *tektonv1.Task {
+	return tasks.GeneratePushArtifactRegistryTask(opts.Namespace, opts.BuildConfig)
+}
+
+// Pipeline returns the Pipeline that runs the build Task and, when asked to, pushes its artifact
+func Pipeline(opts PipelineOptions) *tektonv1.Pipeline {
+	name := opts.Name
+	if name == "" {
+		name = DefaultPipelineName
+	}
+	return tasks.GenerateTektonPipeline(name, opts.Namespace, opts.BuildConfig)
+}
+
+// Tasks returns the Tasks the operator installs for the Pipeline
+func Tasks(opts Options) []*tektonv1.Task {
+	return []*tektonv1.Task{
+		BuildTask(BuildTaskOptions{Options: opts}),
+		PushArtifactTask(opts),
+	}
+}
+
+// Resources returns every resource the operator installs, the Tasks followed by the Pipeline
+func Resources(opts PipelineOptions) []runtime.Object {
+	var objs []runtime.Object
+	for _, task := range Tasks(opts.Options) {
+		objs = append(objs, task)
+	}
+	return append(objs, Pipeline(opts))
+}
+
+// ToYAML renders a generated resource as YAML, without the fields the API server sets on creation
+func ToYAML(obj runtime.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", obj, err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %w", obj, err)
+	}
+	if metadata, ok := fields["metadata"].(map[string]any); ok {
+		delete(metadata, "creationTimestamp")
+	}
+	delete(fields, "status")
+	return yaml.Marshal(fields)
+}
+
+// WriteYAML writes resources to w as a multi-document YAML stream, e.g. to commit them to a GitOps repository
+func WriteYAML(w io.Writer, objs ...runtime.Object) error {
+	var out bytes.Buffer
+	for i, obj := range objs {
+		data, err := ToYAML(obj)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(data)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,13 @@
+package tektongen
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestTektongen(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tektongen Suite")
+}
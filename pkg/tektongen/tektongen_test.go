@@ -0,0 +1,168 @@
+package tektongen
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	automotivev1 "github.com/rh-sdv-cloud-incubator/automotive-dev-operator/api/v1"
+	"github.com/rh-sdv-cloud-incubator/automotive-dev-operator/internal/common/tasks"
+)
+
+// update rewrites the golden files with the current output: go test ./pkg/tektongen -update
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// expectGolden compares objs rendered as YAML with the golden file testdata/name
+func expectGolden(name string, objs ...runtime.Object) {
+	var out bytes.Buffer
+	Expect(WriteYAML(&out, objs...)).To(Succeed())
+	path := filepath.Join("testdata", name)
+	if *update {
+		Expect(os.WriteFile(path, out.Bytes(), 0o644)).To(Succeed())
+	}
+	golden, err := os.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred(), "run go test ./pkg/tektongen -update to create the golden file")
+	Expect(out.String()).To(Equal(string(golden)), "run go test ./pkg/tektongen -update if the change is intended")
+}
+
+var _ = Describe("Generated resources", func() {
+	It("should match the golden files with the defaults", func() {
+		expectGolden("default.yaml", Resources(PipelineOptions{})...)
+	})
+
+	It("should match the golden files with a tuned BuildConfig", func() {
+		opts := Options{
+			Namespace: "automotive-builds",
+			BuildConfig: &automotivev1.BuildConfig{
+				Images: &automotivev1.ImageOverrides{
+					Builder: "registry.example.com/aib:1.1.0",
+					Yq:      "registry.example.com/yq:4",
+					Oras:    "registry.example.com/oras:1.2.0",
+				},
+				WorkspaceKeep: []string{"*.log"},
+			},
+		}
+		expectGolden("custom.yaml",
+			BuildTask(BuildTaskOptions{Options: opts, EnvSecretRef: "build-env"}),
+			PushArtifactTask(opts),
+			Pipeline(PipelineOptions{Options: opts, Name: "partner-build"}),
+		)
+	})
+
+	It("should refuse invalid images, and images without a digest when digests are required", func() {
+		Expect(Validate(Options{})).To(Succeed())
+
+		pinned := "@sha256:" + strings.Repeat("ab", 32)
+		buildConfig := &automotivev1.BuildConfig{Images: &automotivev1.ImageOverrides{
+			Yq:             "registry.example.com/yq:4" + pinned,
+			Oras:           "registry.example.com/oras" + pinned,
+			FileServer:     "registry.example.com/nginx" + pinned,
+			OAuthProxy:     "registry.example.com/oauth-proxy" + pinned,
+			Registry:       "registry.example.com/registry" + pinned,
+			RequireDigests: true,
+		}}
+		By("leaving the builder image to the digest pinning of builds")
+		Expect(Validate(Options{BuildConfig: buildConfig})).To(Succeed())
+
+		buildConfig.BuilderImage = &automotivev1.BuilderImagePolicy{DisableDigestPinning: true}
+		Expect(Validate(Options{BuildConfig: buildConfig})).To(MatchError(ContainSubstring("builder: " + tasks.AutomotiveImageBuilder + " is not pinned by digest")))
+		buildConfig.BuilderImage = nil
+
+		buildConfig.Scan = &automotivev1.ScanPolicy{Enabled: true}
+		Expect(Validate(Options{BuildConfig: buildConfig})).To(MatchError(ContainSubstring("scan.image")))
+		buildConfig.Scan.Image = "registry.example.com/trivy" + pinned
+		Expect(Validate(Options{BuildConfig: buildConfig})).To(Succeed())
+
+		buildConfig.Images.Yq = "registry.example.com/yq@sha256:short"
+		Expect(Validate(Options{BuildConfig: buildConfig})).To(MatchError(ContainSubstring(`yq: invalid digest "sha256:short"`)))
+		buildConfig.Images.RequireDigests = false
+		Expect(Validate(Options{BuildConfig: buildConfig})).NotTo(Succeed())
+	})
+
+	It("should build rootless without privileges or host devices when the BuildConfig asks for it", func() {
+		task := BuildTask(BuildTaskOptions{Options: Options{BuildConfig: &automotivev1.BuildConfig{Unprivileged: true}}})
+		for _, step := range task.Spec.Steps {
+			if step.SecurityContext != nil {
+				Expect(step.SecurityContext.Privileged).To(HaveValue(BeFalse()), "step %s", step.Name)
+				Expect(step.SecurityContext.SELinuxOptions).To(BeNil(), "step %s", step.Name)
+			}
+			Expect(step.VolumeMounts).NotTo(ContainElement(HaveField("MountPath", "/dev")), "step %s", step.Name)
+		}
+		for _, vol := range task.Spec.Volumes {
+			Expect(vol.HostPath).To(BeNil(), "volume %s", vol.Name)
+		}
+		Expect(task.Spec.Steps).To(ContainElement(And(
+			HaveField("Name", "build-image"),
+			HaveField("Env", ContainElement(HaveField("Value", "container ostree-commit rpmlist tar"))),
+		)))
+	})
+
+	It("should only pass the tasks of the pipeline what they declare, and everything they require", func() {
+		tasks := map[string]*tektonv1.Task{}
+		for _, task := range Tasks(Options{}) {
+			tasks[task.Name] = task
+		}
+		pipeline := Pipeline(PipelineOptions{})
+		Expect(pipeline.Spec.Tasks).NotTo(BeEmpty())
+		for _, pt := range pipeline.Spec.Tasks {
+			var ref string
+			for _, p := range pt.TaskRef.Params {
+				if p.Name == "name" {
+					ref = p.Value.StringVal
+				}
+			}
+			task, ok := tasks[ref]
+			Expect(ok).To(BeTrue(), "pipeline task %s runs task %q, which is not generated", pt.Name, ref)
+
+			passed := map[string]bool{}
+			for _, p := range pt.Params {
+				passed[p.Name] = true
+				Expect(task.Spec.Params).To(ContainElement(HaveField("Name", p.Name)),
+					"pipeline task %s passes param %s, which task %s does not declare", pt.Name, p.Name, task.Name)
+			}
+			for _, p := range task.Spec.Params {
+				if p.Default == nil {
+					Expect(passed).To(HaveKey(p.Name), "pipeline task %s does not pass required param %s", pt.Name, p.Name)
+				}
+			}
+
+			bound := map[string]bool{}
+			for _, w := range pt.Workspaces {
+				bound[w.Name] = true
+			}
+			for _, w := range task.Spec.Workspaces {
+				if !w.Optional {
+					Expect(bound).To(HaveKey(w.Name), "pipeline task %s does not bind required workspace %s", pt.Name, w.Name)
+				}
+			}
+			for name := range bound {
+				Expect(task.Spec.Workspaces).To(ContainElement(HaveField("Name", name)),
+					"pipeline task %s binds workspace %s, which task %s does not declare", pt.Name, name, task.Name)
+			}
+		}
+	})
+
+	It("should name the pipeline after the operator's unless told otherwise", func() {
+		Expect(Pipeline(PipelineOptions{}).Name).To(Equal(DefaultPipelineName))
+		Expect(Pipeline(PipelineOptions{Name: "partner-build"}).Name).To(Equal("partner-build"))
+	})
+
+	It("should render resources that can be applied as they are", func() {
+		data, err := ToYAML(BuildTask(BuildTaskOptions{}))
+		Expect(err).NotTo(HaveOccurred())
+		var fields map[string]any
+		Expect(yaml.Unmarshal(data, &fields)).To(Succeed())
+		Expect(fields).To(HaveKeyWithValue("apiVersion", "tekton.dev/v1"))
+		Expect(fields).To(HaveKeyWithValue("kind", "Task"))
+		Expect(fields["metadata"]).NotTo(HaveKey("creationTimestamp"))
+		Expect(fields["metadata"]).NotTo(HaveKey("namespace"))
+	})
+})